@@ -0,0 +1,197 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// AuditHook records row mutations.
+// The hook runs after the mutation succeeded, so with OtsUtilsParams.AuditFatal
+// a hook failure is reported even though the row has already changed.
+// op is "PutRow", "UpdateRow" or "DeleteRow"; before is nil when the row did not exist
+// or OtsUtilsParams.AuditCheap is set, after is nil for deletes.
+type AuditHook interface {
+	RecordMutation(ctx context.Context, op string, table string, pks []KeyValue, before, after []KeyValue) error
+}
+
+// mutationAudit carries the state of one audited mutation between execution steps.
+type mutationAudit struct {
+	op     string
+	table  string
+	pks    []KeyValue
+	before []KeyValue
+	after  func(before []KeyValue) []KeyValue
+}
+
+// beginAudit captures the before image of a mutation request.
+// It returns nil when the request is not a mutation.
+func beginAudit(otsParams *OtsUtilsParams, client OtsClient, req any) (*mutationAudit, error) {
+	var audit *mutationAudit
+	var primaryKey *tablestore.PrimaryKey
+
+	switch r := req.(type) {
+	case *tablestore.PutRowRequest:
+		change := r.PutRowChange
+		primaryKey = change.PrimaryKey
+		audit = &mutationAudit{op: "PutRow", table: change.TableName, after: func([]KeyValue) []KeyValue {
+			after := make([]KeyValue, 0, len(change.Columns))
+			for _, col := range change.Columns {
+				after = append(after, KeyValue{Key: col.ColumnName, Value: col.Value})
+			}
+			return after
+		}}
+	case *tablestore.UpdateRowRequest:
+		change := r.UpdateRowChange
+		primaryKey = change.PrimaryKey
+		audit = &mutationAudit{op: "UpdateRow", table: change.TableName, after: func(before []KeyValue) []KeyValue {
+			return applyColumnUpdates(before, change.Columns)
+		}}
+	case *tablestore.DeleteRowRequest:
+		change := r.DeleteRowChange
+		primaryKey = change.PrimaryKey
+		audit = &mutationAudit{op: "DeleteRow", table: change.TableName, after: func([]KeyValue) []KeyValue {
+			return nil
+		}}
+	default:
+		return nil, nil
+	}
+	audit.pks = primaryKeyToKeyValues(primaryKey)
+
+	if otsParams.AuditCheap {
+		return audit, nil
+	}
+	// A row whose primary key the service assigns is new
+	for _, col := range primaryKey.PrimaryKeys {
+		if col.PrimaryKeyOption == tablestore.AUTO_INCREMENT {
+			return audit, nil
+		}
+	}
+
+	resp, err := client.GetRow(&tablestore.GetRowRequest{SingleRowQueryCriteria: &tablestore.SingleRowQueryCriteria{
+		TableName:  audit.table,
+		PrimaryKey: primaryKey,
+		MaxVersion: 1,
+	}})
+	if err != nil {
+		return audit, fmt.Errorf("audit: read before image: %w", err)
+	}
+	if len(resp.PrimaryKey.PrimaryKeys) > 0 {
+		audit.before = columnsToKeyValues(resp.Columns)
+	}
+	return audit, nil
+}
+
+// record invokes the hook with the before and after images.
+func (audit *mutationAudit) record(ctx context.Context, hook AuditHook) error {
+	if err := hook.RecordMutation(ctx, audit.op, audit.table, audit.pks, audit.before, audit.after(audit.before)); err != nil {
+		return fmt.Errorf("audit: record mutation: %w", err)
+	}
+	return nil
+}
+
+// auditFailed reports whether an audit error should fail the operation, logging it otherwise.
+func (otsUtilsParams *OtsUtilsParams) auditFailed(logger *zerolog.Logger, err error) bool {
+	if otsUtilsParams.AuditFatal {
+		return true
+	}
+	logger.Warn().Err(err).Msg("Audit failed")
+	return false
+}
+
+// applyColumnUpdates returns the row produced by applying the update to before.
+func applyColumnUpdates(before []KeyValue, updates []tablestore.ColumnToUpdate) []KeyValue {
+	after := append([]KeyValue(nil), before...)
+	index := func(name string) int {
+		for i, kv := range after {
+			if kv.Key == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, u := range updates {
+		i := index(u.ColumnName)
+		switch {
+		case u.HasType && (u.Type == tablestore.DELETE_ALL_VERSION || u.Type == tablestore.DELETE_ONE_VERSION):
+			if i >= 0 {
+				after = append(after[:i], after[i+1:]...)
+			}
+		case u.HasType && u.Type == tablestore.INCREMENT:
+			delta, _ := u.Value.(int64)
+			if i < 0 {
+				after = append(after, KeyValue{Key: u.ColumnName, Value: delta})
+			} else if v, ok := after[i].Value.(int64); ok {
+				after[i].Value = v + delta
+			}
+		default:
+			if i < 0 {
+				after = append(after, KeyValue{Key: u.ColumnName, Value: u.Value})
+			} else {
+				after[i].Value = u.Value
+			}
+		}
+	}
+	return after
+}
+
+// TableAuditHook is an AuditHook that writes one row per mutation into an OTS table.
+// The table must have a single STRING primary key named "id"; ids are ULIDs, so rows sort by time.
+// Each row has the columns "op", "table", "time" (unix milliseconds) and
+// "pks", "before", "after" holding the key-value pairs in the JSON form of KeyValue, plus "tags" holding the
+// JSON encoded RequestTags when the context has any.
+//
+// Example usage:
+//
+//	params := OtsUtilsParams{
+//	    Client:    client,
+//	    TableName: "users",
+//	    AuditHook: &TableAuditHook{Client: client, TableName: "users_audit"},
+//	}
+type TableAuditHook struct {
+	Client    OtsClient
+	TableName string
+}
+
+// RecordMutation implements AuditHook.
+func (h *TableAuditHook) RecordMutation(ctx context.Context, op string, table string, pks []KeyValue, before, after []KeyValue) error {
+	cols := []KeyValue{
+		{Key: "op", Value: op},
+		{Key: "table", Value: table},
+		{Key: "time", Value: time.Now().UnixMilli()},
+	}
+	for _, image := range []struct {
+		name string
+		kvs  []KeyValue
+	}{{"pks", pks}, {"before", before}, {"after", after}} {
+		if image.kvs == nil {
+			continue
+		}
+		data, err := json.Marshal(image.kvs)
+		if err != nil {
+			return err
+		}
+		cols = append(cols, KeyValue{Key: image.name, Value: string(data)})
+	}
+	if tags := RequestTags(ctx); tags != nil {
+		data, err := json.Marshal(tags)
+		if err != nil {
+			return err
+		}
+		cols = append(cols, KeyValue{Key: "tags", Value: string(data)})
+	}
+
+	id, err := newULID()
+	if err != nil {
+		return err
+	}
+
+	o := OtsUtilsParams{Client: h.Client, TableName: h.TableName}
+	return PutRowMap(o.WithContext(ctx), []KeyValue{{Key: "id", Value: id}}, cols)
+}
@@ -0,0 +1,139 @@
+package otsutils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+type auditRecord struct {
+	op     string
+	table  string
+	pks    []KeyValue
+	before []KeyValue
+	after  []KeyValue
+}
+
+type recordingAuditHook struct {
+	records []auditRecord
+	err     error
+}
+
+func (h *recordingAuditHook) RecordMutation(ctx context.Context, op string, table string, pks []KeyValue, before, after []KeyValue) error {
+	h.records = append(h.records, auditRecord{op, table, pks, before, after})
+	return h.err
+}
+
+func TestAuditHook(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	hook := &recordingAuditHook{}
+	OtsUtilsParamsFromCtx(ctx).AuditHook = hook
+
+	pks := []KeyValue{{Key: "pk1", Value: "pk1"}, {Key: "pk2", Value: int64(1)}}
+
+	ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col1: tea.String("a"), Col2: tea.Int64(1)}))
+	ast.NoError(UpdateRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col1: tea.String("b")}, UpdateRowParams{DeletedColumns: []string{"col2"}}))
+	ast.NoError(DeleteRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)}))
+
+	ast.Equal([]auditRecord{
+		{"PutRow", "test_table", pks, nil, []KeyValue{{Key: "col1", Value: "a"}, {Key: "col2", Value: int64(1)}}},
+		{"UpdateRow", "test_table", pks, []KeyValue{{Key: "col1", Value: "a"}, {Key: "col2", Value: int64(1)}}, []KeyValue{{Key: "col1", Value: "b"}}},
+		{"DeleteRow", "test_table", pks, []KeyValue{{Key: "col1", Value: "b"}}, nil},
+	}, hook.records)
+	ast.Equal(3, fake.CallCount("GetRow"))
+
+	// 读操作不触发审计
+	ast.NoError(GetRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)}))
+	ast.Len(hook.records, 3)
+
+	// 失败的写操作不触发审计
+	ast.Error(PutRow(ctx, &TestRow{Pk1: tea.String("pk1")}))
+	ast.Len(hook.records, 3)
+}
+
+func TestAuditHookCheap(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	hook := &recordingAuditHook{}
+	OtsUtilsParamsFromCtx(ctx).AuditHook = hook
+	OtsUtilsParamsFromCtx(ctx).AuditCheap = true
+
+	ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col1: tea.String("a")}))
+	ast.NoError(UpdateRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col2: tea.Int64(2)}))
+
+	ast.Equal(0, fake.CallCount("GetRow"))
+	ast.Len(hook.records, 2)
+	ast.Nil(hook.records[1].before)
+	ast.Equal([]KeyValue{{Key: "col2", Value: int64(2)}}, hook.records[1].after)
+}
+
+func TestAuditHookFailure(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	hookErr := errors.New("audit sink down")
+	hook := &recordingAuditHook{err: hookErr}
+	OtsUtilsParamsFromCtx(ctx).AuditHook = hook
+
+	// 默认只记录日志
+	ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col1: tea.String("a")}))
+
+	OtsUtilsParamsFromCtx(ctx).AuditFatal = true
+	err := DeleteRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)})
+	ast.ErrorIs(err, hookErr)
+	ast.Len(hook.records, 2)
+}
+
+type AuditRow struct {
+	Id     *string `json:"id" pk:"1"`
+	Op     *string `json:"op"`
+	Table  *string `json:"table"`
+	Time   *int64  `json:"time"`
+	Pks    *string `json:"pks"`
+	Before *string `json:"before"`
+	After  *string `json:"after"`
+}
+
+func TestTableAuditHook(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	fake.MustCreateTable("audit", "id", tablestore.PrimaryKeyType_STRING)
+
+	OtsUtilsParamsFromCtx(ctx).AuditHook = &TableAuditHook{Client: fake, TableName: "audit"}
+
+	ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col1: tea.String("a")}))
+	ast.NoError(UpdateRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col1: tea.String("b")}))
+
+	o := OtsUtilsParams{Client: fake, TableName: "audit"}
+	var rows []AuditRow
+	ast.NoError(GetRange(o.WithContext(ctx), &AuditRow{}, &AuditRow{}, &rows))
+	if !ast.Len(rows, 2) {
+		return
+	}
+
+	// 同一毫秒内生成的 ULID 不保证有序，按 op 查找
+	byOp := make(map[string]AuditRow)
+	for _, r := range rows {
+		byOp[*r.Op] = r
+	}
+	put, update := byOp["PutRow"], byOp["UpdateRow"]
+	if !ast.NotNil(put.Op) || !ast.NotNil(update.Op) {
+		return
+	}
+
+	ast.Nil(put.Before)
+	ast.Equal("test_table", *update.Table)
+
+	var before []KeyValue
+	ast.NoError(json.Unmarshal([]byte(*update.Before), &before))
+	ast.Equal([]KeyValue{{Key: "col1", Value: "a"}}, before)
+	ast.JSONEq(`[{"key":"col1","type":"string","value":"b"}]`, *update.After)
+}
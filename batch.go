@@ -0,0 +1,88 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// BatchError reports the elements of a batch call that failed. The other elements succeeded.
+type BatchError struct {
+	// Errors is aligned with the objs slice of the call: nil for the elements that succeeded,
+	// and otherwise why the element failed, such as a *RowError when the service failed the row.
+	Errors []error
+}
+
+func (e *BatchError) Error() string {
+	failed, first := 0, -1
+	for i, err := range e.Errors {
+		if err != nil {
+			failed++
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	return fmt.Sprintf("%d of %d rows failed, first at index %d: %v", failed, len(e.Errors), first, e.Errors[first])
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Failed returns the indexes of the elements that failed, in ascending order.
+func (e *BatchError) Failed() []int {
+	var failed []int
+	for i, err := range e.Errors {
+		if err != nil {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
+
+// batchError returns a *BatchError for errs, or nil when every element succeeded.
+func batchError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return &BatchError{Errors: errs}
+		}
+	}
+	return nil
+}
+
+// batchElems returns pointers to the struct elements of objs, a pointer to a slice of structs
+// or of pointers to structs.
+func batchElems(objs any) ([]any, error) {
+	v := reflect.ValueOf(objs)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return nil, fmt.Errorf("objs must be a pointer to a slice of structs, got %T", objs)
+	}
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+	byPointer := elemType.Kind() == reflect.Ptr
+	if byPointer {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("objs must be a pointer to a slice of structs, got %T", objs)
+	}
+
+	elems := make([]any, slice.Len())
+	for i := range elems {
+		elem := slice.Index(i)
+		if !byPointer {
+			elem = elem.Addr()
+		} else if elem.IsNil() {
+			return nil, fmt.Errorf("objs element %d is nil", i)
+		}
+		elems[i] = elem.Interface()
+	}
+	return elems, nil
+}
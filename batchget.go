@@ -0,0 +1,255 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// ErrRowNotFound is reported by BatchGetRows for the elements whose row does not exist, and
+// returned by GetRow and the map getters when GetRowParams.Filter is set, see GetRow.
+var ErrRowNotFound = errors.New("row not found")
+
+// BatchGetRows reads many rows by primary key. objs is a pointer to a slice of structs, or of
+// pointers to structs, whose primary key fields are filled; the attribute columns of each row are
+// assigned to its element, in place. Rows are requested MaxBatchGetRows at a time. Only the
+// columns of the struct are read, unless it has an extra field or BatchGetRowParams.ColumnsToGet
+// is set.
+//
+// A missing row does not fail the call: its element is left unchanged and the returned
+// *BatchError reports ErrRowNotFound at its index. Errors that fail a whole request, such as
+// a missing table, are returned as is.
+//
+// Example usage:
+//
+//	rows := []MyRow{{PK1: tea.String("a")}, {PK1: tea.String("b")}}
+//	err := BatchGetRows(ctx, &rows)
+//	var batchErr *BatchError
+//	if errors.As(err, &batchErr) {
+//	    // batchErr.Errors[i] tells why rows[i] was not read
+//	}
+func BatchGetRows(ctx context.Context, objs any, params ...BatchGetRowParams) error {
+	tables := []BatchGetTable{{TableName: otsUtilsParamsFromCtx(ctx).TableName, Objs: objs}}
+	if err := batchGetTables(ctx, "BatchGetRows", tables, params...); err != nil {
+		return err
+	}
+	return tables[0].Err
+}
+
+// BatchGetTable is the rows of one table read by BatchGetTables.
+type BatchGetTable struct {
+	// TableName is the table the rows are read from, with the client of the context.
+	TableName string
+
+	// Objs is a pointer to a slice of structs, or of pointers to structs, whose primary key
+	// fields are filled, as in BatchGetRows.
+	Objs any
+
+	// Params, when set, replaces the BatchGetRowParams of the call for this table.
+	Params *BatchGetRowParams
+
+	// Err is set by BatchGetTables to a *BatchError reporting the elements of Objs that could
+	// not be read, as in BatchGetRows, or to nil when all were.
+	Err error
+}
+
+// BatchGetTables reads the rows of several tables, sharing each BatchGetRow request between them,
+// MaxBatchGetRows rows at a time. Each table is read with its own projection, versions and filter:
+// its Params, or else the params of the call, and by default the columns of its element type.
+//
+// Rows fail independently, as in BatchGetRows: the Err of each table reports its elements that
+// could not be read, and the returned error joins them. Errors that fail a whole request are
+// returned as is.
+//
+// Example usage:
+//
+//	orders := []Order{{ID: tea.String("o1")}}
+//	users := []User{{ID: tea.String("u1")}, {ID: tea.String("u2")}}
+//	err := BatchGetTables(ctx, []BatchGetTable{
+//	    {TableName: "orders", Objs: &orders},
+//	    {TableName: "users", Objs: &users, Params: &BatchGetRowParams{ColumnsToGet: []string{"name"}}},
+//	})
+func BatchGetTables(ctx context.Context, tables []BatchGetTable, params ...BatchGetRowParams) error {
+	if err := batchGetTables(ctx, "BatchGetTables", tables, params...); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, table := range tables {
+		if table.Err != nil {
+			errs = append(errs, fmt.Errorf("table '%s': %w", table.TableName, table.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// batchGetGroup is the elements of one table read by a BatchGetRow request. A slice of them is
+// the obj passed through the executor.
+type batchGetGroup struct {
+	TableName string
+	Elems     []any
+	Params    BatchGetRowParams
+
+	// columns is the default projection, the columns of the element type
+	columns []string
+
+	// offset is the index of the first element in the objs of the table, whose errors are errs
+	offset int
+	errs   []error
+}
+
+// batchGetTables reads the rows of tables, setting the Err of each, and returns the error of a
+// request failing as a whole.
+func batchGetTables(ctx context.Context, operation string, tables []BatchGetTable, params ...BatchGetRowParams) error {
+	var defaults BatchGetRowParams
+	if len(params) > 0 {
+		defaults = params[0]
+	}
+
+	seen := make(map[string]bool, len(tables))
+	groups := make([]batchGetGroup, len(tables))
+	total := 0
+	for i, table := range tables {
+		if err := validateName("table", table.TableName); err != nil {
+			return err
+		}
+		if seen[table.TableName] {
+			return fmt.Errorf("table '%s' is listed twice", table.TableName)
+		}
+		seen[table.TableName] = true
+
+		elems, err := batchElems(table.Objs)
+		if err != nil {
+			return fmt.Errorf("table '%s': %w", table.TableName, err)
+		}
+		p := defaults
+		if table.Params != nil {
+			p = *table.Params
+		}
+		for _, column := range p.ColumnsToGet {
+			if err := validateName("column", column); err != nil {
+				return fmt.Errorf("table '%s': ColumnsToGet: %w", table.TableName, err)
+			}
+		}
+		elemType := reflect.TypeOf(table.Objs).Elem().Elem()
+		if elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+		meta, err := getStructMeta(elemType)
+		if err != nil {
+			return err
+		}
+		groups[i] = batchGetGroup{TableName: table.TableName, Elems: elems, Params: p, columns: meta.attrColumns(), errs: make([]error, len(elems))}
+		total += len(elems)
+	}
+
+	for start := 0; start < total; start += MaxBatchGetRows {
+		chunk := batchGetChunk(groups, start, min(start+MaxBatchGetRows, total))
+		handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+			for _, group := range chunk {
+				if err := assignBatchGetRows(ctx, resp.(*tablestore.BatchGetRowResponse), group); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := executeOTSOperation(ctx, operation, chunk, buildBatchGetRowRequest, executeBatchGetRow, handleResp, toAnySlice(params)...); err != nil {
+			return err
+		}
+	}
+
+	for i := range tables {
+		tables[i].Err = batchError(groups[i].errs)
+	}
+	return nil
+}
+
+// batchGetChunk returns the part of groups holding the elements from start to end, counting
+// across the groups in order.
+func batchGetChunk(groups []batchGetGroup, start, end int) []batchGetGroup {
+	var chunk []batchGetGroup
+	first := 0
+	for _, group := range groups {
+		from, to := max(start-first, 0), min(end-first, len(group.Elems))
+		first += len(group.Elems)
+		if from >= to {
+			continue
+		}
+		group.offset = from
+		group.Elems = group.Elems[from:to]
+		chunk = append(chunk, group)
+	}
+	return chunk
+}
+
+func buildBatchGetRowRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	req := &tablestore.BatchGetRowRequest{}
+	for _, group := range obj.([]batchGetGroup) {
+		tableParams := *otsParams
+		tableParams.TableName = group.TableName
+		maxVersion, err := tableParams.maxVersion(GetRowParams{MaxVersion: group.Params.MaxVersion})
+		if err != nil {
+			return nil, fmt.Errorf("table '%s': %w", group.TableName, err)
+		}
+
+		criteria := &tablestore.MultiRowQueryCriteria{
+			TableName:    group.TableName,
+			MaxVersion:   int(maxVersion),
+			ColumnsToGet: group.Params.ColumnsToGet,
+			Filter:       group.Params.Filter,
+		}
+		if len(criteria.ColumnsToGet) == 0 {
+			criteria.ColumnsToGet = group.columns
+		}
+		for i, elem := range group.Elems {
+			pks, _, err := parseRow(ctx, elem)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", group.offset+i, err)
+			}
+			pk := &tablestore.PrimaryKey{}
+			for _, kv := range pks {
+				pk.AddPrimaryKeyColumn(kv.Key, kv.Value)
+			}
+			criteria.AddRow(pk)
+		}
+		req.MultiRowQueryCriteria = append(req.MultiRowQueryCriteria, criteria)
+	}
+	return req, nil
+}
+
+func executeBatchGetRow(client OtsClient, req any) (any, error) {
+	batchClient, err := clientAs[BatchClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return batchClient.BatchGetRow(req.(*tablestore.BatchGetRowRequest))
+}
+
+// assignBatchGetRows assigns the rows of group in a BatchGetRow response to the elements they
+// were requested for, recording the elements that could not be read in the errors of the group.
+func assignBatchGetRows(ctx context.Context, resp *tablestore.BatchGetRowResponse, group batchGetGroup) error {
+	results := resp.TableToRowsResult[group.TableName]
+	if len(results) != len(group.Elems) {
+		return fmt.Errorf("BatchGetRow returned %d rows of table '%s' for %d requested", len(results), group.TableName, len(group.Elems))
+	}
+
+	opts := parseResultOptions{lenientNumbers: group.Params.LenientNumbers}
+	for i, result := range results {
+		index := group.offset + i
+		switch {
+		case !result.IsSucceed:
+			group.errs[index] = newRowError(group.TableName, index, result.Error)
+		case len(result.PrimaryKey.PrimaryKeys) == 0:
+			group.errs[index] = ErrRowNotFound
+		default:
+			pks, cols := primaryKeyToKeyValues(&result.PrimaryKey), columnsToKeyValues(result.Columns)
+			group.errs[index] = parseResult(ctx, group.Elems[i], pks, cols, opts)
+		}
+	}
+	return nil
+}
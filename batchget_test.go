@@ -0,0 +1,250 @@
+package otsutils
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchGetRows(t *testing.T) {
+	t.Run("populates in order", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		for i := int64(1); i <= 3; i++ {
+			ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("batch"), Pk2: tea.Int64(i), Col1: tea.String(fmt.Sprintf("v%d", i))}))
+		}
+
+		// 请求顺序与写入顺序不同，结果必须按请求顺序回填
+		rows := []TestRow{
+			{Pk1: tea.String("batch"), Pk2: tea.Int64(3)},
+			{Pk1: tea.String("batch"), Pk2: tea.Int64(1)},
+			{Pk1: tea.String("batch"), Pk2: tea.Int64(2)},
+		}
+		ast.NoError(BatchGetRows(ctx, &rows))
+		ast.Equal("v3", tea.StringValue(rows[0].Col1))
+		ast.Equal("v1", tea.StringValue(rows[1].Col1))
+		ast.Equal("v2", tea.StringValue(rows[2].Col1))
+		ast.Equal(1, fake.CallCount("BatchGetRow"))
+
+		// 指针切片同样支持
+		ptrs := []*TestRow{{Pk1: tea.String("batch"), Pk2: tea.Int64(2)}}
+		ast.NoError(BatchGetRows(ctx, &ptrs))
+		ast.Equal("v2", tea.StringValue(ptrs[0].Col1))
+	})
+
+	t.Run("missing rows are reported per element", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+
+		ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("batch"), Pk2: tea.Int64(1), Col1: tea.String("v1")}))
+
+		rows := []TestRow{
+			{Pk1: tea.String("batch"), Pk2: tea.Int64(404), Col1: tea.String("untouched")},
+			{Pk1: tea.String("batch"), Pk2: tea.Int64(1)},
+		}
+		err := BatchGetRows(ctx, &rows)
+
+		var batchErr *BatchError
+		ast.True(errors.As(err, &batchErr))
+		ast.Len(batchErr.Errors, 2)
+		ast.ErrorIs(batchErr.Errors[0], ErrRowNotFound)
+		ast.NoError(batchErr.Errors[1])
+		ast.ErrorIs(err, ErrRowNotFound)
+
+		// 缺失的行保持原样，其余行照常填充
+		ast.Equal("untouched", tea.StringValue(rows[0].Col1))
+		ast.Equal("v1", tea.StringValue(rows[1].Col1))
+	})
+
+	t.Run("row errors carry the element index", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		// 让服务端对第二行返回单行错误
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "BatchGetRow" {
+				pk := request.(*tablestore.BatchGetRowRequest).MultiRowQueryCriteria[0].PrimaryKey[1]
+				pk.PrimaryKeys[1].Value = "not an integer"
+			}
+			return nil
+		}
+
+		rows := []TestRow{
+			{Pk1: tea.String("batch"), Pk2: tea.Int64(1)},
+			{Pk1: tea.String("batch"), Pk2: tea.Int64(2)},
+		}
+		err := BatchGetRows(ctx, &rows)
+
+		var batchErr *BatchError
+		ast.True(errors.As(err, &batchErr))
+		ast.ErrorIs(batchErr.Errors[0], ErrRowNotFound)
+		var rowErr *RowError
+		ast.True(errors.As(batchErr.Errors[1], &rowErr))
+		ast.Equal(1, rowErr.Index)
+		ast.Equal(CodeParameterInvalid, Code(rowErr))
+	})
+
+	t.Run("chunks at the request limit", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		n := 2*MaxBatchGetRows + 50
+		rows := make([]TestRow, n)
+		for i := range rows {
+			rows[i] = TestRow{Pk1: tea.String("chunk"), Pk2: tea.Int64(int64(i))}
+			if i%50 == 0 {
+				ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("chunk"), Pk2: tea.Int64(int64(i)), Col2: tea.Int64(int64(i))}))
+			}
+		}
+
+		err := BatchGetRows(ctx, &rows)
+		ast.Equal(3, fake.CallCount("BatchGetRow"))
+
+		var batchErr *BatchError
+		ast.True(errors.As(err, &batchErr))
+		for i, rowErr := range batchErr.Errors {
+			if i%50 == 0 {
+				// 跨分片的下标必须对应原始位置
+				ast.NoError(rowErr, "row %d", i)
+				ast.Equal(int64(i), tea.Int64Value(rows[i].Col2), "row %d", i)
+			} else {
+				ast.ErrorIs(rowErr, ErrRowNotFound, "row %d", i)
+			}
+		}
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		ast.ErrorContains(BatchGetRows(ctx, []TestRow{}), "objs must be a pointer to a slice of structs")
+		ast.ErrorContains(BatchGetRows(ctx, &[]string{}), "objs must be a pointer to a slice of structs")
+		ast.ErrorContains(BatchGetRows(ctx, &[]*TestRow{nil}), "objs element 0 is nil")
+
+		// 空切片不发请求
+		ast.NoError(BatchGetRows(ctx, &[]TestRow{}))
+		ast.Equal(0, fake.CallCount("BatchGetRow"))
+	})
+}
+
+func TestBatchGetTables(t *testing.T) {
+	type user struct {
+		Id    *string `json:"id" pk:"1"`
+		Name  *string `json:"name"`
+		Email *string `json:"email"`
+	}
+	type order struct {
+		Id     *string `json:"id" pk:"1"`
+		Status *string `json:"status"`
+	}
+
+	t.Run("each table carries its own settings", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.MustCreateTable("users", "id", tablestore.PrimaryKeyType_STRING)
+		fake.MustCreateTable("orders", "id", tablestore.PrimaryKeyType_STRING)
+
+		ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v1")}))
+		users := OtsUtilsParams{Client: fake, TableName: "users"}
+		ast.NoError(PutRow(users.WithContext(ctx), &user{Id: tea.String("u1"), Name: tea.String("alice"), Email: tea.String("a@x")}))
+		orders := OtsUtilsParams{Client: fake, TableName: "orders"}
+		for id, status := range map[string]string{"o1": "paid", "o2": "open"} {
+			ast.NoError(PutRow(orders.WithContext(ctx), &order{Id: tea.String(id), Status: tea.String(status)}))
+		}
+
+		var req *tablestore.BatchGetRowRequest
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "BatchGetRow" {
+				req = request.(*tablestore.BatchGetRowRequest)
+			}
+			return nil
+		}
+
+		paid := tablestore.NewSingleColumnCondition("status", tablestore.CT_EQUAL, "paid")
+		rows := []TestRow{{Pk1: tea.String("a"), Pk2: tea.Int64(1)}}
+		userRows := []user{{Id: tea.String("u1")}}
+		orderRows := []*order{{Id: tea.String("o1")}, {Id: tea.String("o2")}}
+		tables := []BatchGetTable{
+			{TableName: "test_table", Objs: &rows},
+			{TableName: "users", Objs: &userRows, Params: &BatchGetRowParams{ColumnsToGet: []string{"name"}, MaxVersion: 2}},
+			{TableName: "orders", Objs: &orderRows, Params: &BatchGetRowParams{Filter: paid}},
+		}
+		err := BatchGetTables(ctx, tables)
+		ast.Equal(1, fake.CallCount("BatchGetRow"))
+
+		// 每个表的 MultiRowQueryCriteria 使用各自的设置，未指定时按结构体字段投影
+		ast.Len(req.MultiRowQueryCriteria, 3)
+		ast.Equal("test_table", req.MultiRowQueryCriteria[0].TableName)
+		ast.Equal([]string{"col1", "col2", "col3"}, req.MultiRowQueryCriteria[0].ColumnsToGet)
+		ast.Equal(1, req.MultiRowQueryCriteria[0].MaxVersion)
+		ast.Nil(req.MultiRowQueryCriteria[0].Filter)
+		ast.Equal([]string{"name"}, req.MultiRowQueryCriteria[1].ColumnsToGet)
+		ast.Equal(2, req.MultiRowQueryCriteria[1].MaxVersion)
+		ast.Equal([]string{"status"}, req.MultiRowQueryCriteria[2].ColumnsToGet)
+		ast.Equal(paid, req.MultiRowQueryCriteria[2].Filter)
+
+		ast.Equal("v1", tea.StringValue(rows[0].Col1))
+		ast.Equal("alice", tea.StringValue(userRows[0].Name))
+		ast.Nil(userRows[0].Email)
+		ast.Equal("paid", tea.StringValue(orderRows[0].Status))
+
+		// 被过滤的行只在所属表中报告 ErrRowNotFound
+		ast.NoError(tables[0].Err)
+		ast.NoError(tables[1].Err)
+		var batchErr *BatchError
+		ast.True(errors.As(tables[2].Err, &batchErr))
+		ast.Equal([]int{1}, batchErr.Failed())
+		ast.ErrorIs(batchErr.Errors[1], ErrRowNotFound)
+		ast.Nil(orderRows[1].Status)
+		ast.ErrorContains(err, "table 'orders':")
+		ast.ErrorIs(err, ErrRowNotFound)
+	})
+
+	t.Run("requests are shared across tables at the row limit", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.MustCreateTable("users", "id", tablestore.PrimaryKeyType_STRING)
+
+		rows := batchRows("shared", MaxBatchGetRows+20)
+		ast.NoError(BatchPutRows(ctx, &rows))
+		users := OtsUtilsParams{Client: fake, TableName: "users"}
+		ast.NoError(PutRow(users.WithContext(ctx), &user{Id: tea.String("u99"), Name: tea.String("bob")}))
+
+		got := batchRows("shared", MaxBatchGetRows+20)
+		for i := range got {
+			got[i].Col2 = nil
+		}
+		userRows := make([]user, MaxBatchGetRows)
+		for i := range userRows {
+			userRows[i] = user{Id: tea.String(fmt.Sprintf("u%d", i))}
+		}
+		tables := []BatchGetTable{{TableName: "test_table", Objs: &got}, {TableName: "users", Objs: &userRows}}
+		err := BatchGetTables(ctx, tables)
+		ast.Equal(3, fake.CallCount("BatchGetRow"))
+
+		// 跨请求拆分后，下标仍对应各表中的原始位置
+		ast.NoError(tables[0].Err)
+		ast.Equal(int64(MaxBatchGetRows+19), tea.Int64Value(got[MaxBatchGetRows+19].Col2))
+		var batchErr *BatchError
+		ast.True(errors.As(err, &batchErr))
+		ast.Len(batchErr.Failed(), MaxBatchGetRows-1)
+		ast.NoError(batchErr.Errors[99])
+		ast.Equal("bob", tea.StringValue(userRows[99].Name))
+	})
+
+	t.Run("invalid tables send nothing", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		rows := []TestRow{{Pk1: tea.String("a"), Pk2: tea.Int64(1)}}
+		ast.ErrorContains(BatchGetTables(ctx, []BatchGetTable{{TableName: "test_table", Objs: &rows}, {TableName: "test_table", Objs: &rows}}), "table 'test_table' is listed twice")
+		ast.ErrorContains(BatchGetTables(ctx, []BatchGetTable{{TableName: "test_table", Objs: rows}}), "table 'test_table': objs must be a pointer to a slice of structs")
+		ast.ErrorContains(BatchGetRows(ctx, &rows, BatchGetRowParams{ColumnsToGet: []string{""}}), "ColumnsToGet:")
+		ast.Equal(0, fake.CallCount("BatchGetRow"))
+	})
+}
@@ -0,0 +1,536 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// BatchOpKind is the kind of row change of a BatchOp.
+type BatchOpKind int
+
+const (
+	// PutOp writes a whole row, like PutRow.
+	PutOp BatchOpKind = iota + 1
+
+	// UpdateOp updates columns of a row, like UpdateRow.
+	UpdateOp
+
+	// DeleteOp deletes a row, like DeleteRow.
+	DeleteOp
+)
+
+func (k BatchOpKind) String() string {
+	switch k {
+	case PutOp:
+		return "put"
+	case UpdateOp:
+		return "update"
+	case DeleteOp:
+		return "delete"
+	default:
+		return fmt.Sprintf("BatchOpKind(%d)", int(k))
+	}
+}
+
+// BatchOp is one row change of a BatchWrite.
+type BatchOp struct {
+	Kind BatchOpKind
+
+	// Obj is the row, a pointer to a struct as accepted by the single-row operation of Kind.
+	Obj any
+
+	// Params is a PutRowParams, UpdateRowParams or DeleteRowParams matching Kind, or nil
+	// for the defaults of the single-row operation. PutRowSwap fields are ignored.
+	Params any
+
+	// TableName, when set, is the table the op writes to instead of the table of the context,
+	// or of the row in a WriteBatch.
+	TableName string
+}
+
+// BatchOpResult is the outcome of one BatchOp.
+type BatchOpResult struct {
+	// Err is nil when the change succeeded. Otherwise it is a *RowError carrying the service
+	// error code, the error of the request the change was sent in, or ErrBatchAborted.
+	Err error
+
+	// ConsumedCapacity is the capacity consumed by the change, nil when the service
+	// reported none.
+	ConsumedCapacity *tablestore.ConsumedCapacityUnit
+}
+
+// BatchWriteResult is the outcome of a BatchWrite.
+type BatchWriteResult struct {
+	// Ops is aligned with the ops of the call.
+	Ops []BatchOpResult
+
+	// ConsumedCapacity is the capacity consumed by all the changes.
+	ConsumedCapacity tablestore.ConsumedCapacityUnit
+}
+
+// DefaultBatchWriteRetries is the number of consecutive attempts applying no row that the batch
+// writes retrying throttled rows allow when their Retries is not set: BatchPutRows, BatchWrite,
+// DeleteRange, TruncateTable and CopyTable.
+const DefaultBatchWriteRetries = 5
+
+// DefaultBatchWriteBackoff is the first pause of the batch writes before throttled rows are sent
+// again, when their RetryBackoff is not set.
+const DefaultBatchWriteBackoff = 200 * time.Millisecond
+
+// ErrBatchAborted is reported by batch writes for the elements that were not sent because an
+// earlier request of the batch failed as a whole.
+var ErrBatchAborted = errors.New("not sent: an earlier request of the batch failed")
+
+// BatchPutRows writes many rows. objs is a pointer to a slice of structs, or of pointers to
+// structs, as accepted by PutRow. Every element is converted before anything is sent, so an
+// invalid element fails the call without writing any row. Rows are then written in requests of
+// at most MaxBatchWriteRows rows and, by EstimateRequestSize, MaxRequestSize bytes.
+//
+// Rows fail independently: the returned *BatchError reports, at the index of each failed
+// element, a *RowError carrying the service error code, e.g. CodeConditionCheckFail when the
+// row already exists. Rows the service throttles are sent again, see BatchWriteParams.Retries,
+// so that the error only lists the rows that failed for good. When a request fails as a whole,
+// or its throttled rows run out of retries, its rows not applied report that error and the
+// elements of the following requests report ErrBatchAborted. With Atomic set, the rows are
+// sent in one request applied all or nothing, see BatchWriteParams.
+//
+// Example usage:
+//
+//	err := BatchPutRows(ctx, &rows)
+//	var batchErr *BatchError
+//	if errors.As(err, &batchErr) {
+//	    for _, i := range batchErr.Failed() {
+//	        log.Printf("row %d: %s", i, Code(batchErr.Errors[i]))
+//	    }
+//	}
+func BatchPutRows(ctx context.Context, objs any, params ...BatchWriteParams) error {
+	elems, err := batchElems(objs)
+	if err != nil {
+		return err
+	}
+	rowExistenceExpectation := tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST
+	if len(params) > 0 && params[0].RowExistenceExpectation != nil {
+		rowExistenceExpectation = *params[0].RowExistenceExpectation
+	}
+
+	otsParams := otsUtilsParamsFromCtx(ctx)
+	changes := make([]tablestore.RowChange, len(elems))
+	for i, elem := range elems {
+		change, err := buildPutRowChange(ctx, otsParams, elem, rowExistenceExpectation)
+		if err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+		changes[i] = change
+	}
+	if len(params) > 0 && params[0].Atomic {
+		if err := checkAtomicBatch(changes); err != nil {
+			return err
+		}
+	}
+
+	p := batchWriteParams(params)
+	results := make([]BatchOpResult, len(changes))
+	start := 0
+	for _, chunk := range splitChanges(changes) {
+		chunkResults, err := writeBatchWithRetry(ctx, "BatchPutRows", chunk, elems[start:start+len(chunk)], start, p.Retries, p.RetryBackoff, p)
+		copy(results[start:], chunkResults)
+		start += len(chunk)
+		if err != nil {
+			abortBatch(results, start)
+			break
+		}
+	}
+	return batchResultsError(results)
+}
+
+// batchWriteParams returns the params of a batch write with the defaults filled in.
+func batchWriteParams(params []BatchWriteParams) BatchWriteParams {
+	var p BatchWriteParams
+	if len(params) > 0 {
+		p = params[0]
+	}
+	if p.Retries <= 0 {
+		p.Retries = DefaultBatchWriteRetries
+	}
+	if p.RetryBackoff <= 0 {
+		p.RetryBackoff = DefaultBatchWriteBackoff
+	}
+	return p
+}
+
+// abortBatch records ErrBatchAborted for the changes from start on, not sent because a request
+// before them failed as a whole.
+func abortBatch(results []BatchOpResult, start int) {
+	for i := start; i < len(results); i++ {
+		results[i].Err = ErrBatchAborted
+	}
+}
+
+// batchResultsError returns a *BatchError for the failed changes, or nil when all succeeded.
+func batchResultsError(results []BatchOpResult) error {
+	errs := make([]error, len(results))
+	for i, result := range results {
+		errs[i] = result.Err
+	}
+	return batchError(errs)
+}
+
+// BatchWrite applies a mix of puts, updates and deletes to the table in as few BatchWriteRow
+// requests as possible, each of at most MaxBatchWriteRows changes and, by EstimateRequestSize,
+// MaxRequestSize bytes, sent in the order of ops. An op with a TableName writes to that table
+// instead, sharing the requests with the others; see WriteBatch to take the table from the row.
+// Every op is converted with the logic of its single-row operation before anything is sent, so
+// an invalid op fails the call with a nil result and no change applied.
+//
+// Changes succeed or fail independently unless Atomic is set, which sends them in one request
+// applied all or nothing, see BatchWriteParams. Throttled changes are sent again and a request
+// failing as a whole aborts the rest, as in BatchPutRows. The result reports each op at its index, and the
+// returned error is a *BatchError listing the failed ops, or nil when all succeeded.
+//
+// Example usage:
+//
+//	expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+//	result, err := BatchWrite(ctx, []BatchOp{
+//	    {Kind: PutOp, Obj: &order},
+//	    {Kind: UpdateOp, Obj: &stock, Params: UpdateRowParams{RowExistenceExpectation: &expectExist}},
+//	    {Kind: DeleteOp, Obj: &draft},
+//	})
+func BatchWrite(ctx context.Context, ops []BatchOp, params ...BatchWriteParams) (*BatchWriteResult, error) {
+	return batchWrite(ctx, "BatchWrite", ops, params...)
+}
+
+// batchWrite applies ops as BatchWrite does, each to its TableName or, when empty, to the table of
+// ctx.
+func batchWrite(ctx context.Context, operation string, ops []BatchOp, params ...BatchWriteParams) (*BatchWriteResult, error) {
+	otsParams := otsUtilsParamsFromCtx(ctx)
+	tableCtxs := map[string]context.Context{"": ctx}
+	changes := make([]tablestore.RowChange, len(ops))
+	for i, op := range ops {
+		tableCtx, ok := tableCtxs[op.TableName]
+		if !ok {
+			if err := validateName("table", op.TableName); err != nil {
+				return nil, fmt.Errorf("op %d: %w", i, err)
+			}
+			tableParams := *otsParams
+			tableParams.TableName = op.TableName
+			tableCtx = tableParams.WithContext(ctx)
+			tableCtxs[op.TableName] = tableCtx
+		}
+		change, err := buildBatchOpChange(tableCtx, otsUtilsParamsFromCtx(tableCtx), op)
+		if err != nil {
+			return nil, fmt.Errorf("op %d: %w", i, err)
+		}
+		changes[i] = change
+	}
+	if len(params) > 0 && params[0].Atomic {
+		if err := checkAtomicBatch(changes); err != nil {
+			return nil, err
+		}
+	}
+
+	putObjs := make([]any, len(ops))
+	for i, op := range ops {
+		if op.Kind == PutOp {
+			putObjs[i] = op.Obj
+		}
+	}
+
+	p := batchWriteParams(params)
+	result := &BatchWriteResult{Ops: make([]BatchOpResult, len(ops))}
+	start := 0
+	for _, chunk := range splitChanges(changes) {
+		chunkResults, err := writeBatchWithRetry(ctx, operation, chunk, putObjs[start:start+len(chunk)], start, p.Retries, p.RetryBackoff, p)
+		copy(result.Ops[start:], chunkResults)
+		start += len(chunk)
+		if err != nil {
+			abortBatch(result.Ops, start)
+			break
+		}
+	}
+
+	for _, op := range result.Ops {
+		if op.ConsumedCapacity != nil {
+			result.ConsumedCapacity.Read += op.ConsumedCapacity.Read
+			result.ConsumedCapacity.Write += op.ConsumedCapacity.Write
+		}
+	}
+	return result, batchResultsError(result.Ops)
+}
+
+// buildBatchOpChange converts op to a row change, with the request builder of its single-row
+// operation.
+func buildBatchOpChange(ctx context.Context, otsParams *OtsUtilsParams, op BatchOp) (tablestore.RowChange, error) {
+	var params []any
+	if op.Params != nil {
+		params = []any{op.Params}
+	}
+	logger := otsParams.baseLogger(ctx)
+
+	switch op.Kind {
+	case PutOp:
+		if _, ok := op.Params.(PutRowParams); !ok && op.Params != nil {
+			return nil, fmt.Errorf("put op takes PutRowParams, got %T", op.Params)
+		}
+		req, err := buildPutRowRequest(ctx, otsParams, logger, op.Obj, params...)
+		if err != nil {
+			return nil, err
+		}
+		return req.(*tablestore.PutRowRequest).PutRowChange, nil
+	case UpdateOp:
+		if _, ok := op.Params.(UpdateRowParams); !ok && op.Params != nil {
+			return nil, fmt.Errorf("update op takes UpdateRowParams, got %T", op.Params)
+		}
+		req, err := buildUpdateRowRequest(ctx, otsParams, logger, op.Obj, params...)
+		if err != nil {
+			return nil, err
+		}
+		return req.(*tablestore.UpdateRowRequest).UpdateRowChange, nil
+	case DeleteOp:
+		if _, ok := op.Params.(DeleteRowParams); !ok && op.Params != nil {
+			return nil, fmt.Errorf("delete op takes DeleteRowParams, got %T", op.Params)
+		}
+		req, err := buildDeleteRowRequest(ctx, otsParams, logger, op.Obj, params...)
+		if err != nil {
+			return nil, err
+		}
+		return req.(*tablestore.DeleteRowRequest).DeleteRowChange, nil
+	default:
+		return nil, fmt.Errorf("unknown op kind %s", op.Kind)
+	}
+}
+
+// buildBatchWriteRowRequest groups the changes of obj by their table, in order within each.
+func buildBatchWriteRowRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	req := &tablestore.BatchWriteRowRequest{}
+	for _, change := range obj.([]tablestore.RowChange) {
+		req.AddRowChange(change)
+	}
+	if len(params) > 0 {
+		req.IsAtomic = params[0].(BatchWriteParams).Atomic
+	}
+	return req, nil
+}
+
+// checkAtomicBatch returns an error unless changes fit in a single BatchWriteRow request and
+// share the table and the value of the first primary key column, as the service requires of
+// atomic batches.
+func checkAtomicBatch(changes []tablestore.RowChange) error {
+	if len(changes) > MaxBatchWriteRows {
+		return fmt.Errorf("atomic batch has %d rows, exceeding the limit of %d rows per request", len(changes), MaxBatchWriteRows)
+	}
+	estimates := make([]RowChangeEstimate, len(changes))
+	for i, change := range changes {
+		estimates[i] = rowChangeEstimate(change)
+	}
+	if size := EstimateRequestSize(estimates...); size > MaxRequestSize {
+		return fmt.Errorf("atomic batch is about %d bytes, exceeding the request limit of %d bytes", size, MaxRequestSize)
+	}
+	for i, estimate := range estimates {
+		if estimate.TableName != estimates[0].TableName {
+			return fmt.Errorf("atomic batch spans tables: row %d is of table '%s', row 0 of table '%s'", i, estimate.TableName, estimates[0].TableName)
+		}
+		first := estimates[0].PrimaryKey[0]
+		if !reflect.DeepEqual(estimate.PrimaryKey[0].Value, first.Value) {
+			return fmt.Errorf("atomic batch spans partitions: row %d has %s %v, row 0 has %v", i, first.Key, estimate.PrimaryKey[0].Value, first.Value)
+		}
+	}
+	return nil
+}
+
+// splitChanges splits changes, in order, into the requests they are sent in: at most
+// MaxBatchWriteRows changes and, by EstimateRequestSize, MaxRequestSize bytes each. A change
+// larger than MaxRequestSize on its own is sent alone, for the service to reject.
+func splitChanges(changes []tablestore.RowChange) [][]tablestore.RowChange {
+	var chunks [][]tablestore.RowChange
+	start, size := 0, 0
+	for i, change := range changes {
+		changeSize := EstimateRequestSize(rowChangeEstimate(change))
+		if i > start && (i-start == MaxBatchWriteRows || size+changeSize > MaxRequestSize) {
+			chunks = append(chunks, changes[start:i])
+			start, size = i, 0
+		}
+		size += changeSize
+	}
+	if start < len(changes) {
+		chunks = append(chunks, changes[start:])
+	}
+	return chunks
+}
+
+// rowChangeEstimate describes change for EstimateRequestSize.
+func rowChangeEstimate(change tablestore.RowChange) RowChangeEstimate {
+	estimate := RowChangeEstimate{TableName: change.GetTableName()}
+	switch change := change.(type) {
+	case *tablestore.PutRowChange:
+		estimate.PrimaryKey = primaryKeyToKeyValues(change.PrimaryKey)
+		for _, col := range change.Columns {
+			estimate.Columns = append(estimate.Columns, KeyValue{Key: col.ColumnName, Value: col.Value})
+		}
+	case *tablestore.UpdateRowChange:
+		estimate.PrimaryKey = primaryKeyToKeyValues(change.PrimaryKey)
+		for _, col := range change.Columns {
+			estimate.Columns = append(estimate.Columns, KeyValue{Key: col.ColumnName, Value: col.Value})
+		}
+	case *tablestore.DeleteRowChange:
+		estimate.PrimaryKey = primaryKeyToKeyValues(change.PrimaryKey)
+	}
+	return estimate
+}
+
+func executeBatchWriteRow(client OtsClient, req any) (any, error) {
+	batchClient, err := clientAs[BatchClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return batchClient.BatchWriteRow(req.(*tablestore.BatchWriteRowRequest))
+}
+
+// collectBatchWriteResults records the outcome of each row of a BatchWriteRow response in
+// results, at the index of its change: the changes of the request are those from offset on.
+func collectBatchWriteResults(ctx context.Context, resp *tablestore.BatchWriteRowResponse, changes []tablestore.RowChange, offset int, results []BatchOpResult) error {
+	rows, err := batchWriteRows(resp, changes)
+	if err != nil {
+		return err
+	}
+	for i, row := range rows {
+		results[offset+i].ConsumedCapacity = row.ConsumedCapacityUnit
+		if !row.IsSucceed {
+			results[offset+i].Err = newRowError(changes[i].GetTableName(), offset+i, row.Error)
+		}
+	}
+	return nil
+}
+
+// batchWriteRows returns the result of each change of a BatchWriteRow request, in the order of
+// changes: the service reports them by table, in the order of the changes of each.
+func batchWriteRows(resp *tablestore.BatchWriteRowResponse, changes []tablestore.RowChange) ([]*tablestore.RowResult, error) {
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.GetTableName()]++
+	}
+	for tableName, n := range counts {
+		if got := len(resp.TableToRowsResult[tableName]); got != n {
+			return nil, fmt.Errorf("BatchWriteRow returned %d rows of table '%s' for %d written", got, tableName, n)
+		}
+	}
+
+	rows := make([]*tablestore.RowResult, len(changes))
+	next := make(map[string]int, len(counts))
+	for i, change := range changes {
+		tableName := change.GetTableName()
+		rows[i] = &resp.TableToRowsResult[tableName][next[tableName]]
+		next[tableName]++
+	}
+	return rows, nil
+}
+
+// writeBatchWithRetry sends changes, at most MaxBatchWriteRows, with BatchWriteRow, and sends the
+// changes the service throttles again after backoff, doubled after each attempt that applied
+// none of them, until retries attempts in a row applied none. Changes failing with an error that
+// is not transient, e.g. a failed condition, are not sent again. It returns the result of each
+// change, and fails as a whole when a request fails with a non-transient error or the retries
+// run out, which is then the error of every change not applied. objs, when not nil, holds the
+// row of each put change, for the auto-increment values of its primary key; params are those of
+// buildBatchWriteRowRequest. The *RowError of a change reports its index in changes plus offset.
+// Retries are reported to the job of ctx, if any.
+func writeBatchWithRetry(ctx context.Context, operation string, changes []tablestore.RowChange, objs []any, offset int, retries int, backoff time.Duration, params ...any) ([]BatchOpResult, error) {
+	results := make([]BatchOpResult, len(changes))
+	pending := make([]int, len(changes))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	fail := func(indexes []int, err error) ([]BatchOpResult, error) {
+		for _, i := range indexes {
+			results[i].Err = err
+		}
+		return results, err
+	}
+
+	stalls := 0
+	for {
+		chunk := make([]tablestore.RowChange, len(pending))
+		chunkObjs := make([]any, len(pending))
+		for i, j := range pending {
+			chunk[i] = changes[j]
+			if objs != nil {
+				chunkObjs[i] = objs[j]
+			}
+		}
+		chunkResults := make([]BatchOpResult, len(chunk))
+		handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+			r := resp.(*tablestore.BatchWriteRowResponse)
+			if err := collectBatchWriteResults(ctx, r, chunk, 0, chunkResults); err != nil {
+				return err
+			}
+			assignBatchAutoIncrement(r, chunk, chunkObjs, chunkResults)
+			return nil
+		}
+
+		var left []int
+		var lastErr error
+		if err := executeOTSOperation(ctx, operation, chunk, buildBatchWriteRowRequest, executeBatchWriteRow, handleResp, params...); err != nil {
+			if !isRetriable(err) {
+				return fail(pending, err)
+			}
+			left, lastErr = pending, err
+		} else {
+			for i, result := range chunkResults {
+				var rowErr *RowError
+				if errors.As(result.Err, &rowErr) {
+					rowErr.Index = offset + pending[i]
+				}
+				if isRetriable(result.Err) {
+					left = append(left, pending[i])
+					lastErr = result.Err
+				} else {
+					results[pending[i]] = result
+				}
+			}
+		}
+		if len(left) == 0 {
+			return results, nil
+		}
+		if len(left) < len(pending) {
+			stalls = 0
+		} else if stalls++; stalls >= retries {
+			return fail(left, fmt.Errorf("%d rows left after %d attempts writing none of them: %w", len(left), stalls, lastErr))
+		}
+		pending = left
+
+		jobFromCtx(ctx).retry(JobRetry{Attempt: stalls, Rows: len(pending), Backoff: backoff << stalls, Err: lastErr})
+		timer := time.NewTimer(backoff << stalls)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fail(pending, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// assignBatchAutoIncrement writes the primary key values assigned by the service back into the
+// auto-increment fields of the rows put by a BatchWriteRow request. objs holds the row of each
+// change of the request, nil for the changes that are not puts. A row written without its value
+// coming back reports the error in results.
+func assignBatchAutoIncrement(resp *tablestore.BatchWriteRowResponse, changes []tablestore.RowChange, objs []any, results []BatchOpResult) {
+	rows, err := batchWriteRows(resp, changes)
+	if err != nil {
+		return
+	}
+	for i, row := range rows {
+		if !row.IsSucceed || objs[i] == nil {
+			continue
+		}
+		if err := assignAutoIncrement(objs[i], &row.PrimaryKey); err != nil {
+			results[i].Err = err
+		}
+	}
+}
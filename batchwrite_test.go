@@ -0,0 +1,341 @@
+package otsutils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+// batchRows 返回 n 个主键为 (pk1, 0..n-1) 的行
+func batchRows(pk1 string, n int) []TestRow {
+	rows := make([]TestRow, n)
+	for i := range rows {
+		rows[i] = TestRow{Pk1: tea.String(pk1), Pk2: tea.Int64(int64(i)), Col2: tea.Int64(int64(i))}
+	}
+	return rows
+}
+
+func TestBatchPutRows(t *testing.T) {
+	t.Run("writes every row", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		rows := batchRows("put", 3)
+		ast.NoError(BatchPutRows(ctx, &rows))
+		ast.Equal(1, fake.CallCount("BatchWriteRow"))
+
+		got := batchRows("put", 3)
+		for i := range got {
+			got[i].Col2 = nil
+		}
+		ast.NoError(BatchGetRows(ctx, &got))
+		for i := range got {
+			ast.Equal(int64(i), tea.Int64Value(got[i].Col2))
+		}
+	})
+
+	t.Run("row errors carry the element index across requests", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		// 预先写入第 5 行和第 305 行，批量写入时这两行的 EXPECT_NOT_EXIST 会失败
+		for _, i := range []int64{5, 305} {
+			ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("put"), Pk2: tea.Int64(i)}))
+		}
+
+		rows := batchRows("put", 2*MaxBatchWriteRows+50)
+		err := BatchPutRows(ctx, &rows)
+		ast.Equal(3, fake.CallCount("BatchWriteRow"))
+
+		var batchErr *BatchError
+		ast.True(errors.As(err, &batchErr))
+		ast.Equal([]int{5, 305}, batchErr.Failed())
+		var rowErr *RowError
+		ast.True(errors.As(batchErr.Errors[305], &rowErr))
+		ast.Equal(305, rowErr.Index)
+		ast.Equal(CodeConditionCheckFail, Code(rowErr))
+
+		// 其余行照常写入
+		got := []TestRow{{Pk1: tea.String("put"), Pk2: tea.Int64(449)}}
+		ast.NoError(BatchGetRows(ctx, &got))
+		ast.Equal(int64(449), tea.Int64Value(got[0].Col2))
+	})
+
+	t.Run("row existence expectation applies to every row", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+
+		rows := batchRows("put", 2)
+		ast.NoError(BatchPutRows(ctx, &rows))
+		ast.Error(BatchPutRows(ctx, &rows))
+
+		ignore := tablestore.RowExistenceExpectation_IGNORE
+		ast.NoError(BatchPutRows(ctx, &rows, BatchWriteParams{RowExistenceExpectation: &ignore}))
+	})
+
+	t.Run("request failure aborts the following requests", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		// 第二个请求因非暂时性错误整体失败，不会重试
+		invalid := &tablestore.OtsError{Code: CodeParameterInvalid, Message: "Invalid request."}
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "BatchWriteRow" && fake.CallCount("BatchWriteRow") == 2 {
+				return invalid
+			}
+			return nil
+		}
+
+		rows := batchRows("put", 2*MaxBatchWriteRows+50)
+		err := BatchPutRows(ctx, &rows)
+		ast.Equal(2, fake.CallCount("BatchWriteRow"))
+
+		var batchErr *BatchError
+		ast.True(errors.As(err, &batchErr))
+		ast.NoError(batchErr.Errors[MaxBatchWriteRows-1])
+		ast.Equal(CodeParameterInvalid, Code(batchErr.Errors[MaxBatchWriteRows]))
+		ast.ErrorIs(batchErr.Errors[2*MaxBatchWriteRows-1], invalid)
+		ast.ErrorIs(batchErr.Errors[2*MaxBatchWriteRows], ErrBatchAborted)
+		ast.Len(batchErr.Failed(), MaxBatchWriteRows+50)
+	})
+
+	t.Run("throttled rows are sent again", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("put"), Pk2: tea.Int64(3)}))
+
+		// 第 1、2 行前两次被限流，第 4 行始终被限流；第 3 行已存在，条件检查失败
+		sent := make(map[int64]int)
+		fake.RowIntercept = func(tableName string, change tablestore.RowChange) error {
+			pk2 := change.(*tablestore.PutRowChange).PrimaryKey.PrimaryKeys[1].Value.(int64)
+			sent[pk2]++
+			if pk2 == 4 || (pk2 == 1 || pk2 == 2) && sent[pk2] <= 2 {
+				return &tablestore.OtsError{Code: CodeNotEnoughCapacityUnit, Message: "Remaining capacity unit is not enough."}
+			}
+			return nil
+		}
+
+		rows := batchRows("put", 10)
+		err := BatchPutRows(ctx, &rows, BatchWriteParams{Retries: 3, RetryBackoff: time.Millisecond})
+		// 第 3 次发送写入了第 1、2 行，之后第 4 行连续 3 次没有进展
+		ast.Equal(6, fake.CallCount("BatchWriteRow"))
+		ast.Equal(1, sent[3])
+		ast.Equal(1, sent[5])
+
+		var batchErr *BatchError
+		ast.True(errors.As(err, &batchErr))
+		ast.Equal([]int{3, 4}, batchErr.Failed())
+		ast.Equal(CodeConditionCheckFail, Code(batchErr.Errors[3]))
+		ast.Equal(CodeNotEnoughCapacityUnit, Code(batchErr.Errors[4]))
+		var rowErr *RowError
+		ast.True(errors.As(batchErr.Errors[3], &rowErr))
+		ast.Equal(3, rowErr.Index)
+
+		got := batchRows("put", 3)
+		ast.NoError(BatchGetRows(ctx, &got))
+		ast.Equal(int64(2), tea.Int64Value(got[2].Col2))
+	})
+
+	t.Run("invalid element sends nothing", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		type badRow struct {
+			Pk1 *string `json:"pk1" pk:"1"`
+			Pk2 *int64  `json:"pk2" pk:"2"`
+			Bad *string `json:"bad-name"`
+		}
+		rows := []badRow{{Pk1: tea.String("put"), Pk2: tea.Int64(1)}}
+		ast.ErrorContains(BatchPutRows(ctx, &rows), "element 0")
+		ast.Equal(0, fake.CallCount("BatchWriteRow"))
+	})
+}
+
+func TestBatchWriteSplitsBySize(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	var sizes []int
+	fake.Intercept = func(operation string, request any) error {
+		if req, ok := request.(*tablestore.BatchWriteRowRequest); ok {
+			var estimates []RowChangeEstimate
+			for _, change := range req.RowChangesGroupByTable["test_table"] {
+				estimates = append(estimates, rowChangeEstimate(change))
+			}
+			sizes = append(sizes, EstimateRequestSize(estimates...))
+		}
+		return nil
+	}
+
+	// 每行约 1.5MB：行数远低于上限，但每个请求最多容纳两行
+	rows := batchRows("big", 5)
+	for i := range rows {
+		rows[i].Col1 = tea.String(strings.Repeat("x", 1536*1024))
+	}
+	ast.NoError(BatchPutRows(ctx, &rows))
+	ast.Len(sizes, 3)
+	for _, size := range sizes {
+		ast.LessOrEqual(size, MaxRequestSize)
+	}
+
+	var ops []BatchOp
+	for i := range rows {
+		ops = append(ops, BatchOp{Kind: DeleteOp, Obj: &rows[i]}, BatchOp{Kind: PutOp, Obj: &rows[i]})
+	}
+	sizes = nil
+	result, err := BatchWrite(ctx, ops)
+	ast.NoError(err)
+	ast.Len(result.Ops, 10)
+	// 删除很小，每个请求同样最多两次写入：(d p d p d) (p d p d) (p)
+	ast.Len(sizes, 3)
+}
+
+func TestBatchWrite(t *testing.T) {
+	t.Run("mixed ops keep their order across requests", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		existing := batchRows("mixed", 2)
+		ast.NoError(BatchPutRows(ctx, &existing))
+
+		// 先更新、再删除已有的两行，然后写入足够多的新行以拆分为两个请求；
+		// 最后对同一新行的更新必须在其写入之后执行
+		expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+		ops := []BatchOp{
+			{Kind: UpdateOp, Obj: &TestRow{Pk1: tea.String("mixed"), Pk2: tea.Int64(0), Col1: tea.String("updated")}, Params: UpdateRowParams{RowExistenceExpectation: &expectExist}},
+			{Kind: DeleteOp, Obj: &TestRow{Pk1: tea.String("mixed"), Pk2: tea.Int64(1)}},
+			{Kind: UpdateOp, Obj: &TestRow{Pk1: tea.String("mixed"), Pk2: tea.Int64(404), Col1: tea.String("x")}, Params: UpdateRowParams{RowExistenceExpectation: &expectExist}},
+		}
+		for i := int64(100); i < 100+MaxBatchWriteRows; i++ {
+			ops = append(ops, BatchOp{Kind: PutOp, Obj: &TestRow{Pk1: tea.String("mixed"), Pk2: tea.Int64(i)}})
+		}
+		last := int64(100 + MaxBatchWriteRows - 1)
+		ops = append(ops, BatchOp{Kind: UpdateOp, Obj: &TestRow{Pk1: tea.String("mixed"), Pk2: tea.Int64(last), Col1: tea.String("after put")}, Params: UpdateRowParams{RowExistenceExpectation: &expectExist}})
+
+		result, err := BatchWrite(ctx, ops)
+		ast.Equal(1+2, fake.CallCount("BatchWriteRow"))
+
+		var batchErr *BatchError
+		ast.True(errors.As(err, &batchErr))
+		ast.Equal([]int{2}, batchErr.Failed())
+		ast.Equal(CodeConditionCheckFail, Code(result.Ops[2].Err))
+		ast.Len(result.Ops, len(ops))
+		ast.Equal(int32(len(ops)-1), result.ConsumedCapacity.Write)
+		ast.NotNil(result.Ops[len(ops)-1].ConsumedCapacity)
+
+		got := []TestRow{
+			{Pk1: tea.String("mixed"), Pk2: tea.Int64(0)},
+			{Pk1: tea.String("mixed"), Pk2: tea.Int64(1)},
+			{Pk1: tea.String("mixed"), Pk2: tea.Int64(last)},
+		}
+		err = BatchGetRows(ctx, &got)
+		ast.True(errors.As(err, &batchErr))
+		ast.Equal("updated", tea.StringValue(got[0].Col1))
+		ast.ErrorIs(batchErr.Errors[1], ErrRowNotFound)
+		ast.Equal("after put", tea.StringValue(got[2].Col1))
+	})
+
+	t.Run("throttled request is sent again", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "BatchWriteRow" && fake.CallCount("BatchWriteRow") == 1 {
+				return &tablestore.OtsError{Code: CodeServerBusy, Message: "Server is busy."}
+			}
+			return nil
+		}
+		ops := []BatchOp{
+			{Kind: PutOp, Obj: &TestRow{Pk1: tea.String("retry"), Pk2: tea.Int64(1)}},
+			{Kind: DeleteOp, Obj: &TestRow{Pk1: tea.String("retry"), Pk2: tea.Int64(2)}},
+		}
+		result, err := BatchWrite(ctx, ops, BatchWriteParams{RetryBackoff: time.Millisecond})
+		ast.NoError(err)
+		ast.Equal(2, fake.CallCount("BatchWriteRow"))
+		ast.Equal(int32(2), result.ConsumedCapacity.Write)
+	})
+
+	t.Run("invalid ops send nothing", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		row := &TestRow{Pk1: tea.String("mixed"), Pk2: tea.Int64(1)}
+		_, err := BatchWrite(ctx, []BatchOp{{Kind: PutOp, Obj: row}, {Kind: DeleteOp, Obj: row, Params: PutRowParams{}}})
+		ast.ErrorContains(err, "op 1: delete op takes DeleteRowParams, got otsutils.PutRowParams")
+
+		_, err = BatchWrite(ctx, []BatchOp{{Obj: row}})
+		ast.ErrorContains(err, "op 0: unknown op kind BatchOpKind(0)")
+
+		_, err = BatchWrite(ctx, []BatchOp{{Kind: PutOp, Obj: "not a row"}})
+		ast.ErrorContains(err, "op 0:")
+		ast.Equal(0, fake.CallCount("BatchWriteRow"))
+
+		// 空的操作列表不发请求
+		result, err := BatchWrite(ctx, nil)
+		ast.NoError(err)
+		ast.Empty(result.Ops)
+		ast.Equal(0, fake.CallCount("BatchWriteRow"))
+	})
+
+	t.Run("atomic batch applies all or nothing", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		var atomic []bool
+		fake.Intercept = func(operation string, request any) error {
+			if req, ok := request.(*tablestore.BatchWriteRowRequest); ok {
+				atomic = append(atomic, req.IsAtomic)
+			}
+			return nil
+		}
+
+		// 对不存在的行要求 EXPECT_EXIST 的更新失败，同一批次的写入也必须回滚
+		expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+		ops := []BatchOp{
+			{Kind: PutOp, Obj: &TestRow{Pk1: tea.String("atomic"), Pk2: tea.Int64(0)}},
+			{Kind: UpdateOp, Obj: &TestRow{Pk1: tea.String("atomic"), Pk2: tea.Int64(1), Col1: tea.String("x")}, Params: UpdateRowParams{RowExistenceExpectation: &expectExist}},
+		}
+		result, err := BatchWrite(ctx, ops, BatchWriteParams{Atomic: true})
+		var batchErr *BatchError
+		ast.True(errors.As(err, &batchErr))
+		ast.Equal([]int{0, 1}, batchErr.Failed())
+		ast.Equal(CodeConditionCheckFail, Code(result.Ops[1].Err))
+		exists, err := ExistsRow(ctx, &TestRow{Pk1: tea.String("atomic"), Pk2: tea.Int64(0)})
+		ast.NoError(err)
+		ast.False(exists)
+
+		// 去掉失败的更新后整批写入
+		rows := batchRows("atomic", 3)
+		ast.NoError(BatchPutRows(ctx, &rows, BatchWriteParams{Atomic: true}))
+		ast.Equal([]bool{true, true}, atomic)
+		exists, err = ExistsRow(ctx, &TestRow{Pk1: tea.String("atomic"), Pk2: tea.Int64(2)})
+		ast.NoError(err)
+		ast.True(exists)
+	})
+
+	t.Run("atomic batch is never split", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		atomic := BatchWriteParams{Atomic: true}
+
+		mixed := append(batchRows("a", 1), batchRows("b", 1)...)
+		ast.ErrorContains(BatchPutRows(ctx, &mixed, atomic), "atomic batch spans partitions: row 1 has pk1 b, row 0 has a")
+
+		many := batchRows("atomic", MaxBatchWriteRows+1)
+		ast.ErrorContains(BatchPutRows(ctx, &many, atomic), "atomic batch has 201 rows, exceeding the limit of 200 rows per request")
+
+		// 三行各约 1.5MB，合计超过单个请求的大小上限
+		var ops []BatchOp
+		for i := int64(0); i < 3; i++ {
+			ops = append(ops, BatchOp{Kind: PutOp, Obj: &TestRow{Pk1: tea.String("atomic"), Pk2: tea.Int64(i), Col1: tea.String(strings.Repeat("x", 1536*1024))}})
+		}
+		_, err := BatchWrite(ctx, ops, atomic)
+		ast.ErrorContains(err, "exceeding the request limit of 4194304 bytes")
+		ast.Equal(0, fake.CallCount("BatchWriteRow"))
+	})
+}
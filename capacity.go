@@ -0,0 +1,63 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// consumedCapacity extracts the capacity units consumed by a response.
+// Batch responses are summed over all rows. It returns nil when the response carries none.
+func consumedCapacity(resp any) *tablestore.ConsumedCapacityUnit {
+	switch r := resp.(type) {
+	case *tablestore.PutRowResponse:
+		return r.ConsumedCapacityUnit
+	case *tablestore.UpdateRowResponse:
+		return r.ConsumedCapacityUnit
+	case *tablestore.DeleteRowResponse:
+		return r.ConsumedCapacityUnit
+	case *tablestore.GetRowResponse:
+		return r.ConsumedCapacityUnit
+	case *tablestore.GetRangeResponse:
+		return r.ConsumedCapacityUnit
+	case *tablestore.SearchResponse:
+		return r.ConsumedCapacityUnit
+	case *tablestore.BatchGetRowResponse:
+		return sumRowResultCapacity(r.TableToRowsResult)
+	case *tablestore.BatchWriteRowResponse:
+		return sumRowResultCapacity(r.TableToRowsResult)
+	default:
+		return nil
+	}
+}
+
+func sumRowResultCapacity(tableToRowsResult map[string][]tablestore.RowResult) *tablestore.ConsumedCapacityUnit {
+	var total *tablestore.ConsumedCapacityUnit
+	for _, results := range tableToRowsResult {
+		for _, result := range results {
+			if result.ConsumedCapacityUnit == nil {
+				continue
+			}
+			if total == nil {
+				total = &tablestore.ConsumedCapacityUnit{}
+			}
+			total.Read += result.ConsumedCapacityUnit.Read
+			total.Write += result.ConsumedCapacityUnit.Write
+		}
+	}
+	return total
+}
+
+// capacityLogEvent starts a log event carrying the consumed capacity units.
+// It logs at debug, or at info when the total exceeds threshold (if threshold > 0).
+func capacityLogEvent(logger *zerolog.Logger, cu *tablestore.ConsumedCapacityUnit, threshold int32) *zerolog.Event {
+	if cu == nil {
+		return logger.Debug()
+	}
+
+	e := logger.Debug()
+	if threshold > 0 && cu.Read+cu.Write > threshold {
+		e = logger.Info()
+	}
+	return e.Int32("read_cu", cu.Read).Int32("write_cu", cu.Write)
+}
@@ -0,0 +1,65 @@
+package otsutils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumedCapacity(t *testing.T) {
+	cu := &tablestore.ConsumedCapacityUnit{Read: 1, Write: 2}
+
+	tests := []struct {
+		name string
+		resp any
+		want *tablestore.ConsumedCapacityUnit
+	}{
+		{"PutRow", &tablestore.PutRowResponse{ConsumedCapacityUnit: cu}, cu},
+		{"UpdateRow", &tablestore.UpdateRowResponse{ConsumedCapacityUnit: cu}, cu},
+		{"DeleteRow", &tablestore.DeleteRowResponse{ConsumedCapacityUnit: cu}, cu},
+		{"GetRow", &tablestore.GetRowResponse{ConsumedCapacityUnit: cu}, cu},
+		{"GetRange", &tablestore.GetRangeResponse{ConsumedCapacityUnit: cu}, cu},
+		{"BatchGetRow", &tablestore.BatchGetRowResponse{TableToRowsResult: map[string][]tablestore.RowResult{
+			"t1": {{ConsumedCapacityUnit: cu}, {}},
+			"t2": {{ConsumedCapacityUnit: &tablestore.ConsumedCapacityUnit{Read: 3}}},
+		}}, &tablestore.ConsumedCapacityUnit{Read: 4, Write: 2}},
+		{"BatchWriteRow", &tablestore.BatchWriteRowResponse{TableToRowsResult: map[string][]tablestore.RowResult{
+			"t1": {{ConsumedCapacityUnit: cu}, {ConsumedCapacityUnit: cu}},
+		}}, &tablestore.ConsumedCapacityUnit{Read: 2, Write: 4}},
+		{"BatchWriteRowEmpty", &tablestore.BatchWriteRowResponse{}, nil},
+		{"Missing", &tablestore.PutRowResponse{}, nil},
+		{"Unknown", &tablestore.ListTableResponse{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, consumedCapacity(tt.resp))
+		})
+	}
+}
+
+func TestCapacityLog(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	var buf bytes.Buffer
+	ctx = zerolog.New(&buf).Level(zerolog.InfoLevel).WithContext(ctx)
+
+	// 默认在 debug 级别输出
+	ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col1: tea.String("a")}))
+	ast.NotContains(buf.String(), "write_cu")
+
+	// 超过阈值时提升到 info
+	OtsUtilsParamsFromCtx(ctx).CULogThreshold = 1
+	ast.NoError(GetRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)}))
+	ast.NotContains(buf.String(), "read_cu")
+
+	var rows []RangeRow
+	ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(2), Col1: tea.String("b")}))
+	ast.NoError(GetRange(ctx, &RangeRow{}, &RangeRow{}, &rows))
+	ast.Contains(buf.String(), `{"level":"info","operation":"GetRange","client":"primary","table":"test_table","read_cu":2,"write_cu":0`)
+}
@@ -0,0 +1,286 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// Checkpoint stores the progress of the splits of a ParallelScan, so that a scan restarted after
+// a crash resumes each split after the last row it sent instead of from the beginning. Save is
+// called from the workers of the scan, concurrently for different splits.
+type Checkpoint interface {
+	// Load returns the state saved for each split, by split index, or an empty map when no scan
+	// was started with the checkpoint.
+	Load(ctx context.Context) (map[int]SplitCheckpoint, error)
+
+	// Save records the state of split, replacing the one saved before.
+	Save(ctx context.Context, split int, state SplitCheckpoint) error
+}
+
+// SplitCheckpoint is the saved state of a split of a ParallelScan.
+type SplitCheckpoint struct {
+	// Lower and Upper are the bounds of the split, as returned by ComputeSplitPointsBySize. A
+	// column at the start or end of its range has the value tablestore.MIN or tablestore.MAX.
+	// A resumed scan reads the splits of its checkpoint, so that they match the saved progress
+	// even when the table changed since.
+	Lower, Upper []KeyValue
+
+	// LastPK is the primary key of the last row of the split sent out, nil before the first.
+	LastPK []KeyValue
+
+	// Done is set once every row of the split was sent out.
+	Done bool
+}
+
+// checkpointColumn is the JSON form of a primary key column in a SplitCheckpoint. Value holds
+// strings as is, integers in decimal and binary values in base64, so that types survive.
+type checkpointColumn struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+// checkpointJSON is the JSON form of a SplitCheckpoint.
+type checkpointJSON struct {
+	Lower  []checkpointColumn `json:"lower"`
+	Upper  []checkpointColumn `json:"upper"`
+	LastPK []checkpointColumn `json:"lastPK,omitempty"`
+	Done   bool               `json:"done,omitempty"`
+}
+
+// MarshalJSON encodes the checkpoint keeping the type of every primary key value.
+func (c SplitCheckpoint) MarshalJSON() ([]byte, error) {
+	var out checkpointJSON
+	var err error
+	if out.Lower, err = encodeCheckpointColumns(c.Lower); err != nil {
+		return nil, err
+	}
+	if out.Upper, err = encodeCheckpointColumns(c.Upper); err != nil {
+		return nil, err
+	}
+	if out.LastPK, err = encodeCheckpointColumns(c.LastPK); err != nil {
+		return nil, err
+	}
+	out.Done = c.Done
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a checkpoint encoded by MarshalJSON.
+func (c *SplitCheckpoint) UnmarshalJSON(data []byte) error {
+	var in checkpointJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	var decoded SplitCheckpoint
+	var err error
+	if decoded.Lower, err = decodeCheckpointColumns(in.Lower); err != nil {
+		return err
+	}
+	if decoded.Upper, err = decodeCheckpointColumns(in.Upper); err != nil {
+		return err
+	}
+	if decoded.LastPK, err = decodeCheckpointColumns(in.LastPK); err != nil {
+		return err
+	}
+	decoded.Done = in.Done
+	*c = decoded
+	return nil
+}
+
+func encodeCheckpointColumns(kvs []KeyValue) ([]checkpointColumn, error) {
+	if kvs == nil {
+		return nil, nil
+	}
+	cols := make([]checkpointColumn, len(kvs))
+	for i, kv := range kvs {
+		col := checkpointColumn{Name: kv.Key}
+		switch v := kv.Value.(type) {
+		case string:
+			col.Type, col.Value = "string", v
+		case int64:
+			col.Type, col.Value = "integer", strconv.FormatInt(v, 10)
+		case []byte:
+			col.Type, col.Value = "binary", base64.StdEncoding.EncodeToString(v)
+		case tablestore.PrimaryKeyOption:
+			switch v {
+			case tablestore.MIN:
+				col.Type = "min"
+			case tablestore.MAX:
+				col.Type = "max"
+			default:
+				return nil, fmt.Errorf("column %q has unsupported option %d", kv.Key, v)
+			}
+		default:
+			return nil, fmt.Errorf("column %q has invalid type: %T", kv.Key, kv.Value)
+		}
+		cols[i] = col
+	}
+	return cols, nil
+}
+
+func decodeCheckpointColumns(cols []checkpointColumn) ([]KeyValue, error) {
+	if cols == nil {
+		return nil, nil
+	}
+	kvs := make([]KeyValue, len(cols))
+	for i, col := range cols {
+		kvs[i].Key = col.Name
+		switch col.Type {
+		case "string":
+			kvs[i].Value = col.Value
+		case "integer":
+			v, err := strconv.ParseInt(col.Value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("column %q: %w", col.Name, err)
+			}
+			kvs[i].Value = v
+		case "binary":
+			v, err := base64.StdEncoding.DecodeString(col.Value)
+			if err != nil {
+				return nil, fmt.Errorf("column %q: %w", col.Name, err)
+			}
+			kvs[i].Value = v
+		case "min":
+			kvs[i].Value = tablestore.MIN
+		case "max":
+			kvs[i].Value = tablestore.MAX
+		default:
+			return nil, fmt.Errorf("column %q has unknown type %q", col.Name, col.Type)
+		}
+	}
+	return kvs, nil
+}
+
+// FileCheckpoint is a Checkpoint kept in a JSON file, rewritten through a temporary file and a
+// rename on every Save so that a crash never leaves it half written. A missing file is an empty
+// checkpoint; delete the file to start over.
+//
+// Example usage:
+//
+//	opts := ParallelScanOptions{Checkpoint: &FileCheckpoint{Path: "export.checkpoint.json"}}
+type FileCheckpoint struct {
+	Path string
+
+	mu     sync.Mutex
+	splits map[int]SplitCheckpoint
+}
+
+// Load implements Checkpoint.
+func (c *FileCheckpoint) Load(ctx context.Context) (map[int]SplitCheckpoint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	splits := make(map[int]SplitCheckpoint)
+	data, err := os.ReadFile(c.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &splits); err != nil {
+			return nil, fmt.Errorf("checkpoint %s: %w", c.Path, err)
+		}
+	}
+	c.splits = splits
+	return maps.Clone(splits), nil
+}
+
+// Save implements Checkpoint.
+func (c *FileCheckpoint) Save(ctx context.Context, split int, state SplitCheckpoint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.splits == nil {
+		c.splits = make(map[int]SplitCheckpoint)
+	}
+	c.splits[split] = state
+	data, err := json.Marshal(c.splits)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.Path), filepath.Base(c.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.Path)
+}
+
+// TableCheckpoint is a Checkpoint kept in a table, one row per split of the scan named Job, so
+// that several scans can share the table. The table has a STRING primary key column "job" and an
+// INTEGER primary key column "split"; each row holds the JSON of its SplitCheckpoint in the
+// "state" column. Rows are written with PutRow through Params, and are left in place when the
+// scan completes: delete them to start over.
+//
+// Example usage:
+//
+//	checkpoints := &OtsUtilsParams{Client: client, TableName: "scan_checkpoints"}
+//	opts := ParallelScanOptions{Checkpoint: &TableCheckpoint{Params: checkpoints, Job: "export-users"}}
+type TableCheckpoint struct {
+	Params *OtsUtilsParams
+	Job    string
+}
+
+// checkpointRow is a row of the table of a TableCheckpoint.
+type checkpointRow struct {
+	Job   *string `json:"job" pk:"1"`
+	Split *int64  `json:"split" pk:"2"`
+	State *string `json:"state"`
+}
+
+// Load implements Checkpoint.
+func (c *TableCheckpoint) Load(ctx context.Context) (map[int]SplitCheckpoint, error) {
+	if c.Job == "" {
+		return nil, fmt.Errorf("Job can not be empty")
+	}
+	var rows []checkpointRow
+	if err := QueryByPkPrefix(c.Params.WithContext(ctx), &checkpointRow{Job: &c.Job}, &rows); err != nil {
+		return nil, err
+	}
+
+	splits := make(map[int]SplitCheckpoint, len(rows))
+	for _, row := range rows {
+		if row.State == nil {
+			return nil, fmt.Errorf("checkpoint %s split %d has no state", c.Job, *row.Split)
+		}
+		var state SplitCheckpoint
+		if err := json.Unmarshal([]byte(*row.State), &state); err != nil {
+			return nil, fmt.Errorf("checkpoint %s split %d: %w", c.Job, *row.Split, err)
+		}
+		splits[int(*row.Split)] = state
+	}
+	return splits, nil
+}
+
+// Save implements Checkpoint.
+func (c *TableCheckpoint) Save(ctx context.Context, split int, state SplitCheckpoint) error {
+	if c.Job == "" {
+		return fmt.Errorf("Job can not be empty")
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	splitIndex, stateJSON := int64(split), string(data)
+	ignore := tablestore.RowExistenceExpectation_IGNORE
+	row := &checkpointRow{Job: &c.Job, Split: &splitIndex, State: &stateJSON}
+	return PutRow(c.Params.WithContext(ctx), row, PutRowParams{RowExistenceExpectation: &ignore})
+}
@@ -0,0 +1,59 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// TypeError lists every problem found in a row struct type.
+type TypeError struct {
+	Type     reflect.Type
+	Problems []error
+}
+
+func (e *TypeError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].Error()
+	}
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return fmt.Sprintf("type %s has %d problems: %s", e.Type, len(e.Problems), strings.Join(msgs, "; "))
+}
+
+func (e *TypeError) Unwrap() []error {
+	return e.Problems
+}
+
+// CheckType validates the row struct type of obj, a struct or a pointer to struct, the same way
+// ParseObj and ParseResult do on first use. The returned error is a *TypeError listing every
+// problem, so that a struct can be fixed in one pass.
+func CheckType(obj any) error {
+	t := reflect.TypeOf(obj)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("obj must be a struct or pointer to struct, got %T", obj)
+	}
+
+	_, err := getStructMeta(t)
+	return err
+}
+
+// MustRegister checks the row struct type of obj with CheckType and panics if it is invalid.
+// Call it from init() to fail at startup instead of on the first read or write.
+//
+// Example usage:
+//
+//	func init() {
+//	    otsutils.MustRegister(&User{})
+//	}
+func MustRegister(obj any) {
+	if err := CheckType(obj); err != nil {
+		panic("otsutils: MustRegister: " + err.Error())
+	}
+}
@@ -0,0 +1,157 @@
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckType(t *testing.T) {
+	ast := assert.New(t)
+
+	type valid struct {
+		Pk1  *string `json:"pk1" pk:"1"`
+		Col1 *int64  `json:"col1,omitempty"`
+		Note string
+	}
+	ast.NoError(CheckType(valid{}))
+	ast.NoError(CheckType(&valid{}))
+	ast.NoError(CheckType((*valid)(nil)))
+
+	ast.ErrorContains(CheckType(1), "obj must be a struct or pointer to struct, got int")
+	ast.ErrorContains(CheckType(nil), "obj must be a struct or pointer to struct")
+}
+
+func TestCheckTypeReportsAllProblems(t *testing.T) {
+	ast := assert.New(t)
+
+	type node struct {
+		Pk1    *uint    `json:"pk1" pk:"1"`
+		Pk2    *float64 `json:"pk2" pk:"2"`
+		Name   **string `json:"name"`
+		Value  any      `json:"value"`
+		Next   *node    `json:"next"`
+		Data   []int    `json:"data"`
+		Score  *float32 `json:"score"`
+		NoCol  *string  `json:"-" pk:"3"`
+		hidden *string  `pk:"4"`
+	}
+	err := CheckType(&node{})
+
+	var typeErr *TypeError
+	ast.True(errors.As(err, &typeErr))
+	ast.Len(typeErr.Problems, 9)
+	ast.Contains(err.Error(), "has 9 problems")
+
+	// 每个问题都带字段名与修改建议，主键与普通列的建议不同
+	ast.ErrorContains(err, "field Pk1 has invalid type: *uint. Only string, int64, and []byte fields and pointers to them are allowed; Tablestore integers are signed, use *int64 instead of *uint")
+	ast.ErrorContains(err, "field Pk2 has invalid type: *float64. Only string, int64, and []byte fields and pointers to them are allowed; primary key columns can only hold string, integer or binary values, so float64 cannot be a primary key")
+	ast.ErrorContains(err, "field Name has invalid type: **string. Only string, int64, float64, and []byte fields and pointers to them are allowed; use *string instead of **string")
+	ast.ErrorContains(err, "field Value has invalid type: interface {}. Only string, int64, float64, and []byte fields and pointers to them are allowed; interface fields are not supported, use a concrete type")
+	ast.ErrorContains(err, "nested structs are not supported, register a serializer for otsutils.node with RegisterTypeSerializer")
+	ast.ErrorContains(err, "field Data has invalid type: []int. Only string, int64, float64, and []byte fields and pointers to them are allowed; register a serializer for []int with RegisterTypeSerializer")
+	ast.ErrorContains(err, "field Score has invalid type: *float32. Only string, int64, float64, and []byte fields and pointers to them are allowed; use *float64 instead of *float32")
+	ast.ErrorContains(err, "field NoCol has a pk tag but is excluded from the columns by its json tag")
+	ast.ErrorContains(err, "field hidden is unexported but has a json or pk tag")
+}
+
+func TestCheckTypeDuplicates(t *testing.T) {
+	ast := assert.New(t)
+
+	type dupOrder struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk2 *string `json:"pk2" pk:"1"`
+	}
+	ast.EqualError(CheckType(&dupOrder{}), `field Pk2: invalid pk tag "1": order 1 is already used by field Pk1`)
+
+	// go vet 不允许重复的 json tag，这里动态构造结构体
+	dupColumn := reflect.StructOf([]reflect.StructField{
+		{Name: "Col1", Type: reflect.TypeOf((*string)(nil)), Tag: `json:"col"`},
+		{Name: "Col2", Type: reflect.TypeOf((*string)(nil)), Tag: `json:"col"`},
+	})
+	ast.EqualError(CheckType(reflect.New(dupColumn).Interface()), `field Col2 maps to column "col", which is already used by field Col1`)
+
+	// 未标 json tag 的字段与 json tag 的列名冲突
+	type dupUntagged struct {
+		Pk1    string  `json:"pk1" pk:"1"`
+		Status *string `json:"status"`
+		STATUS *string
+	}
+	ctx := context.Background()
+	_, _, err := ParseObj(ctx, &dupUntagged{})
+	ast.EqualError(err, `field STATUS maps to column "status", which is already used by field Status`)
+	ast.Equal(err, ParseResult(ctx, &dupUntagged{}, nil, nil))
+
+	// 检查结果按类型缓存，出错的类型也不会重复检查
+	_, _, again := ParseObj(ctx, &dupUntagged{})
+	ast.Same(err, again)
+}
+
+func TestParseResultChecksType(t *testing.T) {
+	ast := assert.New(t)
+
+	// ParseResult 与 ParseObj 对同一结构体给出相同的错误
+	type row struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Col *uint64 `json:"col"`
+	}
+	var r row
+	err := ParseResult(context.Background(), &r, []KeyValue{{Key: "pk1", Value: "a"}}, nil)
+	ast.EqualError(err, "field Col has invalid type: *uint64. Only string, int64, float64, and []byte fields and pointers to them are allowed; Tablestore integers are signed, use *int64 instead of *uint64")
+	_, _, objErr := ParseObj(context.Background(), &r)
+	ast.Equal(err.Error(), objErr.Error())
+}
+
+func TestRejectedFieldKinds(t *testing.T) {
+	cases := []struct {
+		name string
+		typ  reflect.Type
+		hint string
+	}{
+		{"pointer to pointer", reflect.TypeOf((**string)(nil)), "use *string instead of **string"},
+		{"pointer to pointer to struct", reflect.TypeOf((**struct{ A int })(nil)), "nested structs are not supported"},
+		{"interface", reflect.TypeOf((*any)(nil)).Elem(), "interface fields are not supported, use a concrete type"},
+		{"pointer to interface", reflect.TypeOf((*any)(nil)), "interface fields are not supported, use a concrete type"},
+		{"channel", reflect.TypeOf((chan string)(nil)), "chan fields cannot be stored in a column"},
+		{"func", reflect.TypeOf((func())(nil)), "func fields cannot be stored in a column"},
+		{"map", reflect.TypeOf((map[string]string)(nil)), "map fields are not supported, register a serializer for map[string]string with RegisterTypeSerializer"},
+		{"pointer to map", reflect.TypeOf((*map[string]int64)(nil)), "map fields are not supported, register a serializer for map[string]int64 with RegisterTypeSerializer"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ast := assert.New(t)
+			typ := reflect.StructOf([]reflect.StructField{
+				{Name: "Pk1", Type: reflect.TypeOf((*string)(nil)), Tag: `json:"pk1" pk:"1"`},
+				{Name: "Col", Type: c.typ, Tag: `json:"col"`},
+			})
+			obj := reflect.New(typ)
+			obj.Elem().Field(0).Set(reflect.ValueOf(tea.String("a")))
+			want := "field Col has invalid type: " + c.typ.String() + ". Only string, int64, float64, and []byte fields and pointers to them are allowed; " + c.hint
+
+			// 写路径与读路径给出同一条错误，且在访问字段值之前就报错
+			_, _, err := ParseObj(context.Background(), obj.Interface())
+			ast.ErrorContains(err, want)
+			err = ParseResult(context.Background(), obj.Interface(), []KeyValue{{Key: "pk1", Value: "a"}}, []KeyValue{{Key: "col", Value: "x"}})
+			ast.ErrorContains(err, want)
+			ast.ErrorContains(CheckType(obj.Interface()), want)
+		})
+	}
+}
+
+func TestMustRegister(t *testing.T) {
+	type valid struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+	}
+	type invalid struct {
+		Pk1 *uint64 `json:"pk1" pk:"1"`
+	}
+
+	assert.NotPanics(t, func() { MustRegister(&valid{}) })
+	assert.PanicsWithValue(t, "otsutils: MustRegister: field Pk1 has invalid type: *uint64. Only string, int64, and []byte fields and pointers to them are allowed; Tablestore integers are signed, use *int64 instead of *uint64", func() {
+		MustRegister(&invalid{})
+	})
+}
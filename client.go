@@ -3,45 +3,362 @@ package otsutils
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"time"
 
 	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 type otsUtilsParamsCtxKey struct{}
 
+// ClientOption configures the TableStore client created by NewClient.
+type ClientOption func(*clientOptions) error
+
+type clientOptions struct {
+	proxy          *url.URL
+	tlsConfig      *tls.Config
+	transport      http.RoundTripper
+	requestTimeout time.Duration
+}
+
+// WithProxy routes all requests through the given HTTP(S) or SOCKS5 proxy.
+// The URL must be absolute, e.g. "http://proxy.internal:3128".
+func WithProxy(rawURL string) ClientOption {
+	return func(o *clientOptions) error {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy url %q: %w", rawURL, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("invalid proxy url %q: scheme must be http, https or socks5", rawURL)
+		}
+		if u.Host == "" {
+			return fmt.Errorf("invalid proxy url %q: missing host", rawURL)
+		}
+		o.proxy = u
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for HTTPS endpoints,
+// e.g. to trust a private CA via RootCAs.
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return func(o *clientOptions) error {
+		if cfg == nil {
+			return fmt.Errorf("tls config can not be nil")
+		}
+		o.tlsConfig = cfg
+		return nil
+	}
+}
+
+// WithTransport replaces the HTTP transport of the underlying client entirely.
+// It can not be combined with WithProxy or WithTLSConfig.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(o *clientOptions) error {
+		if rt == nil {
+			return fmt.Errorf("transport can not be nil")
+		}
+		o.transport = rt
+		return nil
+	}
+}
+
+// WithRequestTimeout sets the HTTP request timeout of the client, which bounds every SDK call:
+// the SDK does not take a context, so OtsUtilsParams.OperationTimeout and the deadline of the
+// context can only classify a call that failed, not interrupt it. Set it to the largest
+// OperationTimeout in use. Defaults to the SDK default of 30 seconds.
+func WithRequestTimeout(d time.Duration) ClientOption {
+	return func(o *clientOptions) error {
+		if d <= 0 {
+			return fmt.Errorf("request timeout must be positive, got %s", d)
+		}
+		o.requestTimeout = d
+		return nil
+	}
+}
+
+// buildTransport returns the transport described by the options,
+// or nil when the SDK default transport should be used.
+func (o *clientOptions) buildTransport(config *tablestore.TableStoreConfig) (http.RoundTripper, error) {
+	if o.transport != nil {
+		if o.proxy != nil || o.tlsConfig != nil {
+			return nil, fmt.Errorf("WithTransport can not be combined with WithProxy or WithTLSConfig")
+		}
+		return o.transport, nil
+	}
+	if o.proxy == nil && o.tlsConfig == nil {
+		return nil, nil
+	}
+
+	// Mirror the SDK's default transport settings
+	transport := &http.Transport{
+		MaxIdleConnsPerHost: config.MaxIdleConnections,
+		IdleConnTimeout:     config.IdleConnTimeout,
+		DialContext: (&net.Dialer{
+			Timeout: config.HTTPTimeout.ConnectionTimeout,
+		}).DialContext,
+		TLSClientConfig: o.tlsConfig,
+	}
+	if o.proxy != nil {
+		transport.Proxy = http.ProxyURL(o.proxy)
+	}
+	return transport, nil
+}
+
 // NewClient creates a new TableStore client with the provided credentials.
-// It will panic if any of the required parameters are empty.
-func NewClient(ctx context.Context, endPoint, instanceName, accessKeyId, accessKeySecret string) *tablestore.TableStoreClient {
+// It will panic if any of the required parameters are empty or an option is invalid.
+//
+// Example usage:
+//
+//	client := NewClient(ctx, endPoint, instanceName, ak, sk,
+//	    WithProxy("http://proxy.internal:3128"),
+//	    WithTLSConfig(&tls.Config{RootCAs: pool}),
+//	)
+func NewClient(ctx context.Context, endPoint, instanceName, accessKeyId, accessKeySecret string, opts ...ClientOption) *tablestore.TableStoreClient {
 	logger := log.Ctx(ctx)
 
 	if endPoint == "" || instanceName == "" || accessKeyId == "" || accessKeySecret == "" {
 		logger.Panic().Msg("endPoint, instanceName, accessKeyId, accessKeySecret can not be empty")
 	}
-	return tablestore.NewClient(endPoint, instanceName, accessKeyId, accessKeySecret)
+
+	o := &clientOptions{}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			logger.Panic().Err(err).Msg("invalid client option")
+		}
+	}
+
+	config := tablestore.NewDefaultTableStoreConfig()
+	transport, err := o.buildTransport(config)
+	if err != nil {
+		logger.Panic().Err(err).Msg("invalid client option")
+	}
+	if transport == nil && o.requestTimeout == 0 {
+		return tablestore.NewClient(endPoint, instanceName, accessKeyId, accessKeySecret)
+	}
+	config.Transport = transport
+	if o.requestTimeout > 0 {
+		config.HTTPTimeout.RequestTimeout = o.requestTimeout
+	}
+	return tablestore.NewClientWithConfig(endPoint, instanceName, accessKeyId, accessKeySecret, "", config)
+}
+
+// OtsClient is the part of the TableStore client API every client must implement: the
+// single-row operations. It is frozen; the other operations are feature-detected on the
+// client with the narrower interfaces below, so a client only implements what it is used
+// for, and operations on a client lacking their interface fail with ErrUnsupportedOperation.
+// *tablestore.TableStoreClient implements all of them; tests can substitute an in-memory
+// fake such as the one in the otsfake package.
+type OtsClient interface {
+	PutRow(request *tablestore.PutRowRequest) (*tablestore.PutRowResponse, error)
+	GetRow(request *tablestore.GetRowRequest) (*tablestore.GetRowResponse, error)
+	UpdateRow(request *tablestore.UpdateRowRequest) (*tablestore.UpdateRowResponse, error)
+	DeleteRow(request *tablestore.DeleteRowRequest) (*tablestore.DeleteRowResponse, error)
+}
+
+// RangeClient is implemented by clients supporting GetRange and the scans built on it.
+type RangeClient interface {
+	GetRange(request *tablestore.GetRangeRequest) (*tablestore.GetRangeResponse, error)
+}
+
+// BatchClient is implemented by clients supporting the batch row operations.
+type BatchClient interface {
+	BatchGetRow(request *tablestore.BatchGetRowRequest) (*tablestore.BatchGetRowResponse, error)
+	BatchWriteRow(request *tablestore.BatchWriteRowRequest) (*tablestore.BatchWriteRowResponse, error)
+}
+
+// SplitClient is implemented by clients supporting ParallelScan.
+type SplitClient interface {
+	ComputeSplitPointsBySize(request *tablestore.ComputeSplitPointsBySizeRequest) (*tablestore.ComputeSplitPointsBySizeResponse, error)
+}
+
+// SQLClient is implemented by clients supporting QuerySQL.
+type SQLClient interface {
+	SQLQuery(request *tablestore.SQLQueryRequest) (*tablestore.SQLQueryResponse, error)
+}
+
+// TableClient is implemented by clients supporting the table management operations.
+type TableClient interface {
+	ListTable() (*tablestore.ListTableResponse, error)
+	CreateTable(request *tablestore.CreateTableRequest) (*tablestore.CreateTableResponse, error)
+	DeleteTable(request *tablestore.DeleteTableRequest) (*tablestore.DeleteTableResponse, error)
+	UpdateTable(request *tablestore.UpdateTableRequest) (*tablestore.UpdateTableResponse, error)
+	DescribeTable(request *tablestore.DescribeTableRequest) (*tablestore.DescribeTableResponse, error)
+}
+
+// IndexClient is implemented by clients supporting the secondary index operations.
+type IndexClient interface {
+	CreateIndex(request *tablestore.CreateIndexRequest) (*tablestore.CreateIndexResponse, error)
+	DeleteIndex(request *tablestore.DeleteIndexRequest) (*tablestore.DeleteIndexResponse, error)
+}
+
+// SearchClient is implemented by clients supporting the search index queries.
+type SearchClient interface {
+	Search(request *tablestore.SearchRequest) (*tablestore.SearchResponse, error)
+}
+
+// TransactionClient is implemented by clients supporting local transactions.
+type TransactionClient interface {
+	StartLocalTransaction(request *tablestore.StartLocalTransactionRequest) (*tablestore.StartLocalTransactionResponse, error)
+	CommitTransaction(request *tablestore.CommitTransactionRequest) (*tablestore.CommitTransactionResponse, error)
+	AbortTransaction(request *tablestore.AbortTransactionRequest) (*tablestore.AbortTransactionResponse, error)
+}
+
+var (
+	_ OtsClient         = (*tablestore.TableStoreClient)(nil)
+	_ RangeClient       = (*tablestore.TableStoreClient)(nil)
+	_ BatchClient       = (*tablestore.TableStoreClient)(nil)
+	_ SplitClient       = (*tablestore.TableStoreClient)(nil)
+	_ SQLClient         = (*tablestore.TableStoreClient)(nil)
+	_ TableClient       = (*tablestore.TableStoreClient)(nil)
+	_ IndexClient       = (*tablestore.TableStoreClient)(nil)
+	_ SearchClient      = (*tablestore.TableStoreClient)(nil)
+	_ TransactionClient = (*tablestore.TableStoreClient)(nil)
+)
+
+// clientAs returns client as the optional interface C, or an error wrapping
+// ErrUnsupportedOperation when the client does not implement it.
+func clientAs[C any](client OtsClient) (C, error) {
+	c, ok := client.(C)
+	if !ok {
+		return c, fmt.Errorf("%w: %T does not implement %s", ErrUnsupportedOperation, client, reflect.TypeFor[C]().Name())
+	}
+	return c, nil
 }
 
 // OtsUtilsParams holds the TableStore client and table name.
 type OtsUtilsParams struct {
-	Client    *tablestore.TableStoreClient
+	Client    OtsClient
 	TableName string
+
+	// ReadClient, when set, serves read operations (GetRow, BatchGetRows, GetRange, ParallelScan and
+	// their variants) while writes always go to Client. Use ReadFromPrimary to force a read onto Client.
+	ReadClient OtsClient
+
+	// AuditHook, when set, is called after every successful PutRow, UpdateRow and DeleteRow
+	// with the row before and after the mutation.
+	AuditHook AuditHook
+
+	// AuditCheap skips the GetRow that captures the before image for AuditHook.
+	AuditCheap bool
+
+	// AuditFatal makes audit failures fail the operation; by default they are only logged.
+	AuditFatal bool
+
+	// CULogThreshold raises the completion log of an operation from debug to info
+	// when it consumed more than this many capacity units. Zero disables it.
+	CULogThreshold int32
+
+	// InstanceName is optional and only used to make error messages more descriptive.
+	InstanceName string
+
+	// TableMetaTTL is how long TableMeta caches the table description. Defaults to DefaultTableMetaTTL.
+	TableMetaTTL time.Duration
+
+	// DefaultMaxVersion is the number of versions GetRow and its variants read per column when
+	// GetRowParams.MaxVersion is not set. Defaults to 1.
+	DefaultMaxVersion int32
+
+	// OperationTimeout is the time budget of each SDK call, in addition to the deadline of the
+	// context. The SDK does not take a context, so calls are never abandoned: a call is bounded by
+	// the HTTP request timeout of the client, see WithRequestTimeout, and one timing out after
+	// OperationTimeout fails with ErrOperationTimeout. A call answered after the budget ran out
+	// keeps its outcome: a write applied succeeds, a service error is returned as is. Zero
+	// disables it.
+	OperationTimeout time.Duration
+
+	// DeadlineFloor is the time an operation needs to have left on the caller's context to
+	// count as slow rather than started too late: when the deadline expires, operations that
+	// started with less fail with ErrCallerDeadline, others with ErrOperationTimeout.
+	// Defaults to DefaultDeadlineFloor.
+	DeadlineFloor time.Duration
+
+	// MetricsHook, when set, is called after every SDK call with its latency, its deadline and
+	// its outcome.
+	MetricsHook MetricsHook
+
+	// Logger, when set, is used for operation logs instead of the logger in the context.
+	// WithLogger overrides it for a single call.
+	Logger *zerolog.Logger
 }
 
 // WithContext adds the OtsUtilsParams to the context.
-// It will panic if TableName or Client are not set.
+// It will panic if TableName or Client are not set, or if TableName breaks the Tablestore naming rules.
 func (otsUtilsParams *OtsUtilsParams) WithContext(ctx context.Context) context.Context {
 	logger := log.Ctx(ctx)
 
 	if otsUtilsParams.TableName == "" {
 		logger.Panic().Msg("TableName can not be empty")
 	}
+	if err := validateName("table", otsUtilsParams.TableName); err != nil {
+		logger.Panic().Err(err).Msg("TableName is invalid")
+	}
 	if otsUtilsParams.Client == nil {
 		logger.Panic().Msg("Client can not be nil")
 	}
+	if otsUtilsParams.DefaultMaxVersion < 0 {
+		logger.Panic().Int32("defaultMaxVersion", otsUtilsParams.DefaultMaxVersion).Msg("DefaultMaxVersion can not be negative")
+	}
 
 	return context.WithValue(ctx, otsUtilsParamsCtxKey{}, otsUtilsParams)
 }
 
+type readFromPrimaryCtxKey struct{}
+
+// ReadFromPrimary returns a context whose read operations use Client even when ReadClient is set,
+// e.g. to read back a row that was just written.
+//
+// Example usage:
+//
+//	err := GetRow(ReadFromPrimary(ctx), &row)
+func ReadFromPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readFromPrimaryCtxKey{}, true)
+}
+
+type loggerCtxKey struct{}
+
+// WithLogger returns a context whose operations log to logger, taking precedence over both
+// OtsUtilsParams.Logger and the zerolog logger of the context.
+//
+// Example usage:
+//
+//	err := PutRow(WithLogger(ctx, &jobLogger), &row)
+func WithLogger(ctx context.Context, logger *zerolog.Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, logger)
+}
+
+// baseLogger returns the logger operations derive their logger from: the one set with
+// WithLogger, then OtsUtilsParams.Logger, then zerolog.Ctx(ctx).
+func (otsUtilsParams *OtsUtilsParams) baseLogger(ctx context.Context) *zerolog.Logger {
+	if logger, _ := ctx.Value(loggerCtxKey{}).(*zerolog.Logger); logger != nil {
+		return logger
+	}
+	if otsUtilsParams.Logger != nil {
+		return otsUtilsParams.Logger
+	}
+	return zerolog.Ctx(ctx)
+}
+
+// clientFor returns the client that serves the operation and its name for logging.
+func (otsUtilsParams *OtsUtilsParams) clientFor(ctx context.Context, operation string) (OtsClient, string) {
+	if otsUtilsParams.ReadClient != nil && readOperations[operation] {
+		if primary, _ := ctx.Value(readFromPrimaryCtxKey{}).(bool); !primary {
+			return otsUtilsParams.ReadClient, "read"
+		}
+	}
+	return otsUtilsParams.Client, "primary"
+}
+
 // OtsUtilsParamsFromCtx retrieves the OtsUtilsParams from the context.
 func OtsUtilsParamsFromCtx(ctx context.Context) *OtsUtilsParams {
 	otsUtilsParams := ctx.Value(otsUtilsParamsCtxKey{})
@@ -57,4 +374,4 @@ func otsUtilsParamsFromCtx(ctx context.Context) *OtsUtilsParams {
 	}
 
 	return otsUtilsParams
-}
\ No newline at end of file
+}
@@ -0,0 +1,182 @@
+package otsutils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/117503445/goutils"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/otsprotocol"
+	"github.com/golang/protobuf/proto"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// listTableHandler answers every request with a ListTable response containing tableName.
+func listTableHandler(t *testing.T, tableName string, hits *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		body, err := proto.Marshal(&otsprotocol.ListTableResponse{TableNames: []string{tableName}})
+		if err != nil {
+			t.Error(err)
+		}
+		_, _ = w.Write(body)
+	}
+}
+
+func TestClientWithTLSConfig(t *testing.T) {
+	goutils.InitZeroLog()
+	ctx := log.Logger.WithContext(context.Background())
+	ast := assert.New(t)
+
+	var hits int32
+	server := httptest.NewTLSServer(listTableHandler(t, "tls_table", &hits))
+	defer server.Close()
+
+	// 信任自签名证书后请求应成功
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	client := NewClient(ctx, server.URL, "test", "ak", "sk", WithTLSConfig(&tls.Config{RootCAs: pool}))
+
+	resp, err := client.ListTable()
+	ast.NoError(err)
+	ast.Equal([]string{"tls_table"}, resp.TableNames)
+	ast.Equal(int32(1), atomic.LoadInt32(&hits))
+
+	// 默认配置不信任该证书
+	client = NewClient(ctx, server.URL, "test", "ak", "sk")
+	_, err = client.ListTable()
+	ast.Error(err)
+	ast.Equal(int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestClientWithProxy(t *testing.T) {
+	goutils.InitZeroLog()
+	ctx := log.Logger.WithContext(context.Background())
+	ast := assert.New(t)
+
+	var hits int32
+	proxy := httptest.NewServer(listTableHandler(t, "proxied_table", &hits))
+	defer proxy.Close()
+
+	// endpoint 不可达，只有经过代理才能拿到响应
+	client := NewClient(ctx, "http://ots.invalid", "test", "ak", "sk", WithProxy(proxy.URL))
+
+	resp, err := client.ListTable()
+	ast.NoError(err)
+	ast.Equal([]string{"proxied_table"}, resp.TableNames)
+	ast.Equal(int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestClientInvalidOptions(t *testing.T) {
+	goutils.InitZeroLog()
+	ctx := log.Logger.WithContext(context.Background())
+	ast := assert.New(t)
+
+	newClient := func(opts ...ClientOption) func() {
+		return func() {
+			NewClient(ctx, "https://test.cn-hangzhou.ots.aliyuncs.com", "test", "ak", "sk", opts...)
+		}
+	}
+
+	ast.Panics(newClient(WithProxy("proxy.internal:3128")))
+	ast.Panics(newClient(WithProxy("ftp://proxy.internal")))
+	ast.Panics(newClient(WithProxy("http://")))
+	ast.Panics(newClient(WithTLSConfig(nil)))
+	ast.Panics(newClient(WithTransport(http.DefaultTransport), WithProxy("http://proxy.internal:3128")))
+	ast.NotPanics(newClient(WithProxy("socks5://proxy.internal:1080")))
+	ast.NotPanics(newClient(WithTransport(http.DefaultTransport)))
+	ast.Panics(newClient(WithRequestTimeout(0)))
+	ast.NotPanics(newClient(WithRequestTimeout(time.Second), WithProxy("http://proxy.internal:3128")))
+}
+
+func TestPing(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	// 使用上下文中的表
+	ast.NoError(Ping(ctx))
+	ast.Equal(1, fake.CallCount("DescribeTable"))
+
+	// 使用裸客户端
+	ast.NoError(Ping(context.Background(), PingParams{Client: fake}))
+	ast.Equal(1, fake.CallCount("ListTable"))
+
+	failure := func(err error) PingFailure {
+		var pingErr *PingError
+		if !ast.ErrorAs(err, &pingErr) {
+			return -1
+		}
+		return pingErr.Failure
+	}
+
+	fake.Intercept = func(operation string, request any) error {
+		return &tablestore.OtsError{Code: CodeAuthFailed, Message: "signature mismatch", HttpStatusCode: 403}
+	}
+	ast.Equal(PingFailureAuth, failure(Ping(ctx)))
+
+	fake.Intercept = func(operation string, request any) error {
+		return &tablestore.OtsError{Code: CodeServerBusy, Message: "busy", HttpStatusCode: 503}
+	}
+	err := Ping(ctx)
+	ast.Equal(PingFailureThrottled, failure(err))
+	ast.Equal(CodeServerBusy, Code(err))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	fake.Intercept = func(operation string, request any) error {
+		close(started)
+		<-release
+		return nil
+	}
+	ast.Equal(PingFailureConnectivity, failure(Ping(ctx, PingParams{Timeout: 20 * time.Millisecond})))
+	<-started
+	close(release)
+
+	// 表不存在
+	fake.Intercept = nil
+	o := OtsUtilsParams{Client: fake, TableName: "missing"}
+	ast.Equal(PingFailureUnknown, failure(Ping(o.WithContext(ctx))))
+
+	// 无法连接的 endpoint
+	client := NewClient(ctx, "http://127.0.0.1:1", "test", "ak", "sk")
+	ast.Equal(PingFailureConnectivity, failure(Ping(ctx, PingParams{Client: client, Timeout: 500 * time.Millisecond})))
+
+	// 既没有客户端也没有上下文参数
+	ast.Panics(func() { _ = Ping(log.Logger.WithContext(context.Background())) })
+}
+
+// rowOnlyClient implements OtsClient and none of the optional client interfaces.
+type rowOnlyClient struct {
+	OtsClient
+}
+
+func TestOptionalClientInterfaces(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	o := OtsUtilsParams{Client: rowOnlyClient{fake}, TableName: "test_table"}
+	ctx = o.WithContext(ctx)
+
+	// 单行操作只需要 OtsClient
+	row := TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v")}
+	ast.NoError(PutRow(ctx, &row))
+	got := TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+	ast.NoError(GetRow(ctx, &got))
+	ast.Equal("v", *got.Col1)
+
+	// 其余操作在客户端未实现对应接口时返回 ErrUnsupportedOperation
+	var rows []TestRow
+	err := GetRange(ctx, &TestRow{}, &TestRow{}, &rows)
+	ast.ErrorIs(err, ErrUnsupportedOperation)
+	ast.ErrorContains(err, "otsutils.rowOnlyClient does not implement RangeClient")
+	_, err = ListTables(ctx)
+	ast.ErrorIs(err, ErrUnsupportedOperation)
+	ast.ErrorIs(Ping(ctx), ErrUnsupportedOperation)
+}
@@ -0,0 +1,15 @@
+// Command rowcheck reports row struct types that otsutils would reject at runtime.
+//
+// It is meant to be run by go vet:
+//
+//	go install github.com/117503445/otsutils/cmd/rowcheck
+//	go vet -vettool=$(which rowcheck) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"github.com/117503445/otsutils/rowcheck"
+)
+
+func main() { unitchecker.Main(rowcheck.Analyzer) }
@@ -0,0 +1,96 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// deleteColumnsAttempts bounds the read-then-update rounds of DeleteColumnsIfPresent
+// when the columns keep changing between the read and the update.
+const deleteColumnsAttempts = 3
+
+// DeleteColumnsIfPresent deletes the given attribute columns from the row whose primary key is
+// taken from the pk fields of pkObj, and returns the columns that existed and were removed.
+// A missing row or missing columns are not errors; removed is then empty.
+//
+// A single UpdateRow cannot report which columns it deleted, so the columns are read from the
+// primary client first and then deleted under a condition that they still hold the values read.
+// If another writer changes them in between, the condition fails and the read is retried, up to
+// three times, after which the CodeConditionCheckFail error is returned.
+//
+// Example usage:
+//
+//	removed, err := DeleteColumnsIfPresent(ctx, &MyRow{PK1: tea.String("pk1value")}, "tmp", "draft")
+func DeleteColumnsIfPresent(ctx context.Context, pkObj any, columns ...string) (removed []string, err error) {
+	if len(columns) == 0 {
+		return nil, nil
+	}
+	pks, err := FromStruct(pkObj).Build()
+	if err != nil {
+		return nil, err
+	}
+	obj := &rowKeyValues{PrimaryKey: pks}
+
+	for attempt := 1; ; attempt++ {
+		// Read the current values of the columns
+		var getResp *tablestore.GetRowResponse
+		buildGet := func(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+			req, err := buildGetRowRequest(ctx, otsParams, logger, obj, params...)
+			if err != nil {
+				return nil, err
+			}
+			req.(*tablestore.GetRowRequest).SingleRowQueryCriteria.ColumnsToGet = columns
+			return req, nil
+		}
+		err := executeOTSOperation(ctx, "DeleteColumnsIfPresent", obj, buildGet, executeGetRow, captureGetRowResponse(&getResp))
+		if err != nil || getResp == nil {
+			return nil, err
+		}
+		present := columnsToKeyValues(getResp.Columns)
+		if len(present) == 0 {
+			return nil, nil
+		}
+
+		// Delete them only if they still hold the values read
+		removed = make([]string, len(present))
+		for i, col := range present {
+			removed[i] = col.Key
+		}
+		buildUpdate := func(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+			req, err := buildUpdateRowRequest(ctx, otsParams, logger, obj, params...)
+			if err != nil {
+				return nil, err
+			}
+			req.(*tablestore.UpdateRowRequest).UpdateRowChange.SetColumnCondition(unchangedColumnsCondition(present))
+			return req, nil
+		}
+		expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+		params := UpdateRowParams{RowExistenceExpectation: &expectExist, DeletedColumns: removed}
+		err = executeOTSOperation(ctx, "DeleteColumnsIfPresent", obj, buildUpdate, executeUpdateRow, nil, params)
+		if err == nil {
+			return removed, nil
+		}
+		if Code(err) != CodeConditionCheckFail || attempt == deleteColumnsAttempts {
+			return nil, err
+		}
+	}
+}
+
+// unchangedColumnsCondition returns a condition that holds while the latest version of every
+// column still has the given value.
+func unchangedColumnsCondition(cols []KeyValue) tablestore.ColumnFilter {
+	conditions := make([]tablestore.ColumnFilter, len(cols))
+	for i, col := range cols {
+		cond := tablestore.NewSingleColumnCondition(col.Key, tablestore.CT_EQUAL, col.Value)
+		cond.FilterIfMissing = true
+		cond.LatestVersionOnly = true
+		conditions[i] = cond
+	}
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	return &tablestore.CompositeColumnValueFilter{Operator: tablestore.LO_AND, Filters: conditions}
+}
@@ -0,0 +1,99 @@
+package otsutils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteColumnsIfPresent(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	key := &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)}
+
+	// 行不存在
+	removed, err := DeleteColumnsIfPresent(ctx, key, "col1")
+	ast.NoError(err)
+	ast.Empty(removed)
+	ast.Equal(0, fake.CallCount("UpdateRow"))
+
+	ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col1: tea.String("a"), Col2: tea.Int64(2), Col3: tea.String("c")}))
+
+	// 只返回实际存在并被删除的列
+	removed, err = DeleteColumnsIfPresent(ctx, key, "col1", "missing", "col2")
+	ast.NoError(err)
+	ast.ElementsMatch([]string{"col1", "col2"}, removed)
+
+	got := TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)}
+	ast.NoError(GetRow(ctx, &got))
+	ast.Nil(got.Col1)
+	ast.Nil(got.Col2)
+	ast.Equal("c", tea.StringValue(got.Col3))
+
+	// 列都不存在时不发起更新
+	updates := fake.CallCount("UpdateRow")
+	removed, err = DeleteColumnsIfPresent(ctx, key, "col1", "missing")
+	ast.NoError(err)
+	ast.Empty(removed)
+	ast.Equal(updates, fake.CallCount("UpdateRow"))
+
+	removed, err = DeleteColumnsIfPresent(ctx, key)
+	ast.NoError(err)
+	ast.Empty(removed)
+}
+
+func TestDeleteColumnsIfPresentConcurrentChange(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	key := &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)}
+	ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col1: tea.String("a"), Col3: tea.String("c")}))
+
+	// 第一次读取之后、更新之前，另一个写入者删除了 col1
+	changed := false
+	fake.Intercept = func(operation string, request any) error {
+		if operation != "UpdateRow" || changed {
+			return nil
+		}
+		changed = true
+		change := &tablestore.UpdateRowChange{TableName: "test_table", PrimaryKey: &tablestore.PrimaryKey{}}
+		change.PrimaryKey.AddPrimaryKeyColumn("pk1", "pk1")
+		change.PrimaryKey.AddPrimaryKeyColumn("pk2", int64(1))
+		change.DeleteColumn("col1")
+		change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		_, err := fake.UpdateRow(&tablestore.UpdateRowRequest{UpdateRowChange: change})
+		return err
+	}
+
+	// 条件失败后重新读取，只删除仍然存在的列
+	removed, err := DeleteColumnsIfPresent(ctx, key, "col1", "col3")
+	ast.NoError(err)
+	ast.Equal([]string{"col3"}, removed)
+
+	// 一直被并发修改时重试有限次后返回条件失败
+	ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(2), Col1: tea.String("a")}))
+	n := 0
+	fake.Intercept = func(operation string, request any) error {
+		if operation != "UpdateRow" {
+			return nil
+		}
+		if req := request.(*tablestore.UpdateRowRequest); req.UpdateRowChange.Condition.ColumnCondition == nil {
+			return nil
+		}
+		n++
+		change := &tablestore.UpdateRowChange{TableName: "test_table", PrimaryKey: &tablestore.PrimaryKey{}}
+		change.PrimaryKey.AddPrimaryKeyColumn("pk1", "pk1")
+		change.PrimaryKey.AddPrimaryKeyColumn("pk2", int64(2))
+		change.PutColumn("col1", fmt.Sprintf("changed-%d", n))
+		change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		_, err := fake.UpdateRow(&tablestore.UpdateRowRequest{UpdateRowChange: change})
+		return err
+	}
+	_, err = DeleteColumnsIfPresent(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(2)}, "col1")
+	ast.Equal(CodeConditionCheckFail, Code(err))
+	ast.Equal(deleteColumnsAttempts, n)
+}
@@ -0,0 +1,296 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// DefaultCopyWorkers is the number of BatchWriteRow requests CopyTable sends at the same time
+// when CopyOptions.Workers is not set.
+const DefaultCopyWorkers = 4
+
+// CopyReport is the outcome of a CopyTable.
+type CopyReport struct {
+	// RowsCopied is the number of rows written to the destination table.
+	RowsCopied int64
+
+	// Bytes is the approximate size of the rows written, as estimated by EstimateRowSize.
+	Bytes int64
+
+	// Failures lists the rows the destination rejected, in no particular order.
+	Failures []CopyFailure
+}
+
+// CopyFailure is a row CopyTable could not write.
+type CopyFailure struct {
+	// PrimaryKey is the primary key of the row.
+	PrimaryKey []KeyValue
+
+	// Err is the *RowError the destination returned for the row.
+	Err error
+}
+
+// CopyTable copies the rows of the table of src to the table of dst, which may belong to another
+// instance, and reports what was copied. The tables must have the same primary key schema. Each
+// row is put with its newest version, overwriting the row of dst with the same primary key; the
+// destination assigns the timestamps, as the original ones may be rejected by its max time
+// deviation.
+//
+// The range of src is scanned in primary key order, through src.ReadClient when it is set, and
+// each page is written to dst with one BatchWriteRow by one of Workers goroutines, at most
+// MaxBatchWriteRows rows and MaxRequestSize bytes each. Rows dst throttles are sent again as in
+// TruncateTable; rows it rejects are listed in CopyReport.Failures and do not stop the copy.
+// The first read error, or a request dst rejects as a whole, stops the copy; the report returned
+// with it describes the rows written before.
+//
+// Example usage:
+//
+//	// The orders of user u1, at most 500 rows per second
+//	report, err := CopyTable(ctx, &OtsUtilsParams{Client: staging, TableName: "orders"},
+//	    &OtsUtilsParams{Client: prod, TableName: "orders"},
+//	    CopyOptions{Start: PK().String("user_id", "u1"), End: PK().String("user_id", "u2"), RowsPerSecond: 500})
+func CopyTable(ctx context.Context, src, dst *OtsUtilsParams, opts CopyOptions) (*CopyReport, error) {
+	return copyTable(ctx, src, dst, opts, nil)
+}
+
+// CopyJob is a CopyTable running in the background, started by StartCopyTable.
+type CopyJob struct {
+	job *backgroundJob[*CopyReport]
+}
+
+// StartCopyTable starts CopyTable on a goroutine of its own and returns at once. Wait returns
+// its outcome, and Close stops it early.
+//
+// Example usage:
+//
+//	job := StartCopyTable(ctx, src, dst, CopyOptions{})
+//	<-shutdown
+//	closeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+//	defer cancel()
+//	err := job.Close(closeCtx)
+func StartCopyTable(ctx context.Context, src, dst *OtsUtilsParams, opts CopyOptions) *CopyJob {
+	return &CopyJob{job: startBackgroundJob(ctx, func(ctx context.Context, stop <-chan struct{}) (*CopyReport, error) {
+		return copyTable(ctx, src, dst, opts, stop)
+	})}
+}
+
+// Wait waits for the end of the copy and returns its outcome, as CopyTable.
+func (j *CopyJob) Wait() (*CopyReport, error) {
+	return j.job.wait()
+}
+
+// Close stops the copy and flushes it: no more page is read from src, and the rows already read
+// are written to dst. When ctx is done first, the writes still pending are cancelled and Close
+// returns ctx.Err() once they returned; otherwise it returns the error of the copy, and Wait the
+// rows copied. Close may be called more than once, and after Wait.
+func (j *CopyJob) Close(ctx context.Context) error {
+	return j.job.close(ctx)
+}
+
+// copyTable is CopyTable, reading no page once stop is closed.
+func copyTable(ctx context.Context, src, dst *OtsUtilsParams, opts CopyOptions, stop <-chan struct{}) (*CopyReport, error) {
+	if src == nil || dst == nil {
+		return nil, fmt.Errorf("src and dst can not be nil")
+	}
+	if src.Client == dst.Client && src.TableName == dst.TableName {
+		return nil, fmt.Errorf("src and dst are the same table '%s'", src.TableName)
+	}
+	if opts.Workers < 0 {
+		return nil, fmt.Errorf("Workers must not be negative, got %d", opts.Workers)
+	}
+	workers := opts.Workers
+	if workers == 0 {
+		workers = DefaultCopyWorkers
+	}
+	if opts.RowsPerSecond < 0 {
+		return nil, fmt.Errorf("RowsPerSecond must not be negative, got %d", opts.RowsPerSecond)
+	}
+	if opts.Retries <= 0 {
+		opts.Retries = DefaultBatchWriteRetries
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = DefaultBatchWriteBackoff
+	}
+	scanParams := GetRangeParams{ColumnsToGet: opts.Columns}
+	if err := scanParams.validate(); err != nil {
+		return nil, err
+	}
+
+	copyCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	copyCtx, job := startJob(src.WithContext(copyCtx), "CopyTable", opts.Listener)
+	// The scan is a read of src as a whole, so it goes to its read client
+	srcRead := *src
+	if src.ReadClient != nil {
+		srcRead.Client = src.ReadClient
+	}
+	srcCtx, dstCtx := srcRead.WithContext(copyCtx), dst.WithContext(copyCtx)
+
+	start, end := opts.Start, opts.End
+	if start == nil {
+		start = PK()
+	}
+	if end == nil {
+		end = PK()
+	}
+	_, page, err := newRangeScan(srcCtx, start, end, rowMapType, GetRangeParams{})
+	if err != nil {
+		job.finish(err)
+		return nil, err
+	}
+	endPK := page.EndPrimaryKey
+	limit := int32(MaxBatchWriteRows)
+	if opts.RowsPerSecond > 0 {
+		// A batch is never more than a second of the rate
+		limit = int32(min(MaxBatchWriteRows, opts.RowsPerSecond))
+	}
+	page.Limit = limit
+
+	report := &CopyReport{}
+	var mu sync.Mutex
+	var errs []error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// Once cancelled, the other workers only report the cancellation
+		if copyCtx.Err() == nil || !errors.Is(err, copyCtx.Err()) {
+			errs = append(errs, err)
+		}
+		cancel()
+	}
+
+	batches := make(chan []*tablestore.Row)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rows := range batches {
+				if err := copyBatch(dstCtx, rows, opts, report, &mu); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+
+	var next time.Time
+scan:
+	for page != nil {
+		select {
+		case <-stop:
+			break scan
+		default:
+		}
+		var rows []*tablestore.Row
+		nextPage := (*rangePage)(nil)
+		handleScan := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+			r := resp.(*tablestore.GetRangeResponse)
+			rows = r.Rows
+			job.consumed(r.ConsumedCapacityUnit)
+			if r.NextStartPrimaryKey != nil {
+				nextPage = &rangePage{StartPrimaryKey: r.NextStartPrimaryKey, EndPrimaryKey: endPK, Limit: limit}
+			}
+			return nil
+		}
+		if err := executeOTSOperation(srcCtx, "CopyTable", page, buildGetRangeRequest, executeGetRange, handleScan, scanParams); err != nil {
+			fail(err)
+			break
+		}
+		page = nextPage
+
+		for _, batch := range splitCopyRows(rows) {
+			if opts.RowsPerSecond > 0 {
+				if wait := time.Until(next); wait > 0 {
+					timer := time.NewTimer(wait)
+					select {
+					case <-copyCtx.Done():
+						timer.Stop()
+						break scan
+					case <-timer.C:
+					}
+				}
+				if now := time.Now(); next.Before(now) {
+					next = now
+				}
+				next = next.Add(time.Duration(len(batch)) * time.Second / time.Duration(opts.RowsPerSecond))
+			}
+			select {
+			case batches <- batch:
+			case <-copyCtx.Done():
+				break scan
+			}
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	err = ctx.Err()
+	if len(errs) > 0 {
+		err = errors.Join(errs...)
+	}
+	job.finish(err)
+	return report, err
+}
+
+// splitCopyRows splits the rows of a page into batches that fit in a BatchWriteRow request.
+func splitCopyRows(rows []*tablestore.Row) [][]*tablestore.Row {
+	var batches [][]*tablestore.Row
+	start, size := 0, 0
+	for i, row := range rows {
+		rowSize := EstimateRowSize(primaryKeyToKeyValues(row.PrimaryKey), columnsToKeyValues(row.Columns))
+		if i > start && (i-start == MaxBatchWriteRows || size+rowSize > MaxRequestSize) {
+			batches = append(batches, rows[start:i])
+			start, size = i, 0
+		}
+		size += rowSize
+	}
+	if start < len(rows) {
+		batches = append(batches, rows[start:])
+	}
+	return batches
+}
+
+// copyBatch puts rows into the table of ctx and records the outcome in report, guarded by mu.
+func copyBatch(ctx context.Context, rows []*tablestore.Row, opts CopyOptions, report *CopyReport, mu *sync.Mutex) error {
+	tableName := otsUtilsParamsFromCtx(ctx).TableName
+	changes := make([]tablestore.RowChange, len(rows))
+	for i, row := range rows {
+		change := &tablestore.PutRowChange{TableName: tableName, PrimaryKey: row.PrimaryKey}
+		for _, col := range columnsToKeyValues(row.Columns) {
+			change.AddColumn(col.Key, col.Value)
+		}
+		change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		changes[i] = change
+	}
+
+	started := time.Now()
+	results, err := writeBatchWithRetry(ctx, "CopyTable", changes, nil, 0, opts.Retries, opts.RetryBackoff)
+	mu.Lock()
+	defer mu.Unlock()
+	// The rows left unwritten by a batch failing as a whole are reported by err instead
+	copied := 0
+	var cu tablestore.ConsumedCapacityUnit
+	for i, result := range results {
+		addCapacity(&cu, result.ConsumedCapacity)
+		rowErr := result.Err
+		pks := primaryKeyToKeyValues(rows[i].PrimaryKey)
+		switch {
+		case rowErr == nil:
+			copied++
+			report.RowsCopied++
+			report.Bytes += int64(EstimateRowSize(pks, columnsToKeyValues(rows[i].Columns)))
+		case rowErr != err:
+			report.Failures = append(report.Failures, CopyFailure{PrimaryKey: pks, Err: rowErr})
+		}
+	}
+	jobFromCtx(ctx).chunk(0, copied, cu, started)
+	return err
+}
@@ -0,0 +1,111 @@
+package otsutils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/117503445/otsutils/otsfake"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+// newCopyDestination 返回另一个实例上同结构的 test_table
+func newCopyDestination(t *testing.T) (*OtsUtilsParams, *otsfake.Client) {
+	t.Helper()
+	fake := otsfake.New()
+	fake.MustCreateTable("test_table",
+		"pk1", tablestore.PrimaryKeyType_STRING,
+		"pk2", tablestore.PrimaryKeyType_INTEGER,
+	)
+	return &OtsUtilsParams{Client: fake, TableName: "test_table"}, fake
+}
+
+func TestCopyTable(t *testing.T) {
+	t.Run("copies every row to another instance", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", MaxBatchWriteRows+20)
+		putRangeRows(t, ctx, "u2", 3)
+		dst, dstFake := newCopyDestination(t)
+
+		report, err := CopyTable(ctx, OtsUtilsParamsFromCtx(ctx), dst, CopyOptions{Workers: 2})
+		ast.NoError(err)
+		ast.Equal(int64(MaxBatchWriteRows+23), report.RowsCopied)
+		ast.Positive(report.Bytes)
+		ast.Empty(report.Failures)
+		ast.Equal(2, dstFake.CallCount("BatchWriteRow"))
+
+		var rows []RangeRow
+		ast.NoError(GetRange(dst.WithContext(ctx), &RangeRow{}, &RangeRow{}, &rows))
+		ast.Len(rows, MaxBatchWriteRows+23)
+		ast.Equal("v", *rows[0].Col1)
+	})
+
+	t.Run("range and columns", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 3)
+		putRangeRows(t, ctx, "u2", 3)
+		ast.NoError(UpdateRowMap(ctx, []KeyValue{{Key: "pk1", Value: "u2"}, {Key: "pk2", Value: int64(0)}}, []KeyValue{{Key: "col2", Value: "x"}}))
+		dst, _ := newCopyDestination(t)
+
+		report, err := CopyTable(ctx, OtsUtilsParamsFromCtx(ctx), dst, CopyOptions{
+			Start:   PK().String("pk1", "u2"),
+			Columns: []string{"col2"},
+		})
+		ast.NoError(err)
+		// 只复制含有 col2 的行
+		ast.Equal(int64(1), report.RowsCopied)
+
+		row, err := GetRowToMap(dst.WithContext(ctx), []KeyValue{{Key: "pk1", Value: "u2"}, {Key: "pk2", Value: int64(0)}})
+		ast.NoError(err)
+		ast.Equal(map[string]any{"col2": "x"}, row)
+	})
+
+	t.Run("rejected rows are reported", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 2)
+		fake := otsfake.New()
+		fake.MustCreateTable("test_table",
+			"pk1", tablestore.PrimaryKeyType_STRING,
+			"pk2", tablestore.PrimaryKeyType_STRING,
+		)
+		dst := &OtsUtilsParams{Client: fake, TableName: "test_table"}
+
+		report, err := CopyTable(ctx, OtsUtilsParamsFromCtx(ctx), dst, CopyOptions{})
+		ast.NoError(err)
+		ast.Zero(report.RowsCopied)
+		ast.Len(report.Failures, 2)
+		ast.Equal(CodeParameterInvalid, Code(report.Failures[0].Err))
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 30)
+		dst, dstFake := newCopyDestination(t)
+
+		begin := time.Now()
+		report, err := CopyTable(ctx, OtsUtilsParamsFromCtx(ctx), dst, CopyOptions{RowsPerSecond: 20})
+		ast.NoError(err)
+		ast.Equal(int64(30), report.RowsCopied)
+		// 20 行一批，第二批在一秒后发送
+		ast.Equal(2, dstFake.CallCount("BatchWriteRow"))
+		ast.GreaterOrEqual(time.Since(begin), 900*time.Millisecond)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		src := OtsUtilsParamsFromCtx(ctx)
+
+		_, err := CopyTable(ctx, src, &OtsUtilsParams{Client: src.Client, TableName: "test_table"}, CopyOptions{})
+		ast.EqualError(err, "src and dst are the same table 'test_table'")
+		dst, _ := newCopyDestination(t)
+		_, err = CopyTable(ctx, src, dst, CopyOptions{RowsPerSecond: -1})
+		ast.EqualError(err, "RowsPerSecond must not be negative, got -1")
+		_, err = CopyTable(ctx, src, nil, CopyOptions{})
+		ast.EqualError(err, "src and dst can not be nil")
+	})
+}
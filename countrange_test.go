@@ -0,0 +1,64 @@
+package otsutils
+
+import (
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountRange(t *testing.T) {
+	t.Run("counts a partition across pages", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.MaxRangeRows = 4
+		putRangeRows(t, ctx, "u1", 10)
+		putRangeRows(t, ctx, "u2", 3)
+
+		var columns [][]string
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "GetRange" {
+				columns = append(columns, request.(*tablestore.GetRangeRequest).RangeRowQueryCriteria.ColumnsToGet)
+			}
+			return nil
+		}
+
+		res, err := CountRange(ctx, &RangeRow{Pk1: tea.String("u1")}, &RangeRow{Pk1: tea.String("u1")}, GetRangeParams{ColumnsToGet: []string{"col1"}})
+		ast.NoError(err)
+		ast.Equal(int64(10), res.Count)
+		ast.Equal(int32(10), res.ConsumedCapacity.Read)
+		ast.Equal(3, fake.CallCount("GetRange"))
+		// 只读取第一个主键列
+		ast.Equal([]string{"pk1"}, columns[0])
+
+		res, err = CountRange(ctx, &RangeRow{}, &RangeRow{})
+		ast.NoError(err)
+		ast.Equal(int64(13), res.Count)
+	})
+
+	t.Run("max rows and builders", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 10)
+
+		res, err := CountRange(ctx, PK().String("pk1", "u1"), PK().String("pk1", "u1"), GetRangeParams{MaxRows: 7, PageSize: 3})
+		ast.NoError(err)
+		ast.Equal(int64(7), res.Count)
+		ast.Equal(3, fake.CallCount("GetRange"))
+
+		res, err = CountRange(ctx, PK().String("pk1", "u1").Int64("pk2", 8), &RangeRow{Pk1: tea.String("u1")})
+		ast.NoError(err)
+		ast.Equal(int64(2), res.Count)
+	})
+
+	t.Run("invalid boundaries", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+
+		_, err := CountRange(ctx, &RangeRow{Pk2: tea.Int64(1)}, &RangeRow{})
+		ast.EqualError(err, "start: primary key field Pk2 is set but preceding primary key field Pk1 is nil")
+		_, err = CountRange(ctx, &RangeRow{}, &RangeRow{}, GetRangeParams{MaxRows: -1})
+		ast.EqualError(err, "MaxRows must not be negative, got -1")
+	})
+}
@@ -0,0 +1,183 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// CreateTableFromStruct creates a table whose primary key is described by the pk fields of
+// obj, a pointer to a row struct. Each pk field becomes a primary key column named by its json
+// tag, in pk tag order: *string fields are STRING, *int64 fields INTEGER and *[]byte fields
+// BINARY, and a `pk:"<order>,auto"` field is an AUTO_INCREMENT column. Attribute fields are not
+// part of the schema, except those with an index tag, which become predefined columns so that
+// CreateIndexesFromStruct can index them.
+//
+// The pk orders must run from 1 without gaps, and pk fields of types handled by a registered
+// serializer are rejected, since their column type is only known once a value is converted.
+// The table is the one of OtsUtilsParams unless CreateTableOptions.TableName is set.
+//
+// Example usage:
+//
+//	err := CreateTableFromStruct(ctx, &MyRow{}, CreateTableOptions{TimeToLive: 30 * 86400})
+func CreateTableFromStruct(ctx context.Context, obj any, opts ...CreateTableOptions) error {
+	var o CreateTableOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.MaxVersions < 0 {
+		return fmt.Errorf("MaxVersions must not be negative, got %d", o.MaxVersions)
+	}
+	if o.ReservedRead < 0 || o.ReservedWrite < 0 {
+		return fmt.Errorf("reserved throughput must not be negative, got read %d and write %d", o.ReservedRead, o.ReservedWrite)
+	}
+
+	pks, err := structPrimaryKeySchema(obj)
+	if err != nil {
+		return err
+	}
+	_, columns, err := structIndexes(obj)
+	if err != nil {
+		return err
+	}
+	schema := &TableDescription{PrimaryKeys: pks, DefinedColumns: columns}
+	return executeOTSOperation(ctx, "CreateTable", schema, buildCreateTableRequest, executeCreateTable, nil, o)
+}
+
+// EnsureTable makes sure the table of obj exists, so that a service can start against a fresh
+// instance. It describes the table and creates it with CreateTableFromStruct when it is missing,
+// reporting created as true. When the table already exists, obj is checked against it with
+// ValidateSchema and a mismatch is returned as a *SchemaError. A table created concurrently by
+// another caller, reported as CodeObjectAlreadyExist, is validated the same way. The options
+// only apply when the table is created.
+//
+// Example usage:
+//
+//	if _, err := EnsureTable(ctx, &MyRow{}, CreateTableOptions{TimeToLive: 30 * 86400}); err != nil {
+//	    log.Fatal().Err(err).Msg("table not ready")
+//	}
+func EnsureTable(ctx context.Context, obj any, opts ...CreateTableOptions) (created bool, err error) {
+	if len(opts) > 0 && opts[0].TableName != "" {
+		o := *otsUtilsParamsFromCtx(ctx)
+		o.TableName = opts[0].TableName
+		ctx = o.WithContext(ctx)
+	}
+
+	_, err = DescribeTable(ctx)
+	if err == nil {
+		return false, ValidateSchema(ctx, obj)
+	}
+	if !errors.Is(err, ErrObjectNotExist) {
+		return false, err
+	}
+
+	err = CreateTableFromStruct(ctx, obj, opts...)
+	if Code(err) == CodeObjectAlreadyExist {
+		return false, ValidateSchema(ctx, obj)
+	}
+	if err != nil {
+		return false, err
+	}
+	InvalidateTableMeta(otsUtilsParamsFromCtx(ctx).TableName)
+	return true, nil
+}
+
+// structPrimaryKeySchema returns the primary key columns described by the pk fields of the
+// row struct obj, in pk order.
+func structPrimaryKeySchema(obj any) ([]PrimaryKeySchema, error) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("obj must be a non-nil pointer to struct, got %T", obj)
+	}
+	t := v.Elem().Type()
+
+	meta, err := getStructMeta(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(meta.pkFields) == 0 {
+		return nil, fmt.Errorf("%s has no pk-tagged fields", t)
+	}
+
+	schema := make([]PrimaryKeySchema, len(meta.pkFields))
+	for n, i := range meta.pkFields {
+		fm := &meta.fields[i]
+		ft := fm.typ
+		typ, ok := fm.primaryKeyType()
+		if !ok {
+			return nil, fmt.Errorf("field %s: the primary key column type of %s can not be derived, only string, int64 and []byte fields and pointers to them are supported", fm.name, ft)
+		}
+		schema[n] = PrimaryKeySchema{Name: fm.column, Type: typ, AutoIncrement: fm.pk.auto}
+	}
+	return schema, nil
+}
+
+// primaryKeyFieldType returns the primary key column type of a pk field of type ft, and false
+// for the types handled by a serializer.
+func primaryKeyFieldType(ft reflect.Type) (tablestore.PrimaryKeyType, bool) {
+	if !isNativeFieldType(ft) {
+		return 0, false
+	}
+	switch kind := nativeBaseType(ft).Kind(); {
+	case kind == reflect.String:
+		return tablestore.PrimaryKeyType_STRING, true
+	case isIntegerKind(kind):
+		return tablestore.PrimaryKeyType_INTEGER, true
+	default:
+		return tablestore.PrimaryKeyType_BINARY, true
+	}
+}
+
+func buildCreateTableRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	var o CreateTableOptions
+	if len(params) > 0 {
+		o, _ = params[0].(CreateTableOptions)
+	}
+	tableName := otsParams.TableName
+	if o.TableName != "" {
+		tableName = o.TableName
+	}
+	if err := validateName("table", tableName); err != nil {
+		return nil, err
+	}
+
+	schema := obj.(*TableDescription)
+	meta := &tablestore.TableMeta{TableName: tableName}
+	for _, pk := range schema.PrimaryKeys {
+		if pk.AutoIncrement {
+			meta.AddPrimaryKeyColumnOption(pk.Name, pk.Type, tablestore.AUTO_INCREMENT)
+		} else {
+			meta.AddPrimaryKeyColumn(pk.Name, pk.Type)
+		}
+	}
+	for _, col := range schema.DefinedColumns {
+		meta.AddDefinedColumn(col.Name, col.Type)
+	}
+
+	ttl := o.TimeToLive
+	if ttl == 0 {
+		ttl = -1
+	}
+	maxVersions := o.MaxVersions
+	if maxVersions == 0 {
+		maxVersions = 1
+	}
+	return &tablestore.CreateTableRequest{
+		TableMeta:          meta,
+		TableOption:        tablestore.NewTableOption(ttl, maxVersions),
+		ReservedThroughput: &tablestore.ReservedThroughput{Readcap: o.ReservedRead, Writecap: o.ReservedWrite},
+	}, nil
+}
+
+func executeCreateTable(client OtsClient, req any) (any, error) {
+	tableClient, err := clientAs[TableClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return tableClient.CreateTable(req.(*tablestore.CreateTableRequest))
+}
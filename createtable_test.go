@@ -0,0 +1,152 @@
+package otsutils
+
+import (
+	"testing"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+type OrderRow struct {
+	Seq    *int64  `json:"seq" pk:"3,auto"`
+	UserID *string `json:"user_id" pk:"1"`
+	Day    *[]byte `json:"day" pk:"2"`
+	Amount *int64  `json:"amount"`
+}
+
+func TestCreateTableFromStruct(t *testing.T) {
+	t.Run("derives the schema from the pk tags", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		var req *tablestore.CreateTableRequest
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "CreateTable" {
+				req = request.(*tablestore.CreateTableRequest)
+			}
+			return nil
+		}
+		ast.NoError(CreateTableFromStruct(ctx, &OrderRow{}, CreateTableOptions{TableName: "orders", TimeToLive: 86400, MaxVersions: 2, ReservedRead: 1}))
+		ast.Equal(86400, req.TableOption.TimeToAlive)
+		ast.Equal(2, req.TableOption.MaxVersion)
+		ast.Equal(&tablestore.ReservedThroughput{Readcap: 1}, req.ReservedThroughput)
+
+		o := OtsUtilsParams{Client: fake, TableName: "orders"}
+		desc, err := TableMeta(o.WithContext(ctx))
+		ast.NoError(err)
+		ast.Equal([]PrimaryKeySchema{
+			{Name: "user_id", Type: tablestore.PrimaryKeyType_STRING},
+			{Name: "day", Type: tablestore.PrimaryKeyType_BINARY},
+			{Name: "seq", Type: tablestore.PrimaryKeyType_INTEGER, AutoIncrement: true},
+		}, desc.PrimaryKeys)
+	})
+
+	t.Run("defaults to the table of the context", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		// test_table 已存在
+		err := CreateTableFromStruct(ctx, &RangeRow{})
+		ast.Equal(CodeObjectAlreadyExist, Code(err))
+		// newFakeContext 创建 test_table 时也计一次
+		ast.Equal(2, fake.CallCount("CreateTable"))
+	})
+
+	t.Run("invalid structs", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		type noPk struct {
+			Col1 *string `json:"col1"`
+		}
+		type gap struct {
+			Pk1 *string `json:"pk1" pk:"1"`
+			Pk3 *int64  `json:"pk3" pk:"3"`
+		}
+		type tooMany struct {
+			Pk1 *string `json:"pk1" pk:"1"`
+			Pk2 *string `json:"pk2" pk:"2"`
+			Pk3 *string `json:"pk3" pk:"3"`
+			Pk4 *string `json:"pk4" pk:"4"`
+			Pk5 *string `json:"pk5" pk:"5"`
+		}
+		ast.EqualError(CreateTableFromStruct(ctx, &noPk{}), "otsutils.noPk has no pk-tagged fields")
+		ast.EqualError(CreateTableFromStruct(ctx, &gap{}), `field Pk3: invalid pk tag "3": no field has pk order 2, pk orders must run from 1 without gaps`)
+		ast.ErrorContains(CreateTableFromStruct(ctx, &tooMany{}), "type otsutils.tooMany: 5 pk-tagged fields Pk1, Pk2, Pk3, Pk4, Pk5 exceed the maximum of 4")
+		ast.EqualError(CreateTableFromStruct(ctx, RangeRow{}), "obj must be a non-nil pointer to struct, got otsutils.RangeRow")
+		ast.EqualError(CreateTableFromStruct(ctx, &RangeRow{}, CreateTableOptions{MaxVersions: -1}), "MaxVersions must not be negative, got -1")
+		ast.Equal(1, fake.CallCount("CreateTable"))
+	})
+}
+
+func TestEnsureTable(t *testing.T) {
+	t.Run("creates a missing table once", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		opts := CreateTableOptions{TableName: "orders", MaxVersions: 3}
+
+		created, err := EnsureTable(ctx, &OrderRow{}, opts)
+		ast.NoError(err)
+		ast.True(created)
+
+		// 再次调用只校验结构
+		created, err = EnsureTable(ctx, &OrderRow{}, opts)
+		ast.NoError(err)
+		ast.False(created)
+		ast.Equal(2, fake.CallCount("CreateTable"))
+
+		o := OtsUtilsParams{Client: fake, TableName: "orders"}
+		desc, err := DescribeTable(o.WithContext(ctx))
+		ast.NoError(err)
+		ast.Equal(3, desc.MaxVersions)
+	})
+
+	t.Run("validates an existing table", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		created, err := EnsureTable(ctx, &RangeRow{})
+		ast.NoError(err)
+		ast.False(created)
+
+		_, err = EnsureTable(ctx, &OrderRow{})
+		var schemaErr *SchemaError
+		ast.ErrorAs(err, &schemaErr)
+		ast.Equal("test_table", schemaErr.TableName)
+		ast.Equal(1, fake.CallCount("CreateTable"))
+	})
+
+	t.Run("table created concurrently", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		// 另一个实例在 DescribeTable 与 CreateTable 之间建好了表
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "CreateTable" && request.(*tablestore.CreateTableRequest).TableMeta.TableName == "orders" {
+				fake.Intercept = nil
+				_, err := fake.CreateTable(request.(*tablestore.CreateTableRequest))
+				ast.NoError(err)
+			}
+			return nil
+		}
+		created, err := EnsureTable(ctx, &OrderRow{}, CreateTableOptions{TableName: "orders"})
+		ast.NoError(err)
+		ast.False(created)
+	})
+
+	t.Run("other errors are returned", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		busy := &tablestore.OtsError{Code: CodeServerBusy, Message: "Server is busy."}
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "DescribeTable" {
+				return busy
+			}
+			return nil
+		}
+		_, err := EnsureTable(ctx, &RangeRow{})
+		ast.ErrorIs(err, busy)
+		ast.Equal(1, fake.CallCount("CreateTable"))
+	})
+}
@@ -0,0 +1,156 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultDeadlineFloor is the deadline floor used when OtsUtilsParams.DeadlineFloor is not set.
+const DefaultDeadlineFloor = 100 * time.Millisecond
+
+var (
+	// ErrCallerDeadline is returned when an operation ran out of time because the caller's context
+	// had less time left than OtsUtilsParams.DeadlineFloor when the operation started.
+	ErrCallerDeadline = errors.New("otsutils: caller deadline too short")
+
+	// ErrOperationTimeout is returned when a call timed out after OtsUtilsParams.OperationTimeout
+	// elapsed, or when the caller's deadline expired although the operation started with enough
+	// time left.
+	ErrOperationTimeout = errors.New("otsutils: operation timed out")
+)
+
+// operationDeadline is the time budget of one operation, recorded when it starts.
+type operationDeadline struct {
+	// remaining is the time left on the caller's context, valid when hasDeadline is set.
+	remaining   time.Duration
+	hasDeadline bool
+	timeout     time.Duration
+	floor       time.Duration
+}
+
+func (otsUtilsParams *OtsUtilsParams) operationDeadline(ctx context.Context) operationDeadline {
+	d := operationDeadline{timeout: otsUtilsParams.OperationTimeout, floor: otsUtilsParams.DeadlineFloor}
+	if d.floor <= 0 {
+		d.floor = DefaultDeadlineFloor
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		d.remaining, d.hasDeadline = time.Until(deadline), true
+	}
+	return d
+}
+
+// run executes the call within the deadline of ctx and the operation timeout. The SDK calls do
+// not take a context, so a call is never abandoned: it runs until the SDK returns, bounded by the
+// HTTP request timeout of the client (see WithRequestTimeout), and a write that succeeds after
+// the budget ran out is reported as the success it is. An operation whose budget is spent before
+// the call fails without sending it, and a call timing out once the budget ran out fails with a
+// *DeadlineError wrapping the error of the call. Other errors, such as a failed condition the
+// service answered late, are returned unchanged.
+func (d operationDeadline) run(ctx context.Context, operation string, call func() (any, error)) (any, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, d.classify(operation, err, 0, err)
+	}
+	if d.hasDeadline && d.remaining <= 0 {
+		return nil, d.classify(operation, context.DeadlineExceeded, 0, context.DeadlineExceeded)
+	}
+
+	start := time.Now()
+	resp, err := call()
+	if err == nil {
+		return resp, nil
+	}
+	err, elapsed := wrapOTSError(err), time.Since(start)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, d.classify(operation, ctxErr, elapsed, err)
+	}
+	if d.timeout > 0 && elapsed >= d.timeout && isTimeoutError(err) {
+		return nil, &DeadlineError{Operation: operation, Cause: ErrOperationTimeout, Remaining: d.remaining, HasDeadline: d.hasDeadline, Timeout: d.timeout, Elapsed: elapsed, Err: err}
+	}
+	return nil, err
+}
+
+// isTimeoutError reports whether the call failed by timing out, in the HTTP client or in the
+// service, rather than with an answer of the service.
+func isTimeoutError(err error) bool {
+	if Code(err) == CodeTimeout || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// classify returns the *DeadlineError of an operation whose caller context expired, with
+// ErrCallerDeadline or ErrOperationTimeout as its cause depending on the time left when the
+// operation started. err is the error of the call, or ctxErr when nothing was sent.
+// Cancellation returns err unchanged.
+func (d operationDeadline) classify(operation string, ctxErr error, elapsed time.Duration, err error) error {
+	if !errors.Is(ctxErr, context.DeadlineExceeded) {
+		return err
+	}
+	cause := ErrOperationTimeout
+	if d.remaining < d.floor {
+		cause = ErrCallerDeadline
+	}
+	return &DeadlineError{Operation: operation, Cause: cause, Remaining: d.remaining, HasDeadline: d.hasDeadline, Floor: d.floor, Elapsed: elapsed, Err: err}
+}
+
+// DeadlineError is the error of an operation that ran out of time. errors.Is matches it against
+// its Cause, ErrCallerDeadline or ErrOperationTimeout, against context.DeadlineExceeded, and
+// against the error of the call it wraps, so errors.As with *OTSError still works when the
+// service answered with an error.
+//
+// Example usage:
+//
+//	var deadlineErr *DeadlineError
+//	if errors.As(err, &deadlineErr) && deadlineErr.Cause == ErrCallerDeadline {
+//	    log.Printf("%s started with only %s left", deadlineErr.Operation, deadlineErr.Remaining)
+//	}
+type DeadlineError struct {
+	// Operation is the name of the operation, e.g. "GetRow".
+	Operation string
+
+	// Cause is ErrCallerDeadline or ErrOperationTimeout.
+	Cause error
+
+	// Remaining is the time left on the caller's context when the operation started, valid
+	// when HasDeadline is set.
+	Remaining   time.Duration
+	HasDeadline bool
+
+	// Floor is the DeadlineFloor the remaining time was compared to, set when the caller's
+	// deadline expired.
+	Floor time.Duration
+
+	// Timeout is the OperationTimeout that fired, set when it did.
+	Timeout time.Duration
+
+	// Elapsed is the time the call took, zero when it was not sent.
+	Elapsed time.Duration
+
+	// Err is the error of the call, or the error of the context when it was not sent.
+	Err error
+}
+
+func (e *DeadlineError) Error() string {
+	var reason string
+	switch {
+	case e.Timeout > 0:
+		reason = fmt.Sprintf("after %s", e.Timeout)
+	case e.Cause == ErrCallerDeadline:
+		reason = fmt.Sprintf("%s left at start, below the floor of %s", e.Remaining, e.Floor)
+	default:
+		reason = fmt.Sprintf("caller deadline expired with %s left at start", e.Remaining)
+	}
+	if e.Err == context.DeadlineExceeded {
+		return fmt.Sprintf("%s: %s: %s", e.Cause, reason, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %s: %s", e.Cause, reason, context.DeadlineExceeded, e.Err)
+}
+
+func (e *DeadlineError) Unwrap() []error {
+	return []error{e.Cause, context.DeadlineExceeded, e.Err}
+}
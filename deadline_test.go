@@ -0,0 +1,190 @@
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+// httpTimeoutError 是 HTTP 客户端超时返回的 net.Error
+type httpTimeoutError struct{}
+
+func (httpTimeoutError) Error() string {
+	return "context deadline exceeded (Client.Timeout exceeded while awaiting headers)"
+}
+func (httpTimeoutError) Timeout() bool   { return true }
+func (httpTimeoutError) Temporary() bool { return true }
+
+type recordingMetricsHook struct {
+	records []OperationMetrics
+}
+
+func (h *recordingMetricsHook) RecordOperation(ctx context.Context, m OperationMetrics) {
+	h.records = append(h.records, m)
+}
+
+func TestOperationDeadline(t *testing.T) {
+	type row struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk2 *int64  `json:"pk2" pk:"2"`
+	}
+	obj := func() *row { return &row{Pk1: tea.String("a"), Pk2: tea.Int64(1)} }
+
+	// errHTTPTimeout 模拟 SDK 的 HTTP 请求超时
+	var errHTTPTimeout error = &url.Error{Op: "Post", URL: "https://ots/GetRow", Err: httpTimeoutError{}}
+
+	// newSlowContext 返回的 context 中，GetRow 耗时 delay 后返回 callErr
+	newSlowContext := func(t *testing.T, o OtsUtilsParams, delay time.Duration, callErr error) context.Context {
+		ctx, fake := newFakeContext(t)
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "GetRow" || operation == "PutRow" {
+				time.Sleep(delay)
+				return callErr
+			}
+			return nil
+		}
+		o.Client, o.TableName = fake, "test_table"
+		return o.WithContext(ctx)
+	}
+
+	t.Run("operation timeout", func(t *testing.T) {
+		ctx := newSlowContext(t, OtsUtilsParams{OperationTimeout: 10 * time.Millisecond}, 20*time.Millisecond, errHTTPTimeout)
+		err := GetRow(ctx, obj())
+		assert.ErrorIs(t, err, ErrOperationTimeout)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, err, errHTTPTimeout)
+		assert.False(t, errors.Is(err, ErrCallerDeadline))
+
+		var deadlineErr *DeadlineError
+		assert.True(t, errors.As(err, &deadlineErr))
+		assert.Equal(t, "GetRow", deadlineErr.Operation)
+		assert.Equal(t, 10*time.Millisecond, deadlineErr.Timeout)
+		assert.GreaterOrEqual(t, deadlineErr.Elapsed, 10*time.Millisecond)
+	})
+
+	t.Run("late service error is not a timeout", func(t *testing.T) {
+		busy := &tablestore.OtsError{Code: CodeServerBusy, Message: "Server is busy."}
+		ctx := newSlowContext(t, OtsUtilsParams{OperationTimeout: 10 * time.Millisecond}, 20*time.Millisecond, busy)
+		err := GetRow(ctx, obj())
+		assert.False(t, errors.Is(err, ErrOperationTimeout))
+		assert.False(t, errors.Is(err, context.DeadlineExceeded))
+		var deadlineErr *DeadlineError
+		assert.False(t, errors.As(err, &deadlineErr))
+		var otsErr *OTSError
+		assert.True(t, errors.As(err, &otsErr))
+		assert.Equal(t, CodeServerBusy, otsErr.Code)
+	})
+
+	t.Run("late service timeout keeps its type", func(t *testing.T) {
+		storageTimeout := &tablestore.OtsError{Code: CodeTimeout, Message: "Operation timeout."}
+		ctx := newSlowContext(t, OtsUtilsParams{OperationTimeout: 10 * time.Millisecond}, 20*time.Millisecond, storageTimeout)
+		err := GetRow(ctx, obj())
+		assert.ErrorIs(t, err, ErrOperationTimeout)
+		var otsErr *OTSError
+		assert.True(t, errors.As(err, &otsErr))
+		assert.Equal(t, CodeTimeout, otsErr.Code)
+	})
+
+	t.Run("fast failure is not a timeout", func(t *testing.T) {
+		ctx := newSlowContext(t, OtsUtilsParams{OperationTimeout: time.Minute}, 0, errHTTPTimeout)
+		err := GetRow(ctx, obj())
+		assert.ErrorIs(t, err, errHTTPTimeout)
+		assert.False(t, errors.Is(err, ErrCallerDeadline) || errors.Is(err, ErrOperationTimeout))
+	})
+
+	t.Run("caller deadline below floor", func(t *testing.T) {
+		ctx := newSlowContext(t, OtsUtilsParams{DeadlineFloor: time.Second}, 30*time.Millisecond, errHTTPTimeout)
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		err := GetRow(ctx, obj())
+		assert.ErrorIs(t, err, ErrCallerDeadline)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.False(t, errors.Is(err, ErrOperationTimeout))
+	})
+
+	t.Run("caller deadline above floor", func(t *testing.T) {
+		// 开始时预算充足，超时说明 OTS 慢
+		ctx := newSlowContext(t, OtsUtilsParams{DeadlineFloor: time.Millisecond}, 30*time.Millisecond, errHTTPTimeout)
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		err := GetRow(ctx, obj())
+		assert.ErrorIs(t, err, ErrOperationTimeout)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("caller deadline before operation timeout", func(t *testing.T) {
+		ctx := newSlowContext(t, OtsUtilsParams{OperationTimeout: time.Minute}, 30*time.Millisecond, errHTTPTimeout)
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, GetRow(ctx, obj()), ErrCallerDeadline)
+	})
+
+	t.Run("expired context", func(t *testing.T) {
+		ctx, fake := newFakeContext(t)
+		ctx, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+		defer cancel()
+		err := GetRow(ctx, obj())
+		assert.ErrorIs(t, err, ErrCallerDeadline)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 0, fake.CallCount("GetRow"))
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, fake := newFakeContext(t)
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		err := GetRow(ctx, obj())
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, errors.Is(err, ErrCallerDeadline) || errors.Is(err, ErrOperationTimeout))
+		assert.Equal(t, 0, fake.CallCount("GetRow"))
+	})
+
+	t.Run("late write succeeds and is audited", func(t *testing.T) {
+		// 超出预算才返回的写入已经生效，不能报告为失败
+		hook := &recordingAuditHook{}
+		ctx := newSlowContext(t, OtsUtilsParams{OperationTimeout: 10 * time.Millisecond, AuditHook: hook, AuditCheap: true}, 20*time.Millisecond, nil)
+		assert.NoError(t, PutRow(ctx, obj()))
+		assert.Len(t, hook.records, 1)
+		exists, err := ExistsRow(ctx, obj())
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	})
+
+	t.Run("metrics hook", func(t *testing.T) {
+		hook := &recordingMetricsHook{}
+		ctx := newSlowContext(t, OtsUtilsParams{DeadlineFloor: time.Second, MetricsHook: hook}, 30*time.Millisecond, errHTTPTimeout)
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		assert.Error(t, GetRow(ctx, obj()))
+
+		assert.Len(t, hook.records, 1)
+		m := hook.records[0]
+		assert.Equal(t, "GetRow", m.Operation)
+		assert.Equal(t, "test_table", m.TableName)
+		assert.Equal(t, "primary", m.Client)
+		assert.True(t, m.HasDeadline)
+		assert.LessOrEqual(t, m.DeadlineRemaining, 20*time.Millisecond)
+		assert.GreaterOrEqual(t, m.Duration, 30*time.Millisecond)
+		assert.ErrorIs(t, m.Err, ErrCallerDeadline)
+	})
+
+	t.Run("within budget", func(t *testing.T) {
+		ctx, _ := newFakeContext(t)
+		hook := &recordingMetricsHook{}
+		o := *otsUtilsParamsFromCtx(ctx)
+		o.OperationTimeout, o.MetricsHook = time.Second, hook
+		ctx, cancel := context.WithTimeout(o.WithContext(ctx), time.Second)
+		defer cancel()
+		assert.NoError(t, PutRow(ctx, obj()))
+		assert.NoError(t, GetRow(ctx, obj()))
+		assert.Len(t, hook.records, 2)
+		assert.NoError(t, hook.records[0].Err)
+		assert.Equal(t, int32(1), hook.records[0].ConsumedCapacity.Write)
+	})
+}
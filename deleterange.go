@@ -0,0 +1,171 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// DeleteRange deletes every row whose leading primary key columns equal those set on prefixObj,
+// and returns the number of rows deleted. prefixObj is as in QueryByPkPrefix. The range is read
+// MaxBatchWriteRows primary keys at a time, and each page is deleted with one BatchWriteRow.
+//
+// Rows the service throttles are left in place and the range is scanned again from its start,
+// after RetryBackoff, until a pass finds nothing left to delete. The call fails once Retries
+// consecutive passes delete no row, or as soon as a row fails with a non-transient error.
+// The count returned with an error is the number of rows deleted before it.
+//
+// Example usage:
+//
+//	// Every order of user u1
+//	n, err := DeleteRange(ctx, &Order{UserID: tea.String("u1")})
+//
+//	// How many rows the same call would delete
+//	n, err := DeleteRange(ctx, &Order{UserID: tea.String("u1")}, DeleteRangeParams{DryRun: true})
+func DeleteRange(ctx context.Context, prefixObj any, params ...DeleteRangeParams) (int, error) {
+	var p DeleteRangeParams
+	if len(params) > 0 {
+		p = params[0]
+	}
+	if p.MaxRows < 0 {
+		return 0, fmt.Errorf("MaxRows can not be negative, got %d", p.MaxRows)
+	}
+	if p.Retries <= 0 {
+		p.Retries = DefaultBatchWriteRetries
+	}
+	if p.RetryBackoff <= 0 {
+		p.RetryBackoff = DefaultBatchWriteBackoff
+	}
+
+	elemType := rowMapType
+	if _, ok := prefixObj.(*PrimaryKeyBuilder); !ok {
+		elemType = reflect.TypeOf(prefixObj)
+	}
+	if err := checkPkPrefix(ctx, prefixObj, elemType); err != nil {
+		return 0, fmt.Errorf("prefix: %w", err)
+	}
+	_, first, err := newRangeScan(ctx, prefixObj, prefixObj, elemType, GetRangeParams{})
+	if err != nil {
+		return 0, err
+	}
+	// Reading only the first pk column still returns the whole primary key, and every row has it.
+	scanParams := GetRangeParams{ColumnsToGet: []string{first.StartPrimaryKey.PrimaryKeys[0].ColumnName}}
+
+	ctx, job := startJob(ctx, "DeleteRange", p.Listener)
+	deleted, err := deleteRangePasses(ctx, job, first, scanParams, p)
+	job.finish(err)
+	return deleted, err
+}
+
+// deleteRangePasses deletes the rows of the range of first, scanning it again until nothing is
+// left, and reports each page to job.
+func deleteRangePasses(ctx context.Context, job *jobRun, first *rangePage, scanParams GetRangeParams, p DeleteRangeParams) (int, error) {
+	deleted, stalls := 0, 0
+	for {
+		left, passDeleted := 0, 0
+		var lastErr error
+		page := first
+		for page != nil {
+			limit := int32(0)
+			if !p.DryRun {
+				limit = MaxBatchWriteRows
+			}
+			if p.MaxRows > 0 {
+				remaining := int32(min(p.MaxRows-deleted, MaxBatchWriteRows))
+				if limit == 0 || remaining < limit {
+					limit = remaining
+				}
+			}
+			page = &rangePage{StartPrimaryKey: page.StartPrimaryKey, EndPrimaryKey: page.EndPrimaryKey, Limit: limit}
+
+			started, pageDeleted := time.Now(), 0
+			var cu tablestore.ConsumedCapacityUnit
+			var pks []*tablestore.PrimaryKey
+			next := (*rangePage)(nil)
+			handleScan := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+				r := resp.(*tablestore.GetRangeResponse)
+				addCapacity(&cu, r.ConsumedCapacityUnit)
+				for _, row := range r.Rows {
+					pks = append(pks, row.PrimaryKey)
+				}
+				if r.NextStartPrimaryKey != nil {
+					next = &rangePage{StartPrimaryKey: r.NextStartPrimaryKey, EndPrimaryKey: first.EndPrimaryKey}
+				}
+				return nil
+			}
+			if err := executeOTSOperation(ctx, "DeleteRange", page, buildGetRangeRequest, executeGetRange, handleScan, scanParams); err != nil {
+				return deleted, err
+			}
+			page = next
+
+			if p.DryRun {
+				pageDeleted = len(pks)
+				deleted += len(pks)
+			} else if len(pks) > 0 {
+				tableName := otsUtilsParamsFromCtx(ctx).TableName
+				changes := make([]tablestore.RowChange, len(pks))
+				for i, pk := range pks {
+					change := &tablestore.DeleteRowChange{TableName: tableName, PrimaryKey: pk}
+					change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+					changes[i] = change
+				}
+
+				results := make([]BatchOpResult, len(changes))
+				handleDelete := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+					return collectBatchWriteResults(ctx, resp.(*tablestore.BatchWriteRowResponse), changes, 0, results)
+				}
+				err := executeOTSOperation(ctx, "DeleteRange", changes, buildBatchWriteRowRequest, executeBatchWriteRow, handleDelete)
+				if err != nil {
+					if !isRetriable(err) {
+						return deleted, err
+					}
+					left += len(changes)
+					lastErr = err
+				} else {
+					for _, result := range results {
+						addCapacity(&cu, result.ConsumedCapacity)
+						switch {
+						case result.Err == nil:
+							pageDeleted++
+							passDeleted++
+							deleted++
+						case isRetriable(result.Err):
+							left++
+							lastErr = result.Err
+						default:
+							return deleted, result.Err
+						}
+					}
+				}
+			}
+			job.chunk(0, pageDeleted, cu, started)
+
+			if p.MaxRows > 0 && deleted >= p.MaxRows {
+				return deleted, nil
+			}
+		}
+
+		if left == 0 {
+			return deleted, nil
+		}
+		if passDeleted > 0 {
+			stalls = 0
+		} else if stalls++; stalls >= p.Retries {
+			return deleted, fmt.Errorf("%d rows left after %d passes deleting no row: %w", left, stalls, lastErr)
+		}
+
+		job.retry(JobRetry{Attempt: stalls, Rows: left, Backoff: p.RetryBackoff << stalls, Err: lastErr})
+		timer := time.NewTimer(p.RetryBackoff << stalls)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return deleted, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package otsutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+// putRangeRows 在分区 pk1 下写入 n 行
+func putRangeRows(t *testing.T, ctx context.Context, pk1 string, n int) {
+	for i := 0; i < n; i++ {
+		assert.NoError(t, PutRow(ctx, &RangeRow{Pk1: tea.String(pk1), Pk2: tea.Int64(int64(i)), Col1: tea.String("v")}))
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	t.Run("deletes the prefix in batches", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", MaxBatchWriteRows+20)
+		putRangeRows(t, ctx, "u2", 3)
+
+		n, err := DeleteRange(ctx, &RangeRow{Pk1: tea.String("u1")})
+		ast.NoError(err)
+		ast.Equal(MaxBatchWriteRows+20, n)
+		ast.Equal(2, fake.CallCount("BatchWriteRow"))
+
+		var rows []RangeRow
+		ast.NoError(GetRange(ctx, &RangeRow{}, &RangeRow{}, &rows))
+		ast.Len(rows, 3)
+		ast.Equal("u2", tea.StringValue(rows[0].Pk1))
+	})
+
+	t.Run("dry run and max rows", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 10)
+
+		// DryRun 只计数
+		n, err := DeleteRange(ctx, PK().String("pk1", "u1"), DeleteRangeParams{DryRun: true})
+		ast.NoError(err)
+		ast.Equal(10, n)
+		ast.Equal(0, fake.CallCount("BatchWriteRow"))
+
+		n, err = DeleteRange(ctx, &RangeRow{Pk1: tea.String("u1")}, DeleteRangeParams{MaxRows: 4})
+		ast.NoError(err)
+		ast.Equal(4, n)
+
+		// 删除从范围起点开始
+		var rows []RangeRow
+		ast.NoError(QueryByPkPrefix(ctx, &RangeRow{Pk1: tea.String("u1")}, &rows))
+		ast.Len(rows, 6)
+		ast.Equal(int64(4), tea.Int64Value(rows[0].Pk2))
+	})
+
+	t.Run("throttled batches are retried", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 2*MaxBatchWriteRows+10)
+
+		// 前两个批次被限流，对应的行在下一轮扫描中重新删除
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "BatchWriteRow" && fake.CallCount("BatchWriteRow") <= 2 {
+				return &tablestore.OtsError{Code: CodeNotEnoughCapacityUnit, Message: "Remaining capacity unit is not enough."}
+			}
+			return nil
+		}
+
+		n, err := DeleteRange(ctx, &RangeRow{Pk1: tea.String("u1")}, DeleteRangeParams{RetryBackoff: time.Millisecond})
+		ast.NoError(err)
+		ast.Equal(2*MaxBatchWriteRows+10, n)
+
+		count, err := DeleteRange(ctx, &RangeRow{Pk1: tea.String("u1")}, DeleteRangeParams{DryRun: true})
+		ast.NoError(err)
+		ast.Zero(count)
+	})
+
+	t.Run("gives up after passes deleting nothing", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 3)
+
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "BatchWriteRow" {
+				return &tablestore.OtsError{Code: CodeServerBusy, Message: "Server is busy."}
+			}
+			return nil
+		}
+		n, err := DeleteRange(ctx, &RangeRow{Pk1: tea.String("u1")}, DeleteRangeParams{Retries: 2, RetryBackoff: time.Millisecond})
+		ast.Zero(n)
+		ast.Equal(CodeServerBusy, Code(err))
+		ast.Equal(2, fake.CallCount("BatchWriteRow"))
+
+		// 非暂时性错误立即返回
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "BatchWriteRow" {
+				return &tablestore.OtsError{Code: CodeAuthFailed, Message: "denied"}
+			}
+			return nil
+		}
+		_, err = DeleteRange(ctx, &RangeRow{Pk1: tea.String("u1")}, DeleteRangeParams{Retries: 2, RetryBackoff: time.Millisecond})
+		ast.Equal(CodeAuthFailed, Code(err))
+		ast.Equal(3, fake.CallCount("BatchWriteRow"))
+	})
+
+	t.Run("full primary key is rejected", func(t *testing.T) {
+		ctx, _ := newFakeContext(t)
+		_, err := DeleteRange(ctx, &RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(1)})
+		assert.EqualError(t, err, "prefix: every primary key column is set, use GetRow to read a single row")
+	})
+}
@@ -0,0 +1,175 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// Error codes returned by the OTS service that this package treats specially.
+const (
+	CodeConditionCheckFail    = "OTSConditionCheckFail"
+	CodeObjectNotExist        = "OTSObjectNotExist"
+	CodeObjectAlreadyExist    = "OTSObjectAlreadyExist"
+	CodeParameterInvalid      = "OTSParameterInvalid"
+	CodeAuthFailed            = "OTSAuthFailed"
+	CodeRowOperationConflict  = tablestore.ROW_OPERATION_CONFLICT
+	CodeNotEnoughCapacityUnit = tablestore.NOT_ENOUGH_CAPACITY_UNIT
+	CodeTableNotReady         = tablestore.TABLE_NOT_READY
+	CodePartitionUnavailable  = tablestore.PARTITION_UNAVAILABLE
+	CodeServerBusy            = tablestore.SERVER_BUSY
+	CodeStorageServerBusy     = tablestore.STORAGE_SERVER_BUSY
+	CodeQuotaExhausted        = tablestore.QUOTA_EXHAUSTED
+	CodeTimeout               = tablestore.STORAGE_TIMEOUT
+	CodeServerUnavailable     = tablestore.SERVER_UNAVAILABLE
+	CodeInternalServerError   = tablestore.INTERNAL_SERVER_ERROR
+)
+
+// Sentinel errors matched with errors.Is by the errors operations return for the
+// corresponding codes, e.g. errors.Is(err, ErrConditionCheckFail).
+var (
+	ErrConditionCheckFail   = errors.New("condition check failed")
+	ErrRowOperationConflict = errors.New("row operation conflict")
+	ErrObjectNotExist       = errors.New("object does not exist")
+)
+
+// ErrUnsupportedOperation is returned by operations whose client does not implement the
+// optional interface they need, such as RangeClient for GetRange.
+var ErrUnsupportedOperation = errors.New("operation not supported by the client")
+
+// codeSentinels maps the error codes that have a sentinel error to it.
+var codeSentinels = map[string]error{
+	CodeConditionCheckFail:   ErrConditionCheckFail,
+	CodeRowOperationConflict: ErrRowOperationConflict,
+	CodeObjectNotExist:       ErrObjectNotExist,
+}
+
+// OTSError is an error returned by the OTS service. Operations return it in place of the
+// *tablestore.OtsError of the SDK, which Unwrap returns, so errors.As with either type works.
+// errors.Is matches it against the sentinel error of its code, if any.
+type OTSError struct {
+	// Code is the OTS error code, e.g. CodeConditionCheckFail.
+	Code string
+
+	// Message is the error message of the service.
+	Message string
+
+	// HTTPStatus is the HTTP status code of the response, 0 when the error was not received
+	// over HTTP.
+	HTTPStatus int
+
+	// RequestID identifies the request for the service support.
+	RequestID string
+
+	// Err is the error returned by the SDK.
+	Err *tablestore.OtsError
+}
+
+// wrapOTSError converts an error returned by the SDK to an *OTSError. Other errors are
+// returned unchanged.
+func wrapOTSError(err error) error {
+	otsErr, ok := err.(*tablestore.OtsError)
+	if !ok {
+		return err
+	}
+	return &OTSError{
+		Code:       otsErr.Code,
+		Message:    otsErr.Message,
+		HTTPStatus: otsErr.HttpStatusCode,
+		RequestID:  otsErr.RequestId,
+		Err:        otsErr,
+	}
+}
+
+// Error returns the message of the SDK error, unchanged.
+func (e *OTSError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *OTSError) Unwrap() error {
+	return e.Err
+}
+
+func (e *OTSError) Is(target error) bool {
+	sentinel, ok := codeSentinels[e.Code]
+	return ok && sentinel == target
+}
+
+// Code returns the OTS error code carried by err or any error it wraps,
+// e.g. CodeConditionCheckFail. It returns "" when err does not come from the service.
+//
+// Example usage:
+//
+//	if Code(err) == CodeConditionCheckFail {
+//	    // the row already exists
+//	}
+func Code(err error) string {
+	var otsErr *tablestore.OtsError
+	if errors.As(err, &otsErr) {
+		return otsErr.Code
+	}
+	return ""
+}
+
+// IsConditionCheckFail reports whether err is the failure of a row existence expectation or
+// column condition, such as a conditional UpdateRow finding a newer version of the row.
+//
+// Example usage:
+//
+//	err := UpdateRow(ctx, &row, UpdateRowParams{ColumnCondition: versionIs3})
+//	if IsConditionCheckFail(err) {
+//	    // reload the row and try again
+//	}
+func IsConditionCheckFail(err error) bool {
+	return Code(err) == CodeConditionCheckFail
+}
+
+// isRetriable reports whether err is a transient service error, such as throttling, after
+// which the same request may succeed.
+func isRetriable(err error) bool {
+	switch Code(err) {
+	case CodeRowOperationConflict, CodeNotEnoughCapacityUnit, CodeTableNotReady, CodePartitionUnavailable,
+		CodeServerBusy, CodeStorageServerBusy, CodeQuotaExhausted, CodeTimeout, CodeServerUnavailable,
+		CodeInternalServerError:
+		return true
+	}
+	return false
+}
+
+// RowError is the failure of a single row in a batch operation.
+// It wraps the service error, so Code and errors.As with *tablestore.OtsError work on it, and
+// errors.Is matches it against the sentinel error of its code, as for *OTSError.
+type RowError struct {
+	// TableName is the table the row belongs to.
+	TableName string
+
+	// Index is the position of the row in the batch request.
+	Index int
+
+	// Err is the service error reported for the row.
+	Err *tablestore.OtsError
+}
+
+// newRowError converts the per-row error of a batch response.
+func newRowError(tableName string, index int, e tablestore.Error) *RowError {
+	return &RowError{
+		TableName: tableName,
+		Index:     index,
+		Err:       &tablestore.OtsError{Code: e.Code, Message: e.Message},
+	}
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d of table %s: %s %s", e.Index, e.TableName, e.Err.Code, e.Err.Message)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RowError) Is(target error) bool {
+	sentinel, ok := codeSentinels[e.Err.Code]
+	return ok && sentinel == target
+}
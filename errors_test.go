@@ -0,0 +1,142 @@
+package otsutils
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCode(t *testing.T) {
+	ast := assert.New(t)
+
+	otsErr := &tablestore.OtsError{Code: CodeConditionCheckFail, Message: "Condition check failed.", HttpStatusCode: 403}
+
+	ast.Equal(CodeConditionCheckFail, Code(otsErr))
+	ast.Equal("", Code(nil))
+	ast.Equal("", Code(errors.New("OTSConditionCheckFail in text only")))
+
+	// 多层 %w 包装
+	wrapped := fmt.Errorf("outer: %w", fmt.Errorf("middle: %w", fmt.Errorf("inner: %w", otsErr)))
+	ast.Equal(CodeConditionCheckFail, Code(wrapped))
+
+	// errors.Join
+	ast.Equal(CodeConditionCheckFail, Code(errors.Join(errors.New("other"), wrapped)))
+
+	// %v 不保留错误链
+	ast.Equal("", Code(fmt.Errorf("flattened: %v", otsErr)))
+}
+
+func TestCodeFromRowError(t *testing.T) {
+	ast := assert.New(t)
+
+	rowErr := newRowError("test_table", 3, tablestore.Error{Code: CodeServerBusy, Message: "Server is busy."})
+	ast.Equal(CodeServerBusy, Code(rowErr))
+	ast.Equal("row 3 of table test_table: OTSServerBusy Server is busy.", rowErr.Error())
+
+	wrapped := fmt.Errorf("batch write: %w", fmt.Errorf("chunk 2: %w", rowErr))
+	ast.Equal(CodeServerBusy, Code(wrapped))
+
+	var target *RowError
+	ast.True(errors.As(wrapped, &target))
+	ast.Equal(3, target.Index)
+}
+
+func TestCodeFromOperation(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	pks := []KeyValue{{Key: "pk1", Value: "pk1"}, {Key: "pk2", Value: int64(1)}}
+	ast.NoError(PutRowMap(ctx, pks, nil))
+	ast.Equal(CodeConditionCheckFail, Code(PutRowMap(ctx, pks, nil)))
+}
+
+func TestConditionalWrite(t *testing.T) {
+	type versionedRow struct {
+		Pk1     *string `json:"pk1" pk:"1"`
+		Pk2     *int64  `json:"pk2" pk:"2"`
+		Version *int64  `json:"version"`
+		Col1    *string `json:"col1"`
+	}
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+	ast.NoError(PutRow(ctx, &versionedRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Version: tea.Int64(3), Col1: tea.String("v3")}))
+
+	versionIs := func(v int64) tablestore.ColumnFilter {
+		return tablestore.NewSingleColumnCondition("version", tablestore.CT_EQUAL, v)
+	}
+	read := func() versionedRow {
+		row := versionedRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+		ast.NoError(GetRow(ctx, &row))
+		return row
+	}
+
+	// 版本不符时更新失败，可通过 IsConditionCheckFail 识别
+	err := UpdateRow(ctx, &versionedRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Version: tea.Int64(5), Col1: tea.String("stale")}, UpdateRowParams{ColumnCondition: versionIs(4)})
+	ast.True(IsConditionCheckFail(err))
+	ast.Equal("v3", tea.StringValue(read().Col1))
+
+	ast.NoError(UpdateRow(ctx, &versionedRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Version: tea.Int64(4), Col1: tea.String("v4")}, UpdateRowParams{ColumnCondition: versionIs(3)}))
+	ast.Equal("v4", tea.StringValue(read().Col1))
+
+	// PutRow 同样支持列条件，与存在性期望一起生效
+	ignore := tablestore.RowExistenceExpectation_IGNORE
+	err = PutRow(ctx, &versionedRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Version: tea.Int64(5)}, PutRowParams{RowExistenceExpectation: &ignore, ColumnCondition: versionIs(3)})
+	ast.True(IsConditionCheckFail(err))
+	ast.NoError(PutRow(ctx, &versionedRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Version: tea.Int64(5)}, PutRowParams{RowExistenceExpectation: &ignore, ColumnCondition: versionIs(4)}))
+	ast.Equal(int64(5), tea.Int64Value(read().Version))
+	ast.Nil(read().Col1)
+
+	// 批量写入中的列条件只影响对应的行
+	results, err := BatchWrite(ctx, []BatchOp{
+		{Kind: UpdateOp, Obj: &versionedRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("x")}, Params: UpdateRowParams{ColumnCondition: versionIs(1)}},
+	})
+	ast.Error(err)
+	ast.True(IsConditionCheckFail(results.Ops[0].Err))
+
+	ast.False(IsConditionCheckFail(nil))
+	ast.False(IsConditionCheckFail(&tablestore.OtsError{Code: CodeServerBusy}))
+}
+
+func TestOTSError(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	pks := []KeyValue{{Key: "pk1", Value: "pk1"}, {Key: "pk2", Value: int64(1)}}
+	ast.NoError(PutRowMap(ctx, pks, nil))
+	err := PutRowMap(ctx, pks, nil)
+
+	// 操作返回 *OTSError，可用哨兵错误匹配
+	var otsErr *OTSError
+	ast.True(errors.As(err, &otsErr))
+	ast.Equal(CodeConditionCheckFail, otsErr.Code)
+	ast.Equal("Condition check failed.", otsErr.Message)
+	ast.ErrorIs(err, ErrConditionCheckFail)
+	ast.NotErrorIs(err, ErrRowOperationConflict)
+
+	// SDK 的原始错误仍可通过 Unwrap 取得，错误信息不变
+	var sdkErr *tablestore.OtsError
+	ast.True(errors.As(err, &sdkErr))
+	ast.Same(sdkErr, errors.Unwrap(err))
+	ast.Equal(sdkErr.Error(), err.Error())
+
+	conflict := &tablestore.OtsError{Code: CodeRowOperationConflict, Message: "Data is being modified.", RequestId: "req-1", HttpStatusCode: 409}
+	fake.Intercept = func(operation string, request any) error { return conflict }
+	err = PutRowMap(ctx, pks, nil)
+	ast.ErrorIs(err, ErrRowOperationConflict)
+	ast.ErrorIs(err, conflict)
+	ast.True(errors.As(err, &otsErr))
+	ast.Equal(409, otsErr.HTTPStatus)
+	ast.Equal("req-1", otsErr.RequestID)
+
+	// 不带哨兵的错误码与非服务端错误
+	ast.NotErrorIs(wrapOTSError(&tablestore.OtsError{Code: CodeServerBusy}), ErrConditionCheckFail)
+	plain := errors.New("plain")
+	ast.Same(plain, wrapOTSError(plain))
+
+	// 批量写入的单行错误同样匹配哨兵错误
+	ast.ErrorIs(newRowError("test_table", 0, tablestore.Error{Code: CodeConditionCheckFail}), ErrConditionCheckFail)
+}
@@ -0,0 +1,63 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+// Wire overheads used by the size estimates. They round the plainbuffer and protobuf framing
+// up, so estimates are slightly larger than the encoded size.
+const (
+	// cellOverhead covers the tags, length prefixes, value type, cell type, timestamp and
+	// checksum of a cell.
+	cellOverhead = 29
+
+	// rowOverhead covers the plainbuffer header, row tags and row checksum.
+	rowOverhead = 8
+
+	// rowChangeOverhead covers the protobuf framing of a row change: field tags, length
+	// prefixes, condition and return type.
+	rowChangeOverhead = 16
+)
+
+// RowChangeEstimate describes a row change for EstimateRequestSize.
+type RowChangeEstimate struct {
+	TableName  string
+	PrimaryKey []KeyValue
+	Columns    []KeyValue
+}
+
+// EstimateRowSize returns the approximate encoded size in bytes of a row: for each cell, the
+// length of its name plus the length of its value plus a fixed cell overhead, and a fixed row
+// overhead. Strings and binary values count their length, integers and doubles 8 bytes and
+// booleans 1 byte. The estimate is never smaller than the encoded row.
+func EstimateRowSize(pks []KeyValue, cols []KeyValue) int {
+	size := rowOverhead
+	for _, kvs := range [][]KeyValue{pks, cols} {
+		for _, kv := range kvs {
+			size += cellOverhead + len(kv.Key) + valueSize(kv.Value)
+		}
+	}
+	return size
+}
+
+// EstimateRequestSize returns the approximate size in bytes of a request carrying the row
+// changes: the EstimateRowSize of each change plus its table name and a fixed per-change
+// overhead. Compare it with MaxRequestSize before sending a batch.
+func EstimateRequestSize(changes ...RowChangeEstimate) int {
+	size := 0
+	for _, change := range changes {
+		size += rowChangeOverhead + len(change.TableName) + EstimateRowSize(change.PrimaryKey, change.Columns)
+	}
+	return size
+}
+
+// valueSize returns the encoded length of a column value, excluding framing.
+func valueSize(value any) int {
+	switch v := value.(type) {
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	case bool:
+		return 1
+	default:
+		return 8
+	}
+}
@@ -0,0 +1,87 @@
+package otsutils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/117503445/otsutils/internal/plainbuffer"
+	"github.com/117503445/otsutils/otsreplay"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/otsprotocol"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateRowSize(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Equal(rowOverhead, EstimateRowSize(nil, nil))
+	ast.Equal(rowOverhead+cellOverhead+3+5+cellOverhead+4+8,
+		EstimateRowSize([]KeyValue{{Key: "pk1", Value: "hello"}}, []KeyValue{{Key: "col1", Value: int64(1)}}))
+
+	changes := []RowChangeEstimate{
+		{TableName: "t1", PrimaryKey: []KeyValue{{Key: "pk1", Value: "a"}}},
+		{TableName: "t2", PrimaryKey: []KeyValue{{Key: "pk1", Value: "b"}}, Columns: []KeyValue{{Key: "c", Value: []byte{1, 2}}}},
+	}
+	ast.Equal(
+		2*rowChangeOverhead+4+EstimateRowSize(changes[0].PrimaryKey, nil)+EstimateRowSize(changes[1].PrimaryKey, changes[1].Columns),
+		EstimateRequestSize(changes...))
+}
+
+// TestEstimateRequestSizeFixtures 用 fixture 中由 SDK 编码的请求检验估算值不小于实际编码大小，且偏差有限。
+// fixture 的响应来自 otsfake，但请求是 SDK 的真实编码，与响应来源无关
+func TestEstimateRequestSizeFixtures(t *testing.T) {
+	ast := assert.New(t)
+
+	paths, err := filepath.Glob("testdata/*.json")
+	ast.NoError(err)
+	checked := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		ast.NoError(err)
+		var fixture otsreplay.Fixture
+		ast.NoError(json.Unmarshal(data, &fixture))
+
+		for _, interaction := range fixture.Interactions {
+			var table string
+			var row []byte
+			switch interaction.Operation {
+			case "PutRow":
+				req := &otsprotocol.PutRowRequest{}
+				ast.NoError(proto.Unmarshal(interaction.Request, req))
+				table, row = req.GetTableName(), req.Row
+			case "UpdateRow":
+				req := &otsprotocol.UpdateRowRequest{}
+				ast.NoError(proto.Unmarshal(interaction.Request, req))
+				table, row = req.GetTableName(), req.RowChange
+			default:
+				continue
+			}
+
+			rows, err := plainbuffer.Decode(row)
+			if !ast.NoError(err) || !ast.Len(rows, 1) {
+				continue
+			}
+			change := RowChangeEstimate{
+				TableName:  table,
+				PrimaryKey: cellsToKeyValues(rows[0].PrimaryKey),
+				Columns:    cellsToKeyValues(rows[0].Cells),
+			}
+			estimate, actual := EstimateRequestSize(change), len(interaction.Request)
+			ast.GreaterOrEqual(estimate, actual, "%s %s", path, interaction.Key)
+			ast.LessOrEqual(estimate, 2*actual, "%s %s", path, interaction.Key)
+			ast.GreaterOrEqual(EstimateRowSize(change.PrimaryKey, change.Columns), len(row))
+			checked++
+		}
+	}
+	ast.Positive(checked)
+}
+
+func cellsToKeyValues(cells []plainbuffer.Cell) []KeyValue {
+	kvs := make([]KeyValue, 0, len(cells))
+	for _, cell := range cells {
+		kvs = append(kvs, KeyValue{Key: cell.Name, Value: cell.Value})
+	}
+	return kvs
+}
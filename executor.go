@@ -3,34 +3,55 @@ package otsutils
 
 import (
 	"context"
+	"time"
 
-	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 	"github.com/rs/zerolog"
 )
 
+// readOperations are served by OtsUtilsParams.ReadClient when it is set.
+var readOperations = map[string]bool{
+	"GetRow":              true,
+	"GetRowMap":           true,
+	"GetRowToMap":         true,
+	"GetRowVersionsToMap": true,
+	"ExistsRow":           true,
+	"GetRange":            true,
+	"QueryByPkPrefix":     true,
+	"CountRange":          true,
+	"BatchGetRows":        true,
+	"ParallelScan":        true,
+	"GetRowsByIndex":      true,
+	"Search":              true,
+}
+
 // executeOTSOperation is a generic OTS operation execution function
 func executeOTSOperation(
 	ctx context.Context,
 	operation string,
 	obj any,
-	buildRequest func(*OtsUtilsParams, *zerolog.Logger, any, ...any) (any, error),
-	execute func(*tablestore.TableStoreClient, any) (any, error),
-	handleResponse func(*zerolog.Logger, any, any) error,
+	buildRequest func(context.Context, *OtsUtilsParams, *zerolog.Logger, any, ...any) (any, error),
+	execute func(OtsClient, any) (any, error),
+	handleResponse func(context.Context, *zerolog.Logger, any, any) error,
 	params ...any,
 ) error {
-	logger := zerolog.Ctx(ctx).With().Str("operation", operation).CallerWithSkipFrameCount(4).Logger()
 	otsParams := otsUtilsParamsFromCtx(ctx)
+	client, clientName := otsParams.clientFor(ctx, operation)
+	logger := otsParams.operationLogger(ctx, operation, clientName)
+	deadline := otsParams.operationDeadline(ctx)
 
 	{
 		e := logger.Debug().Interface("obj", obj)
 		if len(params) > 0 {
 			e = e.Interface("params", params[0])
 		}
+		if deadline.hasDeadline {
+			e = e.Dur("deadlineRemaining", deadline.remaining)
+		}
 		e.Msg("Executing OTS operation")
 	}
 
 	// Build request
-	req, err := buildRequest(otsParams, &logger, obj, params...)
+	req, err := buildRequest(ctx, otsParams, &logger, obj, params...)
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to build request")
 		return err
@@ -38,26 +59,65 @@ func executeOTSOperation(
 
 	logger.Debug().Interface("request", req).Msg("Request built")
 
+	var audit *mutationAudit
+	if otsParams.AuditHook != nil {
+		audit, err = beginAudit(otsParams, client, req)
+		if err != nil && otsParams.auditFailed(&logger, err) {
+			logger.Error().Err(err).Msg("Failed to capture audit before image")
+			return err
+		}
+	}
+
 	// Execute request
-	resp, err := execute(otsParams.Client, req)
+	start := time.Now()
+	resp, err := deadline.run(ctx, operation, func() (any, error) { return execute(client, req) })
+	if otsParams.MetricsHook != nil {
+		otsParams.MetricsHook.RecordOperation(ctx, OperationMetrics{
+			Operation:         operation,
+			TableName:         otsParams.TableName,
+			Client:            clientName,
+			DeadlineRemaining: deadline.remaining,
+			HasDeadline:       deadline.hasDeadline,
+			Duration:          time.Since(start),
+			ConsumedCapacity:  consumedCapacity(resp),
+			Err:               err,
+		})
+	}
 	if err != nil {
 		logger.Error().Err(err).Msg("OTS operation failed")
 		return err
 	}
 
+	if audit != nil {
+		if err := audit.record(ctx, otsParams.AuditHook); err != nil && otsParams.auditFailed(&logger, err) {
+			logger.Error().Err(err).Msg("Failed to record audit")
+			return err
+		}
+	}
+
 	logger.Debug().Interface("response", resp).Msg("Response received")
 
 	// Handle response
 	if handleResponse != nil {
-		if err := handleResponse(&logger, resp, obj); err != nil {
+		if err := handleResponse(ctx, &logger, resp, obj); err != nil {
 			logger.Error().Err(err).Msg("Failed to handle response")
 			return err
 		}
 	}
 
+	capacityLogEvent(&logger, consumedCapacity(resp), otsParams.CULogThreshold).Msg("OTS operation completed")
+
 	return nil
 }
 
+// operationLogger returns the logger of one operation, with its fields and the request tags.
+// The caller skip frame count makes the caller field point at the code that called the
+// exported operation, from executeOTSOperation's logging calls.
+func (otsUtilsParams *OtsUtilsParams) operationLogger(ctx context.Context, operation string, clientName string) zerolog.Logger {
+	logCtx := otsUtilsParams.baseLogger(ctx).With().Str("operation", operation).Str("client", clientName).Str("table", otsUtilsParams.TableName)
+	return withRequestTags(ctx, logCtx).CallerWithSkipFrameCount(4).Logger()
+}
+
 // toAnySlice converts a slice of a specific type to []any
 func toAnySlice[T any](slice []T) []any {
 	result := make([]any, len(slice))
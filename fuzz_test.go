@@ -0,0 +1,158 @@
+package otsutils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// fuzzFieldTypes are the field types FuzzParseObj builds structs from, supported or not.
+var fuzzFieldTypes = []reflect.Type{
+	reflect.TypeOf((*string)(nil)),
+	reflect.TypeOf((*int64)(nil)),
+	reflect.TypeOf((*[]byte)(nil)),
+	reflect.TypeOf(""),
+	reflect.TypeOf(0),
+	reflect.TypeOf((**string)(nil)),
+	reflect.TypeOf((*any)(nil)).Elem(),
+	reflect.TypeOf([]string(nil)),
+	reflect.TypeOf(map[string]string(nil)),
+	reflect.TypeOf(struct{ A *string }{}),
+	reflect.TypeOf((chan int)(nil)),
+	reflect.TypeOf((func())(nil)),
+	reflect.TypeOf((*testMoney)(nil)),
+	reflect.TypeOf(testMoney{}),
+	reflect.TypeOf((*testVersioned)(nil)),
+}
+
+var fuzzJSONTags = []string{"", "a", "b", "a,omitempty", "-", ",omitempty"}
+
+var fuzzPkTags = []string{"", "", "1", "2", "10", "a", "1,auto", "2,auto", "1,gen=ulid", "0", "1,gen"}
+
+// fuzzStruct synthesizes a struct type and a populated value from data, 3 bytes per field.
+func fuzzStruct(data []byte) reflect.Value {
+	var fields []reflect.StructField
+	for i := 0; i+2 < len(data) && len(fields) < 8; i += 3 {
+		typ := fuzzFieldTypes[int(data[i])%len(fuzzFieldTypes)]
+		tag := `json:"` + fuzzJSONTags[int(data[i+1])%len(fuzzJSONTags)] + `"`
+		if pk := fuzzPkTags[int(data[i+2])%len(fuzzPkTags)]; pk != "" {
+			tag += ` pk:"` + pk + `"`
+		}
+
+		field := reflect.StructField{Name: "F" + string(rune('A'+len(fields))), Type: typ, Tag: reflect.StructTag(tag)}
+		if data[i+1]&0x80 != 0 {
+			field.Name = "f" + string(rune('a'+len(fields)))
+			field.PkgPath = "github.com/117503445/otsutils"
+		}
+		fields = append(fields, field)
+	}
+
+	v := reflect.New(reflect.StructOf(fields))
+	for i, f := range fields {
+		if f.PkgPath != "" || data[i*3]&0x40 == 0 {
+			continue
+		}
+		field := v.Elem().Field(i)
+		switch f.Type.Kind() {
+		case reflect.Ptr:
+			elem := reflect.New(f.Type.Elem())
+			switch f.Type.Elem().Kind() {
+			case reflect.String:
+				elem.Elem().SetString(string(data))
+			case reflect.Int64:
+				elem.Elem().SetInt(int64(data[i*3+2]))
+			case reflect.Slice:
+				elem.Elem().SetBytes(data)
+			}
+			field.Set(elem)
+		case reflect.String:
+			field.SetString(string(data))
+		case reflect.Int:
+			field.SetInt(int64(data[i*3+1]))
+		}
+	}
+	return v
+}
+
+func FuzzParseObj(f *testing.F) {
+	registerTestMoney()
+	registerTestVersioned()
+
+	f.Add([]byte{0x40, 1, 2, 0x41, 2, 3})
+	f.Add([]byte{0x40, 0x81, 2})
+	f.Add([]byte{0x45, 1, 0, 0x46, 2, 0})
+	f.Add([]byte{0x4c, 1, 0, 0x4d, 2, 0, 0x4e, 3, 0})
+	f.Add([]byte{0x41, 1, 6, 0x40, 2, 8})
+
+	ctx := zerolog.Nop().WithContext(context.Background())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v := fuzzStruct(data)
+
+		// A nil pointer of the synthesized type must be rejected, not dereferenced
+		if _, _, err := ParseObj(ctx, reflect.Zero(v.Type()).Interface()); err == nil {
+			t.Fatalf("ParseObj accepted a nil %s", v.Type())
+		}
+
+		pks, cols, err := ParseObj(ctx, v.Interface())
+		if err != nil {
+			return
+		}
+		out := reflect.New(v.Elem().Type())
+		_ = ParseResult(ctx, out.Interface(), pks, cols)
+	})
+}
+
+func FuzzParseResult(f *testing.F) {
+	registerTestMoney()
+	registerTestVersioned()
+
+	type row struct {
+		Pk        *string        `json:"pk" pk:"1"`
+		Str       *string        `json:"str,omitempty"`
+		Int       *int64         `json:"int"`
+		Bytes     *[]byte        `json:"bytes"`
+		Money     *testMoney     `json:"money"`
+		Versioned *testVersioned `json:"versioned"`
+		Value     testMoney      `json:"value"`
+		Iface     any            `json:"iface"`
+		Plain     string         `json:"plain"`
+		Nested    **string       `json:"nested"`
+		hidden    *string
+	}
+
+	f.Add("str", uint8(0), "x", int64(1), []byte("b"))
+	f.Add("money", uint8(0), "1.5", int64(0), []byte(nil))
+	f.Add("versioned", uint8(1), "", int64(7), []byte(nil))
+	f.Add("iface", uint8(3), "", int64(0), []byte(nil))
+	f.Add("hidden", uint8(0), "x", int64(0), []byte(nil))
+
+	ctx := zerolog.Nop().WithContext(context.Background())
+	f.Fuzz(func(t *testing.T, key string, kind uint8, s string, i int64, b []byte) {
+		var value any
+		switch kind % 8 {
+		case 0:
+			value = s
+		case 1:
+			value = i
+		case 2:
+			value = b
+		case 3:
+			value = nil
+		case 4:
+			value = float64(i)
+		case 5:
+			value = i%2 == 0
+		case 6:
+			value = []string{s}
+		case 7:
+			value = &s
+		}
+
+		var out row
+		_ = ParseResult(ctx, &out, []KeyValue{{Key: key, Value: value}}, []KeyValue{{Key: key, Value: value}})
+		_ = ParseResult(ctx, &out, nil, nil)
+		_ = out.hidden
+	})
+}
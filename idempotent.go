@@ -0,0 +1,179 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// DefaultIdempotencyKeyColumn is the default of IdempotencyOptions.KeyColumn.
+const DefaultIdempotencyKeyColumn = "idem_key"
+
+// Statuses recorded in the status column of an idempotency marker.
+const (
+	IdempotencyRunning   = "running"
+	IdempotencySucceeded = "succeeded"
+	IdempotencyFailed    = "failed"
+)
+
+// Attribute columns of an idempotency marker row.
+const (
+	idempotencyStatusColumn  = "status"
+	idempotencyExpiresColumn = "expires_at"
+	idempotencyErrorColumn   = "error"
+	idempotencyTokenColumn   = "token"
+)
+
+// ErrIdempotencyInProgress is returned by Idempotent when another call is running fn for the key.
+var ErrIdempotencyInProgress = errors.New("idempotent call in progress")
+
+// idempotencyNow returns the current time, replaced by the tests.
+var idempotencyNow = time.Now
+
+// IdempotencyOptions contains parameters for the Idempotent operation.
+type IdempotencyOptions struct {
+	// KeyColumn is the primary key column of the marker table. Defaults to DefaultIdempotencyKeyColumn.
+	KeyColumn string
+}
+
+// Idempotent runs fn at most once per key, e.g. once per webhook delivery id, using marker rows
+// of the table of OtsUtilsParams. The table has a single STRING primary key column, KeyColumn,
+// holding the key.
+//
+// The marker is written under EXPECT_NOT_EXIST with the status IdempotencyRunning and an
+// expiry ttl from now, in Unix milliseconds in the "expires_at" column. Only the call that
+// writes it runs fn, then records IdempotencySucceeded, or IdempotencyFailed and the error
+// message in the "error" column, and returns executed=true with the error of fn. Other calls
+// return executed=false: with no error once fn succeeded, and with ErrIdempotencyInProgress
+// while it runs, so that the caller can try again later instead of taking the work as done.
+//
+// A failed marker, or one whose expiry passed, is ignored: the next call overwrites it and
+// runs fn again, so a failed fn is retried and a crashed one is run again after ttl. ttl
+// should therefore exceed the run time of fn. Expired markers can be garbage-collected, e.g.
+// with a table TTL longer than ttl.
+//
+// Example usage:
+//
+//	executed, err := Idempotent(ctx, event.ID, 24*time.Hour, func(ctx context.Context) error {
+//	    return handle(ctx, event)
+//	})
+func Idempotent(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) error, opts ...IdempotencyOptions) (executed bool, err error) {
+	var o IdempotencyOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.KeyColumn == "" {
+		o.KeyColumn = DefaultIdempotencyKeyColumn
+	}
+	if key == "" {
+		return false, fmt.Errorf("key can not be empty")
+	}
+	if ttl <= 0 {
+		return false, fmt.Errorf("ttl must be positive, got %s", ttl)
+	}
+	if fn == nil {
+		return false, fmt.Errorf("fn can not be nil")
+	}
+	token, err := newULID()
+	if err != nil {
+		return false, err
+	}
+
+	now := idempotencyNow()
+	pk := []KeyValue{{Key: o.KeyColumn, Value: key}}
+	obj := &rowKeyValues{
+		PrimaryKey: pk,
+		Columns: []KeyValue{
+			{Key: idempotencyExpiresColumn, Value: now.Add(ttl).UnixMilli()},
+			{Key: idempotencyStatusColumn, Value: IdempotencyRunning},
+			{Key: idempotencyTokenColumn, Value: token},
+		},
+	}
+	expectNotExist := tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST
+	expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+
+	// The marker may be deleted between the two puts, so they are tried twice
+	for attempt := 1; ; attempt++ {
+		err := executeOTSOperation(ctx, "Idempotent", obj, buildPutRowRequest, executePutRow, nil, PutRowParams{RowExistenceExpectation: &expectNotExist})
+		if err == nil {
+			break
+		}
+		if !IsConditionCheckFail(err) {
+			return false, err
+		}
+		// Overwrite a marker that failed or expired
+		err = executeOTSOperation(ctx, "Idempotent", obj, buildPutRowRequest, executePutRow, nil, PutRowParams{RowExistenceExpectation: &expectExist, ColumnCondition: staleMarkerCondition(now)})
+		if err == nil {
+			break
+		}
+		if !IsConditionCheckFail(err) {
+			return false, err
+		}
+
+		// The marker is live: fn succeeded or is running
+		status, err := idempotencyStatus(ctx, pk)
+		if err != nil {
+			return false, err
+		}
+		if status == IdempotencySucceeded {
+			return false, nil
+		}
+		if status == IdempotencyRunning || attempt == 2 {
+			return false, fmt.Errorf("%w: %s", ErrIdempotencyInProgress, key)
+		}
+	}
+
+	fnErr := fn(ctx)
+
+	status := &rowKeyValues{PrimaryKey: pk, Columns: []KeyValue{{Key: idempotencyStatusColumn, Value: IdempotencySucceeded}}}
+	p := UpdateRowParams{RowExistenceExpectation: &expectExist, ColumnCondition: tablestore.NewSingleColumnCondition(idempotencyTokenColumn, tablestore.CT_EQUAL, token)}
+	if fnErr != nil {
+		status.Columns = []KeyValue{{Key: idempotencyErrorColumn, Value: fnErr.Error()}, {Key: idempotencyStatusColumn, Value: IdempotencyFailed}}
+	} else {
+		p.DeletedColumns = []string{idempotencyErrorColumn}
+	}
+	err = executeOTSOperation(ctx, "Idempotent", status, buildUpdateRowRequest, executeUpdateRow, nil, p)
+	if IsConditionCheckFail(err) {
+		// fn outlived ttl and another call took the marker over, which records its own status
+		otsUtilsParamsFromCtx(ctx).baseLogger(ctx).Warn().Str("key", key).Msg("Idempotency marker was taken over before fn returned")
+		err = nil
+	}
+	if fnErr != nil {
+		return true, fnErr
+	}
+	return true, err
+}
+
+// idempotencyStatus returns the status of the marker with primary key pk, empty when there is no
+// marker.
+func idempotencyStatus(ctx context.Context, pk []KeyValue) (string, error) {
+	var getResp *tablestore.GetRowResponse
+	obj := &rowKeyValues{PrimaryKey: pk}
+	err := executeOTSOperation(ctx, "Idempotent", obj, buildGetRowRequest, executeGetRow, captureGetRowResponse(&getResp))
+	if err != nil || getResp == nil {
+		return "", err
+	}
+	_, cols := rowFromGetRowResponse(getResp)
+	for _, col := range cols {
+		if status, ok := col.Value.(string); ok && col.Key == idempotencyStatusColumn {
+			return status, nil
+		}
+	}
+	return "", nil
+}
+
+// staleMarkerCondition holds when a marker failed or expired before now.
+func staleMarkerCondition(now time.Time) tablestore.ColumnFilter {
+	failed := tablestore.NewSingleColumnCondition(idempotencyStatusColumn, tablestore.CT_EQUAL, IdempotencyFailed)
+	failed.FilterIfMissing = true
+	expired := tablestore.NewSingleColumnCondition(idempotencyExpiresColumn, tablestore.CT_LESS_THAN, now.UnixMilli())
+	expired.FilterIfMissing = true
+	cond := tablestore.NewCompositeColumnCondition(tablestore.LO_OR)
+	cond.AddFilter(failed)
+	cond.AddFilter(expired)
+	return cond
+}
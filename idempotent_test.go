@@ -0,0 +1,137 @@
+package otsutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// useIdempotencyClock makes Idempotent read clock, which the test moves by hand.
+func useIdempotencyClock(t *testing.T, clock *fakeClock) {
+	idempotencyNow = clock.now
+	t.Cleanup(func() { idempotencyNow = time.Now })
+}
+
+// markerColumns returns the status and error columns of the marker of key.
+func markerColumns(t *testing.T, ctx context.Context, key string) map[string]any {
+	t.Helper()
+	cols, err := GetRowMap(ctx, []KeyValue{{Key: DefaultIdempotencyKeyColumn, Value: key}})
+	assert.NoError(t, err)
+	m := make(map[string]any)
+	for _, col := range cols {
+		if col.Key == "status" || col.Key == "error" {
+			m[col.Key] = col.Value
+		}
+	}
+	return m
+}
+
+func TestIdempotent(t *testing.T) {
+	start := time.UnixMilli(1_700_000_000_000)
+
+	t.Run("runs once", func(t *testing.T) {
+		ast := assert.New(t)
+		clock := &fakeClock{t: start}
+		ctx, _ := newSingleKeyContext(t, "markers", DefaultIdempotencyKeyColumn)
+		useIdempotencyClock(t, clock)
+
+		runs := 0
+		fn := func(ctx context.Context) error {
+			runs++
+			// 执行期间的重复调用返回 ErrIdempotencyInProgress
+			executed, err := Idempotent(ctx, "evt-1", time.Hour, func(ctx context.Context) error {
+				runs++
+				return nil
+			})
+			ast.ErrorIs(err, ErrIdempotencyInProgress)
+			ast.False(executed)
+			return nil
+		}
+		executed, err := Idempotent(ctx, "evt-1", time.Hour, fn)
+		ast.NoError(err)
+		ast.True(executed)
+		ast.Equal(1, runs)
+		ast.Equal(map[string]any{"status": IdempotencySucceeded}, markerColumns(t, ctx, "evt-1"))
+
+		// 成功后的重复调用被跳过
+		executed, err = Idempotent(ctx, "evt-1", time.Hour, fn)
+		ast.NoError(err)
+		ast.False(executed)
+		ast.Equal(1, runs)
+
+		// 过期的标记被忽略
+		clock.t = start.Add(2 * time.Hour)
+		executed, err = Idempotent(ctx, "evt-1", time.Hour, func(ctx context.Context) error { return nil })
+		ast.NoError(err)
+		ast.True(executed)
+	})
+
+	t.Run("failures are recorded and retried", func(t *testing.T) {
+		ast := assert.New(t)
+		clock := &fakeClock{t: start}
+		ctx, _ := newSingleKeyContext(t, "markers", DefaultIdempotencyKeyColumn)
+		useIdempotencyClock(t, clock)
+
+		boom := errors.New("boom")
+		executed, err := Idempotent(ctx, "evt-2", time.Hour, func(ctx context.Context) error { return boom })
+		ast.ErrorIs(err, boom)
+		ast.True(executed)
+		ast.Equal(map[string]any{"status": IdempotencyFailed, "error": "boom"}, markerColumns(t, ctx, "evt-2"))
+
+		executed, err = Idempotent(ctx, "evt-2", time.Hour, func(ctx context.Context) error { return nil })
+		ast.NoError(err)
+		ast.True(executed)
+		ast.Equal(map[string]any{"status": IdempotencySucceeded}, markerColumns(t, ctx, "evt-2"))
+	})
+
+	t.Run("take over is logged", func(t *testing.T) {
+		ast := assert.New(t)
+		clock := &fakeClock{t: start}
+		ctx, _ := newSingleKeyContext(t, "markers", DefaultIdempotencyKeyColumn)
+		useIdempotencyClock(t, clock)
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf)
+		ctx = WithLogger(ctx, &logger)
+
+		// fn 超过 ttl 后，另一次调用接管标记并记录自己的状态
+		executed, err := Idempotent(ctx, "evt-3", time.Hour, func(ctx context.Context) error {
+			clock.t = start.Add(2 * time.Hour)
+			executed, err := Idempotent(ctx, "evt-3", time.Hour, func(ctx context.Context) error { return errors.New("boom") })
+			ast.True(executed)
+			ast.Error(err)
+			return nil
+		})
+		ast.NoError(err)
+		ast.True(executed)
+		ast.Equal(IdempotencyFailed, markerColumns(t, ctx, "evt-3")["status"])
+		ast.Contains(buf.String(), `"message":"Idempotency marker was taken over before fn returned"`)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newSingleKeyContext(t, "markers", DefaultIdempotencyKeyColumn)
+		useIdempotencyClock(t, &fakeClock{t: start})
+		noop := func(ctx context.Context) error { return nil }
+
+		_, err := Idempotent(ctx, "", time.Hour, noop)
+		ast.EqualError(err, "key can not be empty")
+		_, err = Idempotent(ctx, "evt", 0, noop)
+		ast.EqualError(err, "ttl must be positive, got 0s")
+		_, err = Idempotent(ctx, "evt", time.Hour, nil)
+		ast.EqualError(err, "fn can not be nil")
+		ast.Equal(0, fake.CallCount("PutRow"))
+
+		fake.Intercept = func(operation string, request any) error {
+			return &tablestore.OtsError{Code: CodeServerBusy, Message: "Server is busy."}
+		}
+		executed, err := Idempotent(ctx, "evt", time.Hour, noop)
+		ast.Equal(CodeServerBusy, Code(err))
+		ast.False(executed)
+	})
+}
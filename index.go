@@ -0,0 +1,520 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// indexTag is one entry of a parsed index struct tag.
+//
+// The grammar is `index:"<name>,<order>[;<name>,<order>...]"`: the field is the order-th
+// primary key column, from 1, of the secondary index name. A field may belong to several
+// indexes, and the orders of each index must run from 1 without gaps.
+type indexTag struct {
+	name  string
+	order int
+}
+
+// structIndexes returns the secondary indexes described by the index tags of obj, a pointer to
+// a row struct, sorted by name, and the attribute columns they use, which the table must
+// predefine. The primary key of each index is made of its tagged fields in order, followed by
+// the primary key columns of the table that are not already part of it, as the service requires.
+func structIndexes(obj any) (indexes []*tablestore.IndexMeta, columns []DefinedColumn, err error) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("obj must be a non-nil pointer to struct, got %T", obj)
+	}
+	t := v.Elem().Type()
+
+	meta, err := getStructMeta(t)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// index name -> columns in index order
+	byName := make(map[string][]string)
+	for i := range meta.fields {
+		fm := &meta.fields[i]
+		for _, idx := range fm.indexes {
+			cols := byName[idx.name]
+			for len(cols) < idx.order {
+				cols = append(cols, "")
+			}
+			cols[idx.order-1] = fm.column
+			byName[idx.name] = cols
+		}
+		if len(fm.indexes) > 0 && fm.pkTag == "" {
+			columns = append(columns, DefinedColumn{Name: fm.column, Type: definedColumnFieldType(fm.typ)})
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		index := &tablestore.IndexMeta{IndexName: name, Primarykey: byName[name]}
+		for _, i := range meta.pkFields {
+			if column := meta.fields[i].column; !slices.Contains(index.Primarykey, column) {
+				index.AddPrimaryKeyColumn(column)
+			}
+		}
+		index.SetAsGlobalIndex()
+		indexes = append(indexes, index)
+	}
+	return indexes, columns, nil
+}
+
+// definedColumnFieldType returns the predefined column type of a field of type ft, one of the
+// natively supported types.
+func definedColumnFieldType(ft reflect.Type) tablestore.DefinedColumnType {
+	switch kind := nativeBaseType(ft).Kind(); {
+	case kind == reflect.String:
+		return tablestore.DefinedColumn_STRING
+	case isIntegerKind(kind):
+		return tablestore.DefinedColumn_INTEGER
+	default:
+		return tablestore.DefinedColumn_BINARY
+	}
+}
+
+// CreateIndexesFromStruct creates the global secondary indexes declared by the index tags of
+// obj, a pointer to a row struct, on the table of OtsUtilsParams. A field tagged
+// `index:"<name>,<order>"` is the order-th primary key column of index name, from 1; the primary
+// key columns of the table are appended to each index as the service requires. A field may
+// belong to several indexes, separated by semicolons: `index:"idx_email,1;idx_name,2"`.
+//
+// The indexed attribute columns must be predefined columns of the table, which
+// CreateTableFromStruct declares for the same struct. The indexes are created in name order,
+// stopping at the first error; rows already in the table are indexed too. Use ListIndexes to
+// skip the indexes that already exist. The cached metadata of the table is dropped.
+//
+// Example usage:
+//
+//	type User struct {
+//	    ID    *string `json:"id" pk:"1"`
+//	    Email *string `json:"email" index:"idx_email,1"`
+//	}
+//
+//	if err := CreateIndexesFromStruct(ctx, &User{}); err != nil {
+//	    return err
+//	}
+func CreateIndexesFromStruct(ctx context.Context, obj any) error {
+	indexes, _, err := structIndexes(obj)
+	if err != nil {
+		return err
+	}
+	if len(indexes) == 0 {
+		return fmt.Errorf("%T has no index-tagged fields", obj)
+	}
+
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		InvalidateTableMeta(otsUtilsParamsFromCtx(ctx).TableName)
+		logger.Debug().Str("index", obj.(*tablestore.IndexMeta).IndexName).Msg("Index created")
+		return nil
+	}
+	for _, index := range indexes {
+		if err := executeOTSOperation(ctx, "CreateIndex", index, buildCreateIndexRequest, executeCreateIndex, handleResp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func buildCreateIndexRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	if err := validateName("table", otsParams.TableName); err != nil {
+		return nil, err
+	}
+	index := obj.(*tablestore.IndexMeta)
+	if err := validateName("index", index.IndexName); err != nil {
+		return nil, err
+	}
+	return &tablestore.CreateIndexRequest{MainTableName: otsParams.TableName, IndexMeta: index, IncludeBaseData: true}, nil
+}
+
+func executeCreateIndex(client OtsClient, req any) (any, error) {
+	indexClient, err := clientAs[IndexClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return indexClient.CreateIndex(req.(*tablestore.CreateIndexRequest))
+}
+
+// DeleteIndex deletes the secondary index indexName of the table of OtsUtilsParams. Deleting a
+// missing index fails with an error matching ErrObjectNotExist. The cached metadata of the
+// table is dropped.
+//
+// Example usage:
+//
+//	err := DeleteIndex(ctx, "idx_email")
+func DeleteIndex(ctx context.Context, indexName string) error {
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		InvalidateTableMeta(obj.(*tablestore.DeleteIndexRequest).MainTableName)
+		return nil
+	}
+	return executeOTSOperation(ctx, "DeleteIndex", &tablestore.DeleteIndexRequest{IndexName: indexName}, buildDeleteIndexRequest, executeDeleteIndex, handleResp)
+}
+
+func buildDeleteIndexRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	req := obj.(*tablestore.DeleteIndexRequest)
+	req.MainTableName = otsParams.TableName
+	if err := validateName("table", req.MainTableName); err != nil {
+		return nil, err
+	}
+	if err := validateName("index", req.IndexName); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func executeDeleteIndex(client OtsClient, req any) (any, error) {
+	indexClient, err := clientAs[IndexClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return indexClient.DeleteIndex(req.(*tablestore.DeleteIndexRequest))
+}
+
+// ListIndexes returns the secondary indexes of the table of OtsUtilsParams, read from the
+// service with DescribeTable.
+//
+// Example usage:
+//
+//	indexes, err := ListIndexes(ctx)
+//	for _, idx := range indexes {
+//	    fmt.Println(idx.Name, idx.PrimaryKeys)
+//	}
+func ListIndexes(ctx context.Context) ([]IndexDescription, error) {
+	desc, err := DescribeTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return desc.Indexes, nil
+}
+
+// DefaultFetchBaseRowWorkers is the number of BatchGetRow requests GetRowsByIndex sends at the
+// same time with FetchBaseRow when GetRangeParams.FetchWorkers is not set.
+const DefaultFetchBaseRowWorkers = 4
+
+// IndexReadResult is the outcome of a GetRowsByIndex.
+type IndexReadResult struct {
+	// IndexRows is the number of rows read from the index.
+	IndexRows int
+
+	// Duplicates is the number of rows of the index naming a row of the table already listed
+	// by an earlier row of the scan, which FetchBaseRow drops.
+	Duplicates int
+
+	// Missing is the number of rows listed by the index that FetchBaseRow did not find in the
+	// table, which are dropped.
+	Missing int
+
+	// ConsumedCapacity is the capacity consumed by the scan of the index and, with
+	// FetchBaseRow, by the reads from the table.
+	ConsumedCapacity tablestore.ConsumedCapacityUnit
+}
+
+// GetRowsByIndex reads the rows listed by the secondary index indexName of the table of
+// OtsUtilsParams into out, a pointer to a slice of row structs (or of pointers to them).
+// indexKeyObj names a prefix of the primary key of the index: a pointer to a row struct of the
+// same type whose fields set the leading index columns, or a *PrimaryKeyBuilder naming them in
+// index order. The remaining index columns range from INF_MIN to INF_MAX.
+//
+// The rows of the index are decoded as they are, holding only its primary key and the columns
+// it covers. GetRangeParams.FetchBaseRow reads the rows back from the table instead: the rows of
+// each page of the index are read with BatchGetRow requests of at most MaxBatchGetRows rows,
+// FetchWorkers at a time, and appended in the order of the scan. A global index is updated
+// asynchronously, so a row of the table may be listed twice, when it moved in the index during
+// the scan, or no longer exist: the later duplicates and the missing rows are dropped.
+// ColumnsToGet is not supported with FetchBaseRow, the rows read from the table have every
+// column of the struct. PageSize, MaxRows and Direction apply to the scan of the index, which is
+// read through TableMeta.
+//
+// Example usage:
+//
+//	// Every user with this email, read from the table
+//	var users []User
+//	res, err := GetRowsByIndex(ctx, "idx_email", &User{Email: tea.String("a@example.com")}, &users,
+//	    GetRangeParams{FetchBaseRow: true})
+//
+//	// Only the columns of the index
+//	_, err = GetRowsByIndex(ctx, "idx_email", PK().String("email", "a@example.com"), &users)
+func GetRowsByIndex(ctx context.Context, indexName string, indexKeyObj any, out any, params ...GetRangeParams) (*IndexReadResult, error) {
+	var p GetRangeParams
+	if len(params) > 0 {
+		p = params[0]
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if p.FetchBaseRow && len(p.ColumnsToGet) > 0 {
+		return nil, fmt.Errorf("ColumnsToGet can not be combined with FetchBaseRow, the rows read from the table have every column")
+	}
+	if p.FetchWorkers == 0 {
+		p.FetchWorkers = DefaultFetchBaseRowWorkers
+	}
+	if err := validateName("index", indexName); err != nil {
+		return nil, err
+	}
+
+	slice, elemType, err := outSlice(out)
+	if err != nil {
+		return nil, err
+	}
+	if elemType == rowMapType {
+		return nil, fmt.Errorf("out must be a pointer to a slice of structs, got %T", out)
+	}
+
+	desc, err := TableMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(desc.Indexes, func(idx IndexDescription) bool { return idx.Name == indexName })
+	if i < 0 {
+		return nil, fmt.Errorf("table '%s' has no index %s", desc.TableName, indexName)
+	}
+	columns := desc.Indexes[i].PrimaryKeys
+	startPK, endPK, err := indexRangeBoundaries(indexKeyObj, elemType, columns, p)
+	if err != nil {
+		return nil, fmt.Errorf("index key: %w", err)
+	}
+
+	// Reading the rows back from the table only takes the primary key from the index
+	scanParams := p
+	if p.FetchBaseRow {
+		scanParams.ColumnsToGet = columns
+	}
+	indexParams := *otsUtilsParamsFromCtx(ctx)
+	indexParams.TableName = indexName
+	indexCtx := indexParams.WithContext(ctx)
+
+	res := &IndexReadResult{}
+	fetch := &baseRowFetch{workers: p.FetchWorkers, seen: make(map[string]bool), res: res}
+	scan := &rangeScan{params: p, elemType: elemType, endPK: endPK}
+	page := &rangePage{StartPrimaryKey: startPK, EndPrimaryKey: endPK, Limit: p.pageLimit(0)}
+	for page != nil {
+		rows := reflect.New(slice.Type())
+		next := (*rangePage)(nil)
+		handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+			r := resp.(*tablestore.GetRangeResponse)
+			addCapacity(&res.ConsumedCapacity, r.ConsumedCapacityUnit)
+			var err error
+			next, err = scan.decodePage(ctx, r, func(elem reflect.Value) {
+				rows.Elem().Set(reflect.Append(rows.Elem(), elem))
+			})
+			return err
+		}
+
+		err := executeOTSOperation(indexCtx, "GetRowsByIndex", page, buildGetRangeRequest, executeGetRange, handleResp, scanParams)
+		if err != nil {
+			return nil, err
+		}
+		res.IndexRows += rows.Elem().Len()
+		if p.FetchBaseRow {
+			if err := fetch.read(ctx, rows); err != nil {
+				return nil, err
+			}
+		}
+		slice.Set(reflect.AppendSlice(slice, rows.Elem()))
+		page = next
+	}
+
+	return res, nil
+}
+
+// addCapacity adds cu, which may be nil, to total.
+func addCapacity(total *tablestore.ConsumedCapacityUnit, cu *tablestore.ConsumedCapacityUnit) {
+	if cu != nil {
+		total.Read += cu.Read
+		total.Write += cu.Write
+	}
+}
+
+// indexRangeBoundaries builds the boundaries of a scan of an index whose primary key columns
+// are columns, from keyObj: a pointer to a row struct of elemType whose fields set a prefix of
+// those columns, or a *PrimaryKeyBuilder naming it.
+func indexRangeBoundaries(keyObj any, elemType reflect.Type, columns []string, p GetRangeParams) (start, end *tablestore.PrimaryKey, err error) {
+	var prefix []KeyValue
+	if b, ok := keyObj.(*PrimaryKeyBuilder); ok {
+		if prefix, err = b.Build(); err != nil {
+			return nil, nil, err
+		}
+		if len(prefix) > len(columns) {
+			return nil, nil, fmt.Errorf("%d columns given, the index has %d", len(prefix), len(columns))
+		}
+		for i, kv := range prefix {
+			if kv.Key != columns[i] {
+				return nil, nil, fmt.Errorf("column %q at index %d does not match index column %q", kv.Key, i, columns[i])
+			}
+		}
+	} else {
+		structType := elemType
+		if structType.Kind() == reflect.Ptr {
+			structType = structType.Elem()
+		}
+		v := reflect.ValueOf(keyObj)
+		if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Type() != structType {
+			return nil, nil, fmt.Errorf("must be a non-nil *%s or a *PrimaryKeyBuilder, got %T", structType, keyObj)
+		}
+		meta, err := getStructMeta(structType)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		firstUnset := ""
+		for _, column := range columns {
+			var value any
+			skip := true
+			for i := range meta.fields {
+				if fm := &meta.fields[i]; fm.column == column {
+					if value, skip, err = fm.value(fm.field(v.Elem())); err != nil {
+						return nil, nil, err
+					}
+					break
+				}
+			}
+			if skip {
+				if firstUnset == "" {
+					firstUnset = column
+				}
+				continue
+			}
+			if firstUnset != "" {
+				return nil, nil, fmt.Errorf("index column %s is set but preceding index column %s is nil", column, firstUnset)
+			}
+			if err := validatePrimaryKeyValue(column, value); err != nil {
+				return nil, nil, err
+			}
+			prefix = append(prefix, KeyValue{Key: column, Value: value})
+		}
+	}
+	if len(prefix) == len(columns) {
+		return nil, nil, fmt.Errorf("every primary key column of the index is set, leave the trailing ones nil to scan a prefix")
+	}
+
+	startFill, endFill := p.boundaryFills()
+	start, end = &tablestore.PrimaryKey{}, &tablestore.PrimaryKey{}
+	for i, column := range columns {
+		if i < len(prefix) {
+			start.AddPrimaryKeyColumn(column, prefix[i].Value)
+			end.AddPrimaryKeyColumn(column, prefix[i].Value)
+			continue
+		}
+		start.PrimaryKeys = append(start.PrimaryKeys, &tablestore.PrimaryKeyColumn{ColumnName: column, PrimaryKeyOption: startFill})
+		end.PrimaryKeys = append(end.PrimaryKeys, &tablestore.PrimaryKeyColumn{ColumnName: column, PrimaryKeyOption: endFill})
+	}
+	return start, end, nil
+}
+
+// baseRowFetch reads the rows listed by the pages of an index scan back from the table.
+type baseRowFetch struct {
+	workers int
+
+	// seen holds the primary keys of the rows already listed by the scan
+	seen map[string]bool
+
+	res *IndexReadResult
+}
+
+// read replaces the rows decoded from a page of the index, in rows, a pointer to a slice of row
+// structs, with the rows of the table they name, dropping the rows already listed by the scan
+// and the ones missing from the table. The rows are read in chunks of MaxBatchGetRows, workers
+// chunks at a time, and keep their order.
+func (f *baseRowFetch) read(ctx context.Context, rows reflect.Value) error {
+	elems := make([]any, 0, rows.Elem().Len())
+	for i := 0; i < rows.Elem().Len(); i++ {
+		elem := rows.Elem().Index(i)
+		if elem.Kind() != reflect.Ptr {
+			elem = elem.Addr()
+		}
+		pks, _, err := parseRow(ctx, elem.Interface())
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%#v", pks)
+		if f.seen[key] {
+			f.res.Duplicates++
+			continue
+		}
+		f.seen[key] = true
+		elems = append(elems, elem.Interface())
+	}
+
+	structType := rows.Elem().Type().Elem()
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	meta, err := getStructMeta(structType)
+	if err != nil {
+		return err
+	}
+	tableName := otsUtilsParamsFromCtx(ctx).TableName
+	errs := make([]error, len(elems))
+
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, f.workers)
+	for start := 0; start < len(elems); start += MaxBatchGetRows {
+		group := batchGetGroup{
+			TableName: tableName,
+			Elems:     elems[start:min(start+MaxBatchGetRows, len(elems))],
+			columns:   meta.attrColumns(),
+			offset:    start,
+			errs:      errs,
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+				r := resp.(*tablestore.BatchGetRowResponse)
+				mu.Lock()
+				addCapacity(&f.res.ConsumedCapacity, consumedCapacity(r))
+				mu.Unlock()
+				return assignBatchGetRows(ctx, r, group)
+			}
+			err := executeOTSOperation(ctx, "GetRowsByIndex", []batchGetGroup{group}, buildBatchGetRowRequest, executeBatchGetRow, handleResp)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	kept := reflect.MakeSlice(rows.Elem().Type(), 0, len(elems))
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			elem := reflect.ValueOf(elems[i])
+			if rows.Elem().Type().Elem().Kind() != reflect.Ptr {
+				elem = elem.Elem()
+			}
+			kept = reflect.Append(kept, elem)
+		case errors.Is(err, ErrRowNotFound):
+			f.res.Missing++
+		default:
+			return err
+		}
+	}
+	rows.Elem().Set(kept)
+	return nil
+}
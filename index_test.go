@@ -0,0 +1,261 @@
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/117503445/otsutils/otsfake"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+type IndexedRow struct {
+	UserID *string `json:"user_id" pk:"1"`
+	Seq    *int64  `json:"seq" pk:"2" index:"idx_city_seq,2"`
+	Email  *string `json:"email" index:"idx_email,1"`
+	City   *string `json:"city" index:"idx_city_seq,1;idx_city_age,1"`
+	Age    *int64  `json:"age" index:"idx_city_age,2"`
+	Note   *string `json:"note"`
+}
+
+func TestCreateIndexesFromStruct(t *testing.T) {
+	t.Run("creates the indexes and lists them", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		o := OtsUtilsParams{Client: fake, TableName: "users"}
+		ctx = o.WithContext(ctx)
+
+		ast.NoError(CreateTableFromStruct(ctx, &IndexedRow{}))
+		desc, err := DescribeTable(ctx)
+		ast.NoError(err)
+		// 只有建索引的属性列成为预定义列
+		ast.Equal([]DefinedColumn{
+			{Name: "email", Type: tablestore.DefinedColumn_STRING},
+			{Name: "city", Type: tablestore.DefinedColumn_STRING},
+			{Name: "age", Type: tablestore.DefinedColumn_INTEGER},
+		}, desc.DefinedColumns)
+
+		var reqs []*tablestore.CreateIndexRequest
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "CreateIndex" {
+				reqs = append(reqs, request.(*tablestore.CreateIndexRequest))
+			}
+			return nil
+		}
+		_, err = TableMeta(ctx)
+		ast.NoError(err)
+		ast.NoError(CreateIndexesFromStruct(ctx, &IndexedRow{}))
+		ast.Len(reqs, 3)
+		ast.Equal("users", reqs[0].MainTableName)
+		ast.Equal(tablestore.IT_GLOBAL_INDEX, reqs[0].IndexMeta.IndexType)
+		ast.Nil(cachedTableMeta(otsUtilsParamsFromCtx(ctx)))
+
+		// 表的主键列按顺序补在索引主键之后，已在索引中的不重复
+		indexes, err := ListIndexes(ctx)
+		ast.NoError(err)
+		ast.Equal([]IndexDescription{
+			{Name: "idx_city_age", PrimaryKeys: []string{"city", "age", "user_id", "seq"}},
+			{Name: "idx_city_seq", PrimaryKeys: []string{"city", "seq", "user_id"}},
+			{Name: "idx_email", PrimaryKeys: []string{"email", "user_id", "seq"}},
+		}, indexes)
+
+		err = CreateIndexesFromStruct(ctx, &IndexedRow{})
+		ast.Equal(CodeObjectAlreadyExist, Code(err))
+
+		ast.NoError(DeleteIndex(ctx, "idx_email"))
+		indexes, err = ListIndexes(ctx)
+		ast.NoError(err)
+		ast.Len(indexes, 2)
+		ast.ErrorIs(DeleteIndex(ctx, "idx_email"), ErrObjectNotExist)
+		ast.EqualError(DeleteIndex(ctx, "bad-name"), `index name "bad-name" contains '-' at byte 3; only letters, digits and underscores are allowed`)
+	})
+
+	t.Run("the index columns must be predefined", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+
+		type row struct {
+			Pk1   *string `json:"pk1" pk:"1"`
+			Pk2   *int64  `json:"pk2" pk:"2"`
+			Email *string `json:"email" index:"idx_email,1"`
+		}
+		// test_table 没有预定义列
+		ast.Equal(CodeParameterInvalid, Code(CreateIndexesFromStruct(ctx, &row{})))
+	})
+
+	t.Run("invalid structs", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		type noIndex struct {
+			Pk1 *string `json:"pk1" pk:"1"`
+		}
+		type gap struct {
+			Pk1 *string `json:"pk1" pk:"1"`
+			A   *string `json:"a" index:"idx,1"`
+			B   *string `json:"b" index:"idx,3"`
+		}
+		type dup struct {
+			Pk1 *string `json:"pk1" pk:"1"`
+			A   *string `json:"a" index:"idx,1"`
+			B   *string `json:"b" index:"idx,1"`
+		}
+		type badTag struct {
+			Pk1 *string `json:"pk1" pk:"1"`
+			A   *string `json:"a" index:"idx"`
+		}
+		ast.EqualError(CreateIndexesFromStruct(ctx, &noIndex{}), "*otsutils.noIndex has no index-tagged fields")
+		ast.ErrorContains(CreateIndexesFromStruct(ctx, &gap{}), "index idx has no field with order 2: index orders must run from 1 without gaps")
+		ast.ErrorContains(CreateIndexesFromStruct(ctx, &dup{}), `field B: invalid index tag "idx,1": order 1 of index idx is already used by field A`)
+		ast.ErrorContains(CreateIndexesFromStruct(ctx, &badTag{}), `field A: invalid index tag "idx": want <name>,<order>, got "idx"`)
+		ast.EqualError(CreateIndexesFromStruct(ctx, IndexedRow{}), "obj must be a non-nil pointer to struct, got otsutils.IndexedRow")
+		ast.Equal(0, fake.CallCount("CreateIndex"))
+	})
+}
+
+func TestGetRowsByIndex(t *testing.T) {
+	newIndexedContext := func(t *testing.T) (context.Context, *otsfake.Client) {
+		ctx, fake := newFakeContext(t)
+		o := OtsUtilsParams{Client: fake, TableName: "users"}
+		ctx = o.WithContext(ctx)
+		assert.NoError(t, CreateTableFromStruct(ctx, &IndexedRow{}))
+		assert.NoError(t, CreateIndexesFromStruct(ctx, &IndexedRow{}))
+		for _, row := range []IndexedRow{
+			{UserID: tea.String("u1"), Seq: tea.Int64(1), Email: tea.String("a@x.com"), City: tea.String("hz"), Age: tea.Int64(30), Note: tea.String("n1")},
+			{UserID: tea.String("u2"), Seq: tea.Int64(1), Email: tea.String("b@x.com"), City: tea.String("hz"), Age: tea.Int64(20), Note: tea.String("n2")},
+			{UserID: tea.String("u3"), Seq: tea.Int64(2), Email: tea.String("a@x.com"), City: tea.String("sh"), Age: tea.Int64(40)},
+			// 没有 email 的行不进入 idx_email
+			{UserID: tea.String("u4"), Seq: tea.Int64(1), City: tea.String("hz"), Age: tea.Int64(50)},
+		} {
+			assert.NoError(t, PutRow(ctx, &row))
+		}
+		return ctx, fake
+	}
+
+	t.Run("reads the rows back from the table", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newIndexedContext(t)
+
+		var rows []IndexedRow
+		res, err := GetRowsByIndex(ctx, "idx_email", &IndexedRow{Email: tea.String("a@x.com")}, &rows, GetRangeParams{FetchBaseRow: true})
+		ast.NoError(err)
+		ast.Len(rows, 2)
+		ast.Equal("u1", *rows[0].UserID)
+		ast.Equal("n1", *rows[0].Note)
+		ast.Equal("u3", *rows[1].UserID)
+		ast.Nil(rows[1].Note)
+		ast.Equal(1, fake.CallCount("BatchGetRow"))
+		// 容量包含扫描索引和回表两个阶段
+		ast.Equal(&IndexReadResult{IndexRows: 2, ConsumedCapacity: tablestore.ConsumedCapacityUnit{Read: 2 + 2}}, res)
+
+		// 按年龄倒序，分页读取
+		var ptrs []*IndexedRow
+		_, err = GetRowsByIndex(ctx, "idx_city_age", PK().String("city", "hz"), &ptrs,
+			GetRangeParams{Direction: tablestore.BACKWARD, PageSize: 2, FetchBaseRow: true})
+		ast.NoError(err)
+		ast.Len(ptrs, 3)
+		ast.Equal([]int64{50, 30, 20}, []int64{*ptrs[0].Age, *ptrs[1].Age, *ptrs[2].Age})
+		ast.Equal("n2", *ptrs[2].Note)
+
+		rows = nil
+		_, err = GetRowsByIndex(ctx, "idx_city_age", &IndexedRow{City: tea.String("hz")}, &rows, GetRangeParams{MaxRows: 1, FetchBaseRow: true})
+		ast.NoError(err)
+		ast.Len(rows, 1)
+		ast.Equal("u2", *rows[0].UserID)
+	})
+
+	t.Run("index rows by default", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newIndexedContext(t)
+
+		var rows []IndexedRow
+		res, err := GetRowsByIndex(ctx, "idx_email", &IndexedRow{Email: tea.String("a@x.com")}, &rows)
+		ast.NoError(err)
+		ast.Len(rows, 2)
+		ast.Equal("a@x.com", *rows[0].Email)
+		ast.Equal("u1", *rows[0].UserID)
+		ast.Equal(int64(1), *rows[0].Seq)
+		// 索引不覆盖的列不会读出
+		ast.Nil(rows[0].Note)
+		ast.Equal(0, fake.CallCount("BatchGetRow"))
+		ast.Equal(&IndexReadResult{IndexRows: 2, ConsumedCapacity: tablestore.ConsumedCapacityUnit{Read: 2}}, res)
+	})
+
+	t.Run("reads the table in concurrent chunks keeping the scan order", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newIndexedContext(t)
+
+		n := 2*MaxBatchGetRows + 10
+		rows := make([]IndexedRow, n)
+		for i := range rows {
+			// 年龄与用户 ID 的顺序相反，索引顺序不同于表的顺序
+			rows[i] = IndexedRow{UserID: tea.String(fmt.Sprintf("b%03d", i)), Seq: tea.Int64(1), City: tea.String("bj"), Age: tea.Int64(int64(n - i)), Note: tea.String("note")}
+		}
+		ast.NoError(BatchPutRows(ctx, &rows))
+
+		var got []*IndexedRow
+		res, err := GetRowsByIndex(ctx, "idx_city_age", PK().String("city", "bj"), &got, GetRangeParams{FetchBaseRow: true, FetchWorkers: 2})
+		ast.NoError(err)
+		ast.Equal(3, fake.CallCount("BatchGetRow"))
+		ast.Len(got, n)
+		for i, row := range got {
+			ast.Equal(int64(i+1), *row.Age)
+			ast.Equal("note", *row.Note)
+		}
+		ast.Equal(int32(2*n), res.ConsumedCapacity.Read)
+	})
+
+	t.Run("drops duplicates and the rows missing from the table", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newIndexedContext(t)
+
+		// 模拟索引同步延迟：读完第一页后 u2 在索引中后移，会被再次列出；
+		// 回表之前删除 u1
+		fake.Intercept = func(operation string, request any) error {
+			switch {
+			case operation == "GetRange" && fake.CallCount("GetRange") == 2:
+				return UpdateRow(ctx, &IndexedRow{UserID: tea.String("u2"), Seq: tea.Int64(1), Age: tea.Int64(60)})
+			case operation == "BatchGetRow" && fake.CallCount("BatchGetRow") == 2:
+				return DeleteRow(ctx, &IndexedRow{UserID: tea.String("u1"), Seq: tea.Int64(1)})
+			}
+			return nil
+		}
+		var rows []IndexedRow
+		res, err := GetRowsByIndex(ctx, "idx_city_age", PK().String("city", "hz"), &rows, GetRangeParams{PageSize: 1, FetchBaseRow: true})
+		ast.NoError(err)
+		ast.Equal([]string{"u2", "u4"}, []string{*rows[0].UserID, *rows[1].UserID})
+		// 保留第一次列出时读到的行
+		ast.Equal(int64(20), *rows[0].Age)
+		ast.Equal(4, res.IndexRows)
+		ast.Equal(1, res.Duplicates)
+		ast.Equal(1, res.Missing)
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newIndexedContext(t)
+
+		getRowsByIndex := func(indexName string, indexKeyObj any, out any, params ...GetRangeParams) error {
+			_, err := GetRowsByIndex(ctx, indexName, indexKeyObj, out, params...)
+			return err
+		}
+		var rows []IndexedRow
+		ast.EqualError(getRowsByIndex("idx_none", &IndexedRow{}, &rows), "table 'users' has no index idx_none")
+		ast.EqualError(getRowsByIndex("idx_city_age", &IndexedRow{Age: tea.Int64(1)}, &rows),
+			"index key: index column age is set but preceding index column city is nil")
+		ast.EqualError(getRowsByIndex("idx_email", &IndexedRow{Email: tea.String("a@x.com"), UserID: tea.String("u1"), Seq: tea.Int64(1)}, &rows),
+			"index key: every primary key column of the index is set, leave the trailing ones nil to scan a prefix")
+		ast.EqualError(getRowsByIndex("idx_email", PK().String("city", "hz"), &rows),
+			`index key: column "city" at index 0 does not match index column "email"`)
+		ast.EqualError(getRowsByIndex("idx_email", &RangeRow{}, &rows),
+			"index key: must be a non-nil *otsutils.IndexedRow or a *PrimaryKeyBuilder, got *otsutils.RangeRow")
+		ast.EqualError(getRowsByIndex("idx_email", &IndexedRow{}, &rows, GetRangeParams{ColumnsToGet: []string{"note"}, FetchBaseRow: true}),
+			"ColumnsToGet can not be combined with FetchBaseRow, the rows read from the table have every column")
+		ast.EqualError(getRowsByIndex("idx_email", &IndexedRow{}, &rows, GetRangeParams{FetchWorkers: -1}),
+			"FetchWorkers must not be negative, got -1")
+		var maps []map[string]any
+		ast.EqualError(getRowsByIndex("idx_email", PK(), &maps), "out must be a pointer to a slice of structs, got *[]map[string]interface {}")
+	})
+}
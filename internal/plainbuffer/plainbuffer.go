@@ -0,0 +1,442 @@
+// Package plainbuffer encodes and decodes the PlainBuffer row format used on the OTS wire.
+// The SDK keeps its implementation unexported; this one is shared by the test harnesses.
+package plainbuffer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	header = 0x75
+
+	tagRowPK           = 0x1
+	tagRowData         = 0x2
+	tagCell            = 0x3
+	tagCellName        = 0x4
+	tagCellValue       = 0x5
+	tagCellType        = 0x6
+	tagCellTimestamp   = 0x7
+	tagDeleteRowMarker = 0x8
+	tagRowChecksum     = 0x9
+	tagCellChecksum    = 0xA
+	tagExtension       = 0xB
+
+	vtInteger = 0x0
+	vtDouble  = 0x1
+	vtBoolean = 0x2
+	vtString  = 0x3
+	vtBlob    = 0x7
+)
+
+// Special is the value of a primary key cell that has no concrete value.
+type Special byte
+
+const (
+	InfMin        Special = 0x9
+	InfMax        Special = 0xa
+	AutoIncrement Special = 0xb
+)
+
+// Cell is a primary key or attribute cell.
+// Value is a string, int64, []byte, float64, bool or Special, or nil when the cell carries no value.
+type Cell struct {
+	Name         string
+	Value        any
+	Type         byte
+	HasType      bool
+	Timestamp    int64
+	HasTimestamp bool
+}
+
+// Row is a decoded row.
+type Row struct {
+	PrimaryKey []Cell
+	Cells      []Cell
+	Deleted    bool
+}
+
+// Encode writes the rows after a single header.
+func Encode(rows ...Row) []byte {
+	var b bytes.Buffer
+	writeUint32(&b, header)
+	for _, row := range rows {
+		row.write(&b)
+	}
+	return b.Bytes()
+}
+
+func (row Row) write(b *bytes.Buffer) {
+	b.WriteByte(tagRowPK)
+	crc := byte(0)
+	for _, cell := range row.PrimaryKey {
+		crc = crc8Byte(crc, cell.write(b))
+	}
+	if len(row.Cells) > 0 {
+		b.WriteByte(tagRowData)
+		for _, cell := range row.Cells {
+			crc = crc8Byte(crc, cell.write(b))
+		}
+	}
+	if row.Deleted {
+		b.WriteByte(tagDeleteRowMarker)
+		crc = crc8Byte(crc, 1)
+	} else {
+		crc = crc8Byte(crc, 0)
+	}
+	b.WriteByte(tagRowChecksum)
+	b.WriteByte(crc)
+}
+
+// write encodes the cell and returns its checksum.
+func (cell Cell) write(b *bytes.Buffer) byte {
+	b.WriteByte(tagCell)
+	b.WriteByte(tagCellName)
+	writeUint32(b, uint32(len(cell.Name)))
+	b.WriteString(cell.Name)
+	crc := crc8Bytes(0, []byte(cell.Name))
+
+	if cell.Value != nil {
+		b.WriteByte(tagCellValue)
+		switch v := cell.Value.(type) {
+		case Special:
+			writeUint32(b, 1)
+			b.WriteByte(byte(v))
+			crc = crc8Byte(crc, byte(v))
+		case string:
+			writeUint32(b, uint32(5+len(v)))
+			b.WriteByte(vtString)
+			writeUint32(b, uint32(len(v)))
+			b.WriteString(v)
+			crc = crc8Bytes(crc8Uint32(crc8Byte(crc, vtString), uint32(len(v))), []byte(v))
+		case []byte:
+			writeUint32(b, uint32(5+len(v)))
+			b.WriteByte(vtBlob)
+			writeUint32(b, uint32(len(v)))
+			b.Write(v)
+			crc = crc8Bytes(crc8Uint32(crc8Byte(crc, vtBlob), uint32(len(v))), v)
+		case int64:
+			writeUint32(b, 9)
+			b.WriteByte(vtInteger)
+			writeUint64(b, uint64(v))
+			crc = crc8Uint64(crc8Byte(crc, vtInteger), uint64(v))
+		case float64:
+			writeUint32(b, 9)
+			b.WriteByte(vtDouble)
+			writeUint64(b, math.Float64bits(v))
+			crc = crc8Uint64(crc8Byte(crc, vtDouble), math.Float64bits(v))
+		case bool:
+			var x byte
+			if v {
+				x = 1
+			}
+			writeUint32(b, 2)
+			b.WriteByte(vtBoolean)
+			b.WriteByte(x)
+			crc = crc8Byte(crc8Byte(crc, vtBoolean), x)
+		default:
+			panic(fmt.Sprintf("plainbuffer: unsupported value type %T", cell.Value))
+		}
+	}
+	if cell.HasType {
+		b.WriteByte(tagCellType)
+		b.WriteByte(cell.Type)
+	}
+	if cell.HasTimestamp {
+		b.WriteByte(tagCellTimestamp)
+		writeUint64(b, uint64(cell.Timestamp))
+		crc = crc8Uint64(crc, uint64(cell.Timestamp))
+	}
+	if cell.HasType {
+		crc = crc8Byte(crc, cell.Type)
+	}
+	b.WriteByte(tagCellChecksum)
+	b.WriteByte(crc)
+	return crc
+}
+
+var errTruncated = errors.New("plainbuffer: unexpected end of data")
+
+// Decode reads all rows following the header. Checksums are not verified.
+func Decode(data []byte) ([]Row, error) {
+	r := &reader{data: data}
+	if h, err := r.uint32(); err != nil {
+		return nil, err
+	} else if h != header {
+		return nil, fmt.Errorf("plainbuffer: invalid header %#x", h)
+	}
+
+	var rows []Row
+	for r.pos < len(r.data) {
+		row, err := r.row()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) peek() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errTruncated
+	}
+	return r.data[r.pos], nil
+}
+
+func (r *reader) byte() (byte, error) {
+	b, err := r.peek()
+	if err == nil {
+		r.pos++
+	}
+	return b, err
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, errTruncated
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) uint32() (uint32, error) {
+	b, err := r.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (r *reader) uint64() (uint64, error) {
+	b, err := r.bytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (r *reader) expect(tag byte) error {
+	b, err := r.byte()
+	if err != nil {
+		return err
+	}
+	if b != tag {
+		return fmt.Errorf("plainbuffer: expected tag %#x at offset %d, got %#x", tag, r.pos-1, b)
+	}
+	return nil
+}
+
+func (r *reader) row() (Row, error) {
+	var row Row
+	if err := r.expect(tagRowPK); err != nil {
+		return row, err
+	}
+	cells, err := r.cells()
+	if err != nil {
+		return row, err
+	}
+	row.PrimaryKey = cells
+
+	tag, err := r.byte()
+	if err != nil {
+		return row, err
+	}
+	if tag == tagRowData {
+		if row.Cells, err = r.cells(); err != nil {
+			return row, err
+		}
+		if tag, err = r.byte(); err != nil {
+			return row, err
+		}
+	}
+	if tag == tagDeleteRowMarker {
+		row.Deleted = true
+		if tag, err = r.byte(); err != nil {
+			return row, err
+		}
+	}
+	if tag == tagExtension {
+		n, err := r.uint32()
+		if err != nil {
+			return row, err
+		}
+		if _, err := r.bytes(int(n)); err != nil {
+			return row, err
+		}
+		if tag, err = r.byte(); err != nil {
+			return row, err
+		}
+	}
+	if tag != tagRowChecksum {
+		return row, fmt.Errorf("plainbuffer: missing row checksum at offset %d", r.pos-1)
+	}
+	_, err = r.byte()
+	return row, err
+}
+
+func (r *reader) cells() ([]Cell, error) {
+	var cells []Cell
+	for {
+		tag, err := r.peek()
+		if err != nil || tag != tagCell {
+			return cells, nil
+		}
+		r.pos++
+		cell, err := r.cell()
+		if err != nil {
+			return nil, err
+		}
+		cells = append(cells, cell)
+	}
+}
+
+func (r *reader) cell() (Cell, error) {
+	var cell Cell
+	if err := r.expect(tagCellName); err != nil {
+		return cell, err
+	}
+	n, err := r.uint32()
+	if err != nil {
+		return cell, err
+	}
+	name, err := r.bytes(int(n))
+	if err != nil {
+		return cell, err
+	}
+	cell.Name = string(name)
+
+	tag, err := r.byte()
+	if err != nil {
+		return cell, err
+	}
+	if tag == tagCellValue {
+		if cell.Value, err = r.value(); err != nil {
+			return cell, err
+		}
+		if tag, err = r.byte(); err != nil {
+			return cell, err
+		}
+	}
+	if tag == tagCellType {
+		if cell.Type, err = r.byte(); err != nil {
+			return cell, err
+		}
+		cell.HasType = true
+		if tag, err = r.byte(); err != nil {
+			return cell, err
+		}
+	}
+	if tag == tagCellTimestamp {
+		ts, err := r.uint64()
+		if err != nil {
+			return cell, err
+		}
+		cell.Timestamp, cell.HasTimestamp = int64(ts), true
+		if tag, err = r.byte(); err != nil {
+			return cell, err
+		}
+	}
+	if tag != tagCellChecksum {
+		return cell, fmt.Errorf("plainbuffer: missing cell checksum at offset %d", r.pos-1)
+	}
+	_, err = r.byte()
+	return cell, err
+}
+
+func (r *reader) value() (any, error) {
+	if _, err := r.uint32(); err != nil {
+		return nil, err
+	}
+	vt, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch vt {
+	case vtInteger:
+		v, err := r.uint64()
+		return int64(v), err
+	case vtDouble:
+		v, err := r.uint64()
+		return math.Float64frombits(v), err
+	case vtBoolean:
+		v, err := r.byte()
+		return v != 0, err
+	case vtString, vtBlob:
+		n, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.bytes(int(n))
+		if err != nil {
+			return nil, err
+		}
+		if vt == vtString {
+			return string(b), nil
+		}
+		return append([]byte(nil), b...), nil
+	case byte(InfMin), byte(InfMax), byte(AutoIncrement):
+		return Special(vt), nil
+	default:
+		return nil, fmt.Errorf("plainbuffer: unknown value type %#x", vt)
+	}
+}
+
+func writeUint32(b *bytes.Buffer, v uint32) {
+	b.Write(binary.LittleEndian.AppendUint32(nil, v))
+}
+
+func writeUint64(b *bytes.Buffer, v uint64) {
+	b.Write(binary.LittleEndian.AppendUint64(nil, v))
+}
+
+var crc8Table = func() (table [256]byte) {
+	for i := range table {
+		x := byte(i)
+		for j := 0; j < 8; j++ {
+			if x&0x80 != 0 {
+				x = x<<1 ^ 0x07
+			} else {
+				x <<= 1
+			}
+		}
+		table[i] = x
+	}
+	return table
+}()
+
+func crc8Byte(crc, in byte) byte {
+	return crc8Table[crc^in]
+}
+
+func crc8Bytes(crc byte, in []byte) byte {
+	for _, b := range in {
+		crc = crc8Byte(crc, b)
+	}
+	return crc
+}
+
+func crc8Uint32(crc byte, in uint32) byte {
+	for i := 0; i < 4; i++ {
+		crc = crc8Byte(crc, byte(in))
+		in >>= 8
+	}
+	return crc
+}
+
+func crc8Uint64(crc byte, in uint64) byte {
+	for i := 0; i < 8; i++ {
+		crc = crc8Byte(crc, byte(in))
+		in >>= 8
+	}
+	return crc
+}
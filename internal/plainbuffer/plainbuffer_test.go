@@ -0,0 +1,78 @@
+package plainbuffer
+
+import (
+	"testing"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeMatchesSDK(t *testing.T) {
+	ast := assert.New(t)
+
+	pk := &tablestore.PrimaryKey{}
+	pk.AddPrimaryKeyColumn("pk1", "a")
+	pk.AddPrimaryKeyColumn("pk2", int64(7))
+	pk.AddPrimaryKeyColumn("pk3", []byte{1, 2})
+	pkCells := []Cell{{Name: "pk1", Value: "a"}, {Name: "pk2", Value: int64(7)}, {Name: "pk3", Value: []byte{1, 2}}}
+
+	ast.Equal(pk.Build(false), Encode(Row{PrimaryKey: pkCells}))
+	ast.Equal(pk.Build(true), Encode(Row{PrimaryKey: pkCells, Deleted: true}))
+
+	put := &tablestore.PutRowChange{PrimaryKey: pk}
+	put.AddColumn("s", "x")
+	put.AddColumnWithTimestamp("i", int64(-1), 1700000000000)
+	put.AddColumn("f", 1.5)
+	put.AddColumn("b", true)
+	ast.Equal(put.Serialize(), Encode(Row{PrimaryKey: pkCells, Cells: []Cell{
+		{Name: "s", Value: "x"},
+		{Name: "i", Value: int64(-1), Timestamp: 1700000000000, HasTimestamp: true},
+		{Name: "f", Value: 1.5},
+		{Name: "b", Value: true},
+	}}))
+
+	update := &tablestore.UpdateRowChange{PrimaryKey: pk}
+	update.PutColumn("s", "y")
+	update.DeleteColumn("d")
+	update.IncrementColumn("n", 2)
+	ast.Equal(update.Serialize(), Encode(Row{PrimaryKey: pkCells, Cells: []Cell{
+		{Name: "s", Value: "y"},
+		{Name: "d", Type: tablestore.DELETE_ALL_VERSION, HasType: true},
+		{Name: "n", Value: int64(2), Type: tablestore.INCREMENT, HasType: true},
+	}}))
+
+	bounds := &tablestore.PrimaryKey{}
+	bounds.AddPrimaryKeyColumnWithMinValue("pk1")
+	bounds.AddPrimaryKeyColumnWithMaxValue("pk2")
+	ast.Equal(bounds.Build(false), Encode(Row{PrimaryKey: []Cell{{Name: "pk1", Value: InfMin}, {Name: "pk2", Value: InfMax}}}))
+}
+
+func TestDecode(t *testing.T) {
+	ast := assert.New(t)
+
+	rows := []Row{
+		{
+			PrimaryKey: []Cell{{Name: "pk1", Value: "a"}, {Name: "pk2", Value: AutoIncrement}},
+			Cells: []Cell{
+				{Name: "s", Value: "x", Timestamp: 1, HasTimestamp: true},
+				{Name: "d", Type: 1, HasType: true},
+				{Name: "b", Value: []byte("bin")},
+				{Name: "f", Value: -2.25},
+				{Name: "t", Value: false},
+			},
+		},
+		{PrimaryKey: []Cell{{Name: "pk1", Value: "b"}}, Deleted: true},
+	}
+
+	got, err := Decode(Encode(rows...))
+	ast.NoError(err)
+	ast.Equal(rows, got)
+
+	_, err = Decode([]byte{1, 2})
+	ast.Error(err)
+	data := Encode(rows...)
+	_, err = Decode(data[:len(data)-3])
+	ast.Error(err)
+	_, err = Decode([]byte{0x76, 0, 0, 0})
+	ast.ErrorContains(err, "invalid header")
+}
@@ -0,0 +1,803 @@
+// Package rowrules holds the rules a row struct must follow to be used with otsutils: column
+// names, pk and pkprefix tags, supported field types and primary key layout.
+// They are shared by the runtime metadata validator, which describes fields with reflect, and
+// the rowcheck analyzer, which describes them with go/types, so that both report the same problems.
+package rowrules
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Values of the ots tag: `ots:"extra"` marks the field collecting the columns no other field
+// maps to, and `ots:"flatten"` a struct field whose fields map to columns prefixed with its own.
+const (
+	OtsExtra   = "extra"
+	OtsFlatten = "flatten"
+)
+
+// Values of the otstype tag: `otstype:"json"` stores a field of any type as its JSON encoding,
+// and `otstype:"binary"` a UUID field as its 16 bytes.
+const (
+	OtsTypeJSON   = "json"
+	OtsTypeBinary = "binary"
+)
+
+// MaxFlattenDepth is the number of flattened structs a field can be nested in.
+const MaxFlattenDepth = 3
+
+// Kind classifies the type of a field once its pointers are stripped.
+// The names of KindChan, KindFunc and KindUnsafePointer appear in problems.
+type Kind string
+
+const (
+	KindOther         Kind = "other"
+	KindString        Kind = "string"
+	KindInt64         Kind = "int64"
+	KindBytes         Kind = "[]byte"
+	KindInt           Kind = "int"    // int, int8, int16, int32, uint8, uint16 and uint32, stored as int64
+	KindUint64        Kind = "uint64" // uint and uint64, whose values int64 can not all hold
+	KindFloat64       Kind = "float64"
+	KindFloat         Kind = "float" // float kinds other than float64
+	KindBool          Kind = "bool"
+	KindStruct        Kind = "struct"
+	KindMap           Kind = "map"
+	KindInterface     Kind = "interface"
+	KindChan          Kind = "chan"
+	KindFunc          Kind = "func"
+	KindUnsafePointer Kind = "unsafe.Pointer"
+)
+
+// Type describes the type of a field.
+type Type struct {
+	// Name is the type as written in problems, e.g. "**string"
+	Name string
+
+	// Pointers is the number of leading pointers
+	Pointers int
+
+	// Base is the name of the type with its pointers stripped
+	Base string
+
+	// BaseKind is the kind of the type with its pointers stripped
+	BaseKind Kind
+
+	// Serializable is set when a type serializer is registered for the type or the type it points to
+	Serializable bool
+
+	// AnyMap is set when the type is map[string]any, the type of the extra field
+	AnyMap bool
+
+	// Marshaler and Unmarshaler are set when the type, or the type it points to, implements
+	// OTSMarshaler and OTSUnmarshaler, possibly through its pointer
+	Marshaler   bool
+	Unmarshaler bool
+
+	// SQLNull is set when the type, or the type it points to, is a database/sql null type, such
+	// as sql.NullString, whose value a column can hold
+	SQLNull bool
+
+	// OtsNull is set when the type is otsutils.Null[T] of a native T
+	OtsNull bool
+
+	// UUID is set when the type, or the type it points to, is a [16]byte array implementing
+	// encoding.TextMarshaler and encoding.TextUnmarshaler, such as uuid.UUID
+	UUID bool
+}
+
+// Native reports whether the type is a string, integer other than uint and uint64, float64 or
+// []byte kind, or a pointer to one.
+func (t Type) Native() bool {
+	return t.Pointers <= 1 && (t.BaseKind == KindString || t.BaseKind == KindInt64 || t.BaseKind == KindInt || t.BaseKind == KindFloat64 || t.BaseKind == KindBytes)
+}
+
+// NativeKey reports whether the type is a native type a primary key column can hold, which
+// excludes float64.
+func (t Type) NativeKey() bool {
+	return t.Native() && t.BaseKind != KindFloat64
+}
+
+// Field describes one struct field.
+type Field struct {
+	Name     string
+	Exported bool
+	Type     Type
+
+	// JSONTag and PkTag are the values of the json and pk tags, empty when absent
+	JSONTag string
+	PkTag   string
+
+	// UntaggedColumn is the column the field maps to when it has no json tag, derived from
+	// its name
+	UntaggedColumn string
+
+	// PkPrefixTag is the value of the pkprefix tag, meaningful only when HasPkPrefix is set
+	PkPrefixTag string
+	HasPkPrefix bool
+
+	// IndexTag is the value of the index tag, empty when absent
+	IndexTag string
+
+	// OtsTag is the value of the ots tag, empty when absent
+	OtsTag string
+
+	// OtsTypeTag is the value of the otstype tag, empty when absent
+	OtsTypeTag string
+
+	// Anonymous is set for embedded fields
+	Anonymous bool
+
+	// Fields describes the fields of the struct an anonymous or flattened field holds or
+	// points to. It is nil for other fields, for flattened fields nested MaxFlattenDepth deep,
+	// and empty when an anonymous struct embeds itself.
+	Fields []Field
+
+	// Path is the index sequence of the field in the struct passed to Promote, as used by
+	// reflect.Value.FieldByIndex. Promote sets it.
+	Path []int
+
+	// Prefix is prepended to the column of a field of a flattened struct, and FlattenDepth is
+	// the number of flattened structs the field is nested in. Promote sets them.
+	Prefix       string
+	FlattenDepth int
+}
+
+// Column returns the column the field maps to, "" for fields tagged `json:"-"`.
+func (f Field) Column() string {
+	column := ColumnName(f.Name, f.JSONTag, f.UntaggedColumn)
+	if column == "" {
+		return ""
+	}
+	return f.Prefix + column
+}
+
+// Limits are the Tablestore limits the rules check.
+type Limits struct {
+	MaxPrimaryKeyColumns int
+	MaxColumnNameSize    int
+}
+
+// Problem is a rule violation. Field is the index of the offending field, or -1 when the
+// problem concerns the struct as a whole.
+type Problem struct {
+	Field int
+	Err   error
+}
+
+// FieldResult is a field that maps to a column.
+type FieldResult struct {
+	// Index is the index of the field in the input
+	Index  int
+	Column string
+
+	// Pk is the parsed pk tag, meaningful only when IsPk is set
+	Pk   PkTag
+	IsPk bool
+
+	// OmitEmpty is set when the json tag carries the omitempty option
+	OmitEmpty bool
+
+	// Prefix is the parsed pkprefix tag, nil when the field has none
+	Prefix *Prefix
+
+	// Indexes is the parsed index tag, nil when the field has none
+	Indexes []IndexTag
+
+	// OtsType is the value of the otstype tag, empty when absent
+	OtsType string
+}
+
+// Result is the outcome of Check.
+type Result struct {
+	Fields []FieldResult
+
+	// PkFields holds the indexes into Fields of the primary key fields, in pk order
+	PkFields []int
+
+	// AttrFields holds the indexes into Fields of the attribute fields, in declaration order
+	AttrFields []int
+
+	// Extra is the index in the input of the field tagged `ots:"extra"`, or -1 when there is none
+	Extra int
+
+	Problems []Problem
+}
+
+// Promote returns the fields of a struct with the fields of its embedded structs promoted the
+// way encoding/json does. An anonymous field of struct or pointer to struct type, whose json
+// tag names no column, is replaced by the fields of the struct, recursively; an unexported
+// pointer is dropped, since reflect can not allocate it. A column mapped to at a shallower
+// depth, or at the same depth of embedding by the only field naming it in its json tag, hides
+// the fields mapping to it elsewhere. Fields that still collide are kept, for Check to report
+// rather than dropped as encoding/json does.
+//
+// A field tagged `ots:"flatten"` is replaced by the fields of its struct as well, at the same
+// depth, their columns prefixed with its column and separator. The fields are returned in
+// declaration order, each with its Path, Prefix and FlattenDepth set.
+func Promote(fields []Field, separator string) []Field {
+	type embedded struct {
+		fields       []Field
+		path         []int
+		prefix       string
+		flattenDepth int
+	}
+	var promoted []Field
+	var depths []int
+	level := []embedded{{fields: fields}}
+	for depth := 0; len(level) > 0; depth++ {
+		var next []embedded
+		// Flattened structs are appended to the level being walked
+		for j := 0; j < len(level); j++ {
+			e := level[j]
+			for i, f := range e.fields {
+				f.Path = append(slices.Clone(e.path), i)
+				f.Prefix = e.prefix
+				f.FlattenDepth = e.flattenDepth
+				name, _, _ := strings.Cut(f.JSONTag, ",")
+				if f.Anonymous && f.Fields != nil && name == "" && f.JSONTag != "-" {
+					if f.Exported || f.Type.Pointers == 0 {
+						next = append(next, embedded{fields: f.Fields, path: f.Path, prefix: e.prefix, flattenDepth: e.flattenDepth})
+					}
+					continue
+				}
+				if f.OtsTag == OtsFlatten && f.Exported && f.Fields != nil && f.Column() != "" && f.PkTag == "" && f.IndexTag == "" && !f.HasPkPrefix && f.OtsTypeTag == "" {
+					level = append(level, embedded{fields: f.Fields, path: f.Path, prefix: f.Column() + separator, flattenDepth: f.FlattenDepth + 1})
+					continue
+				}
+				promoted = append(promoted, f)
+				depths = append(depths, depth)
+			}
+		}
+		level = next
+	}
+
+	// column -> indexes into promoted of the fields mapping to it at the shallowest depth
+	dominant := make(map[string][]int)
+	hidden := make([]bool, len(promoted))
+	for i, f := range promoted {
+		column := f.Column()
+		if !f.Exported || f.OtsTag != "" || column == "" {
+			continue
+		}
+		switch others := dominant[column]; {
+		case len(others) == 0 || depths[i] < depths[others[0]]:
+			for _, o := range others {
+				hidden[o] = true
+			}
+			dominant[column] = []int{i}
+		case depths[i] > depths[others[0]]:
+			hidden[i] = true
+		default:
+			dominant[column] = append(others, i)
+		}
+	}
+	for _, others := range dominant {
+		var tagged []int
+		for _, o := range others {
+			if name, _, _ := strings.Cut(promoted[o].JSONTag, ","); name != "" {
+				tagged = append(tagged, o)
+			}
+		}
+		// Fields declared in the same struct always collide
+		if len(others) > 1 && len(tagged) == 1 && depths[others[0]] > 0 {
+			for _, o := range others {
+				hidden[o] = o != tagged[0]
+			}
+		}
+	}
+
+	var res []Field
+	for i, f := range promoted {
+		if !hidden[i] {
+			res = append(res, f)
+		}
+	}
+	slices.SortStableFunc(res, func(a, b Field) int { return slices.Compare(a.Path, b.Path) })
+	return res
+}
+
+// Check applies every rule to the fields of a struct, collecting all problems rather than
+// stopping at the first one.
+func Check(fields []Field, limits Limits) Result {
+	res := Result{Extra: -1}
+	problem := func(field int, format string, args ...any) {
+		res.Problems = append(res.Problems, Problem{Field: field, Err: fmt.Errorf(format, args...)})
+	}
+
+	// column name -> field name, to detect two fields mapping to the same column
+	columns := make(map[string]string)
+
+	for i, f := range fields {
+		// Unexported fields cannot be read or set through reflection
+		if !f.Exported {
+			if f.JSONTag != "" || f.PkTag != "" || f.IndexTag != "" || f.OtsTag != "" {
+				problem(i, "field %s is unexported but has a json or pk tag; export it or remove the tags", f.Name)
+			}
+			continue
+		}
+
+		switch f.OtsTag {
+		case "":
+		case OtsExtra:
+			// The extra field holds the columns no other field maps to, so it has no column itself
+			switch {
+			case f.PkTag != "" || f.IndexTag != "" || f.HasPkPrefix:
+				problem(i, "field %s: the extra field can not have pk, pkprefix or index tags", f.Name)
+			case !f.Type.AnyMap:
+				problem(i, "field %s: the extra field must be a map[string]any, got %s", f.Name, f.Type.Name)
+			case f.FlattenDepth > 0:
+				problem(i, "field %s: the extra field can not be inside a flattened struct", f.Name)
+			case res.Extra >= 0:
+				problem(i, "field %s: ots tag %q is already used by field %s", f.Name, f.OtsTag, fields[res.Extra].Name)
+			default:
+				res.Extra = i
+			}
+			continue
+		case OtsFlatten:
+			// Promote replaced the flattened structs it could expand by their fields
+			switch {
+			case f.Column() == "":
+			case f.Type.BaseKind != KindStruct || f.Type.Pointers > 1:
+				problem(i, "field %s: ots tag \"flatten\" is only allowed on struct fields and pointers to them, got %s", f.Name, f.Type.Name)
+			case f.PkTag != "" || f.IndexTag != "" || f.HasPkPrefix || f.OtsTypeTag != "":
+				problem(i, "field %s: a flattened field can not have pk, pkprefix, index or otstype tags", f.Name)
+			default:
+				problem(i, "field %s: flattened structs can be nested at most %d levels deep", f.Name, MaxFlattenDepth)
+			}
+			continue
+		default:
+			problem(i, "field %s: unknown ots tag %q", f.Name, f.OtsTag)
+			continue
+		}
+
+		// Fields tagged `json:"-"` are not mapped to a column
+		column := f.Column()
+		if column == "" {
+			if f.PkTag != "" {
+				problem(i, "field %s has a pk tag but is excluded from the columns by its json tag", f.Name)
+			}
+			if f.IndexTag != "" {
+				problem(i, "field %s has an index tag but is excluded from the columns by its json tag", f.Name)
+			}
+			continue
+		}
+		if err := ValidateName("column", column, limits.MaxColumnNameSize); err != nil {
+			problem(i, "field %s: %w", f.Name, err)
+			continue
+		}
+		if other, ok := columns[column]; ok {
+			problem(i, "field %s maps to column %q, which is already used by field %s", f.Name, column, other)
+			continue
+		}
+		columns[column] = f.Name
+
+		// The primary key columns are those of the row struct itself
+		if f.FlattenDepth > 0 && (f.PkTag != "" || f.HasPkPrefix) {
+			problem(i, "field %s: pk tags are not allowed inside flattened structs", f.Name)
+			continue
+		}
+
+		r := FieldResult{Index: i, Column: column, IsPk: f.PkTag != "", OmitEmpty: HasOmitEmpty(f.JSONTag), OtsType: f.OtsTypeTag}
+		ok := true
+
+		switch {
+		case f.OtsTypeTag != "" && (f.OtsTypeTag != OtsTypeBinary || !f.Type.UUID):
+		case f.Type.Marshaler != f.Type.Unmarshaler:
+			implemented, missing := "OTSMarshaler", "OTSUnmarshaler"
+			if f.Type.Unmarshaler {
+				implemented, missing = missing, implemented
+			}
+			problem(i, "field %s: type %s implements %s but not %s, implement both or neither", f.Name, f.Type.Base, implemented, missing)
+			ok = false
+		case f.Type.Marshaler:
+			// The methods of the type handle any kind
+		case f.Type.UUID && f.Type.Pointers <= 1:
+			// Stored as a string, or as binary with `otstype:"binary"`, in any position
+		case f.Type.OtsNull:
+			if r.IsPk {
+				problem(i, "field %s: a Null field can not be a primary key field", f.Name)
+				ok = false
+			}
+		case f.Type.SQLNull && f.Type.Pointers <= 1:
+			// A primary key column can not be absent
+			if r.IsPk {
+				problem(i, "field %s: the database/sql null type %s can not be a primary key field", f.Name, f.Type.Base)
+				ok = false
+			}
+		case !f.Type.Native() && !f.Type.Serializable || r.IsPk && f.Type.Native() && !f.Type.NativeKey():
+			res.Problems = append(res.Problems, Problem{Field: i, Err: InvalidTypeError(f, r.IsPk)})
+			ok = false
+		}
+
+		switch f.OtsTypeTag {
+		case "":
+		case OtsTypeJSON:
+			// Any type is stored as its JSON encoding, which a key column can not hold reliably
+			if r.IsPk || f.HasPkPrefix || f.IndexTag != "" {
+				problem(i, "field %s: otstype %q can not be used with pk, pkprefix or index tags", f.Name, f.OtsTypeTag)
+				continue
+			}
+		case OtsTypeBinary:
+			if !f.Type.UUID || f.Type.Pointers > 1 {
+				problem(i, "field %s: otstype %q is only allowed on UUID fields, [16]byte types implementing encoding.TextMarshaler and encoding.TextUnmarshaler, got %s", f.Name, f.OtsTypeTag, f.Type.Name)
+				ok = false
+			}
+		default:
+			problem(i, "field %s: unknown otstype tag %q", f.Name, f.OtsTypeTag)
+			ok = false
+		}
+
+		if r.IsPk {
+			pk, err := ParsePkTag(f.PkTag)
+			if err != nil {
+				problem(i, "field %s: invalid pk tag %q: %w", f.Name, f.PkTag, err)
+				ok = false
+			}
+			r.Pk = pk
+		}
+
+		if f.HasPkPrefix {
+			prefix, err := ParsePkPrefix(f.PkPrefixTag)
+			switch {
+			case err != nil:
+				problem(i, "field %s: invalid pkprefix tag %q: %w", f.Name, f.PkPrefixTag, err)
+				ok = false
+			case !r.IsPk:
+				problem(i, "field %s: pkprefix is only allowed on primary key fields", f.Name)
+				ok = false
+			case f.Type.Name != "*string":
+				problem(i, "field %s: pkprefix is only allowed on *string fields, got %s", f.Name, f.Type.Name)
+				ok = false
+			}
+			r.Prefix = prefix
+		}
+
+		if f.IndexTag != "" {
+			indexes, err := ParseIndexTag(f.IndexTag)
+			switch {
+			case err != nil:
+				problem(i, "field %s: invalid index tag %q: %w", f.Name, f.IndexTag, err)
+				ok = false
+			case !f.Type.NativeKey():
+				problem(i, "field %s: index is only allowed on string, int64 and []byte fields, got %s", f.Name, f.Type.Name)
+				ok = false
+			}
+			r.Indexes = indexes
+		}
+
+		if !ok {
+			continue
+		}
+		res.Fields = append(res.Fields, r)
+		if r.IsPk {
+			res.PkFields = append(res.PkFields, len(res.Fields)-1)
+		} else {
+			res.AttrFields = append(res.AttrFields, len(res.Fields)-1)
+		}
+	}
+
+	if len(res.PkFields) > limits.MaxPrimaryKeyColumns {
+		names := make([]string, len(res.PkFields))
+		for n, i := range res.PkFields {
+			names[n] = fields[res.Fields[i].Index].Name
+		}
+		problem(-1, "%d pk-tagged fields %s exceed the maximum of %d", len(res.PkFields), strings.Join(names, ", "), limits.MaxPrimaryKeyColumns)
+	}
+
+	// Sort primary key fields by pk order in ascending order
+	sort.SliceStable(res.PkFields, func(i, j int) bool {
+		return res.Fields[res.PkFields[i]].Pk.Order < res.Fields[res.PkFields[j]].Pk.Order
+	})
+
+	// The service expects the primary key columns in schema order, so orders run from 1 without gaps
+	prevOrder := 0
+	for n, i := range res.PkFields {
+		r := res.Fields[i]
+		f := fields[r.Index]
+		if r.Pk.Order == 0 {
+			// The pk tag is invalid and already reported
+			continue
+		}
+		if n > 0 {
+			if prev := res.Fields[res.PkFields[n-1]]; prev.Pk.Order == r.Pk.Order {
+				problem(r.Index, "field %s: invalid pk tag %q: order %d is already used by field %s", f.Name, f.PkTag, r.Pk.Order, fields[prev.Index].Name)
+			}
+		}
+		if r.Pk.Order > prevOrder+1 {
+			problem(r.Index, "field %s: invalid pk tag %q: no field has pk order %d, pk orders must run from 1 without gaps", f.Name, f.PkTag, prevOrder+1)
+		}
+		prevOrder = r.Pk.Order
+		if r.Pk.Auto {
+			if n != len(res.PkFields)-1 {
+				problem(r.Index, "field %s: invalid pk tag %q: auto is only allowed on the last primary key field", f.Name, f.PkTag)
+			}
+			if f.Type.Pointers != 1 || f.Type.BaseKind != KindInt64 {
+				problem(r.Index, "field %s: invalid pk tag %q: auto is only allowed on *int64 fields, got %s", f.Name, f.PkTag, f.Type.Name)
+			}
+		}
+		if r.Pk.Gen != "" && (f.Type.Pointers != 1 || f.Type.BaseKind != KindString) {
+			problem(r.Index, "field %s: invalid pk tag %q: gen is only allowed on *string fields, got %s", f.Name, f.PkTag, f.Type.Name)
+		}
+	}
+
+	checkIndexLayout(fields, &res, problem)
+
+	return res
+}
+
+// checkIndexLayout checks that the fields of every index have distinct orders running from 1
+// without gaps.
+func checkIndexLayout(fields []Field, res *Result, problem func(field int, format string, args ...any)) {
+	// index name -> order -> field name
+	orders := make(map[string]map[int]string)
+	for _, r := range res.Fields {
+		f := fields[r.Index]
+		for _, idx := range r.Indexes {
+			if orders[idx.Name] == nil {
+				orders[idx.Name] = make(map[int]string)
+			}
+			if other, ok := orders[idx.Name][idx.Order]; ok {
+				problem(r.Index, "field %s: invalid index tag %q: order %d of index %s is already used by field %s", f.Name, f.IndexTag, idx.Order, idx.Name, other)
+				continue
+			}
+			orders[idx.Name][idx.Order] = f.Name
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(orders)) {
+		for order := 1; order <= len(orders[name]); order++ {
+			if _, ok := orders[name][order]; !ok {
+				problem(-1, "index %s has no field with order %d: index orders must run from 1 without gaps", name, order)
+				break
+			}
+		}
+	}
+}
+
+// ColumnName returns the column a field maps to: the name in its json tag, the field name
+// when the tag only carries options (`json:",omitempty"`), or untagged when the field has no
+// json tag. It returns "" for fields tagged `json:"-"`.
+func ColumnName(fieldName, jsonTag, untagged string) string {
+	if jsonTag == "" {
+		return untagged
+	}
+	if jsonTag == "-" {
+		return ""
+	}
+	name, _, _ := strings.Cut(jsonTag, ",")
+	if name == "" {
+		return fieldName
+	}
+	return name
+}
+
+// HasOmitEmpty reports whether the json tag carries the omitempty option.
+func HasOmitEmpty(jsonTag string) bool {
+	_, opts, _ := strings.Cut(jsonTag, ",")
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == "omitempty" {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateName checks a table or column name against the Tablestore naming rules: it must be
+// 1 to limit bytes of letters, digits and underscores, and must not start with a digit.
+// kind describes the name in errors, such as "column".
+func ValidateName(kind, name string, limit int) error {
+	if name == "" {
+		return fmt.Errorf("%s name is empty", kind)
+	}
+	if len(name) > limit {
+		return fmt.Errorf("%s name %q is %d bytes, exceeding the limit of %d bytes", kind, name, len(name), limit)
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9':
+			if i == 0 {
+				return fmt.Errorf("%s name %q must start with a letter or underscore", kind, name)
+			}
+		default:
+			return fmt.Errorf("%s name %q contains %q at byte %d; only letters, digits and underscores are allowed", kind, name, rune(c), i)
+		}
+	}
+	return nil
+}
+
+// InvalidTypeError describes why the type of f cannot be mapped to a column.
+// Primary key fields get different advice, since Tablestore only allows string, integer
+// and binary primary key columns.
+func InvalidTypeError(f Field, pk bool) error {
+	allowed := "string, int64, float64, and []byte fields and pointers to them"
+	if pk {
+		allowed = "string, int64, and []byte fields and pointers to them"
+	}
+	err := fmt.Errorf("field %s has invalid type: %s. Only %s are allowed", f.Name, f.Type.Name, allowed)
+	if hint := TypeHint(f.Type, pk); hint != "" {
+		err = fmt.Errorf("%w; %s", err, hint)
+	}
+	return err
+}
+
+// TypeHint suggests a supported type for the unsupported field type t.
+func TypeHint(t Type, pk bool) string {
+	switch t.BaseKind {
+	case KindInterface:
+		return "interface fields are not supported, use a concrete type"
+	case KindChan, KindFunc, KindUnsafePointer:
+		return fmt.Sprintf("%s fields cannot be stored in a column", t.BaseKind)
+	case KindMap:
+		return fmt.Sprintf("map fields are not supported, register a serializer for %s with RegisterTypeSerializer", t.Base)
+	case KindUint64:
+		return fmt.Sprintf("Tablestore integers are signed, use %sint64 instead of %s", t.pointerPrefix(), t.Name)
+	case KindFloat, KindFloat64:
+		if pk {
+			return fmt.Sprintf("primary key columns can only hold string, integer or binary values, so %s cannot be a primary key", t.Base)
+		}
+		return fmt.Sprintf("use %sfloat64 instead of %s", t.pointerPrefix(), t.Name)
+	case KindBool:
+		if pk {
+			return fmt.Sprintf("primary key columns can only hold string, integer or binary values, so %s cannot be a primary key", t.Base)
+		}
+	case KindString, KindInt64, KindInt, KindBytes:
+		return fmt.Sprintf("use *%s instead of %s", t.Base, t.Name)
+	case KindStruct:
+		return fmt.Sprintf("nested structs are not supported, register a serializer for %s with RegisterTypeSerializer", t.Base)
+	}
+	return fmt.Sprintf("register a serializer for %s with RegisterTypeSerializer", t.Base)
+}
+
+// pointerPrefix is "*" when the type is a pointer, the form its suggested replacement takes.
+func (t Type) pointerPrefix() string {
+	if t.Pointers > 0 {
+		return "*"
+	}
+	return ""
+}
+
+// PkTag is the parsed form of a pk struct tag.
+//
+// The grammar is `pk:"<order>[,auto|,gen=ulid]"`:
+//   - order is the 1-based position of the column in the table's primary key; the orders of a
+//     struct run from 1 without gaps and are compared as integers
+//   - auto marks an auto-increment column; only allowed on the last pk field, of type *int64
+//   - gen=ulid generates a ULID on PutRow when the field is nil; only allowed on *string fields
+type PkTag struct {
+	Order int
+	Auto  bool
+	Gen   string
+}
+
+// GenULID is the only supported value of the gen option.
+const GenULID = "ulid"
+
+// ParsePkTag parses the value of a pk struct tag.
+func ParsePkTag(tag string) (PkTag, error) {
+	parts := strings.Split(tag, ",")
+
+	order, err := strconv.Atoi(parts[0])
+	if err != nil || order < 1 {
+		return PkTag{}, fmt.Errorf("order %q must be a positive integer", parts[0])
+	}
+	parsed := PkTag{Order: order}
+
+	for _, opt := range parts[1:] {
+		switch {
+		case opt == "auto":
+			if parsed.Auto {
+				return PkTag{}, fmt.Errorf("option auto is repeated")
+			}
+			parsed.Auto = true
+		case strings.HasPrefix(opt, "gen="):
+			if parsed.Gen != "" {
+				return PkTag{}, fmt.Errorf("option gen is repeated")
+			}
+			parsed.Gen = strings.TrimPrefix(opt, "gen=")
+			if parsed.Gen != GenULID {
+				return PkTag{}, fmt.Errorf("unknown generator %q, only %q is supported", parsed.Gen, GenULID)
+			}
+		default:
+			return PkTag{}, fmt.Errorf("unknown option %q", opt)
+		}
+	}
+
+	if parsed.Auto && parsed.Gen != "" {
+		return PkTag{}, fmt.Errorf("options auto and gen are mutually exclusive")
+	}
+	return parsed, nil
+}
+
+// Prefix is the parsed form of a pkprefix struct tag, `pkprefix:"<hash>:<length>[,migrate]"`.
+type Prefix struct {
+	Hash    string
+	Length  int
+	Migrate bool
+}
+
+// PrefixHashes maps the hash names supported by pkprefix to functions returning the hex digest.
+var PrefixHashes = map[string]func(string) string{
+	"md5": func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	},
+	"sha1": func(s string) string {
+		sum := sha1.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	},
+	"sha256": func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	},
+}
+
+// ParsePkPrefix parses the value of a pkprefix struct tag.
+func ParsePkPrefix(tag string) (*Prefix, error) {
+	spec, opts, _ := strings.Cut(tag, ",")
+	hash, lengthStr, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, fmt.Errorf("want <hash>:<length>, got %q", spec)
+	}
+	hashFunc, ok := PrefixHashes[hash]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash %q, supported are %s", hash, strings.Join(slices.Sorted(maps.Keys(PrefixHashes)), ", "))
+	}
+	length, err := strconv.Atoi(lengthStr)
+	if maxLength := len(hashFunc("")); err != nil || length < 1 || length > maxLength {
+		return nil, fmt.Errorf("length %q must be an integer between 1 and %d", lengthStr, maxLength)
+	}
+
+	p := &Prefix{Hash: hash, Length: length}
+	if opts != "" {
+		for _, opt := range strings.Split(opts, ",") {
+			if opt != "migrate" {
+				return nil, fmt.Errorf("unknown option %q", opt)
+			}
+			p.Migrate = true
+		}
+	}
+	return p, nil
+}
+
+// IndexTag is one entry of an index struct tag, `index:"<name>,<order>[;<name>,<order>...]"`:
+// the field is the order-th primary key column, from 1, of the secondary index name.
+type IndexTag struct {
+	Name  string
+	Order int
+}
+
+// ParseIndexTag parses the value of an index struct tag.
+func ParseIndexTag(tag string) ([]IndexTag, error) {
+	var indexes []IndexTag
+	for _, entry := range strings.Split(tag, ";") {
+		name, orderStr, ok := strings.Cut(entry, ",")
+		if !ok {
+			return nil, fmt.Errorf("want <name>,<order>, got %q", entry)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("index name is empty")
+		}
+		order, err := strconv.Atoi(orderStr)
+		if err != nil || order < 1 {
+			return nil, fmt.Errorf("order %q must be a positive integer", orderStr)
+		}
+		for _, idx := range indexes {
+			if idx.Name == name {
+				return nil, fmt.Errorf("index %s is repeated", name)
+			}
+		}
+		indexes = append(indexes, IndexTag{Name: name, Order: order})
+	}
+	return indexes, nil
+}
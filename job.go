@@ -0,0 +1,279 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// JobListener receives the lifecycle events of the long-running helpers: CopyTable, DeleteRange,
+// TruncateTable and ParallelScan, each of which accepts one in its options. The callbacks of a
+// job are called one at a time, in order, from a goroutine of their own, so implementations need
+// no locking; a panicking callback is recovered and logged. The helper returns once JobFinished
+// returned, and a slow listener slows the job down.
+type JobListener interface {
+	// JobStarted is called first, before any request is sent.
+	JobStarted(job JobInfo)
+
+	// ChunkCompleted is called after each unit of work: a batch written by CopyTable or
+	// TruncateTable, a page of DeleteRange, a page of a split of ParallelScan.
+	ChunkCompleted(job JobInfo, chunk JobChunk)
+
+	// Retrying is called before rows the service throttled are sent again.
+	Retrying(job JobInfo, retry JobRetry)
+
+	// CheckpointSaved is called after ParallelScan saved the state of a split.
+	CheckpointSaved(job JobInfo, split int, state SplitCheckpoint)
+
+	// JobFinished is called last, also when the job failed.
+	JobFinished(job JobInfo, report JobReport)
+}
+
+// JobInfo identifies a job.
+type JobInfo struct {
+	// Name is the helper running the job, e.g. "CopyTable".
+	Name string
+
+	// TableName is the table the job reads.
+	TableName string
+
+	// Started is the time the job started.
+	Started time.Time
+}
+
+// JobChunk describes a completed unit of work of a job.
+type JobChunk struct {
+	// Split is the split of a ParallelScan, zero for the other helpers.
+	Split int
+
+	// Rows is the number of rows the chunk copied, deleted or read.
+	Rows int
+
+	// ConsumedCapacity is the capacity consumed by the requests of the chunk.
+	ConsumedCapacity tablestore.ConsumedCapacityUnit
+
+	// Duration is the time the chunk took.
+	Duration time.Duration
+}
+
+// JobRetry describes rows about to be sent again.
+type JobRetry struct {
+	// Attempt is the number of consecutive attempts that applied none of the rows, zero when
+	// the last one applied some of them.
+	Attempt int
+
+	// Rows is the number of rows sent again.
+	Rows int
+
+	// Backoff is the pause before they are sent.
+	Backoff time.Duration
+
+	// Err is the error of the last attempt.
+	Err error
+}
+
+// JobReport is the outcome of a job.
+type JobReport struct {
+	// Rows is the sum of the rows of the chunks.
+	Rows int64
+
+	// ConsumedCapacity is the capacity consumed by every request of the job.
+	ConsumedCapacity tablestore.ConsumedCapacityUnit
+
+	// Duration is the time the job took.
+	Duration time.Duration
+
+	// Err is the error the helper returned, nil on success.
+	Err error
+}
+
+// LogJobListener is the JobListener used when none is given: it logs the start and the end of a
+// job at info level, retries at warn level and the other events at debug level.
+type LogJobListener struct {
+	Logger *zerolog.Logger
+}
+
+// JobStarted implements JobListener.
+func (l *LogJobListener) JobStarted(job JobInfo) {
+	l.Logger.Info().Str("job", job.Name).Str("table", job.TableName).Msg("Job started")
+}
+
+// ChunkCompleted implements JobListener.
+func (l *LogJobListener) ChunkCompleted(job JobInfo, chunk JobChunk) {
+	l.Logger.Debug().Str("job", job.Name).Str("table", job.TableName).
+		Int("split", chunk.Split).Int("rows", chunk.Rows).
+		Int32("read_cu", chunk.ConsumedCapacity.Read).Int32("write_cu", chunk.ConsumedCapacity.Write).
+		Dur("duration", chunk.Duration).Msg("Job chunk completed")
+}
+
+// Retrying implements JobListener.
+func (l *LogJobListener) Retrying(job JobInfo, retry JobRetry) {
+	l.Logger.Warn().Str("job", job.Name).Str("table", job.TableName).Err(retry.Err).
+		Int("attempt", retry.Attempt).Int("rows", retry.Rows).Dur("backoff", retry.Backoff).
+		Msg("Job retrying throttled rows")
+}
+
+// CheckpointSaved implements JobListener.
+func (l *LogJobListener) CheckpointSaved(job JobInfo, split int, state SplitCheckpoint) {
+	l.Logger.Debug().Str("job", job.Name).Str("table", job.TableName).
+		Int("split", split).Bool("done", state.Done).Msg("Job checkpoint saved")
+}
+
+// JobFinished implements JobListener.
+func (l *LogJobListener) JobFinished(job JobInfo, report JobReport) {
+	e := l.Logger.Info()
+	if report.Err != nil {
+		e = l.Logger.Error().Err(report.Err)
+	}
+	e.Str("job", job.Name).Str("table", job.TableName).Int64("rows", report.Rows).
+		Int32("read_cu", report.ConsumedCapacity.Read).Int32("write_cu", report.ConsumedCapacity.Write).
+		Dur("duration", report.Duration).Msg("Job finished")
+}
+
+// jobCtxKey is the context key of the running job, so that the batch writes of a helper report
+// their retries to it.
+type jobCtxKey struct{}
+
+// jobRun passes the events of a job to its listener, one at a time, from its own goroutine.
+type jobRun struct {
+	info     JobInfo
+	listener JobListener
+	logger   *zerolog.Logger
+	events   chan func(JobListener)
+	done     chan struct{}
+
+	mu     sync.Mutex
+	report JobReport
+}
+
+// startJob starts the job name on the table of ctx, reporting to listener or, when it is nil, to
+// a LogJobListener, and returns ctx carrying the job.
+func startJob(ctx context.Context, name string, listener JobListener) (context.Context, *jobRun) {
+	otsParams := otsUtilsParamsFromCtx(ctx)
+	logger := otsParams.baseLogger(ctx)
+	if listener == nil {
+		listener = &LogJobListener{Logger: logger}
+	}
+	j := &jobRun{
+		info:     JobInfo{Name: name, TableName: otsParams.TableName, Started: time.Now()},
+		listener: listener,
+		logger:   logger,
+		events:   make(chan func(JobListener), 64),
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(j.done)
+		for event := range j.events {
+			j.call(event)
+		}
+	}()
+	j.events <- func(l JobListener) { l.JobStarted(j.info) }
+	return context.WithValue(ctx, jobCtxKey{}, j), j
+}
+
+// jobFromCtx returns the job of ctx, nil outside of one.
+func jobFromCtx(ctx context.Context) *jobRun {
+	j, _ := ctx.Value(jobCtxKey{}).(*jobRun)
+	return j
+}
+
+// call runs event, recovering a panic of the listener.
+func (j *jobRun) call(event func(JobListener)) {
+	defer func() {
+		if r := recover(); r != nil {
+			j.logger.Error().Interface("panic", r).Str("job", j.info.Name).Msg("JobListener panicked")
+		}
+	}()
+	event(j.listener)
+}
+
+// chunk records a completed unit of work. It does nothing on a nil job.
+func (j *jobRun) chunk(split, rows int, cu tablestore.ConsumedCapacityUnit, started time.Time) {
+	if j == nil {
+		return
+	}
+	c := JobChunk{Split: split, Rows: rows, ConsumedCapacity: cu, Duration: time.Since(started)}
+	j.mu.Lock()
+	j.report.Rows += int64(rows)
+	addCapacity(&j.report.ConsumedCapacity, &cu)
+	j.mu.Unlock()
+	j.events <- func(l JobListener) { l.ChunkCompleted(j.info, c) }
+}
+
+// consumed records capacity consumed outside of a chunk, e.g. by the scan feeding the batches.
+func (j *jobRun) consumed(cu *tablestore.ConsumedCapacityUnit) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	addCapacity(&j.report.ConsumedCapacity, cu)
+	j.mu.Unlock()
+}
+
+// retry reports rows about to be sent again. It does nothing on a nil job.
+func (j *jobRun) retry(r JobRetry) {
+	if j == nil {
+		return
+	}
+	j.events <- func(l JobListener) { l.Retrying(j.info, r) }
+}
+
+// checkpoint reports a saved split state.
+func (j *jobRun) checkpoint(split int, state SplitCheckpoint) {
+	j.events <- func(l JobListener) { l.CheckpointSaved(j.info, split, state) }
+}
+
+// finish reports the end of the job and waits for the listener to handle every event. No event
+// may follow.
+func (j *jobRun) finish(err error) {
+	j.mu.Lock()
+	report := j.report
+	j.mu.Unlock()
+	report.Duration, report.Err = time.Since(j.info.Started), err
+	j.events <- func(l JobListener) { l.JobFinished(j.info, report) }
+	close(j.events)
+	<-j.done
+}
+
+// backgroundJob runs a helper on a goroutine of its own, for the job types whose Close stops it.
+// run reads no new work once stop is closed, and ctx is cancelled when Close gives up waiting.
+type backgroundJob[R any] struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+	cancel   context.CancelFunc
+	done     chan struct{}
+	result   R
+	err      error
+}
+
+func startBackgroundJob[R any](ctx context.Context, run func(ctx context.Context, stop <-chan struct{}) (R, error)) *backgroundJob[R] {
+	ctx, cancel := context.WithCancel(ctx)
+	b := &backgroundJob[R]{stop: make(chan struct{}), cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(b.done)
+		defer cancel()
+		b.result, b.err = run(ctx, b.stop)
+	}()
+	return b
+}
+
+func (b *backgroundJob[R]) wait() (R, error) {
+	<-b.done
+	return b.result, b.err
+}
+
+func (b *backgroundJob[R]) close(ctx context.Context) error {
+	b.stopOnce.Do(func() { close(b.stop) })
+	select {
+	case <-b.done:
+		return b.err
+	case <-ctx.Done():
+		b.cancel()
+		<-b.done
+		return ctx.Err()
+	}
+}
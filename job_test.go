@@ -0,0 +1,157 @@
+package otsutils
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingListener 记录收到的事件，不加锁：并发调用会被 race 检测发现
+type recordingListener struct {
+	events      []string
+	chunks      []JobChunk
+	retries     []JobRetry
+	checkpoints []SplitCheckpoint
+	report      JobReport
+	panicOn     string
+}
+
+func (l *recordingListener) record(event string) {
+	l.events = append(l.events, event)
+	if event == l.panicOn {
+		panic("listener failure")
+	}
+}
+
+func (l *recordingListener) JobStarted(job JobInfo) { l.record("start") }
+
+func (l *recordingListener) ChunkCompleted(job JobInfo, chunk JobChunk) {
+	l.chunks = append(l.chunks, chunk)
+	l.record("chunk")
+}
+
+func (l *recordingListener) Retrying(job JobInfo, retry JobRetry) {
+	l.retries = append(l.retries, retry)
+	l.record("retry")
+}
+
+func (l *recordingListener) CheckpointSaved(job JobInfo, split int, state SplitCheckpoint) {
+	l.checkpoints = append(l.checkpoints, state)
+	l.record("checkpoint")
+}
+
+func (l *recordingListener) JobFinished(job JobInfo, report JobReport) {
+	l.report = report
+	l.record("finish")
+}
+
+func TestJobListener(t *testing.T) {
+	t.Run("copy reports chunks and the report", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", MaxBatchWriteRows+20)
+		dst, _ := newCopyDestination(t)
+
+		l := &recordingListener{}
+		report, err := CopyTable(ctx, OtsUtilsParamsFromCtx(ctx), dst, CopyOptions{Workers: 2, Listener: l})
+		ast.NoError(err)
+		ast.Equal([]string{"start", "chunk", "chunk", "finish"}, l.events)
+		ast.Equal(report.RowsCopied, l.report.Rows)
+		ast.Equal(int32(MaxBatchWriteRows+20), l.report.ConsumedCapacity.Write)
+		ast.Equal(int32(MaxBatchWriteRows+20), l.report.ConsumedCapacity.Read)
+		ast.NoError(l.report.Err)
+		for _, chunk := range l.chunks {
+			ast.Positive(chunk.Rows)
+			ast.Equal(int32(chunk.Rows), chunk.ConsumedCapacity.Write)
+		}
+	})
+
+	t.Run("truncate reports retries", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 10)
+
+		// 第一次发送被限流
+		var calls atomic.Int32
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "BatchWriteRow" && calls.Add(1) == 1 {
+				return &tablestore.OtsError{Code: CodeNotEnoughCapacityUnit, Message: "Remaining capacity unit is not enough."}
+			}
+			return nil
+		}
+		l := &recordingListener{}
+		n, err := TruncateTable(ctx, TruncateTableParams{RetryBackoff: time.Millisecond, Listener: l})
+		ast.NoError(err)
+		ast.Equal(10, n)
+		ast.Equal([]string{"start", "retry", "chunk", "finish"}, l.events)
+		if ast.Len(l.retries, 1) {
+			ast.Equal(JobRetry{Attempt: 1, Rows: 10, Backoff: 2 * time.Millisecond, Err: l.retries[0].Err}, l.retries[0])
+			ast.Equal(CodeNotEnoughCapacityUnit, Code(l.retries[0].Err))
+		}
+		ast.Equal(int64(10), l.report.Rows)
+	})
+
+	t.Run("delete range reports each page", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", MaxBatchWriteRows+20)
+
+		l := &recordingListener{}
+		n, err := DeleteRange(ctx, &RangeRow{Pk1: tea.String("u1")}, DeleteRangeParams{Listener: l})
+		ast.NoError(err)
+		ast.Equal(MaxBatchWriteRows+20, n)
+		ast.Equal([]string{"start", "chunk", "chunk", "finish"}, l.events)
+		ast.Equal(MaxBatchWriteRows, l.chunks[0].Rows)
+		ast.Equal(int64(n), l.report.Rows)
+	})
+
+	t.Run("parallel scan reports checkpoints", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.SplitRows = 10
+		putRangeRows(t, ctx, "a", 15)
+
+		l := &recordingListener{}
+		checkpoint := &FileCheckpoint{Path: t.TempDir() + "/scan.json"}
+		rows, err := collectScan[RangeRow](ctx, ParallelScanOptions{Workers: 1, Checkpoint: checkpoint, Listener: l})
+		ast.NoError(err)
+		ast.Len(rows, 15)
+		ast.Equal([]string{"start", "chunk", "checkpoint", "chunk", "checkpoint", "finish"}, l.events)
+		ast.Equal(1, l.chunks[1].Split)
+		ast.True(l.checkpoints[1].Done)
+		ast.Equal(int64(15), l.report.Rows)
+		ast.Equal(int32(15), l.report.ConsumedCapacity.Read)
+	})
+
+	t.Run("listener panics are recovered", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 3)
+
+		l := &recordingListener{panicOn: "chunk"}
+		n, err := TruncateTable(ctx, TruncateTableParams{Listener: l})
+		ast.NoError(err)
+		ast.Equal(3, n)
+		ast.Equal([]string{"start", "chunk", "finish"}, l.events)
+	})
+
+	t.Run("default listener logs", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 3)
+		var buf bytes.Buffer
+		logger := zerolog.New(&buf).Level(zerolog.InfoLevel)
+
+		_, err := TruncateTable(WithLogger(ctx, &logger))
+		ast.NoError(err)
+		ast.Contains(buf.String(), `"job":"TruncateTable"`)
+		ast.Contains(buf.String(), `"message":"Job started"`)
+		ast.Contains(buf.String(), `"rows":3`)
+	})
+}
@@ -0,0 +1,226 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// Value type names of the JSON form of KeyValue, VersionedValue and the schema types.
+// Together with the value they let int64, string and []byte values survive a round trip.
+const (
+	JSONTypeString  = "string"
+	JSONTypeInteger = "integer"
+	JSONTypeBinary  = "binary"
+	JSONTypeDouble  = "double"
+	JSONTypeBoolean = "boolean"
+	JSONTypeNull    = "null"
+)
+
+// encodeTypedValue returns the type name and JSON encoded value of a column value.
+// Binary values are encoded as standard base64.
+func encodeTypedValue(v any) (string, json.RawMessage, error) {
+	var typ string
+	switch x := v.(type) {
+	case string:
+		typ = JSONTypeString
+	case int64:
+		typ = JSONTypeInteger
+	case int:
+		typ, v = JSONTypeInteger, int64(x)
+	case int32:
+		typ, v = JSONTypeInteger, int64(x)
+	case []byte:
+		typ = JSONTypeBinary
+	case float64:
+		typ = JSONTypeDouble
+	case bool:
+		typ = JSONTypeBoolean
+	case nil:
+		return JSONTypeNull, json.RawMessage("null"), nil
+	default:
+		return "", nil, fmt.Errorf("unsupported value type %T", v)
+	}
+	raw, err := json.Marshal(v)
+	return typ, raw, err
+}
+
+// decodeTypedValue decodes a value encoded by encodeTypedValue into the Go type the rest of
+// the package uses for typ: string, int64, []byte, float64 or bool.
+func decodeTypedValue(typ string, raw json.RawMessage) (any, error) {
+	var err error
+	switch typ {
+	case JSONTypeString:
+		var s string
+		err = json.Unmarshal(raw, &s)
+		return s, err
+	case JSONTypeInteger:
+		// Parsed from the literal so that values beyond 2^53 keep their precision
+		return strconv.ParseInt(string(raw), 10, 64)
+	case JSONTypeBinary:
+		var b []byte
+		err = json.Unmarshal(raw, &b)
+		return b, err
+	case JSONTypeDouble:
+		var f float64
+		err = json.Unmarshal(raw, &f)
+		return f, err
+	case JSONTypeBoolean:
+		var b bool
+		err = json.Unmarshal(raw, &b)
+		return b, err
+	case JSONTypeNull:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown value type %q", typ)
+	}
+}
+
+// keyValueJSON is the JSON form of KeyValue.
+type keyValueJSON struct {
+	Key   string          `json:"key"`
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// MarshalJSON encodes kv as {"key": ..., "type": ..., "value": ...}, where type is one of the
+// JSONType constants and binary values are base64 strings.
+func (kv KeyValue) MarshalJSON() ([]byte, error) {
+	typ, raw, err := encodeTypedValue(kv.Value)
+	if err != nil {
+		return nil, fmt.Errorf("key %q: %w", kv.Key, err)
+	}
+	return json.Marshal(keyValueJSON{Key: kv.Key, Type: typ, Value: raw})
+}
+
+// UnmarshalJSON decodes the form written by MarshalJSON, restoring the Go type of the value.
+func (kv *KeyValue) UnmarshalJSON(data []byte) error {
+	var j keyValueJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	value, err := decodeTypedValue(j.Type, j.Value)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", j.Key, err)
+	}
+	*kv = KeyValue{Key: j.Key, Value: value}
+	return nil
+}
+
+// versionedValueJSON is the JSON form of VersionedValue.
+type versionedValueJSON struct {
+	Type      string          `json:"type"`
+	Value     json.RawMessage `json:"value"`
+	Timestamp int64           `json:"timestamp"`
+}
+
+// MarshalJSON encodes v as {"type": ..., "value": ..., "timestamp": ...}, like KeyValue.
+func (v VersionedValue) MarshalJSON() ([]byte, error) {
+	typ, raw, err := encodeTypedValue(v.Value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(versionedValueJSON{Type: typ, Value: raw, Timestamp: v.Timestamp})
+}
+
+// UnmarshalJSON decodes the form written by MarshalJSON, restoring the Go type of the value.
+func (v *VersionedValue) UnmarshalJSON(data []byte) error {
+	var j versionedValueJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	value, err := decodeTypedValue(j.Type, j.Value)
+	if err != nil {
+		return err
+	}
+	*v = VersionedValue{Value: value, Timestamp: j.Timestamp}
+	return nil
+}
+
+var primaryKeyTypeNames = map[tablestore.PrimaryKeyType]string{
+	tablestore.PrimaryKeyType_STRING:  JSONTypeString,
+	tablestore.PrimaryKeyType_INTEGER: JSONTypeInteger,
+	tablestore.PrimaryKeyType_BINARY:  JSONTypeBinary,
+}
+
+var definedColumnTypeNames = map[tablestore.DefinedColumnType]string{
+	tablestore.DefinedColumn_STRING:  JSONTypeString,
+	tablestore.DefinedColumn_INTEGER: JSONTypeInteger,
+	tablestore.DefinedColumn_BINARY:  JSONTypeBinary,
+	tablestore.DefinedColumn_DOUBLE:  JSONTypeDouble,
+	tablestore.DefinedColumn_BOOLEAN: JSONTypeBoolean,
+}
+
+// typeByName returns the key of names whose value is name.
+func typeByName[T comparable](names map[T]string, name string) (T, error) {
+	for t, n := range names {
+		if n == name {
+			return t, nil
+		}
+	}
+	var zero T
+	return zero, fmt.Errorf("unknown column type %q", name)
+}
+
+// primaryKeySchemaJSON is the JSON form of PrimaryKeySchema.
+type primaryKeySchemaJSON struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	AutoIncrement bool   `json:"autoIncrement,omitempty"`
+}
+
+// MarshalJSON encodes s as {"name": ..., "type": ..., "autoIncrement": true}, where type is
+// one of JSONTypeString, JSONTypeInteger and JSONTypeBinary and autoIncrement is omitted when false.
+func (s PrimaryKeySchema) MarshalJSON() ([]byte, error) {
+	typ, ok := primaryKeyTypeNames[s.Type]
+	if !ok {
+		return nil, fmt.Errorf("primary key %q: unknown column type %d", s.Name, s.Type)
+	}
+	return json.Marshal(primaryKeySchemaJSON{Name: s.Name, Type: typ, AutoIncrement: s.AutoIncrement})
+}
+
+// UnmarshalJSON decodes the form written by MarshalJSON.
+func (s *PrimaryKeySchema) UnmarshalJSON(data []byte) error {
+	var j primaryKeySchemaJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	typ, err := typeByName(primaryKeyTypeNames, j.Type)
+	if err != nil {
+		return fmt.Errorf("primary key %q: %w", j.Name, err)
+	}
+	*s = PrimaryKeySchema{Name: j.Name, Type: typ, AutoIncrement: j.AutoIncrement}
+	return nil
+}
+
+// definedColumnJSON is the JSON form of DefinedColumn.
+type definedColumnJSON struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// MarshalJSON encodes c as {"name": ..., "type": ...}, where type is one of the JSONType constants.
+func (c DefinedColumn) MarshalJSON() ([]byte, error) {
+	typ, ok := definedColumnTypeNames[c.Type]
+	if !ok {
+		return nil, fmt.Errorf("defined column %q: unknown column type %d", c.Name, c.Type)
+	}
+	return json.Marshal(definedColumnJSON{Name: c.Name, Type: typ})
+}
+
+// UnmarshalJSON decodes the form written by MarshalJSON.
+func (c *DefinedColumn) UnmarshalJSON(data []byte) error {
+	var j definedColumnJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	typ, err := typeByName(definedColumnTypeNames, j.Type)
+	if err != nil {
+		return fmt.Errorf("defined column %q: %w", j.Name, err)
+	}
+	*c = DefinedColumn{Name: j.Name, Type: typ}
+	return nil
+}
@@ -0,0 +1,113 @@
+package otsutils
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+// updateGolden 为 true 时重写 golden 文件：OTSUTILS_UPDATE_GOLDEN=1 go test -run TestJSONGolden
+var updateGolden = os.Getenv("OTSUTILS_UPDATE_GOLDEN") != ""
+
+func TestJSONGolden(t *testing.T) {
+	cases := []struct {
+		name  string
+		value any
+		// decode 返回指向新零值的指针，用于反序列化后与 value 比较
+		decode func() any
+	}{
+		{
+			name: "keyvalues",
+			value: []KeyValue{
+				{Key: "pk1", Value: "a"},
+				{Key: "pk2", Value: int64(math.MaxInt64)},
+				{Key: "pk3", Value: []byte{0, 1, 0xfe, 0xff}},
+				{Key: "score", Value: 1.5},
+				{Key: "active", Value: true},
+				{Key: "missing", Value: nil},
+			},
+			decode: func() any { return &[]KeyValue{} },
+		},
+		{
+			name: "versions",
+			value: map[string][]VersionedValue{
+				"col1": {{Value: "v2", Timestamp: 2000}, {Value: "v1", Timestamp: 1000}},
+				"col2": {{Value: int64(-1), Timestamp: 1000}},
+			},
+			decode: func() any { return &map[string][]VersionedValue{} },
+		},
+		{
+			name: "table_description",
+			value: TableDescription{
+				TableName: "users",
+				PrimaryKeys: []PrimaryKeySchema{
+					{Name: "pk1", Type: tablestore.PrimaryKeyType_STRING},
+					{Name: "pk2", Type: tablestore.PrimaryKeyType_INTEGER, AutoIncrement: true},
+					{Name: "pk3", Type: tablestore.PrimaryKeyType_BINARY},
+				},
+				DefinedColumns: []DefinedColumn{
+					{Name: "name", Type: tablestore.DefinedColumn_STRING},
+					{Name: "age", Type: tablestore.DefinedColumn_INTEGER},
+					{Name: "score", Type: tablestore.DefinedColumn_DOUBLE},
+					{Name: "active", Type: tablestore.DefinedColumn_BOOLEAN},
+					{Name: "avatar", Type: tablestore.DefinedColumn_BINARY},
+				},
+				TimeToLive:  -1,
+				MaxVersions: 3,
+			},
+			decode: func() any { return &TableDescription{} },
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ast := assert.New(t)
+			data, err := json.MarshalIndent(c.value, "", "  ")
+			ast.NoError(err)
+			data = append(data, '\n')
+
+			golden := filepath.Join("testdata", "json", c.name+".golden")
+			if updateGolden {
+				ast.NoError(os.WriteFile(golden, data, 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			ast.NoError(err)
+			ast.Equal(string(want), string(data))
+
+			// 反序列化后值的 Go 类型保持不变
+			out := c.decode()
+			ast.NoError(json.Unmarshal(want, out))
+			ast.Equal(c.value, reflect.ValueOf(out).Elem().Interface())
+		})
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	ast := assert.New(t)
+
+	_, err := json.Marshal(KeyValue{Key: "col", Value: struct{}{}})
+	ast.ErrorContains(err, `key "col": unsupported value type struct {}`)
+
+	// int 与 int32 按 integer 编码，反序列化为 int64
+	data, err := json.Marshal(KeyValue{Key: "n", Value: 7})
+	ast.NoError(err)
+	ast.JSONEq(`{"key":"n","type":"integer","value":7}`, string(data))
+	var kv KeyValue
+	ast.NoError(json.Unmarshal(data, &kv))
+	ast.Equal(KeyValue{Key: "n", Value: int64(7)}, kv)
+
+	ast.ErrorContains(json.Unmarshal([]byte(`{"key":"k","type":"decimal","value":1}`), &kv), `key "k": unknown value type "decimal"`)
+	ast.Error(json.Unmarshal([]byte(`{"key":"k","type":"integer","value":1.5}`), &kv))
+	ast.Error(json.Unmarshal([]byte(`{"key":"k","type":"binary","value":"!!"}`), &kv))
+
+	var pk PrimaryKeySchema
+	ast.ErrorContains(json.Unmarshal([]byte(`{"name":"pk1","type":"double"}`), &pk), `primary key "pk1": unknown column type "double"`)
+	_, err = json.Marshal(DefinedColumn{Name: "c", Type: 42})
+	ast.ErrorContains(err, `defined column "c": unknown column type 42`)
+}
@@ -0,0 +1,155 @@
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+// TestClose 检查每个拥有 goroutine 的类型在 Close 之后不遗留 goroutine
+func TestClose(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	t.Run("iterators", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newSearchContext(t)
+		fake.MaxRangeRows = 2
+
+		rangeIt := NewRangeIterator[SearchRow](ctx, nil, nil)
+		ast.True(rangeIt.Next())
+		searchIt := NewSearchIterator[SearchRow](ctx, "search_index", &search.MatchAllQuery{}, SearchParams{Limit: 2})
+		ast.True(searchIt.Next())
+		ast.NoError(rangeIt.Close(ctx))
+		ast.NoError(searchIt.Close(ctx))
+	})
+
+	t.Run("parallel scanner saves the rows received", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.SplitRows = 10
+		putRangeRows(t, ctx, "a", 25)
+		opts := ParallelScanOptions{Workers: 1, PageSize: 5, ProgressInterval: 100, Checkpoint: &FileCheckpoint{Path: t.TempDir() + "/scan.json"}}
+
+		scanner := StartParallelScan[RangeRow](ctx, opts)
+		seen := make(map[string]int)
+		for row := range scanner.Rows() {
+			seen[fmt.Sprintf("%s-%02d", tea.StringValue(row.Pk1), tea.Int64Value(row.Pk2))]++
+			if len(seen) == 7 {
+				break
+			}
+		}
+		ast.NoError(scanner.Close(ctx))
+		ast.NoError(scanner.Close(ctx))
+
+		// 恢复后从收到的最后一行之后继续，不重复也不遗漏
+		scanner = StartParallelScan[RangeRow](ctx, opts)
+		for row := range scanner.Rows() {
+			seen[fmt.Sprintf("%s-%02d", tea.StringValue(row.Pk1), tea.Int64Value(row.Pk2))]++
+		}
+		ast.NoError(scanner.Wait())
+		ast.NoError(scanner.Close(ctx))
+		ast.Len(seen, 25)
+		for key, n := range seen {
+			ast.Equal(1, n, key)
+		}
+	})
+
+	t.Run("copy job flushes the rows read", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 5*MaxBatchWriteRows)
+		dst, _ := newCopyDestination(t)
+
+		// 读取第二页时关闭：已读的两页写完后停止
+		jobs := make(chan *CopyJob, 1)
+		closed := make(chan error, 1)
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "GetRange" && fake.CallCount("GetRange") == 2 {
+				job := <-jobs
+				go func() { closed <- job.Close(ctx) }()
+				time.Sleep(20 * time.Millisecond)
+			}
+			return nil
+		}
+		job := StartCopyTable(ctx, OtsUtilsParamsFromCtx(ctx), dst, CopyOptions{Workers: 1})
+		jobs <- job
+		ast.NoError(<-closed)
+		report, err := job.Wait()
+		ast.NoError(err)
+		ast.Equal(int64(2*MaxBatchWriteRows), report.RowsCopied)
+		ast.NoError(job.Close(ctx))
+	})
+
+	t.Run("close gives up at the deadline", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", MaxBatchWriteRows+3)
+		dst, dstFake := newCopyDestination(t)
+		started := make(chan struct{}, 1)
+		dstFake.Intercept = func(operation string, request any) error {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}
+
+		// 第一批写入期间关闭，等待超时后第二批不再发送
+		job := StartCopyTable(ctx, OtsUtilsParamsFromCtx(ctx), dst, CopyOptions{Workers: 1})
+		<-started
+		closeCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		ast.ErrorIs(job.Close(closeCtx), context.DeadlineExceeded)
+		report, err := job.Wait()
+		ast.ErrorIs(err, context.Canceled)
+		ast.Equal(int64(MaxBatchWriteRows), report.RowsCopied)
+		ast.Equal(1, dstFake.CallCount("BatchWriteRow"))
+	})
+
+	t.Run("truncate job", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 3)
+
+		job := StartTruncateTable(ctx)
+		n, err := job.Wait()
+		ast.NoError(err)
+		ast.Equal(3, n)
+		ast.NoError(job.Close(ctx))
+		ast.NoError(job.Close(ctx))
+	})
+
+	t.Run("pipe stopping a parallel scan", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 50)
+		dst, _ := newCopyDestination(t)
+
+		bad := errors.New("bad row")
+		_, err := Pipe(ctx, ParallelScanSource[*RangeRow](ParallelScanOptions{}), func(obj any) (any, bool, error) {
+			return nil, false, bad
+		}, &BatchWriteSink{Params: dst}, PipeOptions{BatchSize: 1})
+		ast.ErrorIs(err, ErrTooManyTransformFailures)
+		ast.ErrorIs(err, bad)
+	})
+
+	t.Run("search source", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSearchContext(t)
+		dst, _ := newCopyDestination(t)
+
+		_, err := Pipe(ctx, SearchSource[*SearchRow]("search_index", &search.MatchAllQuery{}), func(obj any) (any, bool, error) {
+			return nil, false, &tablestore.OtsError{Code: CodeParameterInvalid}
+		}, &BatchWriteSink{Params: dst}, PipeOptions{BatchSize: 1, Workers: 1})
+		ast.ErrorIs(err, ErrTooManyTransformFailures)
+	})
+}
@@ -0,0 +1,130 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tablestore size limits, checked before a request is sent so that oversized rows fail
+// with an error naming the column instead of a service error.
+const (
+	// MaxPrimaryKeyColumns is the maximum number of primary key columns of a table.
+	MaxPrimaryKeyColumns = 4
+
+	// MaxPrimaryKeyStringSize is the maximum size in bytes of a string primary key value.
+	MaxPrimaryKeyStringSize = 1024
+
+	// MaxPrimaryKeyBinarySize is the maximum size in bytes of a binary primary key value.
+	MaxPrimaryKeyBinarySize = 1024
+
+	// MaxPrimaryKeySize is the maximum size in bytes of a whole primary key: the names and
+	// values of all its columns.
+	MaxPrimaryKeySize = 4 * 1024
+
+	// MaxColumnValueSize is the maximum size in bytes of a string or binary attribute value.
+	MaxColumnValueSize = 2 * 1024 * 1024
+
+	// MaxColumnNameSize is the maximum size in bytes of a column name.
+	MaxColumnNameSize = 255
+
+	// MaxTableNameSize is the maximum size in bytes of a table name.
+	MaxTableNameSize = 255
+
+	// MaxRequestSize is the maximum size in bytes of a single request.
+	MaxRequestSize = 4 * 1024 * 1024
+
+	// MaxBatchWriteRows is the maximum number of row changes in a BatchWriteRow request.
+	MaxBatchWriteRows = 200
+
+	// MaxBatchGetRows is the maximum number of rows in a BatchGetRow request.
+	MaxBatchGetRows = 100
+)
+
+// validateRowLimits checks the primary key and attribute columns of a row against the
+// Tablestore size limits.
+func validateRowLimits(pks []KeyValue, cols []KeyValue) error {
+	if len(pks) > MaxPrimaryKeyColumns {
+		return fmt.Errorf("row has %d primary key columns %s, the maximum is %d", len(pks), keyNames(pks), MaxPrimaryKeyColumns)
+	}
+	for _, pk := range pks {
+		if err := validatePrimaryKeyValue(pk.Key, pk.Value); err != nil {
+			return err
+		}
+	}
+	if size := primaryKeySize(pks); size > MaxPrimaryKeySize {
+		return fmt.Errorf("primary key %s is %d bytes, exceeding the limit of %d bytes", keyNames(pks), size, MaxPrimaryKeySize)
+	}
+	for _, col := range cols {
+		if err := validateColumnValue(col.Key, col.Value); err != nil {
+			return err
+		}
+	}
+	if size := EstimateRowSize(pks, cols); size > MaxRequestSize {
+		return fmt.Errorf("row is about %d bytes, exceeding the request limit of %d bytes", size, MaxRequestSize)
+	}
+	return nil
+}
+
+// validatePrimaryKeyValue checks the name and value size of a single primary key column.
+func validatePrimaryKeyValue(name string, value any) error {
+	if err := validateName("primary key", name); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case string:
+		if len(v) > MaxPrimaryKeyStringSize {
+			return fmt.Errorf("primary key %q is %d bytes, exceeding the limit of %d bytes", name, len(v), MaxPrimaryKeyStringSize)
+		}
+	case []byte:
+		if len(v) > MaxPrimaryKeyBinarySize {
+			return fmt.Errorf("primary key %q is %d bytes, exceeding the limit of %d bytes for binary values", name, len(v), MaxPrimaryKeyBinarySize)
+		}
+	}
+	return nil
+}
+
+// validateColumnValue checks the name and value size of a single attribute column.
+func validateColumnValue(name string, value any) error {
+	if err := validateName("column", name); err != nil {
+		return err
+	}
+	var size int
+	switch v := value.(type) {
+	case string:
+		size = len(v)
+	case []byte:
+		size = len(v)
+	}
+	if size > MaxColumnValueSize {
+		return fmt.Errorf("column %q is %d bytes, exceeding the limit of %d bytes", name, size, MaxColumnValueSize)
+	}
+	return nil
+}
+
+// primaryKeySize returns the size in bytes of the names and values of the primary key columns.
+// Integer values count for 8 bytes.
+func primaryKeySize(pks []KeyValue) int {
+	size := 0
+	for _, pk := range pks {
+		size += len(pk.Key)
+		switch v := pk.Value.(type) {
+		case string:
+			size += len(v)
+		case []byte:
+			size += len(v)
+		default:
+			size += 8
+		}
+	}
+	return size
+}
+
+// keyNames returns the names of the columns, comma separated.
+func keyNames(kvs []KeyValue) string {
+	names := make([]string, len(kvs))
+	for i, kv := range kvs {
+		names[i] = kv.Key
+	}
+	return strings.Join(names, ", ")
+}
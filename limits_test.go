@@ -0,0 +1,89 @@
+package otsutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrimaryKeyColumnLimit(t *testing.T) {
+	type row struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk2 *string `json:"pk2" pk:"2"`
+		Pk3 *string `json:"pk3" pk:"3"`
+		Pk4 *string `json:"pk4" pk:"4"`
+		Pk5 *string `json:"pk5" pk:"5"`
+	}
+	assert.EqualError(t, CheckType(&row{}), "type otsutils.row: 5 pk-tagged fields Pk1, Pk2, Pk3, Pk4, Pk5 exceed the maximum of 4")
+}
+
+func TestValueSizeLimits(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	type row struct {
+		Pk1  *string `json:"pk1" pk:"1"`
+		Pk2  *int64  `json:"pk2" pk:"2"`
+		Col1 *string `json:"col1"`
+	}
+
+	// 超限的主键在发起请求前报错
+	err := PutRow(ctx, &row{Pk1: tea.String(strings.Repeat("a", MaxPrimaryKeyStringSize+1)), Pk2: tea.Int64(1)})
+	ast.EqualError(err, `primary key "pk1" is 1025 bytes, exceeding the limit of 1024 bytes`)
+	err = GetRow(ctx, &row{Pk1: tea.String(strings.Repeat("a", MaxPrimaryKeyStringSize+1)), Pk2: tea.Int64(1)})
+	ast.ErrorContains(err, `primary key "pk1" is 1025 bytes`)
+
+	// 刚好等于上限时允许
+	err = PutRow(ctx, &row{Pk1: tea.String(strings.Repeat("a", MaxPrimaryKeyStringSize)), Pk2: tea.Int64(1)})
+	ast.NoError(err)
+
+	// 超限的属性列
+	err = PutRow(ctx, &row{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String(strings.Repeat("a", MaxColumnValueSize+1))})
+	ast.EqualError(err, `column "col1" is 2097153 bytes, exceeding the limit of 2097152 bytes`)
+	err = UpdateRow(ctx, &row{Pk1: tea.String("a"), Pk2: tea.Int64(1)}, UpdateRowParams{
+		UpdatedColumns: map[string]any{"col2": make([]byte, MaxColumnValueSize+1)},
+	})
+	ast.ErrorContains(err, `column "col2" is 2097153 bytes`)
+
+	ast.Equal(1, fake.CallCount("PutRow"))
+	ast.Equal(0, fake.CallCount("GetRow"))
+	ast.Equal(0, fake.CallCount("UpdateRow"))
+}
+
+func TestRowMapLimits(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	pks := []KeyValue{
+		{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}, {Key: "pk3", Value: "c"},
+		{Key: "pk4", Value: "d"}, {Key: "pk5", Value: "e"},
+	}
+	err := PutRowMap(ctx, pks, nil)
+	ast.EqualError(err, "row has 5 primary key columns pk1, pk2, pk3, pk4, pk5, the maximum is 4")
+
+	err = PutRowMap(ctx, []KeyValue{{Key: "pk1", Value: make([]byte, MaxPrimaryKeyBinarySize+1)}}, nil)
+	ast.EqualError(err, `primary key "pk1" is 1025 bytes, exceeding the limit of 1024 bytes for binary values`)
+
+	// 每个主键列都未超限，但整个主键超过上限
+	long := strings.Repeat("k", MaxColumnNameSize-1)
+	value := strings.Repeat("v", MaxPrimaryKeyStringSize)
+	pks = []KeyValue{{Key: long + "1", Value: value}, {Key: long + "2", Value: value}, {Key: long + "3", Value: value}, {Key: "pk4", Value: value}}
+	err = PutRowMap(ctx, pks, nil)
+	ast.ErrorContains(err, "is 4864 bytes, exceeding the limit of 4096 bytes")
+
+	err = PutRowMap(ctx, []KeyValue{{Key: "pk1", Value: "a"}}, []KeyValue{{Key: strings.Repeat("c", MaxColumnNameSize+1), Value: "v"}})
+	ast.ErrorContains(err, "exceeding the limit of 255 bytes")
+}
+
+func TestRowRequestSizeLimit(t *testing.T) {
+	ctx, fake := newFakeContext(t)
+
+	// 每列都未超限，但整行超过请求大小上限
+	big := strings.Repeat("a", MaxColumnValueSize)
+	cols := []KeyValue{{Key: "c1", Value: big}, {Key: "c2", Value: big}, {Key: "c3", Value: big}}
+	err := PutRowMap(ctx, []KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}}, cols)
+	assert.ErrorContains(t, err, "exceeding the request limit of 4194304 bytes")
+	assert.Equal(t, 0, fake.CallCount("PutRow"))
+}
@@ -0,0 +1,225 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// Defaults of Lock.
+const (
+	DefaultLockKeyColumn = "lock_key"
+	DefaultLockClockSkew = time.Second
+)
+
+// Attribute columns of a lock row.
+const (
+	lockOwnerColumn   = "owner"
+	lockExpiresColumn = "expires_at"
+)
+
+var (
+	// ErrLockHeld is returned by Lock.Acquire when another owner holds an unexpired lease.
+	ErrLockHeld = errors.New("lock is held by another owner")
+
+	// ErrLockLost is returned by LockHandle.Renew and LockHandle.Release when the lease expired
+	// and another owner took the lock over, or the lock row was deleted.
+	ErrLockLost = errors.New("lock was lost")
+)
+
+// Lock acquires leases on named locks, each stored as a row of the table of OtsUtilsParams. The
+// table has a single STRING primary key column, KeyColumn, holding the lock key; a lock row
+// records its owner in the "owner" column and the end of its lease, in Unix milliseconds, in
+// the "expires_at" column. The zero value is ready to use.
+//
+// Leases are measured with the clocks of the owners. To tolerate their skew, an expired lease
+// is only taken over ClockSkew after the expiry its owner wrote, so an owner whose clock is
+// less than ClockSkew ahead never loses a lease it still believes valid.
+//
+// Example usage:
+//
+//	var locks Lock
+//	handle, err := locks.Acquire(ctx, "nightly-report", time.Minute)
+//	if errors.Is(err, ErrLockHeld) {
+//	    return nil // another instance runs it
+//	}
+//	if err != nil {
+//	    return err
+//	}
+//	defer handle.Release(ctx)
+type Lock struct {
+	// KeyColumn is the primary key column of the lock table. Defaults to DefaultLockKeyColumn.
+	KeyColumn string
+
+	// Owner names the holder in the owner column, e.g. the host name, followed by a unique
+	// suffix for each Acquire so that the handles of one process are told apart.
+	Owner string
+
+	// ClockSkew is the time an expired lease is kept before another owner can take it over.
+	// Defaults to DefaultLockClockSkew.
+	ClockSkew time.Duration
+
+	// now returns the current time, replaced by the tests
+	now func() time.Time
+}
+
+// LockHandle is a lease acquired with Lock.Acquire. Its methods are safe for concurrent use,
+// e.g. renewing it from a background goroutine.
+type LockHandle struct {
+	lock  *Lock
+	key   string
+	owner string
+
+	mu        sync.Mutex
+	expiresAt time.Time
+	released  bool
+}
+
+// Acquire takes the lock lockKey for lease. The lock row is created under EXPECT_NOT_EXIST or,
+// when it exists, overwritten under the condition that its lease expired more than ClockSkew
+// ago. ErrLockHeld is returned when neither succeeds.
+func (l *Lock) Acquire(ctx context.Context, lockKey string, lease time.Duration) (*LockHandle, error) {
+	if lockKey == "" {
+		return nil, fmt.Errorf("lockKey can not be empty")
+	}
+	if lease <= 0 {
+		return nil, fmt.Errorf("lease must be positive, got %s", lease)
+	}
+	id, err := newULID()
+	if err != nil {
+		return nil, err
+	}
+	owner := id
+	if l.Owner != "" {
+		owner = l.Owner + "/" + id
+	}
+
+	now := l.clock()
+	expiresAt := now.Add(lease)
+	obj := &rowKeyValues{
+		PrimaryKey: l.primaryKey(lockKey),
+		Columns:    []KeyValue{{Key: lockOwnerColumn, Value: owner}, {Key: lockExpiresColumn, Value: expiresAt.UnixMilli()}},
+	}
+	expectNotExist := tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST
+	expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+	expired := tablestore.NewSingleColumnCondition(lockExpiresColumn, tablestore.CT_LESS_THAN, now.Add(-l.clockSkew()).UnixMilli())
+
+	// The row may be released between the two puts, so they are tried twice
+	for attempt := 1; ; attempt++ {
+		err := executeOTSOperation(ctx, "AcquireLock", obj, buildPutRowRequest, executePutRow, nil, PutRowParams{RowExistenceExpectation: &expectNotExist})
+		if err == nil {
+			break
+		}
+		if !IsConditionCheckFail(err) {
+			return nil, err
+		}
+		// Take over the lease of an owner that neither renewed nor released it
+		err = executeOTSOperation(ctx, "AcquireLock", obj, buildPutRowRequest, executePutRow, nil, PutRowParams{RowExistenceExpectation: &expectExist, ColumnCondition: expired})
+		if err == nil {
+			break
+		}
+		if !IsConditionCheckFail(err) {
+			return nil, err
+		}
+		if attempt == 2 {
+			return nil, fmt.Errorf("%w: %s", ErrLockHeld, lockKey)
+		}
+	}
+	return &LockHandle{lock: l, key: lockKey, owner: owner, expiresAt: expiresAt}, nil
+}
+
+// Renew extends the lease to lease from now. It fails with ErrLockLost when another owner
+// took the lock over; a lease that expired without being taken over is renewed.
+func (h *LockHandle) Renew(ctx context.Context, lease time.Duration) error {
+	if lease <= 0 {
+		return fmt.Errorf("lease must be positive, got %s", lease)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.released {
+		return fmt.Errorf("lock %s is already released", h.key)
+	}
+
+	expiresAt := h.lock.clock().Add(lease)
+	obj := &rowKeyValues{
+		PrimaryKey: h.lock.primaryKey(h.key),
+		Columns:    []KeyValue{{Key: lockExpiresColumn, Value: expiresAt.UnixMilli()}},
+	}
+	expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+	err := executeOTSOperation(ctx, "RenewLock", obj, buildUpdateRowRequest, executeUpdateRow, nil, UpdateRowParams{RowExistenceExpectation: &expectExist, ColumnCondition: h.ownerCondition()})
+	if IsConditionCheckFail(err) {
+		return fmt.Errorf("%w: %s", ErrLockLost, h.key)
+	}
+	if err != nil {
+		return err
+	}
+	h.expiresAt = expiresAt
+	return nil
+}
+
+// Release deletes the lock row if the handle still owns it. Releasing a lease that expired
+// without being taken over still deletes the row; when another owner took it over, its row is
+// left in place and ErrLockLost is returned. The handle can not be used afterwards.
+func (h *LockHandle) Release(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.released {
+		return fmt.Errorf("lock %s is already released", h.key)
+	}
+	h.released = true
+
+	obj := &rowKeyValues{PrimaryKey: h.lock.primaryKey(h.key)}
+	expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+	err := executeOTSOperation(ctx, "ReleaseLock", obj, buildDeleteRowRequest, executeDeleteRow, nil, DeleteRowParams{RowExistenceExpectation: &expectExist, ColumnCondition: h.ownerCondition()})
+	if IsConditionCheckFail(err) {
+		return fmt.Errorf("%w: %s", ErrLockLost, h.key)
+	}
+	return err
+}
+
+// Owner returns the owner written in the lock row.
+func (h *LockHandle) Owner() string {
+	return h.owner
+}
+
+// ExpiresAt returns the end of the lease, as measured by the clock of the owner. Renew it
+// before then: other owners take the lock over ClockSkew later.
+func (h *LockHandle) ExpiresAt() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.expiresAt
+}
+
+// ownerCondition holds when the lock row is still owned by the handle.
+func (h *LockHandle) ownerCondition() tablestore.ColumnFilter {
+	cond := tablestore.NewSingleColumnCondition(lockOwnerColumn, tablestore.CT_EQUAL, h.owner)
+	cond.FilterIfMissing = true
+	return cond
+}
+
+func (l *Lock) primaryKey(lockKey string) []KeyValue {
+	column := l.KeyColumn
+	if column == "" {
+		column = DefaultLockKeyColumn
+	}
+	return []KeyValue{{Key: column, Value: lockKey}}
+}
+
+func (l *Lock) clockSkew() time.Duration {
+	if l.ClockSkew > 0 {
+		return l.ClockSkew
+	}
+	return DefaultLockClockSkew
+}
+
+func (l *Lock) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,120 @@
+package otsutils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a clock the tests move by hand.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func TestLock(t *testing.T) {
+	start := time.UnixMilli(1_700_000_000_000)
+
+	t.Run("acquire and release", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSingleKeyContext(t, "locks", DefaultLockKeyColumn)
+		clock := &fakeClock{t: start}
+		a := &Lock{Owner: "host-a", now: clock.now}
+		b := &Lock{Owner: "host-b", now: clock.now}
+
+		ha, err := a.Acquire(ctx, "job", 10*time.Second)
+		ast.NoError(err)
+		ast.Contains(ha.Owner(), "host-a/")
+		ast.Equal(start.Add(10*time.Second), ha.ExpiresAt())
+
+		cols, err := GetRowMap(ctx, []KeyValue{{Key: "lock_key", Value: "job"}})
+		ast.NoError(err)
+		ast.Equal([]KeyValue{{Key: "expires_at", Value: start.Add(10 * time.Second).UnixMilli()}, {Key: "owner", Value: ha.Owner()}}, cols)
+
+		// 同一进程的另一次获取也被拒绝
+		_, err = b.Acquire(ctx, "job", 10*time.Second)
+		ast.ErrorIs(err, ErrLockHeld)
+		_, err = a.Acquire(ctx, "job", 10*time.Second)
+		ast.ErrorIs(err, ErrLockHeld)
+
+		ast.NoError(ha.Release(ctx))
+		ast.EqualError(ha.Release(ctx), "lock job is already released")
+		ast.EqualError(ha.Renew(ctx, time.Second), "lock job is already released")
+
+		hb, err := b.Acquire(ctx, "job", 10*time.Second)
+		ast.NoError(err)
+		ast.NoError(hb.Release(ctx))
+	})
+
+	t.Run("expired leases are taken over after the clock skew", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSingleKeyContext(t, "locks", DefaultLockKeyColumn)
+		clock := &fakeClock{t: start}
+		a := &Lock{now: clock.now}
+		b := &Lock{ClockSkew: 2 * time.Second, now: clock.now}
+
+		ha, err := a.Acquire(ctx, "job", 10*time.Second)
+		ast.NoError(err)
+
+		// 租约到期但仍在时钟偏差内
+		clock.t = start.Add(11 * time.Second)
+		_, err = b.Acquire(ctx, "job", 10*time.Second)
+		ast.ErrorIs(err, ErrLockHeld)
+
+		// 未被接管的过期租约可以续期
+		ast.NoError(ha.Renew(ctx, 10*time.Second))
+		ast.Equal(start.Add(21*time.Second), ha.ExpiresAt())
+		clock.t = start.Add(22 * time.Second)
+		_, err = b.Acquire(ctx, "job", 10*time.Second)
+		ast.ErrorIs(err, ErrLockHeld)
+
+		clock.t = start.Add(24 * time.Second)
+		hb, err := b.Acquire(ctx, "job", 10*time.Second)
+		ast.NoError(err)
+
+		// 被接管后原持有者不能续期或释放，且不会删除新持有者的行
+		ast.ErrorIs(ha.Renew(ctx, 10*time.Second), ErrLockLost)
+		ast.ErrorIs(ha.Release(ctx), ErrLockLost)
+		cols, err := GetRowMap(ctx, []KeyValue{{Key: "lock_key", Value: "job"}})
+		ast.NoError(err)
+		ast.Contains(cols, KeyValue{Key: "owner", Value: hb.Owner()})
+		ast.NoError(hb.Release(ctx))
+	})
+
+	t.Run("release after the lease expired", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSingleKeyContext(t, "locks", DefaultLockKeyColumn)
+		clock := &fakeClock{t: start}
+		a := &Lock{now: clock.now}
+
+		ha, err := a.Acquire(ctx, "job", time.Second)
+		ast.NoError(err)
+		clock.t = start.Add(time.Minute)
+		ast.NoError(ha.Release(ctx))
+
+		cols, err := GetRowMap(ctx, []KeyValue{{Key: "lock_key", Value: "job"}})
+		ast.NoError(err)
+		ast.Nil(cols)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newSingleKeyContext(t, "locks", DefaultLockKeyColumn)
+		var l Lock
+
+		_, err := l.Acquire(ctx, "", time.Second)
+		ast.EqualError(err, "lockKey can not be empty")
+		_, err = l.Acquire(ctx, "job", 0)
+		ast.EqualError(err, "lease must be positive, got 0s")
+		ast.Equal(0, fake.CallCount("PutRow"))
+
+		fake.Intercept = func(operation string, request any) error {
+			return &tablestore.OtsError{Code: CodeServerBusy, Message: "Server is busy."}
+		}
+		_, err = l.Acquire(ctx, "job", time.Second)
+		ast.Equal(CodeServerBusy, Code(err))
+		ast.False(errors.Is(err, ErrLockHeld))
+	})
+}
@@ -0,0 +1,100 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// OTSMarshaler is implemented by types that encode themselves as a column value, e.g. money
+// types or custom ids. MarshalOTS returns a string, int64, float64, bool or []byte, or nil to
+// write no column.
+//
+// A field is handled by the methods when its type, or the type it points to, implements both
+// OTSMarshaler and OTSUnmarshaler, possibly through its pointer. They take precedence over the
+// natively supported kinds and the serializers registered with RegisterTypeSerializer, while an
+// otstype tag on the field takes precedence over them. A type implementing only one of the two
+// is rejected.
+//
+// Example usage:
+//
+//	type Cents int64
+//
+//	func (c Cents) MarshalOTS() (any, error) { return int64(c), nil }
+//
+//	func (c *Cents) UnmarshalOTS(value any) error {
+//	    n, ok := value.(int64)
+//	    if !ok {
+//	        return fmt.Errorf("cents must be an integer, got %T", value)
+//	    }
+//	    *c = Cents(n)
+//	    return nil
+//	}
+type OTSMarshaler interface {
+	MarshalOTS() (any, error)
+}
+
+// OTSUnmarshaler is implemented by types that decode themselves from a column value, the
+// counterpart of OTSMarshaler. UnmarshalOTS receives the raw column value: a string, int64,
+// float64, bool or []byte. It is called on a newly allocated value for a pointer field.
+type OTSUnmarshaler interface {
+	UnmarshalOTS(value any) error
+}
+
+var (
+	otsMarshalerType   = reflect.TypeFor[OTSMarshaler]()
+	otsUnmarshalerType = reflect.TypeFor[OTSUnmarshaler]()
+)
+
+// implementsOTSMarshaling reports whether fields of type t are handled by the OTSMarshaler
+// and OTSUnmarshaler methods of t, or of the type t points to, through its pointer.
+func implementsOTSMarshaling(t reflect.Type) (marshaler, unmarshaler bool) {
+	ptr := reflect.PointerTo(nativeBaseType(t))
+	return ptr.Implements(otsMarshalerType), ptr.Implements(otsUnmarshalerType)
+}
+
+// marshalOTS returns the column value MarshalOTS returns for the field fm. skip is true when
+// the field is a nil pointer or MarshalOTS returns nil.
+func (fm *fieldMeta) marshalOTS(field reflect.Value) (value any, skip bool, err error) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil, true, nil
+		}
+	} else if field.CanAddr() {
+		field = field.Addr()
+	} else {
+		// MarshalOTS may have a pointer receiver
+		p := reflect.New(field.Type())
+		p.Elem().Set(field)
+		field = p
+	}
+
+	value, err = field.Interface().(OTSMarshaler).MarshalOTS()
+	if err != nil {
+		return nil, false, fmt.Errorf("field %s: MarshalOTS: %w", fm.name, err)
+	}
+	switch value.(type) {
+	case nil:
+		return nil, true, nil
+	case string, int64, float64, bool, []byte:
+	default:
+		return nil, false, fmt.Errorf("field %s: MarshalOTS returned unsupported column type %T, only string, int64, float64, bool and []byte are allowed", fm.name, value)
+	}
+	return value, false, nil
+}
+
+// unmarshalOTS calls UnmarshalOTS of the field fm with the column value, allocating the field
+// when it is a pointer.
+func (fm *fieldMeta) unmarshalOTS(field reflect.Value, value any) error {
+	target := field.Addr()
+	if field.Kind() == reflect.Ptr {
+		target = reflect.New(field.Type().Elem())
+	}
+	if err := target.Interface().(OTSUnmarshaler).UnmarshalOTS(value); err != nil {
+		return fmt.Errorf("field %s: UnmarshalOTS: %w", fm.name, err)
+	}
+	if field.Kind() == reflect.Ptr {
+		field.Set(target)
+	}
+	return nil
+}
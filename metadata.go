@@ -0,0 +1,458 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/117503445/otsutils/internal/rowrules"
+)
+
+// fieldMeta describes how a single struct field maps to an OTS column.
+type fieldMeta struct {
+	// index is the index sequence of the field, through the structs it is promoted from
+	index []int
+	name  string
+	typ   reflect.Type
+	pkTag string
+
+	// column is the column name taken from the json tag, or derived from the field name
+	column string
+
+	// pk is the parsed pk tag, meaningful only when pkTag is not empty
+	pk pkTag
+
+	// omitEmpty is set by the omitempty option of the json tag: a non-pointer field holding
+	// its zero value is then not written
+	omitEmpty bool
+
+	// serializer is set when the field type is handled by the type serializer registry
+	serializer *typeSerializer
+
+	// prefix is the parsed pkprefix tag, nil when the field has none
+	prefix *pkPrefix
+
+	// indexes is the parsed index tag, nil when the field has none
+	indexes []indexTag
+
+	// otsType is the value of the otstype tag: rowrules.OtsTypeJSON stores the field as its
+	// JSON encoding
+	otsType string
+
+	// marshaler is set when the field type is handled by its OTSMarshaler and OTSUnmarshaler
+	// methods, which take precedence over the native kinds and the serializer registry
+	marshaler bool
+
+	// sqlNull is set when the field type is a database/sql null type, such as sql.NullString
+	sqlNull bool
+
+	// null is set when the field type is a Null
+	null bool
+
+	// uuid is set when the field type is a UUID type, see isUUIDType
+	uuid bool
+}
+
+// structMeta is the parsed, validated description of a row struct type.
+type structMeta struct {
+	fields []fieldMeta
+
+	// pkFields holds the indexes into fields of the primary key fields, in pk tag order
+	pkFields []int
+
+	// attrFields holds the indexes into fields of the attribute fields, in the column order
+	// set with SetColumnOrder
+	attrFields []int
+
+	// extra is the index sequence of the field tagged `ots:"extra"`, nil when there is none
+	extra []int
+}
+
+// anyMapType is map[string]any, the type of the extra field.
+var anyMapType = reflect.TypeOf(map[string]any(nil))
+
+// structMetaCache caches *structMetaEntry by reflect.Type.
+var structMetaCache sync.Map
+
+// structMetaGeneration is incremented by invalidateStructMetaCache. Entries built under an
+// older generation are ignored, so a build racing with an invalidation is never served from
+// the cache with the old settings.
+var structMetaGeneration atomic.Uint64
+
+// structMetaEntry is the outcome of buildStructMeta. Invalid types are cached as well, so
+// their problems are only searched for once.
+type structMetaEntry struct {
+	meta *structMeta
+	err  error
+
+	// generation is the value of structMetaGeneration when the build started
+	generation uint64
+}
+
+// invalidateStructMetaCache drops all cached struct metadata.
+func invalidateStructMetaCache() {
+	structMetaGeneration.Add(1)
+	structMetaCache.Range(func(key, _ any) bool {
+		structMetaCache.Delete(key)
+		return true
+	})
+}
+
+// getStructMeta returns the cached metadata of the struct type t, or the problems found in it,
+// building it on first use.
+func getStructMeta(t reflect.Type) (*structMeta, error) {
+	generation := structMetaGeneration.Load()
+	if v, ok := structMetaCache.Load(t); ok {
+		if entry := v.(*structMetaEntry); entry.generation == generation {
+			return entry.meta, entry.err
+		}
+	}
+
+	meta, err := buildStructMeta(t)
+	structMetaCache.Store(t, &structMetaEntry{meta: meta, err: err, generation: generation})
+	return meta, err
+}
+
+// isNativeFieldType reports whether t is one of the natively supported column types or a
+// pointer to one.
+func isNativeFieldType(t reflect.Type) bool {
+	// The type, or the pointed-to type, must be string, an integer, float64 or []byte
+	elem := nativeBaseType(t)
+	switch elem.Kind() {
+	case reflect.String:
+		return true
+	case reflect.Float64:
+		return true
+	case reflect.Slice:
+		return elem.Elem().Kind() == reflect.Uint8 // []byte is []uint8
+	default:
+		// Integers other than int64 are converted to and from int64
+		return isIntegerKind(elem.Kind())
+	}
+}
+
+// isIntegerKind reports whether k is one of the integer kinds stored as an INTEGER column.
+func isIntegerKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return true
+	}
+	return false
+}
+
+// nativeBaseType returns t, or the type it points to when t is a pointer.
+func nativeBaseType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// buildStructMeta parses the fields of the struct type t. Every problem found is collected
+// into a single *TypeError rather than stopping at the first one. The rules themselves live in
+// internal/rowrules, which the rowcheck analyzer applies to the same structs at build time.
+func buildStructMeta(t reflect.Type) (*structMeta, error) {
+	fields := rowrules.Promote(describeFields(t, map[reflect.Type]bool{t: true}, 0), currentFlattenSeparator())
+	res := rowrules.Check(fields, rowrules.Limits{
+		MaxPrimaryKeyColumns: MaxPrimaryKeyColumns,
+		MaxColumnNameSize:    MaxColumnNameSize,
+	})
+	if len(res.Problems) > 0 {
+		problems := make([]error, len(res.Problems))
+		for i, p := range res.Problems {
+			problems[i] = p.Err
+			if p.Field < 0 {
+				// Name the type in the problems concerning it as a whole
+				problems[i] = fmt.Errorf("type %s: %w", t, p.Err)
+			}
+		}
+		return nil, &TypeError{Type: t, Problems: problems}
+	}
+
+	meta := &structMeta{
+		fields:     make([]fieldMeta, len(res.Fields)),
+		pkFields:   res.PkFields,
+		attrFields: res.AttrFields,
+	}
+	if res.Extra >= 0 {
+		meta.extra = fields[res.Extra].Path
+	}
+	for i, r := range res.Fields {
+		ft := t.FieldByIndex(fields[r.Index].Path)
+		fm := fieldMeta{
+			index:     fields[r.Index].Path,
+			name:      ft.Name,
+			typ:       ft.Type,
+			column:    r.Column,
+			pk:        pkTag{order: r.Pk.Order, auto: r.Pk.Auto, gen: r.Pk.Gen},
+			omitEmpty: r.OmitEmpty,
+			otsType:   r.OtsType,
+		}
+		if r.IsPk {
+			fm.pkTag = fields[r.Index].PkTag
+		}
+		if r.Prefix != nil {
+			fm.prefix = &pkPrefix{hash: r.Prefix.Hash, length: r.Prefix.Length, migrate: r.Prefix.Migrate}
+		}
+		for _, idx := range r.Indexes {
+			fm.indexes = append(fm.indexes, indexTag{name: idx.Name, order: idx.Order})
+		}
+		if fm.otsType == "" {
+			fm.marshaler, _ = implementsOTSMarshaling(ft.Type)
+			_, fm.sqlNull = sqlNullValueType(ft.Type)
+			fm.null = isNullType(ft.Type)
+		}
+		fm.uuid = !fm.marshaler && (fm.otsType == "" || fm.otsType == rowrules.OtsTypeBinary) && isUUIDType(ft.Type)
+		if !isNativeFieldType(ft.Type) && fm.otsType == "" && !fm.marshaler && !fm.sqlNull && !fm.null && !fm.uuid {
+			fm.serializer = lookupTypeSerializer(ft.Type)
+		}
+		meta.fields[i] = fm
+	}
+
+	if currentColumnOrder() == ColumnOrderLexicographic {
+		sort.SliceStable(meta.attrFields, func(i, j int) bool {
+			return meta.fields[meta.attrFields[i]].column < meta.fields[meta.attrFields[j]].column
+		})
+	}
+	return meta, nil
+}
+
+// describeFields describes the fields of the struct type t for the rules in internal/rowrules,
+// along with the fields of the structs it embeds or flattens. embedding holds the struct types
+// being embedded, so that a struct embedding itself is not described again, and flattenDepth
+// the number of flattened structs t is nested in.
+func describeFields(t reflect.Type, embedding map[reflect.Type]bool, flattenDepth int) []rowrules.Field {
+	fields := make([]rowrules.Field, t.NumField())
+	for i := range fields {
+		ft := t.Field(i)
+		prefixTag, hasPrefix := ft.Tag.Lookup("pkprefix")
+		fields[i] = rowrules.Field{
+			Name:           ft.Name,
+			Exported:       ft.IsExported(),
+			Type:           ruleType(ft.Type),
+			JSONTag:        ft.Tag.Get("json"),
+			PkTag:          ft.Tag.Get("pk"),
+			UntaggedColumn: untaggedColumnName(ft.Name),
+			PkPrefixTag:    prefixTag,
+			HasPkPrefix:    hasPrefix,
+			IndexTag:       ft.Tag.Get("index"),
+			OtsTag:         ft.Tag.Get("ots"),
+			OtsTypeTag:     ft.Tag.Get("otstype"),
+			Anonymous:      ft.Anonymous,
+		}
+		base := nativeBaseType(ft.Type)
+		if base.Kind() != reflect.Struct {
+			continue
+		}
+		switch {
+		case ft.Anonymous:
+			fields[i].Fields = []rowrules.Field{}
+			if !embedding[base] {
+				embedding[base] = true
+				fields[i].Fields = describeFields(base, embedding, flattenDepth)
+				delete(embedding, base)
+			}
+		case fields[i].OtsTag == rowrules.OtsFlatten && flattenDepth < rowrules.MaxFlattenDepth:
+			fields[i].Fields = describeFields(base, map[reflect.Type]bool{}, flattenDepth+1)
+		}
+	}
+	return fields
+}
+
+// ruleType describes the field type t for the rules in internal/rowrules.
+func ruleType(t reflect.Type) rowrules.Type {
+	rt := rowrules.Type{Name: t.String(), Serializable: lookupTypeSerializer(t) != nil}
+	rt.Marshaler, rt.Unmarshaler = implementsOTSMarshaling(t)
+	_, rt.SQLNull = sqlNullValueType(t)
+	rt.OtsNull = isNullType(t)
+	rt.UUID = isUUIDType(t)
+	base := t
+	for base.Kind() == reflect.Ptr {
+		base = base.Elem()
+		rt.Pointers++
+	}
+	rt.Base = base.String()
+
+	switch base.Kind() {
+	case reflect.String:
+		rt.BaseKind = rowrules.KindString
+	case reflect.Int64:
+		rt.BaseKind = rowrules.KindInt64
+	case reflect.Slice:
+		if base.Elem().Kind() == reflect.Uint8 {
+			rt.BaseKind = rowrules.KindBytes
+		} else {
+			rt.BaseKind = rowrules.KindOther
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		rt.BaseKind = rowrules.KindInt
+	case reflect.Uint, reflect.Uint64:
+		rt.BaseKind = rowrules.KindUint64
+	case reflect.Float64:
+		rt.BaseKind = rowrules.KindFloat64
+	case reflect.Float32:
+		rt.BaseKind = rowrules.KindFloat
+	case reflect.Bool:
+		rt.BaseKind = rowrules.KindBool
+	case reflect.Struct:
+		rt.BaseKind = rowrules.KindStruct
+	case reflect.Map:
+		rt.BaseKind = rowrules.KindMap
+		rt.AnyMap = t == anyMapType
+	case reflect.Interface:
+		rt.BaseKind = rowrules.KindInterface
+	case reflect.Chan:
+		rt.BaseKind = rowrules.KindChan
+	case reflect.Func:
+		rt.BaseKind = rowrules.KindFunc
+	case reflect.UnsafePointer:
+		rt.BaseKind = rowrules.KindUnsafePointer
+	default:
+		rt.BaseKind = rowrules.KindOther
+	}
+	return rt
+}
+
+// fieldByColumn returns the field mapping to column, or nil.
+func (m *structMeta) fieldByColumn(column string) *fieldMeta {
+	for i := range m.fields {
+		if m.fields[i].column == column {
+			return &m.fields[i]
+		}
+	}
+	return nil
+}
+
+// attrColumns returns the columns of the attribute fields, the default projection of reads into
+// the struct, or nil when the struct has an extra field and so takes every column.
+func (m *structMeta) attrColumns() []string {
+	if m.extra != nil {
+		return nil
+	}
+	columns := make([]string, 0, len(m.attrFields))
+	for _, i := range m.attrFields {
+		columns = append(columns, m.fields[i].column)
+	}
+	return columns
+}
+
+// hasPkColumn reports whether column is the column of one of the primary key fields.
+func (m *structMeta) hasPkColumn(column string) bool {
+	for _, i := range m.pkFields {
+		if m.fields[i].column == column {
+			return true
+		}
+	}
+	return false
+}
+
+// field returns the field of the struct v that fm describes, or the zero Value when it is
+// promoted from an embedded struct through a nil pointer.
+func (fm *fieldMeta) field(v reflect.Value) reflect.Value {
+	return fieldByIndex(v, fm.index, false)
+}
+
+// settableField returns the field of the struct v that fm describes, allocating the nil
+// embedded structs it is promoted from.
+func (fm *fieldMeta) settableField(v reflect.Value) reflect.Value {
+	return fieldByIndex(v, fm.index, true)
+}
+
+// fieldByIndex returns the nested field of the struct v with the index sequence index, like
+// reflect.Value.FieldByIndex. A nil embedded struct pointer on the way is allocated when alloc
+// is set, and yields the zero Value otherwise.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for n, i := range index {
+		if n > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// value returns the column value of the field. skip is true when the field is absent: a nil
+// pointer, or an empty value field tagged omitempty.
+func (fm *fieldMeta) value(field reflect.Value) (value any, skip bool, err error) {
+	if !field.IsValid() {
+		// The field is promoted from a nil embedded struct
+		return nil, true, nil
+	}
+	if fm.otsType == rowrules.OtsTypeJSON {
+		return fm.encodeJSON(field)
+	}
+	if fm.marshaler {
+		return fm.marshalOTS(field)
+	}
+	if fm.sqlNull {
+		return fm.encodeSQLNull(field)
+	}
+	if fm.null {
+		return fm.encodeNull(field)
+	}
+	if fm.uuid {
+		return fm.encodeUUID(field)
+	}
+	if fm.serializer != nil {
+		value, skip, err = fm.serializer.encode(field)
+		if err != nil {
+			return nil, false, fmt.Errorf("field %s: %w", fm.name, err)
+		}
+		return value, skip, nil
+	}
+
+	if field.Kind() == reflect.Ptr {
+		// If it's a pointer and is nil, skip
+		if field.IsNil() {
+			return nil, true, nil
+		}
+		field = field.Elem()
+	} else if fm.omitEmpty && isEmptyValue(field) {
+		return nil, true, nil
+	}
+	if fm.prefix != nil {
+		return fm.prefix.apply(field.String()), false, nil
+	}
+	return nativeColumnValue(field), false, nil
+}
+
+// nativeColumnValue returns the column value of the value v of a native type. The value is
+// converted by kind, so that named types such as enums are written as the type the SDK expects.
+func nativeColumnValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			// A nil []byte field is written as an empty binary value
+			return []byte{}
+		}
+		return v.Bytes()
+	case reflect.String:
+		return v.String()
+	case reflect.Int64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return v.Int()
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return int64(v.Uint())
+	case reflect.Float64:
+		return v.Float()
+	}
+	return v.Interface()
+}
+
+// isEmptyValue reports whether the native field value v is empty in the sense of the omitempty
+// option of encoding/json: zero, or a slice of length 0.
+func isEmptyValue(v reflect.Value) bool {
+	if v.Kind() == reflect.Slice {
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,45 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// MetricsHook receives one OperationMetrics per SDK call, e.g. to export latency histograms and
+// count timeouts by cause. It is called synchronously after the call, from the goroutine of the
+// operation, so it should not block.
+type MetricsHook interface {
+	RecordOperation(ctx context.Context, m OperationMetrics)
+}
+
+// OperationMetrics describes one SDK call of an operation.
+type OperationMetrics struct {
+	// Operation is the name of the operation, e.g. "GetRow".
+	Operation string
+
+	// TableName is the table of OtsUtilsParams.
+	TableName string
+
+	// Client is "primary" or "read", the client the call was sent with.
+	Client string
+
+	// DeadlineRemaining is the time left on the caller's context when the operation started,
+	// valid when HasDeadline is set.
+	DeadlineRemaining time.Duration
+	HasDeadline       bool
+
+	// Duration is the time the call took, zero when it was not sent because the budget of the
+	// operation was already spent.
+	Duration time.Duration
+
+	// ConsumedCapacity is the capacity reported by the response, nil when it reported none or
+	// the call failed.
+	ConsumedCapacity *tablestore.ConsumedCapacityUnit
+
+	// Err is the error of the call, nil on success. Timeouts are a *DeadlineError whose Cause
+	// tells a short caller deadline from a slow service.
+	Err error
+}
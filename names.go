@@ -0,0 +1,78 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/117503445/otsutils/internal/rowrules"
+)
+
+// validateName checks a table, index or column name against the Tablestore naming rules: it
+// must be 1 to MaxColumnNameSize (MaxTableNameSize for tables and indexes) bytes of letters,
+// digits and underscores, and must not start with a digit. kind describes the name in errors, such as "column".
+func validateName(kind, name string) error {
+	limit := MaxColumnNameSize
+	if kind == "table" || kind == "index" {
+		limit = MaxTableNameSize
+	}
+	return rowrules.ValidateName(kind, name, limit)
+}
+
+var fieldNameMapper atomic.Pointer[func(string) string]
+
+// SetFieldNameMapper sets the package-wide function deriving the column of a row struct field
+// that has no json tag from the field name. The default is strings.ToLower, so a field Status
+// maps to the column "status"; nil restores it. Fields tagged `json:"-"` are never mapped.
+// The rowcheck analyzer always assumes the default.
+//
+// Example usage:
+//
+//	otsutils.SetFieldNameMapper(func(name string) string {
+//	    return strcase.ToSnake(name)
+//	})
+func SetFieldNameMapper(mapper func(fieldName string) string) {
+	if mapper == nil {
+		fieldNameMapper.Store(nil)
+	} else {
+		fieldNameMapper.Store(&mapper)
+	}
+	// Cached struct metadata holds the column names
+	invalidateStructMetaCache()
+}
+
+// untaggedColumnName returns the column of a field named fieldName that has no json tag.
+func untaggedColumnName(fieldName string) string {
+	if mapper := fieldNameMapper.Load(); mapper != nil {
+		return (*mapper)(fieldName)
+	}
+	return strings.ToLower(fieldName)
+}
+
+// DefaultFlattenSeparator is the default separator of SetFlattenSeparator.
+const DefaultFlattenSeparator = "_"
+
+var flattenSeparator atomic.Pointer[string]
+
+// SetFlattenSeparator sets the package-wide separator joining the column of a field tagged
+// `ots:"flatten"` and the columns of the fields of its struct. With the default
+// DefaultFlattenSeparator, a field Address tagged `json:"addr" ots:"flatten"` maps the fields
+// City and Zip of its struct to the columns "addr_city" and "addr_zip"; "" restores it. The
+// rowcheck analyzer always assumes the default.
+func SetFlattenSeparator(sep string) {
+	if sep == "" {
+		flattenSeparator.Store(nil)
+	} else {
+		flattenSeparator.Store(&sep)
+	}
+	// Cached struct metadata holds the column names
+	invalidateStructMetaCache()
+}
+
+// currentFlattenSeparator returns the separator set by SetFlattenSeparator.
+func currentFlattenSeparator() string {
+	if sep := flattenSeparator.Load(); sep != nil {
+		return *sep
+	}
+	return DefaultFlattenSeparator
+}
@@ -0,0 +1,56 @@
+package otsutils
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateName(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.NoError(validateName("column", "col_1"))
+	ast.NoError(validateName("column", "_hidden"))
+	ast.NoError(validateName("column", strings.Repeat("c", MaxColumnNameSize)))
+
+	ast.EqualError(validateName("column", ""), "column name is empty")
+	ast.EqualError(validateName("column", "1col"), `column name "1col" must start with a letter or underscore`)
+	ast.EqualError(validateName("column", "my col"), `column name "my col" contains ' ' at byte 2; only letters, digits and underscores are allowed`)
+	ast.EqualError(validateName("table", "my-table"), `table name "my-table" contains '-' at byte 2; only letters, digits and underscores are allowed`)
+	ast.EqualError(validateName("table", strings.Repeat("t", MaxTableNameSize+1)), `table name "`+strings.Repeat("t", MaxTableNameSize+1)+`" is 256 bytes, exceeding the limit of 255 bytes`)
+}
+
+func TestNameValidation(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	// 结构体 tag 在构建元数据时校验，错误指出字段名
+	type row struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Col *string `json:"my col"`
+	}
+	err := CheckType(&row{})
+	ast.EqualError(err, `field Col: column name "my col" contains ' ' at byte 2; only letters, digits and underscores are allowed`)
+	ast.ErrorContains(PutRow(ctx, &row{Pk1: tea.String("a")}), "field Col: ")
+
+	// UpdatedColumns / DeletedColumns 以及 map 接口在构建请求时校验，错误指出键名
+	type valid struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk2 *int64  `json:"pk2" pk:"2"`
+	}
+	obj := &valid{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+	err = UpdateRow(ctx, obj, UpdateRowParams{UpdatedColumns: map[string]any{"9lives": "x"}})
+	ast.EqualError(err, `column name "9lives" must start with a letter or underscore`)
+	err = UpdateRow(ctx, obj, UpdateRowParams{DeletedColumns: []string{"old-col"}})
+	ast.EqualError(err, `deleted column name "old-col" contains '-' at byte 3; only letters, digits and underscores are allowed`)
+	err = PutRowMap(ctx, []KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}}, []KeyValue{{Key: "a.b", Value: "v"}})
+	ast.EqualError(err, `column name "a.b" contains '.' at byte 1; only letters, digits and underscores are allowed`)
+	ast.Equal(0, fake.CallCount("PutRow")+fake.CallCount("UpdateRow"))
+
+	// 表名在 WithContext 时校验
+	o := OtsUtilsParams{Client: fake, TableName: "my-table"}
+	ast.Panics(func() { o.WithContext(context.Background()) })
+}
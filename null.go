@@ -0,0 +1,128 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"reflect"
+)
+
+// Null is a row struct field holding a column value of type T, a string, integer, float64 or
+// []byte type, that can also express the absence of the column. It has three states:
+//   - the zero Null is unset: the column is not written, like a nil pointer field
+//   - NullValue(v) holds v, which is written as the column
+//   - NullColumn() is null: UpdateRow deletes the column, and PutRow does not write it
+//
+// GetRow, GetRange and the other reads set a Null field to its value, or to null when the row
+// has no such column, so a row read with GetRangeParams.ColumnsToGet has its other Null fields
+// null. A Null field can not be a primary key field.
+//
+// Example usage:
+//
+//	type User struct {
+//	    ID       *string              `json:"id" pk:"1"`
+//	    Nickname otsutils.Null[string] `json:"nickname"`
+//	}
+//
+//	// Deletes the nickname column
+//	err := UpdateRow(ctx, &User{ID: tea.String("u1"), Nickname: otsutils.NullColumn[string]()})
+type Null[T any] struct {
+	v     T
+	state nullState
+}
+
+// nullState is the state of a Null.
+type nullState uint8
+
+const (
+	nullUnset nullState = iota
+	nullValid
+	nullNull
+)
+
+// NullValue returns a Null holding v.
+func NullValue[T any](v T) Null[T] {
+	return Null[T]{v: v, state: nullValid}
+}
+
+// NullColumn returns a null Null, whose column UpdateRow deletes.
+func NullColumn[T any]() Null[T] {
+	return Null[T]{state: nullNull}
+}
+
+// Get returns the value of n and whether it holds one.
+func (n Null[T]) Get() (T, bool) {
+	return n.v, n.state == nullValid
+}
+
+// IsNull reports whether n is null.
+func (n Null[T]) IsNull() bool {
+	return n.state == nullNull
+}
+
+// nullField is implemented by *Null[T], so that the row struct code can reach its value
+// through reflection.
+type nullField interface {
+	nullValue() reflect.Value
+	nullState() nullState
+	setNullState(state nullState)
+}
+
+func (n *Null[T]) nullValue() reflect.Value     { return reflect.ValueOf(&n.v).Elem() }
+func (n *Null[T]) nullState() nullState         { return n.state }
+func (n *Null[T]) setNullState(state nullState) { n.state = state }
+
+var nullFieldType = reflect.TypeFor[nullField]()
+
+// isNullType reports whether t is a Null whose value type a column can hold.
+func isNullType(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || !reflect.PointerTo(t).Implements(nullFieldType) {
+		return false
+	}
+	vt := t.Field(0).Type
+	return vt.Kind() != reflect.Ptr && isNativeFieldType(vt)
+}
+
+// asNullField returns the Null the field holds.
+func asNullField(field reflect.Value) nullField {
+	if !field.CanAddr() {
+		p := reflect.New(field.Type())
+		p.Elem().Set(field)
+		field = p.Elem()
+	}
+	return field.Addr().Interface().(nullField)
+}
+
+// encodeNull returns the column value of the Null field fm. skip is true unless it holds a value.
+func (fm *fieldMeta) encodeNull(field reflect.Value) (value any, skip bool, err error) {
+	n := asNullField(field)
+	if n.nullState() != nullValid {
+		return nil, true, nil
+	}
+	return nativeColumnValue(n.nullValue()), false, nil
+}
+
+// nullColumns returns the columns of the Null fields of the row struct obj that are null,
+// which UpdateRow deletes.
+func nullColumns(obj any) []string {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	if _, ok := obj.(*rowKeyValues); ok {
+		return nil
+	}
+	meta, err := getStructMeta(v.Elem().Type())
+	if err != nil {
+		return nil
+	}
+	var columns []string
+	for _, i := range meta.attrFields {
+		fm := &meta.fields[i]
+		if !fm.null {
+			continue
+		}
+		if field := fm.field(v.Elem()); field.IsValid() && asNullField(field).nullState() == nullNull {
+			columns = append(columns, fm.column)
+		}
+	}
+	return columns
+}
@@ -3,6 +3,11 @@ package otsutils
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 	"github.com/rs/zerolog"
@@ -11,6 +16,27 @@ import (
 // PutRow inserts a row into the table.
 // The obj parameter should be a pointer to a struct with fields tagged with "json" and optionally "pk".
 // Fields tagged with "pk" are treated as primary key columns, others are treated as attribute columns.
+// A nil pointer field is not written. A value field, e.g. a string rather than a *string, is
+// written even when it holds its zero value, unless its json tag carries omitempty. Fields
+// tagged `json:"-"` are skipped, and a field without a json tag maps to its lower-cased name,
+// see SetFieldNameMapper. Each entry of a map[string]any field tagged `ots:"extra"` is written
+// as a column; its name must not be the column of another field. The fields of embedded structs
+// are promoted as encoding/json does; those behind a nil embedded pointer are not written. The
+// fields of a struct field tagged `ots:"flatten"` map to columns prefixed with its column, see
+// SetFlattenSeparator. A field of any type tagged `otstype:"json"` is written as its JSON
+// encoding in a string column, unless it is nil or an empty map or slice. A field whose type
+// implements OTSMarshaler and OTSUnmarshaler is written as MarshalOTS returns. Fields of type
+// int, int8, int16, int32, uint8, uint16 and uint32 are written as int64, and reading a value
+// out of their range is an error; uint and uint64 are rejected as int64 can not hold them.
+// Named types, e.g. `type Status string`, are converted to and from the type of their kind.
+// A field of a database/sql null type, such as sql.NullString, is written only when Valid is
+// set; sql.NullTime is written as Unix milliseconds. A UUID field, a [16]byte type implementing
+// encoding.TextMarshaler and encoding.TextUnmarshaler such as uuid.UUID, is written as its text
+// form, or as its 16 bytes in a BINARY column when tagged `otstype:"binary"`.
+//
+// A nil field tagged `pk:"<order>,auto"` lets the service assign the value of an AUTO_INCREMENT
+// primary key column; PutRow writes the assigned value back into the field. A non-nil auto field
+// is written as is, like any other primary key field. Every other pk field must be set.
 //
 // Example usage:
 //
@@ -25,47 +51,89 @@ import (
 //	}
 //	err := PutRow(ctx, &row)
 func PutRow(ctx context.Context, obj any, params ...PutRowParams) error {
-	buildReq := func(otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
-		rowExistenceExpectation := tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST
-		if len(params) > 0 {
-			if p, ok := params[0].(PutRowParams); ok && p.RowExistenceExpectation != nil {
+	return executeOTSOperation(ctx, "PutRow", obj, buildPutRowRequest, executePutRow, handlePutRowResponse, toAnySlice(params)...)
+}
+
+// handlePutRowResponse writes the primary key value assigned by the service back into the
+// auto-increment field of obj, if any.
+func handlePutRowResponse(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+	return assignAutoIncrement(obj, &resp.(*tablestore.PutRowResponse).PrimaryKey)
+}
+
+func buildPutRowRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	rowExistenceExpectation := tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST
+	var columnCondition tablestore.ColumnFilter
+	var transactionId *string
+	if len(params) > 0 {
+		if p, ok := params[0].(PutRowParams); ok {
+			if p.RowExistenceExpectation != nil {
 				rowExistenceExpectation = *p.RowExistenceExpectation
 			}
+			columnCondition = p.ColumnCondition
+			transactionId = p.TransactionId
 		}
+	}
 
-		putRowChange := &tablestore.PutRowChange{
-			TableName:  otsParams.TableName,
-			PrimaryKey: &tablestore.PrimaryKey{},
-		}
-		putRowChange.SetCondition(rowExistenceExpectation)
+	putRowChange, err := buildPutRowChange(ctx, otsParams, obj, rowExistenceExpectation)
+	if err != nil {
+		return nil, err
+	}
+	if columnCondition != nil {
+		putRowChange.SetColumnCondition(columnCondition)
+	}
+	putRowChange.TransactionId = ctxTransactionId(ctx, transactionId)
+	return &tablestore.PutRowRequest{PutRowChange: putRowChange}, nil
+}
 
-		pks, cols, err := ParseObj(ctx, obj)
-		if err != nil {
-			return nil, err
-		}
+// buildPutRowChange converts the row obj to a put change of the table, generating the primary
+// key values of its gen fields first.
+func buildPutRowChange(ctx context.Context, otsParams *OtsUtilsParams, obj any, rowExistenceExpectation tablestore.RowExistenceExpectation) (*tablestore.PutRowChange, error) {
+	putRowChange := &tablestore.PutRowChange{
+		TableName:  otsParams.TableName,
+		PrimaryKey: &tablestore.PrimaryKey{},
+	}
+	putRowChange.SetCondition(rowExistenceExpectation)
 
-		for _, pk := range pks {
-			putRowChange.PrimaryKey.AddPrimaryKeyColumn(pk.Key, pk.Value)
-		}
-		for _, col := range cols {
-			putRowChange.AddColumn(col.Key, col.Value)
+	if _, ok := obj.(*rowKeyValues); !ok {
+		if err := generatePkValues(obj); err != nil {
+			return nil, err
 		}
+	}
 
-		return &tablestore.PutRowRequest{PutRowChange: putRowChange}, nil
+	pks, cols, err := parseRow(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPrimaryKeyComplete(obj, pks, true); err != nil {
+		return nil, err
 	}
 
-	execute := func(client *tablestore.TableStoreClient, req any) (any, error) {
-		return client.PutRow(req.(*tablestore.PutRowRequest))
+	for _, pk := range pks {
+		putRowChange.PrimaryKey.AddPrimaryKeyColumn(pk.Key, pk.Value)
+	}
+	// The auto-increment field is the last pk field, so its placeholder goes last
+	if auto, column, err := pendingAutoIncrement(obj); err != nil {
+		return nil, err
+	} else if auto.IsValid() {
+		putRowChange.PrimaryKey.AddPrimaryKeyColumnWithAutoIncrement(column)
+		putRowChange.SetReturnPk()
+	}
+	for _, col := range cols {
+		putRowChange.AddColumn(col.Key, col.Value)
 	}
+	return putRowChange, nil
+}
 
-	// PutRow does not need to handle response data
-	return executeOTSOperation(ctx, "PutRow", obj, buildReq, execute, nil, toAnySlice(params)...)
+func executePutRow(client OtsClient, req any) (any, error) {
+	return client.PutRow(req.(*tablestore.PutRowRequest))
 }
 
 // UpdateRow updates a row in the table.
 // The obj parameter should be a pointer to a struct with fields tagged with "json" and "pk".
 // Fields tagged with "pk" are treated as primary key columns and used to locate the row.
-// Other fields in the struct are treated as attribute columns to update or add.
+// Other fields in the struct are treated as attribute columns to update or add. Every pk field
+// must be set. The column of a Null field set to NullColumn() is deleted, along with
+// UpdateRowParams.DeletedColumns.
 //
 // Example usage:
 //
@@ -87,67 +155,97 @@ func PutRow(ctx context.Context, obj any, params ...PutRowParams) error {
 //	    DeletedColumns: []string{"old_column"},
 //	})
 func UpdateRow(ctx context.Context, obj any, params ...UpdateRowParams) error {
-	buildReq := func(otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
-		rowExistenceExpectation := tablestore.RowExistenceExpectation_IGNORE
-		var deletedColumns []string
-		var updatedColumns map[string]any
+	// UpdateRow does not need special response handling
+	return executeOTSOperation(ctx, "UpdateRow", obj, buildUpdateRowRequest, executeUpdateRow, nil, toAnySlice(params)...)
+}
 
-		if len(params) > 0 {
-			if p, ok := params[0].(UpdateRowParams); ok {
-				if p.RowExistenceExpectation != nil {
-					rowExistenceExpectation = *p.RowExistenceExpectation
-				}
-				deletedColumns = p.DeletedColumns
-				updatedColumns = p.UpdatedColumns
+func buildUpdateRowRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	rowExistenceExpectation := tablestore.RowExistenceExpectation_IGNORE
+	var columnCondition tablestore.ColumnFilter
+	var deletedColumns []string
+	var updatedColumns map[string]any
+	var transactionId *string
+
+	if len(params) > 0 {
+		if p, ok := params[0].(UpdateRowParams); ok {
+			if p.RowExistenceExpectation != nil {
+				rowExistenceExpectation = *p.RowExistenceExpectation
 			}
+			columnCondition = p.ColumnCondition
+			deletedColumns = p.DeletedColumns
+			updatedColumns = p.UpdatedColumns
+			transactionId = p.TransactionId
 		}
+	}
 
-		logger.Debug().Interface("rowExistenceExpectation", rowExistenceExpectation).Send()
+	logger.Debug().Interface("rowExistenceExpectation", rowExistenceExpectation).Send()
 
-		updateRowChange := &tablestore.UpdateRowChange{
-			TableName:  otsParams.TableName,
-			PrimaryKey: &tablestore.PrimaryKey{},
-		}
-		updateRowChange.SetCondition(rowExistenceExpectation)
+	updateRowChange := &tablestore.UpdateRowChange{
+		TableName:     otsParams.TableName,
+		PrimaryKey:    &tablestore.PrimaryKey{},
+		TransactionId: ctxTransactionId(ctx, transactionId),
+	}
+	updateRowChange.SetCondition(rowExistenceExpectation)
+	if columnCondition != nil {
+		updateRowChange.SetColumnCondition(columnCondition)
+	}
 
-		pks, cols, err := ParseObj(ctx, obj)
-		if err != nil {
-			return nil, err
-		}
+	pks, cols, err := parseRow(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPrimaryKeyComplete(obj, pks, false); err != nil {
+		return nil, err
+	}
 
-		for _, pk := range pks {
-			updateRowChange.PrimaryKey.AddPrimaryKeyColumn(pk.Key, pk.Value)
-		}
+	for _, pk := range pks {
+		updateRowChange.PrimaryKey.AddPrimaryKeyColumn(pk.Key, pk.Value)
+	}
 
-		// Process deleted columns
-		for _, colName := range deletedColumns {
-			updateRowChange.DeleteColumn(colName)
+	// Process deleted columns, along with those of the null Null fields of obj
+	for _, colName := range nullColumns(obj) {
+		if !slices.Contains(deletedColumns, colName) {
+			deletedColumns = append(slices.Clip(deletedColumns), colName)
 		}
-
-		// Process updated/added columns
-		for colName, value := range updatedColumns {
-			updateRowChange.PutColumn(colName, value)
+	}
+	for _, colName := range deletedColumns {
+		if err := validateName("deleted column", colName); err != nil {
+			return nil, err
 		}
+		updateRowChange.DeleteColumn(colName)
+	}
 
-		// Process columns extracted from obj (except primary key columns)
-		for _, col := range cols {
-			updateRowChange.PutColumn(col.Key, col.Value)
+	// Process updated/added columns, sorted by name since map iteration order is random
+	for _, colName := range slices.Sorted(maps.Keys(updatedColumns)) {
+		value := updatedColumns[colName]
+		if err := validateColumnValue(colName, value); err != nil {
+			return nil, err
 		}
-
-		return &tablestore.UpdateRowRequest{UpdateRowChange: updateRowChange}, nil
+		updateRowChange.PutColumn(colName, value)
 	}
 
-	execute := func(client *tablestore.TableStoreClient, req any) (any, error) {
-		return client.UpdateRow(req.(*tablestore.UpdateRowRequest))
+	// Process columns extracted from obj (except primary key columns)
+	for _, col := range cols {
+		updateRowChange.PutColumn(col.Key, col.Value)
 	}
 
-	// UpdateRow does not need special response handling
-	return executeOTSOperation(ctx, "UpdateRow", obj, buildReq, execute, nil, toAnySlice(params)...)
+	return &tablestore.UpdateRowRequest{UpdateRowChange: updateRowChange}, nil
+}
+
+func executeUpdateRow(client OtsClient, req any) (any, error) {
+	return client.UpdateRow(req.(*tablestore.UpdateRowRequest))
 }
 
 // GetRow retrieves a row from the table.
 // The obj parameter should be a pointer to a struct with fields tagged with "json" and "pk".
 // Fields tagged with "pk" are used to locate the row, and other fields are populated with the retrieved values.
+// Every pk field must be set. Columns no field maps to are added to the map[string]any field
+// tagged `ots:"extra"`, if any, and ignored otherwise.
+//
+// A missing row is not an error: obj is left unchanged and nil is returned. With
+// GetRowParams.Filter, which makes a row it excludes read as missing, ErrRowNotFound is returned
+// instead. GetRowMap, GetRowToMap and GetRowVersionsToMap follow the same contract, returning a
+// nil result for a missing row.
 //
 // Example usage:
 //
@@ -165,43 +263,304 @@ func UpdateRow(ctx context.Context, obj any, params ...UpdateRowParams) error {
 //	    // row.Col1 and row.Col2 are now populated with values from the table
 //	}
 func GetRow(ctx context.Context, obj any, params ...GetRowParams) error {
-	buildReq := func(otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
-		criteria := &tablestore.SingleRowQueryCriteria{
-			TableName:  otsParams.TableName,
-			MaxVersion: 1,
-			PrimaryKey: &tablestore.PrimaryKey{},
+	found := false
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		pks, cols := rowFromGetRowResponse(resp.(*tablestore.GetRowResponse))
+		found = len(pks) > 0
+		var opts parseResultOptions
+		if len(params) > 0 {
+			opts = parseResultOptions{lenientNumbers: params[0].LenientNumbers, strictColumns: params[0].StrictColumns}
 		}
+		return parseResult(ctx, obj, pks, cols, opts)
+	}
+
+	if err := executeOTSOperation(transactionCtx(ctx, params), "GetRow", obj, buildGetRowRequest, executeGetRow, handleResp, toAnySlice(params)...); err != nil {
+		return err
+	}
+	if !found {
+		return rowNotFound(params)
+	}
+	return nil
+}
+
+// rowNotFound returns the error of a read finding no row, as documented on GetRow.
+func rowNotFound(params []GetRowParams) error {
+	if len(params) > 0 && params[0].Filter != nil {
+		return ErrRowNotFound
+	}
+	return nil
+}
+
+func buildGetRowRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	var p GetRowParams
+	if len(params) > 0 {
+		p, _ = params[0].(GetRowParams)
+	}
+	maxVersion, err := otsParams.maxVersion(p)
+	if err != nil {
+		return nil, err
+	}
+	if p.TimeRange != nil {
+		if err := validateTimeRange(p.TimeRange); err != nil {
+			return nil, err
+		}
+	}
+
+	criteria := &tablestore.SingleRowQueryCriteria{
+		TableName:     otsParams.TableName,
+		MaxVersion:    maxVersion,
+		TimeRange:     p.TimeRange,
+		PrimaryKey:    &tablestore.PrimaryKey{},
+		TransactionId: ctxTransactionId(ctx, p.TransactionId),
+	}
+	if p.Filter != nil {
+		criteria.SetFilter(p.Filter)
+	}
+
+	pks, _, err := parseRow(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPrimaryKeyComplete(obj, pks, false); err != nil {
+		return nil, err
+	}
+	for _, pk := range pks {
+		criteria.PrimaryKey.AddPrimaryKeyColumn(pk.Key, pk.Value)
+	}
 
-		pks, _, err := ParseObj(ctx, obj)
+	return &tablestore.GetRowRequest{SingleRowQueryCriteria: criteria}, nil
+}
+
+// maxVersion returns the number of versions a GetRow reads: GetRowParams.MaxVersion, then
+// OtsUtilsParams.DefaultMaxVersion, then 1, or 0 for every version when GetRowParams.TimeRange
+// is set. When the table metadata is cached, the value must not exceed the table's MaxVersions.
+func (otsUtilsParams *OtsUtilsParams) maxVersion(p GetRowParams) (int32, error) {
+	if p.MaxVersion < 0 {
+		return 0, fmt.Errorf("MaxVersion must be at least 1, got %d", p.MaxVersion)
+	}
+
+	maxVersion := int32(1)
+	switch {
+	case p.MaxVersion > 0:
+		maxVersion = p.MaxVersion
+	case otsUtilsParams.DefaultMaxVersion > 0:
+		maxVersion = otsUtilsParams.DefaultMaxVersion
+	case p.TimeRange != nil:
+		maxVersion = 0
+	}
+
+	if desc := cachedTableMeta(otsUtilsParams); desc != nil && desc.MaxVersions > 0 && int(maxVersion) > desc.MaxVersions {
+		return 0, fmt.Errorf("MaxVersion %d exceeds the %d versions kept by table '%s'", maxVersion, desc.MaxVersions, otsUtilsParams.TableName)
+	}
+	return maxVersion, nil
+}
+
+// validateTimeRange checks that tr is either a single timestamp or a non-empty [Start, End)
+// interval of non-negative timestamps, as the service requires.
+func validateTimeRange(tr *tablestore.TimeRange) error {
+	if tr.Specific != 0 {
+		if tr.Start != 0 || tr.End != 0 {
+			return fmt.Errorf("TimeRange sets both Specific and Start/End")
+		}
+		if tr.Specific < 0 {
+			return fmt.Errorf("TimeRange Specific must not be negative, got %d", tr.Specific)
+		}
+		return nil
+	}
+	if tr.Start < 0 {
+		return fmt.Errorf("TimeRange Start must not be negative, got %d", tr.Start)
+	}
+	if tr.End <= tr.Start {
+		return fmt.Errorf("TimeRange End %d must be after Start %d", tr.End, tr.Start)
+	}
+	return nil
+}
+
+func executeGetRow(client OtsClient, req any) (any, error) {
+	return client.GetRow(req.(*tablestore.GetRowRequest))
+}
+
+// ExistsRow reports whether the row of obj exists. Every pk field of obj must be set. Only the
+// first primary key column is read back, so the call consumes as little read capacity as a
+// GetRow can.
+//
+// Example usage:
+//
+//	exists, err := ExistsRow(ctx, &MyRow{PK1: tea.String("pk1value")})
+func ExistsRow(ctx context.Context, obj any) (bool, error) {
+	if err := checkPkFieldsSet(obj); err != nil {
+		return false, err
+	}
+
+	build := func(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+		req, err := buildGetRowRequest(ctx, otsParams, logger, obj, GetRowParams{MaxVersion: 1})
 		if err != nil {
 			return nil, err
 		}
-		for _, pk := range pks {
-			criteria.PrimaryKey.AddPrimaryKeyColumn(pk.Key, pk.Value)
+		criteria := req.(*tablestore.GetRowRequest).SingleRowQueryCriteria
+		criteria.AddColumnToGet(criteria.PrimaryKey.PrimaryKeys[0].ColumnName)
+		return req, nil
+	}
+	exists := false
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		exists = len(resp.(*tablestore.GetRowResponse).PrimaryKey.PrimaryKeys) > 0
+		return nil
+	}
+
+	err := executeOTSOperation(ctx, "ExistsRow", obj, build, executeGetRow, handleResp)
+	return exists, err
+}
+
+// checkPkFieldsSet checks that obj is a pointer to a row struct whose pk fields are all set.
+func checkPkFieldsSet(obj any) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("obj must be a non-nil pointer to struct, got %T", obj)
+	}
+	v = v.Elem()
+
+	meta, err := getStructMeta(v.Type())
+	if err != nil {
+		return err
+	}
+	if len(meta.pkFields) == 0 {
+		return fmt.Errorf("%s has no pk-tagged fields", v.Type())
+	}
+	for _, i := range meta.pkFields {
+		fm := &meta.fields[i]
+		if _, skip, err := fm.value(fm.field(v)); err != nil {
+			return err
+		} else if skip {
+			return fmt.Errorf("primary key field %s of %s is nil", fm.name, v.Type())
 		}
+	}
+	return nil
+}
 
-		return &tablestore.GetRowRequest{SingleRowQueryCriteria: criteria}, nil
+// checkPrimaryKeyComplete checks that pks, the primary key columns parsed from the row struct
+// obj, hold a value for every pk field of obj, since a single-row operation needs the whole
+// primary key. The nil auto-increment field PutRow lets the service assign is allowed when
+// allowAuto is set.
+func checkPrimaryKeyComplete(obj any, pks []KeyValue, allowAuto bool) error {
+	if _, ok := obj.(*rowKeyValues); ok {
+		return nil
+	}
+	meta, err := getStructMeta(reflect.TypeOf(obj).Elem())
+	if err != nil {
+		return err
+	}
+	if len(pks) == len(meta.pkFields) {
+		return nil
 	}
 
-	execute := func(client *tablestore.TableStoreClient, req any) (any, error) {
-		return client.GetRow(req.(*tablestore.GetRowRequest))
+	var missing []string
+	for _, i := range meta.pkFields {
+		fm := &meta.fields[i]
+		if allowAuto && fm.pk.auto || slices.ContainsFunc(pks, func(pk KeyValue) bool { return pk.Key == fm.column }) {
+			continue
+		}
+		missing = append(missing, fm.column)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing primary key field(s): %s", strings.Join(missing, ", "))
 	}
+	return nil
+}
 
-	handleResp := func(logger *zerolog.Logger, resp any, obj any) error {
-		getResp := resp.(*tablestore.GetRowResponse)
+// rowFromGetRowResponse converts the primary key and columns of a GetRow response to key-value pairs.
+func rowFromGetRowResponse(getResp *tablestore.GetRowResponse) (pks []KeyValue, cols []KeyValue) {
+	return primaryKeyToKeyValues(&getResp.PrimaryKey), columnsToKeyValues(getResp.Columns)
+}
+
+// primaryKeyToKeyValues converts a primary key returned by the SDK to key-value pairs.
+func primaryKeyToKeyValues(primaryKey *tablestore.PrimaryKey) []KeyValue {
+	pks := make([]KeyValue, 0)
+	if primaryKey == nil {
+		return pks
+	}
+	for _, pk := range primaryKey.PrimaryKeys {
+		pks = append(pks, KeyValue{Key: pk.ColumnName, Value: pk.Value})
+	}
+	return pks
+}
 
-		pks := make([]KeyValue, 0)
-		for _, pk := range getResp.PrimaryKey.PrimaryKeys {
-			pks = append(pks, KeyValue{Key: pk.ColumnName, Value: pk.Value})
+// columnsToKeyValues converts attribute columns returned by the SDK to key-value pairs.
+// When several versions of a column are returned, only the newest one is kept.
+func columnsToKeyValues(columns []*tablestore.AttributeColumn) []KeyValue {
+	cols := make([]KeyValue, 0, len(columns))
+	index := make(map[string]int, len(columns))
+	timestamps := make([]int64, 0, len(columns))
+	for _, col := range columns {
+		if i, ok := index[col.ColumnName]; ok {
+			if col.Timestamp > timestamps[i] {
+				cols[i].Value = col.Value
+				timestamps[i] = col.Timestamp
+			}
+			continue
 		}
+		index[col.ColumnName] = len(cols)
+		cols = append(cols, KeyValue{Key: col.ColumnName, Value: col.Value})
+		timestamps = append(timestamps, col.Timestamp)
+	}
+	return cols
+}
+
+// DeleteRow deletes a row from the table.
+// The obj parameter should be a pointer to a struct with fields tagged with "json" and "pk".
+// Only the fields tagged with "pk" are used, to locate the row; other fields are ignored.
+// Every pk field must be set.
+//
+// Example usage:
+//
+//	row := MyRow{
+//	    PK1: tea.String("pk1value"),
+//	}
+//	err := DeleteRow(ctx, &row)
+//
+//	// Delete only an existing row whose status is "done"
+//	expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+//	err = DeleteRow(ctx, &row, DeleteRowParams{
+//	    RowExistenceExpectation: &expectExist,
+//	    ColumnCondition:         tablestore.NewSingleColumnCondition("status", tablestore.CT_EQUAL, "done"),
+//	})
+func DeleteRow(ctx context.Context, obj any, params ...DeleteRowParams) error {
+	return executeOTSOperation(ctx, "DeleteRow", obj, buildDeleteRowRequest, executeDeleteRow, nil, toAnySlice(params)...)
+}
 
-		cols := make([]KeyValue, 0)
-		for _, col := range getResp.Columns {
-			cols = append(cols, KeyValue{Key: col.ColumnName, Value: col.Value})
+func buildDeleteRowRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	rowExistenceExpectation := tablestore.RowExistenceExpectation_IGNORE
+	var p DeleteRowParams
+	if len(params) > 0 {
+		p, _ = params[0].(DeleteRowParams)
+		if p.RowExistenceExpectation != nil {
+			rowExistenceExpectation = *p.RowExistenceExpectation
 		}
+	}
 
-		return ParseResult(ctx, obj, pks, cols)
+	deleteRowChange := &tablestore.DeleteRowChange{
+		TableName:     otsParams.TableName,
+		PrimaryKey:    &tablestore.PrimaryKey{},
+		TransactionId: ctxTransactionId(ctx, p.TransactionId),
 	}
+	deleteRowChange.SetCondition(rowExistenceExpectation)
+	if p.ColumnCondition != nil {
+		deleteRowChange.SetColumnCondition(p.ColumnCondition)
+	}
+
+	pks, _, err := parseRow(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPrimaryKeyComplete(obj, pks, false); err != nil {
+		return nil, err
+	}
+	for _, pk := range pks {
+		deleteRowChange.PrimaryKey.AddPrimaryKeyColumn(pk.Key, pk.Value)
+	}
+
+	return &tablestore.DeleteRowRequest{DeleteRowChange: deleteRowChange}, nil
+}
 
-	return executeOTSOperation(ctx, "GetRow", obj, buildReq, execute, handleResp, toAnySlice(params)...)
-}
\ No newline at end of file
+func executeDeleteRow(client OtsClient, req any) (any, error) {
+	return client.DeleteRow(req.(*tablestore.DeleteRowRequest))
+}
@@ -0,0 +1,189 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// rowKeyValues is the obj passed through the executor by the map-based operations,
+// standing in for a row struct.
+type rowKeyValues struct {
+	PrimaryKey []KeyValue `json:"primaryKey"`
+	Columns    []KeyValue `json:"columns,omitempty"`
+}
+
+// parseRow extracts the primary key and attribute columns from either a row struct
+// or the *rowKeyValues of the map-based operations, and checks them against the size limits.
+func parseRow(ctx context.Context, obj any) (pks []KeyValue, cols []KeyValue, err error) {
+	if kv, ok := obj.(*rowKeyValues); ok {
+		if err := validateKeyValues("primary key", kv.PrimaryKey); err != nil {
+			return nil, nil, err
+		}
+		if err := validateKeyValues("column", kv.Columns); err != nil {
+			return nil, nil, err
+		}
+		pks, cols = kv.PrimaryKey, orderColumns(kv.Columns)
+	} else {
+		pks, cols, err = ParseObj(ctx, obj)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := validateRowLimits(pks, cols); err != nil {
+		return nil, nil, err
+	}
+	return pks, cols, nil
+}
+
+// validateKeyValues checks that every pair has a name and a value of a supported type.
+func validateKeyValues(kind string, kvs []KeyValue) error {
+	for i, kv := range kvs {
+		if kv.Key == "" {
+			return fmt.Errorf("%s at index %d has an empty name", kind, i)
+		}
+		switch kv.Value.(type) {
+		case string, int64, []byte:
+		default:
+			return fmt.Errorf("%s %q has invalid type: %T. Only string, int64, and []byte are allowed", kind, kv.Key, kv.Value)
+		}
+	}
+	return nil
+}
+
+// PutRowMap inserts a row described by key-value pairs instead of a struct.
+// pks must list the primary key columns in schema order.
+// It behaves exactly like PutRow, including the default EXPECT_NOT_EXIST condition.
+//
+// Example usage:
+//
+//	err := PutRowMap(ctx,
+//	    []KeyValue{{Key: "pk1", Value: "pk1value"}},
+//	    []KeyValue{{Key: "col1", Value: int64(42)}},
+//	)
+func PutRowMap(ctx context.Context, pks []KeyValue, cols []KeyValue, params ...PutRowParams) error {
+	obj := &rowKeyValues{PrimaryKey: pks, Columns: cols}
+	return executeOTSOperation(ctx, "PutRowMap", obj, buildPutRowRequest, executePutRow, nil, toAnySlice(params)...)
+}
+
+// GetRowMap retrieves the attribute columns of the row with the given primary key.
+// cols is non-nil (possibly empty) when the row exists; a missing row is reported as by GetRow.
+//
+// Example usage:
+//
+//	cols, err := GetRowMap(ctx, []KeyValue{{Key: "pk1", Value: "pk1value"}})
+func GetRowMap(ctx context.Context, pks []KeyValue, params ...GetRowParams) (cols []KeyValue, err error) {
+	var getResp *tablestore.GetRowResponse
+	obj := &rowKeyValues{PrimaryKey: pks}
+	err = executeOTSOperation(transactionCtx(ctx, params), "GetRowMap", obj, buildGetRowRequest, executeGetRow, captureGetRowResponse(&getResp), toAnySlice(params)...)
+	if err != nil {
+		return nil, err
+	}
+	if getResp == nil {
+		return nil, rowNotFound(params)
+	}
+	_, cols = rowFromGetRowResponse(getResp)
+	return cols, nil
+}
+
+// GetRowToMap retrieves the attribute columns of the row with the given primary key as a map
+// from column name to value, for reading rows without a struct. Values have the same Go types
+// as in GetRowMap, and only the newest version of each column is returned.
+// A missing row is reported as by GetRow, with a nil map; set GetRowParams.IncludePrimaryKey to
+// also get the primary key columns.
+//
+// Example usage:
+//
+//	row, err := GetRowToMap(ctx, pks, GetRowParams{IncludePrimaryKey: true})
+func GetRowToMap(ctx context.Context, pks []KeyValue, params ...GetRowParams) (map[string]any, error) {
+	var getResp *tablestore.GetRowResponse
+	obj := &rowKeyValues{PrimaryKey: pks}
+	err := executeOTSOperation(transactionCtx(ctx, params), "GetRowToMap", obj, buildGetRowRequest, executeGetRow, captureGetRowResponse(&getResp), toAnySlice(params)...)
+	if err != nil {
+		return nil, err
+	}
+	if getResp == nil {
+		return nil, rowNotFound(params)
+	}
+
+	pkKvs, cols := rowFromGetRowResponse(getResp)
+	row := make(map[string]any, len(pkKvs)+len(cols))
+	if len(params) > 0 && params[0].IncludePrimaryKey {
+		for _, kv := range pkKvs {
+			row[kv.Key] = kv.Value
+		}
+	}
+	for _, kv := range cols {
+		row[kv.Key] = kv.Value
+	}
+	return row, nil
+}
+
+// VersionedValue is one version of a column value.
+type VersionedValue struct {
+	Value     any
+	Timestamp int64
+}
+
+// GetRowVersionsToMap is like GetRowToMap but returns up to GetRowParams.MaxVersion versions of
+// each column, newest first. Without GetRowParams.MaxVersion, OtsUtilsParams.DefaultMaxVersion
+// is used. Primary key columns, when included, have a single version with a
+// zero Timestamp. A missing row is reported as by GetRow, with a nil map.
+//
+// Example usage:
+//
+//	versions, err := GetRowVersionsToMap(ctx, pks, GetRowParams{MaxVersion: 3})
+func GetRowVersionsToMap(ctx context.Context, pks []KeyValue, params ...GetRowParams) (map[string][]VersionedValue, error) {
+	var getResp *tablestore.GetRowResponse
+	obj := &rowKeyValues{PrimaryKey: pks}
+	err := executeOTSOperation(transactionCtx(ctx, params), "GetRowVersionsToMap", obj, buildGetRowRequest, executeGetRow, captureGetRowResponse(&getResp), toAnySlice(params)...)
+	if err != nil {
+		return nil, err
+	}
+	if getResp == nil {
+		return nil, rowNotFound(params)
+	}
+
+	row := make(map[string][]VersionedValue, len(getResp.Columns))
+	if len(params) > 0 && params[0].IncludePrimaryKey {
+		for _, kv := range primaryKeyToKeyValues(&getResp.PrimaryKey) {
+			row[kv.Key] = []VersionedValue{{Value: kv.Value}}
+		}
+	}
+	for _, col := range getResp.Columns {
+		row[col.ColumnName] = append(row[col.ColumnName], VersionedValue{Value: col.Value, Timestamp: col.Timestamp})
+	}
+	for _, versions := range row {
+		sort.SliceStable(versions, func(i, j int) bool { return versions[i].Timestamp > versions[j].Timestamp })
+	}
+	return row, nil
+}
+
+// captureGetRowResponse returns a response handler storing the GetRow response into dst,
+// leaving it nil when the row does not exist.
+func captureGetRowResponse(dst **tablestore.GetRowResponse) func(context.Context, *zerolog.Logger, any, any) error {
+	return func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		if r := resp.(*tablestore.GetRowResponse); len(r.PrimaryKey.PrimaryKeys) > 0 {
+			*dst = r
+		}
+		return nil
+	}
+}
+
+// UpdateRowMap updates the row with the given primary key, putting cols in addition to
+// UpdateRowParams.UpdatedColumns. It behaves exactly like UpdateRow.
+func UpdateRowMap(ctx context.Context, pks []KeyValue, cols []KeyValue, params ...UpdateRowParams) error {
+	obj := &rowKeyValues{PrimaryKey: pks, Columns: cols}
+	return executeOTSOperation(ctx, "UpdateRowMap", obj, buildUpdateRowRequest, executeUpdateRow, nil, toAnySlice(params)...)
+}
+
+// DeleteRowMap deletes the row with the given primary key. It behaves exactly like DeleteRow.
+func DeleteRowMap(ctx context.Context, pks []KeyValue, params ...DeleteRowParams) error {
+	obj := &rowKeyValues{PrimaryKey: pks}
+	return executeOTSOperation(ctx, "DeleteRowMap", obj, buildDeleteRowRequest, executeDeleteRow, nil, toAnySlice(params)...)
+}
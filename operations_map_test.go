@@ -0,0 +1,262 @@
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRowMapRoundTrip(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	pks := []KeyValue{{Key: "pk1", Value: "user1"}, {Key: "pk2", Value: int64(7)}}
+	err := PutRowMap(ctx, pks, []KeyValue{
+		{Key: "col1", Value: "hello"},
+		{Key: "col2", Value: int64(42)},
+		{Key: "bin", Value: []byte{1, 2}},
+	})
+	ast.NoError(err)
+
+	// PutRowMap 与 PutRow 一样默认 EXPECT_NOT_EXIST
+	ast.Error(PutRowMap(ctx, pks, nil))
+
+	cols, err := GetRowMap(ctx, pks)
+	ast.NoError(err)
+	ast.ElementsMatch([]KeyValue{
+		{Key: "col1", Value: "hello"},
+		{Key: "col2", Value: int64(42)},
+		{Key: "bin", Value: []byte{1, 2}},
+	}, cols)
+
+	// map 写入的行可以用结构体读取
+	obj := TestRow{Pk1: tea.String("user1"), Pk2: tea.Int64(7)}
+	ast.NoError(GetRow(ctx, &obj))
+	ast.Equal("hello", tea.StringValue(obj.Col1))
+	ast.Equal(int64(42), tea.Int64Value(obj.Col2))
+
+	expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+	err = UpdateRowMap(ctx, pks, []KeyValue{{Key: "col2", Value: int64(43)}}, UpdateRowParams{
+		RowExistenceExpectation: &expectExist,
+		DeletedColumns:          []string{"bin"},
+	})
+	ast.NoError(err)
+
+	cols, err = GetRowMap(ctx, pks)
+	ast.NoError(err)
+	ast.ElementsMatch([]KeyValue{
+		{Key: "col1", Value: "hello"},
+		{Key: "col2", Value: int64(43)},
+	}, cols)
+
+	ast.NoError(DeleteRowMap(ctx, pks))
+	cols, err = GetRowMap(ctx, pks)
+	ast.NoError(err)
+	ast.Nil(cols)
+}
+
+func TestRowMapValidation(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	pks := []KeyValue{{Key: "pk1", Value: "user1"}, {Key: "pk2", Value: int64(7)}}
+
+	err := PutRowMap(ctx, []KeyValue{{Key: "pk1", Value: "user1"}, {Key: "pk2", Value: 7}}, nil)
+	ast.ErrorContains(err, `primary key "pk2" has invalid type: int`)
+
+	err = PutRowMap(ctx, pks, []KeyValue{{Key: "col1", Value: map[string]string{}}})
+	ast.ErrorContains(err, `column "col1" has invalid type`)
+
+	err = UpdateRowMap(ctx, pks, []KeyValue{{Key: "", Value: "x"}})
+	ast.ErrorContains(err, "column at index 0 has an empty name")
+
+	_, err = GetRowMap(ctx, []KeyValue{{Key: "pk1", Value: nil}})
+	ast.ErrorContains(err, `primary key "pk1" has invalid type: <nil>`)
+
+	// 校验失败时不会发出请求
+	ast.Equal(0, fake.CallCount("PutRow"))
+	ast.Equal(0, fake.CallCount("UpdateRow"))
+	ast.Equal(0, fake.CallCount("GetRow"))
+}
+
+func TestGetRowToMap(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	meta := &tablestore.TableMeta{TableName: "versioned"}
+	meta.AddPrimaryKeyColumn("pk1", tablestore.PrimaryKeyType_STRING)
+	_, err := fake.CreateTable(&tablestore.CreateTableRequest{
+		TableMeta:   meta,
+		TableOption: tablestore.NewTableOption(-1, 3),
+	})
+	ast.NoError(err)
+	o := OtsUtilsParams{Client: fake, TableName: "versioned"}
+	ctx = o.WithContext(ctx)
+
+	pks, err := PK().String("pk1", "a").Build()
+	ast.NoError(err)
+
+	// 行不存在时与 GetRow 一致，不返回错误，结果为 nil
+	row, err := GetRowToMap(ctx, pks)
+	ast.NoError(err)
+	ast.Nil(row)
+	versions, err := GetRowVersionsToMap(ctx, pks)
+	ast.NoError(err)
+	ast.Nil(versions)
+	cols, err := GetRowMap(ctx, pks)
+	ast.NoError(err)
+	ast.Nil(cols)
+
+	// 写入 col1 的三个版本
+	for ts := int64(1000); ts <= 3000; ts += 1000 {
+		change := &tablestore.UpdateRowChange{TableName: "versioned", PrimaryKey: &tablestore.PrimaryKey{}}
+		change.PrimaryKey.AddPrimaryKeyColumn("pk1", "a")
+		change.PutColumnWithTimestamp("col1", fmt.Sprintf("v%d", ts/1000), ts)
+		change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		_, err := fake.UpdateRow(&tablestore.UpdateRowRequest{UpdateRowChange: change})
+		ast.NoError(err)
+	}
+	ast.NoError(UpdateRowMap(ctx, pks, []KeyValue{{Key: "col2", Value: int64(7)}}))
+
+	row, err = GetRowToMap(ctx, pks)
+	ast.NoError(err)
+	ast.Equal(map[string]any{"col1": "v3", "col2": int64(7)}, row)
+
+	row, err = GetRowToMap(ctx, pks, GetRowParams{IncludePrimaryKey: true})
+	ast.NoError(err)
+	ast.Equal(map[string]any{"pk1": "a", "col1": "v3", "col2": int64(7)}, row)
+
+	// 多版本读取时结构体与 map 仍取最新版本
+	row, err = GetRowToMap(ctx, pks, GetRowParams{MaxVersion: 3})
+	ast.NoError(err)
+	ast.Equal("v3", row["col1"])
+	type versionedRow struct {
+		Pk1  *string `json:"pk1" pk:"1"`
+		Col1 *string `json:"col1"`
+	}
+	obj := versionedRow{Pk1: tea.String("a")}
+	ast.NoError(GetRow(ctx, &obj, GetRowParams{MaxVersion: 3}))
+	ast.Equal("v3", tea.StringValue(obj.Col1))
+
+	versions, err = GetRowVersionsToMap(ctx, pks, GetRowParams{MaxVersion: 2, IncludePrimaryKey: true})
+	ast.NoError(err)
+	ast.Equal([]VersionedValue{{Value: "v3", Timestamp: 3000}, {Value: "v2", Timestamp: 2000}}, versions["col1"])
+	ast.Equal([]VersionedValue{{Value: "a"}}, versions["pk1"])
+	ast.Len(versions["col2"], 1)
+}
+
+func TestDefaultMaxVersion(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	meta := &tablestore.TableMeta{TableName: "versioned"}
+	meta.AddPrimaryKeyColumn("pk1", tablestore.PrimaryKeyType_STRING)
+	_, err := fake.CreateTable(&tablestore.CreateTableRequest{
+		TableMeta:   meta,
+		TableOption: tablestore.NewTableOption(-1, 3),
+	})
+	ast.NoError(err)
+	pks, err := PK().String("pk1", "a").Build()
+	ast.NoError(err)
+	for ts := int64(1000); ts <= 3000; ts += 1000 {
+		change := &tablestore.UpdateRowChange{TableName: "versioned", PrimaryKey: &tablestore.PrimaryKey{}}
+		change.PrimaryKey.AddPrimaryKeyColumn("pk1", "a")
+		change.PutColumnWithTimestamp("col1", fmt.Sprintf("v%d", ts/1000), ts)
+		change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		_, err := fake.UpdateRow(&tablestore.UpdateRowRequest{UpdateRowChange: change})
+		ast.NoError(err)
+	}
+
+	// 未设置 GetRowParams.MaxVersion 时使用 DefaultMaxVersion
+	o := OtsUtilsParams{Client: fake, TableName: "versioned", DefaultMaxVersion: 3}
+	ctx = o.WithContext(ctx)
+	versions, err := GetRowVersionsToMap(ctx, pks)
+	ast.NoError(err)
+	ast.Len(versions["col1"], 3)
+	versions, err = GetRowVersionsToMap(ctx, pks, GetRowParams{MaxVersion: 1})
+	ast.NoError(err)
+	ast.Len(versions["col1"], 1)
+
+	_, err = GetRowVersionsToMap(ctx, pks, GetRowParams{MaxVersion: -1})
+	ast.ErrorContains(err, "at least 1")
+
+	// 表元数据已缓存时，超过表的 MaxVersions 被拒绝
+	_, err = TableMeta(ctx)
+	ast.NoError(err)
+	_, err = GetRowVersionsToMap(ctx, pks, GetRowParams{MaxVersion: 4})
+	ast.ErrorContains(err, "exceeds the 3 versions")
+	o4 := OtsUtilsParams{Client: fake, TableName: "versioned", DefaultMaxVersion: 4}
+	_, err = GetRowToMap(o4.WithContext(ctx), pks)
+	ast.ErrorContains(err, "exceeds the 3 versions")
+
+	t.Run("negative default", func(t *testing.T) {
+		o := OtsUtilsParams{Client: fake, TableName: "versioned", DefaultMaxVersion: -1}
+		assert.Panics(t, func() { o.WithContext(context.Background()) })
+	})
+}
+
+func TestGetRowTimeRange(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	meta := &tablestore.TableMeta{TableName: "versioned"}
+	meta.AddPrimaryKeyColumn("pk1", tablestore.PrimaryKeyType_STRING)
+	_, err := fake.CreateTable(&tablestore.CreateTableRequest{
+		TableMeta:   meta,
+		TableOption: tablestore.NewTableOption(-1, 3),
+	})
+	ast.NoError(err)
+	o := OtsUtilsParams{Client: fake, TableName: "versioned"}
+	ctx = o.WithContext(ctx)
+	pks, err := PK().String("pk1", "a").Build()
+	ast.NoError(err)
+	for ts := int64(1000); ts <= 3000; ts += 1000 {
+		change := &tablestore.UpdateRowChange{TableName: "versioned", PrimaryKey: &tablestore.PrimaryKey{}}
+		change.PrimaryKey.AddPrimaryKeyColumn("pk1", "a")
+		change.PutColumnWithTimestamp("col1", fmt.Sprintf("v%d", ts/1000), ts)
+		change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		_, err := fake.UpdateRow(&tablestore.UpdateRowRequest{UpdateRowChange: change})
+		ast.NoError(err)
+	}
+
+	// 只设置 TimeRange 时读取区间内的全部版本
+	versions, err := GetRowVersionsToMap(ctx, pks, GetRowParams{TimeRange: &tablestore.TimeRange{Start: 1000, End: 3000}})
+	ast.NoError(err)
+	ast.Equal([]VersionedValue{{Value: "v2", Timestamp: 2000}, {Value: "v1", Timestamp: 1000}}, versions["col1"])
+
+	// MaxVersion 限制区间内的版本数
+	versions, err = GetRowVersionsToMap(ctx, pks, GetRowParams{MaxVersion: 1, TimeRange: &tablestore.TimeRange{Start: 0, End: 2500}})
+	ast.NoError(err)
+	ast.Equal([]VersionedValue{{Value: "v2", Timestamp: 2000}}, versions["col1"])
+
+	// 结构体取区间内的最新版本
+	type versionedRow struct {
+		Pk1  *string `json:"pk1" pk:"1"`
+		Col1 *string `json:"col1"`
+	}
+	obj := versionedRow{Pk1: tea.String("a")}
+	ast.NoError(GetRow(ctx, &obj, GetRowParams{TimeRange: &tablestore.TimeRange{Specific: 1000}}))
+	ast.Equal("v1", tea.StringValue(obj.Col1))
+
+	// 区间内没有版本时不返回任何属性列
+	row, err := GetRowToMap(ctx, pks, GetRowParams{TimeRange: &tablestore.TimeRange{Start: 5000, End: 6000}})
+	ast.NoError(err)
+	ast.Empty(row)
+
+	for _, tc := range []struct {
+		tr  tablestore.TimeRange
+		err string
+	}{
+		{tablestore.TimeRange{Specific: 1000, End: 2000}, "TimeRange sets both Specific and Start/End"},
+		{tablestore.TimeRange{Specific: -1}, "TimeRange Specific must not be negative, got -1"},
+		{tablestore.TimeRange{Start: -1, End: 10}, "TimeRange Start must not be negative, got -1"},
+		{tablestore.TimeRange{Start: 2000, End: 2000}, "TimeRange End 2000 must be after Start 2000"},
+	} {
+		_, err := GetRowToMap(ctx, pks, GetRowParams{TimeRange: &tc.tr})
+		ast.EqualError(err, tc.err)
+	}
+}
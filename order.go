@@ -0,0 +1,63 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync/atomic"
+)
+
+// ColumnOrder is the order of attribute columns in ParseObj output, built requests and logs.
+type ColumnOrder int32
+
+const (
+	// ColumnOrderDeclaration orders the attribute columns of a struct by field declaration order.
+	// Map-based operations keep the order of the given key-value pairs.
+	ColumnOrderDeclaration ColumnOrder = iota
+
+	// ColumnOrderLexicographic orders attribute columns by name.
+	ColumnOrderLexicographic
+)
+
+func (o ColumnOrder) String() string {
+	switch o {
+	case ColumnOrderDeclaration:
+		return "declaration"
+	case ColumnOrderLexicographic:
+		return "lexicographic"
+	default:
+		return fmt.Sprintf("ColumnOrder(%d)", int32(o))
+	}
+}
+
+var columnOrder atomic.Int32
+
+// SetColumnOrder sets the package-wide attribute column order. The default is
+// ColumnOrderDeclaration. Primary key columns always follow the pk tag order, and the
+// columns of UpdateRowParams.UpdatedColumns, a map, are always sorted by name.
+// It panics on an unknown order.
+func SetColumnOrder(order ColumnOrder) {
+	if order != ColumnOrderDeclaration && order != ColumnOrderLexicographic {
+		panic(fmt.Sprintf("otsutils: unknown column order %d", int32(order)))
+	}
+	if ColumnOrder(columnOrder.Swap(int32(order))) != order {
+		// Cached struct metadata holds the attribute order
+		invalidateStructMetaCache()
+	}
+}
+
+// currentColumnOrder returns the order set with SetColumnOrder.
+func currentColumnOrder() ColumnOrder {
+	return ColumnOrder(columnOrder.Load())
+}
+
+// orderColumns returns kvs in the current column order, copying before sorting.
+func orderColumns(kvs []KeyValue) []KeyValue {
+	if currentColumnOrder() != ColumnOrderLexicographic {
+		return kvs
+	}
+	sorted := slices.Clone(kvs)
+	slices.SortStableFunc(sorted, func(a, b KeyValue) int { return cmp.Compare(a.Key, b.Key) })
+	return sorted
+}
@@ -0,0 +1,127 @@
+package otsutils
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnOrder(t *testing.T) {
+	ast := assert.New(t)
+	t.Cleanup(func() { SetColumnOrder(ColumnOrderDeclaration) })
+
+	type row struct {
+		Zeta  *string `json:"zeta"`
+		Pk1   *string `json:"pk1" pk:"1"`
+		Alpha *string `json:"alpha"`
+		Mid   *int64  `json:"mid"`
+	}
+	obj := row{Zeta: tea.String("z"), Pk1: tea.String("p"), Alpha: tea.String("a"), Mid: tea.Int64(1)}
+
+	// 默认按声明顺序
+	_, cols, err := ParseObj(context.Background(), &obj)
+	ast.NoError(err)
+	ast.Equal([]string{"zeta", "alpha", "mid"}, columnNames(cols))
+
+	// 切换后缓存失效，按字典序
+	SetColumnOrder(ColumnOrderLexicographic)
+	_, cols, err = ParseObj(context.Background(), &obj)
+	ast.NoError(err)
+	ast.Equal([]string{"alpha", "mid", "zeta"}, columnNames(cols))
+
+	SetColumnOrder(ColumnOrderDeclaration)
+	_, cols, err = ParseObj(context.Background(), &obj)
+	ast.NoError(err)
+	ast.Equal([]string{"zeta", "alpha", "mid"}, columnNames(cols))
+
+	ast.Panics(func() { SetColumnOrder(ColumnOrder(5)) })
+	ast.Equal("lexicographic", ColumnOrderLexicographic.String())
+}
+
+func TestColumnOrderRacingBuild(t *testing.T) {
+	ast := assert.New(t)
+	t.Cleanup(func() { SetColumnOrder(ColumnOrderDeclaration) })
+
+	type row struct {
+		Zeta  *string `json:"zeta"`
+		Pk1   *string `json:"pk1" pk:"1"`
+		Alpha *string `json:"alpha"`
+	}
+	typ := reflect.TypeOf(row{})
+
+	// 模拟在 SetColumnOrder 之前开始构建、之后才写入缓存的 getStructMeta
+	generation := structMetaGeneration.Load()
+	stale, err := buildStructMeta(typ)
+	ast.NoError(err)
+	SetColumnOrder(ColumnOrderLexicographic)
+	structMetaCache.Store(typ, &structMetaEntry{meta: stale, generation: generation})
+
+	// 旧一代的缓存项被忽略
+	meta, err := getStructMeta(typ)
+	ast.NoError(err)
+	ast.NotSame(stale, meta)
+	_, cols, err := ParseObj(context.Background(), &row{Zeta: tea.String("z"), Pk1: tea.String("p"), Alpha: tea.String("a")})
+	ast.NoError(err)
+	ast.Equal([]string{"alpha", "zeta"}, columnNames(cols))
+}
+
+func TestColumnOrderInRequests(t *testing.T) {
+	ast := assert.New(t)
+	t.Cleanup(func() { SetColumnOrder(ColumnOrderDeclaration) })
+	ctx, fake := newFakeContext(t)
+
+	var puts []*tablestore.PutRowRequest
+	var updates []*tablestore.UpdateRowRequest
+	fake.Intercept = func(operation string, request any) error {
+		switch r := request.(type) {
+		case *tablestore.PutRowRequest:
+			puts = append(puts, r)
+		case *tablestore.UpdateRowRequest:
+			updates = append(updates, r)
+		}
+		return nil
+	}
+
+	pks := []KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}}
+	cols := []KeyValue{{Key: "col3", Value: "c"}, {Key: "col1", Value: "a"}}
+
+	// map 写入默认保持传入顺序
+	ast.NoError(PutRowMap(ctx, pks, cols))
+	ast.Equal([]string{"col3", "col1"}, putColumnNames(puts[0]))
+
+	SetColumnOrder(ColumnOrderLexicographic)
+	ast.NoError(DeleteRowMap(ctx, pks))
+	ast.NoError(PutRowMap(ctx, pks, cols))
+	ast.Equal([]string{"col1", "col3"}, putColumnNames(puts[1]))
+
+	// UpdatedColumns 总是按列名排序
+	SetColumnOrder(ColumnOrderDeclaration)
+	ast.NoError(UpdateRow(ctx, &TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)}, UpdateRowParams{
+		UpdatedColumns: map[string]any{"c": "3", "a": "1", "b": "2"},
+	}))
+	var names []string
+	for _, col := range updates[0].UpdateRowChange.Columns {
+		names = append(names, col.ColumnName)
+	}
+	ast.Equal([]string{"a", "b", "c"}, names)
+}
+
+func columnNames(kvs []KeyValue) []string {
+	names := make([]string, len(kvs))
+	for i, kv := range kvs {
+		names[i] = kv.Key
+	}
+	return names
+}
+
+func putColumnNames(req *tablestore.PutRowRequest) []string {
+	names := make([]string, len(req.PutRowChange.Columns))
+	for i, col := range req.PutRowChange.Columns {
+		names[i] = col.ColumnName
+	}
+	return names
+}
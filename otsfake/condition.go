@@ -0,0 +1,128 @@
+package otsfake
+
+import (
+	"bytes"
+	"cmp"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// matchColumnCondition evaluates a column condition against a row, which is nil when the row
+// does not exist. Only single column and composite conditions are supported.
+func (c *Client) matchColumnCondition(filter tablestore.ColumnFilter, r *row) (bool, error) {
+	switch f := filter.(type) {
+	case *tablestore.SingleColumnCondition:
+		if f.ColumnName == nil || f.Comparator == nil {
+			return false, c.newError(CodeParameterInvalid, "Column name and comparator are required in a column condition.")
+		}
+		var versions []*tablestore.AttributeColumn
+		if r != nil {
+			versions = r.cols[*f.ColumnName]
+		}
+		if len(versions) == 0 {
+			return !f.FilterIfMissing, nil
+		}
+		if f.LatestVersionOnly {
+			versions = versions[:1]
+		}
+		for _, version := range versions {
+			ok, err := c.compareColumnValue(version.Value, *f.Comparator, f.ColumnValue)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case *tablestore.CompositeColumnValueFilter:
+		if f.Operator == tablestore.LO_NOT {
+			if len(f.Filters) != 1 {
+				return false, c.newError(CodeParameterInvalid, "NOT requires exactly one sub condition.")
+			}
+			ok, err := c.matchColumnCondition(f.Filters[0], r)
+			return !ok, err
+		}
+		if len(f.Filters) < 2 {
+			return false, c.newError(CodeParameterInvalid, "AND and OR require at least two sub conditions.")
+		}
+		for _, sub := range f.Filters {
+			ok, err := c.matchColumnCondition(sub, r)
+			if err != nil {
+				return false, err
+			}
+			if f.Operator == tablestore.LO_OR && ok {
+				return true, nil
+			}
+			if f.Operator == tablestore.LO_AND && !ok {
+				return false, nil
+			}
+		}
+		return f.Operator == tablestore.LO_AND, nil
+
+	default:
+		return false, c.newError(CodeParameterInvalid, "Unsupported column condition.")
+	}
+}
+
+// compareColumnValue applies comparator to a stored value and a condition value of the same type.
+// Values of different types never match.
+func (c *Client) compareColumnValue(stored any, comparator tablestore.ComparatorType, want any) (bool, error) {
+	var n int
+	switch s := stored.(type) {
+	case string:
+		w, ok := want.(string)
+		if !ok {
+			return false, nil
+		}
+		n = cmp.Compare(s, w)
+	case int64:
+		w, ok := want.(int64)
+		if !ok {
+			return false, nil
+		}
+		n = cmp.Compare(s, w)
+	case float64:
+		w, ok := want.(float64)
+		if !ok {
+			return false, nil
+		}
+		n = cmp.Compare(s, w)
+	case []byte:
+		w, ok := want.([]byte)
+		if !ok {
+			return false, nil
+		}
+		n = bytes.Compare(s, w)
+	case bool:
+		w, ok := want.(bool)
+		if !ok {
+			return false, nil
+		}
+		switch {
+		case s == w:
+			n = 0
+		case !s:
+			n = -1
+		default:
+			n = 1
+		}
+	default:
+		return false, nil
+	}
+
+	switch comparator {
+	case tablestore.CT_EQUAL:
+		return n == 0, nil
+	case tablestore.CT_NOT_EQUAL:
+		return n != 0, nil
+	case tablestore.CT_GREATER_THAN:
+		return n > 0, nil
+	case tablestore.CT_GREATER_EQUAL:
+		return n >= 0, nil
+	case tablestore.CT_LESS_THAN:
+		return n < 0, nil
+	case tablestore.CT_LESS_EQUAL:
+		return n <= 0, nil
+	default:
+		return false, c.newError(CodeParameterInvalid, "Unsupported comparator.")
+	}
+}
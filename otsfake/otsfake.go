@@ -0,0 +1,1269 @@
+// Package otsfake provides an in-memory fake of the TableStore client for tests.
+//
+// It implements the subset of the *tablestore.TableStoreClient API used by otsutils with
+// simplified but faithful semantics: primary key schema checks, row existence and column conditions,
+// multi-version columns and the error codes the real service returns.
+package otsfake
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/117503445/otsutils/internal/plainbuffer"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// Error codes returned by the fake, matching the real service.
+const (
+	CodeConditionCheckFail = "OTSConditionCheckFail"
+	CodeObjectNotExist     = "OTSObjectNotExist"
+	CodeObjectAlreadyExist = "OTSObjectAlreadyExist"
+	CodeParameterInvalid   = "OTSParameterInvalid"
+)
+
+// Maximum number of rows the service accepts in a batch request.
+const (
+	maxBatchGetRows   = 100
+	maxBatchWriteRows = 200
+)
+
+// Client is an in-memory TableStore client. The zero value is not usable; use New.
+type Client struct {
+	// Intercept, when set, is called before every operation with the operation name
+	// (e.g. "PutRow") and the request. A non-nil error is returned to the caller
+	// instead of executing the operation, which is useful to inject failures or latency.
+	Intercept func(operation string, request any) error
+
+	// RowIntercept, when set, is called for every row change of a BatchWriteRow request with
+	// the table name and the change. A non-nil *tablestore.OtsError is reported as the error
+	// of that row, which is left unchanged, e.g. to throttle some rows of a batch. It is called
+	// with the client locked and must not call the client.
+	RowIntercept func(tableName string, change tablestore.RowChange) error
+
+	// MaxRangeRows caps the number of rows returned by a single GetRange call,
+	// forcing callers to paginate. Zero means no cap beyond the request's Limit.
+	MaxRangeRows int
+
+	// SplitRows makes ComputeSplitPointsBySize cut the table before every SplitRows-th row,
+	// standing in for the service's size-based split points. Zero returns the whole table
+	// as a single split.
+	SplitRows int
+
+	// SearchScore, when set, gives the relevance score of each row returned by Search from its
+	// primary key, standing in for the scoring of the service. Without it, hits carry no score.
+	SearchScore func(pk []*tablestore.PrimaryKeyColumn) float64
+
+	// SQLPageRows caps the number of rows of a single SQLQuery response, which then carries a
+	// NextSearchToken, forcing callers to paginate. Zero returns the whole result set at once.
+	SQLPageRows int
+
+	mu        sync.Mutex
+	tables    map[string]*table
+	calls     map[string]int
+	lastTs    int64
+	lastAuto  int64
+	requestID int
+
+	sqlResults map[string][][]*tablestore.AttributeColumn
+
+	searchTokens   map[string]searchCursor
+	searchTokenSeq int
+
+	transactions   map[string]*transaction
+	transactionSeq int
+}
+
+type table struct {
+	meta     *tablestore.TableMeta
+	option   *tablestore.TableOption
+	reserved tablestore.ReservedThroughput
+	indexes  []*tablestore.IndexMeta
+	rows     map[string]*row
+
+	// searchIndexes maps the search indexes of the table to their schema
+	searchIndexes map[string]*tablestore.IndexSchema
+}
+
+type row struct {
+	pk   []*tablestore.PrimaryKeyColumn
+	cols map[string][]*tablestore.AttributeColumn // versions, newest first
+}
+
+// New creates an empty fake client.
+func New() *Client {
+	return &Client{
+		tables:     make(map[string]*table),
+		calls:      make(map[string]int),
+		sqlResults: make(map[string][][]*tablestore.AttributeColumn),
+
+		searchTokens: make(map[string]searchCursor),
+		transactions: make(map[string]*transaction),
+	}
+}
+
+// CallCount returns how many times the operation has been invoked, including intercepted calls.
+func (c *Client) CallCount(operation string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[operation]
+}
+
+// MustCreateTable creates a table with the given primary key columns, given as
+// alternating name and type pairs, and panics on error.
+//
+//	fake.MustCreateTable("users", "pk1", tablestore.PrimaryKeyType_STRING, "pk2", tablestore.PrimaryKeyType_INTEGER)
+func (c *Client) MustCreateTable(name string, pkNameTypePairs ...any) {
+	meta := &tablestore.TableMeta{TableName: name}
+	for i := 0; i+1 < len(pkNameTypePairs); i += 2 {
+		meta.AddPrimaryKeyColumn(pkNameTypePairs[i].(string), pkNameTypePairs[i+1].(tablestore.PrimaryKeyType))
+	}
+	_, err := c.CreateTable(&tablestore.CreateTableRequest{
+		TableMeta:   meta,
+		TableOption: tablestore.NewTableOption(-1, 1),
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (c *Client) begin(operation string, request any) error {
+	c.mu.Lock()
+	c.calls[operation]++
+	intercept := c.Intercept
+	c.mu.Unlock()
+
+	if intercept != nil {
+		return intercept(operation, request)
+	}
+	return nil
+}
+
+// newError builds an error shaped like the ones the SDK returns for service failures.
+func (c *Client) newError(code, message string) *tablestore.OtsError {
+	status := 400
+	switch code {
+	case CodeConditionCheckFail, CodeObjectAlreadyExist:
+		status = 409
+	case CodeObjectNotExist:
+		status = 404
+	}
+	c.requestID++
+	return &tablestore.OtsError{
+		Code:           code,
+		Message:        message,
+		RequestId:      fmt.Sprintf("fake-%d", c.requestID),
+		HttpStatusCode: status,
+	}
+}
+
+// now returns a strictly increasing millisecond timestamp.
+func (c *Client) now() int64 {
+	ts := time.Now().UnixMilli()
+	if ts <= c.lastTs {
+		ts = c.lastTs + 1
+	}
+	c.lastTs = ts
+	return ts
+}
+
+func (c *Client) table(name string) (*table, error) {
+	t, ok := c.tables[name]
+	if !ok {
+		return nil, c.newError(CodeObjectNotExist, "Requested table does not exist.")
+	}
+	return t, nil
+}
+
+// CreateTable creates a table.
+func (c *Client) CreateTable(request *tablestore.CreateTableRequest) (*tablestore.CreateTableResponse, error) {
+	if err := c.begin("CreateTable", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name := request.TableMeta.TableName
+	if _, ok := c.tables[name]; ok {
+		return nil, c.newError(CodeObjectAlreadyExist, "Requested table already exists.")
+	}
+	if len(request.TableMeta.SchemaEntry) == 0 || len(request.TableMeta.SchemaEntry) > 4 {
+		return nil, c.newError(CodeParameterInvalid, "The number of primary key columns must be in range: [1, 4].")
+	}
+	option := request.TableOption
+	if option == nil {
+		option = tablestore.NewTableOption(-1, 1)
+	}
+	t := &table{meta: request.TableMeta, option: option, rows: make(map[string]*row)}
+	if request.ReservedThroughput != nil {
+		t.reserved = *request.ReservedThroughput
+	}
+	c.tables[name] = t
+	return &tablestore.CreateTableResponse{}, nil
+}
+
+// UpdateTable changes the options and reserved throughput of a table. Like the SDK, it sets
+// both the TTL and the max versions when TableOption is given.
+func (c *Client) UpdateTable(request *tablestore.UpdateTableRequest) (*tablestore.UpdateTableResponse, error) {
+	if err := c.begin("UpdateTable", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if request.TableOption != nil {
+		if request.TableOption.MaxVersion <= 0 {
+			return nil, c.newError(CodeParameterInvalid, "MaxVersions must be greater than 0.")
+		}
+		option := *request.TableOption
+		t.option = &option
+	}
+	if request.ReservedThroughput != nil {
+		t.reserved = *request.ReservedThroughput
+	}
+	option, reserved := *t.option, t.reserved
+	return &tablestore.UpdateTableResponse{TableOption: &option, ReservedThroughput: &reserved}, nil
+}
+
+// DeleteTable deletes a table and all its rows.
+func (c *Client) DeleteTable(request *tablestore.DeleteTableRequest) (*tablestore.DeleteTableResponse, error) {
+	if err := c.begin("DeleteTable", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.table(request.TableName); err != nil {
+		return nil, err
+	}
+	delete(c.tables, request.TableName)
+	return &tablestore.DeleteTableResponse{}, nil
+}
+
+// ListTable lists all table names in lexicographic order.
+func (c *Client) ListTable() (*tablestore.ListTableResponse, error) {
+	if err := c.begin("ListTable", nil); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.tables))
+	for name := range c.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &tablestore.ListTableResponse{TableNames: names}, nil
+}
+
+// DescribeTable returns the schema and options of a table.
+func (c *Client) DescribeTable(request *tablestore.DescribeTableRequest) (*tablestore.DescribeTableResponse, error) {
+	if err := c.begin("DescribeTable", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	option, reserved := *t.option, t.reserved
+	return &tablestore.DescribeTableResponse{
+		TableMeta:          t.meta,
+		TableOption:        &option,
+		ReservedThroughput: &reserved,
+		IndexMetas:         append([]*tablestore.IndexMeta(nil), t.indexes...),
+	}, nil
+}
+
+// CreateIndex adds a secondary index to a table. As in the service, the primary key of the
+// index must be made of primary key and predefined columns of the table and include every
+// primary key column of the table. GetRange reads the index as if it were always in sync: its
+// rows are derived from the rows of the table on every read.
+func (c *Client) CreateIndex(request *tablestore.CreateIndexRequest) (*tablestore.CreateIndexResponse, error) {
+	if err := c.begin("CreateIndex", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(request.MainTableName)
+	if err != nil {
+		return nil, err
+	}
+	meta := request.IndexMeta
+	if _, ok := c.tables[meta.IndexName]; ok {
+		return nil, c.newError(CodeObjectAlreadyExist, "Requested index already exists.")
+	}
+	for _, idx := range t.indexes {
+		if idx.IndexName == meta.IndexName {
+			return nil, c.newError(CodeObjectAlreadyExist, "Requested index already exists.")
+		}
+	}
+
+	columns := make(map[string]bool)
+	for _, s := range t.meta.SchemaEntry {
+		columns[*s.Name] = true
+	}
+	for _, col := range t.meta.DefinedColumns {
+		columns[col.Name] = true
+	}
+	inIndex := make(map[string]bool)
+	for _, name := range meta.Primarykey {
+		if !columns[name] {
+			return nil, c.newError(CodeParameterInvalid, fmt.Sprintf("Column %s is neither a primary key nor a defined column.", name))
+		}
+		inIndex[name] = true
+	}
+	for _, s := range t.meta.SchemaEntry {
+		if !inIndex[*s.Name] {
+			return nil, c.newError(CodeParameterInvalid, fmt.Sprintf("Index primary key must contain primary key column %s of the table.", *s.Name))
+		}
+	}
+
+	index := *meta
+	t.indexes = append(t.indexes, &index)
+	return &tablestore.CreateIndexResponse{}, nil
+}
+
+// DeleteIndex removes a secondary index from a table.
+func (c *Client) DeleteIndex(request *tablestore.DeleteIndexRequest) (*tablestore.DeleteIndexResponse, error) {
+	if err := c.begin("DeleteIndex", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(request.MainTableName)
+	if err != nil {
+		return nil, err
+	}
+	for i, idx := range t.indexes {
+		if idx.IndexName == request.IndexName {
+			t.indexes = append(t.indexes[:i], t.indexes[i+1:]...)
+			return &tablestore.DeleteIndexResponse{}, nil
+		}
+	}
+	return nil, c.newError(CodeObjectNotExist, "Requested index does not exist.")
+}
+
+// ComputeSplitPointsBySize divides the primary key space of a table into consecutive splits
+// covering it from INF_MIN to INF_MAX, cutting at the rows picked by SplitRows.
+func (c *Client) ComputeSplitPointsBySize(request *tablestore.ComputeSplitPointsBySizeRequest) (*tablestore.ComputeSplitPointsBySizeResponse, error) {
+	if err := c.begin("ComputeSplitPointsBySize", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if request.SplitSize <= 0 {
+		return nil, c.newError(CodeParameterInvalid, "Split size must be positive.")
+	}
+
+	lower, upper := &tablestore.PrimaryKey{}, &tablestore.PrimaryKey{}
+	for _, schema := range t.meta.SchemaEntry {
+		lower.AddPrimaryKeyColumnWithMinValue(*schema.Name)
+		upper.AddPrimaryKeyColumnWithMaxValue(*schema.Name)
+	}
+	resp := &tablestore.ComputeSplitPointsBySizeResponse{SchemaEntry: t.meta.SchemaEntry}
+	if c.SplitRows > 0 {
+		for i, r := range t.sortedRows(true) {
+			if i == 0 || i%c.SplitRows != 0 {
+				continue
+			}
+			point := &tablestore.PrimaryKey{PrimaryKeys: clonePrimaryKey(&tablestore.PrimaryKey{PrimaryKeys: r.pk})}
+			resp.Splits = append(resp.Splits, &tablestore.Split{LowerBound: lower, UpperBound: point, Location: "fake"})
+			lower = point
+		}
+	}
+	resp.Splits = append(resp.Splits, &tablestore.Split{LowerBound: lower, UpperBound: upper, Location: "fake"})
+	return resp, nil
+}
+
+// SetSQLResult makes SQLQuery answer query with rows, each listing its columns in select order.
+// A nil Value is SQL NULL; like the plain buffer payload of the service, the columns of the result
+// set are those of its first row. The fake does not parse SQL, so other queries fail.
+func (c *Client) SetSQLResult(query string, rows ...[]*tablestore.AttributeColumn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sqlResults[query] = rows
+}
+
+// SQLQuery returns the result set registered with SetSQLResult, SQLPageRows rows at a time.
+func (c *Client) SQLQuery(request *tablestore.SQLQueryRequest) (*tablestore.SQLQueryResponse, error) {
+	if err := c.begin("SQLQuery", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rows, ok := c.sqlResults[request.Query]
+	if !ok {
+		return nil, c.newError(CodeParameterInvalid, "SQL query is not supported by the fake: "+request.Query)
+	}
+	offset := 0
+	if request.SearchToken != nil {
+		n, err := fmt.Sscanf(*request.SearchToken, "fake-sql-%d", &offset)
+		if n != 1 || err != nil || offset > len(rows) {
+			return nil, c.newError(CodeParameterInvalid, "Invalid search token.")
+		}
+	}
+	end := len(rows)
+	if c.SQLPageRows > 0 && offset+c.SQLPageRows < end {
+		end = offset + c.SQLPageRows
+	}
+
+	resp := &tablestore.SQLQueryResponse{
+		StmtType:         tablestore.SQL_SELECT,
+		PayloadVersion:   tablestore.SQLPAYLOAD_PLAIN_BUFFER,
+		SQLQueryConsumed: &tablestore.SQLQueryConsumed{},
+	}
+	encoded := make([]plainbuffer.Row, 0, end-offset)
+	for _, columns := range rows[offset:end] {
+		var row plainbuffer.Row
+		for _, col := range columns {
+			row.Cells = append(row.Cells, plainbuffer.Cell{Name: col.ColumnName, Value: col.Value})
+		}
+		encoded = append(encoded, row)
+	}
+	rs, err := tablestore.NewSQLResultSetFromPlainBuffer(plainbuffer.Encode(encoded...))
+	if err != nil {
+		return nil, err
+	}
+	resp.ResultSet = rs
+	if end < len(rows) {
+		token := fmt.Sprintf("fake-sql-%d", end)
+		resp.NextSearchToken = &token
+	}
+	return resp, nil
+}
+
+// PutRow writes a whole row, replacing any existing row with the same primary key.
+func (c *Client) PutRow(request *tablestore.PutRowRequest) (*tablestore.PutRowResponse, error) {
+	if err := c.begin("PutRow", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.putRow(request.PutRowChange)
+}
+
+// putRow applies a put change. The caller holds c.mu.
+func (c *Client) putRow(change *tablestore.PutRowChange) (*tablestore.PutRowResponse, error) {
+	if err := c.checkTransaction(change.TransactionId, change.TableName, change.PrimaryKey); err != nil {
+		return nil, err
+	}
+	pk, err := c.assignAutoIncrement(change.TableName, change.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+	t, key, err := c.locate(change.TableName, pk)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkCondition(change.Condition, t.rows[key]); err != nil {
+		return nil, err
+	}
+
+	r := &row{pk: clonePrimaryKey(pk), cols: make(map[string][]*tablestore.AttributeColumn)}
+	ts := c.now()
+	for _, col := range change.Columns {
+		cts := ts
+		if col.Timestamp != 0 {
+			cts = col.Timestamp
+		}
+		t.putVersion(r, &tablestore.AttributeColumn{ColumnName: col.ColumnName, Value: col.Value, Timestamp: cts})
+	}
+	t.rows[key] = r
+
+	resp := &tablestore.PutRowResponse{ConsumedCapacityUnit: &tablestore.ConsumedCapacityUnit{Write: 1}}
+	if change.ReturnType == tablestore.ReturnType_RT_PK {
+		resp.PrimaryKey = tablestore.PrimaryKey{PrimaryKeys: clonePrimaryKey(pk)}
+	}
+	return resp, nil
+}
+
+// assignAutoIncrement returns pk with its AUTO_INCREMENT placeholder, if any, replaced by a new
+// value. Like the service, values increase over time but are not consecutive. The caller holds c.mu.
+func (c *Client) assignAutoIncrement(tableName string, pk *tablestore.PrimaryKey) (*tablestore.PrimaryKey, error) {
+	t, err := c.table(tableName)
+	if err != nil || pk == nil {
+		return pk, err
+	}
+	var assigned *tablestore.PrimaryKey
+	for i, col := range pk.PrimaryKeys {
+		if col.PrimaryKeyOption != tablestore.AUTO_INCREMENT {
+			continue
+		}
+		if i >= len(t.meta.SchemaEntry) || t.meta.SchemaEntry[i].Option == nil || *t.meta.SchemaEntry[i].Option != tablestore.AUTO_INCREMENT {
+			return nil, c.newError(CodeParameterInvalid, fmt.Sprintf("Column %s is not an auto increment column.", col.ColumnName))
+		}
+		if assigned == nil {
+			assigned = &tablestore.PrimaryKey{PrimaryKeys: clonePrimaryKey(pk)}
+		}
+		c.lastAuto = max(time.Now().UnixMicro(), c.lastAuto+1)
+		assigned.PrimaryKeys[i] = &tablestore.PrimaryKeyColumn{ColumnName: col.ColumnName, Value: c.lastAuto}
+	}
+	if assigned == nil {
+		return pk, nil
+	}
+	return assigned, nil
+}
+
+// UpdateRow puts, deletes or increments individual columns of a row, creating it if needed.
+func (c *Client) UpdateRow(request *tablestore.UpdateRowRequest) (*tablestore.UpdateRowResponse, error) {
+	if err := c.begin("UpdateRow", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.updateRow(request.UpdateRowChange)
+}
+
+// updateRow applies an update change. The caller holds c.mu.
+func (c *Client) updateRow(change *tablestore.UpdateRowChange) (*tablestore.UpdateRowResponse, error) {
+	if err := c.checkTransaction(change.TransactionId, change.TableName, change.PrimaryKey); err != nil {
+		return nil, err
+	}
+	t, key, err := c.locate(change.TableName, change.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+	existing := t.rows[key]
+	if err := c.checkCondition(change.Condition, existing); err != nil {
+		return nil, err
+	}
+
+	r := existing
+	if r == nil {
+		r = &row{pk: clonePrimaryKey(change.PrimaryKey), cols: make(map[string][]*tablestore.AttributeColumn)}
+	}
+	ts := c.now()
+	var modified []*tablestore.AttributeColumn
+	for _, col := range change.Columns {
+		switch {
+		case col.HasType && col.Type == tablestore.DELETE_ALL_VERSION:
+			delete(r.cols, col.ColumnName)
+		case col.HasType && col.Type == tablestore.DELETE_ONE_VERSION:
+			versions := r.cols[col.ColumnName]
+			for i, version := range versions {
+				if version.Timestamp == col.Timestamp {
+					r.cols[col.ColumnName] = append(versions[:i:i], versions[i+1:]...)
+					break
+				}
+			}
+			if len(r.cols[col.ColumnName]) == 0 {
+				delete(r.cols, col.ColumnName)
+			}
+		case col.HasType && col.Type == tablestore.INCREMENT:
+			delta, ok := col.Value.(int64)
+			if !ok {
+				return nil, c.newError(CodeParameterInvalid, "Increment value must be an integer.")
+			}
+			var current int64
+			if versions := r.cols[col.ColumnName]; len(versions) > 0 {
+				if current, ok = versions[0].Value.(int64); !ok {
+					return nil, c.newError(CodeParameterInvalid, "Can not increment a non-integer column.")
+				}
+			}
+			attr := &tablestore.AttributeColumn{ColumnName: col.ColumnName, Value: current + delta, Timestamp: ts}
+			t.putVersion(r, attr)
+			modified = append(modified, attr)
+		default:
+			cts := ts
+			if col.HasTimestamp {
+				cts = col.Timestamp
+			}
+			t.putVersion(r, &tablestore.AttributeColumn{ColumnName: col.ColumnName, Value: col.Value, Timestamp: cts})
+		}
+	}
+	t.rows[key] = r
+
+	resp := &tablestore.UpdateRowResponse{ConsumedCapacityUnit: &tablestore.ConsumedCapacityUnit{Write: 1}}
+	if change.ReturnType == tablestore.ReturnType_RT_AFTER_MODIFY {
+		for _, attr := range modified {
+			for _, name := range change.ColumnNamesToReturn {
+				if attr.ColumnName == name {
+					resp.Columns = append(resp.Columns, attr)
+				}
+			}
+		}
+	}
+	return resp, nil
+}
+
+// DeleteRow deletes a row.
+func (c *Client) DeleteRow(request *tablestore.DeleteRowRequest) (*tablestore.DeleteRowResponse, error) {
+	if err := c.begin("DeleteRow", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deleteRow(request.DeleteRowChange)
+}
+
+// deleteRow applies a delete change. The caller holds c.mu.
+func (c *Client) deleteRow(change *tablestore.DeleteRowChange) (*tablestore.DeleteRowResponse, error) {
+	if err := c.checkTransaction(change.TransactionId, change.TableName, change.PrimaryKey); err != nil {
+		return nil, err
+	}
+	t, key, err := c.locate(change.TableName, change.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkCondition(change.Condition, t.rows[key]); err != nil {
+		return nil, err
+	}
+	delete(t.rows, key)
+	return &tablestore.DeleteRowResponse{ConsumedCapacityUnit: &tablestore.ConsumedCapacityUnit{Write: 1}}, nil
+}
+
+// BatchWriteRow applies put, update and delete changes in one call. Each change succeeds or
+// fails on its own, like in the real service; the results of a table follow the order of its changes.
+// An atomic request must hold the rows of a single table partition, and is applied all or nothing:
+// when a change fails, every change is rolled back and reported failed with its error.
+func (c *Client) BatchWriteRow(request *tablestore.BatchWriteRowRequest) (*tablestore.BatchWriteRowResponse, error) {
+	if err := c.begin("BatchWriteRow", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	for tableName, changes := range request.RowChangesGroupByTable {
+		if _, err := c.table(tableName); err != nil {
+			return nil, err
+		}
+		total += len(changes)
+	}
+	if total > maxBatchWriteRows {
+		return nil, c.newError(CodeParameterInvalid, fmt.Sprintf("Rows count exceeds the upper limit: %d.", maxBatchWriteRows))
+	}
+	var snapshot map[string]map[string]*row
+	if request.IsAtomic {
+		if err := c.checkAtomicBatch(request); err != nil {
+			return nil, err
+		}
+		snapshot = c.snapshotRows(request)
+	}
+
+	resp := &tablestore.BatchWriteRowResponse{TableToRowsResult: make(map[string][]tablestore.RowResult)}
+	for tableName, changes := range request.RowChangesGroupByTable {
+		for i, change := range changes {
+			var err error
+			var pk tablestore.PrimaryKey
+			if c.RowIntercept != nil {
+				err = c.RowIntercept(tableName, change)
+			}
+			if err == nil {
+				switch change := change.(type) {
+				case *tablestore.PutRowChange:
+					var putResp *tablestore.PutRowResponse
+					if putResp, err = c.putRow(change); err == nil {
+						pk = putResp.PrimaryKey
+					}
+				case *tablestore.UpdateRowChange:
+					_, err = c.updateRow(change)
+				case *tablestore.DeleteRowChange:
+					_, err = c.deleteRow(change)
+				default:
+					err = c.newError(CodeParameterInvalid, fmt.Sprintf("Unsupported row change %T.", change))
+				}
+			}
+
+			result := tablestore.RowResult{TableName: tableName, Index: int32(i)}
+			if err != nil {
+				var otsErr *tablestore.OtsError
+				if !errors.As(err, &otsErr) {
+					return nil, err
+				}
+				result.Error = tablestore.Error{Code: otsErr.Code, Message: otsErr.Message}
+			} else {
+				result.IsSucceed = true
+				result.PrimaryKey = pk
+				result.ConsumedCapacityUnit = &tablestore.ConsumedCapacityUnit{Write: 1}
+			}
+			resp.TableToRowsResult[tableName] = append(resp.TableToRowsResult[tableName], result)
+		}
+	}
+	if request.IsAtomic {
+		c.settleAtomicBatch(resp, snapshot)
+	}
+	return resp, nil
+}
+
+// checkAtomicBatch rejects an atomic BatchWriteRow request spanning tables or partitions.
+func (c *Client) checkAtomicBatch(request *tablestore.BatchWriteRowRequest) error {
+	if len(request.RowChangesGroupByTable) > 1 {
+		return c.newError(CodeParameterInvalid, "Atomic batch write must be on a single table.")
+	}
+	var partition any
+	for _, changes := range request.RowChangesGroupByTable {
+		for i, change := range changes {
+			var pk *tablestore.PrimaryKey
+			switch change := change.(type) {
+			case *tablestore.PutRowChange:
+				pk = change.PrimaryKey
+			case *tablestore.UpdateRowChange:
+				pk = change.PrimaryKey
+			case *tablestore.DeleteRowChange:
+				pk = change.PrimaryKey
+			}
+			if pk == nil || len(pk.PrimaryKeys) == 0 {
+				continue
+			}
+			if i == 0 {
+				partition = pk.PrimaryKeys[0].Value
+			} else if !reflect.DeepEqual(pk.PrimaryKeys[0].Value, partition) {
+				return c.newError(CodeParameterInvalid, "Atomic batch write rows must share the partition key.")
+			}
+		}
+	}
+	return nil
+}
+
+// snapshotRows copies the rows of the tables of request, for settleAtomicBatch to restore.
+func (c *Client) snapshotRows(request *tablestore.BatchWriteRowRequest) map[string]map[string]*row {
+	snapshot := make(map[string]map[string]*row)
+	for tableName := range request.RowChangesGroupByTable {
+		rows := make(map[string]*row)
+		for key, r := range c.tables[tableName].rows {
+			cols := make(map[string][]*tablestore.AttributeColumn, len(r.cols))
+			for name, versions := range r.cols {
+				cols[name] = append([]*tablestore.AttributeColumn(nil), versions...)
+			}
+			rows[key] = &row{pk: r.pk, cols: cols}
+		}
+		snapshot[tableName] = rows
+	}
+	return snapshot
+}
+
+// settleAtomicBatch restores the snapshot when a change of an atomic request failed, and reports
+// every change failed with the error of the first failed one.
+func (c *Client) settleAtomicBatch(resp *tablestore.BatchWriteRowResponse, snapshot map[string]map[string]*row) {
+	var failure *tablestore.Error
+	for _, results := range resp.TableToRowsResult {
+		for i := range results {
+			if !results[i].IsSucceed && failure == nil {
+				err := results[i].Error
+				failure = &err
+			}
+		}
+	}
+	if failure == nil {
+		return
+	}
+	for tableName, rows := range snapshot {
+		c.tables[tableName].rows = rows
+	}
+	for _, results := range resp.TableToRowsResult {
+		for i := range results {
+			results[i] = tablestore.RowResult{TableName: results[i].TableName, Index: results[i].Index, Error: *failure}
+		}
+	}
+}
+
+// GetRow reads a single row. A missing row yields an empty response, not an error.
+func (c *Client) GetRow(request *tablestore.GetRowRequest) (*tablestore.GetRowResponse, error) {
+	if err := c.begin("GetRow", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	criteria := request.SingleRowQueryCriteria
+	if criteria.MaxVersion <= 0 && criteria.TimeRange == nil {
+		return nil, c.newError(CodeParameterInvalid, "Neither column max versions nor time range is set.")
+	}
+	if err := c.checkTransaction(criteria.TransactionId, criteria.TableName, criteria.PrimaryKey); err != nil {
+		return nil, err
+	}
+	t, key, err := c.locate(criteria.TableName, criteria.PrimaryKey)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &tablestore.GetRowResponse{ConsumedCapacityUnit: &tablestore.ConsumedCapacityUnit{Read: 1}}
+	r := t.rows[key]
+	if r == nil {
+		return resp, nil
+	}
+	if criteria.Filter != nil {
+		// Like the service, a row the filter excludes reads as missing
+		ok, err := c.matchColumnCondition(criteria.Filter, r)
+		if err != nil || !ok {
+			return resp, err
+		}
+	}
+	pk, cols, ok := r.project(criteria.ColumnsToGet, criteria.MaxVersion, criteria.TimeRange)
+	if !ok {
+		return resp, nil
+	}
+	resp.PrimaryKey = tablestore.PrimaryKey{PrimaryKeys: pk}
+	resp.Columns = cols
+	return resp, nil
+}
+
+// BatchGetRow reads several rows in one call. Like the real service, a missing row yields an
+// empty successful result and an invalid primary key fails only its own row.
+func (c *Client) BatchGetRow(request *tablestore.BatchGetRowRequest) (*tablestore.BatchGetRowResponse, error) {
+	if err := c.begin("BatchGetRow", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	total := 0
+	for _, criteria := range request.MultiRowQueryCriteria {
+		total += len(criteria.PrimaryKey)
+	}
+	if total > maxBatchGetRows {
+		return nil, c.newError(CodeParameterInvalid, fmt.Sprintf("Rows count exceeds the upper limit: %d.", maxBatchGetRows))
+	}
+
+	resp := &tablestore.BatchGetRowResponse{TableToRowsResult: make(map[string][]tablestore.RowResult)}
+	for _, criteria := range request.MultiRowQueryCriteria {
+		if criteria.MaxVersion <= 0 && criteria.TimeRange == nil {
+			return nil, c.newError(CodeParameterInvalid, "Neither column max versions nor time range is set.")
+		}
+		if _, err := c.table(criteria.TableName); err != nil {
+			return nil, err
+		}
+		for i, pk := range criteria.PrimaryKey {
+			result := tablestore.RowResult{TableName: criteria.TableName, Index: int32(i)}
+			t, key, err := c.locate(criteria.TableName, pk)
+			if err != nil {
+				otsErr := err.(*tablestore.OtsError)
+				result.Error = tablestore.Error{Code: otsErr.Code, Message: otsErr.Message}
+				resp.TableToRowsResult[criteria.TableName] = append(resp.TableToRowsResult[criteria.TableName], result)
+				continue
+			}
+			result.IsSucceed = true
+			result.ConsumedCapacityUnit = &tablestore.ConsumedCapacityUnit{Read: 1}
+			r := t.rows[key]
+			if r != nil && criteria.Filter != nil {
+				// Like GetRow, a row the filter excludes reads as missing
+				ok, err := c.matchColumnCondition(criteria.Filter, r)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					r = nil
+				}
+			}
+			if r != nil {
+				if pk, cols, ok := r.project(criteria.ColumnsToGet, int32(criteria.MaxVersion), criteria.TimeRange); ok {
+					result.PrimaryKey = tablestore.PrimaryKey{PrimaryKeys: pk}
+					result.Columns = cols
+				}
+			}
+			resp.TableToRowsResult[criteria.TableName] = append(resp.TableToRowsResult[criteria.TableName], result)
+		}
+	}
+	return resp, nil
+}
+
+// GetRange reads rows between the start (inclusive) and end (exclusive) primary keys.
+func (c *Client) GetRange(request *tablestore.GetRangeRequest) (*tablestore.GetRangeResponse, error) {
+	if err := c.begin("GetRange", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	criteria := request.RangeRowQueryCriteria
+	if criteria.MaxVersion <= 0 && criteria.TimeRange == nil {
+		return nil, c.newError(CodeParameterInvalid, "Neither column max versions nor time range is set.")
+	}
+	t, err := c.table(criteria.TableName)
+	if err != nil {
+		if t = c.indexTable(criteria.TableName); t == nil {
+			return nil, err
+		}
+	}
+	if err := c.checkBoundary(t, criteria.StartPrimaryKey); err != nil {
+		return nil, err
+	}
+	if err := c.checkBoundary(t, criteria.EndPrimaryKey); err != nil {
+		return nil, err
+	}
+
+	forward := criteria.Direction == tablestore.FORWARD
+	rows := t.sortedRows(forward)
+
+	limit := int(criteria.Limit)
+	if c.MaxRangeRows > 0 && (limit <= 0 || c.MaxRangeRows < limit) {
+		limit = c.MaxRangeRows
+	}
+
+	resp := &tablestore.GetRangeResponse{ConsumedCapacityUnit: &tablestore.ConsumedCapacityUnit{}}
+	for _, r := range rows {
+		startCmp := comparePrimaryKey(r.pk, criteria.StartPrimaryKey.PrimaryKeys)
+		endCmp := comparePrimaryKey(r.pk, criteria.EndPrimaryKey.PrimaryKeys)
+		if forward && (startCmp < 0 || endCmp >= 0) || !forward && (startCmp > 0 || endCmp <= 0) {
+			continue
+		}
+		if limit > 0 && len(resp.Rows) >= limit {
+			resp.NextStartPrimaryKey = &tablestore.PrimaryKey{PrimaryKeys: clonePrimaryKey(&tablestore.PrimaryKey{PrimaryKeys: r.pk})}
+			break
+		}
+		pk, cols, ok := r.project(criteria.ColumnsToGet, criteria.MaxVersion, criteria.TimeRange)
+		if !ok {
+			continue
+		}
+		resp.Rows = append(resp.Rows, &tablestore.Row{PrimaryKey: &tablestore.PrimaryKey{PrimaryKeys: pk}, Columns: cols})
+		resp.ConsumedCapacityUnit.Read++
+	}
+	return resp, nil
+}
+
+// indexTable returns the secondary index name of one of the tables as a table of its own, or
+// nil when there is no such index. Like the service, a row is indexed only when it has every
+// column of the primary key of the index, and the index rows carry the columns the index covers.
+func (c *Client) indexTable(name string) *table {
+	for _, t := range c.tables {
+		for _, idx := range t.indexes {
+			if idx.IndexName == name {
+				return t.indexView(idx)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *table) indexView(idx *tablestore.IndexMeta) *table {
+	types := make(map[string]tablestore.PrimaryKeyType)
+	for _, s := range t.meta.SchemaEntry {
+		types[*s.Name] = *s.Type
+	}
+	for _, col := range t.meta.DefinedColumns {
+		switch col.ColumnType {
+		case tablestore.DefinedColumn_STRING:
+			types[col.Name] = tablestore.PrimaryKeyType_STRING
+		case tablestore.DefinedColumn_INTEGER:
+			types[col.Name] = tablestore.PrimaryKeyType_INTEGER
+		case tablestore.DefinedColumn_BINARY:
+			types[col.Name] = tablestore.PrimaryKeyType_BINARY
+		}
+	}
+
+	meta := &tablestore.TableMeta{TableName: idx.IndexName}
+	for _, name := range idx.Primarykey {
+		meta.AddPrimaryKeyColumn(name, types[name])
+	}
+	view := &table{meta: meta, option: t.option, rows: make(map[string]*row)}
+rows:
+	for _, r := range t.rows {
+		pkValues := make(map[string]any, len(r.pk))
+		for _, col := range r.pk {
+			pkValues[col.ColumnName] = col.Value
+		}
+		ir := &row{cols: make(map[string][]*tablestore.AttributeColumn)}
+		for _, name := range idx.Primarykey {
+			value, ok := pkValues[name]
+			if !ok {
+				versions := r.cols[name]
+				if len(versions) == 0 {
+					continue rows
+				}
+				value = versions[0].Value
+			}
+			if !valueMatchesType(value, types[name]) {
+				continue rows
+			}
+			ir.pk = append(ir.pk, &tablestore.PrimaryKeyColumn{ColumnName: name, Value: value})
+		}
+		for _, name := range idx.DefinedColumns {
+			if versions, ok := r.cols[name]; ok {
+				ir.cols[name] = versions
+			}
+		}
+		view.rows[encodePrimaryKey(ir.pk)] = ir
+	}
+	return view
+}
+
+// checkBoundary validates a range boundary, which may use INF_MIN/INF_MAX columns.
+func (c *Client) checkBoundary(t *table, pk *tablestore.PrimaryKey) error {
+	if pk == nil || len(pk.PrimaryKeys) != len(t.meta.SchemaEntry) {
+		return c.newError(CodeParameterInvalid, "Validate PK size fail. Input: "+describePrimaryKey(pk)+".")
+	}
+	for i, schema := range t.meta.SchemaEntry {
+		col := pk.PrimaryKeys[i]
+		if col.ColumnName != *schema.Name {
+			return c.newError(CodeParameterInvalid, fmt.Sprintf("Validate PK name fail. Input: %s, Meta: %s.", col.ColumnName, *schema.Name))
+		}
+		if col.PrimaryKeyOption == tablestore.MIN || col.PrimaryKeyOption == tablestore.MAX {
+			continue
+		}
+		if !valueMatchesType(col.Value, *schema.Type) {
+			return c.newError(CodeParameterInvalid, fmt.Sprintf("Validate PK type fail. Input: %T, Meta: %s.", col.Value, typeName(*schema.Type)))
+		}
+	}
+	return nil
+}
+
+// sortedRows returns the table's rows ordered by primary key.
+func (t *table) sortedRows(ascending bool) []*row {
+	rows := make([]*row, 0, len(t.rows))
+	for _, r := range t.rows {
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		cmp := comparePrimaryKey(rows[i].pk, rows[j].pk)
+		if ascending {
+			return cmp < 0
+		}
+		return cmp > 0
+	})
+	return rows
+}
+
+// locate validates the primary key against the table schema and returns the row key.
+func (c *Client) locate(tableName string, pk *tablestore.PrimaryKey) (*table, string, error) {
+	t, err := c.table(tableName)
+	if err != nil {
+		return nil, "", err
+	}
+	if pk == nil || len(pk.PrimaryKeys) != len(t.meta.SchemaEntry) {
+		return nil, "", c.newError(CodeParameterInvalid, "Validate PK size fail. Input: "+describePrimaryKey(pk)+".")
+	}
+	for i, schema := range t.meta.SchemaEntry {
+		col := pk.PrimaryKeys[i]
+		if col.ColumnName != *schema.Name {
+			return nil, "", c.newError(CodeParameterInvalid, fmt.Sprintf("Validate PK name fail. Input: %s, Meta: %s.", col.ColumnName, *schema.Name))
+		}
+		if !valueMatchesType(col.Value, *schema.Type) {
+			return nil, "", c.newError(CodeParameterInvalid, fmt.Sprintf("Validate PK type fail. Input: %T, Meta: %s.", col.Value, typeName(*schema.Type)))
+		}
+	}
+	return t, encodePrimaryKey(pk.PrimaryKeys), nil
+}
+
+func (c *Client) checkCondition(cond *tablestore.RowCondition, existing *row) error {
+	if cond == nil {
+		return nil
+	}
+	switch cond.RowExistenceExpectation {
+	case tablestore.RowExistenceExpectation_EXPECT_EXIST:
+		if existing == nil {
+			return c.newError(CodeConditionCheckFail, "Condition check failed.")
+		}
+	case tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST:
+		if existing != nil {
+			return c.newError(CodeConditionCheckFail, "Condition check failed.")
+		}
+	}
+	if cond.ColumnCondition != nil {
+		ok, err := c.matchColumnCondition(cond.ColumnCondition, existing)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return c.newError(CodeConditionCheckFail, "Condition check failed.")
+		}
+	}
+	return nil
+}
+
+// putVersion adds a column version, keeping at most the table's MaxVersion versions.
+func (t *table) putVersion(r *row, attr *tablestore.AttributeColumn) {
+	versions := r.cols[attr.ColumnName]
+	for i, version := range versions {
+		if version.Timestamp == attr.Timestamp {
+			versions = append(versions[:i:i], versions[i+1:]...)
+			break
+		}
+	}
+	versions = append(versions, attr)
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].Timestamp > versions[j].Timestamp })
+	if max := t.option.MaxVersion; max > 0 && len(versions) > max {
+		versions = versions[:max]
+	}
+	r.cols[attr.ColumnName] = versions
+}
+
+// project returns the row's primary key and the requested column versions.
+// ok is false when columnsToGet names only columns the row does not have.
+func (r *row) project(columnsToGet []string, maxVersion int32, timeRange *tablestore.TimeRange) (pk []*tablestore.PrimaryKeyColumn, cols []*tablestore.AttributeColumn, ok bool) {
+	wanted := func(name string) bool {
+		if len(columnsToGet) == 0 {
+			return true
+		}
+		for _, c := range columnsToGet {
+			if c == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	matched := len(columnsToGet) == 0
+	for _, col := range r.pk {
+		if wanted(col.ColumnName) {
+			matched = true
+		}
+	}
+
+	names := make([]string, 0, len(r.cols))
+	for name := range r.cols {
+		if wanted(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		n := int32(0)
+		for _, version := range r.cols[name] {
+			if timeRange != nil && !inTimeRange(version.Timestamp, timeRange) {
+				continue
+			}
+			if maxVersion > 0 && n >= maxVersion {
+				break
+			}
+			cols = append(cols, &tablestore.AttributeColumn{ColumnName: version.ColumnName, Value: version.Value, Timestamp: version.Timestamp})
+			n++
+		}
+	}
+	if len(cols) > 0 {
+		matched = true
+	}
+	if !matched {
+		return nil, nil, false
+	}
+	return clonePrimaryKey(&tablestore.PrimaryKey{PrimaryKeys: r.pk}), cols, true
+}
+
+func inTimeRange(ts int64, tr *tablestore.TimeRange) bool {
+	if tr.Specific != 0 {
+		return ts == tr.Specific
+	}
+	return ts >= tr.Start && (tr.End == 0 || ts < tr.End)
+}
+
+func clonePrimaryKey(pk *tablestore.PrimaryKey) []*tablestore.PrimaryKeyColumn {
+	out := make([]*tablestore.PrimaryKeyColumn, 0, len(pk.PrimaryKeys))
+	for _, col := range pk.PrimaryKeys {
+		c := *col
+		out = append(out, &c)
+	}
+	return out
+}
+
+func valueMatchesType(value any, t tablestore.PrimaryKeyType) bool {
+	switch value.(type) {
+	case string:
+		return t == tablestore.PrimaryKeyType_STRING
+	case int64:
+		return t == tablestore.PrimaryKeyType_INTEGER
+	case []byte:
+		return t == tablestore.PrimaryKeyType_BINARY
+	default:
+		return false
+	}
+}
+
+func typeName(t tablestore.PrimaryKeyType) string {
+	switch t {
+	case tablestore.PrimaryKeyType_STRING:
+		return "STRING"
+	case tablestore.PrimaryKeyType_INTEGER:
+		return "INTEGER"
+	case tablestore.PrimaryKeyType_BINARY:
+		return "BINARY"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", t)
+	}
+}
+
+func encodePrimaryKey(cols []*tablestore.PrimaryKeyColumn) string {
+	var sb strings.Builder
+	for _, col := range cols {
+		switch v := col.Value.(type) {
+		case string:
+			fmt.Fprintf(&sb, "s%d:%s|", len(v), v)
+		case int64:
+			fmt.Fprintf(&sb, "i:%d|", v)
+		case []byte:
+			fmt.Fprintf(&sb, "b:%s|", hex.EncodeToString(v))
+		}
+	}
+	return sb.String()
+}
+
+func describePrimaryKey(pk *tablestore.PrimaryKey) string {
+	if pk == nil {
+		return "<nil>"
+	}
+	names := make([]string, 0, len(pk.PrimaryKeys))
+	for _, col := range pk.PrimaryKeys {
+		names = append(names, col.ColumnName)
+	}
+	return strings.Join(names, ",")
+}
+
+// comparePrimaryKey orders a row's primary key against another primary key,
+// which may contain INF_MIN/INF_MAX columns.
+func comparePrimaryKey(a, b []*tablestore.PrimaryKeyColumn) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if cmp := comparePrimaryKeyColumn(a[i], b[i]); cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+func comparePrimaryKeyColumn(a, b *tablestore.PrimaryKeyColumn) int {
+	rank := func(col *tablestore.PrimaryKeyColumn) int {
+		switch col.PrimaryKeyOption {
+		case tablestore.MIN:
+			return -1
+		case tablestore.MAX:
+			return 1
+		}
+		return 0
+	}
+	if ra, rb := rank(a), rank(b); ra != 0 || rb != 0 {
+		switch {
+		case ra < rb:
+			return -1
+		case ra > rb:
+			return 1
+		}
+		return 0
+	}
+
+	switch av := a.Value.(type) {
+	case string:
+		return strings.Compare(av, b.Value.(string))
+	case int64:
+		bv := b.Value.(int64)
+		switch {
+		case av < bv:
+			return -1
+		case av > bv:
+			return 1
+		}
+		return 0
+	case []byte:
+		return bytes.Compare(av, b.Value.([]byte))
+	}
+	return 0
+}
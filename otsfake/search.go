@@ -0,0 +1,665 @@
+package otsfake
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+)
+
+// Limits of a Search request, as in the service.
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 100
+
+	// defaultGroupBySize is the number of groups a GroupByField returns without Sz
+	defaultGroupBySize = 10
+)
+
+// searchSettings holds the settings of a query built with search.NewSearchQuery, whose type is
+// unexported. The fields are copied by name.
+type searchSettings struct {
+	Offset        *int32
+	Limit         *int32
+	Query         search.Query
+	Sort          *search.Sort
+	GetTotalCount bool
+	Token         []byte
+	Aggregations  []search.Aggregation
+	GroupBys      []search.GroupBy
+}
+
+// searchCursor is the position a NextToken continues from.
+type searchCursor struct {
+	offset int
+	sort   *search.Sort
+}
+
+// CreateSearchIndex adds a search index to a table. Only the field names and types of the schema
+// are used: Search matches the rows of the table as they are on every call, as if the index were
+// always in sync.
+func (c *Client) CreateSearchIndex(request *tablestore.CreateSearchIndexRequest) (*tablestore.CreateSearchIndexResponse, error) {
+	if err := c.begin("CreateSearchIndex", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := t.searchIndexes[request.IndexName]; ok {
+		return nil, c.newError(CodeObjectAlreadyExist, "Requested search index already exists.")
+	}
+	if request.IndexSchema == nil || len(request.IndexSchema.FieldSchemas) == 0 {
+		return nil, c.newError(CodeParameterInvalid, "Search index schema has no field.")
+	}
+	if t.searchIndexes == nil {
+		t.searchIndexes = make(map[string]*tablestore.IndexSchema)
+	}
+	t.searchIndexes[request.IndexName] = request.IndexSchema
+	return &tablestore.CreateSearchIndexResponse{}, nil
+}
+
+// Search matches the rows of a table against a query of a search index. MatchAll, Term, Terms,
+// Range, Prefix, Exists and Bool queries are supported, on the fields of the index schema.
+// Rows are sorted by the FieldSort and PrimaryKeySort sorters, by primary key by default, and a
+// NextToken is returned while rows remain. The count, sum, min, max, avg and distinct count
+// aggregations and the GroupByField and GroupByFilter group-bys are computed over every matched
+// row.
+func (c *Client) Search(request *tablestore.SearchRequest) (*tablestore.SearchResponse, error) {
+	if err := c.begin("Search", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	schema, ok := t.searchIndexes[request.IndexName]
+	if !ok {
+		return nil, c.newError(CodeObjectNotExist, "Requested search index does not exist.")
+	}
+	fields := make(map[string]bool)
+	for _, f := range schema.FieldSchemas {
+		fields[*f.FieldName] = true
+	}
+
+	settings, err := c.readSearchQuery(request.SearchQuery)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Query == nil {
+		return nil, c.newError(CodeParameterInvalid, "Query is required.")
+	}
+	limit := defaultSearchLimit
+	if settings.Limit != nil {
+		limit = int(*settings.Limit)
+	}
+	if limit < 0 || limit > maxSearchLimit {
+		return nil, c.newError(CodeParameterInvalid, fmt.Sprintf("The limit must be in range: [0, %d].", maxSearchLimit))
+	}
+	offset := 0
+	if settings.Offset != nil {
+		offset = int(*settings.Offset)
+	}
+	order := settings.Sort
+	if settings.Token != nil {
+		if offset != 0 {
+			return nil, c.newError(CodeParameterInvalid, "Offset can not be set with a token.")
+		}
+		if order != nil {
+			return nil, c.newError(CodeParameterInvalid, "Sort can not be set with a token, which carries the sort of the first page.")
+		}
+		cursor, ok := c.searchTokens[string(settings.Token)]
+		if !ok {
+			return nil, c.newError(CodeParameterInvalid, "Invalid token.")
+		}
+		offset, order = cursor.offset, cursor.sort
+	}
+
+	var matched []*row
+	for _, r := range t.sortedRows(true) {
+		ok, err := c.matchSearchQuery(settings.Query, fields, searchValues(r))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, r)
+		}
+	}
+	if err := c.sortSearchRows(matched, order, fields); err != nil {
+		return nil, err
+	}
+
+	resp := &tablestore.SearchResponse{
+		TotalCount:           -1,
+		IsAllSuccess:         true,
+		ConsumedCapacityUnit: &tablestore.ConsumedCapacityUnit{Read: 1},
+	}
+	if settings.GetTotalCount {
+		resp.TotalCount = int64(len(matched))
+	}
+	if resp.AggregationResults, err = c.aggregateSearchRows(settings.Aggregations, matched, fields); err != nil {
+		return nil, err
+	}
+	if resp.GroupByResults, err = c.groupSearchRows(settings.GroupBys, matched, fields); err != nil {
+		return nil, err
+	}
+	end := min(offset+limit, len(matched))
+	for _, r := range matched[min(offset, end):end] {
+		row := searchRow(r, request.ColumnsToGet, fields)
+		hit := &tablestore.SearchHit{Row: row}
+		if c.SearchScore != nil {
+			score := c.SearchScore(r.pk)
+			hit.Score = &score
+		}
+		resp.Rows = append(resp.Rows, row)
+		resp.SearchHits = append(resp.SearchHits, hit)
+	}
+	if end < len(matched) {
+		c.searchTokenSeq++
+		token := fmt.Sprintf("fake-search-%d", c.searchTokenSeq)
+		c.searchTokens[token] = searchCursor{offset: end, sort: order}
+		resp.NextToken = []byte(token)
+	}
+	return resp, nil
+}
+
+// readSearchQuery copies the settings of a query built with search.NewSearchQuery.
+func (c *Client) readSearchQuery(q search.SearchQuery) (*searchSettings, error) {
+	v := reflect.ValueOf(q)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, c.newError(CodeParameterInvalid, fmt.Sprintf("Unsupported search query %T.", q))
+	}
+	var s searchSettings
+	sv := reflect.ValueOf(&s).Elem()
+	for i := 0; i < sv.NumField(); i++ {
+		f := v.Elem().FieldByName(sv.Type().Field(i).Name)
+		if f.IsValid() && f.Type() == sv.Field(i).Type() {
+			sv.Field(i).Set(f)
+		}
+	}
+	return &s, nil
+}
+
+// searchValues returns the primary key and the newest attribute values of a row by name.
+func searchValues(r *row) map[string]any {
+	values := make(map[string]any, len(r.pk)+len(r.cols))
+	for _, col := range r.pk {
+		values[col.ColumnName] = col.Value
+	}
+	for name, versions := range r.cols {
+		values[name] = versions[0].Value
+	}
+	return values
+}
+
+// searchRow returns a row of a Search response: its primary key and the columns of columnsToGet.
+func searchRow(r *row, columnsToGet *tablestore.ColumnsToGet, fields map[string]bool) *tablestore.Row {
+	out := &tablestore.Row{PrimaryKey: &tablestore.PrimaryKey{PrimaryKeys: clonePrimaryKey(&tablestore.PrimaryKey{PrimaryKeys: r.pk})}}
+	if columnsToGet == nil {
+		return out
+	}
+	names := make([]string, 0, len(r.cols))
+	for name := range r.cols {
+		switch {
+		case columnsToGet.ReturnAll,
+			columnsToGet.ReturnAllFromIndex && fields[name],
+			slices.Contains(columnsToGet.Columns, name):
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		version := r.cols[name][0]
+		out.Columns = append(out.Columns, &tablestore.AttributeColumn{ColumnName: name, Value: version.Value, Timestamp: version.Timestamp})
+	}
+	return out
+}
+
+// matchSearchQuery evaluates a query against the values of a row.
+func (c *Client) matchSearchQuery(q search.Query, fields map[string]bool, values map[string]any) (bool, error) {
+	field := func(name string) (any, bool, error) {
+		if !fields[name] {
+			return nil, false, c.newError(CodeParameterInvalid, fmt.Sprintf("Field %s is not in the search index schema.", name))
+		}
+		v, ok := values[name]
+		return v, ok, nil
+	}
+
+	switch q := q.(type) {
+	case *search.MatchAllQuery:
+		return true, nil
+
+	case *search.TermQuery:
+		v, ok, err := field(q.FieldName)
+		if err != nil || !ok {
+			return false, err
+		}
+		n, comparable := compareSearchValues(v, q.Term)
+		return comparable && n == 0, nil
+
+	case *search.TermsQuery:
+		v, ok, err := field(q.FieldName)
+		if err != nil || !ok {
+			return false, err
+		}
+		for _, term := range q.Terms {
+			if n, comparable := compareSearchValues(v, term); comparable && n == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case *search.RangeQuery:
+		v, ok, err := field(q.FieldName)
+		if err != nil || !ok {
+			return false, err
+		}
+		if q.From != nil {
+			n, comparable := compareSearchValues(v, q.From)
+			if !comparable || n < 0 || n == 0 && !q.IncludeLower {
+				return false, nil
+			}
+		}
+		if q.To != nil {
+			n, comparable := compareSearchValues(v, q.To)
+			if !comparable || n > 0 || n == 0 && !q.IncludeUpper {
+				return false, nil
+			}
+		}
+		return true, nil
+
+	case *search.PrefixQuery:
+		v, ok, err := field(q.FieldName)
+		if err != nil || !ok {
+			return false, err
+		}
+		s, isString := v.(string)
+		return isString && strings.HasPrefix(s, q.Prefix), nil
+
+	case *search.ExistsQuery:
+		_, ok, err := field(q.FieldName)
+		return ok, err
+
+	case *search.BoolQuery:
+		for _, sub := range append(append([]search.Query(nil), q.MustQueries...), q.FilterQueries...) {
+			ok, err := c.matchSearchQuery(sub, fields, values)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		for _, sub := range q.MustNotQueries {
+			ok, err := c.matchSearchQuery(sub, fields, values)
+			if err != nil || ok {
+				return false, err
+			}
+		}
+		if len(q.ShouldQueries) == 0 {
+			return true, nil
+		}
+		// Like the service, should queries are optional next to must and filter queries
+		minimum := 1
+		if len(q.MustQueries) > 0 || len(q.FilterQueries) > 0 {
+			minimum = 0
+		}
+		if q.MinimumShouldMatch != nil {
+			minimum = int(*q.MinimumShouldMatch)
+		}
+		matched := 0
+		for _, sub := range q.ShouldQueries {
+			ok, err := c.matchSearchQuery(sub, fields, values)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				matched++
+			}
+		}
+		return matched >= minimum, nil
+
+	default:
+		return false, c.newError(CodeParameterInvalid, fmt.Sprintf("Query %T is not supported by the fake.", q))
+	}
+}
+
+// sortSearchRows sorts matched rows, which are in primary key order, by the sorters of order.
+func (c *Client) sortSearchRows(rows []*row, order *search.Sort, fields map[string]bool) error {
+	if order == nil || len(order.Sorters) == 0 {
+		return nil
+	}
+	for _, sorter := range order.Sorters {
+		switch s := sorter.(type) {
+		case *search.PrimaryKeySort:
+		case *search.FieldSort:
+			if !fields[s.FieldName] {
+				return c.newError(CodeParameterInvalid, fmt.Sprintf("Field %s is not in the search index schema.", s.FieldName))
+			}
+		default:
+			return c.newError(CodeParameterInvalid, fmt.Sprintf("Sorter %T is not supported by the fake.", sorter))
+		}
+	}
+
+	descending := func(o *search.SortOrder) bool { return o != nil && *o == search.SortOrder_DESC }
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, sorter := range order.Sorters {
+			var n int
+			switch s := sorter.(type) {
+			case *search.PrimaryKeySort:
+				n = comparePrimaryKey(rows[i].pk, rows[j].pk)
+				if descending(s.Order) {
+					n = -n
+				}
+			case *search.FieldSort:
+				a, aok := searchValues(rows[i])[s.FieldName]
+				b, bok := searchValues(rows[j])[s.FieldName]
+				switch {
+				case !aok && !bok:
+				case !aok:
+					// Missing values sort last in both orders
+					n = 1
+				case !bok:
+					n = -1
+				default:
+					n, _ = compareSearchValues(a, b)
+					if descending(s.Order) {
+						n = -n
+					}
+				}
+			}
+			if n != 0 {
+				return n < 0
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+// compareSearchValues compares a stored value with a query value. Integer and floating point
+// values compare as numbers; other values only compare with values of the same type.
+func compareSearchValues(stored, want any) (int, bool) {
+	a, aNum := searchNumber(stored)
+	b, bNum := searchNumber(want)
+	if aNum || bNum {
+		return cmp.Compare(a, b), aNum && bNum
+	}
+	switch s := stored.(type) {
+	case string:
+		w, ok := want.(string)
+		return cmp.Compare(s, w), ok
+	case []byte:
+		w, ok := want.([]byte)
+		return bytes.Compare(s, w), ok
+	case bool:
+		w, ok := want.(bool)
+		if !ok || s == w {
+			return 0, ok
+		}
+		if !s {
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func searchNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// aggregateSearchRows computes aggregations over the matched rows.
+func (c *Client) aggregateSearchRows(aggs []search.Aggregation, rows []*row, fields map[string]bool) (search.AggregationResults, error) {
+	var results search.AggregationResults
+	for _, agg := range aggs {
+		var result search.AggregationResult
+		switch a := agg.(type) {
+		case *search.CountAggregation:
+			values, err := c.aggregationValues(a.Field, nil, rows, fields)
+			if err != nil {
+				return results, err
+			}
+			result = &search.CountAggregationResult{Name: a.AggName, Value: int64(len(values))}
+
+		case *search.DistinctCountAggregation:
+			values, err := c.aggregationValues(a.Field, a.MissingValue, rows, fields)
+			if err != nil {
+				return results, err
+			}
+			distinct := make(map[string]bool)
+			for _, v := range values {
+				distinct[fmt.Sprintf("%T:%v", v, v)] = true
+			}
+			result = &search.DistinctCountAggregationResult{Name: a.AggName, Value: int64(len(distinct))}
+
+		case *search.SumAggregation:
+			numbers, err := c.aggregationNumbers(a.Field, a.MissingValue, rows, fields)
+			if err != nil {
+				return results, err
+			}
+			sum := 0.0
+			for _, n := range numbers {
+				sum += n
+			}
+			result = &search.SumAggregationResult{Name: a.AggName, Value: sum}
+
+		case *search.MinAggregation:
+			numbers, err := c.aggregationNumbers(a.Field, a.MissingValue, rows, fields)
+			if err != nil {
+				return results, err
+			}
+			// Like the service, no value reads as +inf
+			value := math.Inf(1)
+			for _, n := range numbers {
+				value = min(value, n)
+			}
+			result = &search.MinAggregationResult{Name: a.AggName, Value: value}
+
+		case *search.MaxAggregation:
+			numbers, err := c.aggregationNumbers(a.Field, a.MissingValue, rows, fields)
+			if err != nil {
+				return results, err
+			}
+			value := math.Inf(-1)
+			for _, n := range numbers {
+				value = max(value, n)
+			}
+			result = &search.MaxAggregationResult{Name: a.AggName, Value: value}
+
+		case *search.AvgAggregation:
+			numbers, err := c.aggregationNumbers(a.Field, a.MissingValue, rows, fields)
+			if err != nil {
+				return results, err
+			}
+			value := math.Inf(1)
+			if len(numbers) > 0 {
+				sum := 0.0
+				for _, n := range numbers {
+					sum += n
+				}
+				value = sum / float64(len(numbers))
+			}
+			result = &search.AvgAggregationResult{Name: a.AggName, Value: value}
+
+		default:
+			return results, c.newError(CodeParameterInvalid, fmt.Sprintf("Aggregation %T is not supported by the fake.", agg))
+		}
+		results.Put(agg.GetName(), result)
+	}
+	return results, nil
+}
+
+// aggregationValues returns the values of field in rows, with missing in place of the values of
+// the rows that lack it unless missing is nil.
+func (c *Client) aggregationValues(field string, missing any, rows []*row, fields map[string]bool) ([]any, error) {
+	if !fields[field] {
+		return nil, c.newError(CodeParameterInvalid, fmt.Sprintf("Field %s is not in the search index schema.", field))
+	}
+	var values []any
+	for _, r := range rows {
+		if v, ok := searchValues(r)[field]; ok {
+			values = append(values, v)
+		} else if missing != nil {
+			values = append(values, missing)
+		}
+	}
+	return values, nil
+}
+
+// aggregationNumbers is aggregationValues for the numeric aggregations.
+func (c *Client) aggregationNumbers(field string, missing any, rows []*row, fields map[string]bool) ([]float64, error) {
+	values, err := c.aggregationValues(field, missing, rows, fields)
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]float64, 0, len(values))
+	for _, v := range values {
+		n, ok := searchNumber(v)
+		if !ok {
+			return nil, c.newError(CodeParameterInvalid, fmt.Sprintf("Field %s is not numeric.", field))
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+// groupSearchRows computes group-bys over the matched rows, with their sub-aggregations and
+// sub-group-bys.
+func (c *Client) groupSearchRows(groupBys []search.GroupBy, rows []*row, fields map[string]bool) (search.GroupByResults, error) {
+	var results search.GroupByResults
+	for _, groupBy := range groupBys {
+		switch g := groupBy.(type) {
+		case *search.GroupByField:
+			if !fields[g.Field] {
+				return results, c.newError(CodeParameterInvalid, fmt.Sprintf("Field %s is not in the search index schema.", g.Field))
+			}
+			var keys []string
+			buckets := make(map[string][]*row)
+			for _, r := range rows {
+				v, ok := searchValues(r)[g.Field]
+				if !ok {
+					continue
+				}
+				key := fmt.Sprint(v)
+				if _, ok := buckets[key]; !ok {
+					keys = append(keys, key)
+				}
+				buckets[key] = append(buckets[key], r)
+			}
+			if err := c.sortGroupKeys(keys, buckets, g.Sorters); err != nil {
+				return results, err
+			}
+			size := defaultGroupBySize
+			if g.Sz != nil {
+				size = int(*g.Sz)
+			}
+
+			result := &search.GroupByFieldResult{Name: g.AggName}
+			for _, key := range keys[:min(size, len(keys))] {
+				item := search.GroupByFieldResultItem{Key: key, RowCount: int64(len(buckets[key]))}
+				var err error
+				if item.SubAggregations, item.SubGroupBys, err = c.subGroupResults(g.SubAggList, g.SubGroupByList, buckets[key], fields); err != nil {
+					return results, err
+				}
+				result.Items = append(result.Items, item)
+			}
+			results.Put(g.AggName, result)
+
+		case *search.GroupByFilter:
+			result := &search.GroupByFilterResult{Name: g.AggName}
+			for _, query := range g.Queries {
+				var bucket []*row
+				for _, r := range rows {
+					ok, err := c.matchSearchQuery(query, fields, searchValues(r))
+					if err != nil {
+						return results, err
+					}
+					if ok {
+						bucket = append(bucket, r)
+					}
+				}
+				item := search.GroupByFilterResultItem{RowCount: int64(len(bucket))}
+				var err error
+				if item.SubAggregations, item.SubGroupBys, err = c.subGroupResults(g.SubAggList, g.SubGroupByList, bucket, fields); err != nil {
+					return results, err
+				}
+				result.Items = append(result.Items, item)
+			}
+			results.Put(g.AggName, result)
+
+		default:
+			return results, c.newError(CodeParameterInvalid, fmt.Sprintf("Group by %T is not supported by the fake.", groupBy))
+		}
+	}
+	return results, nil
+}
+
+// subGroupResults computes the sub-aggregations and sub-group-bys of a group.
+func (c *Client) subGroupResults(aggs []search.Aggregation, groupBys []search.GroupBy, rows []*row, fields map[string]bool) (search.AggregationResults, search.GroupByResults, error) {
+	subAggs, err := c.aggregateSearchRows(aggs, rows, fields)
+	if err != nil {
+		return subAggs, search.GroupByResults{}, err
+	}
+	subGroupBys, err := c.groupSearchRows(groupBys, rows, fields)
+	return subAggs, subGroupBys, err
+}
+
+// sortGroupKeys sorts the keys of the groups of a GroupByField by the row count and group key
+// sorters, by descending row count and then key by default.
+func (c *Client) sortGroupKeys(keys []string, buckets map[string][]*row, sorters []search.GroupBySorter) error {
+	for _, sorter := range sorters {
+		switch sorter.(type) {
+		case *search.RowCountGroupBySort, *search.GroupKeyGroupBySort:
+		default:
+			return c.newError(CodeParameterInvalid, fmt.Sprintf("Group by sorter %T is not supported by the fake.", sorter))
+		}
+	}
+	if len(sorters) == 0 {
+		sorters = []search.GroupBySorter{&search.RowCountGroupBySort{Order: search.SortOrder_DESC.Enum()}}
+	}
+
+	descending := func(o *search.SortOrder) bool { return o != nil && *o == search.SortOrder_DESC }
+	sort.SliceStable(keys, func(i, j int) bool {
+		for _, sorter := range sorters {
+			var n int
+			var order *search.SortOrder
+			switch s := sorter.(type) {
+			case *search.RowCountGroupBySort:
+				n, order = cmp.Compare(len(buckets[keys[i]]), len(buckets[keys[j]])), s.Order
+			case *search.GroupKeyGroupBySort:
+				n, order = cmp.Compare(keys[i], keys[j]), s.Order
+			}
+			if descending(order) {
+				n = -n
+			}
+			if n != 0 {
+				return n < 0
+			}
+		}
+		return keys[i] < keys[j]
+	})
+	return nil
+}
@@ -0,0 +1,346 @@
+package otsfake
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/117503445/otsutils/internal/plainbuffer"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/otsprotocol"
+	"github.com/golang/protobuf/proto"
+)
+
+// ServeHTTP serves the fake over the OTS protobuf-over-HTTP protocol, so a real
+// *tablestore.TableStoreClient pointed at an httptest.Server exercises the SDK's
+// serialization as well. Requests are not authenticated.
+//
+// Supported operations: ListTable, DescribeTable, PutRow, GetRow, UpdateRow, DeleteRow and GetRange.
+func (c *Client) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	operation := strings.TrimPrefix(r.URL.Path, "/")
+
+	body, err := io.ReadAll(r.Body)
+	var resp proto.Message
+	if err == nil {
+		resp, err = c.serve(operation, body)
+	}
+
+	c.mu.Lock()
+	c.requestID++
+	w.Header().Set("x-ots-requestid", fmt.Sprintf("fake-http-%d", c.requestID))
+	c.mu.Unlock()
+
+	if err != nil {
+		status := http.StatusBadRequest
+		code, message := CodeParameterInvalid, err.Error()
+		var otsErr *tablestore.OtsError
+		if errors.As(err, &otsErr) {
+			status, code, message = otsErr.HttpStatusCode, otsErr.Code, otsErr.Message
+		}
+		data, _ := proto.Marshal(&otsprotocol.Error{Code: proto.String(code), Message: proto.String(message)})
+		w.WriteHeader(status)
+		_, _ = w.Write(data)
+		return
+	}
+
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(data)
+}
+
+func (c *Client) serve(operation string, body []byte) (proto.Message, error) {
+	switch operation {
+	case "ListTable":
+		resp, err := c.ListTable()
+		if err != nil {
+			return nil, err
+		}
+		return &otsprotocol.ListTableResponse{TableNames: resp.TableNames}, nil
+
+	case "DescribeTable":
+		req := &otsprotocol.DescribeTableRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			return nil, err
+		}
+		resp, err := c.DescribeTable(&tablestore.DescribeTableRequest{TableName: req.GetTableName()})
+		if err != nil {
+			return nil, err
+		}
+		return describeTableResponse(resp), nil
+
+	case "PutRow":
+		req := &otsprotocol.PutRowRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			return nil, err
+		}
+		row, err := decodeRow(req.Row)
+		if err != nil {
+			return nil, err
+		}
+		condition, err := rowCondition(req.Condition)
+		if err != nil {
+			return nil, err
+		}
+		change := &tablestore.PutRowChange{
+			TableName:  req.GetTableName(),
+			PrimaryKey: primaryKey(row.PrimaryKey),
+			Condition:  condition,
+			ReturnType: tablestore.ReturnType(req.GetReturnContent().GetReturnType()),
+		}
+		for _, cell := range row.Cells {
+			change.Columns = append(change.Columns, tablestore.AttributeColumn{ColumnName: cell.Name, Value: cell.Value, Timestamp: cell.Timestamp})
+		}
+		resp, err := c.PutRow(&tablestore.PutRowRequest{PutRowChange: change})
+		if err != nil {
+			return nil, err
+		}
+		out := &otsprotocol.PutRowResponse{Consumed: consumed(resp.ConsumedCapacityUnit)}
+		if len(resp.PrimaryKey.PrimaryKeys) > 0 {
+			out.Row = plainbuffer.Encode(plainbuffer.Row{PrimaryKey: cells(resp.PrimaryKey.PrimaryKeys)})
+		}
+		return out, nil
+
+	case "UpdateRow":
+		req := &otsprotocol.UpdateRowRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			return nil, err
+		}
+		row, err := decodeRow(req.RowChange)
+		if err != nil {
+			return nil, err
+		}
+		condition, err := rowCondition(req.Condition)
+		if err != nil {
+			return nil, err
+		}
+		change := &tablestore.UpdateRowChange{
+			TableName:           req.GetTableName(),
+			PrimaryKey:          primaryKey(row.PrimaryKey),
+			Condition:           condition,
+			ReturnType:          tablestore.ReturnType(req.GetReturnContent().GetReturnType()),
+			ColumnNamesToReturn: req.GetReturnContent().GetReturnColumnNames(),
+		}
+		for _, cell := range row.Cells {
+			change.Columns = append(change.Columns, tablestore.ColumnToUpdate{
+				ColumnName:   cell.Name,
+				Type:         cell.Type,
+				HasType:      cell.HasType,
+				Timestamp:    cell.Timestamp,
+				HasTimestamp: cell.HasTimestamp,
+				IgnoreValue:  cell.Value == nil,
+				Value:        cell.Value,
+			})
+		}
+		resp, err := c.UpdateRow(&tablestore.UpdateRowRequest{UpdateRowChange: change})
+		if err != nil {
+			return nil, err
+		}
+		out := &otsprotocol.UpdateRowResponse{Consumed: consumed(resp.ConsumedCapacityUnit)}
+		if change.ReturnType == tablestore.ReturnType_RT_AFTER_MODIFY {
+			out.Row = plainbuffer.Encode(plainbuffer.Row{PrimaryKey: cells(change.PrimaryKey.PrimaryKeys), Cells: attributeCells(resp.Columns)})
+		}
+		return out, nil
+
+	case "DeleteRow":
+		req := &otsprotocol.DeleteRowRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			return nil, err
+		}
+		row, err := decodeRow(req.PrimaryKey)
+		if err != nil {
+			return nil, err
+		}
+		condition, err := rowCondition(req.Condition)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.DeleteRow(&tablestore.DeleteRowRequest{DeleteRowChange: &tablestore.DeleteRowChange{
+			TableName:  req.GetTableName(),
+			PrimaryKey: primaryKey(row.PrimaryKey),
+			Condition:  condition,
+		}})
+		if err != nil {
+			return nil, err
+		}
+		return &otsprotocol.DeleteRowResponse{Consumed: consumed(resp.ConsumedCapacityUnit)}, nil
+
+	case "GetRow":
+		req := &otsprotocol.GetRowRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			return nil, err
+		}
+		if len(req.Filter) > 0 {
+			return nil, errors.New("otsfake: filters are not supported")
+		}
+		row, err := decodeRow(req.PrimaryKey)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.GetRow(&tablestore.GetRowRequest{SingleRowQueryCriteria: &tablestore.SingleRowQueryCriteria{
+			TableName:    req.GetTableName(),
+			PrimaryKey:   primaryKey(row.PrimaryKey),
+			ColumnsToGet: req.ColumnsToGet,
+			MaxVersion:   req.GetMaxVersions(),
+			TimeRange:    timeRange(req.TimeRange),
+		}})
+		if err != nil {
+			return nil, err
+		}
+		out := &otsprotocol.GetRowResponse{Consumed: consumed(resp.ConsumedCapacityUnit), Row: []byte{}}
+		if len(resp.PrimaryKey.PrimaryKeys) > 0 {
+			out.Row = plainbuffer.Encode(plainbuffer.Row{PrimaryKey: cells(resp.PrimaryKey.PrimaryKeys), Cells: attributeCells(resp.Columns)})
+		}
+		return out, nil
+
+	case "GetRange":
+		req := &otsprotocol.GetRangeRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			return nil, err
+		}
+		if len(req.Filter) > 0 {
+			return nil, errors.New("otsfake: filters are not supported")
+		}
+		start, err := decodeRow(req.InclusiveStartPrimaryKey)
+		if err != nil {
+			return nil, err
+		}
+		end, err := decodeRow(req.ExclusiveEndPrimaryKey)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.GetRange(&tablestore.GetRangeRequest{RangeRowQueryCriteria: &tablestore.RangeRowQueryCriteria{
+			TableName:       req.GetTableName(),
+			StartPrimaryKey: primaryKey(start.PrimaryKey),
+			EndPrimaryKey:   primaryKey(end.PrimaryKey),
+			ColumnsToGet:    req.ColumnsToGet,
+			MaxVersion:      req.GetMaxVersions(),
+			TimeRange:       timeRange(req.TimeRange),
+			Direction:       tablestore.Direction(req.GetDirection()),
+			Limit:           req.GetLimit(),
+		}})
+		if err != nil {
+			return nil, err
+		}
+		out := &otsprotocol.GetRangeResponse{
+			Consumed:      consumed(resp.ConsumedCapacityUnit),
+			Rows:          []byte{},
+			DataBlockType: otsprotocol.DataBlockType_DBT_PLAIN_BUFFER.Enum(),
+			CompressType:  otsprotocol.CompressType_CPT_NONE.Enum(),
+		}
+		if len(resp.Rows) > 0 {
+			rows := make([]plainbuffer.Row, 0, len(resp.Rows))
+			for _, r := range resp.Rows {
+				rows = append(rows, plainbuffer.Row{PrimaryKey: cells(r.PrimaryKey.PrimaryKeys), Cells: attributeCells(r.Columns)})
+			}
+			out.Rows = plainbuffer.Encode(rows...)
+		}
+		if resp.NextStartPrimaryKey != nil {
+			out.NextStartPrimaryKey = plainbuffer.Encode(plainbuffer.Row{PrimaryKey: cells(resp.NextStartPrimaryKey.PrimaryKeys)})
+		}
+		return out, nil
+
+	default:
+		return nil, fmt.Errorf("otsfake: unsupported operation %q", operation)
+	}
+}
+
+func decodeRow(data []byte) (plainbuffer.Row, error) {
+	rows, err := plainbuffer.Decode(data)
+	if err != nil {
+		return plainbuffer.Row{}, err
+	}
+	if len(rows) != 1 {
+		return plainbuffer.Row{}, fmt.Errorf("otsfake: expected one row, got %d", len(rows))
+	}
+	return rows[0], nil
+}
+
+func rowCondition(condition *otsprotocol.Condition) (*tablestore.RowCondition, error) {
+	if len(condition.GetColumnCondition()) > 0 {
+		return nil, errors.New("otsfake: column conditions are not supported")
+	}
+	return &tablestore.RowCondition{RowExistenceExpectation: tablestore.RowExistenceExpectation(condition.GetRowExistence())}, nil
+}
+
+func primaryKey(pkCells []plainbuffer.Cell) *tablestore.PrimaryKey {
+	pk := &tablestore.PrimaryKey{}
+	for _, cell := range pkCells {
+		switch cell.Value {
+		case plainbuffer.InfMin:
+			pk.AddPrimaryKeyColumnWithMinValue(cell.Name)
+		case plainbuffer.InfMax:
+			pk.AddPrimaryKeyColumnWithMaxValue(cell.Name)
+		case plainbuffer.AutoIncrement:
+			pk.AddPrimaryKeyColumnWithAutoIncrement(cell.Name)
+		default:
+			pk.AddPrimaryKeyColumn(cell.Name, cell.Value)
+		}
+	}
+	return pk
+}
+
+func cells(pk []*tablestore.PrimaryKeyColumn) []plainbuffer.Cell {
+	out := make([]plainbuffer.Cell, 0, len(pk))
+	for _, col := range pk {
+		out = append(out, plainbuffer.Cell{Name: col.ColumnName, Value: col.Value})
+	}
+	return out
+}
+
+func attributeCells(cols []*tablestore.AttributeColumn) []plainbuffer.Cell {
+	out := make([]plainbuffer.Cell, 0, len(cols))
+	for _, col := range cols {
+		out = append(out, plainbuffer.Cell{Name: col.ColumnName, Value: col.Value, Timestamp: col.Timestamp, HasTimestamp: true})
+	}
+	return out
+}
+
+func timeRange(tr *otsprotocol.TimeRange) *tablestore.TimeRange {
+	if tr == nil {
+		return nil
+	}
+	return &tablestore.TimeRange{Start: tr.GetStartTime(), End: tr.GetEndTime(), Specific: tr.GetSpecificTime()}
+}
+
+func consumed(cu *tablestore.ConsumedCapacityUnit) *otsprotocol.ConsumedCapacity {
+	if cu == nil {
+		cu = &tablestore.ConsumedCapacityUnit{}
+	}
+	return &otsprotocol.ConsumedCapacity{CapacityUnit: &otsprotocol.CapacityUnit{Read: proto.Int32(cu.Read), Write: proto.Int32(cu.Write)}}
+}
+
+func describeTableResponse(resp *tablestore.DescribeTableResponse) *otsprotocol.DescribeTableResponse {
+	meta := &otsprotocol.TableMeta{TableName: proto.String(resp.TableMeta.TableName)}
+	for _, s := range resp.TableMeta.SchemaEntry {
+		schema := &otsprotocol.PrimaryKeySchema{Name: proto.String(*s.Name), Type: otsprotocol.PrimaryKeyType(*s.Type).Enum()}
+		if s.Option != nil && *s.Option == tablestore.AUTO_INCREMENT {
+			schema.Option = otsprotocol.PrimaryKeyOption_AUTO_INCREMENT.Enum()
+		}
+		meta.PrimaryKey = append(meta.PrimaryKey, schema)
+	}
+	for _, d := range resp.TableMeta.DefinedColumns {
+		meta.DefinedColumn = append(meta.DefinedColumn, &otsprotocol.DefinedColumnSchema{
+			Name: proto.String(d.Name),
+			Type: d.ColumnType.ConvertToPbDefinedColumnType().Enum(),
+		})
+	}
+
+	return &otsprotocol.DescribeTableResponse{
+		TableMeta: meta,
+		ReservedThroughputDetails: &otsprotocol.ReservedThroughputDetails{
+			CapacityUnit:     &otsprotocol.CapacityUnit{Read: proto.Int32(0), Write: proto.Int32(0)},
+			LastIncreaseTime: proto.Int64(0),
+		},
+		TableOptions: &otsprotocol.TableOptions{
+			TimeToLive:                proto.Int32(int32(resp.TableOption.TimeToAlive)),
+			MaxVersions:               proto.Int32(int32(resp.TableOption.MaxVersion)),
+			DeviationCellVersionInSec: proto.Int64(resp.TableOption.DeviationCellVersionInSec),
+		},
+		TableStatus: otsprotocol.TableStatus_ACTIVE.Enum(),
+	}
+}
@@ -0,0 +1,150 @@
+package otsfake
+
+import (
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// transaction is a local transaction started with StartLocalTransaction.
+type transaction struct {
+	tableName string
+
+	// partition is the encoded value of the partition key the transaction is bound to
+	partition string
+
+	// snapshot holds the rows of the partition as they were when the transaction started,
+	// restored on abort
+	snapshot map[string]*row
+}
+
+// StartLocalTransaction starts a local transaction on the rows of a table sharing the value of
+// its first primary key column, the partition key. The primary key of the request holds only
+// that column.
+//
+// Unlike the service, the fake applies the writes of a transaction right away, where every
+// reader sees them, and does not lock the partition against other writers. Aborting restores
+// the rows of the partition as they were when the transaction started.
+func (c *Client) StartLocalTransaction(request *tablestore.StartLocalTransactionRequest) (*tablestore.StartLocalTransactionResponse, error) {
+	if err := c.begin("StartLocalTransaction", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, err := c.table(request.TableName)
+	if err != nil {
+		return nil, err
+	}
+	pk := request.PrimaryKey
+	if pk == nil || len(pk.PrimaryKeys) != 1 {
+		return nil, c.newError(CodeParameterInvalid, "The primary key of a local transaction must only hold the partition key. Input: "+describePrimaryKey(pk)+".")
+	}
+	schema, col := t.meta.SchemaEntry[0], pk.PrimaryKeys[0]
+	if col.ColumnName != *schema.Name {
+		return nil, c.newError(CodeParameterInvalid, fmt.Sprintf("Validate PK name fail. Input: %s, Meta: %s.", col.ColumnName, *schema.Name))
+	}
+	if !valueMatchesType(col.Value, *schema.Type) {
+		return nil, c.newError(CodeParameterInvalid, fmt.Sprintf("Validate PK type fail. Input: %T, Meta: %s.", col.Value, typeName(*schema.Type)))
+	}
+
+	txn := &transaction{
+		tableName: request.TableName,
+		partition: encodePrimaryKey(pk.PrimaryKeys),
+		snapshot:  make(map[string]*row),
+	}
+	for key, r := range t.rows {
+		if encodePrimaryKey(r.pk[:1]) == txn.partition {
+			txn.snapshot[key] = r.clone()
+		}
+	}
+	c.transactionSeq++
+	id := fmt.Sprintf("fake-txn-%d", c.transactionSeq)
+	c.transactions[id] = txn
+	return &tablestore.StartLocalTransactionResponse{TransactionId: &id}, nil
+}
+
+// CommitTransaction ends a local transaction, keeping its writes.
+func (c *Client) CommitTransaction(request *tablestore.CommitTransactionRequest) (*tablestore.CommitTransactionResponse, error) {
+	if err := c.begin("CommitTransaction", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := c.endTransaction(request.TransactionId); err != nil {
+		return nil, err
+	}
+	return &tablestore.CommitTransactionResponse{}, nil
+}
+
+// AbortTransaction ends a local transaction, restoring the rows of its partition.
+func (c *Client) AbortTransaction(request *tablestore.AbortTransactionRequest) (*tablestore.AbortTransactionResponse, error) {
+	if err := c.begin("AbortTransaction", request); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	txn, err := c.endTransaction(request.TransactionId)
+	if err != nil {
+		return nil, err
+	}
+	t, err := c.table(txn.tableName)
+	if err != nil {
+		return nil, err
+	}
+	for key, r := range t.rows {
+		if encodePrimaryKey(r.pk[:1]) == txn.partition {
+			delete(t.rows, key)
+		}
+	}
+	for key, r := range txn.snapshot {
+		t.rows[key] = r
+	}
+	return &tablestore.AbortTransactionResponse{}, nil
+}
+
+// endTransaction removes the transaction id from the open transactions. The caller holds c.mu.
+func (c *Client) endTransaction(id *string) (*transaction, error) {
+	if id == nil {
+		return nil, c.newError(CodeParameterInvalid, "TransactionId is not set.")
+	}
+	txn, ok := c.transactions[*id]
+	if !ok {
+		return nil, c.newError(CodeParameterInvalid, "Transaction does not exist: "+*id+".")
+	}
+	delete(c.transactions, *id)
+	return txn, nil
+}
+
+// checkTransaction checks that a row operation given a transaction id targets the table and
+// the partition of an open transaction. The caller holds c.mu.
+func (c *Client) checkTransaction(id *string, tableName string, pk *tablestore.PrimaryKey) error {
+	if id == nil {
+		return nil
+	}
+	txn, ok := c.transactions[*id]
+	if !ok {
+		return c.newError(CodeParameterInvalid, "Transaction does not exist: "+*id+".")
+	}
+	if txn.tableName != tableName {
+		return c.newError(CodeParameterInvalid, fmt.Sprintf("Transaction %s is on table %s, not %s.", *id, txn.tableName, tableName))
+	}
+	if pk == nil || len(pk.PrimaryKeys) == 0 || encodePrimaryKey(pk.PrimaryKeys[:1]) != txn.partition {
+		return c.newError(CodeParameterInvalid, "The partition key does not match the one of the transaction "+*id+".")
+	}
+	return nil
+}
+
+// clone returns a copy of the row that later writes to r leave unchanged.
+func (r *row) clone() *row {
+	out := &row{
+		pk:   clonePrimaryKey(&tablestore.PrimaryKey{PrimaryKeys: r.pk}),
+		cols: make(map[string][]*tablestore.AttributeColumn, len(r.cols)),
+	}
+	for name, versions := range r.cols {
+		out.cols[name] = append([]*tablestore.AttributeColumn(nil), versions...)
+	}
+	return out
+}
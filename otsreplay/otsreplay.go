@@ -0,0 +1,259 @@
+// Package otsreplay is an HTTP-level record/replay harness for tests that talk to TableStore.
+//
+// A Server speaks the OTS protobuf-over-HTTP protocol on an httptest.Server. In record mode it
+// proxies every request to a real endpoint and writes the request/response pairs to a JSON
+// fixture when the test ends; in replay mode it answers from that fixture, matching requests
+// by operation, table and primary key. Point a *tablestore.TableStoreClient at Server.URL.
+//
+// Example usage:
+//
+//	srv := otsreplay.Replay(t, "testdata/TestGetRow.json")
+//	client := otsutils.NewClient(ctx, srv.URL, "replay", "ak", "sk")
+package otsreplay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/117503445/otsutils/internal/plainbuffer"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/otsprotocol"
+	"github.com/golang/protobuf/proto"
+)
+
+// Interaction is one recorded request/response pair.
+type Interaction struct {
+	Operation string `json:"operation"`
+	Key       string `json:"key"`
+	Request   []byte `json:"request"`
+	Status    int    `json:"status"`
+	RequestID string `json:"requestId,omitempty"`
+	Response  []byte `json:"response"`
+}
+
+// Fixture is the content of a fixture file.
+type Fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Server is a recording or replaying OTS endpoint.
+type Server struct {
+	// URL is the endpoint to pass to the TableStore client.
+	URL string
+
+	t        testing.TB
+	path     string
+	upstream string
+	client   *http.Client
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// Record starts a server that forwards every request to upstream and writes the
+// interactions to path when the test finishes.
+func Record(t testing.TB, upstream, path string) *Server {
+	t.Helper()
+	s := &Server{t: t, path: path, upstream: strings.TrimSuffix(upstream, "/"), client: &http.Client{}}
+	s.start(s.record)
+	t.Cleanup(func() {
+		if err := s.save(); err != nil {
+			t.Errorf("otsreplay: save %s: %v", path, err)
+		}
+	})
+	return s
+}
+
+// Replay starts a server that answers from the fixture at path.
+// It fails the test if the fixture cannot be read, and reports requests with no recorded answer.
+func Replay(t testing.TB, path string) *Server {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("otsreplay: read fixture: %v", err)
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		t.Fatalf("otsreplay: parse fixture %s: %v", path, err)
+	}
+
+	s := &Server{t: t, path: path, interactions: fixture.Interactions, used: make([]bool, len(fixture.Interactions))}
+	s.start(s.replay)
+	return s
+}
+
+func (s *Server) start(handler http.HandlerFunc) {
+	srv := httptest.NewServer(handler)
+	s.t.Cleanup(srv.Close)
+	s.URL = srv.URL
+}
+
+func (s *Server) record(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, s.upstream+r.URL.Path, bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	req.Header = r.Header.Clone()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	operation := operationOf(r)
+	s.mu.Lock()
+	s.interactions = append(s.interactions, Interaction{
+		Operation: operation,
+		Key:       requestKey(operation, body),
+		Request:   body,
+		Status:    resp.StatusCode,
+		RequestID: resp.Header.Get("x-ots-requestid"),
+		Response:  respBody,
+	})
+	s.mu.Unlock()
+
+	writeResponse(w, resp.StatusCode, resp.Header.Get("x-ots-requestid"), respBody)
+}
+
+func (s *Server) replay(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	operation := operationOf(r)
+	key := requestKey(operation, body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, in := range s.interactions {
+		if s.used[i] || in.Operation != operation || in.Key != key {
+			continue
+		}
+		s.used[i] = true
+		writeResponse(w, in.Status, in.RequestID, in.Response)
+		return
+	}
+
+	s.t.Errorf("otsreplay: no recorded interaction left for %s %s in %s", operation, key, s.path)
+	data, _ := proto.Marshal(&otsprotocol.Error{
+		Code:    proto.String("OTSReplayMiss"),
+		Message: proto.String(fmt.Sprintf("no recorded interaction for %s %s", operation, key)),
+	})
+	writeResponse(w, http.StatusNotImplemented, "", data)
+}
+
+func (s *Server) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(Fixture{Interactions: s.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, append(data, '\n'), 0o644)
+}
+
+func writeResponse(w http.ResponseWriter, status int, requestID string, body []byte) {
+	if requestID != "" {
+		w.Header().Set("x-ots-requestid", requestID)
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+func operationOf(r *http.Request) string {
+	return strings.TrimPrefix(r.URL.Path, "/")
+}
+
+// requestKey identifies a request within its operation: the table and primary key for row
+// operations, the table for DescribeTable, and a hash of the body otherwise.
+func requestKey(operation string, body []byte) string {
+	switch operation {
+	case "GetRow":
+		req := &otsprotocol.GetRowRequest{}
+		if proto.Unmarshal(body, req) == nil {
+			return req.GetTableName() + " " + formatPrimaryKey(req.PrimaryKey)
+		}
+	case "PutRow":
+		req := &otsprotocol.PutRowRequest{}
+		if proto.Unmarshal(body, req) == nil {
+			return req.GetTableName() + " " + formatPrimaryKey(req.Row)
+		}
+	case "UpdateRow":
+		req := &otsprotocol.UpdateRowRequest{}
+		if proto.Unmarshal(body, req) == nil {
+			return req.GetTableName() + " " + formatPrimaryKey(req.RowChange)
+		}
+	case "DeleteRow":
+		req := &otsprotocol.DeleteRowRequest{}
+		if proto.Unmarshal(body, req) == nil {
+			return req.GetTableName() + " " + formatPrimaryKey(req.PrimaryKey)
+		}
+	case "GetRange":
+		req := &otsprotocol.GetRangeRequest{}
+		if proto.Unmarshal(body, req) == nil {
+			return req.GetTableName() + " " + formatPrimaryKey(req.InclusiveStartPrimaryKey) + " .. " + formatPrimaryKey(req.ExclusiveEndPrimaryKey)
+		}
+	case "DescribeTable":
+		req := &otsprotocol.DescribeTableRequest{}
+		if proto.Unmarshal(body, req) == nil {
+			return req.GetTableName()
+		}
+	}
+
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+func formatPrimaryKey(data []byte) string {
+	rows, err := plainbuffer.Decode(data)
+	if err != nil || len(rows) == 0 {
+		sum := sha256.Sum256(data)
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+
+	parts := make([]string, 0, len(rows[0].PrimaryKey))
+	for _, cell := range rows[0].PrimaryKey {
+		var value string
+		switch v := cell.Value.(type) {
+		case string:
+			value = fmt.Sprintf("%q", v)
+		case []byte:
+			value = "0x" + hex.EncodeToString(v)
+		case plainbuffer.Special:
+			value = map[plainbuffer.Special]string{plainbuffer.InfMin: "MIN", plainbuffer.InfMax: "MAX", plainbuffer.AutoIncrement: "AUTO"}[v]
+		default:
+			value = fmt.Sprint(v)
+		}
+		parts = append(parts, cell.Name+"="+value)
+	}
+	return "(" + strings.Join(parts, ",") + ")"
+}
@@ -0,0 +1,127 @@
+package otsreplay
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/117503445/otsutils/otsfake"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+// exercise runs a fixed sequence of operations and returns what the client observed.
+func exercise(t *testing.T, endpoint string) []string {
+	client := tablestore.NewClient(endpoint, "replay", "ak", "sk")
+	var out []string
+
+	put := &tablestore.PutRowChange{TableName: "t", PrimaryKey: &tablestore.PrimaryKey{}}
+	put.PrimaryKey.AddPrimaryKeyColumn("id", "a")
+	put.AddColumn("n", int64(1))
+	put.SetCondition(tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST)
+	_, err := client.PutRow(&tablestore.PutRowRequest{PutRowChange: put})
+	out = append(out, fmt.Sprint(err))
+
+	// 第二次写入违反条件
+	_, err = client.PutRow(&tablestore.PutRowRequest{PutRowChange: put})
+	out = append(out, err.(*tablestore.OtsError).Code)
+
+	update := &tablestore.UpdateRowChange{TableName: "t", PrimaryKey: put.PrimaryKey}
+	update.IncrementColumn("n", 2)
+	update.PutColumn("s", "x")
+	update.SetCondition(tablestore.RowExistenceExpectation_EXPECT_EXIST)
+	_, err = client.UpdateRow(&tablestore.UpdateRowRequest{UpdateRowChange: update})
+	out = append(out, fmt.Sprint(err))
+
+	get := &tablestore.SingleRowQueryCriteria{TableName: "t", PrimaryKey: put.PrimaryKey, MaxVersion: 1}
+	resp, err := client.GetRow(&tablestore.GetRowRequest{SingleRowQueryCriteria: get})
+	if assert.NoError(t, err) {
+		for _, col := range resp.Columns {
+			out = append(out, fmt.Sprintf("%s=%v", col.ColumnName, col.Value))
+		}
+	}
+
+	start, end := &tablestore.PrimaryKey{}, &tablestore.PrimaryKey{}
+	start.AddPrimaryKeyColumnWithMinValue("id")
+	end.AddPrimaryKeyColumnWithMaxValue("id")
+	rangeResp, err := client.GetRange(&tablestore.GetRangeRequest{RangeRowQueryCriteria: &tablestore.RangeRowQueryCriteria{
+		TableName: "t", StartPrimaryKey: start, EndPrimaryKey: end, MaxVersion: 1, Direction: tablestore.FORWARD,
+	}})
+	if assert.NoError(t, err) {
+		out = append(out, fmt.Sprintf("rows=%d", len(rangeResp.Rows)))
+	}
+
+	del := &tablestore.DeleteRowChange{TableName: "t", PrimaryKey: put.PrimaryKey}
+	del.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+	_, err = client.DeleteRow(&tablestore.DeleteRowRequest{DeleteRowChange: del})
+	out = append(out, fmt.Sprint(err))
+
+	resp, err = client.GetRow(&tablestore.GetRowRequest{SingleRowQueryCriteria: get})
+	if assert.NoError(t, err) {
+		out = append(out, fmt.Sprintf("found=%v", len(resp.PrimaryKey.PrimaryKeys) > 0))
+	}
+
+	desc, err := client.DescribeTable(&tablestore.DescribeTableRequest{TableName: "t"})
+	if assert.NoError(t, err) {
+		out = append(out, *desc.TableMeta.SchemaEntry[0].Name)
+	}
+	return out
+}
+
+func TestRecordReplay(t *testing.T) {
+	ast := assert.New(t)
+	path := filepath.Join(t.TempDir(), "fixture.json")
+
+	fake := otsfake.New()
+	fake.MustCreateTable("t", "id", tablestore.PrimaryKeyType_STRING)
+	upstream := httptest.NewServer(fake)
+	defer upstream.Close()
+
+	want := []string{"<nil>", "OTSConditionCheckFail", "<nil>", "n=3", "s=x", "rows=1", "<nil>", "found=false", "id"}
+
+	t.Run("record", func(t *testing.T) {
+		srv := Record(t, upstream.URL, path)
+		ast.Equal(want, exercise(t, srv.URL))
+	})
+	calls := fake.CallCount("GetRow")
+
+	t.Run("replay", func(t *testing.T) {
+		srv := Replay(t, path)
+		ast.Equal(want, exercise(t, srv.URL))
+	})
+	ast.Equal(calls, fake.CallCount("GetRow"))
+}
+
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (tb *recordingTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func TestReplayMiss(t *testing.T) {
+	ast := assert.New(t)
+
+	tb := &recordingTB{TB: t}
+	srv := Replay(tb, filepath.Join("testdata", "empty.json"))
+	client := tablestore.NewClient(srv.URL, "replay", "ak", "sk")
+	_, err := client.ListTable()
+	ast.Error(err)
+	if ast.Len(tb.errors, 1) {
+		ast.Contains(tb.errors[0], "no recorded interaction left for ListTable")
+	}
+}
+
+func TestRequestKey(t *testing.T) {
+	pk := &tablestore.PrimaryKey{}
+	pk.AddPrimaryKeyColumn("a", "x")
+	pk.AddPrimaryKeyColumn("b", int64(2))
+	pk.AddPrimaryKeyColumn("c", []byte{0xff})
+	pk.AddPrimaryKeyColumnWithMinValue("d")
+
+	assert.Equal(t, `(a="x",b=2,c=0xff,d=MIN)`, formatPrimaryKey(pk.Build(false)))
+	assert.Equal(t, requestKey("ListTable", nil), requestKey("ListTable", []byte{}))
+}
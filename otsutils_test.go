@@ -1,26 +1,112 @@
 package otsutils
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
 	"testing"
 
 	"github.com/117503445/goutils"
+	"github.com/117503445/otsutils/otsfake"
+	"github.com/117503445/otsutils/otsreplay"
 	"github.com/alibabacloud-go/tea/tea"
 	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 )
 
+// TestRow is a row of the table of newFakeContext, whose primary key is pk1 and pk2.
 type TestRow struct {
 	Pk1  *string `json:"pk1" pk:"1"`
 	Pk2  *int64  `json:"pk2" pk:"2"`
-	Pk3  *[]byte `json:"pk3" pk:"3"`
 	Col1 *string `json:"col1"`
 	Col2 *int64  `json:"col2"`
 	Col3 *string `json:"col3"`
 }
 
+// newFakeContext returns a context bound to an in-memory fake holding TestRow's table.
+func newFakeContext(t *testing.T) (context.Context, *otsfake.Client) {
+	t.Helper()
+	goutils.InitZeroLog()
+	ctx := log.Logger.WithContext(context.Background())
+
+	fake := otsfake.New()
+	fake.MustCreateTable("test_table",
+		"pk1", tablestore.PrimaryKeyType_STRING,
+		"pk2", tablestore.PrimaryKeyType_INTEGER,
+	)
+
+	o := OtsUtilsParams{
+		Client:    fake,
+		TableName: "test_table",
+	}
+	return o.WithContext(ctx), fake
+}
+
+// newSingleKeyContext returns a context bound to table, a table of the fake of newFakeContext
+// whose primary key is the single STRING column keyColumn.
+func newSingleKeyContext(t *testing.T, table, keyColumn string) (context.Context, *otsfake.Client) {
+	t.Helper()
+	ctx, fake := newFakeContext(t)
+	fake.MustCreateTable(table, keyColumn, tablestore.PrimaryKeyType_STRING)
+	o := OtsUtilsParams{Client: fake, TableName: table}
+	return o.WithContext(ctx), fake
+}
+
+// recordBackend is the shared upstream used to seed fixtures without credentials.
+var (
+	recordBackendOnce sync.Once
+	recordBackend     *httptest.Server
+)
+
+// newIntegrationContext returns a context bound to test_table for the integration tests.
+// With credentials in the environment the test runs against the real service; otherwise it
+// replays testdata/<test name>.json. Setting OTSREPLAY_RECORD=1 re-records that fixture,
+// from the real service when credentials exist and from otsfake served over HTTP otherwise.
+//
+// The fixtures in testdata were recorded the second way: their requests are encoded by the
+// SDK, but their responses come from otsfake, not the service. They check the SDK boundary
+// of this package, not that otsfake behaves like the service, until they are re-recorded
+// with credentials.
+func newIntegrationContext(t *testing.T) context.Context {
+	t.Helper()
+	goutils.InitZeroLog()
+	ctx := log.Logger.WithContext(context.Background())
+
+	fixture := filepath.Join("testdata", t.Name()+".json")
+	endpoint, instanceName, ak, sk := os.Getenv("endpoint"), os.Getenv("instanceName"), os.Getenv("ak"), os.Getenv("sk")
+
+	switch {
+	case os.Getenv("OTSREPLAY_RECORD") != "":
+		if endpoint == "" {
+			recordBackendOnce.Do(func() {
+				fake := otsfake.New()
+				fake.MustCreateTable("test_table",
+					"pk1", tablestore.PrimaryKeyType_STRING,
+					"pk2", tablestore.PrimaryKeyType_INTEGER,
+				)
+				recordBackend = httptest.NewServer(fake)
+			})
+			endpoint, instanceName, ak, sk = recordBackend.URL, "replay", "ak", "sk"
+		}
+		endpoint = otsreplay.Record(t, endpoint, fixture).URL
+	case endpoint == "":
+		endpoint, instanceName, ak, sk = otsreplay.Replay(t, fixture).URL, "replay", "ak", "sk"
+	}
+
+	o := OtsUtilsParams{
+		Client:    NewClient(ctx, endpoint, instanceName, ak, sk),
+		TableName: "test_table",
+	}
+	return o.WithContext(ctx)
+}
+
 func TestClient(t *testing.T) {
 	// 测试正常创建客户端
 	goutils.InitZeroLog()
@@ -46,22 +132,7 @@ func TestClient(t *testing.T) {
 }
 
 func TestPutRow(t *testing.T) {
-	// Skip test if no credentials
-	if os.Getenv("endpoint") == "" {
-		t.Skip("Skipping test: no credentials provided")
-	}
-
-	goutils.InitZeroLog()
-	ctx := context.Background()
-	ctx = log.Logger.WithContext(ctx)
-
-	client := NewClient(ctx, os.Getenv("endpoint"), os.Getenv("instanceName"), os.Getenv("ak"), os.Getenv("sk"))
-
-	o := OtsUtilsParams{
-		Client:    client,
-		TableName: "test_table",
-	}
-	ctx = o.WithContext(ctx)
+	ctx := newIntegrationContext(t)
 
 	// 测试插入新行
 	obj := TestRow{
@@ -92,22 +163,7 @@ func TestPutRow(t *testing.T) {
 }
 
 func TestGetRow(t *testing.T) {
-	// Skip test if no credentials
-	if os.Getenv("endpoint") == "" {
-		t.Skip("Skipping test: no credentials provided")
-	}
-
-	goutils.InitZeroLog()
-	ctx := context.Background()
-	ctx = log.Logger.WithContext(ctx)
-
-	client := NewClient(ctx, os.Getenv("endpoint"), os.Getenv("instanceName"), os.Getenv("ak"), os.Getenv("sk"))
-
-	o := OtsUtilsParams{
-		Client:    client,
-		TableName: "test_table",
-	}
-	ctx = o.WithContext(ctx)
+	ctx := newIntegrationContext(t)
 
 	// 测试获取行
 	obj := TestRow{
@@ -134,23 +190,130 @@ func TestGetRow(t *testing.T) {
 	}
 }
 
-func TestUpdateRow(t *testing.T) {
-	// Skip test if no credentials
-	if os.Getenv("endpoint") == "" {
-		t.Skip("Skipping test: no credentials provided")
+func TestGetRowFilter(t *testing.T) {
+	type statusRow struct {
+		Pk1    *string `json:"pk1" pk:"1"`
+		Pk2    *int64  `json:"pk2" pk:"2"`
+		Status *string `json:"status"`
+		Score  *int64  `json:"score"`
+	}
+	ctx, _ := newFakeContext(t)
+	assert.NoError(t, PutRow(ctx, &statusRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Status: tea.String("active"), Score: tea.Int64(80)}))
+
+	active := tablestore.NewSingleColumnCondition("status", tablestore.CT_EQUAL, "active")
+	active.FilterIfMissing = true
+	highScore := tablestore.NewSingleColumnCondition("score", tablestore.CT_GREATER_EQUAL, int64(90))
+	and := tablestore.NewCompositeColumnCondition(tablestore.LO_AND)
+	and.AddFilter(active)
+	and.AddFilter(highScore)
+	or := tablestore.NewCompositeColumnCondition(tablestore.LO_OR)
+	or.AddFilter(active)
+	or.AddFilter(highScore)
+
+	tests := []struct {
+		name   string
+		filter tablestore.ColumnFilter
+		found  bool
+	}{
+		{name: "SingleMatches", filter: active, found: true},
+		{name: "SingleExcludes", filter: highScore},
+		{name: "AndExcludes", filter: and},
+		{name: "OrMatches", filter: or, found: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast := assert.New(t)
+			obj := statusRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+			getErr := GetRow(ctx, &obj, GetRowParams{Filter: tt.filter})
+			pks := []KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}}
+			row, err := GetRowToMap(ctx, pks, GetRowParams{Filter: tt.filter})
+			cols, colsErr := GetRowMap(ctx, pks, GetRowParams{Filter: tt.filter})
+
+			if tt.found {
+				ast.NoError(getErr)
+				ast.NoError(err)
+				ast.NoError(colsErr)
+				ast.Len(cols, 2)
+				ast.Equal("active", tea.StringValue(obj.Status))
+				ast.Equal(int64(80), tea.Int64Value(obj.Score))
+				ast.Equal(map[string]any{"status": "active", "score": int64(80)}, row)
+			} else {
+				// 设置 Filter 时，被过滤的行与不存在的行一致：返回 ErrRowNotFound，字段保持 nil
+				ast.ErrorIs(getErr, ErrRowNotFound)
+				ast.ErrorIs(err, ErrRowNotFound)
+				ast.ErrorIs(colsErr, ErrRowNotFound)
+				ast.Nil(cols)
+				ast.Nil(obj.Status)
+				ast.Nil(obj.Score)
+				ast.Nil(row)
+			}
+		})
 	}
+}
 
-	goutils.InitZeroLog()
-	ctx := context.Background()
-	ctx = log.Logger.WithContext(ctx)
+func TestExistsRow(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	ast.NoError(PutRow(ctx, &RangeRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v")}))
 
-	client := NewClient(ctx, os.Getenv("endpoint"), os.Getenv("instanceName"), os.Getenv("ak"), os.Getenv("sk"))
+	exists, err := ExistsRow(ctx, &RangeRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)})
+	ast.NoError(err)
+	ast.True(exists)
+	exists, err = ExistsRow(ctx, &RangeRow{Pk1: tea.String("a"), Pk2: tea.Int64(2)})
+	ast.NoError(err)
+	ast.False(exists)
 
-	o := OtsUtilsParams{
-		Client:    client,
-		TableName: "test_table",
+	// 只读取第一个主键列，不读属性列
+	var criteria *tablestore.SingleRowQueryCriteria
+	fake.Intercept = func(operation string, request any) error {
+		if operation == "GetRow" {
+			criteria = request.(*tablestore.GetRowRequest).SingleRowQueryCriteria
+		}
+		return nil
 	}
-	ctx = o.WithContext(ctx)
+	_, err = ExistsRow(ctx, &RangeRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("ignored")})
+	ast.NoError(err)
+	ast.Equal([]string{"pk1"}, criteria.ColumnsToGet)
+	ast.Equal(int32(1), criteria.MaxVersion)
+	fake.Intercept = nil
+
+	_, err = ExistsRow(ctx, &RangeRow{Pk1: tea.String("a")})
+	ast.EqualError(err, "primary key field Pk2 of otsutils.RangeRow is nil")
+	_, err = ExistsRow(ctx, RangeRow{})
+	ast.EqualError(err, "obj must be a non-nil pointer to struct, got otsutils.RangeRow")
+	ast.Equal(3, fake.CallCount("GetRow"))
+}
+
+func TestMissingPrimaryKey(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	type threePk struct {
+		Pk1  *string `json:"pk1" pk:"1"`
+		Pk2  *int64  `json:"pk2" pk:"2"`
+		Pk3  *string `json:"pk3" pk:"3"`
+		Col1 *string `json:"col1"`
+	}
+	// 主键不完整时在发送请求前报错，列出缺失的列
+	row := &threePk{Pk1: tea.String("a"), Col1: tea.String("v")}
+	ast.EqualError(PutRow(ctx, row), "missing primary key field(s): pk2, pk3")
+	ast.EqualError(UpdateRow(ctx, row), "missing primary key field(s): pk2, pk3")
+	ast.EqualError(GetRow(ctx, row), "missing primary key field(s): pk2, pk3")
+	ast.EqualError(GetRow(ctx, &threePk{Pk2: tea.Int64(1), Pk3: tea.String("c")}), "missing primary key field(s): pk1")
+	ast.EqualError(DeleteRow(ctx, row), "missing primary key field(s): pk2, pk3")
+	ast.Equal(0, fake.CallCount("PutRow")+fake.CallCount("UpdateRow")+fake.CallCount("GetRow")+fake.CallCount("DeleteRow"))
+
+	// 自增列只有 PutRow 可以留空，由服务端分配
+	type autoPk struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk2 *int64  `json:"pk2" pk:"2,auto"`
+	}
+	ast.EqualError(UpdateRow(ctx, &autoPk{Pk1: tea.String("a")}), "missing primary key field(s): pk2")
+	ast.EqualError(DeleteRow(ctx, &autoPk{Pk1: tea.String("a")}), "missing primary key field(s): pk2")
+}
+
+func TestUpdateRow(t *testing.T) {
+	ctx := newIntegrationContext(t)
 
 	// 测试更新行
 	obj := TestRow{
@@ -195,24 +358,8 @@ func TestUpdateRow(t *testing.T) {
 }
 
 func TestIntegration(t *testing.T) {
-	// Skip test if no credentials
-	if os.Getenv("sk") == "" {
-		t.Skip("Skipping test: no credentials provided")
-	}
-
 	ast := assert.New(t)
-	goutils.InitZeroLog()
-
-	ctx := context.Background()
-	ctx = log.Logger.WithContext(ctx)
-
-	client := NewClient(ctx, os.Getenv("endpoint"), os.Getenv("instanceName"), os.Getenv("ak"), os.Getenv("sk"))
-
-	o := OtsUtilsParams{
-		Client:    client,
-		TableName: "test_table",
-	}
-	ctx = o.WithContext(ctx)
+	ctx := newIntegrationContext(t)
 
 	obj := TestRow{
 		Pk1:  tea.String("pk1"),
@@ -261,9 +408,19 @@ func TestIntegration(t *testing.T) {
 	ast.Equal(tea.String("updated_col3"), obj.Col3)
 }
 
+// parseObjRow 比 TestRow 多一个主键列 Pk3，只用于解析，不写入表
+type parseObjRow struct {
+	Pk1  *string `json:"pk1" pk:"1"`
+	Pk2  *int64  `json:"pk2" pk:"2"`
+	Pk3  *[]byte `json:"pk3" pk:"3"`
+	Col1 *string `json:"col1"`
+	Col2 *int64  `json:"col2"`
+	Col3 *string `json:"col3"`
+}
+
 func TestParseObj(t *testing.T) {
 	// 测试正常对象解析
-	obj := TestRow{
+	obj := parseObjRow{
 		Pk1:  tea.String("pk1"),
 		Pk2:  tea.Int64(1),
 		Col1: tea.String("col1"),
@@ -289,6 +446,18 @@ func TestParseObj(t *testing.T) {
 	if obj.Col3 != nil {
 		t.Error("Expected Col3 to be nil")
 	}
+
+	// ParseObj 跳过为 nil 的主键字段
+	pks, cols, err := ParseObj(context.Background(), &obj)
+	if err != nil {
+		t.Fatalf("ParseObj failed: %v", err)
+	}
+	if len(pks) != 2 || pks[0].Key != "pk1" || pks[1].Key != "pk2" {
+		t.Errorf("Expected pks pk1 and pk2, got %v", pks)
+	}
+	if len(cols) != 1 || cols[0].Key != "col1" {
+		t.Errorf("Expected cols col1, got %v", cols)
+	}
 }
 
 func TestToAnySlice(t *testing.T) {
@@ -325,3 +494,163 @@ func TestToAnySlice(t *testing.T) {
 		t.Errorf("Expected 3, got %v", result2[2])
 	}
 }
+
+func TestDeleteRow(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	obj := TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col1: tea.String("col1")}
+	ast.NoError(PutRow(ctx, &obj))
+
+	// 只使用主键字段定位行
+	ast.NoError(DeleteRow(ctx, &obj))
+
+	got := TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)}
+	ast.NoError(GetRow(ctx, &got))
+	ast.Nil(got.Col1)
+
+	// 删除不存在的行不报错
+	ast.NoError(DeleteRow(ctx, &obj))
+}
+
+func TestDeleteRowParams(t *testing.T) {
+	expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+	expectNotExist := tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST
+	statusDone := tablestore.NewSingleColumnCondition("col1", tablestore.CT_EQUAL, "done")
+	txn := "fake-txn-1"
+
+	tests := []struct {
+		name      string
+		exists    bool
+		params    DeleteRowParams
+		wantErr   string
+		wantCond  tablestore.RowExistenceExpectation
+		wantTxnId *string
+	}{
+		{name: "IgnoreMissing", wantCond: tablestore.RowExistenceExpectation_IGNORE},
+		{name: "IgnoreExisting", exists: true, wantCond: tablestore.RowExistenceExpectation_IGNORE},
+		{name: "ExpectExistExisting", exists: true, params: DeleteRowParams{RowExistenceExpectation: &expectExist}, wantCond: expectExist},
+		{name: "ExpectExistMissing", params: DeleteRowParams{RowExistenceExpectation: &expectExist}, wantErr: CodeConditionCheckFail, wantCond: expectExist},
+		{name: "ExpectNotExistExisting", exists: true, params: DeleteRowParams{RowExistenceExpectation: &expectNotExist}, wantErr: CodeConditionCheckFail, wantCond: expectNotExist},
+		{name: "ColumnConditionHolds", exists: true, params: DeleteRowParams{ColumnCondition: statusDone}, wantCond: tablestore.RowExistenceExpectation_IGNORE},
+		{name: "ColumnConditionFails", params: DeleteRowParams{ColumnCondition: tablestore.NewSingleColumnCondition("col1", tablestore.CT_EQUAL, "pending")}, exists: true, wantErr: CodeConditionCheckFail, wantCond: tablestore.RowExistenceExpectation_IGNORE},
+		{name: "ExpectExistAndColumnCondition", exists: true, params: DeleteRowParams{RowExistenceExpectation: &expectExist, ColumnCondition: statusDone}, wantCond: expectExist},
+		{name: "TransactionId", exists: true, params: DeleteRowParams{TransactionId: &txn}, wantCond: tablestore.RowExistenceExpectation_IGNORE, wantTxnId: &txn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast := assert.New(t)
+			ctx, fake := newFakeContext(t)
+
+			var req *tablestore.DeleteRowRequest
+			fake.Intercept = func(operation string, request any) error {
+				if r, ok := request.(*tablestore.DeleteRowRequest); ok {
+					req = r
+				}
+				return nil
+			}
+
+			obj := TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col1: tea.String("done")}
+			if tt.exists {
+				ast.NoError(PutRow(ctx, &obj))
+			}
+			if tt.params.TransactionId != nil {
+				// fake 只接受已开启的事务
+				_, err := BeginTransaction(ctx, &obj)
+				ast.NoError(err)
+			}
+
+			err := DeleteRow(ctx, &obj, tt.params)
+			if tt.wantErr != "" {
+				ast.Equal(tt.wantErr, Code(err))
+			} else {
+				ast.NoError(err)
+			}
+
+			if ast.NotNil(req) {
+				change := req.DeleteRowChange
+				ast.Equal(tt.wantCond, change.Condition.RowExistenceExpectation)
+				ast.Equal(tt.params.ColumnCondition, change.Condition.ColumnCondition)
+				ast.Equal(tt.wantTxnId, change.TransactionId)
+			}
+
+			// 条件失败时行保留
+			got := TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)}
+			ast.NoError(GetRow(ctx, &got))
+			ast.Equal(tt.exists && tt.wantErr != "", got.Col1 != nil)
+		})
+	}
+}
+
+func TestReadClient(t *testing.T) {
+	ast := assert.New(t)
+	ctx, primary := newFakeContext(t)
+
+	var buf bytes.Buffer
+	ctx = zerolog.New(&buf).Level(zerolog.DebugLevel).WithContext(ctx)
+
+	replica := otsfake.New()
+	replica.MustCreateTable("test_table",
+		"pk1", tablestore.PrimaryKeyType_STRING,
+		"pk2", tablestore.PrimaryKeyType_INTEGER,
+	)
+	OtsUtilsParamsFromCtx(ctx).ReadClient = replica
+
+	// 写操作始终使用主实例
+	obj := TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1), Col1: tea.String("col1")}
+	ast.NoError(PutRow(ctx, &obj))
+	ast.Equal(1, primary.CallCount("PutRow"))
+	ast.Equal(0, replica.CallCount("PutRow"))
+	ast.Contains(buf.String(), `"operation":"PutRow","client":"primary"`)
+
+	// 读操作使用只读实例
+	buf.Reset()
+	got := TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)}
+	ast.NoError(GetRow(ctx, &got))
+	ast.Nil(got.Col1)
+	ast.Equal(1, replica.CallCount("GetRow"))
+	ast.Contains(buf.String(), `"operation":"GetRow","client":"read"`)
+
+	var rows []RangeRow
+	ast.NoError(GetRange(ctx, &RangeRow{}, &RangeRow{}, &rows))
+	ast.Equal(1, replica.CallCount("GetRange"))
+	ast.Equal(0, primary.CallCount("GetRange"))
+
+	// 强制从主实例读取
+	ast.NoError(GetRow(ReadFromPrimary(ctx), &got))
+	ast.Equal("col1", *got.Col1)
+	ast.Equal(1, primary.CallCount("GetRow"))
+}
+
+func TestLoggerOverride(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	var ctxBuf, paramsBuf, callBuf bytes.Buffer
+	ctx = zerolog.New(&ctxBuf).Level(zerolog.DebugLevel).WithContext(ctx)
+	obj := TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)}
+
+	// 默认使用 context 中的 logger
+	_, _, line, _ := runtime.Caller(0)
+	ast.NoError(GetRow(ctx, &obj))
+	ast.Contains(ctxBuf.String(), fmt.Sprintf(`"caller":"otsutils_test.go:%d"`, line+1))
+
+	// OtsUtilsParams.Logger 优先于 context
+	paramsLogger := zerolog.New(&paramsBuf).Level(zerolog.DebugLevel)
+	OtsUtilsParamsFromCtx(ctx).Logger = &paramsLogger
+	ctxBuf.Reset()
+	_, _, line, _ = runtime.Caller(0)
+	ast.NoError(GetRow(ctx, &obj))
+	ast.Empty(ctxBuf.String())
+	ast.Contains(paramsBuf.String(), `"operation":"GetRow"`)
+	ast.Contains(paramsBuf.String(), fmt.Sprintf(`"caller":"otsutils_test.go:%d"`, line+1))
+
+	// WithLogger 优先于 OtsUtilsParams.Logger
+	callLogger := zerolog.New(&callBuf).Level(zerolog.DebugLevel)
+	paramsBuf.Reset()
+	_, _, line, _ = runtime.Caller(0)
+	ast.NoError(GetRow(WithLogger(ctx, &callLogger), &obj))
+	ast.Empty(paramsBuf.String())
+	ast.Contains(callBuf.String(), `"operation":"GetRow"`)
+	ast.Contains(callBuf.String(), fmt.Sprintf(`"caller":"otsutils_test.go:%d"`, line+1))
+}
@@ -0,0 +1,373 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// DefaultParallelScanWorkers is the number of splits ParallelScan reads at the same time when
+// ParallelScanOptions.Workers is not set.
+const DefaultParallelScanWorkers = 4
+
+// DefaultProgressInterval is the number of rows of a split between the Progress calls and
+// Checkpoint saves of ParallelScan when ParallelScanOptions.ProgressInterval is not set.
+const DefaultProgressInterval = 1000
+
+// ParallelScan reads the whole table, sending every row to out. It divides the table with
+// ComputeSplitPointsBySize and scans up to Workers splits concurrently, each one page after
+// page, so rows arrive in primary key order within a split but interleaved across splits.
+// T is a struct, a pointer to struct or map[string]any, as the elements of GetRange's out.
+//
+// ParallelScan closes out when it returns. The first failing worker cancels the others, and
+// the errors of all the workers are returned joined. Cancelling ctx stops the scan, also when
+// the consumer stops reading from out.
+//
+// With ParallelScanOptions.Checkpoint set, the splits and the progress of each are saved as the
+// scan goes, and a scan started with a checkpoint holding them reads the saved splits, skipping
+// the completed ones and resuming the others after their last saved row.
+//
+// Example usage:
+//
+//	rows := make(chan MyRow, 1000)
+//	errc := make(chan error, 1)
+//	go func() { errc <- ParallelScan(ctx, rows, ParallelScanOptions{Workers: 8}) }()
+//	for row := range rows {
+//	    export(row)
+//	}
+//	err := <-errc
+func ParallelScan[T any](ctx context.Context, out chan<- T, opts ParallelScanOptions) error {
+	defer close(out)
+
+	if opts.Workers < 0 {
+		return fmt.Errorf("Workers must not be negative, got %d", opts.Workers)
+	}
+	workers := opts.Workers
+	if workers == 0 {
+		workers = DefaultParallelScanWorkers
+	}
+	if opts.SplitSize < 0 {
+		return fmt.Errorf("SplitSize must not be negative, got %d", opts.SplitSize)
+	}
+	splitSize := opts.SplitSize
+	if splitSize == 0 {
+		splitSize = 1
+	}
+	if opts.ProgressInterval < 0 {
+		return fmt.Errorf("ProgressInterval must not be negative, got %d", opts.ProgressInterval)
+	}
+	interval := opts.ProgressInterval
+	if interval == 0 {
+		interval = DefaultProgressInterval
+	}
+	p := GetRangeParams{PageSize: opts.PageSize, ColumnsToGet: opts.ColumnsToGet}
+	if err := p.validate(); err != nil {
+		return err
+	}
+	_, elemType, err := outSlice(new([]T))
+	if err != nil {
+		return fmt.Errorf("ParallelScan needs a struct, pointer to struct or map[string]any type, got %s", reflect.TypeFor[T]())
+	}
+
+	splits, err := scanSplits(ctx, splitSize, opts.Checkpoint)
+	if err != nil {
+		return err
+	}
+
+	scanCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	scanCtx, job := startJob(scanCtx, "ParallelScan", opts.Listener)
+
+	var mu sync.Mutex
+	var errs []error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// Once cancelled, the other workers only report the cancellation
+		if scanCtx.Err() == nil || !errors.Is(err, scanCtx.Err()) {
+			errs = append(errs, err)
+		}
+		cancel()
+	}
+
+	// scanSplit sends the rows of split i to out, from its last saved row on
+	scanSplit := func(i int) error {
+		split := splits[i]
+		if split.Done {
+			return nil
+		}
+		endPK := boundPrimaryKey(split.Upper)
+		scan := &rangeScan{params: p, elemType: elemType, endPK: endPK}
+		start := split.Lower
+		if split.LastPK != nil {
+			start = split.LastPK
+		}
+		page := &rangePage{StartPrimaryKey: boundPrimaryKey(start), EndPrimaryKey: endPK, Limit: p.pageLimit(0)}
+
+		var rowsDone, rowsSaved int64
+		lastPK := split.LastPK
+		report := func(ctx context.Context, done bool) error {
+			rowsSaved = rowsDone
+			if opts.Progress != nil {
+				opts.Progress(i, rowsDone, lastPK)
+			}
+			if opts.Checkpoint != nil {
+				state := split
+				state.LastPK, state.Done = lastPK, done
+				if err := opts.Checkpoint.Save(ctx, i, state); err != nil {
+					return fmt.Errorf("save checkpoint of split %d: %w", i, err)
+				}
+				job.checkpoint(i, state)
+			}
+			return nil
+		}
+		// stopped saves the rows sent since the last save when the scan is cancelled, so that a
+		// resumed scan does not send them again
+		stopped := func() error {
+			if rowsDone > rowsSaved {
+				if err := report(context.WithoutCancel(scanCtx), false); err != nil {
+					return err
+				}
+			}
+			return scanCtx.Err()
+		}
+
+		// The scan of a resumed split starts at its last saved row, sent before
+		skip := split.LastPK
+		for page != nil {
+			started, sent := time.Now(), 0
+			var cu tablestore.ConsumedCapacityUnit
+			var rows []T
+			var pks [][]KeyValue
+			next := (*rangePage)(nil)
+			handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+				r := resp.(*tablestore.GetRangeResponse)
+				addCapacity(&cu, r.ConsumedCapacityUnit)
+				var err error
+				next, err = scan.decodePage(ctx, r, func(elem reflect.Value) {
+					rows = append(rows, elem.Interface().(T))
+					pks = append(pks, primaryKeyToKeyValues(r.Rows[len(rows)-1].PrimaryKey))
+				})
+				return err
+			}
+			if err := executeOTSOperation(scanCtx, "ParallelScan", page, buildGetRangeRequest, executeGetRange, handleResp, p); err != nil {
+				if scanCtx.Err() != nil {
+					return stopped()
+				}
+				return err
+			}
+
+			for j, row := range rows {
+				if skip != nil {
+					skipped := reflect.DeepEqual(pks[j], skip)
+					skip = nil
+					if skipped {
+						continue
+					}
+				}
+				select {
+				case out <- row:
+				case <-scanCtx.Done():
+					return stopped()
+				}
+				rowsDone, lastPK, sent = rowsDone+1, pks[j], sent+1
+				if rowsDone%interval == 0 {
+					if err := report(scanCtx, false); err != nil {
+						return err
+					}
+				}
+			}
+			job.chunk(i, sent, cu, started)
+			page = next
+		}
+		return report(scanCtx, true)
+	}
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(splits)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				if err := scanSplit(i); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range splits {
+		select {
+		case work <- i:
+		case <-scanCtx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	err = ctx.Err()
+	if len(errs) > 0 {
+		err = errors.Join(errs...)
+	}
+	job.finish(err)
+	return err
+}
+
+// ParallelScanner is a ParallelScan running in the background, started by StartParallelScan.
+type ParallelScanner[T any] struct {
+	ctx    context.Context
+	rows   chan T
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// StartParallelScan starts ParallelScan on goroutines of its own and returns at once. The rows
+// arrive on Rows, which is closed at the end of the scan. Wait returns the error of the scan
+// once every row was received, and Close stops it early; one of them must be called, or the
+// workers are left blocked.
+//
+// Example usage:
+//
+//	scanner := StartParallelScan[MyRow](ctx, ParallelScanOptions{Checkpoint: checkpoint})
+//	defer scanner.Close(ctx)
+//	for row := range scanner.Rows() {
+//	    if err := export(row); err != nil {
+//	        return err
+//	    }
+//	}
+//	return scanner.Wait()
+func StartParallelScan[T any](ctx context.Context, opts ParallelScanOptions) *ParallelScanner[T] {
+	scanCtx, cancel := context.WithCancel(ctx)
+	s := &ParallelScanner[T]{ctx: ctx, rows: make(chan T), cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		s.err = ParallelScan(scanCtx, s.rows, opts)
+	}()
+	return s
+}
+
+// Rows returns the channel the rows arrive on.
+func (s *ParallelScanner[T]) Rows() <-chan T {
+	return s.rows
+}
+
+// Wait waits for the end of the scan and returns its error, as ParallelScan. The rows must be
+// received meanwhile.
+func (s *ParallelScanner[T]) Wait() error {
+	<-s.done
+	s.cancel()
+	return s.err
+}
+
+// Close stops the scan, discarding the rows not received yet. Each worker saves the progress of
+// its split to ParallelScanOptions.Checkpoint before returning, so that a scan resumed from it
+// starts after the last row received. Close waits for the workers until ctx is done, returning
+// ctx.Err() then. Otherwise it returns the error of the scan, nil when it only stopped because of
+// Close. Close may be called more than once, and after Wait.
+func (s *ParallelScanner[T]) Close(ctx context.Context) error {
+	s.cancel()
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if errors.Is(s.err, context.Canceled) && s.ctx.Err() == nil {
+		return nil
+	}
+	return s.err
+}
+
+// scanSplits returns the splits of a ParallelScan: those saved in checkpoint when it holds any,
+// or else those of ComputeSplitPointsBySize, then saved in checkpoint before any row is read.
+func scanSplits(ctx context.Context, splitSize int64, checkpoint Checkpoint) ([]SplitCheckpoint, error) {
+	if checkpoint != nil {
+		saved, err := checkpoint.Load(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("load checkpoint: %w", err)
+		}
+		if len(saved) > 0 {
+			splits := make([]SplitCheckpoint, len(saved))
+			for i := range splits {
+				state, ok := saved[i]
+				if !ok {
+					return nil, fmt.Errorf("checkpoint holds %d splits but not split %d", len(saved), i)
+				}
+				splits[i] = state
+			}
+			return splits, nil
+		}
+	}
+
+	var splits []SplitCheckpoint
+	handleSplits := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		for _, split := range resp.(*tablestore.ComputeSplitPointsBySizeResponse).Splits {
+			splits = append(splits, SplitCheckpoint{Lower: boundKeyValues(split.LowerBound), Upper: boundKeyValues(split.UpperBound)})
+		}
+		logger.Debug().Int("splits", len(splits)).Msg("Table split")
+		return nil
+	}
+	if err := executeOTSOperation(ctx, "ParallelScan", splitSize, buildComputeSplitPointsRequest, executeComputeSplitPoints, handleSplits); err != nil {
+		return nil, err
+	}
+	if checkpoint != nil {
+		for i, split := range splits {
+			if err := checkpoint.Save(ctx, i, split); err != nil {
+				return nil, fmt.Errorf("save checkpoint of split %d: %w", i, err)
+			}
+		}
+	}
+	return splits, nil
+}
+
+// boundKeyValues converts a split boundary to key-value pairs, with tablestore.MIN or
+// tablestore.MAX as the value of the columns at the start or end of their range.
+func boundKeyValues(pk *tablestore.PrimaryKey) []KeyValue {
+	kvs := make([]KeyValue, len(pk.PrimaryKeys))
+	for i, col := range pk.PrimaryKeys {
+		kvs[i] = KeyValue{Key: col.ColumnName, Value: col.Value}
+		if col.PrimaryKeyOption == tablestore.MIN || col.PrimaryKeyOption == tablestore.MAX {
+			kvs[i].Value = col.PrimaryKeyOption
+		}
+	}
+	return kvs
+}
+
+// boundPrimaryKey converts key-value pairs from boundKeyValues back to a range boundary.
+func boundPrimaryKey(kvs []KeyValue) *tablestore.PrimaryKey {
+	pk := &tablestore.PrimaryKey{}
+	for _, kv := range kvs {
+		switch option, _ := kv.Value.(tablestore.PrimaryKeyOption); option {
+		case tablestore.MIN:
+			pk.AddPrimaryKeyColumnWithMinValue(kv.Key)
+		case tablestore.MAX:
+			pk.AddPrimaryKeyColumnWithMaxValue(kv.Key)
+		default:
+			pk.AddPrimaryKeyColumn(kv.Key, kv.Value)
+		}
+	}
+	return pk
+}
+
+func buildComputeSplitPointsRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	return &tablestore.ComputeSplitPointsBySizeRequest{TableName: otsParams.TableName, SplitSize: obj.(int64)}, nil
+}
+
+func executeComputeSplitPoints(client OtsClient, req any) (any, error) {
+	splitClient, err := clientAs[SplitClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return splitClient.ComputeSplitPointsBySize(req.(*tablestore.ComputeSplitPointsBySizeRequest))
+}
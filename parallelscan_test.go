@@ -0,0 +1,181 @@
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/117503445/otsutils/otsfake"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+// collectScan 运行 ParallelScan 并收集所有行
+func collectScan[T any](ctx context.Context, opts ParallelScanOptions) ([]T, error) {
+	out := make(chan T)
+	errc := make(chan error, 1)
+	go func() { errc <- ParallelScan(ctx, out, opts) }()
+	var rows []T
+	for row := range out {
+		rows = append(rows, row)
+	}
+	return rows, <-errc
+}
+
+func TestParallelScan(t *testing.T) {
+	t.Run("scans every split", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.SplitRows = 7
+		fake.MaxRangeRows = 3
+		for _, partition := range []string{"a", "b", "c"} {
+			putRangeRows(t, ctx, partition, 10)
+		}
+
+		rows, err := collectScan[RangeRow](ctx, ParallelScanOptions{Workers: 2})
+		ast.NoError(err)
+		ast.Len(rows, 30)
+		ast.Equal(1, fake.CallCount("ComputeSplitPointsBySize"))
+
+		// 每行恰好出现一次
+		keys := make([]string, len(rows))
+		for i, row := range rows {
+			keys[i] = fmt.Sprintf("%s-%02d", tea.StringValue(row.Pk1), tea.Int64Value(row.Pk2))
+		}
+		sort.Strings(keys)
+		ast.Equal("a-00", keys[0])
+		ast.Equal("c-09", keys[29])
+		for i := 1; i < len(keys); i++ {
+			ast.NotEqual(keys[i-1], keys[i])
+		}
+	})
+
+	t.Run("maps and columns to get", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.SplitRows = 2
+		putRangeRows(t, ctx, "a", 5)
+
+		rows, err := collectScan[map[string]any](ctx, ParallelScanOptions{ColumnsToGet: []string{"col1"}, PageSize: 1})
+		ast.NoError(err)
+		ast.Len(rows, 5)
+		ast.Equal("v", rows[0]["col1"])
+	})
+
+	t.Run("worker failure cancels the scan", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.SplitRows = 1
+		putRangeRows(t, ctx, "a", 20)
+
+		busy := &tablestore.OtsError{Code: CodeServerBusy, Message: "Server is busy."}
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "GetRange" && fake.CallCount("GetRange") == 3 {
+				return busy
+			}
+			return nil
+		}
+
+		rows, err := collectScan[*RangeRow](ctx, ParallelScanOptions{Workers: 2})
+		ast.ErrorIs(err, busy)
+		ast.Less(len(rows), 20)
+	})
+
+	t.Run("progress is reported at the row interval", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.SplitRows = 10
+		putRangeRows(t, ctx, "a", 25)
+
+		var mu sync.Mutex
+		progress := make(map[int][]string)
+		_, err := collectScan[RangeRow](ctx, ParallelScanOptions{PageSize: 3, ProgressInterval: 4, Progress: func(split int, rowsDone int64, lastPK []KeyValue) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress[split] = append(progress[split], fmt.Sprintf("%d@%d", rowsDone, lastPK[1].Value))
+		}})
+		ast.NoError(err)
+
+		// 每 4 行报告一次，分片结束时再报告一次
+		ast.Equal(map[int][]string{
+			0: {"4@3", "8@7", "10@9"},
+			1: {"4@13", "8@17", "10@19"},
+			2: {"4@23", "5@24"},
+		}, progress)
+	})
+
+	for _, checkpoint := range []struct {
+		name string
+		new  func(t *testing.T, ctx context.Context) Checkpoint
+	}{
+		{"file checkpoint", func(t *testing.T, ctx context.Context) Checkpoint {
+			return &FileCheckpoint{Path: filepath.Join(t.TempDir(), "scan.json")}
+		}},
+		{"table checkpoint", func(t *testing.T, ctx context.Context) Checkpoint {
+			fake := OtsUtilsParamsFromCtx(ctx).Client.(*otsfake.Client)
+			fake.MustCreateTable("checkpoints", "job", tablestore.PrimaryKeyType_STRING, "split", tablestore.PrimaryKeyType_INTEGER)
+			return &TableCheckpoint{Params: &OtsUtilsParams{Client: fake, TableName: "checkpoints"}, Job: "export"}
+		}},
+	} {
+		t.Run(checkpoint.name+" resumes after a crash", func(t *testing.T) {
+			ast := assert.New(t)
+			ctx, fake := newFakeContext(t)
+			fake.SplitRows = 10
+			putRangeRows(t, ctx, "a", 25)
+			opts := ParallelScanOptions{Workers: 1, PageSize: 5, ProgressInterval: 3, Checkpoint: checkpoint.new(t, ctx)}
+
+			// 第一次扫描在读取第二个分片的 a-15 时崩溃
+			crash := errors.New("crash")
+			fake.Intercept = func(operation string, request any) error {
+				if req, ok := request.(*tablestore.GetRangeRequest); ok && req.RangeRowQueryCriteria.TableName == "test_table" {
+					if req.RangeRowQueryCriteria.StartPrimaryKey.PrimaryKeys[1].Value == int64(15) {
+						fake.Intercept = nil
+						return crash
+					}
+				}
+				return nil
+			}
+			first, err := collectScan[RangeRow](ctx, opts)
+			ast.ErrorIs(err, crash)
+			ast.Len(first, 15)
+
+			// 恢复时沿用保存的分片，不重新计算
+			second, err := collectScan[RangeRow](ctx, opts)
+			ast.NoError(err)
+			ast.Equal(1, fake.CallCount("ComputeSplitPointsBySize"))
+
+			// 已完成的分片不再读取，第二个分片从最后保存的 a-12 之后继续
+			seen := make(map[string]int)
+			for _, row := range append(first, second...) {
+				seen[fmt.Sprintf("%s-%02d", tea.StringValue(row.Pk1), tea.Int64Value(row.Pk2))]++
+			}
+			ast.Len(seen, 25)
+			ast.Equal(2, seen["a-13"])
+			ast.Equal(2, seen["a-14"])
+			ast.Equal(1, seen["a-12"])
+			ast.Len(second, 7+5)
+
+			// 全部分片完成后再次扫描不读取任何行
+			third, err := collectScan[RangeRow](ctx, opts)
+			ast.NoError(err)
+			ast.Empty(third)
+		})
+	}
+
+	t.Run("invalid options", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+
+		_, err := collectScan[RangeRow](ctx, ParallelScanOptions{Workers: -1})
+		ast.EqualError(err, "Workers must not be negative, got -1")
+		_, err = collectScan[RangeRow](ctx, ParallelScanOptions{ProgressInterval: -1})
+		ast.EqualError(err, "ProgressInterval must not be negative, got -1")
+		_, err = collectScan[string](ctx, ParallelScanOptions{})
+		ast.EqualError(err, "ParallelScan needs a struct, pointer to struct or map[string]any type, got string")
+	})
+}
@@ -1,7 +1,12 @@
 // Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
 package otsutils
 
-import "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+import (
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+)
 
 // KeyValue represents a key-value pair.
 type KeyValue struct {
@@ -12,21 +17,397 @@ type KeyValue struct {
 // PutRowParams contains parameters for the PutRow operation.
 type PutRowParams struct {
 	// RowExistenceExpectation specifies the row existence expectation for the operation.
+	// PutRowSwap ignores it and sets its own condition.
 	RowExistenceExpectation *tablestore.RowExistenceExpectation
+
+	// ColumnCondition, when set, puts the row only if the condition on the columns of the row
+	// already stored holds, e.g. a *tablestore.SingleColumnCondition. A failed condition is
+	// reported by IsConditionCheckFail. PutRowSwap ignores it and sets its own condition.
+	ColumnCondition tablestore.ColumnFilter
+
+	// SwapVersionColumn names the attribute column PutRowSwap compares to detect concurrent
+	// writes, such as a version counter or update timestamp. When empty, or when the row read
+	// has no such column, every attribute column read must be unchanged.
+	SwapVersionColumn string
+
+	// SwapAttempts bounds the read-then-put rounds of PutRowSwap. Defaults to DefaultSwapAttempts.
+	SwapAttempts int
+
+	// TransactionId runs the put inside a local transaction, see BeginTransaction.
+	TransactionId *string
 }
 
 // GetRowParams contains parameters for the GetRow operation.
 type GetRowParams struct {
+	// MaxVersion is the number of versions read per column. Defaults to OtsUtilsParams.DefaultMaxVersion,
+	// or 1 when that is not set either.
+	// Struct and map reads keep the newest version; GetRowVersionsToMap returns all of them.
+	MaxVersion int32
+
+	// TimeRange restricts the versions read to those written at a Specific timestamp, or from
+	// Start (inclusive) to End (exclusive), in milliseconds. MaxVersion then caps the versions read
+	// within the range; when neither it nor OtsUtilsParams.DefaultMaxVersion is set, every version
+	// in the range is read.
+	TimeRange *tablestore.TimeRange
+
+	// Filter, when set, returns the row only if the condition on its columns holds, e.g. a
+	// *tablestore.SingleColumnCondition or a *tablestore.CompositeColumnValueFilter combining
+	// several with AND, OR or NOT. A row the filter excludes reads as a missing row, reported
+	// with ErrRowNotFound, see GetRow.
+	Filter tablestore.ColumnFilter
+
+	// IncludePrimaryKey adds the primary key columns to the result of GetRowToMap and
+	// GetRowVersionsToMap.
+	IncludePrimaryKey bool
+
+	// LenientNumbers lets GetRow assign DOUBLE values without a fractional part to *int64 fields,
+	// for rows whose writer changed the column type. int and int32 values are always accepted.
+	LenientNumbers bool
+
+	// StrictColumns makes GetRow fail, leaving obj unchanged, when the row holds columns that
+	// no field of obj maps to; the error lists them. By default such columns are ignored.
+	StrictColumns bool
+
+	// TransactionId reads the row inside a local transaction, seeing its uncommitted writes.
+	TransactionId *string
+}
+
+// BatchGetRowParams contains parameters for the BatchGetRows and BatchGetTables operations.
+type BatchGetRowParams struct {
+	// MaxVersion is the number of versions read per column, as in GetRowParams.
+	// The newest version is assigned to the struct fields.
+	MaxVersion int32
+
+	// ColumnsToGet restricts the attribute columns read. Defaults to the columns of the element
+	// struct, or to every column when it has an extra field. As in the service, a row holding
+	// none of the columns reads as missing.
+	ColumnsToGet []string
+
+	// Filter, when set, returns a row only if the condition on its columns holds, as in
+	// GetRowParams. A row the filter excludes reports ErrRowNotFound.
+	Filter tablestore.ColumnFilter
+
+	// LenientNumbers lets BatchGetRows assign DOUBLE values without a fractional part to
+	// *int64 fields, as in GetRowParams.
+	LenientNumbers bool
+}
+
+// BatchWriteParams contains parameters for the BatchPutRows and BatchWrite operations.
+type BatchWriteParams struct {
+	// RowExistenceExpectation applies to every row of a BatchPutRows. Defaults to
+	// EXPECT_NOT_EXIST, as in PutRow. BatchWrite ignores it in favor of the Params of each op.
+	RowExistenceExpectation *tablestore.RowExistenceExpectation
+
+	// Atomic applies the batch all or nothing. Every row must share the value of the first
+	// primary key column, the partition key, and the batch must fit in a single request of at
+	// most MaxBatchWriteRows rows and MaxRequestSize bytes: it is never split, and a batch
+	// breaking either rule fails without sending anything.
+	Atomic bool
+
+	// Retries bounds the consecutive attempts of a request that apply none of its rows because
+	// the service throttled them. Only the throttled rows are sent again; rows failing for
+	// another reason, e.g. a failed condition, report their error at once. Defaults to
+	// DefaultBatchWriteRetries.
+	Retries int
+
+	// RetryBackoff is the pause before the throttled rows are sent again, doubled after each
+	// attempt that applied no row. Defaults to DefaultBatchWriteBackoff.
+	RetryBackoff time.Duration
 }
 
 // UpdateRowParams contains parameters for the UpdateRow operation.
 type UpdateRowParams struct {
 	// RowExistenceExpectation specifies the row existence expectation for the operation.
 	RowExistenceExpectation *tablestore.RowExistenceExpectation
-	
+
+	// ColumnCondition, when set, updates the row only if the condition on its columns holds,
+	// e.g. a *tablestore.SingleColumnCondition checking a version column or a
+	// *tablestore.CompositeColumnValueFilter. A failed condition is reported by IsConditionCheckFail.
+	ColumnCondition tablestore.ColumnFilter
+
 	// DeletedColumns is a list of column names to delete.
 	DeletedColumns []string
-	
+
 	// UpdatedColumns is a map of column names to values to update or add.
 	UpdatedColumns map[string]any
-}
\ No newline at end of file
+
+	// TransactionId runs the update inside a local transaction, see BeginTransaction.
+	TransactionId *string
+}
+
+// DeleteRowParams contains parameters for the DeleteRow operation.
+type DeleteRowParams struct {
+	// RowExistenceExpectation specifies the row existence expectation for the operation.
+	// Defaults to IGNORE. Under EXPECT_EXIST, deleting a missing row fails with
+	// CodeConditionCheckFail, which callers may treat as already deleted.
+	RowExistenceExpectation *tablestore.RowExistenceExpectation
+
+	// ColumnCondition, when set, deletes the row only if the condition on its columns holds,
+	// e.g. a *tablestore.SingleColumnCondition or *tablestore.CompositeColumnValueFilter.
+	ColumnCondition tablestore.ColumnFilter
+
+	// TransactionId runs the delete inside a local transaction, see BeginTransaction.
+	TransactionId *string
+}
+
+// GetRangeParams contains parameters for the GetRange operation.
+//
+// PageSize and MaxRows are independent: PageSize only changes how many requests a scan takes,
+// while MaxRows changes how many rows it returns. The Limit of the underlying SDK request is
+// per page, so setting PageSize to 100 still returns every row of the range.
+type GetRangeParams struct {
+	// PageSize is the maximum number of rows read by each GetRange request.
+	// Zero leaves it to the service, which returns at most 5000 rows or 4MB per page.
+	PageSize int32
+
+	// MaxRows is the maximum number of rows returned across all pages. The scan stops as soon as
+	// it is reached, even in the middle of a page, and the last page only requests the rows still
+	// needed. Zero means no limit.
+	MaxRows int64
+
+	// Direction is the scan order. Defaults to tablestore.FORWARD. With tablestore.BACKWARD,
+	// start is the upper boundary and end the lower one, so the pk fields left nil become INF_MAX
+	// on start and INF_MIN on end.
+	Direction tablestore.Direction
+
+	// ColumnsToGet restricts the attribute columns read; the primary key is always returned.
+	// Like the service, the scan skips rows that have none of these columns. Empty reads all columns.
+	ColumnsToGet []string
+
+	// FetchBaseRow makes GetRowsByIndex read every row listed by the index back from the table,
+	// instead of decoding the rows of the index, which only hold its primary key and the
+	// columns it covers. Only GetRowsByIndex uses it.
+	FetchBaseRow bool
+
+	// FetchWorkers is the number of BatchGetRow requests FetchBaseRow sends at the same time.
+	// Defaults to DefaultFetchBaseRowWorkers.
+	FetchWorkers int
+}
+
+// DeleteRangeParams contains parameters for the DeleteRange operation.
+type DeleteRangeParams struct {
+	// DryRun counts the rows of the range without deleting them.
+	DryRun bool
+
+	// MaxRows is the maximum number of rows deleted, or counted under DryRun. Zero means no limit.
+	MaxRows int
+
+	// Retries bounds the consecutive passes over the range that delete no row because the
+	// service throttled every batch. Defaults to DefaultBatchWriteRetries.
+	Retries int
+
+	// RetryBackoff is the pause before a new pass over the range, doubled after each pass that
+	// deleted no row. Defaults to DefaultBatchWriteBackoff.
+	RetryBackoff time.Duration
+
+	// Listener receives the lifecycle events of the deletion, see JobListener. Defaults to a
+	// LogJobListener on the logger of the context.
+	Listener JobListener
+}
+
+// CopyOptions contains parameters for the CopyTable operation.
+type CopyOptions struct {
+	// Start and End restrict the copy to the rows from Start (inclusive) to End (exclusive),
+	// named by a prefix of the primary key as the boundaries of GetRange into maps. Nil copies
+	// from the first row, or up to the last one.
+	Start, End *PrimaryKeyBuilder
+
+	// Columns restricts the attribute columns copied. Like GetRangeParams.ColumnsToGet, rows
+	// that have none of them are skipped. Empty copies every column.
+	Columns []string
+
+	// Workers is the number of BatchWriteRow requests sent to the destination at the same time.
+	// Defaults to DefaultCopyWorkers.
+	Workers int
+
+	// RowsPerSecond caps the rate at which rows are written to the destination, averaged over
+	// batches of at most a second of rows. Zero means no limit.
+	RowsPerSecond int
+
+	// Retries and RetryBackoff apply to the batches the destination throttles, as in
+	// TruncateTableParams.
+	Retries      int
+	RetryBackoff time.Duration
+
+	// Listener receives the lifecycle events of the copy, see JobListener. Defaults to a
+	// LogJobListener on the logger of the context.
+	Listener JobListener
+}
+
+// RMWOptions contains parameters for the ReadModifyWrite operation.
+type RMWOptions struct {
+	// VersionColumn names the attribute column compared to detect concurrent writes, as
+	// PutRowParams.SwapVersionColumn. modify should then change it, e.g. increment it. When
+	// empty, or when the row read has no such column, every attribute column read must be
+	// unchanged.
+	VersionColumn string
+
+	// MaxAttempts bounds the read-modify-write rounds. Defaults to DefaultRMWAttempts.
+	MaxAttempts int
+
+	// Backoff is the base pause before a new round, doubled after each conflict and jittered by
+	// up to half its value either way. Defaults to DefaultRMWBackoff.
+	Backoff time.Duration
+}
+
+// SequenceOptions contains parameters for the NewSequence operation.
+type SequenceOptions struct {
+	// KeyColumn is the primary key column of the counter table, holding the sequence name.
+	// Defaults to DefaultSequenceKeyColumn.
+	KeyColumn string
+
+	// ValueColumn is the INTEGER attribute column holding the last value reserved.
+	// Defaults to DefaultSequenceValueColumn.
+	ValueColumn string
+
+	// Step is the difference between consecutive values. Defaults to 1.
+	Step int64
+
+	// BlockSize is the number of values reserved by each write and then handed out from memory.
+	// Values above 1 cut the writes by as much, at the cost of the values left unused when the
+	// process exits. Defaults to 1.
+	BlockSize int64
+}
+
+// TruncateTableParams contains parameters for the TruncateTable operation.
+type TruncateTableParams struct {
+	// TableName, when set, must be the table of OtsUtilsParams, or TruncateTable fails before
+	// reading any row. Setting it guards against truncating the table of the wrong context.
+	TableName string
+
+	// Workers is the number of BatchWriteRow requests sent at the same time. Defaults to
+	// DefaultTruncateWorkers.
+	Workers int
+
+	// Retries bounds the consecutive attempts of a batch that delete none of its rows because
+	// the service throttled them. Defaults to DefaultBatchWriteRetries.
+	Retries int
+
+	// RetryBackoff is the pause before a batch is sent again, doubled after each attempt that
+	// deleted no row. Defaults to DefaultBatchWriteBackoff.
+	RetryBackoff time.Duration
+
+	// Listener receives the lifecycle events of the truncation, see JobListener. Defaults to a
+	// LogJobListener on the logger of the context.
+	Listener JobListener
+}
+
+// ParallelScanOptions contains parameters for the ParallelScan operation.
+type ParallelScanOptions struct {
+	// Workers is the number of splits scanned at the same time. Defaults to DefaultParallelScanWorkers.
+	Workers int
+
+	// SplitSize is the approximate size of each split, in the 100MB units of
+	// ComputeSplitPointsBySize. Defaults to 1.
+	SplitSize int64
+
+	// PageSize is the maximum number of rows read by each GetRange request, as in GetRangeParams.
+	PageSize int32
+
+	// ColumnsToGet restricts the attribute columns read, as in GetRangeParams.
+	ColumnsToGet []string
+
+	// Progress, when set, is called by the worker of a split every ProgressInterval rows it
+	// sent to out, once the split is done and when the scan is cancelled, with the rows of the
+	// split sent since the scan started and the primary key of the last one. Calls for
+	// different splits may run concurrently.
+	Progress func(split int, rowsDone int64, lastPK []KeyValue)
+
+	// ProgressInterval is the number of rows of a split between Progress calls and Checkpoint
+	// saves. Defaults to DefaultProgressInterval.
+	ProgressInterval int64
+
+	// Checkpoint, when set, is loaded when the scan starts and saved as it goes, at the same
+	// points as Progress, so that a restarted scan resumes each split after the last row saved.
+	// A cancelled scan saves the rows each split sent since its last save before returning; the
+	// rows sent after the last save of a split that failed are sent again by the resumed scan.
+	Checkpoint Checkpoint
+
+	// Listener receives the lifecycle events of the scan, see JobListener. Defaults to a
+	// LogJobListener on the logger of the context.
+	Listener JobListener
+}
+
+// SQLQueryParams contains parameters for the QuerySQL operation.
+type SQLQueryParams struct {
+	// Strict fails the query when the result set has a column that no struct field maps to.
+	// By default such columns are ignored, as ParseResult does.
+	Strict bool
+}
+
+// SearchParams contains parameters for the Search operation.
+type SearchParams struct {
+	// Limit is the maximum number of rows returned. Zero leaves it to the service, which returns
+	// 10 rows by default and at most 100.
+	Limit int32
+
+	// Offset skips the first rows of the result. It can not be combined with Token.
+	Offset int32
+
+	// Token continues a previous search from its SearchResult.NextToken.
+	Token []byte
+
+	// Sort orders the rows. Nil sorts them by primary key. It is not sent with a Token, which
+	// carries the sort of the search it continues, so the same params can be reused from page to
+	// page.
+	Sort []search.Sorter
+
+	// ColumnsToGet restricts the attribute columns returned. Empty returns the columns of the out
+	// struct, or every column when reading into maps.
+	ColumnsToGet []string
+
+	// ReturnAll returns every attribute column of the rows.
+	ReturnAll bool
+
+	// Aggregations are computed over every row matching the query, not only the returned ones,
+	// and reported in SearchResult.Aggs by name.
+	Aggregations []search.Aggregation
+
+	// GroupBys split the rows matching the query into groups, reported in SearchResult.Groups
+	// by name.
+	GroupBys []search.GroupBy
+
+	// MaxRows is the maximum number of rows SearchAll passes to its callback. Zero means no
+	// limit. Only SearchAll uses it.
+	MaxRows int64
+}
+
+// CreateTableOptions contains parameters for the CreateTableFromStruct operation.
+type CreateTableOptions struct {
+	// TableName is the table created. Defaults to OtsUtilsParams.TableName.
+	TableName string
+
+	// TimeToLive is the data TTL in seconds, as in TableDescription. Zero or -1 keeps data forever.
+	TimeToLive int
+
+	// MaxVersions is the number of versions kept per column. Defaults to 1.
+	MaxVersions int
+
+	// ReservedRead and ReservedWrite are the reserved read and write capacity units of the table.
+	ReservedRead  int
+	ReservedWrite int
+}
+
+// UpdateTableOptions contains parameters for the UpdateTable operation. Zero fields leave the
+// current setting unchanged.
+type UpdateTableOptions struct {
+	// TimeToLive is the data TTL in seconds, -1 to keep data forever.
+	TimeToLive int
+
+	// MaxVersions is the number of versions kept per column.
+	MaxVersions int
+
+	// ReservedRead and ReservedWrite are the reserved read and write capacity units. Since zero
+	// leaves them unchanged, UpdateTable can not lower them to zero.
+	ReservedRead  int
+	ReservedWrite int
+}
+
+// PingParams contains parameters for the Ping operation.
+type PingParams struct {
+	// Client is pinged with ListTable when set, instead of the client stored in the context.
+	Client OtsClient
+
+	// Timeout bounds the call. Defaults to DefaultPingTimeout.
+	Timeout time.Duration
+}
@@ -4,10 +4,13 @@ package otsutils
 import (
 	"context"
 	"fmt"
+	"maps"
+	"math"
 	"reflect"
-	"sort"
+	"slices"
 	"strings"
 
+	"github.com/117503445/otsutils/internal/rowrules"
 	"github.com/rs/zerolog/log"
 )
 
@@ -19,207 +22,295 @@ func ParseObj(ctx context.Context, obj any) (pks []KeyValue, cols []KeyValue, er
 	cols = make([]KeyValue, 0)
 
 	v := reflect.ValueOf(obj)
-	if v.Kind() != reflect.Ptr {
-		return nil, nil, fmt.Errorf("obj must be a pointer")
-	}
-
-	t := reflect.TypeOf(obj)
-
-	// If it's a pointer, dereference it
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
-		t = t.Elem()
-	}
-
-	// Ensure it's a struct
-	if v.Kind() != reflect.Struct {
-		return nil, nil, fmt.Errorf("obj must be a struct or pointer to struct")
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("obj must be a non-nil pointer to struct, got %T", obj)
 	}
+	v = v.Elem()
+	t := v.Type()
 
-	// Collect primary key fields to sort them by pk tag value
-	type pkField struct {
-		jsonTag string
-		pkTag   string
-		value   any
+	meta, err := getStructMeta(t)
+	if err != nil {
+		return nil, nil, err
 	}
-	var pkFields []pkField
 
-	// Iterate through all fields
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		fieldType := t.Field(i)
-
-		isValidPointerType := func(t reflect.Type) bool {
-			// Must be a pointer
-			if t.Kind() != reflect.Ptr {
-				return false
-			}
-			// The pointed-to type must be string, int64, or []byte
-			elem := t.Elem()
-			switch elem.Kind() {
-			case reflect.String:
-				return true
-			case reflect.Int64:
-				return true
-			case reflect.Slice:
-				return elem.Elem().Kind() == reflect.Uint8 // []byte is []uint8
-			default:
-				return false
-			}
-		}
-		// Check if field type is valid
-		if !isValidPointerType(field.Type()) {
-			return nil, nil, fmt.Errorf("field %s has invalid type: %s. Only *string, *int64, and *[]byte are allowed", fieldType.Name, field.Type())
+	// Attribute columns in the configured column order
+	for _, i := range meta.attrFields {
+		fm := meta.fields[i]
+		value, skip, err := fm.value(fm.field(v))
+		if err != nil {
+			return nil, nil, err
 		}
-
-		// If it's a pointer and is nil, skip
-		if field.IsNil() {
+		if skip {
 			continue // Note: continue here, not participating in PutRow
 		}
+		cols = append(cols, KeyValue{Key: fm.column, Value: value})
+	}
 
-		jsonTag := fieldType.Tag.Get("json")
-		pkTag := fieldType.Tag.Get("pk")
-
-		// logger.Debug().Str("jsonTag", jsonTag).Str("pkTag", pkTag).Send()
-
-		value := field.Elem().Interface()
-
-		// Check if it's a primary key
-		isPk := pkTag != ""
-
-		// Add to corresponding place based on whether it's a primary key
-		// if isPk {
-		// 	putPk.AddPrimaryKeyColumn(jsonTag, value)
-		// } else {
-		// 	putRowChange.AddColumn(jsonTag, value)
-		// }
-		if isPk {
-			pkFields = append(pkFields, pkField{jsonTag: jsonTag, pkTag: pkTag, value: value})
-		} else {
-			cols = append(cols, KeyValue{Key: jsonTag, Value: value})
+	// Columns of the extra field, sorted by name since map iteration order is random
+	if extraField := fieldByIndex(v, meta.extra, false); meta.extra != nil && extraField.IsValid() {
+		extra := extraField.Interface().(map[string]any)
+		for _, column := range slices.Sorted(maps.Keys(extra)) {
+			value, err := extraColumnValue(meta, column, extra[column])
+			if err != nil {
+				return nil, nil, err
+			}
+			cols = append(cols, KeyValue{Key: column, Value: value})
 		}
 	}
 
-	// Sort primary key fields by pk tag value in ascending order
-	sort.Slice(pkFields, func(i, j int) bool {
-		return pkFields[i].pkTag < pkFields[j].pkTag
-	})
-
-	// Add sorted primary key fields to the pks array
-	for _, pkField := range pkFields {
-		pks = append(pks, KeyValue{Key: pkField.jsonTag, Value: pkField.value})
+	// Primary key columns in pk order
+	for _, i := range meta.pkFields {
+		fm := meta.fields[i]
+		value, skip, err := fm.value(fm.field(v))
+		if err != nil {
+			return nil, nil, err
+		}
+		if skip {
+			continue
+		}
+		pks = append(pks, KeyValue{Key: fm.column, Value: value})
 	}
 
 	return pks, cols, nil
 }
 
 func ParseResult(ctx context.Context, obj any, pks []KeyValue, cols []KeyValue) error {
+	return parseResult(ctx, obj, pks, cols, parseResultOptions{})
+}
+
+// parseResultOptions holds the options of GetRowParams that change how a row is assigned.
+type parseResultOptions struct {
+	// lenientNumbers also assigns float64 values without a fractional part to int64 fields
+	lenientNumbers bool
+	// strictColumns rejects rows holding columns that no field maps to
+	strictColumns bool
+}
+
+// parseResult is ParseResult with the options of GetRowParams.
+func parseResult(ctx context.Context, obj any, pks []KeyValue, cols []KeyValue, opts parseResultOptions) error {
 	logger := log.Ctx(ctx)
 	logger.Debug().Discard().Interface("obj", obj).Interface("pks", pks).Interface("cols", cols).Send()
 
 	v := reflect.ValueOf(obj)
-	if v.Kind() != reflect.Ptr || v.IsNil() {
-		return fmt.Errorf("parseResult: obj must be a non-nil pointer to struct, got %T", obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("obj must be a non-nil pointer to struct, got %T", obj)
 	}
 	v = v.Elem()
 	t := v.Type()
 
-	if v.Kind() != reflect.Struct {
-		return fmt.Errorf("parseResult: obj must be a pointer to struct, got %s", t.Name())
-	}
-
 	// Internal function: type mismatch error
-	typeMismatchError := func(fieldType, value any) error {
-		return fmt.Errorf("expected %v, but got %T", fieldType, value)
+	typeMismatchError := func(field reflect.Value, value any) error {
+		return fmt.Errorf("cannot assign %T value to field of type %s", value, field.Type())
 	}
 
-	// Internal function: assign to pointer field
-	assignToPointerField := func(field reflect.Value, value any) error {
-		if field.Kind() != reflect.Ptr {
-			return fmt.Errorf("field is not a pointer, got %s", field.Kind())
+	// Internal function: assign to a native field, or to a new value a native pointer field points to
+	assignNativeField := func(field reflect.Value, value any) error {
+		target := field
+		if field.Kind() == reflect.Ptr {
+			target = reflect.New(field.Type().Elem()).Elem()
 		}
 
-		elemType := field.Type().Elem()
-
-		switch elemType.Kind() {
+		switch target.Kind() {
 		case reflect.String:
 			if v, ok := value.(string); ok {
-				newVal := reflect.New(elemType)
-				newVal.Elem().SetString(v)
-				field.Set(newVal)
+				target.SetString(v)
 			} else {
-				return typeMismatchError("string", value)
+				return typeMismatchError(field, value)
 			}
 
 		case reflect.Int64:
-			if v, ok := value.(int64); ok {
-				newVal := reflect.New(elemType)
-				newVal.Elem().SetInt(v)
-				field.Set(newVal)
+			if v, ok := toInt64(value, opts.lenientNumbers); ok {
+				target.SetInt(v)
+			} else {
+				return typeMismatchError(field, value)
+			}
+
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+			v, ok := toInt64(value, opts.lenientNumbers)
+			if !ok {
+				return typeMismatchError(field, value)
+			}
+			if target.OverflowInt(v) {
+				return fmt.Errorf("value %d overflows field of type %s", v, field.Type())
+			}
+			target.SetInt(v)
+
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+			v, ok := toInt64(value, opts.lenientNumbers)
+			if !ok {
+				return typeMismatchError(field, value)
+			}
+			if v < 0 || target.OverflowUint(uint64(v)) {
+				return fmt.Errorf("value %d overflows field of type %s", v, field.Type())
+			}
+			target.SetUint(uint64(v))
+
+		case reflect.Float64:
+			if v, ok := value.(float64); ok {
+				target.SetFloat(v)
 			} else {
-				return typeMismatchError("int64", value)
+				return typeMismatchError(field, value)
 			}
 
 		case reflect.Slice:
-			if elemType.Elem().Kind() == reflect.Uint8 { // []byte
+			if target.Type().Elem().Kind() == reflect.Uint8 { // []byte
 				if v, ok := value.([]byte); ok {
-					newVal := reflect.New(elemType)
-					newVal.Elem().SetBytes(v)
-					field.Set(newVal)
+					target.SetBytes(v)
 				} else {
-					return typeMismatchError("[]byte", value)
+					return typeMismatchError(field, value)
 				}
 			} else {
-				return fmt.Errorf("unsupported slice element type: %s", elemType)
+				return fmt.Errorf("unsupported slice element type: %s", target.Type().Elem())
 			}
 
 		default:
-			return fmt.Errorf("unsupported field type: %s", elemType.Kind())
+			return fmt.Errorf("unsupported field type: %s", target.Kind())
 		}
 
+		if field.Kind() == reflect.Ptr {
+			field.Set(target.Addr())
+		}
 		return nil
 	}
 
-	// Build json tag to field mapping
-	fieldMap := make(map[string]reflect.Value)
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		ft := t.Field(i)
-
-		if !field.CanSet() {
-			continue
+	// Internal function: assign using the otstype tag or the OTSUnmarshaler of the field
+	// first, then the native kinds, then the serializer registry
+	assignField := func(fm *fieldMeta, value any) error {
+		field := fm.settableField(v)
+		switch {
+		case fm.otsType == rowrules.OtsTypeJSON:
+			return fm.decodeJSON(field, value)
+		case fm.marshaler:
+			return fm.unmarshalOTS(field, value)
+		case fm.sqlNull:
+			return assignSQLNull(field, value, assignNativeField)
+		case fm.uuid:
+			return fm.decodeUUID(field, value)
+		case fm.null:
+			n := asNullField(field)
+			if err := assignNativeField(n.nullValue(), value); err != nil {
+				return err
+			}
+			n.setNullState(nullValid)
+			return nil
 		}
-
-		jsonTag := ft.Tag.Get("json")
-		if jsonTag == "" {
-			continue
+		if !isNativeFieldType(field.Type()) {
+			if s := lookupTypeSerializer(field.Type()); s != nil {
+				return s.decode(field, value)
+			}
 		}
+		return assignNativeField(field, value)
+	}
 
-		// Remove modifiers like ,omitempty
-		if idx := strings.Index(jsonTag, ","); idx != -1 {
-			jsonTag = jsonTag[:idx]
-		}
+	meta, err := getStructMeta(t)
+	if err != nil {
+		return err
+	}
 
-		fieldMap[jsonTag] = field
+	// Build column name to field mapping
+	fieldMap := make(map[string]*fieldMeta, len(meta.fields))
+	for i := range meta.fields {
+		fieldMap[meta.fields[i].column] = &meta.fields[i]
 	}
 
-	// Process primary keys
+	if opts.strictColumns {
+		var unknown []string
+		for i, kv := range slices.Concat(pks, cols) {
+			// The extra field takes every attribute column
+			if _, ok := fieldMap[kv.Key]; !ok && (i < len(pks) || meta.extra == nil) {
+				unknown = append(unknown, kv.Key)
+			}
+		}
+		if len(unknown) > 0 {
+			return fmt.Errorf("column(s) with no field in %s: %s", t, strings.Join(unknown, ", "))
+		}
+	}
+
+	// Process primary keys, stripping the hash prefix of pkprefix fields
 	for _, pk := range pks {
-		if field, ok := fieldMap[pk.Key]; ok {
-			if err := assignToPointerField(field, pk.Value); err != nil {
+		if fm, ok := fieldMap[pk.Key]; ok {
+			value := pk.Value
+			if s, isString := value.(string); isString && fm.prefix != nil {
+				logical, err := fm.prefix.strip(s)
+				if err != nil {
+					return fmt.Errorf("primary key %q: %w", pk.Key, err)
+				}
+				value = logical
+			}
+			if err := assignField(fm, value); err != nil {
 				return fmt.Errorf("primary key %q: %w", pk.Key, err)
 			}
 		}
 	}
 
-	// Process regular columns
+	// Process regular columns, collecting the others into the extra field
 	for _, col := range cols {
-		if field, ok := fieldMap[col.Key]; ok {
-			if err := assignToPointerField(field, col.Value); err != nil {
+		if fm, ok := fieldMap[col.Key]; ok {
+			if err := assignField(fm, col.Value); err != nil {
 				return fmt.Errorf("column %q: %w", col.Key, err)
 			}
+		} else if meta.extra != nil {
+			extra := fieldByIndex(v, meta.extra, true)
+			if extra.IsNil() {
+				extra.Set(reflect.MakeMap(anyMapType))
+			}
+			extra.SetMapIndex(reflect.ValueOf(col.Key), reflect.ValueOf(&col.Value).Elem())
+		}
+	}
+
+	// Null fields whose column the row lacks are null
+	read := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		read[col.Key] = true
+	}
+	for _, i := range meta.attrFields {
+		if fm := &meta.fields[i]; fm.null && !read[fm.column] {
+			n := asNullField(fm.settableField(v))
+			n.nullValue().SetZero()
+			n.setNullState(nullNull)
 		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// extraColumnValue returns the value written for the entry column of the extra field of a
+// struct described by meta. The column must not be one of the fields, and the value must be
+// of a type Tablestore stores natively.
+func extraColumnValue(meta *structMeta, column string, value any) (any, error) {
+	if fm := meta.fieldByColumn(column); fm != nil {
+		return nil, fmt.Errorf("extra column %q collides with field %s", column, fm.name)
+	}
+	switch v := value.(type) {
+	case string, []byte, bool, float64:
+	default:
+		n, ok := toInt64(v, false)
+		if !ok {
+			return nil, fmt.Errorf("extra column %q: unsupported value type %T, only string, int64, float64, bool and []byte are allowed", column, value)
+		}
+		value = n
+	}
+	if err := validateColumnValue(column, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// toInt64 converts the integer types other SDKs may decode a column into to int64. With lenient,
+// float64 values with no fractional part in the int64 range are converted too.
+func toInt64(value any, lenient bool) (int64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case float64:
+		// -2^63 is exact in float64, while 2^63 is the first value past the int64 range
+		if lenient && v == math.Trunc(v) && v >= -(1<<63) && v < 1<<63 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,1395 @@
+package otsutils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type testMoney struct {
+	cents int64
+}
+
+type testVersioned struct {
+	v string
+}
+
+func registerTestMoney() {
+	RegisterTypeSerializer(reflect.TypeOf(testMoney{}),
+		func(v any) (any, error) {
+			m := v.(testMoney)
+			return fmt.Sprintf("%d.%02d", m.cents/100, m.cents%100), nil
+		},
+		func(v any) (any, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected string, got %T", v)
+			}
+			var whole, frac int64
+			if _, err := fmt.Sscanf(s, "%d.%d", &whole, &frac); err != nil {
+				return nil, err
+			}
+			return testMoney{cents: whole*100 + frac}, nil
+		},
+	)
+}
+
+func registerTestVersioned() {
+	RegisterTypeSerializer(reflect.TypeOf(testVersioned{}),
+		func(v any) (any, error) { return v.(testVersioned).v, nil },
+		func(v any) (any, error) { return testVersioned{v: v.(string)}, nil },
+	)
+}
+
+func TestTypeSerializerRoundTrip(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+	registerTestMoney()
+
+	type row struct {
+		Pk1     *string    `json:"pk1" pk:"1"`
+		Price   *testMoney `json:"price"`
+		Balance testMoney  `json:"balance"`
+		Unset   *testMoney `json:"unset"`
+	}
+
+	obj := row{
+		Pk1:     tea.String("pk1"),
+		Price:   &testMoney{cents: 1234},
+		Balance: testMoney{cents: 500},
+	}
+	pks, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "pk1", Value: "pk1"}}, pks)
+	ast.Equal([]KeyValue{{Key: "price", Value: "12.34"}, {Key: "balance", Value: "5.00"}}, cols)
+
+	var out row
+	ast.NoError(ParseResult(ctx, &out, pks, cols))
+	ast.Equal("pk1", tea.StringValue(out.Pk1))
+	ast.Equal(&testMoney{cents: 1234}, out.Price)
+	ast.Equal(testMoney{cents: 500}, out.Balance)
+	ast.Nil(out.Unset)
+
+	// fromColumn 的错误需要带上列名
+	err = ParseResult(ctx, &out, nil, []KeyValue{{Key: "price", Value: int64(1)}})
+	ast.ErrorContains(err, `column "price"`)
+}
+
+func TestTypeSerializerUnregisteredType(t *testing.T) {
+	ast := assert.New(t)
+
+	type row struct {
+		Pk1 *string     `json:"pk1" pk:"1"`
+		Col *complex128 `json:"col"`
+	}
+	_, _, err := ParseObj(context.Background(), &row{Pk1: tea.String("pk1")})
+	ast.ErrorContains(err, "field Col has invalid type")
+}
+
+func TestTypeSerializerPanicRecovered(t *testing.T) {
+	ast := assert.New(t)
+	registerTestVersioned()
+
+	type row struct {
+		Pk1       *string        `json:"pk1" pk:"1"`
+		Versioned *testVersioned `json:"versioned"`
+	}
+	// 列值类型与反序列化函数预期不符时，应返回错误而不是 panic
+	var r row
+	err := ParseResult(context.Background(), &r,
+		[]KeyValue{{Key: "pk1", Value: "pk1"}},
+		[]KeyValue{{Key: "versioned", Value: int64(1)}},
+	)
+	ast.ErrorContains(err, "panicked")
+}
+
+func TestParseObjUnexportedField(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	// 未导出且无 tag 的字段被忽略
+	type skipped struct {
+		Pk1    *string `json:"pk1" pk:"1"`
+		hidden string
+	}
+	pks, cols, err := ParseObj(ctx, &skipped{Pk1: tea.String("pk1"), hidden: "x"})
+	ast.NoError(err)
+	ast.Len(pks, 1)
+	ast.Empty(cols)
+
+	// 未导出但带 tag 的字段报错
+	type tagged struct {
+		Pk1    *string `json:"pk1" pk:"1"`
+		hidden *string `pk:"2"`
+	}
+	_, _, err = ParseObj(ctx, &tagged{Pk1: tea.String("pk1")})
+	ast.ErrorContains(err, "field hidden is unexported")
+}
+
+func TestInvalidObj(t *testing.T) {
+	ctx, fake := newFakeContext(t)
+
+	var iface any = &TestRow{}
+	var nilRow *TestRow
+	n := int64(1)
+	tests := []struct {
+		name string
+		obj  any
+	}{
+		{"nil", nil},
+		{"nil typed pointer", nilRow},
+		{"struct value", TestRow{}},
+		{"pointer to non-struct", &n},
+		{"pointer to interface", &iface},
+		{"pointer to pointer", &nilRow},
+		{"pointer to slice", &[]TestRow{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast := assert.New(t)
+			want := fmt.Sprintf("obj must be a non-nil pointer to struct, got %T", tt.obj)
+
+			// 所有入口都返回错误而不是 panic
+			ast.NotPanics(func() {
+				_, _, err := ParseObj(ctx, tt.obj)
+				ast.EqualError(err, want)
+				ast.EqualError(ParseResult(ctx, tt.obj, nil, nil), want)
+				ast.EqualError(PutRow(ctx, tt.obj), want)
+				ast.EqualError(GetRow(ctx, tt.obj), want)
+				ast.EqualError(UpdateRow(ctx, tt.obj), want)
+				ast.EqualError(DeleteRow(ctx, tt.obj), want)
+			})
+		})
+	}
+	assert.Empty(t, fake.CallCount("PutRow")+fake.CallCount("GetRow")+fake.CallCount("UpdateRow")+fake.CallCount("DeleteRow"))
+
+	t.Run("unexported fields", func(t *testing.T) {
+		ast := assert.New(t)
+
+		// 任意类型的未导出字段都被跳过，读写都不会触碰它们
+		type row struct {
+			Pk1   *string `json:"pk1" pk:"1"`
+			Pk2   int64   `json:"pk2" pk:"2"`
+			Col1  string  `json:"col1"`
+			name  *string
+			calls map[string]int
+			done  chan struct{}
+			hook  func()
+			inner struct{ n int }
+		}
+		obj := row{Pk1: tea.String("a"), Pk2: 1, Col1: "v", name: tea.String("x"), calls: map[string]int{}, hook: func() {}}
+		ast.NoError(PutRow(ctx, &obj))
+
+		out := row{Pk1: tea.String("a"), Pk2: 1, name: tea.String("kept")}
+		ast.NoError(GetRow(ctx, &out))
+		ast.Equal("v", out.Col1)
+		ast.Equal("kept", *out.name)
+		ast.Nil(out.calls)
+	})
+}
+
+func TestTypeSerializerInvalidColumnType(t *testing.T) {
+	ast := assert.New(t)
+
+	type badType struct{}
+	RegisterTypeSerializer(reflect.TypeOf(badType{}),
+		func(v any) (any, error) { return 1.5, nil },
+		func(v any) (any, error) { return badType{}, nil },
+	)
+
+	type row struct {
+		Col *badType `json:"col"`
+	}
+	_, _, err := ParseObj(context.Background(), &row{Col: &badType{}})
+	ast.ErrorContains(err, "unsupported column type float64")
+}
+
+func TestTypeSerializerRegistrationAfterFirstUse(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	register := func(prefix string) {
+		RegisterTypeSerializer(reflect.TypeOf(testVersioned{}),
+			func(v any) (any, error) { return prefix + v.(testVersioned).v, nil },
+			func(v any) (any, error) { return testVersioned{v: strconv.Quote(v.(string))}, nil },
+		)
+	}
+
+	type row struct {
+		Col *testVersioned `json:"col"`
+	}
+	obj := row{Col: &testVersioned{v: "x"}}
+
+	register("v1:")
+	_, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal("v1:x", cols[0].Value)
+
+	// 结构体元数据已被缓存，重新注册后应立即生效
+	register("v2:")
+	_, cols, err = ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal("v2:x", cols[0].Value)
+}
+
+func TestRegisterTypeSerializerPanics(t *testing.T) {
+	ast := assert.New(t)
+	ast.Panics(func() { RegisterTypeSerializer(nil, nil, nil) })
+	ast.Panics(func() { RegisterTypeSerializer(reflect.TypeOf(testMoney{}), nil, nil) })
+}
+
+func TestParsePkTag(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    pkTag
+		wantErr string
+	}{
+		{tag: "1", want: pkTag{order: 1}},
+		{tag: "12", want: pkTag{order: 12}},
+		{tag: "2,auto", want: pkTag{order: 2, auto: true}},
+		{tag: "1,gen=ulid", want: pkTag{order: 1, gen: "ulid"}},
+		{tag: "", wantErr: `order "" must be a positive integer`},
+		{tag: "a", wantErr: `order "a" must be a positive integer`},
+		{tag: "0", wantErr: `order "0" must be a positive integer`},
+		{tag: "-1", wantErr: `order "-1" must be a positive integer`},
+		{tag: "1.5", wantErr: `order "1.5" must be a positive integer`},
+		{tag: ",auto", wantErr: `order "" must be a positive integer`},
+		{tag: "1,", wantErr: `unknown option ""`},
+		{tag: "1,AUTO", wantErr: `unknown option "AUTO"`},
+		{tag: "1,auto,auto", wantErr: "option auto is repeated"},
+		{tag: "1,gen=uuid", wantErr: `unknown generator "uuid"`},
+		{tag: "1,gen=ulid,gen=ulid", wantErr: "option gen is repeated"},
+		{tag: "1,auto,gen=ulid", wantErr: "options auto and gen are mutually exclusive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, err := parsePkTag(tt.tag)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPkTagStructValidation(t *testing.T) {
+	ast := assert.New(t)
+	ctx := context.Background()
+
+	type autoNotLast struct {
+		Pk1 *int64  `json:"pk1" pk:"1,auto"`
+		Pk2 *string `json:"pk2" pk:"2"`
+	}
+	_, _, err := ParseObj(ctx, &autoNotLast{})
+	ast.EqualError(err, `field Pk1: invalid pk tag "1,auto": auto is only allowed on the last primary key field`)
+
+	type autoOnString struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk2 *string `json:"pk2" pk:"2,auto"`
+	}
+	_, _, err = ParseObj(ctx, &autoOnString{})
+	ast.EqualError(err, `field Pk2: invalid pk tag "2,auto": auto is only allowed on *int64 fields, got *string`)
+
+	type genOnInt struct {
+		Pk1 *int64 `json:"pk1" pk:"1,gen=ulid"`
+	}
+	_, _, err = ParseObj(ctx, &genOnInt{})
+	ast.EqualError(err, `field Pk1: invalid pk tag "1,gen=ulid": gen is only allowed on *string fields, got *int64`)
+
+	type badOrder struct {
+		Pk1 *string `json:"pk1" pk:"first"`
+	}
+	_, _, err = ParseObj(ctx, &badOrder{})
+	ast.EqualError(err, `field Pk1: invalid pk tag "first": order "first" must be a positive integer`)
+
+	type dupOrder struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk2 *string `json:"pk2" pk:"1"`
+	}
+	_, _, err = ParseObj(ctx, &dupOrder{})
+	ast.EqualError(err, `field Pk2: invalid pk tag "1": order 1 is already used by field Pk1`)
+
+	type gap struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk3 *string `json:"pk3" pk:"3"`
+	}
+	_, _, err = ParseObj(ctx, &gap{})
+	ast.EqualError(err, `field Pk3: invalid pk tag "3": no field has pk order 2, pk orders must run from 1 without gaps`)
+	type noFirst struct {
+		Pk2 *string `json:"pk2" pk:"2"`
+	}
+	_, _, err = ParseObj(ctx, &noFirst{})
+	ast.EqualError(err, `field Pk2: invalid pk tag "2": no field has pk order 1, pk orders must run from 1 without gaps`)
+
+	// 声明顺序与 pk 顺序不同时按数字排序
+	type valid struct {
+		Pk2 *int64  `json:"pk2" pk:"2,auto"`
+		Pk1 *string `json:"pk1" pk:"1,gen=ulid"`
+	}
+	pks, _, err := ParseObj(ctx, &valid{Pk1: tea.String("a"), Pk2: tea.Int64(1)})
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}}, pks)
+
+	type reversed struct {
+		D string `json:"d" pk:"4"`
+		C string `json:"c" pk:"03"`
+		B string `json:"b" pk:"2"`
+		A string `json:"a" pk:"1"`
+	}
+	pks, _, err = ParseObj(ctx, &reversed{A: "a", B: "b", C: "c", D: "d"})
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "a", Value: "a"}, {Key: "b", Value: "b"}, {Key: "c", Value: "c"}, {Key: "d", Value: "d"}}, pks)
+}
+
+func TestPkGenULID(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type row struct {
+		Pk1  *string `json:"pk1" pk:"1,gen=ulid"`
+		Pk2  *int64  `json:"pk2" pk:"2"`
+		Col1 *string `json:"col1"`
+	}
+
+	obj := row{Pk2: tea.Int64(1), Col1: tea.String("v")}
+	ast.NoError(PutRow(ctx, &obj))
+	ast.Len(tea.StringValue(obj.Pk1), 26)
+
+	// 生成的 id 可以直接用于读取
+	got := row{Pk1: obj.Pk1, Pk2: tea.Int64(1)}
+	ast.NoError(GetRow(ctx, &got))
+	ast.Equal("v", tea.StringValue(got.Col1))
+
+	// 已设置的值不会被覆盖
+	obj2 := row{Pk1: tea.String("explicit"), Pk2: tea.Int64(1)}
+	ast.NoError(PutRow(ctx, &obj2))
+	ast.Equal("explicit", tea.StringValue(obj2.Pk1))
+
+	// ULID 按时间有序
+	a, err := newULID()
+	ast.NoError(err)
+	time.Sleep(2 * time.Millisecond)
+	b, err := newULID()
+	ast.NoError(err)
+	ast.Less(a, b)
+}
+
+func TestPkAutoIncrement(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	meta := &tablestore.TableMeta{TableName: "auto_table"}
+	meta.AddPrimaryKeyColumn("pk1", tablestore.PrimaryKeyType_STRING)
+	meta.AddPrimaryKeyColumnOption("pk2", tablestore.PrimaryKeyType_INTEGER, tablestore.AUTO_INCREMENT)
+	_, err := fake.CreateTable(&tablestore.CreateTableRequest{TableMeta: meta, TableOption: tablestore.NewTableOption(-1, 1)})
+	ast.NoError(err)
+	ctx = (&OtsUtilsParams{Client: fake, TableName: "auto_table"}).WithContext(ctx)
+
+	type row struct {
+		Pk1  *string `json:"pk1" pk:"1"`
+		Pk2  *int64  `json:"pk2" pk:"2,auto"`
+		Col1 *string `json:"col1"`
+	}
+
+	// 服务端分配的值写回字段
+	a := row{Pk1: tea.String("a"), Col1: tea.String("first")}
+	ast.NoError(PutRow(ctx, &a))
+	ast.NotNil(a.Pk2)
+	b := row{Pk1: tea.String("a"), Col1: tea.String("second")}
+	ast.NoError(PutRow(ctx, &b))
+	ast.Greater(tea.Int64Value(b.Pk2), tea.Int64Value(a.Pk2))
+
+	// 非 nil 时按普通主键读写
+	got := row{Pk1: tea.String("a"), Pk2: a.Pk2}
+	ast.NoError(GetRow(ctx, &got))
+	ast.Equal("first", tea.StringValue(got.Col1))
+	ast.NoError(UpdateRow(ctx, &row{Pk1: tea.String("a"), Pk2: a.Pk2, Col1: tea.String("updated")}))
+	ast.NoError(GetRow(ctx, &got))
+	ast.Equal("updated", tea.StringValue(got.Col1))
+
+	// 批量写入同样写回
+	rows := []row{{Pk1: tea.String("b")}, {Pk1: tea.String("b")}}
+	ast.NoError(BatchPutRows(ctx, &rows))
+	ast.NotNil(rows[0].Pk2)
+	ast.NotEqual(tea.Int64Value(rows[0].Pk2), tea.Int64Value(rows[1].Pk2))
+	c := row{Pk1: tea.String("c")}
+	_, err = BatchWrite(ctx, []BatchOp{{Kind: PutOp, Obj: &c}})
+	ast.NoError(err)
+	ast.NotNil(c.Pk2)
+
+	// 非自增列不接受占位符
+	type notAuto struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk2 *int64  `json:"pk2" pk:"2,auto"`
+	}
+	err = PutRow((&OtsUtilsParams{Client: fake, TableName: "test_table"}).WithContext(ctx), &notAuto{Pk1: tea.String("a")})
+	ast.Equal(CodeParameterInvalid, Code(err))
+}
+
+func TestParseResultNumberCoercion(t *testing.T) {
+	type row struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Col *int64  `json:"col"`
+	}
+	pks := []KeyValue{{Key: "pk1", Value: "a"}}
+
+	cases := []struct {
+		name    string
+		value   any
+		lenient bool
+		want    int64
+		err     string
+	}{
+		{name: "int64", value: int64(7), want: 7},
+		{name: "int", value: int(7), want: 7},
+		{name: "int32", value: int32(-7), want: -7},
+		{name: "float64 strict", value: float64(7), err: `column "col": cannot assign float64 value to field of type *int64`},
+		{name: "float64 lenient", value: float64(7), lenient: true, want: 7},
+		{name: "float64 fraction", value: 7.5, lenient: true, err: `column "col": cannot assign float64 value to field of type *int64`},
+		{name: "float64 out of range", value: math.Ldexp(1, 63), lenient: true, err: "cannot assign float64 value"},
+		{name: "float64 min int64", value: -math.Ldexp(1, 63), lenient: true, want: math.MinInt64},
+		{name: "string", value: "7", lenient: true, err: `column "col": cannot assign string value to field of type *int64`},
+		{name: "bool", value: true, err: `column "col": cannot assign bool value to field of type *int64`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var r row
+			err := parseResult(context.Background(), &r, pks, []KeyValue{{Key: "col", Value: c.value}}, parseResultOptions{lenientNumbers: c.lenient})
+			if c.err != "" {
+				assert.ErrorContains(t, err, c.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.want, tea.Int64Value(r.Col))
+		})
+	}
+
+	// 类型不符的错误包含列名、实际类型与字段类型
+	type strRow struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Col *string `json:"col"`
+	}
+	var r strRow
+	err := ParseResult(context.Background(), &r, pks, []KeyValue{{Key: "col", Value: int64(1)}})
+	assert.EqualError(t, err, `column "col": cannot assign int64 value to field of type *string`)
+}
+
+func TestGetRowLenientNumbers(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	// 模拟其他写入方把 col1 写成了 DOUBLE
+	change := &tablestore.UpdateRowChange{TableName: "test_table", PrimaryKey: &tablestore.PrimaryKey{}}
+	change.PrimaryKey.AddPrimaryKeyColumn("pk1", "a")
+	change.PrimaryKey.AddPrimaryKeyColumn("pk2", int64(1))
+	change.PutColumn("col1", float64(42))
+	change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+	_, err := fake.UpdateRow(&tablestore.UpdateRowRequest{UpdateRowChange: change})
+	ast.NoError(err)
+
+	type row struct {
+		Pk1  *string `json:"pk1" pk:"1"`
+		Pk2  *int64  `json:"pk2" pk:"2"`
+		Col1 *int64  `json:"col1"`
+	}
+	obj := row{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+	ast.EqualError(GetRow(ctx, &obj), `column "col1": cannot assign float64 value to field of type *int64`)
+	ast.NoError(GetRow(ctx, &obj, GetRowParams{LenientNumbers: true}))
+	ast.Equal(int64(42), tea.Int64Value(obj.Col1))
+}
+
+func TestGetRowStrictColumns(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	// 旧的写入方留下了结构体中没有的列
+	pks := []KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}}
+	ast.NoError(PutRowMap(ctx, pks, []KeyValue{{Key: "col1", Value: "v"}, {Key: "legacy", Value: int64(1)}, {Key: "old_name", Value: "x"}}))
+
+	type row struct {
+		Pk1  string  `json:"pk1" pk:"1"`
+		Pk2  int64   `json:"pk2" pk:"2"`
+		Col1 *string `json:"col1"`
+	}
+	obj := row{Pk1: "a", Pk2: 1}
+	ast.EqualError(GetRow(ctx, &obj, GetRowParams{StrictColumns: true}), "column(s) with no field in otsutils.row: legacy, old_name")
+	ast.Nil(obj.Col1)
+
+	// 默认忽略多余的列
+	ast.NoError(GetRow(ctx, &obj))
+	ast.Equal("v", tea.StringValue(obj.Col1))
+
+	ast.NoError(UpdateRow(ctx, &row{Pk1: "a", Pk2: 1}, UpdateRowParams{DeletedColumns: []string{"legacy", "old_name"}}))
+	ast.NoError(GetRow(ctx, &obj, GetRowParams{StrictColumns: true}))
+}
+
+func TestFloat64Columns(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type row struct {
+		Pk1   *string  `json:"pk1" pk:"1"`
+		Pk2   *int64   `json:"pk2" pk:"2"`
+		Name  *string  `json:"name"`
+		Price *float64 `json:"price"`
+		Score *float64 `json:"score"`
+		Data  *[]byte  `json:"data"`
+	}
+	obj := row{
+		Pk1:   tea.String("a"),
+		Pk2:   tea.Int64(1),
+		Name:  tea.String("apple"),
+		Price: tea.Float64(12.5),
+		Data:  &[]byte{1, 2},
+	}
+	pks, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}}, pks)
+	ast.Equal([]KeyValue{{Key: "name", Value: "apple"}, {Key: "price", Value: 12.5}, {Key: "data", Value: []byte{1, 2}}}, cols)
+
+	// 经 PutRow/GetRow 往返
+	ast.NoError(PutRow(ctx, &obj))
+	out := row{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(obj, out)
+
+	obj.Price = tea.Float64(-0.25)
+	ast.NoError(UpdateRow(ctx, &obj))
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(-0.25, tea.Float64Value(out.Price))
+
+	// DOUBLE 列不能赋给其他类型的字段，其他类型的列也不能赋给 *float64 字段
+	err = ParseResult(ctx, &out, pks, []KeyValue{{Key: "name", Value: 1.5}})
+	ast.EqualError(err, `column "name": cannot assign float64 value to field of type *string`)
+	err = ParseResult(ctx, &out, pks, []KeyValue{{Key: "price", Value: int64(1)}})
+	ast.EqualError(err, `column "price": cannot assign int64 value to field of type *float64`)
+
+	// DOUBLE 不能作为主键
+	type floatPk struct {
+		Pk1 *float64 `json:"pk1" pk:"1"`
+	}
+	ast.EqualError(CheckType(&floatPk{}), "field Pk1 has invalid type: *float64. Only string, int64, and []byte fields and pointers to them are allowed; primary key columns can only hold string, integer or binary values, so float64 cannot be a primary key")
+}
+
+func TestValueFields(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type row struct {
+		Pk1   string   `json:"pk1" pk:"1"`
+		Pk2   *int64   `json:"pk2" pk:"2"`
+		Name  string   `json:"name"`
+		Count int64    `json:"count"`
+		Score float64  `json:"score,omitempty"`
+		Data  []byte   `json:"data,omitempty"`
+		Note  *string  `json:"note"`
+		Price *float64 `json:"price"`
+	}
+
+	// 未标 omitempty 的零值照常写入，标了 omitempty 的零值与 nil 指针一样跳过
+	obj := row{Pk1: "a", Pk2: tea.Int64(1), Note: tea.String("n")}
+	pks, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}}, pks)
+	ast.Equal([]KeyValue{{Key: "name", Value: ""}, {Key: "count", Value: int64(0)}, {Key: "note", Value: "n"}}, cols)
+
+	obj = row{Pk1: "a", Pk2: tea.Int64(1), Name: "apple", Count: 3, Score: 0.5, Data: []byte{1}, Price: tea.Float64(2)}
+	_, cols, err = ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{
+		{Key: "name", Value: "apple"},
+		{Key: "count", Value: int64(3)},
+		{Key: "score", Value: 0.5},
+		{Key: "data", Value: []byte{1}},
+		{Key: "price", Value: 2.0},
+	}, cols)
+
+	// 经 PutRow/GetRow 往返，值字段直接赋值
+	ast.NoError(PutRow(ctx, &obj))
+	out := row{Pk1: "a", Pk2: tea.Int64(1)}
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(obj, out)
+
+	// 缺失的列保持值字段原样
+	out = row{Pk1: "a", Pk2: tea.Int64(1), Note: tea.String("kept")}
+	ast.NoError(ParseResult(ctx, &out, pks, []KeyValue{{Key: "count", Value: int64(9)}}))
+	ast.Equal(row{Pk1: "a", Pk2: tea.Int64(1), Count: 9, Note: tea.String("kept")}, out)
+
+	err = ParseResult(ctx, &out, pks, []KeyValue{{Key: "count", Value: "9"}})
+	ast.EqualError(err, `column "count": cannot assign string value to field of type int64`)
+}
+
+func TestUntaggedFields(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type helper struct{ calls int }
+	type row struct {
+		Pk1      string `json:"pk1" pk:"1"`
+		Pk2      int64  `json:"pk2" pk:"2"`
+		UserName *string
+		Cache    *helper        `json:"-"`
+		Internal map[string]int `json:"-"`
+	}
+
+	// json:"-" 的字段被跳过，无 json tag 的字段使用小写字段名
+	obj := row{Pk1: "a", Pk2: 1, UserName: tea.String("bob"), Cache: &helper{calls: 1}}
+	pks, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}}, pks)
+	ast.Equal([]KeyValue{{Key: "username", Value: "bob"}}, cols)
+
+	out := row{}
+	ast.NoError(ParseResult(ctx, &out, pks, append(cols, KeyValue{Key: "cache", Value: "x"})))
+	ast.Equal(row{Pk1: "a", Pk2: 1, UserName: tea.String("bob")}, out)
+
+	// 自定义字段名映射，读写保持对称
+	SetFieldNameMapper(func(name string) string { return "x_" + strings.ToLower(name) })
+	t.Cleanup(func() { SetFieldNameMapper(nil) })
+
+	_, cols, err = ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "x_username", Value: "bob"}}, cols)
+
+	ast.NoError(PutRow(ctx, &obj))
+	out = row{Pk1: "a", Pk2: 1}
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(row{Pk1: "a", Pk2: 1, UserName: tea.String("bob")}, out)
+}
+
+func TestOmitEmptyColumnNames(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type row struct {
+		Pk1   *string `json:"pk1,omitempty" pk:"1"`
+		Pk2   *int64  `json:"pk2" pk:"2"`
+		Col1  *string `json:"col1,omitempty"`
+		Count int64   `json:",omitempty"`
+	}
+
+	// 写入的列名不带 json tag 的选项
+	obj := row{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v"), Count: 2}
+	pks, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}}, pks)
+	ast.Equal([]KeyValue{{Key: "col1", Value: "v"}, {Key: "Count", Value: int64(2)}}, cols)
+
+	ast.NoError(PutRow(ctx, &obj))
+	stored, err := GetRowMap(ctx, pks)
+	ast.NoError(err)
+	ast.ElementsMatch(cols, stored)
+
+	out := row{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(obj, out)
+}
+
+func TestExtraColumns(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type row struct {
+		Pk1   string         `json:"pk1" pk:"1"`
+		Pk2   int64          `json:"pk2" pk:"2"`
+		Name  *string        `json:"name"`
+		Extra map[string]any `json:"extra" ots:"extra"`
+	}
+
+	// map 中的每一项写成一列，按列名排序
+	obj := row{Pk1: "a", Pk2: 1, Name: tea.String("n"), Extra: map[string]any{
+		"size": 3, "color": "red", "ratio": 0.5, "ok": true, "raw": []byte{1},
+	}}
+	_, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{
+		{Key: "name", Value: "n"},
+		{Key: "color", Value: "red"},
+		{Key: "ok", Value: true},
+		{Key: "ratio", Value: 0.5},
+		{Key: "raw", Value: []byte{1}},
+		{Key: "size", Value: int64(3)},
+	}, cols)
+
+	// 读取时没有对应字段的列都放入 Extra
+	ast.NoError(PutRow(ctx, &obj))
+	out := row{Pk1: "a", Pk2: 1}
+	ast.NoError(GetRow(ctx, &out, GetRowParams{StrictColumns: true}))
+	ast.Equal("n", tea.StringValue(out.Name))
+	ast.Equal(map[string]any{"size": int64(3), "color": "red", "ratio": 0.5, "ok": true, "raw": []byte{1}}, out.Extra)
+
+	// ReadModifyWrite 删除从 Extra 中移除的列
+	ast.NoError(ReadModifyWrite(ctx, &out, func() error {
+		delete(out.Extra, "raw")
+		out.Extra["size"] = int64(4)
+		return nil
+	}))
+	cols, err = GetRowMap(ctx, []KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}})
+	ast.NoError(err)
+	ast.ElementsMatch([]KeyValue{
+		{Key: "name", Value: "n"},
+		{Key: "color", Value: "red"},
+		{Key: "ok", Value: true},
+		{Key: "ratio", Value: 0.5},
+		{Key: "size", Value: int64(4)},
+	}, cols)
+
+	obj.Extra = map[string]any{"name": "x"}
+	_, _, err = ParseObj(ctx, &obj)
+	ast.EqualError(err, `extra column "name" collides with field Name`)
+	obj.Extra = map[string]any{"pk2": int64(2)}
+	_, _, err = ParseObj(ctx, &obj)
+	ast.EqualError(err, `extra column "pk2" collides with field Pk2`)
+	obj.Extra = map[string]any{"when": time.Now()}
+	_, _, err = ParseObj(ctx, &obj)
+	ast.EqualError(err, `extra column "when": unsupported value type time.Time, only string, int64, float64, bool and []byte are allowed`)
+	obj.Extra = map[string]any{"bad-name": "x"}
+	_, _, err = ParseObj(ctx, &obj)
+	ast.ErrorContains(err, `column name "bad-name" contains '-'`)
+
+	type invalid struct {
+		Pk1    string            `json:"pk1" pk:"1"`
+		Extra  map[string]string `ots:"extra"`
+		Extra2 map[string]any    `ots:"extra" pk:"2"`
+		Extra3 map[string]any    `ots:"extra"`
+		Extra4 map[string]any    `ots:"extras"`
+		Extra5 map[string]any    `ots:"extra"`
+	}
+	err = CheckType(&invalid{})
+	ast.ErrorContains(err, "field Extra: the extra field must be a map[string]any, got map[string]string")
+	ast.ErrorContains(err, "field Extra2: the extra field can not have pk, pkprefix or index tags")
+	ast.ErrorContains(err, `field Extra4: unknown ots tag "extras"`)
+	ast.ErrorContains(err, `field Extra5: ots tag "extra" is already used by field Extra3`)
+}
+
+func TestEmbeddedStructs(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type Audit struct {
+		CreatedAt int64   `json:"created_at"`
+		Note      *string `json:"note"`
+	}
+	type Owned struct {
+		Audit
+		Owner string  `json:"owner"`
+		Name  *string `json:"name"`
+	}
+	type Keys struct {
+		Pk1 string `json:"pk1" pk:"1"`
+		Pk2 int64  `json:"pk2" pk:"2"`
+	}
+	type row struct {
+		Keys
+		*Owned
+		Name *string `json:"name"`
+		Note string
+	}
+
+	// 两层嵌入的字段被提升，外层字段覆盖同名的内层字段
+	obj := row{
+		Keys:  Keys{Pk1: "a", Pk2: 1},
+		Owned: &Owned{Audit: Audit{CreatedAt: 5, Note: tea.String("inner")}, Owner: "bob", Name: tea.String("inner")},
+		Name:  tea.String("outer"),
+		Note:  "outer",
+	}
+	pks, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}}, pks)
+	ast.Equal([]KeyValue{
+		{Key: "created_at", Value: int64(5)},
+		{Key: "owner", Value: "bob"},
+		{Key: "name", Value: "outer"},
+		{Key: "note", Value: "outer"},
+	}, cols)
+
+	// 读取时分配空的嵌入指针
+	ast.NoError(PutRow(ctx, &obj))
+	out := row{Keys: Keys{Pk1: "a", Pk2: 1}}
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(row{
+		Keys:  Keys{Pk1: "a", Pk2: 1},
+		Owned: &Owned{Audit: Audit{CreatedAt: 5}, Owner: "bob"},
+		Name:  tea.String("outer"),
+		Note:  "outer",
+	}, out)
+
+	// 空的嵌入指针中的字段不写入
+	_, cols, err = ParseObj(ctx, &row{Keys: Keys{Pk1: "a", Pk2: 1}, Note: "n"})
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "note", Value: "n"}}, cols)
+
+	// 同一层级中只有带 json tag 的字段生效，否则报告冲突
+	type Tagged struct {
+		Color *string `json:"color"`
+	}
+	type Untagged struct {
+		Color *string
+	}
+	type preferTagged struct {
+		Keys
+		Untagged
+		Tagged
+	}
+	_, cols, err = ParseObj(ctx, &preferTagged{Keys: Keys{Pk1: "a"}, Untagged: Untagged{Color: tea.String("x")}, Tagged: Tagged{Color: tea.String("y")}})
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "color", Value: "y"}}, cols)
+
+	// go vet 不允许重复的 json tag，这里动态构造结构体
+	type Other struct {
+		Color *string `json:"color"`
+	}
+	ambiguous := reflect.StructOf([]reflect.StructField{
+		{Name: "Keys", Type: reflect.TypeOf(Keys{}), Anonymous: true},
+		{Name: "Tagged", Type: reflect.TypeOf(&Tagged{}), Anonymous: true},
+		{Name: "Other", Type: reflect.TypeOf(&Other{}), Anonymous: true},
+	})
+	ast.EqualError(CheckType(reflect.New(ambiguous).Interface()), `field Color maps to column "color", which is already used by field Color`)
+
+	// 嵌入自身的结构体不会无限递归
+	type Node struct {
+		*Node
+		ID string `json:"id" pk:"1"`
+	}
+	ast.NoError(CheckType(&Node{}))
+}
+
+func TestFlattenedStructs(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type Geo struct {
+		Lat *float64 `json:"lat"`
+	}
+	type AddressInfo struct {
+		City string `json:"city"`
+		Zip  *string
+		Geo  *Geo `json:"geo" ots:"flatten"`
+	}
+	type row struct {
+		Pk1     string       `json:"pk1" pk:"1"`
+		Pk2     int64        `json:"pk2" pk:"2"`
+		Address AddressInfo  `json:"addr" ots:"flatten"`
+		Billing *AddressInfo `ots:"flatten"`
+	}
+
+	// 嵌套结构体的字段展开为带前缀的列
+	obj := row{
+		Pk1:     "a",
+		Pk2:     1,
+		Address: AddressInfo{City: "hz", Zip: tea.String("310000"), Geo: &Geo{Lat: tea.Float64(30.25)}},
+	}
+	_, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{
+		{Key: "addr_city", Value: "hz"},
+		{Key: "addr_zip", Value: "310000"},
+		{Key: "addr_geo_lat", Value: 30.25},
+	}, cols)
+
+	// 读取时按列名还原嵌套结构体，空指针按需分配
+	ast.NoError(PutRow(ctx, &obj))
+	out := row{Pk1: "a", Pk2: 1}
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(obj, out)
+
+	obj.Billing = &AddressInfo{City: "sh"}
+	_, cols, err = ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Contains(cols, KeyValue{Key: "billing_city", Value: "sh"})
+
+	// 自定义分隔符
+	SetFlattenSeparator("__")
+	t.Cleanup(func() { SetFlattenSeparator("") })
+	_, cols, err = ParseObj(ctx, &row{Pk1: "a", Address: AddressInfo{City: "hz"}})
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "addr__city", Value: "hz"}}, cols)
+	SetFlattenSeparator("")
+
+	// 嵌套结构体中不允许 pk tag
+	type Keyed struct {
+		ID string `json:"id" pk:"2"`
+	}
+	type keyedRow struct {
+		Pk1   string `json:"pk1" pk:"1"`
+		Inner Keyed  `json:"inner" ots:"flatten"`
+	}
+	ast.EqualError(CheckType(&keyedRow{}), "field ID: pk tags are not allowed inside flattened structs")
+
+	// 嵌套层数超过限制
+	type L4 struct {
+		V string `json:"v"`
+	}
+	type L3 struct {
+		L4 L4 `json:"l4" ots:"flatten"`
+	}
+	type L2 struct {
+		L3 L3 `json:"l3" ots:"flatten"`
+	}
+	type L1 struct {
+		L2 L2 `json:"l2" ots:"flatten"`
+	}
+	type deepRow struct {
+		Pk1 string `json:"pk1" pk:"1"`
+		L1  L1     `json:"l1" ots:"flatten"`
+	}
+	ast.EqualError(CheckType(&deepRow{}), "field L4: flattened structs can be nested at most 3 levels deep")
+	type shallowRow struct {
+		Pk1 string `json:"pk1" pk:"1"`
+		L2  L2     `json:"l2" ots:"flatten"`
+	}
+	ast.NoError(CheckType(&shallowRow{}))
+
+	// 只有结构体字段可以展开
+	type badRow struct {
+		Pk1  string   `json:"pk1" pk:"1"`
+		Tags []string `json:"tags" ots:"flatten"`
+	}
+	ast.EqualError(CheckType(&badRow{}), `field Tags: ots tag "flatten" is only allowed on struct fields and pointers to them, got []string`)
+}
+
+func TestJSONColumns(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type Config struct {
+		Retries int               `json:"retries"`
+		Labels  map[string]string `json:"labels,omitempty"`
+	}
+	type row struct {
+		Pk1    string         `json:"pk1" pk:"1"`
+		Pk2    int64          `json:"pk2" pk:"2"`
+		Tags   []string       `json:"tags" otstype:"json"`
+		Config *Config        `json:"config" otstype:"json"`
+		Meta   map[string]any `json:"meta" otstype:"json"`
+		Fn     func()         `json:"fn" otstype:"json"`
+	}
+
+	// 值以 JSON 字符串写入
+	obj := row{
+		Pk1:    "a",
+		Pk2:    1,
+		Tags:   []string{"x", "y"},
+		Config: &Config{Retries: 3, Labels: map[string]string{"env": "prod"}},
+	}
+	_, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{
+		{Key: "tags", Value: `["x","y"]`},
+		{Key: "config", Value: `{"retries":3,"labels":{"env":"prod"}}`},
+	}, cols)
+
+	// 读取时解码回字段，覆盖原有内容
+	ast.NoError(PutRow(ctx, &obj))
+	out := row{Pk1: "a", Pk2: 1, Tags: []string{"old", "values", "here"}}
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(obj, out)
+
+	// 二进制列同样可以解码
+	ast.NoError(ParseResult(ctx, &out, nil, []KeyValue{{Key: "meta", Value: []byte(`{"n":1}`)}}))
+	ast.Equal(map[string]any{"n": float64(1)}, out.Meta)
+
+	// 空的 map 和 slice 不写入
+	_, cols, err = ParseObj(ctx, &row{Pk1: "a", Tags: []string{}, Meta: map[string]any{}})
+	ast.NoError(err)
+	ast.Empty(cols)
+
+	// 编解码错误包含字段名和列名
+	_, _, err = ParseObj(ctx, &row{Pk1: "a", Fn: func() {}})
+	ast.ErrorContains(err, `field Fn: column "fn": marshal json: json: unsupported type: func()`)
+	err = ParseResult(ctx, &out, nil, []KeyValue{{Key: "tags", Value: "not json"}})
+	ast.ErrorContains(err, `column "tags": field Tags: unmarshal json: invalid character`)
+	err = ParseResult(ctx, &out, nil, []KeyValue{{Key: "tags", Value: int64(1)}})
+	ast.EqualError(err, `column "tags": field Tags: a json column must be a string or binary, got int64`)
+
+	// json 字段不能作为主键
+	type badRow struct {
+		ID   []string `json:"id" pk:"1" otstype:"json"`
+		Kind string   `json:"kind" otstype:"yaml"`
+	}
+	ast.EqualError(CheckType(&badRow{}), `type otsutils.badRow has 2 problems: field ID: otstype "json" can not be used with pk, pkprefix or index tags; field Kind: unknown otstype tag "yaml"`)
+}
+
+// testCents is stored as an integer column by its OTSMarshaler methods.
+type testCents int64
+
+func (c testCents) MarshalOTS() (any, error) {
+	if c < 0 {
+		return nil, fmt.Errorf("negative amount %d", c)
+	}
+	return int64(c), nil
+}
+
+func (c *testCents) UnmarshalOTS(value any) error {
+	n, ok := value.(int64)
+	if !ok {
+		return fmt.Errorf("cents must be an integer, got %T", value)
+	}
+	*c = testCents(n)
+	return nil
+}
+
+// testCode is a string kind stored upper-cased by its OTSMarshaler methods.
+type testCode string
+
+func (c *testCode) MarshalOTS() (any, error) {
+	if *c == "" {
+		return nil, nil
+	}
+	return strings.ToUpper(string(*c)), nil
+}
+
+func (c *testCode) UnmarshalOTS(value any) error {
+	*c = testCode(strings.ToLower(value.(string)))
+	return nil
+}
+
+// testWriteOnly implements OTSMarshaler only.
+type testWriteOnly struct{}
+
+func (testWriteOnly) MarshalOTS() (any, error) { return "", nil }
+
+func TestOTSMarshaler(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type row struct {
+		Pk1   testCode   `json:"pk1" pk:"1"`
+		Pk2   int64      `json:"pk2" pk:"2"`
+		Price testCents  `json:"price"`
+		Tip   *testCents `json:"tip"`
+		Note  testCode   `json:"note"`
+		Codes []testCode `json:"codes" otstype:"json"`
+	}
+
+	// 方法优先于内置的 string 处理，返回 nil 时不写入
+	tip := testCents(30)
+	obj := row{Pk1: "a", Pk2: 1, Price: 250, Tip: &tip, Codes: []testCode{"x"}}
+	pks, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "pk1", Value: "A"}, {Key: "pk2", Value: int64(1)}}, pks)
+	// otstype 标签优先于方法
+	ast.Equal([]KeyValue{{Key: "price", Value: int64(250)}, {Key: "tip", Value: int64(30)}, {Key: "codes", Value: `["x"]`}}, cols)
+
+	// 读取时调用 UnmarshalOTS，指针字段按需分配
+	ast.NoError(PutRow(ctx, &obj))
+	out := row{Pk1: "a", Pk2: 1}
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(obj, out)
+
+	// 方法的错误和不支持的返回值包含字段名
+	_, _, err = ParseObj(ctx, &row{Pk1: "a", Price: -1})
+	ast.EqualError(err, "field Price: MarshalOTS: negative amount -1")
+	err = ParseResult(ctx, &out, nil, []KeyValue{{Key: "price", Value: "x"}})
+	ast.EqualError(err, `column "price": field Price: UnmarshalOTS: cents must be an integer, got string`)
+
+	// 方法优先于注册的序列化器
+	RegisterTypeSerializer(reflect.TypeOf(testCents(0)),
+		func(v any) (any, error) { return "serialized", nil },
+		func(v any) (any, error) { return testCents(0), nil },
+	)
+	_, cols, err = ParseObj(ctx, &row{Pk1: "a", Price: 7})
+	ast.NoError(err)
+	ast.Equal(KeyValue{Key: "price", Value: int64(7)}, cols[0])
+
+	// 只实现一个接口的类型被拒绝
+	type halfRow struct {
+		Pk1 string        `json:"pk1" pk:"1"`
+		W   testWriteOnly `json:"w"`
+	}
+	ast.EqualError(CheckType(&halfRow{}), "field W: type otsutils.testWriteOnly implements OTSMarshaler but not OTSUnmarshaler, implement both or neither")
+}
+
+func TestIntegerWidths(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type row struct {
+		Pk1 string  `json:"pk1" pk:"1"`
+		Pk2 int32   `json:"pk2" pk:"2"`
+		I   int     `json:"i"`
+		I8  int8    `json:"i8"`
+		I16 *int16  `json:"i16"`
+		I32 *int32  `json:"i32"`
+		U8  uint8   `json:"u8"`
+		U16 uint16  `json:"u16"`
+		U32 *uint32 `json:"u32"`
+	}
+
+	// 写入时转换为 int64
+	obj := row{Pk1: "a", Pk2: 1, I: -7, I8: math.MinInt8, I16: tea.Int16(300), I32: tea.Int32(math.MaxInt32), U8: 255, U16: 65535, U32: tea.Uint32(math.MaxUint32)}
+	pks, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(1)}}, pks)
+	ast.Equal([]KeyValue{
+		{Key: "i", Value: int64(-7)},
+		{Key: "i8", Value: int64(math.MinInt8)},
+		{Key: "i16", Value: int64(300)},
+		{Key: "i32", Value: int64(math.MaxInt32)},
+		{Key: "u8", Value: int64(255)},
+		{Key: "u16", Value: int64(65535)},
+		{Key: "u32", Value: int64(math.MaxUint32)},
+	}, cols)
+
+	ast.NoError(PutRow(ctx, &obj))
+	out := row{Pk1: "a", Pk2: 1}
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(obj, out)
+
+	// 读取时超出字段范围的值报错，而不是被截断
+	err = ParseResult(ctx, &out, nil, []KeyValue{{Key: "i32", Value: int64(5_000_000_000)}})
+	ast.EqualError(err, `column "i32": value 5000000000 overflows field of type *int32`)
+	err = ParseResult(ctx, &out, nil, []KeyValue{{Key: "u8", Value: int64(-1)}})
+	ast.EqualError(err, `column "u8": value -1 overflows field of type uint8`)
+	err = ParseResult(ctx, &out, []KeyValue{{Key: "pk2", Value: int64(math.MaxInt32 + 1)}}, nil)
+	ast.EqualError(err, `primary key "pk2": value 2147483648 overflows field of type int32`)
+
+	// 主键列的类型为 INTEGER
+	schema, err := structPrimaryKeySchema(&row{})
+	ast.NoError(err)
+	ast.Equal(tablestore.PrimaryKeyType_INTEGER, schema[1].Type)
+
+	// uint64 的值不一定能用 int64 表示
+	type unsignedRow struct {
+		Pk1 string `json:"pk1" pk:"1"`
+		N   uint64 `json:"n"`
+	}
+	ast.EqualError(CheckType(&unsignedRow{}), "field N has invalid type: uint64. Only string, int64, float64, and []byte fields and pointers to them are allowed; Tablestore integers are signed, use int64 instead of uint64")
+}
+
+// testStatus and testKind are enum types with a native underlying kind.
+type (
+	testStatus string
+	testKind   int64
+	testRatio  float64
+	testBlob   []byte
+)
+
+func TestNamedNativeTypes(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type row struct {
+		Pk1    testStatus  `json:"pk1" pk:"1"`
+		Pk2    testKind    `json:"pk2" pk:"2"`
+		Status *testStatus `json:"status"`
+		Kind   testKind    `json:"kind"`
+		Ratio  *testRatio  `json:"ratio"`
+		Blob   testBlob    `json:"blob"`
+	}
+
+	// 按底层类型写入
+	status, ratio := testStatus("active"), testRatio(0.5)
+	obj := row{Pk1: "a", Pk2: 2, Status: &status, Kind: 3, Ratio: &ratio, Blob: testBlob("b")}
+	pks, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "pk1", Value: "a"}, {Key: "pk2", Value: int64(2)}}, pks)
+	ast.Equal([]KeyValue{
+		{Key: "status", Value: "active"},
+		{Key: "kind", Value: int64(3)},
+		{Key: "ratio", Value: 0.5},
+		{Key: "blob", Value: []byte("b")},
+	}, cols)
+
+	// 读取时转换回命名类型，主键同样适用
+	ast.NoError(PutRow(ctx, &obj))
+	out := row{Pk1: "a", Pk2: 2}
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(obj, out)
+
+	batch := []*row{{Pk1: "a", Pk2: 2}}
+	ast.NoError(BatchGetRows(ctx, &batch))
+	ast.Equal(obj, *batch[0])
+
+	var rows []row
+	ast.NoError(GetRange(ctx, &row{Pk1: "a"}, &row{Pk1: "a", Pk2: 3}, &rows))
+	ast.Equal([]row{obj}, rows)
+}
+
+func TestSQLNullTypes(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	type row struct {
+		Pk1    string           `json:"pk1" pk:"1"`
+		Pk2    int64            `json:"pk2" pk:"2"`
+		Name   sql.NullString   `json:"name"`
+		Count  sql.NullInt64    `json:"count"`
+		Small  *sql.NullInt32   `json:"small"`
+		Score  sql.NullFloat64  `json:"score"`
+		Active sql.NullBool     `json:"active"`
+		Seen   sql.NullTime     `json:"seen"`
+		Note   sql.Null[string] `json:"note"`
+	}
+
+	// Valid 时写入内部的值，时间按 Unix 毫秒写入
+	seen := time.UnixMilli(1_700_000_000_123)
+	obj := row{
+		Pk1:    "a",
+		Pk2:    1,
+		Name:   sql.NullString{String: "n", Valid: true},
+		Count:  sql.NullInt64{Int64: 0, Valid: true},
+		Small:  &sql.NullInt32{Int32: 7, Valid: true},
+		Active: sql.NullBool{Bool: false, Valid: true},
+		Seen:   sql.NullTime{Time: seen, Valid: true},
+		Note:   sql.Null[string]{V: "ignored"},
+	}
+	_, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{
+		{Key: "name", Value: "n"},
+		{Key: "count", Value: int64(0)},
+		{Key: "small", Value: int64(7)},
+		{Key: "active", Value: false},
+		{Key: "seen", Value: int64(1_700_000_000_123)},
+	}, cols)
+
+	// 读取时设置 Valid，缺失的列保持 Valid=false
+	ast.NoError(PutRow(ctx, &obj))
+	out := row{Pk1: "a", Pk2: 1}
+	ast.NoError(GetRow(ctx, &out))
+	obj.Note = sql.Null[string]{}
+	ast.Equal(obj, out)
+	ast.False(out.Score.Valid)
+
+	err = ParseResult(ctx, &out, nil, []KeyValue{{Key: "seen", Value: "yesterday"}})
+	ast.EqualError(err, `column "seen": cannot assign string value to field of type sql.NullTime, a time is stored as Unix milliseconds`)
+
+	// null 类型不能作为主键
+	type badRow struct {
+		Pk1 sql.NullString `json:"pk1" pk:"1"`
+	}
+	ast.EqualError(CheckType(&badRow{}), "field Pk1: the database/sql null type sql.NullString can not be a primary key field")
+}
+
+func TestNullColumns(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	type row struct {
+		Pk1      string           `json:"pk1" pk:"1"`
+		Pk2      int64            `json:"pk2" pk:"2"`
+		Nickname Null[string]     `json:"nickname"`
+		Age      Null[int32]      `json:"age"`
+		Status   Null[testStatus] `json:"status"`
+	}
+
+	// 持有值的字段写入，未设置和 null 的字段不写入
+	obj := row{Pk1: "a", Pk2: 1, Nickname: NullValue("nick"), Age: NullValue[int32](30), Status: NullColumn[testStatus]()}
+	_, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "nickname", Value: "nick"}, {Key: "age", Value: int64(30)}}, cols)
+	ast.NoError(PutRow(ctx, &obj))
+
+	// 读取时设置值，缺失的列为 null
+	out := row{Pk1: "a", Pk2: 1, Status: NullValue[testStatus]("old")}
+	ast.NoError(GetRow(ctx, &out))
+	nick, ok := out.Nickname.Get()
+	ast.True(ok)
+	ast.Equal("nick", nick)
+	ast.Equal(NullValue[int32](30), out.Age)
+	ast.True(out.Status.IsNull())
+	ast.Equal(NullColumn[testStatus](), out.Status)
+
+	// UpdateRow 删除 null 字段的列，未设置的字段保持不变
+	var req *tablestore.UpdateRowRequest
+	fake.Intercept = func(operation string, request any) error {
+		if operation == "UpdateRow" {
+			req = request.(*tablestore.UpdateRowRequest)
+		}
+		return nil
+	}
+	deleted := make([]string, 1, 4)
+	deleted[0] = "age"
+	ast.NoError(UpdateRow(ctx, &row{Pk1: "a", Pk2: 1, Age: NullColumn[int32](), Status: NullColumn[testStatus]()}, UpdateRowParams{DeletedColumns: deleted}))
+	var columns []string
+	for _, col := range req.UpdateRowChange.Columns {
+		ast.EqualValues(tablestore.DELETE_ALL_VERSION, col.Type)
+		columns = append(columns, col.ColumnName)
+	}
+	ast.Equal([]string{"age", "status"}, columns)
+	// 调用方的 DeletedColumns 不被修改
+	ast.Equal([]string{"age", ""}, deleted[:2])
+
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(NullValue("nick"), out.Nickname)
+	ast.True(out.Age.IsNull())
+
+	// Null 字段不能作为主键
+	type badRow struct {
+		Pk1 Null[string] `json:"pk1" pk:"1"`
+	}
+	ast.EqualError(CheckType(&badRow{}), "field Pk1: a Null field can not be a primary key field")
+}
+
+func TestUUIDFields(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	fake.MustCreateTable("uuids", "id", tablestore.PrimaryKeyType_STRING, "raw", tablestore.PrimaryKeyType_BINARY)
+	o := OtsUtilsParams{Client: fake, TableName: "uuids"}
+	ctx = o.WithContext(ctx)
+
+	type row struct {
+		ID     uuid.UUID  `json:"id" pk:"1"`
+		Raw    uuid.UUID  `json:"raw" pk:"2" otstype:"binary"`
+		Parent *uuid.UUID `json:"parent"`
+		Owner  *uuid.UUID `json:"owner" otstype:"binary"`
+		Empty  uuid.UUID  `json:"empty,omitempty"`
+	}
+
+	// 默认写入 36 字符的字符串，otstype:"binary" 写入 16 字节
+	id := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	raw := uuid.MustParse("123e4567-e89b-12d3-a456-426614174000")
+	parent := uuid.MustParse("f47ac10b-58cc-4372-a567-0e02b2c3d479")
+	obj := row{ID: id, Raw: raw, Parent: &parent, Owner: &id}
+	pks, cols, err := ParseObj(ctx, &obj)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "id", Value: id.String()}, {Key: "raw", Value: raw[:]}}, pks)
+	ast.Equal([]KeyValue{{Key: "parent", Value: parent.String()}, {Key: "owner", Value: id[:]}}, cols)
+
+	ast.NoError(PutRow(ctx, &obj))
+	out := row{ID: id, Raw: raw}
+	ast.NoError(GetRow(ctx, &out))
+	ast.Equal(obj, out)
+
+	// 两种形式都可以读取
+	err = ParseResult(ctx, &out, nil, []KeyValue{{Key: "parent", Value: id[:]}, {Key: "owner", Value: parent.String()}})
+	ast.NoError(err)
+	ast.Equal(id, *out.Parent)
+	ast.Equal(parent, *out.Owner)
+
+	// 格式错误的值报错
+	err = ParseResult(ctx, &out, nil, []KeyValue{{Key: "parent", Value: "not-a-uuid"}})
+	ast.EqualError(err, `column "parent": field Parent: malformed UUID "not-a-uuid": invalid UUID length: 10`)
+	err = ParseResult(ctx, &out, nil, []KeyValue{{Key: "owner", Value: []byte{1, 2, 3}}})
+	ast.EqualError(err, `column "owner": field Owner: malformed UUID: a binary UUID has 16 bytes, got 3`)
+	err = ParseResult(ctx, &out, nil, []KeyValue{{Key: "owner", Value: int64(1)}})
+	ast.EqualError(err, `column "owner": field Owner: a UUID column must be a string or binary, got int64`)
+
+	// 主键列的类型为 STRING，otstype:"binary" 时为 BINARY
+	schema, err := structPrimaryKeySchema(&row{})
+	ast.NoError(err)
+	ast.Equal(tablestore.PrimaryKeyType_STRING, schema[0].Type)
+	ast.Equal(tablestore.PrimaryKeyType_BINARY, schema[1].Type)
+
+	// otstype:"binary" 只能用于 UUID 字段
+	type badRow struct {
+		Pk1  string `json:"pk1" pk:"1"`
+		Name string `json:"name" otstype:"binary"`
+	}
+	ast.EqualError(CheckType(&badRow{}), `field Name: otstype "binary" is only allowed on UUID fields, [16]byte types implementing encoding.TextMarshaler and encoding.TextUnmarshaler, got string`)
+}
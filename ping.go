@@ -0,0 +1,153 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// DefaultPingTimeout is the timeout Ping applies when PingParams.Timeout is not set.
+const DefaultPingTimeout = 3 * time.Second
+
+// PingFailure classifies why a Ping failed.
+type PingFailure int
+
+const (
+	// PingFailureUnknown is any failure not covered by the other kinds, e.g. a missing table.
+	PingFailureUnknown PingFailure = iota
+	// PingFailureConnectivity means the endpoint could not be reached in time.
+	PingFailureConnectivity
+	// PingFailureAuth means the service rejected the credentials.
+	PingFailureAuth
+	// PingFailureThrottled means the service is reachable but busy or out of capacity.
+	PingFailureThrottled
+)
+
+func (f PingFailure) String() string {
+	switch f {
+	case PingFailureConnectivity:
+		return "connectivity"
+	case PingFailureAuth:
+		return "auth"
+	case PingFailureThrottled:
+		return "throttled"
+	default:
+		return "unknown"
+	}
+}
+
+// PingError is returned by Ping when the health check fails.
+type PingError struct {
+	Failure PingFailure
+	Err     error
+}
+
+func (e *PingError) Error() string {
+	return fmt.Sprintf("ping failed (%s): %v", e.Failure, e.Err)
+}
+
+func (e *PingError) Unwrap() error {
+	return e.Err
+}
+
+// Ping verifies connectivity and credentials without side effects.
+// With PingParams.Client it calls ListTable on that client; otherwise it calls
+// DescribeTable on the table stored in the context.
+// Failures are returned as *PingError so a readiness probe can tell
+// "not ready" (connectivity, auth) from "degraded" (throttled).
+//
+// Example usage:
+//
+//	var pingErr *PingError
+//	if err := Ping(ctx); errors.As(err, &pingErr) && pingErr.Failure == PingFailureThrottled {
+//	    // degraded
+//	}
+func Ping(ctx context.Context, params ...PingParams) error {
+	logger := zerolog.Ctx(ctx).With().Str("operation", "Ping").Logger()
+
+	var p PingParams
+	if len(params) > 0 {
+		p = params[0]
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = DefaultPingTimeout
+	}
+
+	var call func() error
+	if p.Client != nil {
+		client := p.Client
+		call = func() error {
+			tableClient, err := clientAs[TableClient](client)
+			if err != nil {
+				return err
+			}
+			_, err = tableClient.ListTable()
+			return err
+		}
+	} else {
+		otsParams, ok := ctx.Value(otsUtilsParamsCtxKey{}).(*OtsUtilsParams)
+		if !ok || otsParams == nil {
+			logger.Panic().Msg("Ping needs PingParams.Client or OtsUtilsParams in the context")
+		}
+		call = func() error {
+			tableClient, err := clientAs[TableClient](otsParams.Client)
+			if err != nil {
+				return err
+			}
+			_, err = tableClient.DescribeTable(&tablestore.DescribeTableRequest{TableName: otsParams.TableName})
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	// The SDK does not support contexts, so the call is abandoned rather than cancelled on timeout.
+	done := make(chan error, 1)
+	go func() {
+		done <- call()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err == nil {
+		return nil
+	}
+
+	pingErr := &PingError{Failure: classifyPingError(err), Err: err}
+	logger.Warn().Err(err).Str("failure", pingErr.Failure.String()).Msg("Ping failed")
+	return pingErr
+}
+
+func classifyPingError(err error) PingFailure {
+	switch Code(err) {
+	case CodeAuthFailed:
+		return PingFailureAuth
+	case CodeServerBusy, CodeStorageServerBusy, CodeNotEnoughCapacityUnit, CodeQuotaExhausted,
+		CodeTableNotReady, CodePartitionUnavailable, CodeServerUnavailable:
+		return PingFailureThrottled
+	case "":
+	default:
+		var otsErr *tablestore.OtsError
+		if errors.As(err, &otsErr) && otsErr.HttpStatusCode == 403 {
+			return PingFailureAuth
+		}
+		return PingFailureUnknown
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || errors.As(err, &netErr) {
+		return PingFailureConnectivity
+	}
+	return PingFailureUnknown
+}
@@ -0,0 +1,375 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+)
+
+// DefaultPipeWorkers is the number of batches Pipe transforms and writes at the same time when
+// PipeOptions.Workers is not set.
+const DefaultPipeWorkers = 4
+
+// RowSource produces the rows of a Pipe. RangeSource, SearchSource and ParallelScanSource read
+// them from a table.
+type RowSource interface {
+	// Rows passes each row to yield, from the goroutine of the call, until the rows run out or
+	// yield returns false, and returns the error that stopped it, nil in both cases. ctx is the
+	// context given to Pipe, with the OtsUtilsParams of the table to read.
+	Rows(ctx context.Context, yield func(obj any) bool) error
+}
+
+// RowSink receives the rows of a Pipe. BatchWriteSink writes them to a table.
+type RowSink interface {
+	// WriteRows writes objs. The result reports each row at its index, as BatchWrite does; a nil
+	// result with an error means none was written. It is called from several goroutines at
+	// once.
+	WriteRows(ctx context.Context, objs []any) (*BatchWriteResult, error)
+}
+
+// rowSourceFunc adapts a function to RowSource.
+type rowSourceFunc func(ctx context.Context, yield func(obj any) bool) error
+
+func (f rowSourceFunc) Rows(ctx context.Context, yield func(obj any) bool) error {
+	return f(ctx, yield)
+}
+
+// RangeSource is a RowSource reading the rows from start to end with RangeRows. The rows are *T.
+func RangeSource[T any](start, end *T, params ...GetRangeParams) RowSource {
+	return rowSourceFunc(func(ctx context.Context, yield func(obj any) bool) error {
+		for row, err := range RangeRows(ctx, start, end, params...) {
+			if err != nil {
+				return err
+			}
+			if !yield(row) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// errSourceStopped stops the SearchAll of a SearchSource or a search RowIterator whose consumer
+// returned.
+var errSourceStopped = errors.New("source stopped")
+
+// SearchSource is a RowSource reading the rows matching query with SearchAll. The rows are T.
+func SearchSource[T any](indexName string, query search.Query, params ...SearchParams) RowSource {
+	return rowSourceFunc(func(ctx context.Context, yield func(obj any) bool) error {
+		_, err := SearchAll(ctx, indexName, query, func(row T) error {
+			if !yield(row) {
+				return errSourceStopped
+			}
+			return nil
+		}, params...)
+		if errors.Is(err, errSourceStopped) {
+			return nil
+		}
+		return err
+	})
+}
+
+// ParallelScanSource is a RowSource reading the whole table with ParallelScan. The rows are T,
+// passed to yield from the goroutine of Rows while the workers of the scan read ahead.
+func ParallelScanSource[T any](opts ParallelScanOptions) RowSource {
+	return rowSourceFunc(func(ctx context.Context, yield func(obj any) bool) error {
+		scanner := StartParallelScan[T](ctx, opts)
+		for row := range scanner.Rows() {
+			if !yield(row) {
+				// The pipe may be cancelled already, the workers must still be waited for
+				return scanner.Close(context.WithoutCancel(ctx))
+			}
+		}
+		return scanner.Wait()
+	})
+}
+
+// BatchWriteSink is a RowSink writing with BatchWrite to the table of Params, or to the table of
+// the context of the Pipe when Params is nil. A row is a BatchOp, or a pointer to a struct put
+// as by BatchPutRows, with Write.RowExistenceExpectation.
+type BatchWriteSink struct {
+	Params *OtsUtilsParams
+	Write  BatchWriteParams
+}
+
+// WriteRows implements RowSink.
+func (s *BatchWriteSink) WriteRows(ctx context.Context, objs []any) (*BatchWriteResult, error) {
+	ops := make([]BatchOp, len(objs))
+	for i, obj := range objs {
+		switch obj := obj.(type) {
+		case BatchOp:
+			ops[i] = obj
+		case *BatchOp:
+			ops[i] = *obj
+		default:
+			ops[i] = BatchOp{Kind: PutOp, Obj: obj}
+			if s.Write.RowExistenceExpectation != nil {
+				ops[i].Params = PutRowParams{RowExistenceExpectation: s.Write.RowExistenceExpectation}
+			}
+		}
+	}
+	if s.Params != nil {
+		ctx = s.Params.WithContext(ctx)
+	}
+	return BatchWrite(ctx, ops, s.Write)
+}
+
+// PipeOptions are the options of a Pipe.
+type PipeOptions struct {
+	// Workers is the number of batches transformed and written at the same time. Defaults to
+	// DefaultPipeWorkers.
+	Workers int
+
+	// BatchSize is the number of rows passed to each RowSink.WriteRows. Defaults to
+	// MaxBatchWriteRows.
+	BatchSize int
+
+	// RowsPerSecond, when set, bounds the rate rows are read from the source.
+	RowsPerSecond int
+
+	// MaxTransformFailures is the number of rows transform may fail before the pipe stops:
+	// the failure reaching it aborts the pipe. Zero aborts on the first failure, and a negative
+	// value never aborts.
+	MaxTransformFailures int
+}
+
+// PipeReport is the outcome of a Pipe.
+type PipeReport struct {
+	// RowsRead is the number of rows the source produced.
+	RowsRead int64
+
+	// RowsWritten is the number of rows the sink wrote.
+	RowsWritten int64
+
+	// RowsSkipped is the number of rows transform dropped.
+	RowsSkipped int64
+
+	// RowsFailed is the number of rows transform failed or the sink rejected.
+	RowsFailed int64
+
+	// Failures lists the failed rows, in no particular order.
+	Failures []PipeFailure
+
+	// ReadCapacity is the capacity consumed by the source, as reported by its responses.
+	ReadCapacity tablestore.ConsumedCapacityUnit
+
+	// WriteCapacity is the capacity consumed by the sink.
+	WriteCapacity tablestore.ConsumedCapacityUnit
+}
+
+// PipeFailure is a row Pipe could not write.
+type PipeFailure struct {
+	// Obj is the row of the source when transform failed, the transformed row otherwise.
+	Obj any
+
+	// Err is the error of transform, or the *RowError the sink reported for the row.
+	Err error
+}
+
+// ErrTooManyTransformFailures is returned by Pipe when transform failed
+// PipeOptions.MaxTransformFailures times.
+var ErrTooManyTransformFailures = errors.New("too many transform failures")
+
+// Pipe reads the rows of src, passes each to transform and writes what it returns to dst, and
+// reports what was moved. transform returns the row to write and true, or false to skip the row;
+// a nil transform writes the rows as read. The rows are gathered in batches of BatchSize, each
+// transformed and written by one of Workers goroutines, so the rows of different batches are
+// written in no particular order.
+//
+// ctx carries the OtsUtilsParams of the table src reads. Rows transform fails or dst rejects
+// are listed in PipeReport.Failures and do not stop the pipe until MaxTransformFailures
+// transforms failed. A source error, a batch dst fails as a whole or a cancelled ctx stops it;
+// the report returned with the error describes the rows moved before.
+//
+// Example usage:
+//
+//	// Copy the active users to the table users_v2, renaming a column
+//	report, err := Pipe(ctx, RangeSource[User](nil, nil), func(obj any) (any, bool, error) {
+//	    u := obj.(*User)
+//	    if !u.Active {
+//	        return nil, false, nil
+//	    }
+//	    return &UserV2{ID: u.ID, DisplayName: u.Name}, true, nil
+//	}, &BatchWriteSink{Params: usersV2}, PipeOptions{RowsPerSecond: 1000})
+func Pipe(ctx context.Context, src RowSource, transform func(obj any) (any, bool, error), dst RowSink, opts PipeOptions) (PipeReport, error) {
+	var report PipeReport
+	if src == nil || dst == nil {
+		return report, fmt.Errorf("src and dst can not be nil")
+	}
+	if opts.Workers < 0 {
+		return report, fmt.Errorf("Workers must not be negative, got %d", opts.Workers)
+	}
+	workers := opts.Workers
+	if workers == 0 {
+		workers = DefaultPipeWorkers
+	}
+	if opts.BatchSize < 0 {
+		return report, fmt.Errorf("BatchSize must not be negative, got %d", opts.BatchSize)
+	}
+	batchSize := opts.BatchSize
+	if batchSize == 0 {
+		batchSize = MaxBatchWriteRows
+	}
+	if opts.RowsPerSecond < 0 {
+		return report, fmt.Errorf("RowsPerSecond must not be negative, got %d", opts.RowsPerSecond)
+	}
+	if transform == nil {
+		transform = func(obj any) (any, bool, error) { return obj, true, nil }
+	}
+
+	pipeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var errs []error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// Once cancelled, the other workers only report the cancellation
+		if pipeCtx.Err() == nil || !errors.Is(err, pipeCtx.Err()) {
+			errs = append(errs, err)
+		}
+		cancel()
+	}
+
+	// The read capacity is counted by a hook on the calls of the source
+	srcParams := *otsUtilsParamsFromCtx(ctx)
+	srcParams.MetricsHook = &capacityHook{next: srcParams.MetricsHook, mu: &mu, total: &report.ReadCapacity}
+	srcCtx := srcParams.WithContext(pipeCtx)
+
+	transformFailures := 0
+	writeBatch := func(rows []any) error {
+		var outs []any
+		for _, row := range rows {
+			out, keep, err := transform(row)
+			mu.Lock()
+			switch {
+			case err != nil:
+				report.RowsFailed++
+				report.Failures = append(report.Failures, PipeFailure{Obj: row, Err: err})
+				transformFailures++
+				if opts.MaxTransformFailures >= 0 && transformFailures >= max(opts.MaxTransformFailures, 1) {
+					mu.Unlock()
+					return fmt.Errorf("%w: %d, the last: %w", ErrTooManyTransformFailures, transformFailures, err)
+				}
+			case !keep:
+				report.RowsSkipped++
+			default:
+				outs = append(outs, out)
+			}
+			mu.Unlock()
+		}
+		if len(outs) == 0 {
+			return nil
+		}
+
+		result, err := dst.WriteRows(pipeCtx, outs)
+		if result == nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		addCapacity(&report.WriteCapacity, &result.ConsumedCapacity)
+		var batchErr error
+		for i, op := range result.Ops {
+			var rowErr *RowError
+			switch {
+			case op.Err == nil:
+				report.RowsWritten++
+			case errors.As(op.Err, &rowErr):
+				report.RowsFailed++
+				report.Failures = append(report.Failures, PipeFailure{Obj: outs[i], Err: op.Err})
+			case batchErr == nil:
+				// The request failed as a whole
+				batchErr = op.Err
+			}
+		}
+		return batchErr
+	}
+
+	batches := make(chan []any)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rows := range batches {
+				if err := writeBatch(rows); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+
+	var next time.Time
+	var batch []any
+	send := func() bool {
+		select {
+		case batches <- batch:
+			batch = nil
+			return true
+		case <-pipeCtx.Done():
+			return false
+		}
+	}
+	err := src.Rows(srcCtx, func(obj any) bool {
+		if opts.RowsPerSecond > 0 {
+			if wait := time.Until(next); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-pipeCtx.Done():
+					timer.Stop()
+					return false
+				case <-timer.C:
+				}
+			}
+			if now := time.Now(); next.Before(now) {
+				next = now
+			}
+			next = next.Add(time.Second / time.Duration(opts.RowsPerSecond))
+		}
+		mu.Lock()
+		report.RowsRead++
+		mu.Unlock()
+		batch = append(batch, obj)
+		return len(batch) < batchSize || send()
+	})
+	if err != nil {
+		fail(err)
+	} else if len(batch) > 0 {
+		send()
+	}
+	close(batches)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return report, errors.Join(errs...)
+	}
+	return report, ctx.Err()
+}
+
+// capacityHook is a MetricsHook adding the capacity of each call to total, guarded by mu, before
+// passing the call on to next.
+type capacityHook struct {
+	next  MetricsHook
+	mu    *sync.Mutex
+	total *tablestore.ConsumedCapacityUnit
+}
+
+func (h *capacityHook) RecordOperation(ctx context.Context, m OperationMetrics) {
+	if m.ConsumedCapacity != nil {
+		h.mu.Lock()
+		addCapacity(h.total, m.ConsumedCapacity)
+		h.mu.Unlock()
+	}
+	if h.next != nil {
+		h.next.RecordOperation(ctx, m)
+	}
+}
@@ -0,0 +1,168 @@
+package otsutils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipe(t *testing.T) {
+	// evenRows 跳过 Col2 为奇数的行，Col2 为 4 的行转换失败
+	evenRows := func(obj any) (any, bool, error) {
+		row := obj.(*TestRow)
+		switch {
+		case *row.Col2 == 4:
+			return nil, false, errors.New("bad row")
+		case *row.Col2%2 == 1:
+			return nil, false, nil
+		}
+		out := *row
+		out.Col1 = tea.String("piped")
+		return &out, true, nil
+	}
+
+	t.Run("range source to batch sink", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		rows := batchRows("u1", 10)
+		ast.NoError(BatchPutRows(ctx, &rows))
+		dst, dstFake := newCopyDestination(t)
+
+		report, err := Pipe(ctx, RangeSource[TestRow](nil, nil), evenRows, &BatchWriteSink{Params: dst}, PipeOptions{
+			BatchSize:            3,
+			MaxTransformFailures: -1,
+		})
+		ast.NoError(err)
+		ast.Equal(int64(10), report.RowsRead)
+		ast.Equal(int64(4), report.RowsWritten)
+		ast.Equal(int64(5), report.RowsSkipped)
+		ast.Equal(int64(1), report.RowsFailed)
+		if ast.Len(report.Failures, 1) {
+			ast.Equal(int64(4), *report.Failures[0].Obj.(*TestRow).Col2)
+		}
+		ast.Equal(tablestore.ConsumedCapacityUnit{Read: 10}, report.ReadCapacity)
+		ast.Equal(tablestore.ConsumedCapacityUnit{Write: 4}, report.WriteCapacity)
+		// 10 行分为 4 批，每批写入一次，全部被跳过的批次除外
+		ast.LessOrEqual(dstFake.CallCount("BatchWriteRow"), 4)
+
+		var got []TestRow
+		ast.NoError(GetRange(dst.WithContext(ctx), &TestRow{}, &TestRow{}, &got))
+		if ast.Len(got, 4) {
+			ast.Equal("piped", *got[0].Col1)
+			ast.Equal(int64(0), *got[0].Col2)
+		}
+	})
+
+	t.Run("transform failures abort", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		rows := batchRows("u1", 10)
+		ast.NoError(BatchPutRows(ctx, &rows))
+		dst, _ := newCopyDestination(t)
+
+		failing := func(obj any) (any, bool, error) {
+			if *obj.(*TestRow).Col2 >= 2 {
+				return nil, false, errors.New("bad row")
+			}
+			return obj, true, nil
+		}
+		report, err := Pipe(ctx, RangeSource[TestRow](nil, nil), failing, &BatchWriteSink{Params: dst}, PipeOptions{
+			Workers:              1,
+			BatchSize:            1,
+			MaxTransformFailures: 3,
+		})
+		ast.ErrorIs(err, ErrTooManyTransformFailures)
+		ast.Equal(int64(2), report.RowsWritten)
+		ast.Equal(int64(3), report.RowsFailed)
+		ast.Less(report.RowsRead, int64(10))
+	})
+
+	t.Run("rejected rows are reported", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		rows := batchRows("u1", 5)
+		ast.NoError(BatchPutRows(ctx, &rows))
+		dst, dstFake := newCopyDestination(t)
+		ast.NoError(PutRow(dst.WithContext(ctx), &TestRow{Pk1: tea.String("u1"), Pk2: tea.Int64(2)}))
+		ast.Equal(1, dstFake.CallCount("PutRow"))
+
+		// 默认 EXPECT_NOT_EXIST，已存在的行被拒绝
+		report, err := Pipe(ctx, RangeSource[TestRow](nil, nil), nil, &BatchWriteSink{Params: dst}, PipeOptions{})
+		ast.NoError(err)
+		ast.Equal(int64(4), report.RowsWritten)
+		ast.Equal(int64(1), report.RowsFailed)
+		if ast.Len(report.Failures, 1) {
+			ast.True(IsConditionCheckFail(report.Failures[0].Err))
+			ast.Equal(int64(2), *report.Failures[0].Obj.(*TestRow).Pk2)
+		}
+	})
+
+	t.Run("parallel scan source", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		rows := batchRows("u1", MaxBatchWriteRows+20)
+		ast.NoError(BatchPutRows(ctx, &rows))
+		dst, _ := newCopyDestination(t)
+
+		report, err := Pipe(ctx, ParallelScanSource[*TestRow](ParallelScanOptions{}), nil, &BatchWriteSink{Params: dst}, PipeOptions{Workers: 2})
+		ast.NoError(err)
+		ast.Equal(int64(MaxBatchWriteRows+20), report.RowsRead)
+		ast.Equal(int64(MaxBatchWriteRows+20), report.RowsWritten)
+		ast.Positive(report.ReadCapacity.Read)
+
+		count, err := CountRange(dst.WithContext(ctx), &TestRow{}, &TestRow{})
+		ast.NoError(err)
+		ast.Equal(int64(MaxBatchWriteRows+20), count.Count)
+	})
+
+	t.Run("search source", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSearchContext(t)
+		dst, _ := newCopyDestination(t)
+
+		query := &search.TermQuery{FieldName: "city", Term: "hz"}
+		report, err := Pipe(ctx, SearchSource[*SearchRow]("search_index", query), nil, &BatchWriteSink{Params: dst}, PipeOptions{})
+		ast.NoError(err)
+		ast.Equal(int64(3), report.RowsRead)
+		ast.Equal(int64(3), report.RowsWritten)
+		ast.Equal(tablestore.ConsumedCapacityUnit{Read: 1}, report.ReadCapacity)
+	})
+
+	t.Run("source errors stop the pipe", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		rows := batchRows("u1", 10)
+		ast.NoError(BatchPutRows(ctx, &rows))
+		dst, _ := newCopyDestination(t)
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "GetRange" {
+				return &tablestore.OtsError{Code: CodeParameterInvalid, Message: "bad range"}
+			}
+			return nil
+		}
+
+		report, err := Pipe(ctx, RangeSource[TestRow](nil, nil), nil, &BatchWriteSink{Params: dst}, PipeOptions{})
+		ast.Equal(CodeParameterInvalid, Code(err))
+		ast.Zero(report.RowsRead)
+	})
+
+	t.Run("options are checked", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+		dst, _ := newCopyDestination(t)
+		src, sink := RangeSource[TestRow](nil, nil), &BatchWriteSink{Params: dst}
+
+		_, err := Pipe(ctx, nil, nil, sink, PipeOptions{})
+		ast.Error(err)
+		_, err = Pipe(ctx, src, nil, sink, PipeOptions{Workers: -1})
+		ast.Error(err)
+		_, err = Pipe(ctx, src, nil, sink, PipeOptions{BatchSize: -1})
+		ast.Error(err)
+		_, err = Pipe(ctx, src, nil, sink, PipeOptions{RowsPerSecond: -1})
+		ast.Error(err)
+	})
+}
@@ -0,0 +1,125 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// PrimaryKeyBuilder builds an ordered primary key from loose values, for the map-based
+// operations and range boundaries, without declaring a row struct.
+// The first error, such as a duplicate column name, is kept and returned by Build.
+type PrimaryKeyBuilder struct {
+	kvs []KeyValue
+	err error
+}
+
+// PK returns an empty PrimaryKeyBuilder. Columns must be added in schema order.
+//
+// Example usage:
+//
+//	pks, err := PK().String("pk1", "user1").Int64("pk2", 42).Build()
+//	cols, err := GetRowMap(ctx, pks)
+func PK() *PrimaryKeyBuilder {
+	return &PrimaryKeyBuilder{}
+}
+
+// String appends a string primary key column.
+func (b *PrimaryKeyBuilder) String(name string, value string) *PrimaryKeyBuilder {
+	return b.add(name, value)
+}
+
+// Int64 appends an integer primary key column.
+func (b *PrimaryKeyBuilder) Int64(name string, value int64) *PrimaryKeyBuilder {
+	return b.add(name, value)
+}
+
+// Bytes appends a binary primary key column.
+func (b *PrimaryKeyBuilder) Bytes(name string, value []byte) *PrimaryKeyBuilder {
+	return b.add(name, value)
+}
+
+func (b *PrimaryKeyBuilder) add(name string, value any) *PrimaryKeyBuilder {
+	if b.err != nil {
+		return b
+	}
+	if name == "" {
+		b.err = fmt.Errorf("primary key at index %d has an empty name", len(b.kvs))
+		return b
+	}
+	for _, kv := range b.kvs {
+		if kv.Key == name {
+			b.err = fmt.Errorf("primary key %q is added twice", name)
+			return b
+		}
+	}
+	b.kvs = append(b.kvs, KeyValue{Key: name, Value: value})
+	return b
+}
+
+// Build returns the primary key columns in the order they were added.
+func (b *PrimaryKeyBuilder) Build() ([]KeyValue, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if err := validateRowLimits(b.kvs, nil); err != nil {
+		return nil, err
+	}
+	return append([]KeyValue(nil), b.kvs...), nil
+}
+
+// FromStruct returns a builder holding the non-nil primary key fields of the row struct obj,
+// in pk order.
+func FromStruct(obj any) *PrimaryKeyBuilder {
+	b := PK()
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		b.err = fmt.Errorf("obj must be a non-nil pointer to struct, got %T", obj)
+		return b
+	}
+	v = v.Elem()
+
+	meta, err := getStructMeta(v.Type())
+	if err != nil {
+		b.err = err
+		return b
+	}
+	for _, i := range meta.pkFields {
+		fm := meta.fields[i]
+		value, skip, err := fm.value(fm.field(v))
+		if err != nil {
+			b.err = err
+			return b
+		}
+		if skip {
+			continue
+		}
+		b.add(fm.column, value)
+	}
+	return b
+}
+
+// ApplyToStruct sets the primary key fields of the row struct obj from the builder.
+// Every column must match a pk field of obj; other fields are left untouched.
+func (b *PrimaryKeyBuilder) ApplyToStruct(obj any) error {
+	kvs, err := b.Build()
+	if err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("obj must be a non-nil pointer to struct, got %T", obj)
+	}
+	meta, err := getStructMeta(v.Elem().Type())
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		if !meta.hasPkColumn(kv.Key) {
+			return fmt.Errorf("%s has no pk-tagged field for column %q", v.Elem().Type(), kv.Key)
+		}
+	}
+	return ParseResult(context.Background(), obj, kvs, nil)
+}
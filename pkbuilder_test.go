@@ -0,0 +1,76 @@
+package otsutils
+
+import (
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrimaryKeyBuilder(t *testing.T) {
+	ast := assert.New(t)
+
+	pks, err := PK().String("pk1", "user1").Int64("pk2", 42).Bytes("pk3", []byte{1}).Build()
+	ast.NoError(err)
+	ast.Equal([]KeyValue{
+		{Key: "pk1", Value: "user1"},
+		{Key: "pk2", Value: int64(42)},
+		{Key: "pk3", Value: []byte{1}},
+	}, pks)
+
+	_, err = PK().String("pk1", "a").Int64("pk1", 1).Build()
+	ast.EqualError(err, `primary key "pk1" is added twice`)
+
+	_, err = PK().String("", "a").Build()
+	ast.EqualError(err, "primary key at index 0 has an empty name")
+
+	_, err = PK().String("a", "").String("b", "").String("c", "").String("d", "").String("e", "").Build()
+	ast.EqualError(err, "row has 5 primary key columns a, b, c, d, e, the maximum is 4")
+}
+
+func TestPrimaryKeyBuilderStructBridge(t *testing.T) {
+	ast := assert.New(t)
+
+	// FromStruct 按 pk 顺序取出非 nil 的主键
+	pks, err := FromStruct(&RangeRow{Pk2: tea.Int64(2), Pk1: tea.String("u1"), Col1: tea.String("c")}).Build()
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "pk1", Value: "u1"}, {Key: "pk2", Value: int64(2)}}, pks)
+
+	_, err = FromStruct(RangeRow{}).Build()
+	ast.ErrorContains(err, "obj must be a non-nil pointer to struct")
+
+	// ApplyToStruct 只设置主键字段
+	row := RangeRow{Col1: tea.String("keep")}
+	ast.NoError(PK().String("pk1", "u2").Int64("pk2", 3).ApplyToStruct(&row))
+	ast.Equal("u2", tea.StringValue(row.Pk1))
+	ast.Equal(int64(3), tea.Int64Value(row.Pk2))
+	ast.Equal("keep", tea.StringValue(row.Col1))
+
+	ast.EqualError(PK().String("col1", "x").ApplyToStruct(&row), `otsutils.RangeRow has no pk-tagged field for column "col1"`)
+	ast.ErrorContains(PK().String("pk1", "x").Int64("pk1", 1).ApplyToStruct(&row), "added twice")
+}
+
+func TestPrimaryKeyBuilderWithOperations(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	for _, partition := range []string{"u0", "u1"} {
+		for i := int64(0); i < 3; i++ {
+			ast.NoError(PutRow(ctx, &RangeRow{Pk1: tea.String(partition), Pk2: tea.Int64(i), Col1: tea.String(partition)}))
+		}
+	}
+
+	pks, err := PK().String("pk1", "u1").Int64("pk2", 2).Build()
+	ast.NoError(err)
+	cols, err := GetRowMap(ctx, pks)
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "col1", Value: "u1"}}, cols)
+
+	// 主键前缀作为范围边界
+	var rows []RangeRow
+	ast.NoError(GetRange(ctx, PK().String("pk1", "u1"), PK().String("pk1", "u1"), &rows))
+	ast.Len(rows, 3)
+
+	err = GetRange(ctx, PK().String("col1", "x"), PK(), &rows)
+	ast.ErrorContains(err, `start: otsutils.RangeRow has no pk-tagged field for column "col1"`)
+}
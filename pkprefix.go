@@ -0,0 +1,68 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/117503445/otsutils/internal/rowrules"
+)
+
+// pkPrefix is the parsed form of a pkprefix struct tag, which spreads the rows of a string
+// primary key column across partitions by storing each value behind a hash prefix.
+//
+// The grammar is `pkprefix:"<hash>:<length>[,migrate]"`:
+//   - hash is md5, sha1 or sha256
+//   - length is the number of hex characters of the hash kept as the prefix
+//   - migrate also accepts values stored without the prefix on read, for tables that are
+//     being moved to prefixed keys; such values are read as is
+//
+// A value "real_id" with `pkprefix:"md5:4"` is stored as "<4 hex chars of md5(real_id)>|real_id".
+// ParseObj adds the prefix and ParseResult strips it, so structs, GetRange boundaries and
+// PrimaryKeyBuilder.ApplyToStruct always deal with the logical value, while PrimaryKeyBuilder
+// values and the map APIs deal with the stored one.
+type pkPrefix struct {
+	hash    string
+	length  int
+	migrate bool
+}
+
+// pkPrefixSeparator separates the hash prefix from the logical value.
+const pkPrefixSeparator = "|"
+
+// parsePkPrefix parses the value of a pkprefix struct tag.
+func parsePkPrefix(tag string) (*pkPrefix, error) {
+	parsed, err := rowrules.ParsePkPrefix(tag)
+	if err != nil {
+		return nil, err
+	}
+	return &pkPrefix{hash: parsed.Hash, length: parsed.Length, migrate: parsed.Migrate}, nil
+}
+
+func (p *pkPrefix) String() string {
+	return p.hash + ":" + strconv.Itoa(p.length)
+}
+
+// prefix returns the hash prefix of the logical value s.
+func (p *pkPrefix) prefix(s string) string {
+	return rowrules.PrefixHashes[p.hash](s)[:p.length]
+}
+
+// apply returns the stored form of the logical value s.
+func (p *pkPrefix) apply(s string) string {
+	return p.prefix(s) + pkPrefixSeparator + s
+}
+
+// strip returns the logical value of the stored value s. Without the migrate option, s must
+// carry the prefix of its logical value.
+func (p *pkPrefix) strip(s string) (string, error) {
+	if len(s) > p.length && s[p.length:p.length+len(pkPrefixSeparator)] == pkPrefixSeparator {
+		if logical := s[p.length+len(pkPrefixSeparator):]; s[:p.length] == p.prefix(logical) {
+			return logical, nil
+		}
+	}
+	if p.migrate {
+		return s, nil
+	}
+	return "", fmt.Errorf("value %q does not carry its %s prefix", s, p)
+}
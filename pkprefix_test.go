@@ -0,0 +1,143 @@
+package otsutils
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+type prefixedRow struct {
+	Pk1  *string `json:"pk1" pk:"1" pkprefix:"md5:4"`
+	Pk2  *int64  `json:"pk2" pk:"2"`
+	Col1 *string `json:"col1"`
+}
+
+type migratingRow struct {
+	Pk1  *string `json:"pk1" pk:"1" pkprefix:"md5:4,migrate"`
+	Pk2  *int64  `json:"pk2" pk:"2"`
+	Col1 *string `json:"col1"`
+}
+
+func md5Prefix(s string, n int) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])[:n] + "|" + s
+}
+
+func TestParsePkPrefix(t *testing.T) {
+	ast := assert.New(t)
+
+	p, err := parsePkPrefix("sha256:8,migrate")
+	ast.NoError(err)
+	ast.Equal(&pkPrefix{hash: "sha256", length: 8, migrate: true}, p)
+
+	_, err = parsePkPrefix("md5")
+	ast.EqualError(err, `want <hash>:<length>, got "md5"`)
+	_, err = parsePkPrefix("crc32:4")
+	ast.EqualError(err, `unknown hash "crc32", supported are md5, sha1, sha256`)
+	_, err = parsePkPrefix("md5:33")
+	ast.EqualError(err, `length "33" must be an integer between 1 and 32`)
+	_, err = parsePkPrefix("md5:4,lenient")
+	ast.EqualError(err, `unknown option "lenient"`)
+
+	// 不同表可以使用不同的哈希与长度
+	p, _ = parsePkPrefix("sha1:2")
+	ast.Len(p.apply("id"), len("xx|id"))
+}
+
+func TestPkPrefixTagValidation(t *testing.T) {
+	type attrPrefix struct {
+		Pk1  *string `json:"pk1" pk:"1"`
+		Col1 *string `json:"col1" pkprefix:"md5:4"`
+	}
+	assert.EqualError(t, CheckType(&attrPrefix{}), "field Col1: pkprefix is only allowed on primary key fields")
+
+	type intPrefix struct {
+		Pk1 *int64 `json:"pk1" pk:"1" pkprefix:"md5:4"`
+	}
+	assert.EqualError(t, CheckType(&intPrefix{}), "field Pk1: pkprefix is only allowed on *string fields, got *int64")
+
+	type badPrefix struct {
+		Pk1 *string `json:"pk1" pk:"1" pkprefix:"md5:0"`
+	}
+	assert.EqualError(t, CheckType(&badPrefix{}), `field Pk1: invalid pkprefix tag "md5:0": length "0" must be an integer between 1 and 32`)
+}
+
+func TestPkPrefixRoundTrip(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	// 写入时加前缀，存储的是带前缀的值
+	pks, cols, err := ParseObj(ctx, &prefixedRow{Pk1: tea.String("real_id"), Pk2: tea.Int64(1), Col1: tea.String("v")})
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "pk1", Value: md5Prefix("real_id", 4)}, {Key: "pk2", Value: int64(1)}}, pks)
+	ast.Equal([]KeyValue{{Key: "col1", Value: "v"}}, cols)
+
+	ast.NoError(PutRow(ctx, &prefixedRow{Pk1: tea.String("real_id"), Pk2: tea.Int64(1), Col1: tea.String("v")}))
+	stored, err := GetRowMap(ctx, []KeyValue{{Key: "pk1", Value: md5Prefix("real_id", 4)}, {Key: "pk2", Value: int64(1)}})
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "col1", Value: "v"}}, stored)
+
+	// 读取时去掉前缀
+	row := prefixedRow{Pk1: tea.String("real_id"), Pk2: tea.Int64(1)}
+	ast.NoError(GetRow(ctx, &row))
+	ast.Equal("real_id", tea.StringValue(row.Pk1))
+	ast.Equal("v", tea.StringValue(row.Col1))
+
+	// 范围查询对逻辑键计算相同的前缀
+	ast.NoError(PutRow(ctx, &prefixedRow{Pk1: tea.String("real_id"), Pk2: tea.Int64(2)}))
+	ast.NoError(PutRow(ctx, &prefixedRow{Pk1: tea.String("other_id"), Pk2: tea.Int64(1)}))
+	var rows []prefixedRow
+	ast.NoError(GetRange(ctx, &prefixedRow{Pk1: tea.String("real_id")}, &prefixedRow{Pk1: tea.String("real_id")}, &rows))
+	ast.Len(rows, 2)
+	for _, r := range rows {
+		ast.Equal("real_id", tea.StringValue(r.Pk1))
+	}
+
+	// PrimaryKeyBuilder 持有存储形式的值
+	b := FromStruct(&prefixedRow{Pk1: tea.String("real_id"), Pk2: tea.Int64(1)})
+	kvs, err := b.Build()
+	ast.NoError(err)
+	ast.Equal(md5Prefix("real_id", 4), kvs[0].Value)
+	var back prefixedRow
+	ast.NoError(b.ApplyToStruct(&back))
+	ast.Equal("real_id", tea.StringValue(back.Pk1))
+}
+
+func TestPkPrefixMigration(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	// 迁移前写入的原始值
+	change := &tablestore.PutRowChange{TableName: "test_table", PrimaryKey: &tablestore.PrimaryKey{}}
+	change.PrimaryKey.AddPrimaryKeyColumn("pk1", "legacy_id")
+	change.PrimaryKey.AddPrimaryKeyColumn("pk2", int64(1))
+	change.AddColumn("col1", "old")
+	change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+	_, err := fake.PutRow(&tablestore.PutRowRequest{PutRowChange: change})
+	ast.NoError(err)
+	ast.NoError(PutRow(ctx, &migratingRow{Pk1: tea.String("new_id"), Pk2: tea.Int64(1), Col1: tea.String("new")}))
+
+	// 严格模式下原始值报错，并指出列名
+	var strict []prefixedRow
+	err = GetRange(ctx, &prefixedRow{}, &prefixedRow{}, &strict)
+	ast.ErrorContains(err, `primary key "pk1": value "legacy_id" does not carry its md5:4 prefix`)
+
+	// 迁移模式两种形式都接受
+	var rows []migratingRow
+	ast.NoError(GetRange(ctx, &migratingRow{}, &migratingRow{}, &rows))
+	got := map[string]string{}
+	for _, r := range rows {
+		got[tea.StringValue(r.Pk1)] = tea.StringValue(r.Col1)
+	}
+	ast.Equal(map[string]string{"legacy_id": "old", "new_id": "new"}, got)
+
+	// 看起来像前缀但哈希不符的值按原样读取
+	var r migratingRow
+	ast.NoError(ParseResult(context.Background(), &r, []KeyValue{{Key: "pk1", Value: "zzzz|x"}}, nil))
+	ast.Equal("zzzz|x", tea.StringValue(r.Pk1))
+}
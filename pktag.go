@@ -0,0 +1,143 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/117503445/otsutils/internal/rowrules"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// pkTag is the parsed form of a pk struct tag.
+//
+// The grammar is `pk:"<order>[,auto|,gen=ulid]"`:
+//   - order is the 1-based position of the column in the table's primary key
+//   - auto marks an auto-increment column; only allowed on the last pk field, of type *int64
+//   - gen=ulid generates a ULID on PutRow when the field is nil; only allowed on *string fields
+type pkTag struct {
+	order int
+	auto  bool
+	gen   string
+}
+
+// parsePkTag parses the value of a pk struct tag.
+func parsePkTag(tag string) (pkTag, error) {
+	parsed, err := rowrules.ParsePkTag(tag)
+	if err != nil {
+		return pkTag{}, err
+	}
+	return pkTag{order: parsed.Order, auto: parsed.Auto, gen: parsed.Gen}, nil
+}
+
+// generatePkValues fills the nil primary key fields of the row struct obj that carry a gen option,
+// so that the generated ids are visible to the caller after the write.
+func generatePkValues(obj any) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		// ParseObj reports the error
+		return nil
+	}
+	v = v.Elem()
+
+	meta, err := getStructMeta(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, i := range meta.pkFields {
+		fm := meta.fields[i]
+		if fm.pk.gen == "" {
+			continue
+		}
+		field := fm.settableField(v)
+		if !field.IsNil() || !field.CanSet() {
+			continue
+		}
+
+		id, err := newULID()
+		if err != nil {
+			return fmt.Errorf("field %s: %w", fm.name, err)
+		}
+		newVal := reflect.New(field.Type().Elem())
+		newVal.Elem().SetString(id)
+		field.Set(newVal)
+	}
+	return nil
+}
+
+// pendingAutoIncrement returns the nil auto-increment primary key field of the row struct obj
+// and its column, whose value the service assigns on PutRow. field is invalid when there is none.
+func pendingAutoIncrement(obj any) (field reflect.Value, column string, err error) {
+	if _, ok := obj.(*rowKeyValues); ok {
+		return reflect.Value{}, "", nil
+	}
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, "", nil
+	}
+	v = v.Elem()
+
+	meta, err := getStructMeta(v.Type())
+	if err != nil {
+		return reflect.Value{}, "", err
+	}
+	for _, i := range meta.pkFields {
+		fm := meta.fields[i]
+		if !fm.pk.auto {
+			continue
+		}
+		if field := fm.settableField(v); field.IsNil() {
+			return field, fm.column, nil
+		}
+	}
+	return reflect.Value{}, "", nil
+}
+
+// assignAutoIncrement writes the value the service assigned to the auto-increment primary key
+// column back into the nil auto field of the row struct obj. pk is the primary key returned by
+// the write.
+func assignAutoIncrement(obj any, pk *tablestore.PrimaryKey) error {
+	field, column, err := pendingAutoIncrement(obj)
+	if err != nil || !field.IsValid() {
+		return err
+	}
+
+	for _, col := range pk.PrimaryKeys {
+		if col.ColumnName != column {
+			continue
+		}
+		value, ok := col.Value.(int64)
+		if !ok {
+			return fmt.Errorf("primary key %q: expected the assigned int64 value, got %T", column, col.Value)
+		}
+		field.Set(reflect.ValueOf(&value))
+		return nil
+	}
+	return fmt.Errorf("primary key %q was not returned by the service", column)
+}
+
+// crockfordAlphabet is the Crockford base32 alphabet used by ULIDs.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a new ULID: a 48-bit millisecond timestamp followed by 80 random bits,
+// encoded as 26 Crockford base32 characters so that ids sort by creation time.
+func newULID() (string, error) {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	if _, err := rand.Read(b[6:]); err != nil {
+		return "", fmt.Errorf("generate ulid: %w", err)
+	}
+
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out), nil
+}
@@ -0,0 +1,222 @@
+// Package quick is a map-based facade over otsutils for scripts and REPL-style usage.
+//
+// It needs no row structs and no context plumbing: every call runs with a timeout of
+// DB.Timeout, and rows are plain maps. The primary key columns of a map are put in schema
+// order using the table metadata, so pks maps can be written in any order. Integer values
+// may be given as int or int32; they are stored as int64.
+//
+// Example usage:
+//
+//	db := quick.Connect(endpoint, instance, ak, sk)
+//	users := db.Table("users")
+//	err := users.Put(map[string]any{"id": "u1"}, map[string]any{"name": "Alice", "age": 30})
+//	row, err := users.Get(map[string]any{"id": "u1"})
+//	fmt.Println(row) // {age: 30, id: "u1", name: "Alice"}
+package quick
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/117503445/otsutils"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// DefaultTimeout is the timeout of each call when DB.Timeout is not set.
+const DefaultTimeout = 10 * time.Second
+
+// DB is a handle on a Tablestore instance.
+type DB struct {
+	client otsutils.OtsClient
+
+	// Timeout bounds each call. Defaults to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Connect returns a DB for the instance. Like otsutils.NewClient, it panics when any
+// argument is empty.
+func Connect(endpoint, instance, accessKeyId, accessKeySecret string) *DB {
+	return New(otsutils.NewClient(context.Background(), endpoint, instance, accessKeyId, accessKeySecret))
+}
+
+// New returns a DB using an existing client, such as an otsfake.Client in tests.
+func New(client otsutils.OtsClient) *DB {
+	return &DB{client: client}
+}
+
+// Table returns a handle on the table. It does not contact the service.
+func (db *DB) Table(name string) *Table {
+	return &Table{db: db, params: &otsutils.OtsUtilsParams{Client: db.client, TableName: name}}
+}
+
+// Table is a handle on one table of a DB.
+type Table struct {
+	db     *DB
+	params *otsutils.OtsUtilsParams
+}
+
+// context returns the context of one call.
+func (t *Table) context() (context.Context, context.CancelFunc) {
+	timeout := t.db.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	return t.params.WithContext(ctx), cancel
+}
+
+// Row is a row read by Get or Scan: the primary key and attribute columns by name.
+type Row map[string]any
+
+// String formats the row with sorted column names, quoted strings and hex binary values.
+func (r Row) String() string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, key := range slices.Sorted(maps.Keys(r)) {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		switch v := r[key].(type) {
+		case string:
+			fmt.Fprintf(&b, "%s: %q", key, v)
+		case []byte:
+			fmt.Fprintf(&b, "%s: 0x%x", key, v)
+		default:
+			fmt.Fprintf(&b, "%s: %v", key, v)
+		}
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Get returns the row with the primary key pks, or nil when it does not exist.
+func (t *Table) Get(pks map[string]any) (Row, error) {
+	ctx, cancel := t.context()
+	defer cancel()
+
+	kvs, err := primaryKey(ctx, pks, false)
+	if err != nil {
+		return nil, err
+	}
+	row, err := otsutils.GetRowToMap(ctx, kvs, otsutils.GetRowParams{IncludePrimaryKey: true})
+	if err != nil || row == nil {
+		return nil, err
+	}
+	return Row(row), nil
+}
+
+// Put writes the row with the primary key pks and the attribute columns cols, replacing the
+// row if it exists.
+func (t *Table) Put(pks, cols map[string]any) error {
+	ctx, cancel := t.context()
+	defer cancel()
+
+	kvs, err := primaryKey(ctx, pks, false)
+	if err != nil {
+		return err
+	}
+	colKvs := make([]otsutils.KeyValue, 0, len(cols))
+	for _, name := range slices.Sorted(maps.Keys(cols)) {
+		colKvs = append(colKvs, otsutils.KeyValue{Key: name, Value: normalize(cols[name])})
+	}
+	ignore := tablestore.RowExistenceExpectation_IGNORE
+	return otsutils.PutRowMap(ctx, kvs, colKvs, otsutils.PutRowParams{RowExistenceExpectation: &ignore})
+}
+
+// Scan returns the rows from start (inclusive) to end (exclusive), at most limit rows when
+// limit is positive. start and end name a prefix of the primary key: the columns they leave
+// out span the whole range, so nil scans the whole table and equal partition keys scan
+// the partition.
+func (t *Table) Scan(start, end map[string]any, limit int) ([]Row, error) {
+	ctx, cancel := t.context()
+	defer cancel()
+
+	startKvs, err := primaryKey(ctx, start, true)
+	if err != nil {
+		return nil, fmt.Errorf("start: %w", err)
+	}
+	endKvs, err := primaryKey(ctx, end, true)
+	if err != nil {
+		return nil, fmt.Errorf("end: %w", err)
+	}
+
+	var p otsutils.GetRangeParams
+	if limit > 0 {
+		p.MaxRows = int64(limit)
+	}
+	var out []map[string]any
+	if err := otsutils.GetRange(ctx, builder(startKvs), builder(endKvs), &out, p); err != nil {
+		return nil, err
+	}
+	rows := make([]Row, len(out))
+	for i, m := range out {
+		rows[i] = Row(m)
+	}
+	return rows, nil
+}
+
+// primaryKey orders the columns of pks by the table's primary key. With prefix, pks may
+// name only the leading columns; otherwise it must name all of them.
+func primaryKey(ctx context.Context, pks map[string]any, prefix bool) ([]otsutils.KeyValue, error) {
+	desc, err := otsutils.TableMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	kvs := make([]otsutils.KeyValue, 0, len(pks))
+	for _, schema := range desc.PrimaryKeys {
+		value, ok := pks[schema.Name]
+		if !ok {
+			if prefix {
+				break
+			}
+			return nil, fmt.Errorf("primary key %q of table '%s' is missing", schema.Name, desc.TableName)
+		}
+		value = normalize(value)
+		switch value.(type) {
+		case string, int64, []byte:
+		default:
+			return nil, fmt.Errorf("primary key %q has invalid type: %T. Only string, int64, and []byte are allowed", schema.Name, value)
+		}
+		kvs = append(kvs, otsutils.KeyValue{Key: schema.Name, Value: value})
+	}
+	if len(kvs) < len(pks) {
+		for _, name := range slices.Sorted(maps.Keys(pks)) {
+			if !slices.ContainsFunc(kvs, func(kv otsutils.KeyValue) bool { return kv.Key == name }) {
+				return nil, fmt.Errorf("%q is not a leading primary key column of table '%s'", name, desc.TableName)
+			}
+		}
+	}
+	return kvs, nil
+}
+
+// builder returns a PrimaryKeyBuilder holding kvs, whose values primaryKey has checked.
+func builder(kvs []otsutils.KeyValue) *otsutils.PrimaryKeyBuilder {
+	b := otsutils.PK()
+	for _, kv := range kvs {
+		switch v := kv.Value.(type) {
+		case string:
+			b.String(kv.Key, v)
+		case int64:
+			b.Int64(kv.Key, v)
+		case []byte:
+			b.Bytes(kv.Key, v)
+		}
+	}
+	return b
+}
+
+// normalize converts the integer types scripts tend to use to int64.
+func normalize(v any) any {
+	switch x := v.(type) {
+	case int:
+		return int64(x)
+	case int32:
+		return int64(x)
+	}
+	return v
+}
@@ -0,0 +1,95 @@
+package quick
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/117503445/otsutils/otsfake"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFakeDB(t *testing.T) (*DB, *otsfake.Client) {
+	t.Helper()
+	fake := otsfake.New()
+	fake.MustCreateTable("users",
+		"tenant", tablestore.PrimaryKeyType_STRING,
+		"id", tablestore.PrimaryKeyType_INTEGER,
+	)
+	return New(fake), fake
+}
+
+func TestGetPut(t *testing.T) {
+	ast := assert.New(t)
+	db, fake := newFakeDB(t)
+	users := db.Table("users")
+
+	// 主键 map 的顺序无关，int 按 int64 存储
+	ast.NoError(users.Put(map[string]any{"id": 1, "tenant": "t1"}, map[string]any{"name": "Alice", "age": 30, "avatar": []byte{0xca, 0xfe}}))
+	row, err := users.Get(map[string]any{"tenant": "t1", "id": int64(1)})
+	ast.NoError(err)
+	ast.Equal(Row{"tenant": "t1", "id": int64(1), "name": "Alice", "age": int64(30), "avatar": []byte{0xca, 0xfe}}, row)
+	ast.Equal(`{age: 30, avatar: 0xcafe, id: 1, name: "Alice", tenant: "t1"}`, row.String())
+
+	// Put 覆盖已存在的行
+	ast.NoError(users.Put(map[string]any{"tenant": "t1", "id": 1}, map[string]any{"name": "Bob"}))
+	row, err = users.Get(map[string]any{"tenant": "t1", "id": 1})
+	ast.NoError(err)
+	ast.Equal("Bob", row["name"])
+
+	row, err = users.Get(map[string]any{"tenant": "t1", "id": 2})
+	ast.NoError(err)
+	ast.Nil(row)
+
+	// 表元数据只获取一次
+	ast.Equal(1, fake.CallCount("DescribeTable"))
+}
+
+func TestPrimaryKeyErrors(t *testing.T) {
+	ast := assert.New(t)
+	db, _ := newFakeDB(t)
+	users := db.Table("users")
+
+	_, err := users.Get(map[string]any{"tenant": "t1"})
+	ast.EqualError(err, `primary key "id" of table 'users' is missing`)
+	_, err = users.Get(map[string]any{"tenant": "t1", "id": 1, "name": "x"})
+	ast.EqualError(err, `"name" is not a leading primary key column of table 'users'`)
+	_, err = users.Scan(map[string]any{"id": 1}, nil, 0)
+	ast.EqualError(err, `start: "id" is not a leading primary key column of table 'users'`)
+	err = users.Put(map[string]any{"tenant": "t1", "id": 1.5}, nil)
+	ast.EqualError(err, `primary key "id" has invalid type: float64. Only string, int64, and []byte are allowed`)
+
+	_, err = db.Table("missing").Get(map[string]any{"id": 1})
+	ast.Error(err)
+}
+
+func TestScan(t *testing.T) {
+	ast := assert.New(t)
+	db, fake := newFakeDB(t)
+	fake.MaxRangeRows = 2
+	users := db.Table("users")
+
+	for _, tenant := range []string{"t1", "t2"} {
+		for id := 0; id < 3; id++ {
+			ast.NoError(users.Put(map[string]any{"tenant": tenant, "id": id}, map[string]any{"name": fmt.Sprintf("%s-%d", tenant, id)}))
+		}
+	}
+
+	// nil 边界扫描全表
+	rows, err := users.Scan(nil, nil, 0)
+	ast.NoError(err)
+	ast.Len(rows, 6)
+
+	// 相同分区键扫描整个分区
+	rows, err = users.Scan(map[string]any{"tenant": "t2"}, map[string]any{"tenant": "t2"}, 0)
+	ast.NoError(err)
+	ast.Len(rows, 3)
+	ast.Equal(`{id: 0, name: "t2-0", tenant: "t2"}`, rows[0].String())
+
+	// 左闭右开，limit 限制行数
+	rows, err = users.Scan(map[string]any{"tenant": "t1", "id": 1}, map[string]any{"tenant": "t2", "id": 2}, 3)
+	ast.NoError(err)
+	ast.Len(rows, 3)
+	ast.Equal("t1-1", rows[0]["name"])
+	ast.Equal("t2-0", rows[2]["name"])
+}
@@ -0,0 +1,456 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// rangePage is the obj passed through the executor for each page of a range scan.
+type rangePage struct {
+	StartPrimaryKey *tablestore.PrimaryKey
+	EndPrimaryKey   *tablestore.PrimaryKey
+
+	// Limit is the maximum number of rows of this page, zero for the service default
+	Limit int32
+}
+
+// pageLimit returns the row limit of the next page of a scan that has already returned
+// collected rows: PageSize, lowered to the rows still allowed by MaxRows.
+func (p GetRangeParams) pageLimit(collected int64) int32 {
+	limit := p.PageSize
+	if p.MaxRows > 0 {
+		remaining := p.MaxRows - collected
+		if limit == 0 || remaining < int64(limit) {
+			limit = int32(min(remaining, math.MaxInt32))
+		}
+	}
+	return limit
+}
+
+// validate checks that the limits are not negative, the direction is known and the column
+// names are valid.
+func (p GetRangeParams) validate() error {
+	if p.PageSize < 0 {
+		return fmt.Errorf("PageSize must not be negative, got %d", p.PageSize)
+	}
+	if p.MaxRows < 0 {
+		return fmt.Errorf("MaxRows must not be negative, got %d", p.MaxRows)
+	}
+	if p.Direction != tablestore.FORWARD && p.Direction != tablestore.BACKWARD {
+		return fmt.Errorf("unknown Direction %d", p.Direction)
+	}
+	if p.FetchWorkers < 0 {
+		return fmt.Errorf("FetchWorkers must not be negative, got %d", p.FetchWorkers)
+	}
+	for _, column := range p.ColumnsToGet {
+		if err := validateName("column", column); err != nil {
+			return fmt.Errorf("ColumnsToGet: %w", err)
+		}
+	}
+	return nil
+}
+
+// boundaryFills returns the option filling the unset trailing pk columns of the start and end
+// boundaries: the start of a forward scan is the lowest key, the start of a backward one the highest.
+func (p GetRangeParams) boundaryFills() (start, end tablestore.PrimaryKeyOption) {
+	if p.Direction == tablestore.BACKWARD {
+		return tablestore.MAX, tablestore.MIN
+	}
+	return tablestore.MIN, tablestore.MAX
+}
+
+// GetRange reads every row from start (inclusive) to end (exclusive) into out,
+// following pagination until the range is exhausted.
+// start and end are pointers to row structs whose pk fields describe the boundaries, or
+// *PrimaryKeyBuilder values naming a prefix of the primary key, and out is a pointer to a
+// slice of that struct type (or of pointers to it).
+//
+// Boundaries may be partially filled: pk fields left nil after the last set one become
+// INF_MIN on the start boundary and INF_MAX on the end boundary, so passing the same
+// partition key for both scans the whole partition. Setting a pk field while a preceding
+// pk field is nil is an error. GetRangeParams.Direction set to tablestore.BACKWARD scans from
+// start down to end instead, with the fills swapped.
+//
+// Rows are appended to out until the range is exhausted or GetRangeParams.MaxRows rows have
+// been read; GetRangeParams.PageSize only bounds each request. GetRangeParams.ColumnsToGet
+// restricts the attribute columns read.
+//
+// out may also be a *[]map[string]any, to read rows without a struct. Each map holds the
+// primary key and the newest version of every attribute column, and start and end must then
+// be *PrimaryKeyBuilder values; the remaining pk columns are filled from the table metadata.
+//
+// Example usage:
+//
+//	var rows []MyRow
+//	err := GetRange(ctx, &MyRow{PK1: tea.String("u1")}, &MyRow{PK1: tea.String("u1")}, &rows)
+//
+//	// At most 10 rows, fetched in pages of 5
+//	err = GetRange(ctx, &MyRow{}, &MyRow{}, &rows, GetRangeParams{PageSize: 5, MaxRows: 10})
+//
+//	// The 10 newest rows of the partition, newest first, with col1 only
+//	err = GetRange(ctx, &MyRow{PK1: tea.String("u1")}, &MyRow{PK1: tea.String("u1")}, &rows,
+//	    GetRangeParams{Direction: tablestore.BACKWARD, MaxRows: 10, ColumnsToGet: []string{"col1"}})
+//
+//	var maps []map[string]any
+//	err = GetRange(ctx, PK().String("pk1", "u1"), PK().String("pk1", "u1"), &maps)
+func GetRange(ctx context.Context, start any, end any, out any, params ...GetRangeParams) error {
+	var p GetRangeParams
+	if len(params) > 0 {
+		p = params[0]
+	}
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	slice, elemType, err := outSlice(out)
+	if err != nil {
+		return err
+	}
+	scan, page, err := newRangeScan(ctx, start, end, elemType, p)
+	if err != nil {
+		return err
+	}
+
+	for page != nil {
+		next := (*rangePage)(nil)
+		handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+			var err error
+			next, err = scan.decodePage(ctx, resp.(*tablestore.GetRangeResponse), func(elem reflect.Value) {
+				slice.Set(reflect.Append(slice, elem))
+			})
+			return err
+		}
+
+		err := executeOTSOperation(ctx, "GetRange", page, buildGetRangeRequest, executeGetRange, handleResp, toAnySlice(params)...)
+		if err != nil {
+			return err
+		}
+		page = next
+	}
+
+	return nil
+}
+
+// QueryByPkPrefix reads every row whose leading primary key columns equal those set on
+// prefixObj into out. prefixObj is a pointer to a row struct, or a *PrimaryKeyBuilder, whose
+// non-nil pk fields form a prefix of the primary key: the remaining columns range from INF_MIN
+// to INF_MAX. out is as in GetRange, and so are params; a prefix with no pk field set scans
+// the whole table.
+//
+// Setting a pk field while a preceding pk field is nil is an error, and so is setting every
+// pk field, since that names a single row: use GetRow instead.
+//
+// Example usage:
+//
+//	// Every order of user u1, whatever the order id
+//	var orders []Order
+//	err := QueryByPkPrefix(ctx, &Order{UserID: tea.String("u1")}, &orders)
+func QueryByPkPrefix(ctx context.Context, prefixObj any, out any, params ...GetRangeParams) error {
+	var p GetRangeParams
+	if len(params) > 0 {
+		p = params[0]
+	}
+	if err := p.validate(); err != nil {
+		return err
+	}
+
+	slice, elemType, err := outSlice(out)
+	if err != nil {
+		return err
+	}
+	if err := checkPkPrefix(ctx, prefixObj, elemType); err != nil {
+		return fmt.Errorf("prefix: %w", err)
+	}
+	scan, page, err := newRangeScan(ctx, prefixObj, prefixObj, elemType, p)
+	if err != nil {
+		return err
+	}
+
+	for page != nil {
+		next := (*rangePage)(nil)
+		handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+			var err error
+			next, err = scan.decodePage(ctx, resp.(*tablestore.GetRangeResponse), func(elem reflect.Value) {
+				slice.Set(reflect.Append(slice, elem))
+			})
+			return err
+		}
+
+		err := executeOTSOperation(ctx, "QueryByPkPrefix", page, buildGetRangeRequest, executeGetRange, handleResp, toAnySlice(params)...)
+		if err != nil {
+			return err
+		}
+		page = next
+	}
+
+	return nil
+}
+
+// checkPkPrefix checks that prefixObj names a proper prefix of the primary key, which leaves
+// at least one trailing pk column open.
+func checkPkPrefix(ctx context.Context, prefixObj any, elemType reflect.Type) error {
+	var pk *tablestore.PrimaryKey
+	var err error
+	if elemType == rowMapType {
+		pk, err = builderRangeBoundary(ctx, prefixObj, tablestore.MIN)
+	} else {
+		var obj any
+		if obj, err = boundaryStruct(prefixObj, elemType); err == nil {
+			pk, err = rangeBoundary(obj, tablestore.MIN)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	last := pk.PrimaryKeys[len(pk.PrimaryKeys)-1]
+	if last.PrimaryKeyOption != tablestore.MIN {
+		return fmt.Errorf("every primary key column is set, use GetRow to read a single row")
+	}
+	return nil
+}
+
+// rangeScan is the state of a range scan, shared by GetRange, QueryByPkPrefix and RangeRows.
+type rangeScan struct {
+	params GetRangeParams
+
+	// elemType is the type of the decoded rows: a struct, pointer to struct or map[string]any
+	elemType reflect.Type
+
+	endPK     *tablestore.PrimaryKey
+	collected int64
+}
+
+// newRangeScan validates the boundaries of a scan decoding rows into elemType, and returns
+// the scan with its first page.
+func newRangeScan(ctx context.Context, start, end any, elemType reflect.Type, p GetRangeParams) (*rangeScan, *rangePage, error) {
+	startFill, endFill := p.boundaryFills()
+	var startPK, endPK *tablestore.PrimaryKey
+	var err error
+	if elemType == rowMapType {
+		if startPK, err = builderRangeBoundary(ctx, start, startFill); err != nil {
+			return nil, nil, fmt.Errorf("start: %w", err)
+		}
+		if endPK, err = builderRangeBoundary(ctx, end, endFill); err != nil {
+			return nil, nil, fmt.Errorf("end: %w", err)
+		}
+	} else {
+		if start, err = boundaryStruct(start, elemType); err != nil {
+			return nil, nil, fmt.Errorf("start: %w", err)
+		}
+		if end, err = boundaryStruct(end, elemType); err != nil {
+			return nil, nil, fmt.Errorf("end: %w", err)
+		}
+		if startPK, err = rangeBoundary(start, startFill); err != nil {
+			return nil, nil, fmt.Errorf("start: %w", err)
+		}
+		if endPK, err = rangeBoundary(end, endFill); err != nil {
+			return nil, nil, fmt.Errorf("end: %w", err)
+		}
+	}
+
+	scan := &rangeScan{params: p, elemType: elemType, endPK: endPK}
+	return scan, &rangePage{StartPrimaryKey: startPK, EndPrimaryKey: endPK, Limit: p.pageLimit(0)}, nil
+}
+
+// decodePage decodes the rows of a page, passing each to emit until MaxRows is reached, and
+// returns the next page, or nil when the scan is done.
+func (s *rangeScan) decodePage(ctx context.Context, resp *tablestore.GetRangeResponse, emit func(reflect.Value)) (*rangePage, error) {
+	maxRows := s.params.MaxRows
+	for _, row := range resp.Rows {
+		if maxRows > 0 && s.collected >= maxRows {
+			return nil, nil
+		}
+		elem, err := decodeRow(ctx, s.elemType, row.PrimaryKey, row.Columns)
+		if err != nil {
+			return nil, err
+		}
+		emit(elem)
+		s.collected++
+	}
+	if resp.NextStartPrimaryKey == nil || maxRows > 0 && s.collected >= maxRows {
+		return nil, nil
+	}
+	return &rangePage{StartPrimaryKey: resp.NextStartPrimaryKey, EndPrimaryKey: s.endPK, Limit: s.params.pageLimit(s.collected)}, nil
+}
+
+func buildGetRangeRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	var p GetRangeParams
+	if len(params) > 0 {
+		p, _ = params[0].(GetRangeParams)
+	}
+	page := obj.(*rangePage)
+	criteria := &tablestore.RangeRowQueryCriteria{
+		TableName:       otsParams.TableName,
+		StartPrimaryKey: page.StartPrimaryKey,
+		EndPrimaryKey:   page.EndPrimaryKey,
+		MaxVersion:      1,
+		Direction:       p.Direction,
+		Limit:           page.Limit,
+		ColumnsToGet:    p.ColumnsToGet,
+	}
+	return &tablestore.GetRangeRequest{RangeRowQueryCriteria: criteria}, nil
+}
+
+func executeGetRange(client OtsClient, req any) (any, error) {
+	rangeClient, err := clientAs[RangeClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return rangeClient.GetRange(req.(*tablestore.GetRangeRequest))
+}
+
+// boundaryStruct returns obj unchanged, or when obj is a *PrimaryKeyBuilder, a new row struct of
+// elemType (a struct or pointer to struct) with the builder's columns applied.
+func boundaryStruct(obj any, elemType reflect.Type) (any, error) {
+	b, ok := obj.(*PrimaryKeyBuilder)
+	if !ok {
+		return obj, nil
+	}
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	row := reflect.New(elemType).Interface()
+	if err := b.ApplyToStruct(row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// rangeBoundary builds a range boundary from a partially filled row struct,
+// filling the unset trailing pk columns with fill (tablestore.MIN or tablestore.MAX).
+func rangeBoundary(obj any, fill tablestore.PrimaryKeyOption) (*tablestore.PrimaryKey, error) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("boundary must be a non-nil pointer to struct, got %T", obj)
+	}
+	v = v.Elem()
+
+	meta, err := getStructMeta(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	if len(meta.pkFields) == 0 {
+		return nil, fmt.Errorf("%s has no pk-tagged fields", v.Type())
+	}
+
+	pk := &tablestore.PrimaryKey{}
+	var firstUnset *fieldMeta
+	for _, i := range meta.pkFields {
+		fm := &meta.fields[i]
+		value, skip, err := fm.value(fm.field(v))
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			if firstUnset == nil {
+				firstUnset = fm
+			}
+			pk.PrimaryKeys = append(pk.PrimaryKeys, &tablestore.PrimaryKeyColumn{ColumnName: fm.column, PrimaryKeyOption: fill})
+			continue
+		}
+		if firstUnset != nil {
+			return nil, fmt.Errorf("primary key field %s is set but preceding primary key field %s is nil", fm.name, firstUnset.name)
+		}
+		if err := validatePrimaryKeyValue(fm.column, value); err != nil {
+			return nil, err
+		}
+		pk.AddPrimaryKeyColumn(fm.column, value)
+	}
+
+	return pk, nil
+}
+
+// builderRangeBoundary builds a range boundary for a map range from a *PrimaryKeyBuilder naming
+// a prefix of the table's primary key, filling the remaining pk columns with fill.
+func builderRangeBoundary(ctx context.Context, obj any, fill tablestore.PrimaryKeyOption) (*tablestore.PrimaryKey, error) {
+	b, ok := obj.(*PrimaryKeyBuilder)
+	if !ok {
+		return nil, fmt.Errorf("boundary must be a *PrimaryKeyBuilder when reading into maps, got %T", obj)
+	}
+	kvs, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	desc, err := TableMeta(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(kvs) > len(desc.PrimaryKeys) {
+		return nil, fmt.Errorf("boundary has %d primary key columns, table '%s' has %d", len(kvs), desc.TableName, len(desc.PrimaryKeys))
+	}
+
+	pk := &tablestore.PrimaryKey{}
+	for i, schema := range desc.PrimaryKeys {
+		if i >= len(kvs) {
+			pk.PrimaryKeys = append(pk.PrimaryKeys, &tablestore.PrimaryKeyColumn{ColumnName: schema.Name, PrimaryKeyOption: fill})
+			continue
+		}
+		if kvs[i].Key != schema.Name {
+			return nil, fmt.Errorf("primary key %q at index %d does not match column %q of table '%s'", kvs[i].Key, i, schema.Name, desc.TableName)
+		}
+		pk.AddPrimaryKeyColumn(kvs[i].Key, kvs[i].Value)
+	}
+	return pk, nil
+}
+
+// rowMapType is the element type of the map form of GetRange's out.
+var rowMapType = reflect.TypeOf(map[string]any(nil))
+
+// outSlice validates that out is a pointer to a slice of structs, of pointers to structs or
+// of map[string]any, returning the slice and its element type.
+func outSlice(out any) (reflect.Value, reflect.Type, error) {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, nil, fmt.Errorf("out must be a non-nil pointer to slice, got %T", out)
+	}
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+	if elemType == rowMapType {
+		return slice, elemType, nil
+	}
+
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return reflect.Value{}, nil, fmt.Errorf("out must be a pointer to a slice of structs, got %T", out)
+	}
+	return slice, elemType, nil
+}
+
+// decodeRow decodes a returned row into a new value of elemType, a struct, pointer to struct
+// or map[string]any.
+func decodeRow(ctx context.Context, elemType reflect.Type, primaryKey *tablestore.PrimaryKey, columns []*tablestore.AttributeColumn) (reflect.Value, error) {
+	pks, cols := primaryKeyToKeyValues(primaryKey), columnsToKeyValues(columns)
+
+	if elemType == rowMapType {
+		row := make(map[string]any, len(pks)+len(cols))
+		for _, kv := range append(pks, cols...) {
+			row[kv.Key] = kv.Value
+		}
+		return reflect.ValueOf(row), nil
+	}
+
+	isPtr := elemType.Kind() == reflect.Ptr
+	structType := elemType
+	if isPtr {
+		structType = elemType.Elem()
+	}
+
+	elem := reflect.New(structType)
+	if err := ParseResult(ctx, elem.Interface(), pks, cols); err != nil {
+		return reflect.Value{}, err
+	}
+	if isPtr {
+		return elem, nil
+	}
+	return elem.Elem(), nil
+}
@@ -0,0 +1,412 @@
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/stretchr/testify/assert"
+)
+
+type RangeRow struct {
+	Pk1  *string `json:"pk1" pk:"1"`
+	Pk2  *int64  `json:"pk2" pk:"2"`
+	Col1 *string `json:"col1"`
+}
+
+func TestGetRangePartitionPrefix(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	fake.MaxRangeRows = 2
+
+	for _, partition := range []string{"u0", "u1", "u2"} {
+		for i := int64(0); i < 5; i++ {
+			row := RangeRow{Pk1: tea.String(partition), Pk2: tea.Int64(i), Col1: tea.String(fmt.Sprintf("%s-%d", partition, i))}
+			ast.NoError(PutRow(ctx, &row))
+		}
+	}
+
+	// 只设置分区键：扫描整个分区
+	var rows []RangeRow
+	err := GetRange(ctx, &RangeRow{Pk1: tea.String("u1")}, &RangeRow{Pk1: tea.String("u1")}, &rows)
+	ast.NoError(err)
+	ast.Len(rows, 5)
+	for i, row := range rows {
+		ast.Equal("u1", tea.StringValue(row.Pk1))
+		ast.Equal(int64(i), tea.Int64Value(row.Pk2))
+		ast.Equal(fmt.Sprintf("u1-%d", i), tea.StringValue(row.Col1))
+	}
+	// 每页 2 行，共 3 页
+	ast.Equal(3, fake.CallCount("GetRange"))
+
+	// 完整主键边界：左闭右开
+	var ptrRows []*RangeRow
+	err = GetRange(ctx,
+		&RangeRow{Pk1: tea.String("u0"), Pk2: tea.Int64(3)},
+		&RangeRow{Pk1: tea.String("u2"), Pk2: tea.Int64(1)},
+		&ptrRows)
+	ast.NoError(err)
+	ast.Len(ptrRows, 2+5+1)
+	ast.Equal("u0-3", tea.StringValue(ptrRows[0].Col1))
+	ast.Equal("u2-0", tea.StringValue(ptrRows[len(ptrRows)-1].Col1))
+
+	// 全空边界：扫描全表
+	rows = nil
+	ast.NoError(GetRange(ctx, &RangeRow{}, &RangeRow{}, &rows))
+	ast.Len(rows, 15)
+}
+
+func TestGetRangeBoundaryValidation(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	var rows []RangeRow
+
+	// 前一个主键为 nil 时不能设置后面的主键
+	err := GetRange(ctx, &RangeRow{Pk2: tea.Int64(1)}, &RangeRow{}, &rows)
+	ast.ErrorContains(err, "start: primary key field Pk2 is set but preceding primary key field Pk1 is nil")
+
+	err = GetRange(ctx, &RangeRow{}, &RangeRow{Pk2: tea.Int64(1)}, &rows)
+	ast.ErrorContains(err, "end: primary key field Pk2 is set")
+
+	err = GetRange(ctx, &RangeRow{}, &RangeRow{}, rows)
+	ast.ErrorContains(err, "out must be a non-nil pointer to slice")
+
+	var ints []int
+	err = GetRange(ctx, &RangeRow{}, &RangeRow{}, &ints)
+	ast.ErrorContains(err, "out must be a pointer to a slice of structs")
+
+	err = GetRange(ctx, RangeRow{}, &RangeRow{}, &rows)
+	ast.ErrorContains(err, "boundary must be a non-nil pointer to struct")
+
+	ast.Equal(0, fake.CallCount("GetRange"))
+}
+
+func TestGetRangePageSizeAndMaxRows(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	for i := int64(0); i < 10; i++ {
+		ast.NoError(PutRow(ctx, &RangeRow{Pk1: tea.String("u"), Pk2: tea.Int64(i)}))
+	}
+
+	scan := func(params GetRangeParams) ([]RangeRow, int) {
+		before := fake.CallCount("GetRange")
+		var rows []RangeRow
+		ast.NoError(GetRange(ctx, &RangeRow{}, &RangeRow{}, &rows, params))
+		return rows, fake.CallCount("GetRange") - before
+	}
+
+	// PageSize 只影响请求次数，不影响返回行数
+	rows, calls := scan(GetRangeParams{PageSize: 3})
+	ast.Len(rows, 10)
+	ast.Equal(4, calls)
+
+	// MaxRows 限制总行数
+	rows, calls = scan(GetRangeParams{MaxRows: 4})
+	ast.Len(rows, 4)
+	ast.Equal(1, calls)
+	ast.Equal(int64(3), tea.Int64Value(rows[3].Pk2))
+
+	// 最后一页只请求剩余所需的行数
+	rows, calls = scan(GetRangeParams{PageSize: 3, MaxRows: 7})
+	ast.Len(rows, 7)
+	ast.Equal(3, calls)
+
+	// 恰好在页边界结束时不再多发请求
+	rows, calls = scan(GetRangeParams{PageSize: 3, MaxRows: 6})
+	ast.Len(rows, 6)
+	ast.Equal(2, calls)
+
+	// MaxRows 大于总行数
+	rows, _ = scan(GetRangeParams{PageSize: 4, MaxRows: 100})
+	ast.Len(rows, 10)
+
+	var out []RangeRow
+	ast.ErrorContains(GetRange(ctx, &RangeRow{}, &RangeRow{}, &out, GetRangeParams{PageSize: -1}), "PageSize must not be negative")
+	ast.ErrorContains(GetRange(ctx, &RangeRow{}, &RangeRow{}, &out, GetRangeParams{MaxRows: -1}), "MaxRows must not be negative")
+}
+
+func TestGetRangeMaxRowsStopsMidPage(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	for i := int64(0); i < 5; i++ {
+		ast.NoError(PutRow(ctx, &RangeRow{Pk1: tea.String("u"), Pk2: tea.Int64(i)}))
+	}
+
+	// 服务端返回的行数超过请求的 Limit 时，仍在 MaxRows 处停止
+	fake.Intercept = func(operation string, request any) error {
+		if req, ok := request.(*tablestore.GetRangeRequest); ok {
+			req.RangeRowQueryCriteria.Limit = 0
+		}
+		return nil
+	}
+	var rows []RangeRow
+	ast.NoError(GetRange(ctx, &RangeRow{}, &RangeRow{}, &rows, GetRangeParams{MaxRows: 2}))
+	ast.Len(rows, 2)
+	ast.Equal(1, fake.CallCount("GetRange"))
+}
+
+func TestGetRangeToMaps(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	fake.MaxRangeRows = 2
+
+	for _, partition := range []string{"u0", "u1"} {
+		for i := int64(0); i < 3; i++ {
+			ast.NoError(PutRow(ctx, &RangeRow{Pk1: tea.String(partition), Pk2: tea.Int64(i), Col1: tea.String(fmt.Sprintf("%s-%d", partition, i))}))
+		}
+	}
+
+	// 前缀边界：剩余主键列按表元数据补齐
+	var rows []map[string]any
+	ast.NoError(GetRange(ctx, PK().String("pk1", "u1"), PK().String("pk1", "u1"), &rows))
+	ast.Equal([]map[string]any{
+		{"pk1": "u1", "pk2": int64(0), "col1": "u1-0"},
+		{"pk1": "u1", "pk2": int64(1), "col1": "u1-1"},
+		{"pk1": "u1", "pk2": int64(2), "col1": "u1-2"},
+	}, rows)
+
+	rows = nil
+	ast.NoError(GetRange(ctx, PK(), PK(), &rows, GetRangeParams{MaxRows: 4}))
+	ast.Len(rows, 4)
+	ast.Equal(1, fake.CallCount("DescribeTable"))
+
+	err := GetRange(ctx, &RangeRow{}, PK(), &rows)
+	ast.EqualError(err, "start: boundary must be a *PrimaryKeyBuilder when reading into maps, got *otsutils.RangeRow")
+	err = GetRange(ctx, PK().Int64("pk2", 1), PK(), &rows)
+	ast.EqualError(err, `start: primary key "pk2" at index 0 does not match column "pk1" of table 'test_table'`)
+}
+
+func TestGetRangeDirectionAndColumns(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	fake.MaxRangeRows = 2
+
+	type rangeColsRow struct {
+		Pk1  *string `json:"pk1" pk:"1"`
+		Pk2  *int64  `json:"pk2" pk:"2"`
+		Col1 *string `json:"col1"`
+		Col2 *int64  `json:"col2"`
+	}
+
+	for _, partition := range []string{"u0", "u1", "u2"} {
+		for i := int64(0); i < 5; i++ {
+			ast.NoError(PutRow(ctx, &rangeColsRow{Pk1: tea.String(partition), Pk2: tea.Int64(i), Col1: tea.String(fmt.Sprintf("%s-%d", partition, i)), Col2: tea.Int64(i)}))
+		}
+	}
+
+	// 反向扫描：起点的空主键列填 INF_MAX，终点填 INF_MIN
+	var rows []rangeColsRow
+	err := GetRange(ctx, &rangeColsRow{Pk1: tea.String("u1")}, &rangeColsRow{Pk1: tea.String("u1")}, &rows, GetRangeParams{Direction: tablestore.BACKWARD})
+	ast.NoError(err)
+	ast.Len(rows, 5)
+	for i, row := range rows {
+		ast.Equal(int64(4-i), tea.Int64Value(row.Pk2))
+	}
+
+	// 反向的 MaxRows 取最新的若干行，map 形式同样适用
+	var maps []map[string]any
+	err = GetRange(ctx, PK().String("pk1", "u2"), PK().String("pk1", "u0"), &maps, GetRangeParams{Direction: tablestore.BACKWARD, MaxRows: 6})
+	ast.NoError(err)
+	ast.Len(maps, 6)
+	ast.Equal("u2-4", maps[0]["col1"])
+	ast.Equal("u1-4", maps[5]["col1"])
+
+	// ColumnsToGet 只读取指定的属性列，主键总是返回
+	rows = nil
+	err = GetRange(ctx, &rangeColsRow{Pk1: tea.String("u0")}, &rangeColsRow{Pk1: tea.String("u0")}, &rows, GetRangeParams{ColumnsToGet: []string{"col2"}})
+	ast.NoError(err)
+	ast.Len(rows, 5)
+	ast.Equal("u0", tea.StringValue(rows[3].Pk1))
+	ast.Equal(int64(3), tea.Int64Value(rows[3].Col2))
+	ast.Nil(rows[3].Col1)
+
+	ast.ErrorContains(GetRange(ctx, &rangeColsRow{}, &rangeColsRow{}, &rows, GetRangeParams{ColumnsToGet: []string{"bad-name"}}), "ColumnsToGet: column name")
+	ast.ErrorContains(GetRange(ctx, &rangeColsRow{}, &rangeColsRow{}, &rows, GetRangeParams{Direction: 2}), "unknown Direction 2")
+}
+
+func TestRangeRows(t *testing.T) {
+	t.Run("fetches only the pages consumed", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.MaxRangeRows = 2
+
+		for i := int64(0); i < 10; i++ {
+			ast.NoError(PutRow(ctx, &RangeRow{Pk1: tea.String("u"), Pk2: tea.Int64(i)}))
+		}
+
+		// 读到第 3 行就退出：只需要两页
+		var seen []int64
+		for row, err := range RangeRows(ctx, &RangeRow{Pk1: tea.String("u")}, &RangeRow{Pk1: tea.String("u")}) {
+			ast.NoError(err)
+			seen = append(seen, tea.Int64Value(row.Pk2))
+			if len(seen) == 3 {
+				break
+			}
+		}
+		ast.Equal([]int64{0, 1, 2}, seen)
+		ast.Equal(2, fake.CallCount("GetRange"))
+
+		// 完整迭代与 GetRange 结果一致，nil 边界扫描全表
+		seen = nil
+		for row, err := range RangeRows[RangeRow](ctx, nil, nil, GetRangeParams{Direction: tablestore.BACKWARD, MaxRows: 7}) {
+			ast.NoError(err)
+			seen = append(seen, tea.Int64Value(row.Pk2))
+		}
+		ast.Equal([]int64{9, 8, 7, 6, 5, 4, 3}, seen)
+	})
+
+	t.Run("stops on a cancelled context between pages", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.MaxRangeRows = 2
+
+		for i := int64(0); i < 5; i++ {
+			ast.NoError(PutRow(ctx, &RangeRow{Pk1: tea.String("u"), Pk2: tea.Int64(i)}))
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var rows int
+		var lastErr error
+		for row, err := range RangeRows[RangeRow](ctx, nil, nil) {
+			if err != nil {
+				ast.Nil(row)
+				lastErr = err
+				continue
+			}
+			rows++
+			cancel()
+		}
+		ast.Equal(2, rows)
+		ast.ErrorIs(lastErr, context.Canceled)
+		ast.Equal(1, fake.CallCount("GetRange"))
+	})
+
+	t.Run("reports errors once", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+
+		var errs []error
+		for _, err := range RangeRows(ctx, &RangeRow{Pk2: tea.Int64(1)}, nil) {
+			errs = append(errs, err)
+		}
+		ast.Len(errs, 1)
+		ast.ErrorContains(errs[0], "start: primary key field Pk2 is set but preceding primary key field Pk1 is nil")
+
+		errs = nil
+		for _, err := range RangeRows[string](ctx, nil, nil) {
+			errs = append(errs, err)
+		}
+		ast.Len(errs, 1)
+		ast.ErrorContains(errs[0], "RangeRows needs a struct type, got string")
+	})
+}
+
+func TestRowIterator(t *testing.T) {
+	t.Run("range iterator", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.MaxRangeRows = 2
+		putRangeRows(t, ctx, "u", 5)
+
+		it := NewRangeIterator[RangeRow](ctx, nil, nil)
+		ast.Zero(fake.CallCount("GetRange"))
+		var seen []int64
+		for it.Next() {
+			seen = append(seen, tea.Int64Value(it.Row().Pk2))
+		}
+		ast.NoError(it.Err())
+		ast.Equal([]int64{0, 1, 2, 3, 4}, seen)
+		ast.NoError(it.Close(ctx))
+		ast.False(it.Next())
+	})
+
+	t.Run("close discards the page", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.MaxRangeRows = 2
+		putRangeRows(t, ctx, "u", 5)
+
+		it := NewRangeIterator[RangeRow](ctx, nil, nil)
+		ast.True(it.Next())
+		ast.NoError(it.Close(ctx))
+		ast.Nil(it.Row())
+		// 关闭后不再读取，可重复关闭
+		ast.False(it.Next())
+		ast.NoError(it.Close(ctx))
+		ast.NoError(it.Err())
+		ast.Equal(1, fake.CallCount("GetRange"))
+	})
+
+	t.Run("errors end the iteration", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newFakeContext(t)
+
+		it := NewRangeIterator(ctx, &RangeRow{Pk1: tea.String("u")}, &RangeRow{}, GetRangeParams{PageSize: -1})
+		ast.False(it.Next())
+		ast.Error(it.Err())
+		ast.NoError(it.Close(ctx))
+	})
+
+	t.Run("search iterator", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSearchContext(t)
+
+		it := NewSearchIterator[SearchRow](ctx, "search_index", &search.TermQuery{FieldName: "city", Term: "hz"}, SearchParams{Limit: 2})
+		defer it.Close(ctx)
+		var ages []int64
+		for it.Next() {
+			ages = append(ages, *it.Row().Age)
+		}
+		ast.NoError(it.Err())
+		ast.ElementsMatch([]int64{20, 22, 24}, ages)
+	})
+}
+
+func TestQueryByPkPrefix(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	fake.MaxRangeRows = 2
+
+	for _, partition := range []string{"u0", "u1", "u2"} {
+		for i := int64(0); i < 3; i++ {
+			ast.NoError(PutRow(ctx, &RangeRow{Pk1: tea.String(partition), Pk2: tea.Int64(i), Col1: tea.String(fmt.Sprintf("%s-%d", partition, i))}))
+		}
+	}
+
+	var rows []RangeRow
+	ast.NoError(QueryByPkPrefix(ctx, &RangeRow{Pk1: tea.String("u1")}, &rows))
+	ast.Len(rows, 3)
+	for i, row := range rows {
+		ast.Equal(fmt.Sprintf("u1-%d", i), tea.StringValue(row.Col1))
+	}
+
+	// 反向与 map 形式沿用 GetRange 的参数
+	var maps []map[string]any
+	ast.NoError(QueryByPkPrefix(ctx, PK().String("pk1", "u2"), &maps, GetRangeParams{Direction: tablestore.BACKWARD, MaxRows: 2}))
+	ast.Len(maps, 2)
+	ast.Equal("u2-2", maps[0]["col1"])
+
+	// 空前缀扫描全表
+	rows = nil
+	ast.NoError(QueryByPkPrefix(ctx, &RangeRow{}, &rows))
+	ast.Len(rows, 9)
+
+	// 非连续前缀和完整主键都会被拒绝
+	type threePk struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk2 *int64  `json:"pk2" pk:"2"`
+		Pk3 *string `json:"pk3" pk:"3"`
+	}
+	var out []threePk
+	err := QueryByPkPrefix(ctx, &threePk{Pk1: tea.String("u1"), Pk3: tea.String("x")}, &out)
+	ast.EqualError(err, "prefix: primary key field Pk3 is set but preceding primary key field Pk2 is nil")
+	err = QueryByPkPrefix(ctx, &RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(1)}, &rows)
+	ast.EqualError(err, "prefix: every primary key column is set, use GetRow to read a single row")
+}
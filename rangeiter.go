@@ -0,0 +1,167 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"reflect"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// RangeRows is the lazy form of GetRange: it reads the rows from start to end one page at a
+// time, yielding each decoded row without buffering the whole range. A nil boundary is a
+// struct with every pk field nil, so RangeRows(ctx, nil, nil) scans the whole table.
+//
+// A page is requested only when the rows of the previous one have been consumed, so breaking
+// out of the loop stops the scan without further requests. The context is checked before
+// each page. On failure, including a cancelled context, the error is yielded once with a nil
+// row and the iteration ends. RangeRows starts no goroutine.
+//
+// Example usage:
+//
+//	for row, err := range RangeRows(ctx, &MyRow{PK1: tea.String("u1")}, &MyRow{PK1: tea.String("u1")}) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    if done(row) {
+//	        break
+//	    }
+//	}
+func RangeRows[T any](ctx context.Context, start, end *T, params ...GetRangeParams) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		var p GetRangeParams
+		if len(params) > 0 {
+			p = params[0]
+		}
+		if err := p.validate(); err != nil {
+			yield(nil, err)
+			return
+		}
+		if t := reflect.TypeFor[T](); t.Kind() != reflect.Struct {
+			yield(nil, fmt.Errorf("RangeRows needs a struct type, got %s", t))
+			return
+		}
+		if start == nil {
+			start = new(T)
+		}
+		if end == nil {
+			end = new(T)
+		}
+
+		scan, page, err := newRangeScan(ctx, start, end, reflect.TypeFor[*T](), p)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		for page != nil {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			var rows []*T
+			next := (*rangePage)(nil)
+			handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+				var err error
+				next, err = scan.decodePage(ctx, resp.(*tablestore.GetRangeResponse), func(elem reflect.Value) {
+					rows = append(rows, elem.Interface().(*T))
+				})
+				return err
+			}
+
+			if err := executeOTSOperation(ctx, "GetRange", page, buildGetRangeRequest, executeGetRange, handleResp, toAnySlice(params)...); err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, row := range rows {
+				if !yield(row, nil) {
+					return
+				}
+			}
+			page = next
+		}
+	}
+}
+
+// RowIterator is the pull form of a sequence of rows: Next advances to the next row, Row returns
+// it and Err the error that ended the iteration. Until the rows run out, the page being read is
+// held by a coroutine of the iterator, so an iterator left before its end must be closed.
+//
+// Example usage:
+//
+//	it := NewRangeIterator(ctx, &MyRow{PK1: tea.String("u1")}, &MyRow{PK1: tea.String("u1")})
+//	defer it.Close(ctx)
+//	for it.Next() {
+//	    if done(it.Row()) {
+//	        break
+//	    }
+//	}
+//	if err := it.Err(); err != nil {
+//	    return err
+//	}
+type RowIterator[T any] struct {
+	next func() (T, error, bool)
+	stop func()
+	row  T
+	err  error
+}
+
+// NewRangeIterator returns an iterator over the rows from start to end, read as by RangeRows.
+// No request is sent before the first Next.
+func NewRangeIterator[T any](ctx context.Context, start, end *T, params ...GetRangeParams) *RowIterator[*T] {
+	return newRowIterator(RangeRows(ctx, start, end, params...))
+}
+
+func newRowIterator[T any](seq iter.Seq2[T, error]) *RowIterator[T] {
+	next, stop := iter.Pull2(seq)
+	return &RowIterator[T]{next: next, stop: stop}
+}
+
+// Next advances to the next row, reading a page when the rows of the previous one ran out. It
+// returns false at the end of the rows, on error and once the iterator is closed.
+func (it *RowIterator[T]) Next() bool {
+	var zero T
+	it.row = zero
+	if it.next == nil {
+		return false
+	}
+	row, err, ok := it.next()
+	if !ok || err != nil {
+		it.err = err
+		it.release()
+		return false
+	}
+	it.row = row
+	return true
+}
+
+// Row returns the row Next advanced to.
+func (it *RowIterator[T]) Row() T {
+	return it.row
+}
+
+// Err returns the error that ended the iteration, nil at the end of the rows or after Close.
+func (it *RowIterator[T]) Err() error {
+	return it.err
+}
+
+// Close discards the rows not read yet, releasing the page buffered and the coroutine reading
+// it; Next then returns false. It sends no request and returns nil, and may be called more than
+// once.
+func (it *RowIterator[T]) Close(ctx context.Context) error {
+	var zero T
+	it.row = zero
+	it.release()
+	return nil
+}
+
+func (it *RowIterator[T]) release() {
+	if it.stop != nil {
+		it.stop()
+		it.next, it.stop = nil, nil
+	}
+}
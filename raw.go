@@ -0,0 +1,40 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+// RawExecute sends a hand-built SDK request through the same pipeline as the wrapped operations:
+// logging, client selection, auditing of PutRow/UpdateRow/DeleteRow requests and capacity
+// reporting. Use it for SDK features this package does not wrap yet.
+//
+// exec receives the client from the context; operation is used for logging and, when it names
+// a read operation such as "GetRow" or "GetRange", routes the call to OtsUtilsParams.ReadClient.
+// Type-assert the client to *tablestore.TableStoreClient to reach methods missing from OtsClient.
+//
+// Example usage:
+//
+//	resp, err := RawExecute(ctx, "GetRow", req,
+//	    func(client OtsClient, req *tablestore.GetRowRequest) (*tablestore.GetRowResponse, error) {
+//	        return client.GetRow(req)
+//	    })
+func RawExecute[Req any, Resp any](ctx context.Context, operation string, req Req, exec func(client OtsClient, req Req) (Resp, error)) (Resp, error) {
+	var resp Resp
+	buildRequest := func(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+		return obj, nil
+	}
+	execute := func(client OtsClient, req any) (any, error) {
+		typed, _ := req.(Req)
+		return exec(client, typed)
+	}
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, r any, obj any) error {
+		resp, _ = r.(Resp)
+		return nil
+	}
+
+	err := executeOTSOperation(ctx, operation, req, buildRequest, execute, handleResp)
+	return resp, err
+}
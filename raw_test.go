@@ -0,0 +1,80 @@
+package otsutils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/117503445/otsutils/otsfake"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRawExecute(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	var buf bytes.Buffer
+	ctx = zerolog.New(&buf).Level(zerolog.DebugLevel).WithContext(ctx)
+	hook := &recordingAuditHook{}
+	OtsUtilsParamsFromCtx(ctx).AuditHook = hook
+
+	putChange := &tablestore.PutRowChange{TableName: "test_table", PrimaryKey: &tablestore.PrimaryKey{}}
+	putChange.PrimaryKey.AddPrimaryKeyColumn("pk1", "a")
+	putChange.PrimaryKey.AddPrimaryKeyColumn("pk2", int64(1))
+	putChange.AddColumn("col1", "v")
+	putChange.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+	putResp, err := RawExecute(ctx, "PutRow", &tablestore.PutRowRequest{PutRowChange: putChange},
+		func(client OtsClient, req *tablestore.PutRowRequest) (*tablestore.PutRowResponse, error) {
+			return client.PutRow(req)
+		})
+	ast.NoError(err)
+	ast.NotNil(putResp)
+	ast.Equal(1, fake.CallCount("PutRow"))
+
+	// 原始请求同样经过审计与日志
+	ast.Len(hook.records, 1)
+	ast.Equal("PutRow", hook.records[0].op)
+	ast.Contains(buf.String(), `"operation":"PutRow"`)
+	ast.Contains(buf.String(), "OTS operation completed")
+
+	criteria := &tablestore.SingleRowQueryCriteria{TableName: "test_table", MaxVersion: 1, PrimaryKey: &tablestore.PrimaryKey{}}
+	criteria.PrimaryKey.AddPrimaryKeyColumn("pk1", "a")
+	criteria.PrimaryKey.AddPrimaryKeyColumn("pk2", int64(1))
+	getResp, err := RawExecute(ctx, "GetRow", &tablestore.GetRowRequest{SingleRowQueryCriteria: criteria},
+		func(client OtsClient, req *tablestore.GetRowRequest) (*tablestore.GetRowResponse, error) {
+			return client.GetRow(req)
+		})
+	ast.NoError(err)
+	ast.Equal([]KeyValue{{Key: "col1", Value: "v"}}, columnsToKeyValues(getResp.Columns))
+
+	// 错误原样返回
+	criteria.TableName = "missing"
+	getResp, err = RawExecute(ctx, "GetRow", &tablestore.GetRowRequest{SingleRowQueryCriteria: criteria},
+		func(client OtsClient, req *tablestore.GetRowRequest) (*tablestore.GetRowResponse, error) {
+			return client.GetRow(req)
+		})
+	ast.Equal(CodeObjectNotExist, Code(err))
+	ast.Nil(getResp)
+}
+
+func TestRawExecuteReadClient(t *testing.T) {
+	ast := assert.New(t)
+	ctx, primary := newFakeContext(t)
+	replica := otsfake.New()
+	OtsUtilsParamsFromCtx(ctx).ReadClient = replica
+
+	// 读操作名会路由到只读实例
+	_, err := RawExecute(ctx, "GetRange", 0, func(client OtsClient, _ int) (int, error) {
+		ast.Same(replica, client)
+		return 1, nil
+	})
+	ast.NoError(err)
+
+	n, err := RawExecute(ctx, "CreateTable", 0, func(client OtsClient, _ int) (int, error) {
+		ast.Same(primary, client)
+		return 2, nil
+	})
+	ast.NoError(err)
+	ast.Equal(2, n)
+}
@@ -0,0 +1,162 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// Defaults of RMWOptions.
+const (
+	DefaultRMWAttempts = 5
+	DefaultRMWBackoff  = 20 * time.Millisecond
+)
+
+// ReadModifyWrite reads the row of obj, a pointer to a row struct whose pk fields are set, calls
+// modify to change obj in place and writes the result back with a conditional UpdateRow, which
+// only succeeds if the row is still the one read: under RMWOptions.VersionColumn, or every
+// attribute column read, as in PutRowSwap. A missing row is read as the zero struct and created
+// only if it is still missing. The attribute fields modify sets to nil delete their column.
+//
+// When the condition fails because another writer got in between, the row is read again and
+// modify called again, after a jittered backoff, up to RMWOptions.MaxAttempts rounds; modify
+// must therefore only depend on obj. The error of the last round then tells how many were made
+// and is still reported by IsConditionCheckFail. An error returned by modify stops the loop
+// and is returned as is, without writing.
+//
+// Example usage:
+//
+//	account := Account{UserID: tea.String("u1")}
+//	err := ReadModifyWrite(ctx, &account, func() error {
+//	    if *account.Balance < amount {
+//	        return ErrInsufficientBalance
+//	    }
+//	    *account.Balance -= amount
+//	    *account.Version++
+//	    return nil
+//	}, RMWOptions{VersionColumn: "version"})
+func ReadModifyWrite(ctx context.Context, obj any, modify func() error, opts ...RMWOptions) error {
+	var o RMWOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	attempts := o.MaxAttempts
+	if attempts <= 0 {
+		attempts = DefaultRMWAttempts
+	}
+	backoff := o.Backoff
+	if backoff <= 0 {
+		backoff = DefaultRMWBackoff
+	}
+	if modify == nil {
+		return fmt.Errorf("modify can not be nil")
+	}
+
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("obj must be a non-nil pointer to struct, got %T", obj)
+	}
+	meta, err := getStructMeta(v.Elem().Type())
+	if err != nil {
+		return err
+	}
+	pks, _, err := parseRow(ctx, obj)
+	if err != nil {
+		return err
+	}
+	key := &rowKeyValues{PrimaryKey: pks}
+
+	for attempt := 1; ; attempt++ {
+		// Read the current row into obj
+		var getResp *tablestore.GetRowResponse
+		if err := executeOTSOperation(ctx, "ReadModifyWrite", key, buildGetRowRequest, executeGetRow, captureGetRowResponse(&getResp)); err != nil {
+			return err
+		}
+		var oldCols []KeyValue
+		expectation := tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST
+		if getResp != nil {
+			_, oldCols = rowFromGetRowResponse(getResp)
+			expectation = tablestore.RowExistenceExpectation_EXPECT_EXIST
+		}
+		v.Elem().SetZero()
+		if err := ParseResult(ctx, obj, pks, oldCols); err != nil {
+			return err
+		}
+
+		if err := modify(); err != nil {
+			return err
+		}
+
+		newPks, newCols, err := parseRow(ctx, obj)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(newPks, pks) {
+			return fmt.Errorf("modify changed the primary key of the row")
+		}
+		deleted := deletedStructColumns(meta, oldCols, newCols)
+		if len(newCols) == 0 && len(deleted) == 0 {
+			// Nothing to write
+			return nil
+		}
+
+		// Write the row only if the one read is still there
+		p := UpdateRowParams{
+			RowExistenceExpectation: &expectation,
+			ColumnCondition:         swapCondition(oldCols, o.VersionColumn),
+			DeletedColumns:          deleted,
+		}
+		err = executeOTSOperation(ctx, "ReadModifyWrite", obj, buildUpdateRowRequest, executeUpdateRow, nil, p)
+		if err == nil || !IsConditionCheckFail(err) {
+			return err
+		}
+		if attempt == attempts {
+			return fmt.Errorf("ReadModifyWrite gave up after %d attempts: %w", attempts, err)
+		}
+
+		// The jitter spreads out the writers racing for the row
+		wait := backoff << (attempt - 1)
+		wait = wait/2 + rand.N(wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// deletedStructColumns returns the columns of the attribute fields of meta that were read in
+// oldCols and are no longer set in newCols. With an extra field, every column read maps to a
+// field, so every column no longer set is returned.
+func deletedStructColumns(meta *structMeta, oldCols, newCols []KeyValue) []string {
+	read := make(map[string]bool, len(oldCols))
+	for _, col := range oldCols {
+		read[col.Key] = true
+	}
+	for _, col := range newCols {
+		delete(read, col.Key)
+	}
+	var deleted []string
+	if meta.extra != nil {
+		for _, col := range oldCols {
+			if read[col.Key] {
+				deleted = append(deleted, col.Key)
+				delete(read, col.Key)
+			}
+		}
+		return deleted
+	}
+	for _, i := range meta.attrFields {
+		if column := meta.fields[i].column; read[column] {
+			deleted = append(deleted, column)
+		}
+	}
+	return deleted
+}
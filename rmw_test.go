@@ -0,0 +1,130 @@
+package otsutils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadModifyWrite(t *testing.T) {
+	t.Run("reads, modifies and writes the row", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		// 行不存在时从零值开始并创建
+		row := TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("ignored")}
+		ast.NoError(ReadModifyWrite(ctx, &row, func() error {
+			ast.Nil(row.Col1)
+			row.Col1 = tea.String("v1")
+			row.Col2 = tea.Int64(1)
+			return nil
+		}))
+
+		row = TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+		ast.NoError(ReadModifyWrite(ctx, &row, func() error {
+			ast.Equal("v1", *row.Col1)
+			*row.Col2++
+			row.Col1 = nil
+			return nil
+		}))
+		ast.Equal(2, fake.CallCount("UpdateRow"))
+
+		// 置为 nil 的字段删除其列
+		got := TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+		ast.NoError(GetRow(ctx, &got))
+		ast.Nil(got.Col1)
+		ast.Equal(int64(2), *got.Col2)
+	})
+
+	t.Run("conflicts are retried", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col2: tea.Int64(1)}))
+
+		// 前 concurrentWrites 次写入前，col2 被并发修改为 10、20……
+		concurrentWrites, written := 2, int64(0)
+		fake.Intercept = func(operation string, request any) error {
+			if operation != "UpdateRow" || request.(*tablestore.UpdateRowRequest).UpdateRowChange.Condition.ColumnCondition == nil || concurrentWrites == 0 {
+				return nil
+			}
+			concurrentWrites--
+			written += 10
+			change := &tablestore.UpdateRowChange{TableName: "test_table", PrimaryKey: &tablestore.PrimaryKey{}}
+			change.PrimaryKey.AddPrimaryKeyColumn("pk1", "a")
+			change.PrimaryKey.AddPrimaryKeyColumn("pk2", int64(1))
+			change.PutColumn("col2", written)
+			change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+			_, err := fake.UpdateRow(&tablestore.UpdateRowRequest{UpdateRowChange: change})
+			return err
+		}
+
+		row := TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+		calls := 0
+		ast.NoError(ReadModifyWrite(ctx, &row, func() error {
+			calls++
+			*row.Col2++
+			return nil
+		}, RMWOptions{Backoff: time.Millisecond}))
+		ast.Equal(3, calls)
+		ast.Equal(int64(21), *row.Col2)
+		ast.Equal(3, fake.CallCount("GetRow"))
+
+		// 一直冲突时报告尝试次数
+		concurrentWrites = 100
+		err := ReadModifyWrite(ctx, &row, func() error {
+			*row.Col2++
+			return nil
+		}, RMWOptions{MaxAttempts: 2, Backoff: time.Millisecond})
+		ast.ErrorContains(err, "ReadModifyWrite gave up after 2 attempts")
+		ast.True(IsConditionCheckFail(err))
+	})
+
+	t.Run("version column", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v"), Col2: tea.Int64(1)}))
+
+		var cond *tablestore.SingleColumnCondition
+		fake.Intercept = func(operation string, request any) error {
+			if r, ok := request.(*tablestore.UpdateRowRequest); ok {
+				cond, _ = r.UpdateRowChange.Condition.ColumnCondition.(*tablestore.SingleColumnCondition)
+			}
+			return nil
+		}
+		row := TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+		ast.NoError(ReadModifyWrite(ctx, &row, func() error {
+			row.Col1 = tea.String("w")
+			*row.Col2++
+			return nil
+		}, RMWOptions{VersionColumn: "col2"}))
+		if ast.NotNil(cond) {
+			ast.Equal("col2", *cond.ColumnName)
+			ast.Equal(int64(1), cond.ColumnValue)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v")}))
+
+		// modify 的错误原样返回，不写入
+		errRejected := errors.New("rejected")
+		row := TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+		ast.Equal(errRejected, ReadModifyWrite(ctx, &row, func() error { return errRejected }))
+		ast.Equal(0, fake.CallCount("UpdateRow"))
+
+		err := ReadModifyWrite(ctx, &row, func() error {
+			row.Pk2 = tea.Int64(2)
+			return nil
+		})
+		ast.EqualError(err, "modify changed the primary key of the row")
+		ast.EqualError(ReadModifyWrite(ctx, &row, nil), "modify can not be nil")
+		ast.EqualError(ReadModifyWrite(ctx, row, func() error { return nil }), "obj must be a non-nil pointer to struct, got otsutils.TestRow")
+		ast.Equal(0, fake.CallCount("UpdateRow"))
+	})
+}
@@ -0,0 +1,501 @@
+// Package rowcheck defines an Analyzer that reports invalid row struct types at build time.
+//
+// It inspects the struct types passed to the otsutils row APIs (PutRow, GetRow, UpdateRow,
+// DeleteRow, PutRowSwap, GetRange, RangeRows, QueryByPkPrefix, ParseObj, ParseResult, CheckType, MustRegister, FromStruct,
+// ApplyToStruct and DeleteColumnsIfPresent) and reports the problems the runtime metadata
+// validator would return from CheckType. Both apply the rules in internal/rowrules, so they
+// cannot disagree. Problems in a struct declared in the analyzed package are reported at the
+// offending field; problems in a struct declared elsewhere are reported at the call.
+//
+// Serializers registered with RegisterTypeSerializer(reflect.TypeOf(...)) in the analyzed
+// package or one of its dependencies are taken into account. Registrations made in a package
+// the analyzed package does not import are invisible to the analyzer, which then reports the
+// field types they cover.
+//
+// Run it with go vet:
+//
+//	go install github.com/117503445/otsutils/cmd/rowcheck
+//	go vet -vettool=$(which rowcheck) ./...
+package rowcheck
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/117503445/otsutils"
+	"github.com/117503445/otsutils/internal/rowrules"
+)
+
+// otsutilsPath is the import path of the package whose calls are inspected.
+const otsutilsPath = "github.com/117503445/otsutils"
+
+// Analyzer reports row struct types that the otsutils runtime would reject.
+var Analyzer = &analysis.Analyzer{
+	Name:      "rowcheck",
+	Doc:       "report row struct types passed to otsutils that the runtime metadata validator would reject",
+	URL:       "https://pkg.go.dev/github.com/117503445/otsutils/rowcheck",
+	Requires:  []*analysis.Analyzer{inspect.Analyzer},
+	Run:       run,
+	FactTypes: []analysis.Fact{new(serializersFact)},
+}
+
+// serializersFact lists the types a package registers serializers for, by typeKey.
+type serializersFact struct {
+	Types []string
+}
+
+func (*serializersFact) AFact() {}
+
+func (f *serializersFact) String() string {
+	return "serializers(" + strings.Join(f.Types, ", ") + ")"
+}
+
+// rowArgs maps the otsutils functions taking row structs to the indexes of those arguments.
+// For ApplyToStruct, a method, the indexes count the arguments after the receiver.
+var rowArgs = map[string][]int{
+	"PutRow":                  {1},
+	"GetRow":                  {1},
+	"UpdateRow":               {1},
+	"DeleteRow":               {1},
+	"ExistsRow":               {1},
+	"PutRowSwap":              {1, 2},
+	"Upsert":                  {1},
+	"GetRange":                {1, 2, 3},
+	"RangeRows":               {1, 2},
+	"QueryByPkPrefix":         {1, 2},
+	"CountRange":              {1, 2},
+	"DeleteRange":             {1},
+	"ParallelScan":            {1},
+	"QuerySQL":                {2},
+	"ParseObj":                {1},
+	"ParseResult":             {1},
+	"CheckType":               {0},
+	"MustRegister":            {0},
+	"FromStruct":              {0},
+	"ApplyToStruct":           {0},
+	"DeleteColumnsIfPresent":  {1},
+	"CreateTableFromStruct":   {1},
+	"ValidateSchema":          {1},
+	"EnsureTable":             {1},
+	"CreateIndexesFromStruct": {1},
+	"GetRowsByIndex":          {2, 3},
+	"Search":                  {3},
+	"SearchAll":               {3},
+	"ReadModifyWrite":         {1},
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	nodeFilter := []ast.Node{(*ast.CallExpr)(nil)}
+
+	// Registrations apply to the whole program, so collect them before checking any call
+	serializable := make(map[string]bool)
+	for _, f := range pass.AllPackageFacts() {
+		for _, key := range f.Fact.(*serializersFact).Types {
+			serializable[key] = true
+		}
+	}
+	var registered []string
+	ins.Preorder(nodeFilter, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		if otsutilsCallee(pass, call) != "RegisterTypeSerializer" || len(call.Args) == 0 {
+			return
+		}
+		if t := reflectTypeOfArg(pass, call.Args[0]); t != nil {
+			key := typeKey(t)
+			if !serializable[key] {
+				serializable[key] = true
+				registered = append(registered, key)
+			}
+		}
+	})
+	if len(registered) > 0 {
+		slices.Sort(registered)
+		pass.ExportPackageFact(&serializersFact{Types: registered})
+	}
+
+	c := &checker{pass: pass, serializable: serializable, checked: make(map[string]bool)}
+	ins.Preorder(nodeFilter, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		name := otsutilsCallee(pass, call)
+		if rowArgs[name] == nil {
+			return
+		}
+		sig, _ := pass.TypesInfo.TypeOf(call.Fun).(*types.Signature)
+		for _, i := range rowArgs[name] {
+			if i >= len(call.Args) {
+				continue
+			}
+			// Generic functions fix the row type in their instantiated signature,
+			// which also covers nil boundaries
+			t := pass.TypesInfo.TypeOf(call.Args[i])
+			if sig != nil && i < sig.Params().Len() && !types.IsInterface(sig.Params().At(i).Type()) {
+				t = sig.Params().At(i).Type()
+			}
+			if name == "GetRange" && i == 3 || name == "QueryByPkPrefix" && i == 2 || name == "QuerySQL" ||
+				name == "GetRowsByIndex" && i == 3 || name == "Search" {
+				t = sliceElem(t)
+			}
+			if ch, ok := types.Unalias(t).(*types.Chan); ok && name == "ParallelScan" {
+				t = ch.Elem()
+			}
+			if fn, ok := types.Unalias(t).(*types.Signature); ok && name == "SearchAll" && fn.Params().Len() == 1 {
+				t = fn.Params().At(0).Type()
+			}
+			c.check(call.Args[i], t)
+		}
+	})
+	return nil, nil
+}
+
+// otsutilsCallee returns the name of the otsutils function or method called by call.
+func otsutilsCallee(pass *analysis.Pass, call *ast.CallExpr) string {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != otsutilsPath {
+		return ""
+	}
+	return fn.Name()
+}
+
+// reflectTypeOfArg returns the type described by expr when it is reflect.TypeOf(x) or
+// reflect.TypeOf(x).Elem(), and nil otherwise.
+func reflectTypeOfArg(pass *analysis.Pass, expr ast.Expr) types.Type {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok {
+		return nil
+	}
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Elem" && len(call.Args) == 0 {
+		if ptr, ok := reflectTypeOfArg(pass, sel.X).(*types.Pointer); ok {
+			return ptr.Elem()
+		}
+		return nil
+	}
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != "reflect" || fn.Name() != "TypeOf" || len(call.Args) != 1 {
+		return nil
+	}
+	return pass.TypesInfo.TypeOf(call.Args[0])
+}
+
+// sliceElem returns the element type of t, a pointer to a slice, or nil.
+func sliceElem(t types.Type) types.Type {
+	ptr, ok := types.Unalias(t).(*types.Pointer)
+	if !ok {
+		return nil
+	}
+	slice, ok := ptr.Elem().Underlying().(*types.Slice)
+	if !ok {
+		return nil
+	}
+	return slice.Elem()
+}
+
+// checker checks each row struct type once per package.
+type checker struct {
+	pass         *analysis.Pass
+	serializable map[string]bool
+	checked      map[string]bool
+}
+
+// check reports the problems of the struct type t, or the struct t points to, passed as arg.
+// Other types, such as interfaces and *PrimaryKeyBuilder boundaries, are left to the runtime.
+func (c *checker) check(arg ast.Expr, t types.Type) {
+	if t == nil {
+		return
+	}
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	st, ok := t.Underlying().(*types.Struct)
+	if !ok {
+		return
+	}
+	key := typeKey(t)
+	if c.checked[key] {
+		return
+	}
+	c.checked[key] = true
+
+	fields := rowrules.Promote(c.describeFields(st, map[string]bool{key: true}, 0), "_")
+	res := rowrules.Check(fields, rowrules.Limits{
+		MaxPrimaryKeyColumns: otsutils.MaxPrimaryKeyColumns,
+		MaxColumnNameSize:    otsutils.MaxColumnNameSize,
+	})
+	for _, p := range res.Problems {
+		pos := token.NoPos
+		if p.Field >= 0 {
+			// A field promoted from an embedded struct is reported at the embedding field
+			pos = st.Field(fields[p.Field].Path[0]).Pos()
+		} else if named, ok := types.Unalias(t).(*types.Named); ok {
+			pos = named.Obj().Pos()
+		}
+		if c.inPackage(pos) {
+			c.pass.Reportf(pos, "%s", p.Err)
+		} else {
+			c.pass.Reportf(arg.Pos(), "type %s: %s", typeName(t), p.Err)
+		}
+	}
+}
+
+// describeFields describes the fields of the struct st for the rules in internal/rowrules,
+// along with the fields of the structs it embeds or flattens, as the runtime does with reflect.
+// embedding holds the keys of the struct types being embedded, so that a struct embedding
+// itself is not described again, and flattenDepth the number of flattened structs st is nested
+// in. The default flatten separator "_" is assumed.
+func (c *checker) describeFields(st *types.Struct, embedding map[string]bool, flattenDepth int) []rowrules.Field {
+	fields := make([]rowrules.Field, st.NumFields())
+	for i := range fields {
+		f := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i))
+		prefixTag, hasPrefix := tag.Lookup("pkprefix")
+		fields[i] = rowrules.Field{
+			Name:           f.Name(),
+			Exported:       f.Exported(),
+			Type:           c.ruleType(f.Type()),
+			JSONTag:        tag.Get("json"),
+			PkTag:          tag.Get("pk"),
+			UntaggedColumn: strings.ToLower(f.Name()),
+			PkPrefixTag:    prefixTag,
+			HasPkPrefix:    hasPrefix,
+			IndexTag:       tag.Get("index"),
+			OtsTag:         tag.Get("ots"),
+			OtsTypeTag:     tag.Get("otstype"),
+			Anonymous:      f.Embedded(),
+		}
+		base := f.Type()
+		if ptr, ok := base.Underlying().(*types.Pointer); ok {
+			base = ptr.Elem()
+		}
+		nested, ok := base.Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		switch {
+		case f.Embedded():
+			fields[i].Fields = []rowrules.Field{}
+			if key := typeKey(base); !embedding[key] {
+				embedding[key] = true
+				fields[i].Fields = c.describeFields(nested, embedding, flattenDepth)
+				delete(embedding, key)
+			}
+		case fields[i].OtsTag == rowrules.OtsFlatten && flattenDepth < rowrules.MaxFlattenDepth:
+			fields[i].Fields = c.describeFields(nested, map[string]bool{}, flattenDepth+1)
+		}
+	}
+	return fields
+}
+
+// inPackage reports whether pos is in one of the files of the analyzed package.
+func (c *checker) inPackage(pos token.Pos) bool {
+	if !pos.IsValid() {
+		return false
+	}
+	for _, f := range c.pass.Files {
+		if f.FileStart <= pos && pos <= f.FileEnd {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleType describes the field type t for the rules in internal/rowrules, using the same
+// names and kinds as reflect so that problems read the same at build time and at runtime.
+func (c *checker) ruleType(t types.Type) rowrules.Type {
+	rt := rowrules.Type{Name: typeName(t), Serializable: c.serializable[typeKey(t)]}
+	base := t
+	for {
+		ptr, ok := base.Underlying().(*types.Pointer)
+		if !ok {
+			break
+		}
+		if rt.Pointers == 0 && c.serializable[typeKey(ptr.Elem())] {
+			rt.Serializable = true
+		}
+		base = ptr.Elem()
+		rt.Pointers++
+	}
+	rt.Base = typeName(base)
+	methodBase := t
+	if ptr, ok := t.Underlying().(*types.Pointer); ok {
+		methodBase = ptr.Elem()
+	}
+	rt.Marshaler = types.Implements(types.NewPointer(methodBase), otsMarshalerType)
+	rt.Unmarshaler = types.Implements(types.NewPointer(methodBase), otsUnmarshalerType)
+	rt.SQLNull = c.sqlNull(methodBase)
+	rt.OtsNull = c.otsNull(t)
+	rt.UUID = isUUID(methodBase)
+
+	switch u := base.Underlying().(type) {
+	case *types.Basic:
+		switch info := u.Info(); {
+		case u.Kind() == types.String:
+			rt.BaseKind = rowrules.KindString
+		case u.Kind() == types.Int64:
+			rt.BaseKind = rowrules.KindInt64
+		case u.Kind() == types.Float64:
+			rt.BaseKind = rowrules.KindFloat64
+		case u.Kind() == types.UnsafePointer:
+			rt.BaseKind = rowrules.KindUnsafePointer
+		case u.Kind() == types.Uintptr:
+			rt.BaseKind = rowrules.KindOther
+		case u.Kind() == types.Uint || u.Kind() == types.Uint64:
+			rt.BaseKind = rowrules.KindUint64
+		case info&types.IsInteger != 0:
+			rt.BaseKind = rowrules.KindInt
+		case info&types.IsFloat != 0:
+			rt.BaseKind = rowrules.KindFloat
+		case info&types.IsBoolean != 0:
+			rt.BaseKind = rowrules.KindBool
+		default:
+			rt.BaseKind = rowrules.KindOther
+		}
+	case *types.Slice:
+		rt.BaseKind = rowrules.KindOther
+		if elem, ok := u.Elem().Underlying().(*types.Basic); ok && elem.Kind() == types.Uint8 {
+			rt.BaseKind = rowrules.KindBytes
+		}
+	case *types.Struct:
+		rt.BaseKind = rowrules.KindStruct
+	case *types.Map:
+		rt.BaseKind = rowrules.KindMap
+		rt.AnyMap = types.Identical(t, anyMapType)
+	case *types.Interface:
+		rt.BaseKind = rowrules.KindInterface
+	case *types.Chan:
+		rt.BaseKind = rowrules.KindChan
+	case *types.Signature:
+		rt.BaseKind = rowrules.KindFunc
+	default:
+		rt.BaseKind = rowrules.KindOther
+	}
+	return rt
+}
+
+// anyMapType is map[string]any, the type of the extra field.
+var anyMapType = types.NewMap(types.Typ[types.String], types.NewInterfaceType(nil, nil).Complete())
+
+// otsMarshalerType and otsUnmarshalerType are the otsutils.OTSMarshaler and
+// otsutils.OTSUnmarshaler interfaces, which types implement by their method sets alone.
+var (
+	otsMarshalerType = methodInterface("MarshalOTS", nil,
+		[]*types.Var{param(types.NewInterfaceType(nil, nil).Complete()), param(types.Universe.Lookup("error").Type())})
+	otsUnmarshalerType = methodInterface("UnmarshalOTS",
+		[]*types.Var{param(types.NewInterfaceType(nil, nil).Complete())}, []*types.Var{param(types.Universe.Lookup("error").Type())})
+)
+
+// textMarshalerType and textUnmarshalerType are the encoding.TextMarshaler and
+// encoding.TextUnmarshaler interfaces.
+var (
+	textMarshalerType = methodInterface("MarshalText", nil,
+		[]*types.Var{param(types.NewSlice(types.Typ[types.Byte])), param(types.Universe.Lookup("error").Type())})
+	textUnmarshalerType = methodInterface("UnmarshalText",
+		[]*types.Var{param(types.NewSlice(types.Typ[types.Byte]))}, []*types.Var{param(types.Universe.Lookup("error").Type())})
+)
+
+// isUUID reports whether t is a [16]byte array implementing encoding.TextMarshaler and
+// encoding.TextUnmarshaler, such as uuid.UUID, as the runtime does.
+func isUUID(t types.Type) bool {
+	arr, ok := t.Underlying().(*types.Array)
+	if !ok || arr.Len() != 16 {
+		return false
+	}
+	if elem, ok := arr.Elem().Underlying().(*types.Basic); !ok || elem.Kind() != types.Uint8 {
+		return false
+	}
+	return types.Implements(types.NewPointer(t), textMarshalerType) && types.Implements(types.NewPointer(t), textUnmarshalerType)
+}
+
+// methodInterface returns an interface with the single method name.
+func methodInterface(name string, params, results []*types.Var) *types.Interface {
+	sig := types.NewSignatureType(nil, nil, nil, types.NewTuple(params...), types.NewTuple(results...), false)
+	return types.NewInterfaceType([]*types.Func{types.NewFunc(token.NoPos, nil, name, sig)}, nil).Complete()
+}
+
+// param returns an unnamed parameter of type t.
+func param(t types.Type) *types.Var {
+	return types.NewParam(token.NoPos, nil, "", t)
+}
+
+// sqlNull reports whether t is one of the null types of database/sql, such as sql.NullString
+// or sql.Null[T], holding a value a column can hold, as the runtime does.
+func (c *checker) sqlNull(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != "database/sql" || !strings.HasPrefix(named.Obj().Name(), "Null") {
+		return false
+	}
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok || st.NumFields() != 2 || st.Field(1).Name() != "Valid" {
+		return false
+	}
+	vt := c.ruleType(st.Field(0).Type())
+	return vt.Pointers == 0 && (vt.Base == "time.Time" || vt.BaseKind == rowrules.KindBool || vt.Native())
+}
+
+// otsNull reports whether t is otsutils.Null[T] of a T a column can hold, as the runtime does.
+func (c *checker) otsNull(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || named.Obj().Pkg().Path() != otsutilsPath || named.Obj().Name() != "Null" || named.TypeArgs().Len() != 1 {
+		return false
+	}
+	vt := c.ruleType(named.TypeArgs().At(0))
+	return vt.Pointers == 0 && vt.Native()
+}
+
+// typeKey identifies t across packages, qualifying named types by import path.
+func typeKey(t types.Type) string {
+	return types.TypeString(t, nil)
+}
+
+// typeName formats t the way reflect.Type.String does, e.g. "[]uint8" for []byte and
+// "time.Time" for a named type. Types reflect formats differently from go/types, such as
+// non-empty interface and struct literals, fall back to the go/types form.
+func typeName(t types.Type) string {
+	switch t := types.Unalias(t).(type) {
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() == nil {
+			return obj.Name()
+		}
+		return types.TypeString(t, func(p *types.Package) string { return p.Name() })
+	case *types.Basic:
+		if t.Kind() == types.UnsafePointer {
+			return "unsafe.Pointer"
+		}
+		return types.Typ[t.Kind()].Name()
+	case *types.Pointer:
+		return "*" + typeName(t.Elem())
+	case *types.Slice:
+		return "[]" + typeName(t.Elem())
+	case *types.Array:
+		return "[" + strconv.FormatInt(t.Len(), 10) + "]" + typeName(t.Elem())
+	case *types.Map:
+		return "map[" + typeName(t.Key()) + "]" + typeName(t.Elem())
+	case *types.Chan:
+		switch t.Dir() {
+		case types.SendOnly:
+			return "chan<- " + typeName(t.Elem())
+		case types.RecvOnly:
+			return "<-chan " + typeName(t.Elem())
+		}
+		return "chan " + typeName(t.Elem())
+	case *types.Interface:
+		if t.Empty() {
+			return "interface {}"
+		}
+	case *types.Struct:
+		if t.NumFields() == 0 {
+			return "struct {}"
+		}
+	}
+	return types.TypeString(t, func(p *types.Package) string { return p.Name() })
+}
@@ -0,0 +1,14 @@
+package rowcheck_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/117503445/otsutils/rowcheck"
+)
+
+// TestAnalyzer 在 testdata/src/a 的注解夹具上运行分析器，每个 want 注释对应一条诊断
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), rowcheck.Analyzer, "a")
+}
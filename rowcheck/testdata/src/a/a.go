@@ -0,0 +1,284 @@
+package a // want package:`serializers\(a\.Money\)`
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"time"
+	"unsafe"
+
+	"b"
+	_ "ser"
+
+	"github.com/117503445/otsutils"
+)
+
+type Valid struct {
+	ID      *string        `json:"id" pk:"1" pkprefix:"md5:4"`
+	Seq     *int64         `json:"seq" pk:"2,auto"`
+	Name    *string        `json:"name,omitempty"`
+	Data    *[]byte        `json:"data"`
+	Created *time.Time     `json:"created"`
+	Price   *Money         `json:"price"`
+	Alias   *string        `json:",omitempty"`
+	Skipped int            `json:"-"`
+	Untyped map[string]int `json:"-"`
+	Plain   *string        // no json tag, maps to the column "plain"
+	note    string
+}
+
+type Money struct {
+	Cents int64
+}
+
+type Unexported struct {
+	ID   *string `json:"id" pk:"1"`
+	name *string `json:"name"` // want `field name is unexported but has a json or pk tag; export it or remove the tags`
+}
+
+type NoJSON struct {
+	ID *string `json:"-" pk:"1"` // want `field ID has a pk tag but is excluded from the columns by its json tag`
+}
+
+type BadColumn struct {
+	ID   *string `json:"id" pk:"1"`
+	Name *string `json:"bad-name"` // want `field Name: column name "bad-name" contains '-' at byte 3; only letters, digits and underscores are allowed`
+	Num  *string `json:"1st"`      // want `field Num: column name "1st" must start with a letter or underscore`
+}
+
+type DuplicateColumn struct {
+	A *string `json:"x"`
+	B *string `json:"x"` // want `field B maps to column "x", which is already used by field A`
+}
+
+type Types struct {
+	Uint   uint64         `json:"uint"` // want `field Uint has invalid type: uint64\. Only string, int64, float64, and \[\]byte fields and pointers to them are allowed; Tablestore integers are signed, use int64 instead of uint64$`
+	Day    *time.Weekday  `json:"day"`
+	Float  *float32       `json:"float"` // want `field Float has invalid type: \*float32\. .*; use \*float64 instead of \*float32$`
+	Str    string         `json:"str"`
+	PtrPtr **int64        `json:"ptrptr"` // want `field PtrPtr has invalid type: \*\*int64\. .*; use \*int64 instead of \*\*int64$`
+	Bytes  []byte         `json:"bytes"`
+	Map    map[string]int `json:"map"`    // want `field Map has invalid type: map\[string\]int\. .*; map fields are not supported, register a serializer for map\[string\]int with RegisterTypeSerializer$`
+	Iface  any            `json:"iface"`  // want `field Iface has invalid type: interface \{\}\. .*; interface fields are not supported, use a concrete type$`
+	Chan   chan int       `json:"chan"`   // want `field Chan has invalid type: chan int\. .*; chan fields cannot be stored in a column$`
+	Func   func()         `json:"func"`   // want `field Func has invalid type: func\(\)\. .*; func fields cannot be stored in a column$`
+	Unsafe unsafe.Pointer `json:"unsafe"` // want `field Unsafe has invalid type: unsafe\.Pointer\. .*; unsafe\.Pointer fields cannot be stored in a column$`
+	Nested *Valid         `json:"nested"` // want `field Nested has invalid type: \*a\.Valid\. .*; nested structs are not supported, register a serializer for a\.Valid with RegisterTypeSerializer$`
+}
+
+type FloatPk struct {
+	ID *float64 `json:"id" pk:"1"` // want `field ID has invalid type: \*float64\. .*; primary key columns can only hold string, integer or binary values, so float64 cannot be a primary key$`
+}
+
+type BadPkTags struct {
+	A *string `json:"a" pk:"0"`     // want `field A: invalid pk tag "0": order "0" must be a positive integer`
+	B *string `json:"b" pk:"1,foo"` // want `field B: invalid pk tag "1,foo": unknown option "foo"`
+}
+
+type BadPkPrefix struct {
+	ID   *string `json:"id" pk:"1" pkprefix:"crc:4"`  // want `field ID: invalid pkprefix tag "crc:4": unknown hash "crc", supported are md5, sha1, sha256`
+	Num  *int64  `json:"num" pk:"2" pkprefix:"md5:4"` // want `field Num: pkprefix is only allowed on \*string fields, got \*int64`
+	Name *string `json:"name" pkprefix:"md5:4"`       // want `field Name: pkprefix is only allowed on primary key fields`
+}
+
+type TooManyPks struct { // want `5 pk-tagged fields A, B, C, D, E exceed the maximum of 4`
+	A *string `json:"a" pk:"1"`
+	B *string `json:"b" pk:"2"`
+	C *string `json:"c" pk:"3"`
+	D *string `json:"d" pk:"4"`
+	E *string `json:"e" pk:"5"`
+}
+
+type PkLayout struct {
+	A *string `json:"a" pk:"1"`
+	B *string `json:"b" pk:"1"`      // want `field B: invalid pk tag "1": order 1 is already used by field A`
+	C *int64  `json:"c" pk:"2,auto"` // want `field C: invalid pk tag "2,auto": auto is only allowed on the last primary key field`
+	D *string `json:"d" pk:"3,auto"` // want `field D: invalid pk tag "3,auto": auto is only allowed on \*int64 fields, got \*string`
+}
+
+type PkGap struct {
+	A *string `json:"a" pk:"1"`
+	C *string `json:"c" pk:"3"` // want `field C: invalid pk tag "3": no field has pk order 2, pk orders must run from 1 without gaps`
+	X *string `json:"x" pk:"x"` // want `field X: invalid pk tag "x": order "x" must be a positive integer`
+}
+
+type Extras struct {
+	ID      *string           `json:"id" pk:"1"`
+	Extra   map[string]any    `ots:"extra"`
+	Again   map[string]any    `ots:"extra"`  // want `field Again: ots tag "extra" is already used by field Extra`
+	Strings map[string]string `ots:"extra"`  // want `field Strings: the extra field must be a map\[string\]any, got map\[string\]string`
+	Typo    map[string]any    `ots:"extras"` // want `field Typo: unknown ots tag "extras"`
+}
+
+type Base struct {
+	Count   *int    `json:"count"` // hidden by WithBase.Count, so never used
+	Created *uint64 `json:"created"`
+}
+
+type WithBase struct {
+	ID    *string `json:"id" pk:"1"`
+	*Base         // want `field Created has invalid type: \*uint64\. .*; Tablestore integers are signed, use \*int64 instead of \*uint64$`
+	Count *int64  `json:"count"`
+}
+
+type Address struct {
+	City *string `json:"city"`
+	Zip  *uint64 `json:"zip"`
+	Key  *string `json:"key" pk:"2"`
+}
+
+type Flattened struct {
+	ID      *string  `json:"id" pk:"1"`
+	Address Address  `json:"addr" ots:"flatten"` // want `field Zip has invalid type: \*uint64\.` `field Key: pk tags are not allowed inside flattened structs`
+	Tags    []string `ots:"flatten"`             // want `field Tags: ots tag "flatten" is only allowed on struct fields and pointers to them, got \[\]string`
+	City    *string  `json:"addr_city"`          // want `field City maps to column "addr_city", which is already used by field City`
+}
+
+type Documents struct {
+	ID     *string        `json:"id" pk:"1"`
+	Tags   []string       `json:"tags" otstype:"json"`
+	Config map[string]any `json:"config" otstype:"json"`
+	Key    []string       `json:"key" otstype:"json" index:"idx_key,1"` // want `field Key: otstype "json" can not be used with pk, pkprefix or index tags`
+	Typo   []string       `json:"typo" otstype:"jsn"`                   // want `field Typo: unknown otstype tag "jsn"`
+}
+
+type Cents int64
+
+func (c Cents) MarshalOTS() (any, error) { return int64(c), nil }
+
+func (c *Cents) UnmarshalOTS(value any) error { return nil }
+
+type WriteOnly struct{}
+
+func (WriteOnly) MarshalOTS() (any, error) { return "", nil }
+
+type Marshaled struct {
+	ID    Cents      `json:"id" pk:"1"`
+	Price *Cents     `json:"price"`
+	Bad   *WriteOnly `json:"bad"` // want `field Bad: type a.WriteOnly implements OTSMarshaler but not OTSUnmarshaler, implement both or neither`
+}
+
+type Nullable struct {
+	ID      sql.NullString     `json:"id" pk:"1"` // want `field ID: the database/sql null type sql.NullString can not be a primary key field`
+	Name    sql.NullString     `json:"name"`
+	Count   *sql.NullInt32     `json:"count"`
+	Seen    sql.NullTime       `json:"seen"`
+	Active  sql.Null[bool]     `json:"active"`
+	Unknown sql.Null[[]string] `json:"unknown"` // want `field Unknown has invalid type: sql\.Null\[\[\]string\]\.`
+}
+
+type Deletable struct {
+	ID       otsutils.Null[string]   `json:"id" pk:"1"` // want `field ID: a Null field can not be a primary key field`
+	Nickname otsutils.Null[string]   `json:"nickname"`
+	Age      otsutils.Null[int32]    `json:"age"`
+	Tags     otsutils.Null[[]string] `json:"tags"` // want `field Tags has invalid type: otsutils\.Null\[\[\]string\]\.`
+}
+
+// UUID stands for uuid.UUID of github.com/google/uuid.
+type UUID [16]byte
+
+func (u UUID) MarshalText() ([]byte, error)     { return nil, nil }
+func (u *UUID) UnmarshalText(text []byte) error { return nil }
+
+type Identified struct {
+	ID      UUID    `json:"id" pk:"1"`
+	Raw     UUID    `json:"raw" pk:"2" otstype:"binary"`
+	Parent  *UUID   `json:"parent" otstype:"binary"`
+	Twice   **UUID  `json:"twice"`                 // want `field Twice has invalid type: \*\*a\.UUID\.`
+	Name    string  `json:"name" otstype:"binary"` // want `field Name: otstype "binary" is only allowed on UUID fields, \[16\]byte types implementing encoding\.TextMarshaler and encoding\.TextUnmarshaler, got string`
+	Payload [16]int `json:"payload"`               // want `field Payload has invalid type: \[16\]int\.`
+}
+
+type BadGen struct {
+	ID *int64 `json:"id" pk:"1,gen=ulid"` // want `field ID: invalid pk tag "1,gen=ulid": gen is only allowed on \*string fields, got \*int64`
+}
+
+type RangeRow struct {
+	ID    *string `json:"id" pk:"1"`
+	Score float32 `json:"score"` // want `field Score has invalid type: float32\.`
+}
+
+type IterRow struct {
+	ID *string `json:"id" pk:"1"`
+	N  *int32  `json:"n"`
+	U  **uint8 `json:"u"` // want `field U has invalid type: \*\*uint8\. .*; use \*uint8 instead of \*\*uint8$`
+}
+
+type SearchHit struct {
+	ID  *string `json:"id" pk:"1"`
+	Age []int64 `json:"age"` // want `field Age has invalid type: \[\]int64\.`
+}
+
+type ExportRow struct {
+	ID   *string `json:"id" pk:"1"`
+	Tags []int   `json:"tags"` // want `field Tags has invalid type: \[\]int\.`
+}
+
+type Account struct {
+	ID      *string `json:"id" pk:"1"`
+	Balance float32 `json:"balance"` // want `field Balance has invalid type: float32\.`
+}
+
+type ScanRow struct {
+	ID *string `json:"id" pk:"1"`
+	N  *uint   `json:"n"` // want `field N has invalid type: \*uint\. .*; Tablestore integers are signed, use \*int64 instead of \*uint$`
+}
+
+type Boundary struct {
+	ID *string `json:"id" pk:"1,auto"` // want `field ID: invalid pk tag "1,auto": auto is only allowed on \*int64 fields, got \*string`
+}
+
+type BadIndexes struct { // want `index idx_b has no field with order 1: index orders must run from 1 without gaps`
+	ID    *string  `json:"id" pk:"1"`
+	Email *string  `json:"email" index:"idx_a"`          // want `field Email: invalid index tag "idx_a": want <name>,<order>, got "idx_a"`
+	Score *float64 `json:"score" index:"idx_a,1"`        // want `field Score: index is only allowed on string, int64 and \[\]byte fields, got \*float64`
+	Name  *string  `json:"name" index:"idx_b,2;idx_b,3"` // want `field Name: invalid index tag "idx_b,2;idx_b,3": index idx_b is repeated`
+	Nick  *string  `json:"nick" index:"idx_b,2"`
+	City  *string  `json:"city" index:"idx_c,1"`
+	Town  *string  `json:"town" index:"idx_c,1"` // want `field Town: invalid index tag "idx_c,1": order 1 of index idx_c is already used by field City`
+}
+
+func init() {
+	otsutils.RegisterTypeSerializer(reflect.TypeOf((*Money)(nil)).Elem(), nil, nil)
+}
+
+func use(ctx context.Context) {
+	_ = otsutils.PutRow(ctx, &Valid{})
+	_ = otsutils.GetRow(ctx, &Valid{}) // each type is checked once
+	_ = otsutils.UpdateRow(ctx, &Unexported{})
+	_ = otsutils.DeleteRow(ctx, &NoJSON{})
+	_, _ = otsutils.ExistsRow(ctx, &Valid{})
+	_ = otsutils.PutRowSwap(ctx, &BadColumn{}, &DuplicateColumn{})
+	_ = otsutils.CheckType(Types{})
+	otsutils.MustRegister(&FloatPk{})
+	_, _, _ = otsutils.ParseObj(ctx, &BadPkTags{})
+	_ = otsutils.ParseResult(ctx, &BadPkPrefix{}, nil, nil)
+	_ = otsutils.FromStruct(&TooManyPks{})
+	_ = otsutils.PK().ApplyToStruct(&PkLayout{})
+	_, _ = otsutils.DeleteColumnsIfPresent(ctx, &BadGen{}, "col")
+	_ = otsutils.DeleteRow(ctx, &PkGap{})
+	_ = otsutils.PutRow(ctx, &Extras{})
+	_ = otsutils.PutRow(ctx, &WithBase{})
+	_ = otsutils.PutRow(ctx, &Flattened{})
+	_ = otsutils.PutRow(ctx, &Documents{})
+	_ = otsutils.PutRow(ctx, &Marshaled{})
+	_ = otsutils.PutRow(ctx, &Nullable{})
+	_ = otsutils.UpdateRow(ctx, &Deletable{})
+	_ = otsutils.PutRow(ctx, &Identified{})
+	_ = otsutils.CreateIndexesFromStruct(ctx, &BadIndexes{})
+
+	var rows []RangeRow
+	_ = otsutils.GetRange(ctx, &Boundary{}, otsutils.PK(), &rows)
+	for range otsutils.RangeRows[IterRow](ctx, nil, nil) {
+	}
+	_ = otsutils.ParallelScan(ctx, make(chan ScanRow), otsutils.ParallelScanOptions{})
+	var hits []SearchHit
+	_, _ = otsutils.Search(ctx, "index", nil, &hits)
+	_, _ = otsutils.SearchAll(ctx, "index", nil, func(row *ExportRow) error { return nil })
+	_ = otsutils.ReadModifyWrite(ctx, &Account{}, nil)
+
+	_ = otsutils.PutRow(ctx, &b.Row{}) // want `type b\.Row: field Count has invalid type: uint\. .*; Tablestore integers are signed, use int64 instead of uint$`
+
+	var obj any = &Types{}
+	_ = otsutils.PutRow(ctx, obj) // dynamic types are left to the runtime
+}
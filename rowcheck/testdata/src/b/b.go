@@ -0,0 +1,7 @@
+// Package b declares a row struct outside the package that uses it.
+package b
+
+type Row struct {
+	ID    *string `json:"id" pk:"1"`
+	Count uint    `json:"count"`
+}
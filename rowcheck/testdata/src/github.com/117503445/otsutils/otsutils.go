@@ -0,0 +1,71 @@
+// Package otsutils is a stub of the otsutils API used by the rowcheck fixtures.
+package otsutils
+
+import (
+	"context"
+	"iter"
+	"reflect"
+)
+
+type KeyValue struct {
+	Key   string
+	Value any
+}
+
+type PutRowParams struct{}
+type GetRowParams struct{}
+type UpdateRowParams struct{}
+type DeleteRowParams struct{}
+type GetRangeParams struct{}
+
+type ParallelScanOptions struct{}
+
+type PrimaryKeyBuilder struct{}
+
+type Null[T any] struct {
+	v     T
+	state uint8
+}
+
+func PutRow(ctx context.Context, obj any, params ...PutRowParams) error       { return nil }
+func GetRow(ctx context.Context, obj any, params ...GetRowParams) error       { return nil }
+func UpdateRow(ctx context.Context, obj any, params ...UpdateRowParams) error { return nil }
+func DeleteRow(ctx context.Context, obj any, params ...DeleteRowParams) error { return nil }
+func ExistsRow(ctx context.Context, obj any) (bool, error)                    { return false, nil }
+func Upsert(ctx context.Context, obj any) (bool, error)                       { return false, nil }
+func PutRowSwap(ctx context.Context, obj any, old any, params ...PutRowParams) error {
+	return nil
+}
+func GetRange(ctx context.Context, start any, end any, out any, params ...GetRangeParams) error {
+	return nil
+}
+func RangeRows[T any](ctx context.Context, start, end *T, params ...GetRangeParams) iter.Seq2[*T, error] {
+	return nil
+}
+func ParallelScan[T any](ctx context.Context, out chan<- T, opts ParallelScanOptions) error {
+	return nil
+}
+func ParseObj(ctx context.Context, obj any) (pks []KeyValue, cols []KeyValue, err error) {
+	return nil, nil, nil
+}
+func ParseResult(ctx context.Context, obj any, pks []KeyValue, cols []KeyValue) error {
+	return nil
+}
+func CheckType(obj any) error                            { return nil }
+func MustRegister(obj any)                               {}
+func PK() *PrimaryKeyBuilder                             { return nil }
+func FromStruct(obj any) *PrimaryKeyBuilder              { return nil }
+func (b *PrimaryKeyBuilder) ApplyToStruct(obj any) error { return nil }
+func DeleteColumnsIfPresent(ctx context.Context, pkObj any, columns ...string) ([]string, error) {
+	return nil, nil
+}
+func CreateIndexesFromStruct(ctx context.Context, obj any) error { return nil }
+func Search(ctx context.Context, indexName string, query any, out any) (any, error) {
+	return nil, nil
+}
+func SearchAll[T any](ctx context.Context, indexName string, query any, fn func(T) error) (any, error) {
+	return nil, nil
+}
+func ReadModifyWrite(ctx context.Context, obj any, modify func() error) error { return nil }
+func RegisterTypeSerializer(t reflect.Type, toColumn func(any) (any, error), fromColumn func(any) (any, error)) {
+}
@@ -0,0 +1,13 @@
+// Package ser registers a serializer the rowcheck fixtures rely on.
+package ser
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/117503445/otsutils"
+)
+
+func init() {
+	otsutils.RegisterTypeSerializer(reflect.TypeOf(time.Time{}), nil, nil)
+}
@@ -0,0 +1,92 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SchemaError is returned by ValidateSchema when a row struct does not match the primary key of
+// a table. It lists every mismatch found.
+type SchemaError struct {
+	TableName string
+	Type      reflect.Type
+	Problems  []error
+}
+
+func (e *SchemaError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return fmt.Sprintf("type %s does not match the primary key of table '%s': %s", e.Type, e.TableName, strings.Join(msgs, "; "))
+}
+
+func (e *SchemaError) Unwrap() []error {
+	return e.Problems
+}
+
+// ValidateSchema checks that the pk fields of obj, a row struct or pointer to one, describe the
+// primary key of the table in the context: the same columns, in the same order, with the same
+// types and AUTO_INCREMENT option. Fields of types handled by a registered serializer are only
+// checked by name. Every mismatch is reported in a single *SchemaError, so that calling it at
+// startup catches a misplaced pk tag before the first write. The table description is read
+// with TableMeta.
+//
+// Example usage:
+//
+//	if err := ValidateSchema(ctx, &MyRow{}); err != nil {
+//	    log.Fatal().Err(err).Msg("MyRow does not match its table")
+//	}
+func ValidateSchema(ctx context.Context, obj any) error {
+	t := reflect.TypeOf(obj)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("obj must be a struct or pointer to struct, got %T", obj)
+	}
+	meta, err := getStructMeta(t)
+	if err != nil {
+		return err
+	}
+	desc, err := TableMeta(ctx)
+	if err != nil {
+		return err
+	}
+
+	var problems []error
+	for n, i := range meta.pkFields {
+		fm := &meta.fields[i]
+		if n >= len(desc.PrimaryKeys) {
+			problems = append(problems, fmt.Errorf("field %s is primary key column %d, the table has %d", fm.name, n+1, len(desc.PrimaryKeys)))
+			continue
+		}
+		live := desc.PrimaryKeys[n]
+		if fm.column != live.Name {
+			problems = append(problems, fmt.Errorf("field %s is primary key column %d %q, the table has %q there", fm.name, n+1, fm.column, live.Name))
+			continue
+		}
+		ft := fm.typ
+		if typ, ok := fm.primaryKeyType(); ok && typ != live.Type {
+			problems = append(problems, fmt.Errorf("field %s: column %q is %s in the table, but the field type %s is %s", fm.name, fm.column, primaryKeyTypeNames[live.Type], ft, primaryKeyTypeNames[typ]))
+		}
+		if fm.pk.auto != live.AutoIncrement {
+			if live.AutoIncrement {
+				problems = append(problems, fmt.Errorf("field %s: column %q is AUTO_INCREMENT in the table, but the pk tag has no auto option", fm.name, fm.column))
+			} else {
+				problems = append(problems, fmt.Errorf("field %s: the pk tag has the auto option, but column %q is not AUTO_INCREMENT in the table", fm.name, fm.column))
+			}
+		}
+	}
+	for n := len(meta.pkFields); n < len(desc.PrimaryKeys); n++ {
+		problems = append(problems, fmt.Errorf("primary key column %d %q has no pk field", n+1, desc.PrimaryKeys[n].Name))
+	}
+
+	if len(problems) > 0 {
+		return &SchemaError{TableName: desc.TableName, Type: t, Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package otsutils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSchema(t *testing.T) {
+	ast := assert.New(t)
+	ctx, _ := newFakeContext(t)
+
+	ast.NoError(ValidateSchema(ctx, &RangeRow{}))
+	ast.NoError(ValidateSchema(ctx, RangeRow{}))
+
+	// pk 顺序写反
+	type swapped struct {
+		Pk1 *string `json:"pk1" pk:"2"`
+		Pk2 *int64  `json:"pk2" pk:"1"`
+	}
+	err := ValidateSchema(ctx, &swapped{})
+	var schemaErr *SchemaError
+	ast.True(errors.As(err, &schemaErr))
+	ast.Len(schemaErr.Problems, 2)
+	ast.EqualError(err, `type otsutils.swapped does not match the primary key of table 'test_table': `+
+		`field Pk2 is primary key column 1 "pk2", the table has "pk1" there; `+
+		`field Pk1 is primary key column 2 "pk1", the table has "pk2" there`)
+
+	type wrongType struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk2 *string `json:"pk2" pk:"2"`
+	}
+	ast.ErrorContains(ValidateSchema(ctx, &wrongType{}), `field Pk2: column "pk2" is integer in the table, but the field type *string is string`)
+
+	type autoPk struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk2 *int64  `json:"pk2" pk:"2,auto"`
+	}
+	ast.EqualError(ValidateSchema(ctx, &autoPk{}), `type otsutils.autoPk does not match the primary key of table 'test_table': `+
+		`field Pk2: the pk tag has the auto option, but column "pk2" is not AUTO_INCREMENT in the table`)
+
+	type missingPk struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+	}
+	ast.ErrorContains(ValidateSchema(ctx, &missingPk{}), `primary key column 2 "pk2" has no pk field`)
+
+	type extraPk struct {
+		Pk1 *string `json:"pk1" pk:"1"`
+		Pk2 *int64  `json:"pk2" pk:"2"`
+		Pk3 *string `json:"pk3" pk:"3"`
+	}
+	ast.ErrorContains(ValidateSchema(ctx, &extraPk{}), "field Pk3 is primary key column 3, the table has 2")
+
+	ast.EqualError(ValidateSchema(ctx, "row"), "obj must be a struct or pointer to struct, got string")
+}
@@ -0,0 +1,318 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/rs/zerolog"
+)
+
+// SearchResult describes the outcome of a Search besides the returned rows.
+type SearchResult struct {
+	// TotalHits is the number of rows matching the query, including those not returned.
+	TotalHits int64
+
+	// NextToken continues the search with SearchParams.Token. It is nil once every row has
+	// been returned.
+	NextToken []byte
+
+	// IsAllSuccess is false when some partitions of the index failed, so that rows may be missing.
+	IsAllSuccess bool
+
+	// Aggs holds the results of SearchParams.Aggregations by aggregation name.
+	Aggs map[string]AggResult
+
+	// Groups holds the groups of SearchParams.GroupBys by group-by name.
+	Groups map[string][]GroupRow
+
+	// Hits holds the rows of the page in order, the same rows Search appends to out, with their
+	// score and primary key. SearchAll and SearchAggregate leave it nil.
+	Hits []SearchHit
+}
+
+// SearchHit is a row returned by Search.
+type SearchHit struct {
+	// Row is the decoded row, of the element type of out: a struct, a pointer to a struct or a
+	// map[string]any.
+	Row any
+
+	// Score is the relevance score of the row, zero when the service reported none, e.g. when
+	// the rows are sorted by a field rather than by score.
+	Score float64
+
+	// PrimaryKey is the primary key of the row, as returned by the service.
+	PrimaryKey []KeyValue
+}
+
+// searchPage is the obj passed through the executor for a Search.
+type searchPage struct {
+	IndexName string
+	Query     search.Query
+
+	// Columns is the columns of the out struct, the default of SearchParams.ColumnsToGet
+	Columns []string
+
+	// NoRows only requests the aggregations and group-bys, for SearchAggregate
+	NoRows bool
+}
+
+// Search runs query against the search index indexName of the table of OtsUtilsParams and
+// appends the matching rows to out, a pointer to a slice of structs, of pointers to structs or of
+// map[string]any, as in GetRange. Each row is decoded with ParseResult, so columns the index
+// does not return leave their fields nil. A single page of at most SearchParams.Limit rows is
+// read; pass the returned NextToken as SearchParams.Token to read the next one. The results of
+// SearchParams.Aggregations and SearchParams.GroupBys are reported in the SearchResult, as in
+// SearchAggregate. SearchResult.Hits reports the score and the primary key of each row.
+//
+// Example usage:
+//
+//	var users []User
+//	res, err := Search(ctx, "users_index", &search.TermQuery{FieldName: "city", Term: "hz"}, &users,
+//	    SearchParams{Limit: 20})
+//	if err == nil && res.NextToken != nil {
+//	    res, err = Search(ctx, "users_index", &search.TermQuery{FieldName: "city", Term: "hz"}, &users,
+//	        SearchParams{Limit: 20, Token: res.NextToken})
+//	}
+func Search(ctx context.Context, indexName string, query search.Query, out any, params ...SearchParams) (*SearchResult, error) {
+	var p SearchParams
+	if len(params) > 0 {
+		p = params[0]
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if err := validateName("index", indexName); err != nil {
+		return nil, err
+	}
+	if query == nil {
+		return nil, fmt.Errorf("query can not be nil")
+	}
+
+	slice, elemType, err := outSlice(out)
+	if err != nil {
+		return nil, err
+	}
+	page := &searchPage{IndexName: indexName, Query: query}
+	if elemType != rowMapType {
+		structType := elemType
+		if structType.Kind() == reflect.Ptr {
+			structType = structType.Elem()
+		}
+		meta, err := getStructMeta(structType)
+		if err != nil {
+			return nil, err
+		}
+		page.Columns = meta.attrColumns()
+	}
+
+	var res *SearchResult
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		r := resp.(*tablestore.SearchResponse)
+		var err error
+		if res, err = newSearchResult(r); err != nil {
+			return err
+		}
+		res.Hits = make([]SearchHit, len(r.Rows))
+		for i, row := range r.Rows {
+			elem, err := decodeRow(ctx, elemType, row.PrimaryKey, row.Columns)
+			if err != nil {
+				return err
+			}
+			slice.Set(reflect.Append(slice, elem))
+			res.Hits[i] = SearchHit{Row: elem.Interface(), PrimaryKey: primaryKeyToKeyValues(row.PrimaryKey)}
+			if i < len(r.SearchHits) && r.SearchHits[i].Score != nil {
+				res.Hits[i].Score = *r.SearchHits[i].Score
+			}
+		}
+		logger.Debug().Int("rows", len(r.Rows)).Int64("totalHits", r.TotalCount).Msg("Search done")
+		return nil
+	}
+	if err := executeOTSOperation(ctx, "Search", page, buildSearchRequest, executeSearch, handleResp, p); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DefaultSearchAllPageSize is the number of rows each request of SearchAll reads when
+// SearchParams.Limit is not set, the most the service returns.
+const DefaultSearchAllPageSize = 100
+
+// SearchAll runs query against the search index indexName like Search, following NextToken
+// from page to page and passing each row to fn, until every matching row or
+// SearchParams.MaxRows rows have been passed. T is a struct, a pointer to struct or
+// map[string]any, as the elements of Search's out. SearchParams.Limit is the size of each page,
+// DefaultSearchAllPageSize by default, and the last page only requests the rows still needed.
+//
+// An error returned by fn stops the search and is returned as is. The returned SearchResult has
+// the TotalHits and the aggregations of the first page, which are only computed once, and the
+// NextToken of the last page: it is not nil when MaxRows stopped the search before the last
+// row, and can be passed back as SearchParams.Token to resume it. Its Hits is nil: the rows
+// only go to fn.
+//
+// Example usage:
+//
+//	sorters := []search.Sorter{&search.FieldSort{FieldName: "created_at"}}
+//	_, err := SearchAll(ctx, "users_index", &search.MatchAllQuery{}, func(u User) error {
+//	    return enc.Encode(u)
+//	}, SearchParams{Sort: sorters, MaxRows: 100000})
+func SearchAll[T any](ctx context.Context, indexName string, query search.Query, fn func(T) error, params ...SearchParams) (*SearchResult, error) {
+	var p SearchParams
+	if len(params) > 0 {
+		p = params[0]
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("fn can not be nil")
+	}
+	if p.Limit == 0 {
+		p.Limit = DefaultSearchAllPageSize
+	}
+
+	var res *SearchResult
+	var passed int64
+	for {
+		page := p
+		if p.MaxRows > 0 {
+			page.Limit = int32(min(int64(p.Limit), p.MaxRows-passed))
+		}
+		var rows []T
+		pageRes, err := Search(ctx, indexName, query, &rows, page)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return nil, err
+			}
+		}
+		passed += int64(len(rows))
+
+		if res == nil {
+			res = pageRes
+			res.Hits = nil
+		} else {
+			res.NextToken = pageRes.NextToken
+			res.IsAllSuccess = res.IsAllSuccess && pageRes.IsAllSuccess
+		}
+		if res.NextToken == nil || p.MaxRows > 0 && passed >= p.MaxRows {
+			return res, nil
+		}
+		// The token carries the position, the sort and the offset of the search
+		p.Token, p.Offset = res.NextToken, 0
+		p.Aggregations, p.GroupBys = nil, nil
+	}
+}
+
+// NewSearchIterator returns an iterator over the rows matching query, read as by SearchAll. No
+// request is sent before the first Next.
+func NewSearchIterator[T any](ctx context.Context, indexName string, query search.Query, params ...SearchParams) *RowIterator[T] {
+	return newRowIterator(func(yield func(T, error) bool) {
+		_, err := SearchAll(ctx, indexName, query, func(row T) error {
+			if !yield(row, nil) {
+				return errSourceStopped
+			}
+			return nil
+		}, params...)
+		if err != nil && !errors.Is(err, errSourceStopped) {
+			var zero T
+			yield(zero, err)
+		}
+	})
+}
+
+// validate checks that the limits are not negative, Offset and Token are not combined, the
+// column names are valid and the aggregations and group-bys have distinct names.
+func (p SearchParams) validate() error {
+	if p.Limit < 0 {
+		return fmt.Errorf("Limit must not be negative, got %d", p.Limit)
+	}
+	if p.Offset < 0 {
+		return fmt.Errorf("Offset must not be negative, got %d", p.Offset)
+	}
+	if p.MaxRows < 0 {
+		return fmt.Errorf("MaxRows must not be negative, got %d", p.MaxRows)
+	}
+	if p.Offset > 0 && p.Token != nil {
+		return fmt.Errorf("Offset and Token can not be combined")
+	}
+	if p.ReturnAll && len(p.ColumnsToGet) > 0 {
+		return fmt.Errorf("ColumnsToGet and ReturnAll can not be combined")
+	}
+	for _, column := range p.ColumnsToGet {
+		if err := validateName("column", column); err != nil {
+			return fmt.Errorf("ColumnsToGet: %w", err)
+		}
+	}
+	names := make(map[string]bool)
+	for _, agg := range p.Aggregations {
+		if agg == nil {
+			return fmt.Errorf("Aggregations can not hold nil")
+		}
+		if names[agg.GetName()] {
+			return fmt.Errorf("duplicate aggregation name %q", agg.GetName())
+		}
+		names[agg.GetName()] = true
+	}
+	names = make(map[string]bool)
+	for _, groupBy := range p.GroupBys {
+		if groupBy == nil {
+			return fmt.Errorf("GroupBys can not hold nil")
+		}
+		if names[groupBy.GetName()] {
+			return fmt.Errorf("duplicate group-by name %q", groupBy.GetName())
+		}
+		names[groupBy.GetName()] = true
+	}
+	return nil
+}
+
+func buildSearchRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	page := obj.(*searchPage)
+	p := params[0].(SearchParams)
+
+	query := search.NewSearchQuery().SetQuery(page.Query).SetGetTotalCount(true)
+	if p.Limit > 0 {
+		query.SetLimit(p.Limit)
+	}
+	if p.Offset > 0 {
+		query.SetOffset(p.Offset)
+	}
+	if p.Token != nil {
+		query.SetToken(p.Token)
+	}
+	if p.Sort != nil && p.Token == nil {
+		query.SetSort(&search.Sort{Sorters: p.Sort})
+	}
+	query.Aggregation(p.Aggregations...).GroupBy(p.GroupBys...)
+	if page.NoRows {
+		query.SetLimit(0)
+		return &tablestore.SearchRequest{TableName: otsParams.TableName, IndexName: page.IndexName, SearchQuery: query}, nil
+	}
+
+	columns := &tablestore.ColumnsToGet{Columns: p.ColumnsToGet, ReturnAll: p.ReturnAll}
+	if len(columns.Columns) == 0 && !columns.ReturnAll {
+		if page.Columns != nil {
+			columns.Columns = page.Columns
+		} else {
+			columns.ReturnAll = true
+		}
+	}
+	return &tablestore.SearchRequest{
+		TableName:    otsParams.TableName,
+		IndexName:    page.IndexName,
+		SearchQuery:  query,
+		ColumnsToGet: columns,
+	}, nil
+}
+
+func executeSearch(client OtsClient, req any) (any, error) {
+	searchClient, err := clientAs[SearchClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return searchClient.Search(req.(*tablestore.SearchRequest))
+}
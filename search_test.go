@@ -0,0 +1,303 @@
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/117503445/otsutils/otsfake"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/stretchr/testify/assert"
+)
+
+type SearchRow struct {
+	Pk1  *string `json:"pk1" pk:"1"`
+	Pk2  *int64  `json:"pk2" pk:"2"`
+	City *string `json:"city"`
+	Age  *int64  `json:"age"`
+	Bio  *string `json:"bio"`
+}
+
+// newSearchContext returns a fake context whose test_table has the search index search_index
+// on city and age, and five rows.
+func newSearchContext(t *testing.T) (context.Context, *otsfake.Client) {
+	t.Helper()
+	ctx, fake := newFakeContext(t)
+	_, err := fake.CreateSearchIndex(&tablestore.CreateSearchIndexRequest{
+		TableName: "test_table",
+		IndexName: "search_index",
+		IndexSchema: &tablestore.IndexSchema{FieldSchemas: []*tablestore.FieldSchema{
+			{FieldName: tea.String("city"), FieldType: tablestore.FieldType_KEYWORD},
+			{FieldName: tea.String("age"), FieldType: tablestore.FieldType_LONG},
+		}},
+	})
+	assert.NoError(t, err)
+	for i, city := range []string{"hz", "sh", "hz", "bj", "hz"} {
+		row := SearchRow{Pk1: tea.String("u"), Pk2: tea.Int64(int64(i)), City: tea.String(city), Age: tea.Int64(int64(20 + i)), Bio: tea.String("bio")}
+		assert.NoError(t, PutRow(ctx, &row))
+	}
+	return ctx, fake
+}
+
+func TestSearch(t *testing.T) {
+	t.Run("decodes the matching rows", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newSearchContext(t)
+
+		var req *tablestore.SearchRequest
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "Search" {
+				req = request.(*tablestore.SearchRequest)
+			}
+			return nil
+		}
+		var rows []SearchRow
+		res, err := Search(ctx, "search_index", &search.TermQuery{FieldName: "city", Term: "hz"}, &rows)
+		ast.NoError(err)
+		ast.Equal(int64(3), res.TotalHits)
+		ast.True(res.IsAllSuccess)
+		ast.Nil(res.NextToken)
+		ast.Equal([]int64{0, 2, 4}, []int64{*rows[0].Pk2, *rows[1].Pk2, *rows[2].Pk2})
+		ast.Equal("bio", *rows[0].Bio)
+		// 默认读取结构体的属性列
+		ast.Equal(&tablestore.ColumnsToGet{Columns: []string{"city", "age", "bio"}}, req.ColumnsToGet)
+	})
+
+	t.Run("hits carry the score and the primary key", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newSearchContext(t)
+		fake.SearchScore = func(pk []*tablestore.PrimaryKeyColumn) float64 {
+			return float64(pk[1].Value.(int64)) / 10
+		}
+
+		var rows []*SearchRow
+		res, err := Search(ctx, "search_index", &search.TermQuery{FieldName: "city", Term: "hz"}, &rows)
+		ast.NoError(err)
+		ast.Len(res.Hits, 3)
+		for i, hit := range res.Hits {
+			// Row 与 out 中的元素相同
+			ast.Same(rows[i], hit.Row)
+			ast.Equal([]KeyValue{{Key: "pk1", Value: "u"}, {Key: "pk2", Value: *rows[i].Pk2}}, hit.PrimaryKey)
+			ast.Equal(float64(*rows[i].Pk2)/10, hit.Score)
+		}
+
+		// 读取到 map 时 Row 为 map[string]any；没有分数时为 0
+		fake.SearchScore = nil
+		var maps []map[string]any
+		res, err = Search(ctx, "search_index", &search.TermQuery{FieldName: "city", Term: "bj"}, &maps)
+		ast.NoError(err)
+		ast.Equal([]SearchHit{{
+			Row:        maps[0],
+			PrimaryKey: []KeyValue{{Key: "pk1", Value: "u"}, {Key: "pk2", Value: int64(3)}},
+		}}, res.Hits)
+
+		res, err = SearchAll(ctx, "search_index", &search.MatchAllQuery{}, func(SearchRow) error { return nil })
+		ast.NoError(err)
+		ast.Nil(res.Hits)
+	})
+
+	t.Run("missing columns leave the fields nil", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSearchContext(t)
+
+		var rows []*SearchRow
+		query := &search.RangeQuery{FieldName: "age", From: 22, IncludeLower: true}
+		_, err := Search(ctx, "search_index", query, &rows, SearchParams{ColumnsToGet: []string{"age"}})
+		ast.NoError(err)
+		ast.Len(rows, 3)
+		ast.Equal(int64(22), *rows[0].Age)
+		ast.Nil(rows[0].City)
+		ast.Nil(rows[0].Bio)
+
+		var maps []map[string]any
+		_, err = Search(ctx, "search_index", &search.TermQuery{FieldName: "city", Term: "bj"}, &maps, SearchParams{ReturnAll: true})
+		ast.NoError(err)
+		ast.Equal([]map[string]any{{"pk1": "u", "pk2": int64(3), "city": "bj", "age": int64(23), "bio": "bio"}}, maps)
+	})
+
+	t.Run("limit, offset, sort and token", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSearchContext(t)
+
+		var rows []SearchRow
+		sorters := []search.Sorter{&search.FieldSort{FieldName: "age", Order: search.SortOrder_DESC.Enum()}}
+		res, err := Search(ctx, "search_index", &search.MatchAllQuery{}, &rows, SearchParams{Limit: 2, Offset: 1, Sort: sorters})
+		ast.NoError(err)
+		ast.Equal(int64(5), res.TotalHits)
+		ast.Equal([]int64{23, 22}, []int64{*rows[0].Age, *rows[1].Age})
+		ast.NotNil(res.NextToken)
+
+		res, err = Search(ctx, "search_index", &search.MatchAllQuery{}, &rows, SearchParams{Limit: 2, Token: res.NextToken})
+		ast.NoError(err)
+		ast.Len(rows, 4)
+		ast.Equal([]int64{21, 20}, []int64{*rows[2].Age, *rows[3].Age})
+		ast.Nil(res.NextToken)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSearchContext(t)
+
+		var rows []SearchRow
+		_, err := Search(ctx, "no_index", &search.MatchAllQuery{}, &rows)
+		ast.ErrorIs(err, ErrObjectNotExist)
+		_, err = Search(ctx, "search_index", &search.TermQuery{FieldName: "bio", Term: "bio"}, &rows)
+		ast.Equal(CodeParameterInvalid, Code(err))
+		_, err = Search(ctx, "search_index", &search.MatchAllQuery{}, &rows, SearchParams{Offset: 1, Token: []byte("t")})
+		ast.EqualError(err, "Offset and Token can not be combined")
+		_, err = Search(ctx, "search_index", nil, &rows)
+		ast.EqualError(err, "query can not be nil")
+		_, err = Search(ctx, "search_index", &search.MatchAllQuery{}, rows)
+		ast.EqualError(err, "out must be a non-nil pointer to slice, got []otsutils.SearchRow")
+		ast.Empty(rows)
+	})
+}
+
+func TestSearchAggregate(t *testing.T) {
+	t.Run("group by field with a sum sub-aggregation", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSearchContext(t)
+
+		groupBy := &search.GroupByField{AggName: "by_city", Field: "city",
+			SubAggList: []search.Aggregation{&search.SumAggregation{AggName: "total_age", Field: "age"}}}
+		res, err := SearchAggregate(ctx, "search_index", &search.MatchAllQuery{}, nil, []search.GroupBy{groupBy})
+		ast.NoError(err)
+		groups := res.Groups["by_city"]
+		ast.Len(groups, 3)
+		// 按行数降序，行数相同时按键升序
+		ast.Equal([]string{"hz", "bj", "sh"}, []string{groups[0].Key, groups[1].Key, groups[2].Key})
+		ast.Equal(int64(3), groups[0].RowCount)
+		ast.Equal(66.0, groups[0].Aggs["total_age"].Value)
+		ast.Equal(23.0, groups[1].Aggs["total_age"].Value)
+		ast.Equal(search.AggregationSumType, groups[2].Aggs["total_age"].Type)
+	})
+
+	t.Run("aggregations next to the rows of Search", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSearchContext(t)
+
+		var rows []SearchRow
+		res, err := Search(ctx, "search_index", &search.TermQuery{FieldName: "city", Term: "hz"}, &rows, SearchParams{
+			Limit: 1,
+			Aggregations: []search.Aggregation{
+				&search.CountAggregation{AggName: "count", Field: "age"},
+				&search.MinAggregation{AggName: "min", Field: "age"},
+				&search.MaxAggregation{AggName: "max", Field: "age"},
+				&search.DistinctCountAggregation{AggName: "cities", Field: "city"},
+			},
+		})
+		ast.NoError(err)
+		ast.Len(rows, 1)
+		// 聚合覆盖所有匹配的行，而不仅是返回的行
+		ast.Equal(int64(3), res.Aggs["count"].Count)
+		ast.Equal(20.0, res.Aggs["min"].Value)
+		ast.Equal(24.0, res.Aggs["max"].Value)
+		ast.Equal(int64(1), res.Aggs["cities"].Count)
+		ast.False(res.Aggs["min"].Missing)
+	})
+
+	t.Run("nested group by and missing values", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSearchContext(t)
+
+		groupBy := &search.GroupByFilter{AggName: "adults",
+			Queries:        []search.Query{&search.RangeQuery{FieldName: "age", From: 22, IncludeLower: true}},
+			SubGroupByList: []search.GroupBy{&search.GroupByField{AggName: "by_city", Field: "city"}}}
+		avg := &search.AvgAggregation{AggName: "avg", Field: "age"}
+		res, err := SearchAggregate(ctx, "search_index", &search.TermQuery{FieldName: "city", Term: "gz"}, []search.Aggregation{avg}, nil)
+		ast.NoError(err)
+		ast.True(res.Aggs["avg"].Missing)
+
+		res, err = SearchAggregate(ctx, "search_index", &search.MatchAllQuery{}, nil, []search.GroupBy{groupBy})
+		ast.NoError(err)
+		adults := res.Groups["adults"]
+		ast.Equal(int64(3), adults[0].RowCount)
+		ast.Equal([]GroupRow{{Key: "hz", RowCount: 2}, {Key: "bj", RowCount: 1}}, adults[0].Groups["by_city"])
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSearchContext(t)
+
+		_, err := SearchAggregate(ctx, "search_index", &search.MatchAllQuery{}, nil, nil)
+		ast.EqualError(err, "no aggregation or group-by given")
+		sum := &search.SumAggregation{AggName: "sum", Field: "age"}
+		_, err = SearchAggregate(ctx, "search_index", &search.MatchAllQuery{}, []search.Aggregation{sum, sum}, nil)
+		ast.EqualError(err, `duplicate aggregation name "sum"`)
+		_, err = SearchAggregate(ctx, "search_index", &search.MatchAllQuery{}, []search.Aggregation{&search.SumAggregation{AggName: "sum", Field: "city"}}, nil)
+		ast.Equal(CodeParameterInvalid, Code(err))
+	})
+}
+
+func TestSearchAll(t *testing.T) {
+	t.Run("follows the tokens", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newSearchContext(t)
+
+		requests := 0
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "Search" {
+				requests++
+			}
+			return nil
+		}
+		var ages []int64
+		sorters := []search.Sorter{&search.FieldSort{FieldName: "age", Order: search.SortOrder_DESC.Enum()}}
+		res, err := SearchAll(ctx, "search_index", &search.MatchAllQuery{}, func(row *SearchRow) error {
+			ages = append(ages, *row.Age)
+			return nil
+		}, SearchParams{Limit: 2, Sort: sorters})
+		ast.NoError(err)
+		ast.Equal([]int64{24, 23, 22, 21, 20}, ages)
+		ast.Equal(3, requests)
+		ast.Equal(int64(5), res.TotalHits)
+		ast.Nil(res.NextToken)
+	})
+
+	t.Run("MaxRows stops the search and the token resumes it", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSearchContext(t)
+
+		var ages []int64
+		collect := func(row map[string]any) error {
+			ages = append(ages, row["age"].(int64))
+			return nil
+		}
+		sorters := []search.Sorter{&search.FieldSort{FieldName: "age"}}
+		p := SearchParams{Limit: 2, Sort: sorters, MaxRows: 3}
+		res, err := SearchAll(ctx, "search_index", &search.MatchAllQuery{}, collect, p)
+		ast.NoError(err)
+		ast.Equal([]int64{20, 21, 22}, ages)
+		ast.NotNil(res.NextToken)
+
+		// 同一组参数加上 Token 即可继续，排序由 Token 携带
+		p.Token = res.NextToken
+		res, err = SearchAll(ctx, "search_index", &search.MatchAllQuery{}, collect, p)
+		ast.NoError(err)
+		ast.Equal([]int64{20, 21, 22, 23, 24}, ages)
+		ast.Nil(res.NextToken)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSearchContext(t)
+
+		calls := 0
+		stop := errors.New("stop")
+		_, err := SearchAll(ctx, "search_index", &search.MatchAllQuery{}, func(row SearchRow) error {
+			calls++
+			return stop
+		})
+		ast.ErrorIs(err, stop)
+		ast.Equal(1, calls)
+
+		_, err = SearchAll[SearchRow](ctx, "search_index", &search.MatchAllQuery{}, nil)
+		ast.EqualError(err, "fn can not be nil")
+		_, err = SearchAll(ctx, "search_index", &search.MatchAllQuery{}, func(row SearchRow) error { return nil }, SearchParams{MaxRows: -1})
+		ast.EqualError(err, "MaxRows must not be negative, got -1")
+		_, err = SearchAll(ctx, "search_index", &search.MatchAllQuery{}, func(row int) error { return nil })
+		ast.Error(err)
+	})
+}
@@ -0,0 +1,209 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/rs/zerolog"
+)
+
+// AggResult is the result of an aggregation of a Search.
+type AggResult struct {
+	// Type is the type of the aggregation, such as search.AggregationSumType.
+	Type search.AggregationType
+
+	// Value is the result of a sum, min, max or avg aggregation.
+	Value float64
+
+	// Count is the result of a count or distinct count aggregation.
+	Count int64
+
+	// Missing is true for a min, max or avg aggregation over rows that have none of its field,
+	// which the service reports as an infinite Value.
+	Missing bool
+
+	// Raw is the result returned by the SDK, for the aggregations whose result is neither a
+	// Value nor a Count, such as percentiles and top rows.
+	Raw search.AggregationResult
+}
+
+// GroupRow is a group of a group-by of a Search.
+type GroupRow struct {
+	// Key identifies the group: the field value of GroupByField, the position of the query of
+	// GroupByFilter from 0, the lower bound of the bucket of GroupByHistogram, the start
+	// timestamp in milliseconds of GroupByDateHistogram or the cell of GroupByGeoGrid.
+	// It is empty for GroupByRange and GroupByGeoDistance, whose groups are set by From and To.
+	Key string
+
+	// From and To bound the group of a GroupByRange or GroupByGeoDistance.
+	From, To float64
+
+	// RowCount is the number of rows of the group.
+	RowCount int64
+
+	// Aggs holds the results of the sub-aggregations of the group by name.
+	Aggs map[string]AggResult
+
+	// Groups holds the groups of the sub-group-bys of the group by name.
+	Groups map[string][]GroupRow
+}
+
+// SearchAggregate runs query against the search index indexName of the table of OtsUtilsParams
+// and returns the results of aggs and groupBys over every matching row, without reading any row.
+// Sub-aggregations and sub-group-bys of the group-bys are reported in each GroupRow. Use Search
+// with SearchParams.Aggregations and SearchParams.GroupBys to read rows too.
+//
+// Example usage:
+//
+//	// Sum of amount grouped by status
+//	res, err := SearchAggregate(ctx, "orders_index", &search.MatchAllQuery{}, nil,
+//	    []search.GroupBy{&search.GroupByField{AggName: "by_status", Field: "status",
+//	        SubAggList: []search.Aggregation{&search.SumAggregation{AggName: "amount", Field: "amount"}}}})
+//	for _, g := range res.Groups["by_status"] {
+//	    fmt.Println(g.Key, g.RowCount, g.Aggs["amount"].Value)
+//	}
+func SearchAggregate(ctx context.Context, indexName string, query search.Query, aggs []search.Aggregation, groupBys []search.GroupBy) (*SearchResult, error) {
+	p := SearchParams{Aggregations: aggs, GroupBys: groupBys}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if len(aggs) == 0 && len(groupBys) == 0 {
+		return nil, fmt.Errorf("no aggregation or group-by given")
+	}
+	if err := validateName("index", indexName); err != nil {
+		return nil, err
+	}
+	if query == nil {
+		return nil, fmt.Errorf("query can not be nil")
+	}
+
+	var res *SearchResult
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		var err error
+		res, err = newSearchResult(resp.(*tablestore.SearchResponse))
+		return err
+	}
+	page := &searchPage{IndexName: indexName, Query: query, NoRows: true}
+	if err := executeOTSOperation(ctx, "Search", page, buildSearchRequest, executeSearch, handleResp, p); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// newSearchResult converts a SearchResponse, except its rows.
+func newSearchResult(r *tablestore.SearchResponse) (*SearchResult, error) {
+	res := &SearchResult{TotalHits: r.TotalCount, NextToken: r.NextToken, IsAllSuccess: r.IsAllSuccess}
+	res.Aggs = aggResults(r.AggregationResults)
+	var err error
+	if res.Groups, err = groupRows(r.GroupByResults); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// aggResults converts the aggregation results of a response or a group. It returns nil when
+// there are none.
+func aggResults(results search.AggregationResults) map[string]AggResult {
+	if results.Empty() {
+		return nil
+	}
+	aggs := make(map[string]AggResult)
+	for name, raw := range results.GetRawResults() {
+		agg := AggResult{Type: raw.GetType(), Raw: raw}
+		switch r := raw.(type) {
+		case *search.SumAggregationResult:
+			agg.Value = r.Value
+		case *search.MinAggregationResult:
+			agg.Value, agg.Missing = r.Value, math.IsInf(r.Value, 1)
+		case *search.MaxAggregationResult:
+			agg.Value, agg.Missing = r.Value, math.IsInf(r.Value, -1)
+		case *search.AvgAggregationResult:
+			agg.Value, agg.Missing = r.Value, math.IsInf(r.Value, 1)
+		case *search.CountAggregationResult:
+			agg.Count = r.Value
+		case *search.DistinctCountAggregationResult:
+			agg.Count = r.Value
+		}
+		aggs[name] = agg
+	}
+	return aggs
+}
+
+// groupRows converts the group-by results of a response or a group, recursively. It returns nil
+// when there are none.
+func groupRows(results search.GroupByResults) (map[string][]GroupRow, error) {
+	if results.Empty() {
+		return nil, nil
+	}
+	groups := make(map[string][]GroupRow)
+	for name, raw := range results.GetRawResults() {
+		var rows []GroupRow
+		add := func(row GroupRow, subAggs search.AggregationResults, subGroupBys search.GroupByResults) error {
+			row.Aggs = aggResults(subAggs)
+			var err error
+			if row.Groups, err = groupRows(subGroupBys); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			rows = append(rows, row)
+			return nil
+		}
+
+		var err error
+		switch r := raw.(type) {
+		case *search.GroupByFieldResult:
+			for _, item := range r.Items {
+				if err = add(GroupRow{Key: item.Key, RowCount: item.RowCount}, item.SubAggregations, item.SubGroupBys); err != nil {
+					break
+				}
+			}
+		case *search.GroupByFilterResult:
+			for i, item := range r.Items {
+				if err = add(GroupRow{Key: strconv.Itoa(i), RowCount: item.RowCount}, item.SubAggregations, item.SubGroupBys); err != nil {
+					break
+				}
+			}
+		case *search.GroupByRangeResult:
+			for _, item := range r.Items {
+				if err = add(GroupRow{From: item.From, To: item.To, RowCount: item.RowCount}, item.SubAggregations, item.SubGroupBys); err != nil {
+					break
+				}
+			}
+		case *search.GroupByGeoDistanceResult:
+			for _, item := range r.Items {
+				if err = add(GroupRow{From: item.From, To: item.To, RowCount: item.RowCount}, item.SubAggregations, item.SubGroupBys); err != nil {
+					break
+				}
+			}
+		case *search.GroupByHistogramResult:
+			for _, item := range r.Items {
+				if err = add(GroupRow{Key: fmt.Sprint(item.Key.Value), RowCount: item.Value}, item.SubAggregations, item.SubGroupBys); err != nil {
+					break
+				}
+			}
+		case *search.GroupByDateHistogramResult:
+			for _, item := range r.Items {
+				if err = add(GroupRow{Key: strconv.FormatInt(item.Timestamp, 10), RowCount: item.RowCount}, item.SubAggregations, item.SubGroupBys); err != nil {
+					break
+				}
+			}
+		case *search.GroupByGeoGridResult:
+			for _, item := range r.Items {
+				if err = add(GroupRow{Key: item.Key, RowCount: item.RowCount}, item.SubAggregations, item.SubGroupBys); err != nil {
+					break
+				}
+			}
+		default:
+			return nil, fmt.Errorf("group-by %s: results of type %T are not supported", name, raw)
+		}
+		if err != nil {
+			return nil, err
+		}
+		groups[name] = rows
+	}
+	return groups, nil
+}
@@ -0,0 +1,132 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// Defaults of SequenceOptions.
+const (
+	DefaultSequenceKeyColumn   = "seq_name"
+	DefaultSequenceValueColumn = "value"
+)
+
+// Sequence generates increasing int64 values, e.g. application-level ids, from a counter row of
+// the table of OtsUtilsParams. The table has a single STRING primary key column, KeyColumn,
+// holding the sequence name, and the counter is the INTEGER column ValueColumn. Each write is
+// an atomic increment that returns the new value, so the values are unique across processes.
+//
+// Values are increasing within a process. With a BlockSize above 1, processes reserve blocks of
+// values in turn, so values handed out by different processes interleave and can have gaps.
+//
+// Example usage:
+//
+//	seq := NewSequence(ctx, "order_id", SequenceOptions{BlockSize: 100})
+//	id, err := seq.Next(ctx)
+type Sequence struct {
+	params *OtsUtilsParams
+	name   string
+	opts   SequenceOptions
+
+	mu sync.Mutex
+	// next is the next value handed out, remaining the values of the block left from it
+	next      int64
+	remaining int64
+}
+
+// NewSequence returns the sequence name of the table of the OtsUtilsParams of ctx. The table
+// is kept, so Next can be called with any context. A sequence with no counter row yet starts
+// at Step. NewSequence makes no call to the service.
+func NewSequence(ctx context.Context, name string, opts ...SequenceOptions) *Sequence {
+	var o SequenceOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.KeyColumn == "" {
+		o.KeyColumn = DefaultSequenceKeyColumn
+	}
+	if o.ValueColumn == "" {
+		o.ValueColumn = DefaultSequenceValueColumn
+	}
+	if o.Step == 0 {
+		o.Step = 1
+	}
+	if o.BlockSize == 0 {
+		o.BlockSize = 1
+	}
+	return &Sequence{params: otsUtilsParamsFromCtx(ctx), name: name, opts: o}
+}
+
+// Next returns the next value of the sequence. It increments the counter row by Step, or by
+// Step times BlockSize when the values reserved by the previous increment are used up. Next
+// is safe for concurrent use.
+func (s *Sequence) Next(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.remaining == 0 {
+		last, err := s.reserve(ctx)
+		if err != nil {
+			return 0, err
+		}
+		// The increment returns the last value of the block
+		s.next = last - (s.opts.BlockSize-1)*s.opts.Step
+		s.remaining = s.opts.BlockSize
+	}
+	value := s.next
+	s.next += s.opts.Step
+	s.remaining--
+	return value, nil
+}
+
+// reserve increments the counter row by a block of values and returns its new value.
+func (s *Sequence) reserve(ctx context.Context) (int64, error) {
+	if s.name == "" {
+		return 0, fmt.Errorf("sequence name can not be empty")
+	}
+	if s.opts.Step < 0 {
+		return 0, fmt.Errorf("Step must be positive, got %d", s.opts.Step)
+	}
+	if s.opts.BlockSize < 0 {
+		return 0, fmt.Errorf("BlockSize must be positive, got %d", s.opts.BlockSize)
+	}
+	if err := validateName("column", s.opts.ValueColumn); err != nil {
+		return 0, err
+	}
+
+	build := func(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+		change := &tablestore.UpdateRowChange{
+			TableName:  otsParams.TableName,
+			PrimaryKey: &tablestore.PrimaryKey{},
+		}
+		change.PrimaryKey.AddPrimaryKeyColumn(s.opts.KeyColumn, s.name)
+		change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		change.IncrementColumn(s.opts.ValueColumn, s.opts.Step*s.opts.BlockSize)
+		change.SetReturnIncrementValue()
+		change.AppendIncrementColumnToReturn(s.opts.ValueColumn)
+		return &tablestore.UpdateRowRequest{UpdateRowChange: change}, nil
+	}
+	var value int64
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		for _, col := range resp.(*tablestore.UpdateRowResponse).Columns {
+			if col.ColumnName != s.opts.ValueColumn {
+				continue
+			}
+			v, ok := col.Value.(int64)
+			if !ok {
+				return fmt.Errorf("sequence %s: column %q holds %T, not an INTEGER", s.name, col.ColumnName, col.Value)
+			}
+			value = v
+			return nil
+		}
+		return fmt.Errorf("sequence %s: the response has no column %q", s.name, s.opts.ValueColumn)
+	}
+
+	err := executeOTSOperation(s.params.WithContext(ctx), "SequenceNext", s.name, build, executeUpdateRow, handleResp)
+	return value, err
+}
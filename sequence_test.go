@@ -0,0 +1,104 @@
+package otsutils
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequence(t *testing.T) {
+	t.Run("one write per value", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newSingleKeyContext(t, "sequences", DefaultSequenceKeyColumn)
+		seq := NewSequence(ctx, "order_id")
+
+		for want := int64(1); want <= 3; want++ {
+			got, err := seq.Next(ctx)
+			ast.NoError(err)
+			ast.Equal(want, got)
+		}
+		ast.Equal(3, fake.CallCount("UpdateRow"))
+
+		// 另一个实例从计数器行继续
+		other := NewSequence(ctx, "order_id")
+		got, err := other.Next(ctx)
+		ast.NoError(err)
+		ast.Equal(int64(4), got)
+
+		cols, err := GetRowMap(ctx, []KeyValue{{Key: DefaultSequenceKeyColumn, Value: "order_id"}})
+		ast.NoError(err)
+		ast.Equal([]KeyValue{{Key: DefaultSequenceValueColumn, Value: int64(4)}}, cols)
+	})
+
+	t.Run("step and block", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newSingleKeyContext(t, "sequences", DefaultSequenceKeyColumn)
+		seq := NewSequence(ctx, "ticket", SequenceOptions{Step: 10, BlockSize: 3})
+
+		var got []int64
+		for range 4 {
+			v, err := seq.Next(ctx)
+			ast.NoError(err)
+			got = append(got, v)
+		}
+		ast.Equal([]int64{10, 20, 30, 40}, got)
+		ast.Equal(2, fake.CallCount("UpdateRow"))
+
+		cols, err := GetRowMap(ctx, []KeyValue{{Key: DefaultSequenceKeyColumn, Value: "ticket"}})
+		ast.NoError(err)
+		ast.Equal([]KeyValue{{Key: DefaultSequenceValueColumn, Value: int64(60)}}, cols)
+	})
+
+	t.Run("concurrent blocks hand out unique values", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, _ := newSingleKeyContext(t, "sequences", DefaultSequenceKeyColumn)
+		a := NewSequence(ctx, "id", SequenceOptions{BlockSize: 7})
+		b := NewSequence(ctx, "id", SequenceOptions{BlockSize: 5})
+
+		var mu sync.Mutex
+		var got []int64
+		var wg sync.WaitGroup
+		for i := range 8 {
+			seq := a
+			if i%2 == 1 {
+				seq = b
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 25 {
+					v, err := seq.Next(ctx)
+					ast.NoError(err)
+					mu.Lock()
+					got = append(got, v)
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		ast.Len(got, 200)
+		for i := 1; i < len(got); i++ {
+			ast.NotEqual(got[i-1], got[i])
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newSingleKeyContext(t, "sequences", DefaultSequenceKeyColumn)
+
+		_, err := NewSequence(ctx, "").Next(ctx)
+		ast.EqualError(err, "sequence name can not be empty")
+		_, err = NewSequence(ctx, "id", SequenceOptions{Step: -1}).Next(ctx)
+		ast.EqualError(err, "Step must be positive, got -1")
+		ast.Equal(0, fake.CallCount("UpdateRow"))
+
+		// 计数器列不是整数
+		ast.NoError(PutRowMap(ctx, []KeyValue{{Key: DefaultSequenceKeyColumn, Value: "bad"}}, []KeyValue{{Key: DefaultSequenceValueColumn, Value: "x"}}))
+		_, err = NewSequence(ctx, "bad").Next(ctx)
+		ast.Equal(CodeParameterInvalid, Code(err))
+	})
+}
@@ -0,0 +1,168 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// typeSerializer converts values of a registered Go type to and from OTS column values.
+type typeSerializer struct {
+	typ        reflect.Type
+	toColumn   func(any) (any, error)
+	fromColumn func(any) (any, error)
+}
+
+var (
+	typeSerializersMu sync.RWMutex
+	typeSerializers   = make(map[reflect.Type]*typeSerializer)
+)
+
+// RegisterTypeSerializer registers a global serializer for t, so that struct fields of type t
+// or *t can be used with ParseObj and ParseResult without per-field configuration.
+// toColumn receives a value of type t and must return a string, int64 or []byte;
+// fromColumn receives the raw column value and must return a value of type t.
+//
+// The registry is consulted after the natively supported kinds, so registering a type whose
+// pointer is already accepted (e.g. string) has no effect. Registering a type again replaces the
+// previous serializer; registrations take effect immediately, including for struct types that
+// have already been parsed. It panics if t or either function is nil.
+//
+// Example usage:
+//
+//	RegisterTypeSerializer(reflect.TypeOf(decimal.Decimal{}),
+//	    func(v any) (any, error) { return v.(decimal.Decimal).String(), nil },
+//	    func(v any) (any, error) { return decimal.NewFromString(v.(string)) },
+//	)
+func RegisterTypeSerializer(t reflect.Type, toColumn func(any) (any, error), fromColumn func(any) (any, error)) {
+	if t == nil || toColumn == nil || fromColumn == nil {
+		panic("otsutils: RegisterTypeSerializer requires a type and both conversion functions")
+	}
+
+	typeSerializersMu.Lock()
+	typeSerializers[t] = &typeSerializer{typ: t, toColumn: toColumn, fromColumn: fromColumn}
+	typeSerializersMu.Unlock()
+
+	// Cached struct metadata may reference the previous registry state
+	invalidateStructMetaCache()
+}
+
+// lookupTypeSerializer returns the serializer handling fields of type t, which is either
+// registered for t itself or, when t is a pointer, for the type it points to.
+func lookupTypeSerializer(t reflect.Type) *typeSerializer {
+	typeSerializersMu.RLock()
+	defer typeSerializersMu.RUnlock()
+
+	if s, ok := typeSerializers[t]; ok {
+		return s
+	}
+	if t.Kind() == reflect.Ptr {
+		if s, ok := typeSerializers[t.Elem()]; ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// call invokes a conversion function, turning a panic into an error
+// so that an unexpected column value cannot crash the caller.
+func (s *typeSerializer) call(fn func(any) (any, error), v any) (out any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("serializer for %s panicked: %v", s.typ, r)
+		}
+	}()
+	return fn(v)
+}
+
+// encode converts the field value to a column value. skip is true when the field is a nil pointer.
+func (s *typeSerializer) encode(field reflect.Value) (value any, skip bool, err error) {
+	if field.Type() != s.typ {
+		// Pointer to the registered type
+		if field.IsNil() {
+			return nil, true, nil
+		}
+		field = field.Elem()
+	} else if field.Kind() == reflect.Ptr && field.IsNil() {
+		return nil, true, nil
+	}
+
+	value, err = s.call(s.toColumn, field.Interface())
+	if err != nil {
+		return nil, false, err
+	}
+	switch value.(type) {
+	case string, int64, []byte:
+	default:
+		return nil, false, fmt.Errorf("serializer for %s returned unsupported column type %T", s.typ, value)
+	}
+	return value, false, nil
+}
+
+// decode converts the column value and assigns it to the field.
+func (s *typeSerializer) decode(field reflect.Value, value any) error {
+	decoded, err := s.call(s.fromColumn, value)
+	if err != nil {
+		return err
+	}
+
+	dv := reflect.ValueOf(decoded)
+	if !dv.IsValid() || dv.Type() != s.typ {
+		return fmt.Errorf("serializer for %s returned %T", s.typ, decoded)
+	}
+
+	if field.Type() == s.typ {
+		field.Set(dv)
+		return nil
+	}
+	newVal := reflect.New(s.typ)
+	newVal.Elem().Set(dv)
+	field.Set(newVal)
+	return nil
+}
+
+// encodeJSON returns the JSON encoding of the field fm, tagged `otstype:"json"`, as a string
+// column value. skip is true when the field is nil or an empty map or slice, so that "null"
+// and empty documents are not written.
+func (fm *fieldMeta) encodeJSON(field reflect.Value) (value any, skip bool, err error) {
+	switch field.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Func, reflect.Chan:
+		if field.IsNil() {
+			return nil, true, nil
+		}
+	case reflect.Map, reflect.Slice:
+		if field.Len() == 0 {
+			return nil, true, nil
+		}
+	}
+	if fm.omitEmpty && isEmptyValue(field) {
+		return nil, true, nil
+	}
+	b, err := json.Marshal(field.Interface())
+	if err != nil {
+		return nil, false, fmt.Errorf("field %s: column %q: marshal json: %w", fm.name, fm.column, err)
+	}
+	return string(b), false, nil
+}
+
+// decodeJSON unmarshals value, a string or binary column holding JSON, into the field fm,
+// tagged `otstype:"json"`.
+func (fm *fieldMeta) decodeJSON(field reflect.Value, value any) error {
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("field %s: a json column must be a string or binary, got %T", fm.name, value)
+	}
+	// Unmarshal merges into maps and slices, so the field is reset first
+	field.SetZero()
+	if err := json.Unmarshal(data, field.Addr().Interface()); err != nil {
+		return fmt.Errorf("field %s: unmarshal json: %w", fm.name, err)
+	}
+	return nil
+}
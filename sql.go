@@ -0,0 +1,215 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// QuerySQL executes a SQL statement and decodes the rows of its result set into out, a pointer to
+// a slice of structs, of pointers to structs or of map[string]any, as in GetRange. Columns map to
+// struct fields by their json tag, as in ParseResult, and NULL values leave the field nil.
+// Result sets the service returns in several pages are read to the end.
+//
+// VARCHAR, BIGINT, DOUBLE and VARBINARY columns decode into *string, *int64, *float64 and
+// *[]byte fields. DOUBLE columns without a fractional part and BOOLEAN columns, as 0 or 1, also
+// decode into *int64 fields, and VARCHAR columns into *[]byte fields. Otherwise BOOLEAN and the
+// date and time columns (bool, time.Time and time.Duration values) need a field type registered
+// with RegisterTypeSerializer.
+//
+// Example usage:
+//
+//	var users []User
+//	err := QuerySQL(ctx, "SELECT id, name FROM users WHERE age > 30", &users)
+func QuerySQL(ctx context.Context, query string, out any, params ...SQLQueryParams) error {
+	var p SQLQueryParams
+	if len(params) > 0 {
+		p = params[0]
+	}
+	if query == "" {
+		return fmt.Errorf("query can not be empty")
+	}
+	slice, elemType, err := outSlice(out)
+	if err != nil {
+		return err
+	}
+
+	var token *string
+	for {
+		next := (*string)(nil)
+		handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+			r := resp.(*tablestore.SQLQueryResponse)
+			if r.NextSearchToken != nil && *r.NextSearchToken != "" {
+				next = r.NextSearchToken
+			}
+			if r.ResultSet == nil {
+				return nil
+			}
+			return decodeSQLRows(ctx, r.ResultSet, elemType, p.Strict, func(elem reflect.Value) {
+				slice.Set(reflect.Append(slice, elem))
+			})
+		}
+
+		req := &tablestore.SQLQueryRequest{Query: query, SearchToken: token}
+		if err := executeOTSOperation(ctx, "QuerySQL", req, buildSQLQueryRequest, executeSQLQuery, handleResp, toAnySlice(params)...); err != nil {
+			return err
+		}
+		if next == nil {
+			return nil
+		}
+		token = next
+	}
+}
+
+func buildSQLQueryRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	return obj, nil
+}
+
+func executeSQLQuery(client OtsClient, req any) (any, error) {
+	sqlClient, err := clientAs[SQLClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return sqlClient.SQLQuery(req.(*tablestore.SQLQueryRequest))
+}
+
+// decodeSQLRows decodes the rows of a SQL result set into new values of elemType, a struct,
+// pointer to struct or map[string]any, passing each to emit.
+func decodeSQLRows(ctx context.Context, rs tablestore.SQLResultSet, elemType reflect.Type, strict bool, emit func(reflect.Value)) error {
+	columns := rs.Columns()
+
+	if elemType == rowMapType {
+		for rs.HasNext() {
+			values, err := sqlRowValues(rs.Next(), columns)
+			if err != nil {
+				return err
+			}
+			row := make(map[string]any, len(values))
+			for _, kv := range values {
+				row[kv.Key] = kv.Value
+			}
+			emit(reflect.ValueOf(row))
+		}
+		return nil
+	}
+
+	isPtr := elemType.Kind() == reflect.Ptr
+	structType := elemType
+	if isPtr {
+		structType = elemType.Elem()
+	}
+	meta, err := getStructMeta(structType)
+	if err != nil {
+		return err
+	}
+	fieldMap := make(map[string]*fieldMeta, len(meta.fields))
+	for i := range meta.fields {
+		fieldMap[meta.fields[i].column] = &meta.fields[i]
+	}
+	if strict {
+		for _, col := range columns {
+			if fieldMap[col.Name] == nil {
+				return fmt.Errorf("column %q has no field in %s", col.Name, structType)
+			}
+		}
+	}
+
+	for rs.HasNext() {
+		values, err := sqlRowValues(rs.Next(), columns)
+		if err != nil {
+			return err
+		}
+		var pks, cols []KeyValue
+		for _, kv := range values {
+			fm := fieldMap[kv.Key]
+			if fm == nil {
+				continue
+			}
+			kv.Value = sqlFieldValue(fm.typ, kv.Value)
+			if fm.pkTag != "" {
+				pks = append(pks, kv)
+			} else {
+				cols = append(cols, kv)
+			}
+		}
+
+		elem := reflect.New(structType)
+		if err := parseResult(ctx, elem.Interface(), pks, cols, parseResultOptions{lenientNumbers: true}); err != nil {
+			return err
+		}
+		if isPtr {
+			emit(elem)
+		} else {
+			emit(elem.Elem())
+		}
+	}
+	return nil
+}
+
+// sqlRowValues returns the non-NULL values of a SQL row, in column order.
+func sqlRowValues(row tablestore.SQLRow, columns []*tablestore.SQLColumnInfo) ([]KeyValue, error) {
+	values := make([]KeyValue, 0, len(columns))
+	for i, col := range columns {
+		null, err := row.IsNull(i)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", col.Name, err)
+		}
+		if null {
+			continue
+		}
+
+		var value any
+		switch col.Type {
+		case tablestore.ColumnType_STRING:
+			value, err = row.GetString(i)
+		case tablestore.ColumnType_INTEGER:
+			value, err = row.GetInt64(i)
+		case tablestore.ColumnType_BINARY:
+			value, err = row.GetBytes(i)
+		case tablestore.ColumnType_BOOLEAN:
+			value, err = row.GetBool(i)
+		case tablestore.ColumnType_DOUBLE:
+			value, err = row.GetFloat64(i)
+		case tablestore.ColumnType_DATETIME:
+			value, err = row.GetDateTime(i)
+		case tablestore.ColumnType_DATE:
+			value, err = row.GetDate(i)
+		case tablestore.ColumnType_TIME:
+			value, err = row.GetTime(i)
+		default:
+			return nil, fmt.Errorf("column %q has unsupported SQL type %d", col.Name, col.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", col.Name, err)
+		}
+		values = append(values, KeyValue{Key: col.Name, Value: value})
+	}
+	return values, nil
+}
+
+// sqlFieldValue converts the SQL values that have a lossless native form to the field type:
+// BOOLEAN to 0 or 1 for *int64 fields and VARCHAR to bytes for *[]byte fields. Other values,
+// including the DOUBLE values parseResult converts leniently, are returned unchanged.
+func sqlFieldValue(fieldType reflect.Type, value any) any {
+	if !isNativeFieldType(fieldType) {
+		return value
+	}
+	switch v := value.(type) {
+	case bool:
+		if isIntegerKind(nativeBaseType(fieldType).Kind()) {
+			if v {
+				return int64(1)
+			}
+			return int64(0)
+		}
+	case string:
+		if nativeBaseType(fieldType).Kind() == reflect.Slice {
+			return []byte(v)
+		}
+	}
+	return value
+}
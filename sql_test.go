@@ -0,0 +1,93 @@
+package otsutils
+
+import (
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+// sqlRow 按 select 顺序构造一行结果
+func sqlRow(kvs ...any) []*tablestore.AttributeColumn {
+	cols := make([]*tablestore.AttributeColumn, 0, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		cols = append(cols, &tablestore.AttributeColumn{ColumnName: kvs[i].(string), Value: kvs[i+1]})
+	}
+	return cols
+}
+
+type SQLUser struct {
+	ID    *string `json:"id" pk:"1"`
+	Age   *int64  `json:"age"`
+	Admin *int64  `json:"admin"`
+	Avg   *int64  `json:"avg"`
+	Blob  *[]byte `json:"blob"`
+	Note  *string `json:"note"`
+}
+
+func TestQuerySQL(t *testing.T) {
+	const query = "SELECT * FROM test_table"
+
+	t.Run("decodes and converts the result set", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.SQLPageRows = 1
+		fake.SetSQLResult(query,
+			sqlRow("id", "u1", "age", int64(31), "admin", true, "avg", float64(2), "blob", "raw", "note", "hi", "extra", int64(1)),
+			sqlRow("id", "u2", "age", int64(40), "admin", false, "avg", float64(3), "blob", []byte{1}),
+		)
+
+		var users []SQLUser
+		ast.NoError(QuerySQL(ctx, query, &users))
+		ast.Len(users, 2)
+		ast.Equal(2, fake.CallCount("SQLQuery"))
+
+		ast.Equal("u1", tea.StringValue(users[0].ID))
+		ast.Equal(int64(31), tea.Int64Value(users[0].Age))
+		ast.Equal(int64(1), tea.Int64Value(users[0].Admin))
+		ast.Equal(int64(2), tea.Int64Value(users[0].Avg))
+		ast.Equal([]byte("raw"), *users[0].Blob)
+		ast.Equal("hi", tea.StringValue(users[0].Note))
+
+		// 缺失的列即 NULL，字段保持 nil
+		ast.Equal(int64(0), tea.Int64Value(users[1].Admin))
+		ast.Nil(users[1].Note)
+	})
+
+	t.Run("maps and pointers", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.SetSQLResult(query, sqlRow("id", "u1", "score", 1.5))
+
+		var rows []map[string]any
+		ast.NoError(QuerySQL(ctx, query, &rows))
+		ast.Equal(map[string]any{"id": "u1", "score": 1.5}, rows[0])
+
+		// DOUBLE 不能无损写入 *int64 字段
+		type scored struct {
+			ID    *string `json:"id"`
+			Score *int64  `json:"score"`
+		}
+		var ptrs []*scored
+		ast.EqualError(QuerySQL(ctx, query, &ptrs), `column "score": cannot assign float64 value to field of type *int64`)
+	})
+
+	t.Run("strict rejects unknown columns", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		fake.SetSQLResult(query, sqlRow("id", "u1", "extra", int64(1)))
+
+		var users []SQLUser
+		ast.NoError(QuerySQL(ctx, query, &users))
+		err := QuerySQL(ctx, query, &users, SQLQueryParams{Strict: true})
+		ast.EqualError(err, `column "extra" has no field in otsutils.SQLUser`)
+	})
+
+	t.Run("service errors are returned", func(t *testing.T) {
+		ctx, _ := newFakeContext(t)
+		var users []SQLUser
+		err := QuerySQL(ctx, "SELECT 1", &users)
+		assert.Equal(t, CodeParameterInvalid, Code(err))
+	})
+}
@@ -0,0 +1,83 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// sqlNullValueType returns the type of the value field of t, or of the type t points to, when
+// it is one of the null types of database/sql, such as sql.NullString, sql.NullTime or
+// sql.Null[T], and Tablestore can store its value: a native type, a bool or a time.Time.
+func sqlNullValueType(t reflect.Type) (reflect.Type, bool) {
+	t = nativeBaseType(t)
+	if t.Kind() != reflect.Struct || t.PkgPath() != "database/sql" || !strings.HasPrefix(t.Name(), "Null") ||
+		t.NumField() != 2 || t.Field(1).Name != "Valid" {
+		return nil, false
+	}
+	vt := t.Field(0).Type
+	return vt, vt == timeType || vt.Kind() == reflect.Bool || vt.Kind() != reflect.Ptr && isNativeFieldType(vt)
+}
+
+// encodeSQLNull returns the column value of the field fm of a database/sql null type: its
+// value when Valid is set, as Unix milliseconds for a time.Time. skip is true when Valid is
+// not set or the field is a nil pointer.
+func (fm *fieldMeta) encodeSQLNull(field reflect.Value) (value any, skip bool, err error) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil, true, nil
+		}
+		field = field.Elem()
+	}
+	if !field.Field(1).Bool() {
+		return nil, true, nil
+	}
+	inner := field.Field(0)
+	switch {
+	case inner.Type() == timeType:
+		return inner.Interface().(time.Time).UnixMilli(), false, nil
+	case inner.Kind() == reflect.Bool:
+		return inner.Bool(), false, nil
+	}
+	return nativeColumnValue(inner), false, nil
+}
+
+// assignSQLNull assigns the column value to the field of a database/sql null type and sets
+// its Valid field, allocating the field when it is a pointer. assignNative assigns the values
+// of native types.
+func assignSQLNull(field reflect.Value, value any, assignNative func(field reflect.Value, value any) error) error {
+	target := field
+	if field.Kind() == reflect.Ptr {
+		target = reflect.New(field.Type().Elem()).Elem()
+	}
+
+	inner := target.Field(0)
+	switch {
+	case inner.Type() == timeType:
+		ms, ok := value.(int64)
+		if !ok {
+			return fmt.Errorf("cannot assign %T value to field of type %s, a time is stored as Unix milliseconds", value, field.Type())
+		}
+		inner.Set(reflect.ValueOf(time.UnixMilli(ms)))
+	case inner.Kind() == reflect.Bool:
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("cannot assign %T value to field of type %s", value, field.Type())
+		}
+		inner.SetBool(b)
+	default:
+		if err := assignNative(inner, value); err != nil {
+			return err
+		}
+	}
+	target.Field(1).SetBool(true)
+
+	if field.Kind() == reflect.Ptr {
+		field.Set(target.Addr())
+	}
+	return nil
+}
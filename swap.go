@@ -0,0 +1,107 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// DefaultSwapAttempts is the number of read-then-put rounds of PutRowSwap when
+// PutRowParams.SwapAttempts is not set.
+const DefaultSwapAttempts = 3
+
+// PutRowSwap overwrites the row described by obj and stores the row it replaced into old,
+// a pointer to a row struct. When the row did not exist, old is set to its zero value.
+//
+// Tablestore does not return the previous row of a PutRow, so the row is read from the
+// primary client first and then written under a condition: EXPECT_NOT_EXIST when it was
+// missing, otherwise that PutRowParams.SwapVersionColumn still holds the value read (or,
+// without a version column, that every attribute column does). If another writer gets in
+// between, the condition fails and both steps are retried, up to PutRowParams.SwapAttempts
+// times, after which the CodeConditionCheckFail error is returned and old holds the last read.
+//
+// This is weaker than a transaction: a concurrent write that leaves the compared columns
+// unchanged, e.g. one that does not bump the version column, goes unnoticed, and old is then
+// not exactly the row that was replaced.
+//
+// Example usage:
+//
+//	var old MyRow
+//	err := PutRowSwap(ctx, &row, &old, PutRowParams{SwapVersionColumn: "version"})
+func PutRowSwap(ctx context.Context, obj any, old any, params ...PutRowParams) error {
+	var p PutRowParams
+	if len(params) > 0 {
+		p = params[0]
+	}
+	attempts := p.SwapAttempts
+	if attempts <= 0 {
+		attempts = DefaultSwapAttempts
+	}
+
+	oldValue := reflect.ValueOf(old)
+	if oldValue.Kind() != reflect.Ptr || oldValue.IsNil() || oldValue.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("old must be a non-nil pointer to struct, got %T", old)
+	}
+	pks, _, err := parseRow(ctx, obj)
+	if err != nil {
+		return err
+	}
+	key := &rowKeyValues{PrimaryKey: pks}
+
+	for attempt := 1; ; attempt++ {
+		// Read the current row
+		var getResp *tablestore.GetRowResponse
+		if err := executeOTSOperation(ctx, "PutRowSwap", key, buildGetRowRequest, executeGetRow, captureGetRowResponse(&getResp)); err != nil {
+			return err
+		}
+		oldValue.Elem().SetZero()
+		var condition tablestore.ColumnFilter
+		expectation := tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST
+		if getResp != nil {
+			oldPks, oldCols := rowFromGetRowResponse(getResp)
+			if err := ParseResult(ctx, old, oldPks, oldCols); err != nil {
+				return err
+			}
+			expectation = tablestore.RowExistenceExpectation_EXPECT_EXIST
+			condition = swapCondition(oldCols, p.SwapVersionColumn)
+		}
+
+		// Write the new row only if the one read is still there
+		buildPut := func(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+			req, err := buildPutRowRequest(ctx, otsParams, logger, obj, params...)
+			if err != nil {
+				return nil, err
+			}
+			if condition != nil {
+				req.(*tablestore.PutRowRequest).PutRowChange.SetColumnCondition(condition)
+			}
+			return req, nil
+		}
+		putParams := p
+		putParams.RowExistenceExpectation = &expectation
+		putParams.ColumnCondition = nil
+		err := executeOTSOperation(ctx, "PutRowSwap", obj, buildPut, executePutRow, nil, putParams)
+		if err == nil || Code(err) != CodeConditionCheckFail || attempt == attempts {
+			return err
+		}
+	}
+}
+
+// swapCondition returns the condition under which the row read with cols is unchanged:
+// the version column keeps its value, or every column does when there is no version column.
+// It returns nil for a row without attribute columns, which only needs to exist.
+func swapCondition(cols []KeyValue, versionColumn string) tablestore.ColumnFilter {
+	for _, col := range cols {
+		if col.Key == versionColumn {
+			return unchangedColumnsCondition([]KeyValue{col})
+		}
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+	return unchangedColumnsCondition(cols)
+}
@@ -0,0 +1,107 @@
+package otsutils
+
+import (
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPutRowSwap(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	// 行不存在时 old 为零值，以 EXPECT_NOT_EXIST 写入
+	old := TestRow{Col1: tea.String("stale")}
+	ast.NoError(PutRowSwap(ctx, &TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v1"), Col2: tea.Int64(1)}, &old))
+	ast.Equal(TestRow{}, old)
+
+	// 行存在时 old 为被替换的行
+	ast.NoError(PutRowSwap(ctx, &TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v2"), Col2: tea.Int64(2)}, &old))
+	ast.Equal("v1", tea.StringValue(old.Col1))
+	ast.Equal(int64(1), tea.Int64Value(old.Col2))
+	ast.Equal("a", tea.StringValue(old.Pk1))
+
+	row := TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+	ast.NoError(GetRow(ctx, &row))
+	ast.Equal("v2", tea.StringValue(row.Col1))
+	ast.Equal(2, fake.CallCount("PutRow"))
+
+	ast.ErrorContains(PutRowSwap(ctx, &row, TestRow{}), "old must be a non-nil pointer to struct")
+}
+
+func TestPutRowSwapConflict(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v1"), Col2: tea.Int64(1)}))
+
+	// concurrentWrite 在 PutRowSwap 读取之后、写入之前修改 col2
+	writes := 0
+	concurrentWrite := func(limit int) func(string, any) error {
+		return func(operation string, request any) error {
+			if operation != "PutRow" || writes >= limit {
+				return nil
+			}
+			if req := request.(*tablestore.PutRowRequest); req.PutRowChange.Condition.ColumnCondition == nil {
+				return nil
+			}
+			writes++
+			change := &tablestore.UpdateRowChange{TableName: "test_table", PrimaryKey: &tablestore.PrimaryKey{}}
+			change.PrimaryKey.AddPrimaryKeyColumn("pk1", "a")
+			change.PrimaryKey.AddPrimaryKeyColumn("pk2", int64(1))
+			change.PutColumn("col2", int64(100+writes))
+			change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+			_, err := fake.UpdateRow(&tablestore.UpdateRowRequest{UpdateRowChange: change})
+			return err
+		}
+	}
+
+	// 版本列被并发修改后重试，old 为重新读取的行
+	fake.Intercept = concurrentWrite(1)
+	var old TestRow
+	err := PutRowSwap(ctx, &TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v2"), Col2: tea.Int64(2)}, &old, PutRowParams{SwapVersionColumn: "col2"})
+	ast.NoError(err)
+	ast.Equal(int64(101), tea.Int64Value(old.Col2))
+	ast.Equal(2, fake.CallCount("GetRow"))
+
+	// 一直冲突时重试 SwapAttempts 次后返回条件失败
+	writes = 0
+	fake.Intercept = concurrentWrite(100)
+	err = PutRowSwap(ctx, &TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col2: tea.Int64(3)}, &old, PutRowParams{SwapVersionColumn: "col2", SwapAttempts: 2})
+	ast.Equal(CodeConditionCheckFail, Code(err))
+	ast.Equal(2, writes)
+
+	// 没有版本列时比较所有读到的列
+	writes = 0
+	fake.Intercept = concurrentWrite(1)
+	err = PutRowSwap(ctx, &TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v3")}, &old)
+	ast.NoError(err)
+	ast.Equal(1, writes)
+	ast.Equal(int64(101), tea.Int64Value(old.Col2))
+}
+
+func TestPutRowSwapConcurrentInsert(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	// 读取时行不存在，写入前被另一个写入者插入
+	inserted := false
+	fake.Intercept = func(operation string, request any) error {
+		if operation != "PutRow" || inserted {
+			return nil
+		}
+		inserted = true
+		change := &tablestore.PutRowChange{TableName: "test_table", PrimaryKey: &tablestore.PrimaryKey{}}
+		change.PrimaryKey.AddPrimaryKeyColumn("pk1", "a")
+		change.PrimaryKey.AddPrimaryKeyColumn("pk2", int64(1))
+		change.AddColumn("col1", "other")
+		change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		_, err := fake.PutRow(&tablestore.PutRowRequest{PutRowChange: change})
+		return err
+	}
+
+	var old TestRow
+	ast.NoError(PutRowSwap(ctx, &TestRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("mine")}, &old))
+	ast.Equal("other", tea.StringValue(old.Col1))
+}
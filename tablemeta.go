@@ -0,0 +1,269 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// DefaultTableMetaTTL is how long table metadata is cached when OtsUtilsParams.TableMetaTTL is not set.
+const DefaultTableMetaTTL = 5 * time.Minute
+
+// TableDescription is the schema of a table as returned by DescribeTable.
+// Its JSON form uses the field names in lower camel case, with column types named
+// by the JSONType constants.
+type TableDescription struct {
+	TableName string `json:"tableName"`
+
+	// PrimaryKeys lists the primary key columns in schema order.
+	PrimaryKeys []PrimaryKeySchema `json:"primaryKeys"`
+
+	// DefinedColumns lists the predefined attribute columns.
+	DefinedColumns []DefinedColumn `json:"definedColumns,omitempty"`
+
+	// TimeToLive is the data TTL in seconds, -1 means never expire.
+	TimeToLive int `json:"timeToLive"`
+
+	// MaxVersions is the number of versions kept per column.
+	MaxVersions int `json:"maxVersions"`
+
+	// ReservedRead and ReservedWrite are the reserved read and write capacity units.
+	ReservedRead  int `json:"reservedRead,omitempty"`
+	ReservedWrite int `json:"reservedWrite,omitempty"`
+
+	// Indexes lists the secondary indexes of the table.
+	Indexes []IndexDescription `json:"indexes,omitempty"`
+}
+
+// PrimaryKeySchema describes one primary key column.
+type PrimaryKeySchema struct {
+	Name          string
+	Type          tablestore.PrimaryKeyType
+	AutoIncrement bool
+}
+
+// IndexDescription describes one secondary index.
+type IndexDescription struct {
+	Name string `json:"name"`
+
+	// PrimaryKeys lists the primary key columns of the index in order.
+	PrimaryKeys []string `json:"primaryKeys"`
+
+	// DefinedColumns lists the attribute columns the index covers.
+	DefinedColumns []string `json:"definedColumns,omitempty"`
+}
+
+// DefinedColumn describes one predefined attribute column.
+type DefinedColumn struct {
+	Name string
+	Type tablestore.DefinedColumnType
+}
+
+// tableMetaKey identifies a table of a client. client is the identity of the client, see
+// clientIdentity, rather than the client value, which may not be comparable.
+type tableMetaKey struct {
+	client    any
+	tableName string
+}
+
+// clientIdentity returns a comparable value identifying the client of otsParams: the client
+// itself when it is a pointer, which is how clients are normally passed, or else otsParams,
+// so that a client value is only shared by the contexts made from the same OtsUtilsParams.
+func clientIdentity(otsParams *OtsUtilsParams) any {
+	if reflect.ValueOf(otsParams.Client).Kind() == reflect.Ptr {
+		return otsParams.Client
+	}
+	return otsParams
+}
+
+// tableMetaEntry is a cached or in-flight DescribeTable result.
+// done is closed once desc and err are set.
+type tableMetaEntry struct {
+	done      chan struct{}
+	desc      *TableDescription
+	err       error
+	fetchedAt time.Time
+}
+
+var (
+	tableMetaMu    sync.Mutex
+	tableMetaCache = map[tableMetaKey]*tableMetaEntry{}
+)
+
+// TableMeta returns the description of the table in the context.
+// The result is fetched once per client and table and cached for OtsUtilsParams.TableMetaTTL;
+// concurrent callers share a single DescribeTable call. Errors are not cached.
+//
+// Example usage:
+//
+//	desc, err := TableMeta(ctx)
+//	if err != nil {
+//	    return err
+//	}
+//	for _, pk := range desc.PrimaryKeys {
+//	    fmt.Println(pk.Name)
+//	}
+func TableMeta(ctx context.Context) (*TableDescription, error) {
+	otsParams := otsUtilsParamsFromCtx(ctx)
+	logger := zerolog.Ctx(ctx).With().Str("operation", "TableMeta").Str("table", otsParams.TableName).Logger()
+
+	ttl := otsParams.TableMetaTTL
+	if ttl <= 0 {
+		ttl = DefaultTableMetaTTL
+	}
+	key := tableMetaKey{client: clientIdentity(otsParams), tableName: otsParams.TableName}
+
+	tableMetaMu.Lock()
+	entry, ok := tableMetaCache[key]
+	if ok {
+		select {
+		case <-entry.done:
+			if entry.err != nil || time.Since(entry.fetchedAt) > ttl {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		entry = &tableMetaEntry{done: make(chan struct{})}
+		tableMetaCache[key] = entry
+		tableMetaMu.Unlock()
+
+		logger.Debug().Msg("Fetching table metadata")
+		entry.desc, entry.err = describeTable(otsParams.Client, otsParams.TableName)
+		entry.fetchedAt = time.Now()
+		close(entry.done)
+		if entry.err != nil {
+			logger.Error().Err(entry.err).Msg("Failed to fetch table metadata")
+		}
+	} else {
+		tableMetaMu.Unlock()
+	}
+
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return entry.desc, entry.err
+}
+
+// DescribeTable returns the description of the table in the context, read from the service on
+// every call. TableMeta serves the same description from a cache.
+//
+// Example usage:
+//
+//	desc, err := DescribeTable(ctx)
+//	if err == nil {
+//	    fmt.Println(desc.ReservedRead, desc.ReservedWrite)
+//	}
+func DescribeTable(ctx context.Context) (*TableDescription, error) {
+	var desc *TableDescription
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		desc = tableDescriptionFromResponse(obj.(string), resp.(*tablestore.DescribeTableResponse))
+		return nil
+	}
+	tableName := otsUtilsParamsFromCtx(ctx).TableName
+	if err := executeOTSOperation(ctx, "DescribeTable", tableName, buildDescribeTableRequest, executeDescribeTable, handleResp); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+func buildDescribeTableRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	return &tablestore.DescribeTableRequest{TableName: obj.(string)}, nil
+}
+
+func executeDescribeTable(client OtsClient, req any) (any, error) {
+	tableClient, err := clientAs[TableClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return tableClient.DescribeTable(req.(*tablestore.DescribeTableRequest))
+}
+
+// cachedTableMeta returns the cached description of the table in otsParams without fetching it,
+// or nil when it is not cached, failed or has expired.
+func cachedTableMeta(otsParams *OtsUtilsParams) *TableDescription {
+	ttl := otsParams.TableMetaTTL
+	if ttl <= 0 {
+		ttl = DefaultTableMetaTTL
+	}
+
+	tableMetaMu.Lock()
+	entry, ok := tableMetaCache[tableMetaKey{client: clientIdentity(otsParams), tableName: otsParams.TableName}]
+	tableMetaMu.Unlock()
+	if !ok {
+		return nil
+	}
+	select {
+	case <-entry.done:
+		if entry.err == nil && time.Since(entry.fetchedAt) <= ttl {
+			return entry.desc
+		}
+	default:
+	}
+	return nil
+}
+
+// InvalidateTableMeta drops the cached metadata of the table for every client,
+// so the next TableMeta call fetches it again.
+func InvalidateTableMeta(tableName string) {
+	tableMetaMu.Lock()
+	defer tableMetaMu.Unlock()
+
+	for key := range tableMetaCache {
+		if key.tableName == tableName {
+			delete(tableMetaCache, key)
+		}
+	}
+}
+
+func describeTable(client OtsClient, tableName string) (*TableDescription, error) {
+	tableClient, err := clientAs[TableClient](client)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := tableClient.DescribeTable(&tablestore.DescribeTableRequest{TableName: tableName})
+	if err != nil {
+		return nil, err
+	}
+	return tableDescriptionFromResponse(tableName, resp), nil
+}
+
+func tableDescriptionFromResponse(tableName string, resp *tablestore.DescribeTableResponse) *TableDescription {
+	desc := &TableDescription{TableName: tableName}
+	if resp.TableMeta != nil {
+		for _, s := range resp.TableMeta.SchemaEntry {
+			pk := PrimaryKeySchema{}
+			if s.Name != nil {
+				pk.Name = *s.Name
+			}
+			if s.Type != nil {
+				pk.Type = *s.Type
+			}
+			pk.AutoIncrement = s.Option != nil && *s.Option == tablestore.AUTO_INCREMENT
+			desc.PrimaryKeys = append(desc.PrimaryKeys, pk)
+		}
+		for _, c := range resp.TableMeta.DefinedColumns {
+			desc.DefinedColumns = append(desc.DefinedColumns, DefinedColumn{Name: c.Name, Type: c.ColumnType})
+		}
+	}
+	if resp.TableOption != nil {
+		desc.TimeToLive = resp.TableOption.TimeToAlive
+		desc.MaxVersions = resp.TableOption.MaxVersion
+	}
+	if resp.ReservedThroughput != nil {
+		desc.ReservedRead = resp.ReservedThroughput.Readcap
+		desc.ReservedWrite = resp.ReservedThroughput.Writecap
+	}
+	for _, idx := range resp.IndexMetas {
+		desc.Indexes = append(desc.Indexes, IndexDescription{Name: idx.IndexName, PrimaryKeys: idx.Primarykey, DefinedColumns: idx.DefinedColumns})
+	}
+	return desc
+}
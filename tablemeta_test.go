@@ -0,0 +1,185 @@
+package otsutils
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/117503445/otsutils/otsfake"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableMeta(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	desc, err := TableMeta(ctx)
+	ast.NoError(err)
+	ast.Equal("test_table", desc.TableName)
+	ast.Equal([]PrimaryKeySchema{
+		{Name: "pk1", Type: tablestore.PrimaryKeyType_STRING},
+		{Name: "pk2", Type: tablestore.PrimaryKeyType_INTEGER},
+	}, desc.PrimaryKeys)
+
+	// 命中缓存
+	_, err = TableMeta(ctx)
+	ast.NoError(err)
+	ast.Equal(1, fake.CallCount("DescribeTable"))
+
+	// 显式失效后重新获取
+	InvalidateTableMeta("test_table")
+	_, err = TableMeta(ctx)
+	ast.NoError(err)
+	ast.Equal(2, fake.CallCount("DescribeTable"))
+}
+
+// uncomparableClient is a client value whose type can not be compared, because of its slice.
+type uncomparableClient struct {
+	*otsfake.Client
+	tags []string
+}
+
+func TestTableMetaUncomparableClient(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	o := OtsUtilsParams{Client: uncomparableClient{Client: fake, tags: []string{"a"}}, TableName: "test_table"}
+	ctx = o.WithContext(ctx)
+
+	// 不可比较的客户端不会让缓存 panic，同一 OtsUtilsParams 仍共享缓存
+	for i := 0; i < 2; i++ {
+		desc, err := TableMeta(ctx)
+		ast.NoError(err)
+		ast.Equal("test_table", desc.TableName)
+	}
+	ast.Equal(1, fake.CallCount("DescribeTable"))
+	ast.NotNil(cachedTableMeta(&o))
+}
+
+func TestDescribeTable(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	ast.NoError(CreateTableFromStruct(ctx, &RangeRow{}, CreateTableOptions{TableName: "reserved", TimeToLive: 86400, MaxVersions: 2, ReservedRead: 10, ReservedWrite: 5}))
+
+	o := OtsUtilsParams{Client: fake, TableName: "reserved"}
+	desc, err := DescribeTable(o.WithContext(ctx))
+	ast.NoError(err)
+	ast.Equal(&TableDescription{
+		TableName: "reserved",
+		PrimaryKeys: []PrimaryKeySchema{
+			{Name: "pk1", Type: tablestore.PrimaryKeyType_STRING},
+			{Name: "pk2", Type: tablestore.PrimaryKeyType_INTEGER},
+		},
+		TimeToLive:    86400,
+		MaxVersions:   2,
+		ReservedRead:  10,
+		ReservedWrite: 5,
+	}, desc)
+
+	// 不使用缓存
+	_, err = DescribeTable(o.WithContext(ctx))
+	ast.NoError(err)
+	ast.Equal(2, fake.CallCount("DescribeTable"))
+
+	o.TableName = "missing"
+	_, err = DescribeTable(o.WithContext(ctx))
+	ast.ErrorIs(err, ErrObjectNotExist)
+}
+
+func TestTableMetaConcurrent(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	fake.Intercept = func(operation string, request any) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			desc, err := TableMeta(ctx)
+			ast.NoError(err)
+			ast.Len(desc.PrimaryKeys, 2)
+		}()
+	}
+	wg.Wait()
+	ast.Equal(1, fake.CallCount("DescribeTable"))
+}
+
+func TestTableMetaTTL(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	OtsUtilsParamsFromCtx(ctx).TableMetaTTL = 20 * time.Millisecond
+
+	desc, err := TableMeta(ctx)
+	ast.NoError(err)
+	ast.Len(desc.PrimaryKeys, 2)
+
+	// 表结构变更, TTL 过期后自动刷新
+	_, err = fake.DeleteTable(&tablestore.DeleteTableRequest{TableName: "test_table"})
+	ast.NoError(err)
+	fake.MustCreateTable("test_table", "id", tablestore.PrimaryKeyType_STRING)
+
+	desc, err = TableMeta(ctx)
+	ast.NoError(err)
+	ast.Len(desc.PrimaryKeys, 2)
+
+	time.Sleep(30 * time.Millisecond)
+	desc, err = TableMeta(ctx)
+	ast.NoError(err)
+	ast.Equal([]PrimaryKeySchema{{Name: "id", Type: tablestore.PrimaryKeyType_STRING}}, desc.PrimaryKeys)
+}
+
+func TestTableMetaErrorNotCached(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+
+	o := OtsUtilsParams{Client: fake, TableName: "later_table"}
+	ctx = o.WithContext(ctx)
+
+	_, err := TableMeta(ctx)
+	ast.Equal(CodeObjectNotExist, Code(err))
+
+	fake.MustCreateTable("later_table", "id", tablestore.PrimaryKeyType_INTEGER)
+	desc, err := TableMeta(ctx)
+	ast.NoError(err)
+	ast.Len(desc.PrimaryKeys, 1)
+	ast.Equal(2, fake.CallCount("DescribeTable"))
+}
+
+func TestWithContextValidated(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	fake.MustCreateTable("users", "id", tablestore.PrimaryKeyType_STRING)
+	fake.MustCreateTable("orders", "id", tablestore.PrimaryKeyType_STRING)
+
+	o := OtsUtilsParams{Client: fake, TableName: "users"}
+	vctx, err := o.WithContextValidated(ctx)
+	ast.NoError(err)
+	ast.Equal(1, fake.CallCount("DescribeTable"))
+
+	// 校验结果写入元数据缓存
+	_, err = TableMeta(vctx)
+	ast.NoError(err)
+	ast.Equal(1, fake.CallCount("DescribeTable"))
+
+	o = OtsUtilsParams{Client: fake, TableName: "user", InstanceName: "prod-a"}
+	_, err = o.WithContextValidated(ctx)
+	ast.ErrorContains(err, "table 'user' not found on instance 'prod-a'; did you mean 'users'?")
+	ast.Equal(CodeObjectNotExist, Code(err))
+
+	o = OtsUtilsParams{Client: fake, TableName: "zzz"}
+	_, err = o.WithContextValidated(ctx)
+	ast.ErrorContains(err, "table 'zzz' not found: ")
+	ast.NotContains(err.Error(), "did you mean")
+}
+
+func TestSimilarNames(t *testing.T) {
+	ast := assert.New(t)
+	ast.Equal([]string{"users", "Users_"}, similarNames("user", []string{"orders", "Users_", "users"}))
+	ast.Nil(similarNames("user", []string{"orders"}))
+}
@@ -0,0 +1,160 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// DeleteTable deletes a table and all its rows: tableName when given, otherwise the table of
+// OtsUtilsParams. Its cached metadata is dropped. Deleting a missing table fails with an error
+// matching ErrObjectNotExist, which a test teardown may ignore.
+//
+// Example usage:
+//
+//	if err := DeleteTable(ctx); err != nil && !errors.Is(err, ErrObjectNotExist) {
+//	    return err
+//	}
+func DeleteTable(ctx context.Context, tableName ...string) error {
+	var name string
+	if len(tableName) > 0 {
+		name = tableName[0]
+	}
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		InvalidateTableMeta(obj.(*tablestore.DeleteTableRequest).TableName)
+		return nil
+	}
+	return executeOTSOperation(ctx, "DeleteTable", &tablestore.DeleteTableRequest{TableName: name}, buildDeleteTableRequest, executeDeleteTable, handleResp)
+}
+
+func buildDeleteTableRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	req := obj.(*tablestore.DeleteTableRequest)
+	if req.TableName == "" {
+		req.TableName = otsParams.TableName
+	}
+	if err := validateName("table", req.TableName); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func executeDeleteTable(client OtsClient, req any) (any, error) {
+	tableClient, err := clientAs[TableClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return tableClient.DeleteTable(req.(*tablestore.DeleteTableRequest))
+}
+
+// ListTables returns the names of the tables of the instance.
+//
+// Example usage:
+//
+//	names, err := ListTables(ctx)
+func ListTables(ctx context.Context) ([]string, error) {
+	var names []string
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		names = resp.(*tablestore.ListTableResponse).TableNames
+		return nil
+	}
+	err := executeOTSOperation(ctx, "ListTables", nil, buildListTableRequest, executeListTable, handleResp)
+	return names, err
+}
+
+func buildListTableRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	return nil, nil
+}
+
+func executeListTable(client OtsClient, req any) (any, error) {
+	tableClient, err := clientAs[TableClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return tableClient.ListTable()
+}
+
+// UpdateTable changes the TTL, max versions and reserved throughput of the table of
+// OtsUtilsParams, leaving the settings whose field in opts is zero unchanged, and returns the
+// description of the table with the resulting settings. The service takes the TTL and max
+// versions together, and the read and write throughput together, so the current table is read
+// first to fill the ones left unchanged. Its cached metadata is dropped.
+//
+// Example usage:
+//
+//	// Keep 30 days of data, leaving the rest as is
+//	desc, err := UpdateTable(ctx, UpdateTableOptions{TimeToLive: 30 * 86400})
+func UpdateTable(ctx context.Context, opts UpdateTableOptions) (*TableDescription, error) {
+	if opts.TimeToLive < -1 {
+		return nil, fmt.Errorf("TimeToLive must be -1 or positive, got %d", opts.TimeToLive)
+	}
+	if opts.MaxVersions < 0 {
+		return nil, fmt.Errorf("MaxVersions must not be negative, got %d", opts.MaxVersions)
+	}
+	if opts.ReservedRead < 0 || opts.ReservedWrite < 0 {
+		return nil, fmt.Errorf("reserved throughput must not be negative, got read %d and write %d", opts.ReservedRead, opts.ReservedWrite)
+	}
+	if opts == (UpdateTableOptions{}) {
+		return nil, fmt.Errorf("UpdateTableOptions changes nothing")
+	}
+
+	desc, err := DescribeTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		r := resp.(*tablestore.UpdateTableResponse)
+		if r.TableOption != nil {
+			desc.TimeToLive = r.TableOption.TimeToAlive
+			desc.MaxVersions = r.TableOption.MaxVersion
+		}
+		if r.ReservedThroughput != nil {
+			desc.ReservedRead = r.ReservedThroughput.Readcap
+			desc.ReservedWrite = r.ReservedThroughput.Writecap
+		}
+		InvalidateTableMeta(desc.TableName)
+		logger.Debug().Int("timeToLive", desc.TimeToLive).Int("maxVersions", desc.MaxVersions).
+			Int("reservedRead", desc.ReservedRead).Int("reservedWrite", desc.ReservedWrite).Msg("Table updated")
+		return nil
+	}
+	if err := executeOTSOperation(ctx, "UpdateTable", desc, buildUpdateTableRequest, executeUpdateTable, handleResp, opts); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+func buildUpdateTableRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	current := obj.(*TableDescription)
+	opts := params[0].(UpdateTableOptions)
+	req := &tablestore.UpdateTableRequest{TableName: current.TableName}
+
+	if opts.TimeToLive != 0 || opts.MaxVersions != 0 {
+		req.TableOption = tablestore.NewTableOption(current.TimeToLive, current.MaxVersions)
+		if opts.TimeToLive != 0 {
+			req.TableOption.TimeToAlive = opts.TimeToLive
+		}
+		if opts.MaxVersions != 0 {
+			req.TableOption.MaxVersion = opts.MaxVersions
+		}
+	}
+	if opts.ReservedRead != 0 || opts.ReservedWrite != 0 {
+		req.ReservedThroughput = &tablestore.ReservedThroughput{Readcap: current.ReservedRead, Writecap: current.ReservedWrite}
+		if opts.ReservedRead != 0 {
+			req.ReservedThroughput.Readcap = opts.ReservedRead
+		}
+		if opts.ReservedWrite != 0 {
+			req.ReservedThroughput.Writecap = opts.ReservedWrite
+		}
+	}
+	return req, nil
+}
+
+func executeUpdateTable(client OtsClient, req any) (any, error) {
+	tableClient, err := clientAs[TableClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return tableClient.UpdateTable(req.(*tablestore.UpdateTableRequest))
+}
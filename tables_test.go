@@ -0,0 +1,71 @@
+package otsutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteTableAndListTables(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	ast.NoError(CreateTableFromStruct(ctx, &RangeRow{}, CreateTableOptions{TableName: "other"}))
+
+	names, err := ListTables(ctx)
+	ast.NoError(err)
+	ast.Equal([]string{"other", "test_table"}, names)
+
+	// 删除后缓存的表元数据失效
+	_, err = TableMeta(ctx)
+	ast.NoError(err)
+	ast.NotNil(cachedTableMeta(otsUtilsParamsFromCtx(ctx)))
+	ast.NoError(DeleteTable(ctx))
+	ast.Nil(cachedTableMeta(otsUtilsParamsFromCtx(ctx)))
+
+	ast.NoError(DeleteTable(ctx, "other"))
+	names, err = ListTables(ctx)
+	ast.NoError(err)
+	ast.Empty(names)
+
+	// 表不存在时返回可识别的错误
+	err = DeleteTable(ctx)
+	ast.ErrorIs(err, ErrObjectNotExist)
+	ast.Equal(CodeObjectNotExist, Code(err))
+	ast.Equal(3, fake.CallCount("DeleteTable"))
+
+	ast.EqualError(DeleteTable(ctx, "bad-name"), `table name "bad-name" contains '-' at byte 3; only letters, digits and underscores are allowed`)
+}
+
+func TestUpdateTable(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	ast.NoError(CreateTableFromStruct(ctx, &RangeRow{}, CreateTableOptions{TableName: "tuned", TimeToLive: 86400, MaxVersions: 2, ReservedRead: 3, ReservedWrite: 4}))
+	o := OtsUtilsParams{Client: fake, TableName: "tuned"}
+	ctx = o.WithContext(ctx)
+
+	// 只修改 TTL，其余保持不变
+	desc, err := UpdateTable(ctx, UpdateTableOptions{TimeToLive: 2 * 86400})
+	ast.NoError(err)
+	ast.Equal(2*86400, desc.TimeToLive)
+	ast.Equal(2, desc.MaxVersions)
+	ast.Equal(3, desc.ReservedRead)
+	ast.Equal(4, desc.ReservedWrite)
+
+	desc, err = UpdateTable(ctx, UpdateTableOptions{MaxVersions: 5, ReservedWrite: 1})
+	ast.NoError(err)
+	ast.Equal(2*86400, desc.TimeToLive)
+	ast.Equal(5, desc.MaxVersions)
+	ast.Equal(3, desc.ReservedRead)
+	ast.Equal(1, desc.ReservedWrite)
+
+	// 缓存的表元数据已失效
+	meta, err := TableMeta(ctx)
+	ast.NoError(err)
+	ast.Equal(5, meta.MaxVersions)
+
+	_, err = UpdateTable(ctx, UpdateTableOptions{})
+	ast.EqualError(err, "UpdateTableOptions changes nothing")
+	_, err = UpdateTable(ctx, UpdateTableOptions{TimeToLive: -2})
+	ast.EqualError(err, "TimeToLive must be -1 or positive, got -2")
+	ast.Equal(2, fake.CallCount("UpdateTable"))
+}
@@ -0,0 +1,71 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/rs/zerolog"
+)
+
+type requestTagsCtxKey struct{}
+
+// reservedLogFields are the field names written by the executor and zerolog itself.
+// Request tags with these names are logged with a "tag_" prefix instead.
+var reservedLogFields = map[string]bool{
+	"operation":                 true,
+	"client":                    true,
+	"table":                     true,
+	"obj":                       true,
+	"params":                    true,
+	"deadlineRemaining":         true,
+	"request":                   true,
+	"response":                  true,
+	"read_cu":                   true,
+	"write_cu":                  true,
+	zerolog.LevelFieldName:      true,
+	zerolog.TimestampFieldName:  true,
+	zerolog.MessageFieldName:    true,
+	zerolog.CallerFieldName:     true,
+	zerolog.ErrorFieldName:      true,
+	zerolog.ErrorStackFieldName: true,
+}
+
+// WithRequestTags returns a context whose operations carry tags, such as a tenant or job id.
+// Every operation log line gets the tags as fields, and hooks can read them with RequestTags.
+// Tags already on ctx are kept unless overridden; the map is copied, so the caller may reuse it.
+//
+// Example usage:
+//
+//	ctx = WithRequestTags(ctx, map[string]string{"tenant": "t1", "job": "nightly-sync"})
+func WithRequestTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := RequestTags(ctx)
+	if merged == nil {
+		merged = make(map[string]string, len(tags))
+	}
+	maps.Copy(merged, tags)
+	return context.WithValue(ctx, requestTagsCtxKey{}, merged)
+}
+
+// RequestTags returns a copy of the tags set with WithRequestTags, or nil when there are none.
+func RequestTags(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(requestTagsCtxKey{}).(map[string]string)
+	if tags == nil {
+		return nil
+	}
+	return maps.Clone(tags)
+}
+
+// withRequestTags adds the request tags of ctx to the logger context in key order.
+func withRequestTags(ctx context.Context, logCtx zerolog.Context) zerolog.Context {
+	tags, _ := ctx.Value(requestTagsCtxKey{}).(map[string]string)
+	for _, key := range slices.Sorted(maps.Keys(tags)) {
+		field := key
+		if reservedLogFields[key] {
+			field = "tag_" + key
+		}
+		logCtx = logCtx.Str(field, tags[key])
+	}
+	return logCtx
+}
@@ -0,0 +1,75 @@
+package otsutils
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTags(t *testing.T) {
+	ast := assert.New(t)
+
+	ast.Nil(RequestTags(context.Background()))
+
+	// 传入的 map 被复制，后续修改不影响 context
+	tags := map[string]string{"tenant": "t1"}
+	ctx := WithRequestTags(context.Background(), tags)
+	tags["tenant"] = "t2"
+	ast.Equal(map[string]string{"tenant": "t1"}, RequestTags(ctx))
+
+	// 返回值同样是副本
+	RequestTags(ctx)["tenant"] = "t3"
+	ast.Equal("t1", RequestTags(ctx)["tenant"])
+
+	// 嵌套调用合并标签
+	child := WithRequestTags(ctx, map[string]string{"job": "j1", "tenant": "t4"})
+	ast.Equal(map[string]string{"tenant": "t4", "job": "j1"}, RequestTags(child))
+	ast.Equal(map[string]string{"tenant": "t1"}, RequestTags(ctx))
+}
+
+func TestRequestTagsInLogsAndHooks(t *testing.T) {
+	ast := assert.New(t)
+	ctx, fake := newFakeContext(t)
+	fake.MustCreateTable("audit", "id", tablestore.PrimaryKeyType_STRING)
+
+	var buf bytes.Buffer
+	ctx = zerolog.New(&buf).Level(zerolog.DebugLevel).WithContext(ctx)
+	hook := &recordingAuditHook{}
+	OtsUtilsParamsFromCtx(ctx).AuditHook = hook
+
+	ctx = WithRequestTags(ctx, map[string]string{"tenant": "t1", "operation": "sync", "job": "j1"})
+	ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)}))
+
+	// 与执行器字段重名的标签加前缀，不覆盖原字段
+	ast.Contains(buf.String(), `"operation":"PutRow","client":"primary","table":"test_table","job":"j1","tag_operation":"sync","tenant":"t1"`)
+	ast.NotContains(buf.String(), `"operation":"sync"`)
+
+	// 执行器在各条日志中写入的其它字段同样保留
+	buf.Reset()
+	reserved := map[string]string{"obj": "o", "params": "p", "request": "rq", "response": "rs", "read_cu": "r", "write_cu": "w", "deadlineRemaining": "d"}
+	ast.NoError(PutRow(WithRequestTags(ctx, reserved), &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(2)}))
+	for key, value := range reserved {
+		ast.Contains(buf.String(), `"tag_`+key+`":"`+value+`"`)
+		ast.NotContains(buf.String(), `"`+key+`":"`+value+`"`)
+	}
+
+	// TableAuditHook 记录标签
+	OtsUtilsParamsFromCtx(ctx).AuditHook = &TableAuditHook{Client: fake, TableName: "audit"}
+	ast.NoError(DeleteRow(ctx, &TestRow{Pk1: tea.String("pk1"), Pk2: tea.Int64(1)}))
+
+	o := OtsUtilsParams{Client: fake, TableName: "audit"}
+	type auditTags struct {
+		Id   *string `json:"id" pk:"1"`
+		Tags *string `json:"tags"`
+	}
+	var rows []auditTags
+	ast.NoError(GetRange(o.WithContext(ctx), &auditTags{}, &auditTags{}, &rows))
+	if ast.Len(rows, 1) {
+		ast.JSONEq(`{"tenant":"t1","operation":"sync","job":"j1"}`, tea.StringValue(rows[0].Tags))
+	}
+}
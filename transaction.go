@@ -0,0 +1,237 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// Txn is a local transaction started by BeginTransaction. It covers the rows of the table whose
+// partition key, the first primary key column, has the value it was started with.
+type Txn struct {
+	id *string
+
+	// ended is "committed" or "aborted" once the transaction is over
+	ended string
+}
+
+// BeginTransaction starts a local transaction on the table of OtsUtilsParams. The partition key
+// is read from partitionKeyObj, a pointer to a row struct whose `pk:"1"` field, the partition key,
+// must be set; the other pk fields are ignored.
+//
+// The operations join the transaction with the TransactionId of their params: PutRowParams,
+// UpdateRowParams, DeleteRowParams and GetRowParams. Their rows must have the partition key of
+// the transaction. Writes are only visible to other readers once Commit returns, and Abort
+// discards them. A GetRow in the transaction reads from Client even when ReadClient is set.
+// WithTransaction ends the transaction on its own once a function returns.
+//
+// Example usage:
+//
+//	txn, err := BeginTransaction(ctx, &Order{UserID: tea.String("u1")})
+//	if err != nil {
+//	    return err
+//	}
+//	if err := PutRow(ctx, &order, PutRowParams{TransactionId: txn.TransactionId()}); err != nil {
+//	    _ = txn.Abort(ctx)
+//	    return err
+//	}
+//	return txn.Commit(ctx)
+func BeginTransaction(ctx context.Context, partitionKeyObj any) (*Txn, error) {
+	partitionKey, err := partitionKeyOf(partitionKeyObj)
+	if err != nil {
+		return nil, err
+	}
+
+	txn := &Txn{}
+	handleResp := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+		txn.id = resp.(*tablestore.StartLocalTransactionResponse).TransactionId
+		if txn.id == nil {
+			return fmt.Errorf("StartLocalTransaction returned no transaction id")
+		}
+		logger.Debug().Str("transactionId", *txn.id).Msg("Transaction started")
+		return nil
+	}
+	if err := executeOTSOperation(ctx, "StartLocalTransaction", partitionKey, buildStartLocalTransactionRequest, executeStartLocalTransaction, handleResp); err != nil {
+		return nil, err
+	}
+	return txn, nil
+}
+
+// partitionKeyOf returns the partition key column of the row struct obj points to.
+func partitionKeyOf(obj any) (KeyValue, error) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return KeyValue{}, fmt.Errorf("partitionKeyObj must be a non-nil pointer to struct, got %T", obj)
+	}
+	meta, err := getStructMeta(v.Elem().Type())
+	if err != nil {
+		return KeyValue{}, err
+	}
+	if len(meta.pkFields) == 0 {
+		return KeyValue{}, fmt.Errorf("%s has no pk field", v.Elem().Type())
+	}
+	fm := meta.fields[meta.pkFields[0]]
+	value, skip, err := fm.value(fm.field(v.Elem()))
+	if err != nil {
+		return KeyValue{}, err
+	}
+	if skip {
+		return KeyValue{}, fmt.Errorf("partition key field %s is not set", fm.name)
+	}
+	return KeyValue{Key: fm.column, Value: value}, nil
+}
+
+// TransactionId returns the id of the transaction, to set as the TransactionId of the params
+// of the operations that join it.
+func (txn *Txn) TransactionId() *string {
+	return txn.id
+}
+
+// Commit ends the transaction, making its writes visible.
+func (txn *Txn) Commit(ctx context.Context) error {
+	if err := txn.end("committed"); err != nil {
+		return err
+	}
+	return executeOTSOperation(ctx, "CommitTransaction", txn, buildCommitTransactionRequest, executeCommitTransaction, nil)
+}
+
+// Abort ends the transaction, discarding its writes.
+func (txn *Txn) Abort(ctx context.Context) error {
+	if err := txn.end("aborted"); err != nil {
+		return err
+	}
+	return executeOTSOperation(ctx, "AbortTransaction", txn, buildAbortTransactionRequest, executeAbortTransaction, nil)
+}
+
+// end marks the transaction as over, failing when it already is.
+func (txn *Txn) end(state string) error {
+	if txn.ended != "" {
+		return fmt.Errorf("transaction %s is already %s", *txn.id, txn.ended)
+	}
+	txn.ended = state
+	return nil
+}
+
+type transactionCtxKey struct{}
+
+// WithTransaction runs fn inside a local transaction on the partition of partitionKeyObj, as
+// started by BeginTransaction. The PutRow, UpdateRow, DeleteRow and GetRow calls made with
+// txCtx, including their map variants, join the transaction unless their params set another
+// TransactionId. The transaction is committed when fn returns nil and aborted when it returns
+// an error or panics; the panic is then raised again. fn must not use the transaction after it
+// returns, nor call WithTransaction with txCtx: local transactions do not nest.
+//
+// Example usage:
+//
+//	err := WithTransaction(ctx, &Order{UserID: tea.String("u1")}, func(txCtx context.Context) error {
+//	    if err := GetRow(txCtx, &account); err != nil {
+//	        return err
+//	    }
+//	    *account.Balance -= *order.Amount
+//	    if err := UpdateRow(txCtx, &account); err != nil {
+//	        return err
+//	    }
+//	    return PutRow(txCtx, &order)
+//	})
+func WithTransaction(ctx context.Context, partitionKeyObj any, fn func(txCtx context.Context) error) error {
+	if fn == nil {
+		return fmt.Errorf("fn can not be nil")
+	}
+	if txn, _ := ctx.Value(transactionCtxKey{}).(*Txn); txn != nil {
+		return fmt.Errorf("WithTransaction can not be nested: transaction %s is already open on the context", *txn.id)
+	}
+	txn, err := BeginTransaction(ctx, partitionKeyObj)
+	if err != nil {
+		return err
+	}
+	// The transaction is aborted even when ctx is why fn failed
+	abortCtx := context.WithoutCancel(ctx)
+
+	defer func() {
+		if r := recover(); r != nil {
+			if err := txn.Abort(abortCtx); err != nil {
+				otsUtilsParamsFromCtx(ctx).baseLogger(ctx).Error().Err(err).Str("transactionId", *txn.id).Msg("Failed to abort transaction after panic")
+			}
+			panic(r)
+		}
+	}()
+	if err := fn(context.WithValue(ctx, transactionCtxKey{}, txn)); err != nil {
+		if abortErr := txn.Abort(abortCtx); abortErr != nil {
+			return errors.Join(err, fmt.Errorf("abort transaction %s: %w", *txn.id, abortErr))
+		}
+		return err
+	}
+	return txn.Commit(ctx)
+}
+
+// ctxTransactionId returns id, or when it is nil the id of the transaction of the
+// WithTransaction ctx is in, if any.
+func ctxTransactionId(ctx context.Context, id *string) *string {
+	if id != nil {
+		return id
+	}
+	if txn, _ := ctx.Value(transactionCtxKey{}).(*Txn); txn != nil {
+		return txn.id
+	}
+	return nil
+}
+
+// transactionCtx returns the context of a GetRow, which reads from the primary client when it
+// is part of a transaction.
+func transactionCtx(ctx context.Context, params []GetRowParams) context.Context {
+	var id *string
+	if len(params) > 0 {
+		id = params[0].TransactionId
+	}
+	if ctxTransactionId(ctx, id) != nil {
+		return ReadFromPrimary(ctx)
+	}
+	return ctx
+}
+
+func buildStartLocalTransactionRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	partitionKey := obj.(KeyValue)
+	if err := validateKeyValues("partition key", []KeyValue{partitionKey}); err != nil {
+		return nil, err
+	}
+	pk := &tablestore.PrimaryKey{}
+	pk.AddPrimaryKeyColumn(partitionKey.Key, partitionKey.Value)
+	return &tablestore.StartLocalTransactionRequest{TableName: otsParams.TableName, PrimaryKey: pk}, nil
+}
+
+func executeStartLocalTransaction(client OtsClient, req any) (any, error) {
+	txClient, err := clientAs[TransactionClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return txClient.StartLocalTransaction(req.(*tablestore.StartLocalTransactionRequest))
+}
+
+func buildCommitTransactionRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	return &tablestore.CommitTransactionRequest{TransactionId: obj.(*Txn).id}, nil
+}
+
+func executeCommitTransaction(client OtsClient, req any) (any, error) {
+	txClient, err := clientAs[TransactionClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return txClient.CommitTransaction(req.(*tablestore.CommitTransactionRequest))
+}
+
+func buildAbortTransactionRequest(ctx context.Context, otsParams *OtsUtilsParams, logger *zerolog.Logger, obj any, params ...any) (any, error) {
+	return &tablestore.AbortTransactionRequest{TransactionId: obj.(*Txn).id}, nil
+}
+
+func executeAbortTransaction(client OtsClient, req any) (any, error) {
+	txClient, err := clientAs[TransactionClient](client)
+	if err != nil {
+		return nil, err
+	}
+	return txClient.AbortTransaction(req.(*tablestore.AbortTransactionRequest))
+}
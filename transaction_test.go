@@ -0,0 +1,251 @@
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/117503445/otsutils/otsfake"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBeginTransaction(t *testing.T) {
+	t.Run("operations carry the transaction id", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		var ids []*string
+		fake.Intercept = func(operation string, request any) error {
+			switch r := request.(type) {
+			case *tablestore.StartLocalTransactionRequest:
+				// 只携带分区键
+				ast.Equal("test_table", r.TableName)
+				ast.Equal([]KeyValue{{Key: "pk1", Value: "u1"}}, primaryKeyToKeyValues(r.PrimaryKey))
+			case *tablestore.PutRowRequest:
+				ids = append(ids, r.PutRowChange.TransactionId)
+			case *tablestore.UpdateRowRequest:
+				ids = append(ids, r.UpdateRowChange.TransactionId)
+			case *tablestore.GetRowRequest:
+				ids = append(ids, r.SingleRowQueryCriteria.TransactionId)
+			case *tablestore.DeleteRowRequest:
+				ids = append(ids, r.DeleteRowChange.TransactionId)
+			case *tablestore.CommitTransactionRequest:
+				ids = append(ids, r.TransactionId)
+			}
+			return nil
+		}
+
+		txn, err := BeginTransaction(ctx, &RangeRow{Pk1: tea.String("u1")})
+		ast.NoError(err)
+		id := txn.TransactionId()
+		ast.NotNil(id)
+
+		row := RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(1), Col1: tea.String("a")}
+		ast.NoError(PutRow(ctx, &row, PutRowParams{TransactionId: id}))
+		row.Col1 = tea.String("b")
+		ast.NoError(UpdateRow(ctx, &row, UpdateRowParams{TransactionId: id}))
+		got := RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(1)}
+		ast.NoError(GetRow(ctx, &got, GetRowParams{TransactionId: id}))
+		ast.Equal("b", *got.Col1)
+		ast.NoError(DeleteRow(ctx, &RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(2)}, DeleteRowParams{TransactionId: id}))
+		ast.NoError(txn.Commit(ctx))
+		ast.Equal([]*string{id, id, id, id, id}, ids)
+
+		// 提交后写入保留
+		fake.Intercept = nil
+		got = RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(1)}
+		ast.NoError(GetRow(ctx, &got))
+		ast.Equal("b", *got.Col1)
+	})
+
+	t.Run("abort discards the writes", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 2)
+
+		txn, err := BeginTransaction(ctx, &RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(0)})
+		ast.NoError(err)
+		expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+		ast.NoError(UpdateRow(ctx, &RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(0), Col1: tea.String("changed")},
+			UpdateRowParams{RowExistenceExpectation: &expectExist, TransactionId: txn.TransactionId()}))
+		ast.NoError(PutRow(ctx, &RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(5)}, PutRowParams{TransactionId: txn.TransactionId()}))
+		ast.NoError(txn.Abort(ctx))
+		ast.Equal(1, fake.CallCount("AbortTransaction"))
+
+		var rows []RangeRow
+		ast.NoError(GetRange(ctx, &RangeRow{Pk1: tea.String("u1")}, &RangeRow{Pk1: tea.String("u1")}, &rows))
+		ast.Len(rows, 2)
+		for _, row := range rows {
+			ast.Equal("v", *row.Col1)
+		}
+
+		// 结束后不能再次提交或回滚
+		ast.EqualError(txn.Commit(ctx), "transaction "+*txn.TransactionId()+" is already aborted")
+		ast.Error(txn.Abort(ctx))
+		ast.Equal(1, fake.CallCount("AbortTransaction"))
+		ast.Equal(0, fake.CallCount("CommitTransaction"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		_, err := BeginTransaction(ctx, RangeRow{Pk1: tea.String("u1")})
+		ast.EqualError(err, "partitionKeyObj must be a non-nil pointer to struct, got otsutils.RangeRow")
+		_, err = BeginTransaction(ctx, &RangeRow{Pk2: tea.Int64(1)})
+		ast.EqualError(err, "partition key field Pk1 is not set")
+		ast.Equal(0, fake.CallCount("StartLocalTransaction"))
+
+		// 其他分区的行不能加入事务
+		txn, err := BeginTransaction(ctx, &RangeRow{Pk1: tea.String("u1")})
+		ast.NoError(err)
+		err = PutRow(ctx, &RangeRow{Pk1: tea.String("u2"), Pk2: tea.Int64(1)}, PutRowParams{TransactionId: txn.TransactionId()})
+		ast.Equal(CodeParameterInvalid, Code(err))
+		ast.NoError(txn.Commit(ctx))
+
+		// 已结束的事务
+		err = PutRow(ctx, &RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(1)}, PutRowParams{TransactionId: txn.TransactionId()})
+		ast.Equal(CodeParameterInvalid, Code(err))
+	})
+}
+
+func TestBeginTransactionReadsFromPrimary(t *testing.T) {
+	ast := assert.New(t)
+	ctx, primary := newFakeContext(t)
+	putRangeRows(t, ctx, "u1", 1)
+
+	replica := otsfake.New()
+	replica.MustCreateTable("test_table",
+		"pk1", tablestore.PrimaryKeyType_STRING,
+		"pk2", tablestore.PrimaryKeyType_INTEGER,
+	)
+	OtsUtilsParamsFromCtx(ctx).ReadClient = replica
+	txn, err := BeginTransaction(ctx, &RangeRow{Pk1: tea.String("u1")})
+	ast.NoError(err)
+
+	// 事务内的读取使用主实例
+	row := RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(0)}
+	ast.NoError(GetRow(ctx, &row, GetRowParams{TransactionId: txn.TransactionId()}))
+	ast.Equal("v", *row.Col1)
+	ast.Equal(1, primary.CallCount("GetRow"))
+	ast.Equal(0, replica.CallCount("GetRow"))
+	ast.NoError(txn.Commit(ctx))
+}
+
+func TestWithTransaction(t *testing.T) {
+	t.Run("commits when fn succeeds", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 1)
+
+		var ids []*string
+		fake.Intercept = func(operation string, request any) error {
+			switch r := request.(type) {
+			case *tablestore.PutRowRequest:
+				ids = append(ids, r.PutRowChange.TransactionId)
+			case *tablestore.UpdateRowRequest:
+				ids = append(ids, r.UpdateRowChange.TransactionId)
+			case *tablestore.GetRowRequest:
+				ids = append(ids, r.SingleRowQueryCriteria.TransactionId)
+			}
+			return nil
+		}
+		err := WithTransaction(ctx, &RangeRow{Pk1: tea.String("u1")}, func(txCtx context.Context) error {
+			row := RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(0)}
+			if err := GetRow(txCtx, &row); err != nil {
+				return err
+			}
+			row.Col1 = tea.String(*row.Col1 + "1")
+			if err := UpdateRow(txCtx, &row); err != nil {
+				return err
+			}
+			return PutRow(txCtx, &RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(1), Col1: tea.String("new")})
+		})
+		ast.NoError(err)
+		ast.Equal(1, fake.CallCount("CommitTransaction"))
+		ast.Equal(0, fake.CallCount("AbortTransaction"))
+		if ast.Len(ids, 3) {
+			ast.NotNil(ids[0])
+			ast.Equal([]*string{ids[0], ids[0], ids[0]}, ids)
+		}
+
+		// 事务外的操作不携带事务 ID
+		ids = nil
+		got := RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(0)}
+		ast.NoError(GetRow(ctx, &got))
+		ast.Equal("v1", *got.Col1)
+		ast.Equal([]*string{nil}, ids)
+	})
+
+	t.Run("aborts when fn fails", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 1)
+
+		errInsufficient := errors.New("insufficient balance")
+		err := WithTransaction(ctx, &RangeRow{Pk1: tea.String("u1")}, func(txCtx context.Context) error {
+			if err := UpdateRow(txCtx, &RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(0), Col1: tea.String("changed")}); err != nil {
+				return err
+			}
+			return errInsufficient
+		})
+		ast.ErrorIs(err, errInsufficient)
+		ast.Equal(1, fake.CallCount("AbortTransaction"))
+		ast.Equal(0, fake.CallCount("CommitTransaction"))
+
+		got := RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(0)}
+		ast.NoError(GetRow(ctx, &got))
+		ast.Equal("v", *got.Col1)
+	})
+
+	t.Run("aborts and panics again when fn panics", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 1)
+
+		ast.PanicsWithValue("boom", func() {
+			_ = WithTransaction(ctx, &RangeRow{Pk1: tea.String("u1")}, func(txCtx context.Context) error {
+				ast.NoError(DeleteRow(txCtx, &RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(0)}))
+				panic("boom")
+			})
+		})
+		ast.Equal(1, fake.CallCount("AbortTransaction"))
+
+		// 删除已回滚
+		got := RangeRow{Pk1: tea.String("u1"), Pk2: tea.Int64(0)}
+		ast.NoError(GetRow(ctx, &got))
+		ast.Equal("v", *got.Col1)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		// 不支持嵌套
+		err := WithTransaction(ctx, &RangeRow{Pk1: tea.String("u1")}, func(txCtx context.Context) error {
+			return WithTransaction(txCtx, &RangeRow{Pk1: tea.String("u1")}, func(context.Context) error {
+				return nil
+			})
+		})
+		ast.EqualError(err, "WithTransaction can not be nested: transaction fake-txn-1 is already open on the context")
+		ast.Equal(1, fake.CallCount("StartLocalTransaction"))
+		ast.Equal(1, fake.CallCount("AbortTransaction"))
+
+		ast.EqualError(WithTransaction(ctx, &RangeRow{Pk1: tea.String("u1")}, nil), "fn can not be nil")
+		err = WithTransaction(ctx, &RangeRow{}, func(context.Context) error { return nil })
+		ast.EqualError(err, "partition key field Pk1 is not set")
+		ast.Equal(1, fake.CallCount("StartLocalTransaction"))
+
+		// 提交失败时返回错误
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "CommitTransaction" {
+				return &tablestore.OtsError{Code: CodeServerBusy, Message: "Server is busy."}
+			}
+			return nil
+		}
+		err = WithTransaction(ctx, &RangeRow{Pk1: tea.String("u1")}, func(context.Context) error { return nil })
+		ast.Equal(CodeServerBusy, Code(err))
+	})
+}
@@ -0,0 +1,208 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/rs/zerolog"
+)
+
+// DefaultTruncateWorkers is the number of BatchWriteRow requests TruncateTable sends at the same
+// time when TruncateTableParams.Workers is not set.
+const DefaultTruncateWorkers = 4
+
+// TruncateTable deletes every row of the table of OtsUtilsParams, keeping the table, its
+// indexes and its settings, and returns the number of rows deleted. It is meant for tests that
+// reset a table between cases, where deleting and recreating it would wait for the new schema
+// to propagate.
+//
+// The whole primary key range is read MaxBatchWriteRows primary keys at a time, without the
+// attribute columns, and each page is deleted with one BatchWriteRow by one of Workers
+// goroutines. The rows of a batch the service throttles are sent again after RetryBackoff,
+// until Retries consecutive attempts delete none of them. The first error stops the scan and
+// the other batches; the count returned with it is the number of rows deleted before it.
+// Rows written during the call may be left in place.
+//
+// Example usage:
+//
+//	// Fails unless the table of ctx is test_users
+//	n, err := TruncateTable(ctx, TruncateTableParams{TableName: "test_users"})
+func TruncateTable(ctx context.Context, params ...TruncateTableParams) (int, error) {
+	var p TruncateTableParams
+	if len(params) > 0 {
+		p = params[0]
+	}
+	return truncateTable(ctx, p, nil)
+}
+
+// TruncateJob is a TruncateTable running in the background, started by StartTruncateTable.
+type TruncateJob struct {
+	job *backgroundJob[int]
+}
+
+// StartTruncateTable starts TruncateTable on a goroutine of its own and returns at once. Wait
+// returns its outcome, and Close stops it early.
+func StartTruncateTable(ctx context.Context, params ...TruncateTableParams) *TruncateJob {
+	return &TruncateJob{job: startBackgroundJob(ctx, func(ctx context.Context, stop <-chan struct{}) (int, error) {
+		var p TruncateTableParams
+		if len(params) > 0 {
+			p = params[0]
+		}
+		return truncateTable(ctx, p, stop)
+	})}
+}
+
+// Wait waits for the end of the truncation and returns its outcome, as TruncateTable.
+func (j *TruncateJob) Wait() (int, error) {
+	return j.job.wait()
+}
+
+// Close stops the truncation and flushes it: no more page is read, and the rows already read are
+// deleted. When ctx is done first, the deletes still pending are cancelled and Close returns
+// ctx.Err() once they returned; otherwise it returns the error of the truncation, and Wait the
+// rows deleted. Close may be called more than once, and after Wait.
+func (j *TruncateJob) Close(ctx context.Context) error {
+	return j.job.close(ctx)
+}
+
+// truncateTable is TruncateTable, reading no page once stop is closed.
+func truncateTable(ctx context.Context, p TruncateTableParams, stop <-chan struct{}) (int, error) {
+	if p.Workers < 0 {
+		return 0, fmt.Errorf("Workers must not be negative, got %d", p.Workers)
+	}
+	workers := p.Workers
+	if workers == 0 {
+		workers = DefaultTruncateWorkers
+	}
+	if p.Retries <= 0 {
+		p.Retries = DefaultBatchWriteRetries
+	}
+	if p.RetryBackoff <= 0 {
+		p.RetryBackoff = DefaultBatchWriteBackoff
+	}
+	if tableName := otsUtilsParamsFromCtx(ctx).TableName; p.TableName != "" && p.TableName != tableName {
+		return 0, fmt.Errorf("TableName %q does not match the table of OtsUtilsParams %q", p.TableName, tableName)
+	}
+
+	desc, err := TableMeta(ctx)
+	if err != nil {
+		return 0, err
+	}
+	start, end := &tablestore.PrimaryKey{}, &tablestore.PrimaryKey{}
+	for _, pk := range desc.PrimaryKeys {
+		start.AddPrimaryKeyColumnWithMinValue(pk.Name)
+		end.AddPrimaryKeyColumnWithMaxValue(pk.Name)
+	}
+	// Reading only the first pk column still returns the whole primary key, and every row has it.
+	scanParams := GetRangeParams{ColumnsToGet: []string{desc.PrimaryKeys[0].Name}}
+
+	scanCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	scanCtx, job := startJob(scanCtx, "TruncateTable", p.Listener)
+
+	var deleted atomic.Int64
+	var mu sync.Mutex
+	var errs []error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		// Once cancelled, the other workers only report the cancellation
+		if scanCtx.Err() == nil || !errors.Is(err, scanCtx.Err()) {
+			errs = append(errs, err)
+		}
+		cancel()
+	}
+
+	batches := make(chan []*tablestore.PrimaryKey)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pks := range batches {
+				if err := truncateBatch(scanCtx, pks, p, &deleted); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+
+	page := &rangePage{StartPrimaryKey: start, EndPrimaryKey: end, Limit: MaxBatchWriteRows}
+scan:
+	for page != nil {
+		select {
+		case <-stop:
+			break scan
+		default:
+		}
+		var pks []*tablestore.PrimaryKey
+		next := (*rangePage)(nil)
+		handleScan := func(ctx context.Context, logger *zerolog.Logger, resp any, obj any) error {
+			r := resp.(*tablestore.GetRangeResponse)
+			job.consumed(r.ConsumedCapacityUnit)
+			for _, row := range r.Rows {
+				pks = append(pks, row.PrimaryKey)
+			}
+			if r.NextStartPrimaryKey != nil {
+				next = &rangePage{StartPrimaryKey: r.NextStartPrimaryKey, EndPrimaryKey: end, Limit: MaxBatchWriteRows}
+			}
+			return nil
+		}
+		if err := executeOTSOperation(scanCtx, "TruncateTable", page, buildGetRangeRequest, executeGetRange, handleScan, scanParams); err != nil {
+			fail(err)
+			break
+		}
+		page = next
+
+		if len(pks) > 0 {
+			select {
+			case batches <- pks:
+			case <-scanCtx.Done():
+				break scan
+			}
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	err = ctx.Err()
+	if len(errs) > 0 {
+		err = errors.Join(errs...)
+	}
+	job.finish(err)
+	return int(deleted.Load()), err
+}
+
+// truncateBatch deletes the rows of pks, retrying the rows the service throttles.
+func truncateBatch(ctx context.Context, pks []*tablestore.PrimaryKey, p TruncateTableParams, deleted *atomic.Int64) error {
+	tableName := otsUtilsParamsFromCtx(ctx).TableName
+	changes := make([]tablestore.RowChange, len(pks))
+	for i, pk := range pks {
+		change := &tablestore.DeleteRowChange{TableName: tableName, PrimaryKey: pk}
+		change.SetCondition(tablestore.RowExistenceExpectation_IGNORE)
+		changes[i] = change
+	}
+
+	started := time.Now()
+	results, err := writeBatchWithRetry(ctx, "TruncateTable", changes, nil, 0, p.Retries, p.RetryBackoff)
+	batchDeleted := 0
+	var cu tablestore.ConsumedCapacityUnit
+	for _, result := range results {
+		addCapacity(&cu, result.ConsumedCapacity)
+		if result.Err == nil {
+			batchDeleted++
+		} else if err == nil {
+			err = result.Err
+		}
+	}
+	deleted.Add(int64(batchDeleted))
+	jobFromCtx(ctx).chunk(0, batchDeleted, cu, started)
+	return err
+}
@@ -0,0 +1,85 @@
+package otsutils
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateTable(t *testing.T) {
+	t.Run("deletes every row", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", MaxBatchWriteRows+20)
+		putRangeRows(t, ctx, "u2", MaxBatchWriteRows)
+
+		n, err := TruncateTable(ctx, TruncateTableParams{TableName: "test_table", Workers: 2})
+		ast.NoError(err)
+		ast.Equal(2*MaxBatchWriteRows+20, n)
+		ast.Equal(3, fake.CallCount("BatchWriteRow"))
+
+		var rows []RangeRow
+		ast.NoError(GetRange(ctx, &RangeRow{}, &RangeRow{}, &rows))
+		ast.Empty(rows)
+
+		// 空表无需写入
+		n, err = TruncateTable(ctx)
+		ast.NoError(err)
+		ast.Zero(n)
+		ast.Equal(3, fake.CallCount("BatchWriteRow"))
+	})
+
+	t.Run("throttled batches are retried", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 2*MaxBatchWriteRows+10)
+
+		// 前两个批次被限流，随后重新发送
+		var calls atomic.Int32
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "BatchWriteRow" && calls.Add(1) <= 2 {
+				return &tablestore.OtsError{Code: CodeNotEnoughCapacityUnit, Message: "Remaining capacity unit is not enough."}
+			}
+			return nil
+		}
+		n, err := TruncateTable(ctx, TruncateTableParams{RetryBackoff: time.Millisecond})
+		ast.NoError(err)
+		ast.Equal(2*MaxBatchWriteRows+10, n)
+		ast.Equal(5, fake.CallCount("BatchWriteRow"))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		putRangeRows(t, ctx, "u1", 3)
+
+		_, err := TruncateTable(ctx, TruncateTableParams{TableName: "prod_table"})
+		ast.EqualError(err, `TableName "prod_table" does not match the table of OtsUtilsParams "test_table"`)
+		ast.Equal(0, fake.CallCount("GetRange"))
+
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "BatchWriteRow" {
+				return &tablestore.OtsError{Code: CodeServerBusy, Message: "Server is busy."}
+			}
+			return nil
+		}
+		n, err := TruncateTable(ctx, TruncateTableParams{Retries: 2, RetryBackoff: time.Millisecond})
+		ast.Zero(n)
+		ast.Equal(CodeServerBusy, Code(err))
+		ast.Equal(2, fake.CallCount("BatchWriteRow"))
+
+		// 非暂时性错误立即返回
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "BatchWriteRow" {
+				return &tablestore.OtsError{Code: CodeAuthFailed, Message: "denied"}
+			}
+			return nil
+		}
+		_, err = TruncateTable(ctx)
+		ast.Equal(CodeAuthFailed, Code(err))
+		ast.Equal(3, fake.CallCount("BatchWriteRow"))
+	})
+}
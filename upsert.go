@@ -0,0 +1,32 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// Upsert creates the row described by obj, or updates it when it already exists, and reports
+// whether a new row was created.
+//
+// The row is first put under EXPECT_NOT_EXIST. Only when that fails with
+// CodeConditionCheckFail is it updated under EXPECT_EXIST, which writes the columns of obj and
+// keeps the other columns of the stored row, as UpdateRow does. Any other error, such as
+// throttling, is returned without a fallback. If the row is deleted between the two steps, the
+// update fails with CodeConditionCheckFail as well.
+//
+// Example usage:
+//
+//	created, err := Upsert(ctx, &row)
+func Upsert(ctx context.Context, obj any) (created bool, err error) {
+	expectNotExist := tablestore.RowExistenceExpectation_EXPECT_NOT_EXIST
+	err = executeOTSOperation(ctx, "Upsert", obj, buildPutRowRequest, executePutRow, handlePutRowResponse, PutRowParams{RowExistenceExpectation: &expectNotExist})
+	if !IsConditionCheckFail(err) {
+		return err == nil, err
+	}
+
+	expectExist := tablestore.RowExistenceExpectation_EXPECT_EXIST
+	err = executeOTSOperation(ctx, "Upsert", obj, buildUpdateRowRequest, executeUpdateRow, nil, UpdateRowParams{RowExistenceExpectation: &expectExist})
+	return false, err
+}
@@ -0,0 +1,69 @@
+package otsutils
+
+import (
+	"testing"
+
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpsert(t *testing.T) {
+	t.Run("creates then updates", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		created, err := Upsert(ctx, &RangeRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v1")})
+		ast.NoError(err)
+		ast.True(created)
+
+		created, err = Upsert(ctx, &RangeRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v2")})
+		ast.NoError(err)
+		ast.False(created)
+		ast.Equal(2, fake.CallCount("PutRow"))
+		ast.Equal(1, fake.CallCount("UpdateRow"))
+
+		row := RangeRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)}
+		ast.NoError(GetRow(ctx, &row))
+		ast.Equal("v2", tea.StringValue(row.Col1))
+	})
+
+	t.Run("other errors do not fall back", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+
+		busy := &tablestore.OtsError{Code: CodeServerBusy, Message: "Server is busy."}
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "PutRow" {
+				return busy
+			}
+			return nil
+		}
+		created, err := Upsert(ctx, &RangeRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)})
+		ast.ErrorIs(err, busy)
+		ast.False(created)
+		ast.Equal(0, fake.CallCount("UpdateRow"))
+	})
+
+	t.Run("row deleted before the update", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newFakeContext(t)
+		ast.NoError(PutRow(ctx, &RangeRow{Pk1: tea.String("a"), Pk2: tea.Int64(1)}))
+
+		// 模拟两步之间行被删除
+		fake.Intercept = func(operation string, request any) error {
+			if operation == "UpdateRow" {
+				_, err := fake.DeleteRow(&tablestore.DeleteRowRequest{DeleteRowChange: &tablestore.DeleteRowChange{
+					TableName:  "test_table",
+					PrimaryKey: request.(*tablestore.UpdateRowRequest).UpdateRowChange.PrimaryKey,
+					Condition:  &tablestore.RowCondition{RowExistenceExpectation: tablestore.RowExistenceExpectation_IGNORE},
+				}})
+				return err
+			}
+			return nil
+		}
+		created, err := Upsert(ctx, &RangeRow{Pk1: tea.String("a"), Pk2: tea.Int64(1), Col1: tea.String("v")})
+		ast.True(IsConditionCheckFail(err))
+		ast.False(created)
+	})
+}
@@ -0,0 +1,94 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+
+	"github.com/117503445/otsutils/internal/rowrules"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// isUUIDType reports whether t, or the type it points to, is a UUID type: a [16]byte array
+// implementing encoding.TextMarshaler and encoding.TextUnmarshaler, such as uuid.UUID of
+// github.com/google/uuid. A UUID is stored as its text form in a STRING column, or as its 16
+// bytes in a BINARY column when the field is tagged `otstype:"binary"`.
+func isUUIDType(t reflect.Type) bool {
+	base := nativeBaseType(t)
+	if base.Kind() != reflect.Array || base.Len() != 16 || base.Elem().Kind() != reflect.Uint8 {
+		return false
+	}
+	ptr := reflect.PointerTo(base)
+	return ptr.Implements(textMarshalerType) && ptr.Implements(textUnmarshalerType)
+}
+
+// encodeUUID returns the column value of the UUID field fm: its text form, or its bytes when
+// the field is tagged `otstype:"binary"`. skip is true when the field is a nil pointer, or a
+// zero value field tagged omitempty.
+func (fm *fieldMeta) encodeUUID(field reflect.Value) (value any, skip bool, err error) {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil, true, nil
+		}
+		field = field.Elem()
+	} else if fm.omitEmpty && field.IsZero() {
+		return nil, true, nil
+	}
+
+	if fm.otsType == rowrules.OtsTypeBinary {
+		b := make([]byte, 16)
+		reflect.Copy(reflect.ValueOf(b), field)
+		return b, false, nil
+	}
+	p := reflect.New(field.Type())
+	p.Elem().Set(field)
+	text, err := p.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return nil, false, fmt.Errorf("field %s: %w", fm.name, err)
+	}
+	return string(text), false, nil
+}
+
+// decodeUUID assigns the column value, the text form or the 16 bytes of a UUID, to the UUID
+// field fm, allocating the field when it is a pointer.
+func (fm *fieldMeta) decodeUUID(field reflect.Value, value any) error {
+	target := reflect.New(nativeBaseType(field.Type()))
+	switch v := value.(type) {
+	case string:
+		if err := target.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("field %s: malformed UUID %q: %w", fm.name, v, err)
+		}
+	case []byte:
+		if len(v) != 16 {
+			return fmt.Errorf("field %s: malformed UUID: a binary UUID has 16 bytes, got %d", fm.name, len(v))
+		}
+		reflect.Copy(target.Elem(), reflect.ValueOf(v))
+	default:
+		return fmt.Errorf("field %s: a UUID column must be a string or binary, got %T", fm.name, value)
+	}
+
+	if field.Kind() == reflect.Ptr {
+		field.Set(target)
+	} else {
+		field.Set(target.Elem())
+	}
+	return nil
+}
+
+// primaryKeyType returns the primary key column type of the pk field fm, and false for the
+// types handled by a serializer.
+func (fm *fieldMeta) primaryKeyType() (tablestore.PrimaryKeyType, bool) {
+	if fm.uuid {
+		if fm.otsType == rowrules.OtsTypeBinary {
+			return tablestore.PrimaryKeyType_BINARY, true
+		}
+		return tablestore.PrimaryKeyType_STRING, true
+	}
+	return primaryKeyFieldType(fm.typ)
+}
@@ -0,0 +1,93 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WithContextValidated is like WithContext but also checks that the table exists,
+// so a wrong table name fails here instead of at the first operation.
+// The fetched description is stored in the TableMeta cache.
+// When the table is missing the error suggests similarly named tables from ListTable.
+//
+// Example usage:
+//
+//	ctx, err := params.WithContextValidated(ctx)
+//	if err != nil {
+//	    return err // table 'user' not found on instance 'prod-a'; did you mean 'users'?
+//	}
+func (otsUtilsParams *OtsUtilsParams) WithContextValidated(ctx context.Context) (context.Context, error) {
+	ctx = otsUtilsParams.WithContext(ctx)
+
+	InvalidateTableMeta(otsUtilsParams.TableName)
+	if _, err := TableMeta(ctx); err != nil {
+		if Code(err) != CodeObjectNotExist {
+			return nil, fmt.Errorf("validate table '%s': %w", otsUtilsParams.TableName, err)
+		}
+
+		msg := fmt.Sprintf("table '%s' not found", otsUtilsParams.TableName)
+		if otsUtilsParams.InstanceName != "" {
+			msg += fmt.Sprintf(" on instance '%s'", otsUtilsParams.InstanceName)
+		}
+		if tableClient, ok := otsUtilsParams.Client.(TableClient); ok {
+			if resp, listErr := tableClient.ListTable(); listErr == nil {
+				if suggestions := similarNames(otsUtilsParams.TableName, resp.TableNames); len(suggestions) > 0 {
+					msg += fmt.Sprintf("; did you mean '%s'?", strings.Join(suggestions, "', '"))
+				}
+			}
+		}
+		return nil, fmt.Errorf("%s: %w", msg, err)
+	}
+
+	return ctx, nil
+}
+
+// similarNames returns up to 3 candidates close to name, closest first.
+func similarNames(name string, candidates []string) []string {
+	type scored struct {
+		name     string
+		distance int
+	}
+
+	maxDistance := max(2, len(name)/3)
+	var matches []scored
+	for _, c := range candidates {
+		d := levenshtein(strings.ToLower(name), strings.ToLower(c))
+		if d <= maxDistance {
+			matches = append(matches, scored{c, d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+
+	var result []string
+	for i := 0; i < len(matches) && i < 3; i++ {
+		result = append(result, matches[i].name)
+	}
+	return result
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
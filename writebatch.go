@@ -0,0 +1,124 @@
+// Package otsutils provides utilities for working with Alibaba Cloud Tablestore (OTS).
+package otsutils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// TableNamer is implemented by row structs that know their table, for WriteBatch.
+type TableNamer interface {
+	TableName() string
+}
+
+// WriteBatch collects puts, updates and deletes of rows of different types and tables, and
+// writes them with BatchWrite, sharing the requests between the tables. The table of each row is
+// the TableName of its op when added with Add, or else the table the row names: its TableName
+// method, see TableNamer, or the table tag of a blank field, e.g.
+//
+//	type OrderLine struct {
+//	    _       struct{} `table:"order_lines"`
+//	    OrderID *string  `pk:"1"`
+//	    Line    *int64   `pk:"2"`
+//	}
+//
+// The table of the context is never used: a row whose table can not be resolved fails Write
+// before anything is sent. The zero value is an empty batch.
+//
+// Example usage:
+//
+//	result, err := NewWriteBatch().
+//	    Put(&order).
+//	    Put(&line).
+//	    Add(BatchOp{Kind: PutOp, Obj: &event, TableName: "outbox_" + region}).
+//	    Write(ctx)
+type WriteBatch struct {
+	ops []BatchOp
+}
+
+// NewWriteBatch returns an empty WriteBatch.
+func NewWriteBatch() *WriteBatch {
+	return &WriteBatch{}
+}
+
+// Put adds a put of obj, as PutRow does.
+func (b *WriteBatch) Put(obj any, params ...PutRowParams) *WriteBatch {
+	op := BatchOp{Kind: PutOp, Obj: obj}
+	if len(params) > 0 {
+		op.Params = params[0]
+	}
+	return b.Add(op)
+}
+
+// Update adds an update of obj, as UpdateRow does.
+func (b *WriteBatch) Update(obj any, params ...UpdateRowParams) *WriteBatch {
+	op := BatchOp{Kind: UpdateOp, Obj: obj}
+	if len(params) > 0 {
+		op.Params = params[0]
+	}
+	return b.Add(op)
+}
+
+// Delete adds a delete of obj, as DeleteRow does.
+func (b *WriteBatch) Delete(obj any, params ...DeleteRowParams) *WriteBatch {
+	op := BatchOp{Kind: DeleteOp, Obj: obj}
+	if len(params) > 0 {
+		op.Params = params[0]
+	}
+	return b.Add(op)
+}
+
+// Add adds op. Its TableName, when set, overrides the table of the row.
+func (b *WriteBatch) Add(op BatchOp) *WriteBatch {
+	b.ops = append(b.ops, op)
+	return b
+}
+
+// Len returns the number of ops of the batch.
+func (b *WriteBatch) Len() int {
+	return len(b.ops)
+}
+
+// Write resolves the table of every op, then writes them with BatchWrite, with the client of
+// ctx. The result reports each op at the index it was added at, whatever its table, and the
+// *RowError of a failed op names its table. An op whose table can not be resolved fails the call
+// with a nil result and no change applied.
+func (b *WriteBatch) Write(ctx context.Context, params ...BatchWriteParams) (*BatchWriteResult, error) {
+	ops := make([]BatchOp, len(b.ops))
+	for i, op := range b.ops {
+		if op.TableName == "" {
+			tableName, err := rowTableName(op.Obj)
+			if err != nil {
+				return nil, fmt.Errorf("op %d: %w", i, err)
+			}
+			op.TableName = tableName
+		}
+		ops[i] = op
+	}
+	return batchWrite(ctx, "WriteBatch", ops, params...)
+}
+
+// rowTableName returns the table the row obj names, with its TableName method or the table tag of
+// one of its fields.
+func rowTableName(obj any) (string, error) {
+	if namer, ok := obj.(TableNamer); ok {
+		if tableName := namer.TableName(); tableName != "" {
+			return tableName, nil
+		}
+		return "", fmt.Errorf("%T names no table", obj)
+	}
+
+	t := reflect.TypeOf(obj)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil && t.Kind() == reflect.Struct {
+		for i := range t.NumField() {
+			if tableName := t.Field(i).Tag.Get("table"); tableName != "" {
+				return tableName, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("can not resolve the table of %T: it implements no TableNamer, has no table tag and the op no TableName", obj)
+}
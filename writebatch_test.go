@@ -0,0 +1,160 @@
+package otsutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/117503445/otsutils/otsfake"
+	"github.com/alibabacloud-go/tea/tea"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/stretchr/testify/assert"
+)
+
+// orderRow 通过 TableNamer 指定表
+type orderRow struct {
+	ID     *string `json:"id" pk:"1"`
+	Status *string `json:"status"`
+}
+
+func (orderRow) TableName() string { return "orders" }
+
+// orderLineRow 通过 table 标签指定表
+type orderLineRow struct {
+	_       struct{} `table:"order_lines"`
+	OrderID *string  `json:"order_id" pk:"1"`
+	Line    *int64   `json:"line" pk:"2"`
+	Sku     *string  `json:"sku"`
+}
+
+// outboxRow 通过 TableNamer 指定表
+type outboxRow struct {
+	ID    *string `json:"id" pk:"1"`
+	Event *string `json:"event"`
+}
+
+func (*outboxRow) TableName() string { return "outbox" }
+
+// newWriteBatchContext 返回带有 orders、order_lines 和 outbox 三张表的上下文，上下文的表为 test_table
+func newWriteBatchContext(t *testing.T) (context.Context, *otsfake.Client) {
+	ctx, fake := newFakeContext(t)
+	fake.MustCreateTable("orders", "id", tablestore.PrimaryKeyType_STRING)
+	fake.MustCreateTable("order_lines", "order_id", tablestore.PrimaryKeyType_STRING, "line", tablestore.PrimaryKeyType_INTEGER)
+	fake.MustCreateTable("outbox", "id", tablestore.PrimaryKeyType_STRING)
+	return ctx, fake
+}
+
+func TestWriteBatch(t *testing.T) {
+	t.Run("three tables with a failure mid-batch", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newWriteBatchContext(t)
+		ast.NoError(PutRow(ctx, &TestRow{Pk1: tea.String("ctx"), Pk2: tea.Int64(1)}))
+
+		// 第二行订单明细已存在，默认 EXPECT_NOT_EXIST 的写入失败
+		existing := &orderLineRow{OrderID: tea.String("o1"), Line: tea.Int64(2), Sku: tea.String("old")}
+		_, err := NewWriteBatch().Put(existing).Write(ctx)
+		ast.NoError(err)
+
+		// 上下文的表只能通过 TableName 指定
+		calls := fake.CallCount("BatchWriteRow")
+		batch := NewWriteBatch().
+			Put(&orderRow{ID: tea.String("o1"), Status: tea.String("new")}).
+			Put(&orderLineRow{OrderID: tea.String("o1"), Line: tea.Int64(1), Sku: tea.String("a")}).
+			Put(&outboxRow{ID: tea.String("e1"), Event: tea.String("created")}).
+			Put(&orderLineRow{OrderID: tea.String("o1"), Line: tea.Int64(2), Sku: tea.String("b")}).
+			Put(&outboxRow{ID: tea.String("e2"), Event: tea.String("lines")}).
+			Add(BatchOp{Kind: DeleteOp, Obj: &TestRow{Pk1: tea.String("ctx"), Pk2: tea.Int64(1)}, TableName: "test_table"}).
+			Put(&orderLineRow{OrderID: tea.String("o1"), Line: tea.Int64(3), Sku: tea.String("c")})
+		ast.Equal(7, batch.Len())
+		result, err := batch.Write(ctx)
+		ast.Equal(calls+1, fake.CallCount("BatchWriteRow"))
+
+		var batchErr *BatchError
+		ast.True(errors.As(err, &batchErr))
+		ast.Equal([]int{3}, batchErr.Failed())
+		if ast.Len(result.Ops, 7) {
+			var rowErr *RowError
+			ast.True(errors.As(result.Ops[3].Err, &rowErr))
+			ast.Equal("order_lines", rowErr.TableName)
+			ast.Equal(3, rowErr.Index)
+			ast.Equal(CodeConditionCheckFail, Code(rowErr))
+			for _, i := range []int{0, 1, 2, 4, 5, 6} {
+				ast.NoError(result.Ops[i].Err, "op %d", i)
+				ast.NotNil(result.Ops[i].ConsumedCapacity, "op %d", i)
+			}
+		}
+		ast.Equal(int32(6), result.ConsumedCapacity.Write)
+
+		lines := []orderLineRow{
+			{OrderID: tea.String("o1"), Line: tea.Int64(1)},
+			{OrderID: tea.String("o1"), Line: tea.Int64(2)},
+			{OrderID: tea.String("o1"), Line: tea.Int64(3)},
+		}
+		linesParams := OtsUtilsParams{Client: fake, TableName: "order_lines"}
+		ast.NoError(BatchGetRows(linesParams.WithContext(ctx), &lines))
+		ast.Equal("a", *lines[0].Sku)
+		ast.Equal("old", *lines[1].Sku)
+		ast.Equal("c", *lines[2].Sku)
+
+		var events []outboxRow
+		outboxParams := OtsUtilsParams{Client: fake, TableName: "outbox"}
+		ast.NoError(GetRange(outboxParams.WithContext(ctx), &outboxRow{}, &outboxRow{}, &events))
+		ast.Len(events, 2)
+
+		exists, err := ExistsRow(ctx, &TestRow{Pk1: tea.String("ctx"), Pk2: tea.Int64(1)})
+		ast.NoError(err)
+		ast.False(exists)
+	})
+
+	t.Run("override takes precedence over the row", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newWriteBatchContext(t)
+		fake.MustCreateTable("outbox_eu", "id", tablestore.PrimaryKeyType_STRING)
+
+		result, err := NewWriteBatch().
+			Add(BatchOp{Kind: PutOp, Obj: &outboxRow{ID: tea.String("e1")}, TableName: "outbox_eu"}).
+			Write(ctx)
+		ast.NoError(err)
+		ast.Len(result.Ops, 1)
+
+		euParams := OtsUtilsParams{Client: fake, TableName: "outbox_eu"}
+		exists, err := ExistsRow(euParams.WithContext(ctx), &outboxRow{ID: tea.String("e1")})
+		ast.NoError(err)
+		ast.True(exists)
+		outboxParams := OtsUtilsParams{Client: fake, TableName: "outbox"}
+		exists, err = ExistsRow(outboxParams.WithContext(ctx), &outboxRow{ID: tea.String("e1")})
+		ast.NoError(err)
+		ast.False(exists)
+	})
+
+	t.Run("invalid tables send nothing", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newWriteBatchContext(t)
+
+		// TestRow 不能解析出表，不会写入上下文的表
+		_, err := NewWriteBatch().Put(&outboxRow{ID: tea.String("e1")}).Delete(&TestRow{Pk1: tea.String("u1"), Pk2: tea.Int64(1)}).Write(ctx)
+		ast.ErrorContains(err, "op 1: can not resolve the table of *otsutils.TestRow")
+
+		_, err = NewWriteBatch().Put(&outboxRow{ID: tea.String("e1")}).Put(nil).Write(ctx)
+		ast.ErrorContains(err, "op 1: can not resolve the table of <nil>")
+
+		_, err = NewWriteBatch().
+			Put(&outboxRow{ID: tea.String("e1")}).
+			Add(BatchOp{Kind: PutOp, Obj: &outboxRow{ID: tea.String("e2")}, TableName: "bad table"}).
+			Write(ctx)
+		ast.ErrorContains(err, "op 1:")
+		ast.Equal(0, fake.CallCount("BatchWriteRow"))
+	})
+
+	t.Run("atomic batch can not span tables", func(t *testing.T) {
+		ast := assert.New(t)
+		ctx, fake := newWriteBatchContext(t)
+
+		_, err := NewWriteBatch().
+			Put(&orderRow{ID: tea.String("o1")}).
+			Put(&outboxRow{ID: tea.String("o1")}).
+			Write(ctx, BatchWriteParams{Atomic: true})
+		ast.ErrorContains(err, "atomic batch spans tables: row 1 is of table 'outbox', row 0 of table 'orders'")
+		ast.Equal(0, fake.CallCount("BatchWriteRow"))
+	})
+}